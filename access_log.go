@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// accessLog HTTP requests log for operators, written to stdout or appended to the file.
+type accessLog struct {
+	mux    sync.Mutex
+	out    io.Writer
+	format string
+	// user header set by the proxy.
+	user   string
+	skip   []string
+	rate   float64
+	random *rand.Rand
+}
+
+// accessLogEntry fields of the logged request, JSON format keeps the names.
+type accessLogEntry struct {
+	Time      string  `json:"time"`
+	RequestID string  `json:"request_id"`
+	RemoteIP  string  `json:"remote_ip"`
+	User      string  `json:"user"`
+	Method    string  `json:"method"`
+	URI       string  `json:"uri"`
+	Protocol  string  `json:"protocol"`
+	Status    int     `json:"status"`
+	Bytes     int64   `json:"bytes"`
+	LatencyMS float64 `json:"latency_ms"`
+	Referer   string  `json:"referer"`
+	UserAgent string  `json:"user_agent"`
+}
+
+// newAccessLog open the access log configured by access_log, nil when it is disabled.
+func newAccessLog(config configData) (*accessLog, error) {
+	l := &accessLog{
+		format: config.AccessLogFormat,
+		user:   config.UserHeader,
+		skip:   config.AccessLogSkipPaths,
+		rate:   config.AccessLogSampleRate,
+		random: rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	switch config.AccessLog {
+	case "":
+		return nil, nil
+	case "stdout":
+		l.out = os.Stdout
+	default:
+		f, err := os.OpenFile(config.AccessLog, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+		if err != nil {
+			return nil, err
+		}
+		l.out = f
+	}
+	return l, nil
+}
+
+// sampled whether to log the request, server errors are always logged.
+func (l *accessLog) sampled(status int) bool {
+	if l.rate >= 1 || status >= 500 {
+		return true
+	}
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	return l.random.Float64() < l.rate
+}
+
+// middleware log the requests except the skipped paths, e.g. health checks of the load balancer.
+func (l *accessLog) middleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		req := c.Request()
+		if repoPatternsMatch(l.skip, req.URL.Path) {
+			return next(c)
+		}
+		start := time.Now()
+		err := next(c)
+		if err != nil {
+			// Let the error handler write the response to log its status.
+			c.Error(err)
+		}
+		res := c.Response()
+		if !l.sampled(res.Status) {
+			return nil
+		}
+		e := accessLogEntry{
+			RequestID: requestIDOf(c),
+			RemoteIP:  c.RealIP(),
+			User:      req.Header.Get(l.user),
+			Method:    req.Method,
+			URI:       redactedURI(req),
+			Protocol:  req.Proto,
+			Status:    res.Status,
+			Bytes:     res.Size,
+			LatencyMS: float64(time.Now().Sub(start).Microseconds()) / 1000,
+			Referer:   req.Referer(),
+			UserAgent: req.UserAgent(),
+		}
+		line := l.combined(e, start)
+		if l.format == "json" {
+			e.Time = start.Format(time.RFC3339)
+			b, _ := json.Marshal(e)
+			line = string(b)
+		}
+		l.mux.Lock()
+		fmt.Fprintln(l.out, line)
+		l.mux.Unlock()
+		return nil
+	}
+}
+
+// redactedURI request URI with the token query parameter masked, it is sent so by the webhooks of Quay.
+func redactedURI(req *http.Request) string {
+	q := req.URL.Query()
+	if q.Get("token") == "" {
+		return req.RequestURI
+	}
+	q.Set("token", "REDACTED")
+	u := *req.URL
+	u.RawQuery = q.Encode()
+	return u.RequestURI()
+}
+
+// combined the entry in the combined log format of Apache and nginx followed by the request ID.
+func (l *accessLog) combined(e accessLogEntry, start time.Time) string {
+	dash := func(s string) string {
+		if s == "" {
+			return "-"
+		}
+		return strings.Replace(s, `"`, `\"`, -1)
+	}
+	return fmt.Sprintf(`%s - %s [%s] "%s %s %s" %d %d "%s" "%s" %s`, e.RemoteIP, dash(e.User),
+		start.Format("02/Jan/2006:15:04:05 -0700"), e.Method, dash(e.URI), e.Protocol, e.Status, e.Bytes,
+		dash(e.Referer), dash(e.UserAgent), dash(e.RequestID))
+}
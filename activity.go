@@ -0,0 +1,75 @@
+package main
+
+import (
+	"time"
+)
+
+// activityWeeks weeks of the push calendar, shorter when the events are deleted sooner.
+const activityWeeks = 53
+
+// activityDay cell of the push calendar, Level 0-4 is the shade relative to the busiest day.
+type activityDay struct {
+	Date  string
+	Count int
+	Level int
+}
+
+// activityCalendar pushes by day laid out as the rows of weekdays from Sunday with a column per week,
+// Months are the labels of the weeks starting a month. Days after today have no date.
+type activityCalendar struct {
+	Rows   [][]activityDay
+	Months []string
+	Total  int
+	Max    int
+	Since  string
+}
+
+// pushActivity calendar of the pushes to the repos matching the scope, see events.scopeCondition.
+func (a *apiClient) pushActivity(scope []string) activityCalendar {
+	weeks := activityWeeks
+	if a.config.EventDeletionEnabled && a.config.EventRetentionDays/7+1 < weeks {
+		weeks = a.config.EventRetentionDays/7 + 1
+	}
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	start := today.AddDate(0, 0, -int(today.Weekday())-(weeks-1)*7)
+	return buildActivityCalendar(a.eventListener.GetPushActivity(scope, start), start, today, weeks)
+}
+
+// buildActivityCalendar lay out the counts by day of the weeks from the start.
+func buildActivityCalendar(counts map[string]int, start, today time.Time, weeks int) activityCalendar {
+	cal := activityCalendar{Rows: make([][]activityDay, 7), Months: make([]string, weeks), Since: start.Format("2006-01-02")}
+	for _, n := range counts {
+		if n > cal.Max {
+			cal.Max = n
+		}
+	}
+	for w := 0; w < weeks; w++ {
+		for d := 0; d < 7; d++ {
+			day := start.AddDate(0, 0, w*7+d)
+			if d == 0 && (w == 0 || day.Day() <= 7) {
+				cal.Months[w] = day.Format("Jan")
+			}
+			if day.After(today) {
+				cal.Rows[d] = append(cal.Rows[d], activityDay{})
+				continue
+			}
+			date := day.Format("2006-01-02")
+			n := counts[date]
+			cal.Total += n
+			cal.Rows[d] = append(cal.Rows[d], activityDay{Date: date, Count: n, Level: activityLevel(n, cal.Max)})
+		}
+	}
+	return cal
+}
+
+// activityLevel shade 1-4 of the count in quarters of the max, 0 for none.
+func activityLevel(n, max int) int {
+	if n == 0 || max == 0 {
+		return 0
+	}
+	level := (n*4 + max - 1) / max
+	if level > 4 {
+		level = 4
+	}
+	return level
+}
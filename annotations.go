@@ -0,0 +1,69 @@
+package main
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// OCI annotations linked on the image page, see https://github.com/opencontainers/image-spec/blob/main/annotations.md
+const (
+	annotationSource        = "org.opencontainers.image.source"
+	annotationURL           = "org.opencontainers.image.url"
+	annotationDocumentation = "org.opencontainers.image.documentation"
+	annotationRevision      = "org.opencontainers.image.revision"
+)
+
+// annotationRow manifest or index annotation with the link for the well-known ones.
+type annotationRow struct {
+	Key   string
+	Value string
+	Link  string
+}
+
+// webURL the value if it is http(s) URL to link to, e.g. not javascript: one.
+func webURL(value string) string {
+	u, err := url.Parse(value)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ""
+	}
+	return value
+}
+
+// commitURL link to the commit on the web page of the source repo, the path differs on GitLab and Bitbucket.
+// BuildKit git contexts like git+https://github.com/acme/app.git#main are accepted too.
+func commitURL(source, revision string) string {
+	source = strings.TrimPrefix(source, "git+")
+	if i := strings.Index(source, "#"); i >= 0 {
+		source = source[:i]
+	}
+	source = strings.TrimSuffix(strings.TrimSuffix(webURL(source), "/"), ".git")
+	if source == "" || revision == "" {
+		return ""
+	}
+	switch {
+	case strings.Contains(source, "gitlab"):
+		return source + "/-/commit/" + url.PathEscape(revision)
+	case strings.Contains(source, "bitbucket"):
+		return source + "/commits/" + url.PathEscape(revision)
+	}
+	return source + "/commit/" + url.PathEscape(revision)
+}
+
+// annotationRows annotations sorted by key, the source, url and documentation ones are linked as is
+// and the revision is linked to the commit in the source repo.
+func annotationRows(annotations map[string]string) []annotationRow {
+	rows := make([]annotationRow, 0, len(annotations))
+	for k, v := range annotations {
+		row := annotationRow{Key: k, Value: v}
+		switch k {
+		case annotationSource, annotationURL, annotationDocumentation:
+			row.Link = webURL(v)
+		case annotationRevision:
+			row.Link = commitURL(annotations[annotationSource], v)
+		}
+		rows = append(rows, row)
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].Key < rows[j].Key })
+	return rows
+}
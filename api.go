@@ -0,0 +1,301 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/quiq/docker-registry-ui/events"
+	"github.com/quiq/docker-registry-ui/registry"
+)
+
+// Page size limits of the JSON API.
+const (
+	apiDefaultLimit = 100
+	apiMaxLimit     = 1000
+	// apiSuggestLimit default count of suggestions for typeahead.
+	apiSuggestLimit = 10
+)
+
+// apiRoute endpoint of the JSON API. Routes are registered and described in the OpenAPI spec from the same list,
+// so the spec is always in sync with the handlers.
+type apiRoute struct {
+	Method  string
+	Path    string
+	Summary string
+	Params  []apiParam
+	// Body value of the type of JSON request body, used for the spec schema.
+	Body interface{}
+	// Response value of the type returned on success, used for the spec schema.
+	Response interface{}
+	// Auth the route requires event listener token.
+	Auth    bool
+	handler echo.HandlerFunc
+}
+
+// apiParam query or path parameter of the API route.
+type apiParam struct {
+	Name        string
+	In          string
+	Type        string
+	Description string
+	Required    bool
+}
+
+// ParamNames names of the route parameters, the required ones are marked with asterisk.
+func (r apiRoute) ParamNames() []string {
+	names := []string{}
+	for _, p := range r.Params {
+		if p.Required {
+			names = append(names, p.Name+"*")
+		} else {
+			names = append(names, p.Name)
+		}
+	}
+	return names
+}
+
+type apiReposResponse struct {
+	Repositories []registry.CatalogRepo `json:"repositories"`
+	Next         string                 `json:"next"`
+	// Loading whether the catalog is still being listed for the first time, Progress is the estimated percent.
+	Loading  bool `json:"loading,omitempty"`
+	Progress int  `json:"progress,omitempty"`
+}
+
+type apiSearchResponse struct {
+	Results      []registry.SearchResult `json:"results"`
+	IndexUpdated time.Time               `json:"index_updated"`
+}
+
+// apiSuggestion repo or tag to jump to from the quick switcher.
+type apiSuggestion struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+type apiSuggestResponse struct {
+	Suggestions []apiSuggestion `json:"suggestions"`
+}
+
+type apiJobsResponse struct {
+	Background []taskInfo `json:"background"`
+	Jobs       []jobInfo  `json:"jobs"`
+}
+
+var limitParam = apiParam{Name: "limit", In: "query", Type: "integer", Description: "Page size, 100 by default, 1000 at most."}
+
+var repositoryParam = apiParam{Name: "repository", In: "query", Type: "string", Description: "Repository path, e.g. team/app or alpine.", Required: true}
+
+// apiRoutes list of the JSON API endpoints.
+func (a *apiClient) apiRoutes() []apiRoute {
+	return []apiRoute{
+		{
+			Method: "GET", Path: "/api/v1/repos", Summary: "List repositories sorted by namespace/repo page by page",
+			Params: []apiParam{
+				{Name: "namespace", In: "query", Type: "string", Description: "Only repositories of the namespace, library is the default one."},
+				{Name: "after", In: "query", Type: "string", Description: "Cursor, pass next from the previous page."},
+				limitParam,
+			},
+			Response: apiReposResponse{}, handler: a.apiRepos,
+		},
+		{
+			Method: "GET", Path: "/api/v1/search", Summary: "Search repositories and tags by names, labels and annotations",
+			Params: []apiParam{
+				{Name: "q", In: "query", Type: "string", Description: "Words to search for, all of them should match by prefix.", Required: true},
+				limitParam,
+			},
+			Response: apiSearchResponse{}, handler: a.apiSearch,
+		},
+		{
+			Method: "GET", Path: "/api/v1/files", Summary: "Search file paths in the images of the indexed repos, admins only",
+			Params: []apiParam{
+				{Name: "path", In: "query", Type: "string", Description: "Path glob, * matches any characters, e.g. log4j*.jar in any directory or /usr/bin/* from the root.", Required: true},
+				limitParam,
+			},
+			Response: apiFilesResponse{}, handler: a.apiFiles,
+		},
+		{
+			Method: "GET", Path: "/api/v1/blobs", Summary: "What references the digest and what breaks when it is deleted, by the blob graph of the opted-in repos, admins only",
+			Params: []apiParam{
+				{Name: "digest", In: "query", Type: "string", Description: "Digest of the index, manifest, config or layer, e.g. sha256:...", Required: true},
+			},
+			Response: apiBlobResponse{}, handler: a.apiBlobs,
+		},
+		{
+			Method: "GET", Path: "/api/v1/suggest", Summary: "Suggest repositories and tags by names for typeahead",
+			Params: []apiParam{
+				{Name: "q", In: "query", Type: "string", Description: "Name or its words, e.g. team/app:v1 or app v1, matched by prefix.", Required: true},
+				{Name: "limit", In: "query", Type: "integer", Description: "How many suggestions, 10 by default, 1000 at most."},
+			},
+			Response: apiSuggestResponse{}, handler: a.apiSuggest,
+		},
+		{
+			Method: "GET", Path: "/api/v1/policy", Summary: "Policy compliance of the tag or all tags of the repository",
+			Params: []apiParam{
+				{Name: "repository", In: "query", Type: "string", Description: "Repository path, e.g. team/app or alpine.", Required: true},
+				{Name: "tag", In: "query", Type: "string", Description: "Only this tag, all tags of the repository otherwise."},
+			},
+			Response: apiPolicyResponse{}, handler: a.apiPolicy,
+		},
+		{
+			Method: "GET", Path: "/api/v1/lint", Summary: "Lint findings of the tag or all tags of the repository, e.g. running as root or secrets in ENV",
+			Params: []apiParam{
+				repositoryParam,
+				{Name: "tag", In: "query", Type: "string", Description: "Only this tag, all tags of the repository otherwise."},
+			},
+			Response: apiLintResponse{}, handler: a.apiLint,
+		},
+		{
+			Method: "GET", Path: "/api/v1/feed", Summary: "Atom feed of the tags appeared in the repository, noticed by push events and the tags refresh",
+			Params: []apiParam{repositoryParam}, handler: a.apiFeed,
+		},
+		{
+			Method: "GET", Path: "/api/v1/settings", Summary: "Settings of the repository overriding the global config, admins only",
+			Params:   []apiParam{repositoryParam},
+			Response: apiRepoSettingsResponse{}, handler: a.apiRepoSettings,
+		},
+		{
+			Method: "PUT", Path: "/api/v1/settings", Summary: "Set the setting of the repository, admins only",
+			Params: []apiParam{
+				repositoryParam,
+				{Name: "name", In: "query", Type: "string", Description: "Setting name, e.g. purge_tags_keep_days.", Required: true},
+				{Name: "value", In: "query", Type: "string", Description: "Setting value.", Required: true},
+			},
+			Response: apiRepoSettingsResponse{}, handler: a.apiSetRepoSetting,
+		},
+		{
+			Method: "DELETE", Path: "/api/v1/settings", Summary: "Reset the setting of the repository to the global config, admins only",
+			Params: []apiParam{
+				repositoryParam,
+				{Name: "name", In: "query", Type: "string", Description: "Setting name, e.g. purge_tags_keep_days.", Required: true},
+			},
+			Response: apiRepoSettingsResponse{}, handler: a.apiSetRepoSetting,
+		},
+		{
+			Method: "GET", Path: "/api/v1/export", Summary: "Export the settings managed from UI as JSON bundle, admins only",
+			Response: events.Bundle{}, handler: a.apiExport,
+		},
+		{
+			Method: "POST", Path: "/api/v1/import", Summary: "Import JSON bundle replacing the settings of its tables, admins only",
+			Body: events.Bundle{}, Response: apiImportResponse{}, handler: a.apiImport,
+		},
+		{
+			Method: "GET", Path: "/api/v1/backup", Summary: "Download a consistent snapshot of the sqlite event database, admins only",
+			handler: a.apiBackup,
+		},
+		{
+			Method: "POST", Path: "/api/v1/restore", Summary: "Replace the event database by the snapshot sent as the body or merge its events and audit log, admins only",
+			Params: []apiParam{
+				{Name: "mode", In: "query", Type: "string", Description: "replace or merge.", Required: true},
+			},
+			Response: apiRestoreResponse{}, handler: a.apiRestore,
+		},
+		{
+			Method: "POST", Path: "/api/v1/upload", Summary: "Push the images of OCI layout or docker save tarball sent as the body, admins only",
+			Params: []apiParam{
+				{Name: "image", In: "query", Type: "string", Description: "repo:tag to push the only image of the tarball as, the names in the tarball are used when omitted."},
+			},
+			Response: jobInfo{}, handler: a.apiUpload,
+		},
+		{
+			Method: "GET", Path: "/api/v1/jobs", Summary: "Status of the background tasks and the jobs started from UI, other users than admins see only their jobs",
+			Response: apiJobsResponse{}, handler: a.apiJobs,
+		},
+		{
+			Method: "GET", Path: "/api/v1/openapi.json", Summary: "OpenAPI spec of this API",
+			handler: a.apiSpec,
+		},
+		{
+			Method: "POST", Path: "/api/events", Summary: "Receive notification events from Docker Registry, Harbor or Quay webhooks detected by the payload",
+			Auth: true, handler: a.receiveEvents,
+		},
+		{
+			Method: "POST", Path: "/api/events/:source", Summary: "Receive notification events of the registry given, Quay sends the token as token query parameter",
+			Params: []apiParam{
+				{Name: "source", In: "path", Type: "string", Description: "distribution, harbor or quay."},
+			},
+			Auth: true, handler: a.receiveEvents,
+		},
+	}
+}
+
+// apiRepos list repositories page by page from the catalog snapshot.
+// The "after" cursor is "namespace/repo" of the last repo from the previous page, use "next" from the response.
+func (a *apiClient) apiRepos(c echo.Context) error {
+	limit, err := apiLimit(c)
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, err)
+	}
+
+	repos, next := a.scopedCatalogPage(a.tenantScope(c), c.QueryParam("namespace"), c.QueryParam("after"), limit)
+	resp := apiReposResponse{Repositories: repos, Next: next}
+	if loading, progress := a.client.CatalogLoading(); loading {
+		resp.Loading, resp.Progress = true, progress
+	}
+	return c.JSON(http.StatusOK, resp)
+}
+
+// apiSearch search repos and tags by names, labels and annotations, the best matches first.
+func (a *apiClient) apiSearch(c echo.Context) error {
+	limit, err := apiLimit(c)
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, err)
+	}
+
+	scope := a.tenantScope(c)
+	results, built := a.client.Search(c.QueryParam("q"), scopedSearchLimit(scope, limit))
+	return c.JSON(http.StatusOK, apiSearchResponse{scopedSearch(scope, results, limit), built})
+}
+
+// apiSuggest suggest repos and tags by names with links to their pages.
+func (a *apiClient) apiSuggest(c echo.Context) error {
+	limit := apiSuggestLimit
+	if c.QueryParam("limit") != "" {
+		var err error
+		if limit, err = apiLimit(c); err != nil {
+			return apiError(c, http.StatusBadRequest, err)
+		}
+	}
+
+	suggestions := []apiSuggestion{}
+	scope := a.tenantScope(c)
+	for _, r := range scopedSearch(scope, a.client.Suggest(c.QueryParam("q"), scopedSearchLimit(scope, limit)), limit) {
+		name := r.Repo
+		if r.Namespace != "library" {
+			name = r.Namespace + "/" + r.Repo
+		}
+		// Repo is escaped the same way as in the links on the pages.
+		link := fmt.Sprintf("%s/%s/%s", a.config.BasePath, r.Namespace, url.QueryEscape(r.Repo))
+		if r.Tag != "" {
+			name = name + ":" + r.Tag
+			link = link + "/" + r.Tag
+		}
+		suggestions = append(suggestions, apiSuggestion{name, link})
+	}
+	return c.JSON(http.StatusOK, apiSuggestResponse{suggestions})
+}
+
+// apiLimit get page size from the limit parameter.
+func apiLimit(c echo.Context) (int, error) {
+	v := c.QueryParam("limit")
+	if v == "" {
+		return apiDefaultLimit, nil
+	}
+	limit, err := strconv.Atoi(v)
+	if err != nil || limit < 1 {
+		return 0, fmt.Errorf("limit should be a positive number")
+	}
+	if limit > apiMaxLimit {
+		limit = apiMaxLimit
+	}
+	return limit, nil
+}
+
+// apiError respond with JSON error and ID of the request to report.
+func apiError(c echo.Context, code int, err error) error {
+	return c.JSON(code, map[string]string{"error": err.Error(), "request_id": requestIDOf(c)})
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// apiAuth middleware authenticating JSON API requests by API token from Authorization header.
+// The owner of the token is passed on in user_header as if sent by the proxy, so the permissions and
+// tenant scope are of the owner, thus it runs before authorize. Requests without Bearer token are let through
+// as coming from browser unless api_require_token is set. The event listener has tokens of its own.
+func (a *apiClient) apiAuth(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		req := c.Request()
+		path := strings.TrimPrefix(req.URL.Path, a.config.BasePath)
+		if !strings.HasPrefix(path, "/api/") || strings.HasPrefix(path, "/api/events") || path == "/api/v1/docs" {
+			return next(c)
+		}
+		// Other schemes, e.g. Basic of the reverse proxy, are left to the user header and session auth.
+		auth := req.Header.Get("Authorization")
+		if !strings.HasPrefix(auth, "Bearer ") {
+			if a.config.APIRequireToken && a.requestUser(c) == "" {
+				return apiError(c, http.StatusUnauthorized, fmt.Errorf("API token is required"))
+			}
+			return next(c)
+		}
+		t, ok := a.eventListener.CheckAPIToken(strings.TrimPrefix(auth, "Bearer "))
+		if !ok {
+			return apiError(c, http.StatusUnauthorized, fmt.Errorf("invalid API token"))
+		}
+		req.Header.Set(a.config.UserHeader, t.User)
+		req.Header.Del(a.config.groupsHeader())
+		return next(c)
+	}
+}
+
+// viewAPITokens view API tokens to manage them.
+func (a *apiClient) viewAPITokens(c echo.Context) error {
+	data := a.setUserPermissions(c)
+	data.Set("tokens", a.eventListener.GetAPITokens())
+	data.Set("newToken", "")
+	data.Set("newTokenName", "")
+	return c.Render(http.StatusOK, "api_tokens.html", data)
+}
+
+// createAPIToken create API token, it is shown only once.
+func (a *apiClient) createAPIToken(c echo.Context) error {
+	data := a.setUserPermissions(c)
+	name := strings.TrimSpace(c.FormValue("name"))
+	if name == "" || len(name) > 100 {
+		return c.String(http.StatusBadRequest, "Token name should be set and not longer than 100 characters.")
+	}
+	token, err := a.eventListener.CreateAPIToken(name, data["user"].String())
+	if err != nil {
+		a.log(c).Error(err)
+		return c.String(http.StatusInternalServerError, "Cannot create API token, see the log for request "+requestIDOf(c)+".")
+	}
+	a.trackAction(c, "create-api-token")
+	data.Set("tokens", a.eventListener.GetAPITokens())
+	data.Set("newToken", token)
+	data.Set("newTokenName", name)
+	return c.Render(http.StatusOK, "api_tokens.html", data)
+}
+
+// revokeAPIToken delete API token.
+func (a *apiClient) revokeAPIToken(c echo.Context) error {
+	data := a.setUserPermissions(c)
+	id, _ := strconv.Atoi(c.Param("id"))
+	if err := a.eventListener.RevokeAPIToken(id); err != nil {
+		a.log(c).Error(err)
+		return c.String(http.StatusInternalServerError, "Cannot revoke API token, see the log for request "+requestIDOf(c)+".")
+	}
+	a.trackAction(c, "revoke-api-token")
+	a.log(c).Infof("API token #%d revoked by %s", id, data["user"].String())
+	return c.Redirect(http.StatusSeeOther, a.config.BasePath+"/api-tokens")
+}
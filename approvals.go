@@ -0,0 +1,148 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"github.com/quiq/docker-registry-ui/events"
+	"github.com/quiq/docker-registry-ui/registry"
+)
+
+// approvalRequired whether the deletion by the user waits for an admin approval, admins delete right away.
+func (a *apiClient) approvalRequired(isAdmin bool) bool {
+	return a.config.DeletionApprovals && !isAdmin
+}
+
+// requestDeletion store the deletion request of the tag for admins to review and notify them.
+func (a *apiClient) requestDeletion(c echo.Context, repo, tag, reason string) error {
+	user := a.setUserPermissions(c)["user"].String()
+	for _, r := range a.eventListener.GetDeletionRequests(events.DeletionPending) {
+		if r.Repository == repo && r.Tag == tag {
+			return c.String(http.StatusConflict, fmt.Sprintf("Deletion of %s:%s is already requested by %s.", repo, tag, r.User))
+		}
+	}
+	// Locked and protected tags would not be deleted anyway, so admins are not asked about them.
+	if err := a.guardDeletion(repo, tag, ""); err != nil {
+		return c.String(http.StatusConflict, fmt.Sprintf("Cannot delete %s:%s: %s.", repo, tag, err))
+	}
+	r := events.DeletionRequest{Repository: repo, Tag: tag, Reason: reason, User: user, Status: events.DeletionPending}
+	id, err := a.eventListener.RequestDeletion(r)
+	if err != nil {
+		a.log(c).Error(err)
+		return c.String(http.StatusInternalServerError, "Cannot request deletion, see the log for request "+requestIDOf(c)+".")
+	}
+	r.ID = id
+	a.trackAction(c, "delete-request")
+	a.audit(c, "delete-request", repo, tag, reason)
+	a.notifyDeletion(c, r)
+	return c.Redirect(http.StatusSeeOther, a.config.BasePath+"/approvals")
+}
+
+// viewApprovals view deletion requests, admins review the pending ones and users see their own.
+func (a *apiClient) viewApprovals(c echo.Context) error {
+	data := a.setUserPermissions(c)
+	list := a.eventListener.GetDeletionRequests("")
+	if !data["isAdmin"].Bool() {
+		own := []events.DeletionRequest{}
+		for _, r := range list {
+			if r.User == data["user"].String() {
+				own = append(own, r)
+			}
+		}
+		list = own
+	}
+	data.Set("requests", list)
+	return c.Render(http.StatusOK, "approvals.html", data)
+}
+
+// reviewDeletion approve and delete the tag or reject the pending request, the requester cannot approve it.
+func (a *apiClient) reviewDeletion(c echo.Context) error {
+	id, _ := strconv.Atoi(c.Param("id"))
+	action := c.Param("action")
+	if action != "approve" && action != "reject" {
+		return c.String(http.StatusNotFound, "Unknown action.")
+	}
+	user := a.setUserPermissions(c)["user"].String()
+	for _, r := range a.eventListener.GetDeletionRequests(events.DeletionPending) {
+		if r.ID != id {
+			continue
+		}
+		if action == "reject" {
+			if err := a.eventListener.ReviewDeletion(id, events.DeletionRejected, user); err != nil {
+				return c.String(http.StatusConflict, err.Error()+".")
+			}
+			r.Status, r.Reviewer = events.DeletionRejected, user
+			a.trackAction(c, "delete-reject")
+			a.audit(c, "delete-reject", r.Repository, r.Tag, fmt.Sprintf("Requested by %s: %s", r.User, r.Reason))
+			a.notifyDeletion(c, r)
+			return c.Redirect(http.StatusSeeOther, a.config.BasePath+"/approvals")
+		}
+
+		if r.User == user {
+			return a.forbidden(c, "The deletion should be approved by another admin than the one requested it.")
+		}
+		// The request stays pending when the tag cannot be deleted, e.g. it is locked since, to be rejected then.
+		err := a.client.DeleteTag(r.Repository, r.Tag)
+		if errors.Is(err, registry.ErrTagInUse) || errors.Is(err, registry.ErrTagLocked) || errors.Is(err, registry.ErrTagProtected) {
+			return c.String(http.StatusConflict, fmt.Sprintf("Cannot delete %s:%s: %s.", r.Repository, r.Tag, err))
+		}
+		if err != nil {
+			a.log(c).Errorf("Cannot delete %s:%s approved by %s: %s", r.Repository, r.Tag, user, err)
+			return c.String(http.StatusInternalServerError, "Cannot delete tag, see the log for request "+requestIDOf(c)+".")
+		}
+		if err := a.eventListener.ReviewDeletion(id, events.DeletionApproved, user); err != nil {
+			a.log(c).Warn(err)
+		}
+		r.Status, r.Reviewer = events.DeletionApproved, user
+		a.trackAction(c, "delete-approve")
+		a.audit(c, "delete", r.Repository, r.Tag, fmt.Sprintf("Requested by %s: %s", r.User, r.Reason))
+		a.notifyDeletedTag(a.uiBaseURL(c), user, c.RealIP(), r.Repository, r.Tag)
+		a.notifyDeletion(c, r)
+		return c.Redirect(http.StatusSeeOther, a.config.BasePath+"/approvals")
+	}
+	return c.String(http.StatusNotFound, "Pending deletion request not found.")
+}
+
+// deletionNotification JSON posted to deletion_approvals_webhook_url, text makes it a Slack or Mattermost message.
+type deletionNotification struct {
+	Text       string `json:"text"`
+	Status     string `json:"status"`
+	ID         int    `json:"id"`
+	Repository string `json:"repository"`
+	Tag        string `json:"tag"`
+	User       string `json:"user"`
+	Reason     string `json:"reason"`
+	Reviewer   string `json:"reviewer,omitempty"`
+	URL        string `json:"url"`
+}
+
+// notifyDeletion post the new request or its review to the webhook in background.
+func (a *apiClient) notifyDeletion(c echo.Context, r events.DeletionRequest) {
+	if a.config.DeletionApprovalsWebhookURL == "" {
+		return
+	}
+	n := deletionNotification{
+		Status: r.Status, ID: r.ID, Repository: r.Repository, Tag: r.Tag, User: r.User, Reason: r.Reason, Reviewer: r.Reviewer,
+		URL: c.Scheme() + "://" + c.Request().Host + a.config.BasePath + "/approvals",
+	}
+	image := a.config.imageName(r.Repository) + ":" + r.Tag
+	switch r.Status {
+	case events.DeletionPending:
+		n.Text = fmt.Sprintf("%s requests deleting %s: %s. Review it at %s", r.User, image, r.Reason, n.URL)
+	case events.DeletionApproved:
+		n.Text = fmt.Sprintf("%s approved deleting %s requested by %s, the tag is deleted.", r.Reviewer, image, r.User)
+	default:
+		n.Text = fmt.Sprintf("%s rejected deleting %s requested by %s.", r.Reviewer, image, r.User)
+	}
+	body, _ := json.Marshal(n)
+	logger := a.log(c)
+	go func() {
+		if err := postReport(a.config.DeletionApprovalsWebhookURL, nil, body); err != nil {
+			logger.Warnf("Cannot notify about deletion request %d: %s", r.ID, err)
+		}
+	}()
+}
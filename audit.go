@@ -0,0 +1,34 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/quiq/docker-registry-ui/events"
+)
+
+// audit record the action of the user making the request in audit log. The admin viewing the UI as another user
+// is recorded as the one who did it, with the user viewed as.
+func (a *apiClient) audit(c echo.Context, action, repo, tag, reason string) {
+	data := a.setUserPermissions(c)
+	a.addAuditRecord(events.AuditRecord{Action: action, Repository: repo, Tag: tag, User: data["realUser"].String(),
+		IP: c.RealIP(), Reason: reason, ViewAs: data["viewAs"].String()})
+}
+
+// auditAs record the action in audit log on behalf of the user, for background jobs.
+func (a *apiClient) auditAs(user, ip, action, repo, tag, reason string) {
+	a.addAuditRecord(events.AuditRecord{Action: action, Repository: repo, Tag: tag, User: user, IP: ip, Reason: reason})
+}
+
+func (a *apiClient) addAuditRecord(r events.AuditRecord) {
+	if err := a.eventListener.AddAuditRecord(r); err != nil {
+		a.logger.Errorf("Cannot add audit record %+v: %s", r, err)
+	}
+}
+
+// viewAuditLog view actions performed from UI.
+func (a *apiClient) viewAuditLog(c echo.Context) error {
+	data := a.setUserPermissions(c)
+	data.Set("records", a.eventListener.GetAuditLog())
+	return c.Render(http.StatusOK, "audit_log.html", data)
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/CloudyKit/jet"
+	"github.com/labstack/echo/v4"
+	"github.com/quiq/docker-registry-ui/registry"
+)
+
+const viewAsCookie = "view_as"
+
+// Permissions required by routes.
+const (
+	permAnyone    = ""
+	permAdmin     = "admin"
+	permDelete    = "delete"
+	permRealAdmin = "real-admin"
+	// permRetentionPreview dry-run of purging old tags, it deletes nothing.
+	permRetentionPreview = "retention-preview"
+	// permRetention purging old tags and changing retention rules.
+	permRetention = "retention"
+)
+
+// routePermissions permissions required by routes, keyed by method and path without base path.
+// GET routes not listed here are allowed to anyone, other methods are denied unless listed,
+// so a new mutating route cannot be exposed without deciding who can use it.
+var routePermissions = map[string]string{
+	"GET /:namespace/:repo/:tag/delete": permDelete,
+	"GET /usage":                        permAdmin,
+	"GET /audit":                        permAdmin,
+	"GET /diagnostics":                  permAdmin,
+	"GET /debug/runtime":                permAdmin,
+	"GET /debug/pprof/*":                permAdmin,
+	"GET /storage":                      permAdmin,
+	"GET /stale":                        permAdmin,
+	"GET /transfer":                     permAdmin,
+	"POST /transfer/export":             permAdmin,
+	"GET /transfer/exports/:name":       permAdmin,
+	"POST /transfer/import":             permAdmin,
+	"POST /transfer/uploads":            permAdmin,
+	"GET /transfer/uploads/:id":         permAdmin,
+	"PATCH /transfer/uploads/:id":       permAdmin,
+	"DELETE /transfer/uploads/:id":      permAdmin,
+	"POST /transfer/uploads/:id/import": permAdmin,
+	"GET /layers":                       permAdmin,
+	"GET /files":                        permAdmin,
+	"GET /blobs":                        permAdmin,
+	"GET /api/v1/blobs":                 permAdmin,
+	"GET /api/v1/files":                 permAdmin,
+	"POST /storage/scan":                permAdmin,
+	"GET /view-as":                      permRealAdmin,
+	"POST /login":                       permAnyone,
+	"POST /logout":                      permAnyone,
+	"GET /api-tokens":                   permAdmin,
+	"POST /api-tokens":                  permAdmin,
+	"POST /api-tokens/:id/revoke":       permAdmin,
+	"GET /vulnerabilities":              permAdmin,
+	"POST /vulnerabilities":             permAdmin,
+	"POST /vulnerabilities/:id/revoke":  permAdmin,
+	"POST /scan":                        permAdmin,
+	"POST /sign":                        permAdmin,
+	"POST /locks":                       permDelete,
+	"POST /undo/:id":                    permDelete,
+	"POST /locks/:id/unlock":            permAnyone,
+	"POST /preferences/columns":         permAnyone,
+	"POST /copy":                        permAdmin,
+	"POST /prune-index":                 permAdmin,
+	"POST /cache/check":                 permAdmin,
+	"GET /settings":                     permAdmin,
+	"POST /settings":                    permAdmin,
+	"GET /api/v1/settings":              permAdmin,
+	"PUT /api/v1/settings":              permAdmin,
+	"DELETE /api/v1/settings":           permAdmin,
+	"GET /api/v1/export":                permAdmin,
+	"POST /api/v1/import":               permAdmin,
+	"GET /api/v1/backup":                permAdmin,
+	"POST /api/v1/restore":              permAdmin,
+	"POST /api/v1/upload":               permAdmin,
+	"GET /retention":                    permRetentionPreview,
+	"POST /retention/preview":           permRetentionPreview,
+	"POST /retention/run":               permRetention,
+	"POST /retention/rules":             permRetention,
+	"POST /retention/import":            permRetention,
+	"GET /options":                      permAdmin,
+	"GET /webhooks":                     permDelete,
+	"POST /webhooks":                    permDelete,
+	"GET /webhooks/:id":                 permDelete,
+	"POST /webhooks/:id/test":           permDelete,
+	"POST /webhooks/:id/delete":         permDelete,
+	"GET /owners":                       permAdmin,
+	"POST /owners":                      permAdmin,
+	"POST /owners/:id/delete":           permAdmin,
+	"POST /gc":                          permAdmin,
+	"POST /tasks/:id/:action":           permAdmin,
+	"POST /approvals/:id/:action":       permAdmin,
+	// Event listener is protected by the token auth of the API group.
+	"POST /api/events":         permAnyone,
+	"POST /api/events/:source": permAnyone,
+	// Unknown paths of the API group reach only its not found handler.
+	"* /api/*": permAnyone,
+}
+
+// permissionMessages explanation shown when the permission is missing.
+var permissionMessages = map[string]string{
+	permAdmin:            "Only admins can do this.",
+	permDelete:           "You are not allowed to delete images.",
+	permRealAdmin:        "Only admins can view the UI as another user.",
+	permRetentionPreview: "You are not allowed to preview purging old tags.",
+	permRetention:        "You are not allowed to purge old tags or change retention rules.",
+}
+
+// authorize check permissions required by the route before running the handler.
+func (a *apiClient) authorize(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		method := c.Request().Method
+		path := strings.TrimPrefix(c.Path(), a.config.BasePath)
+		perm, ok := routePermissions[method+" "+path]
+		if !ok {
+			perm, ok = routePermissions["* "+path]
+		}
+		// Empty path means no route matched, echo responds with not found then.
+		if !ok && path != "" && method != http.MethodGet && method != http.MethodHead {
+			return a.forbidden(c, "This action is not permitted.")
+		}
+
+		data := a.setUserPermissions(c)
+		switch {
+		case perm == permAdmin && !data["isAdmin"].Bool(),
+			perm == permDelete && !data["deleteAllowed"].Bool(),
+			perm == permRealAdmin && !data["realIsAdmin"].Bool(),
+			perm == permRetentionPreview && !data["retentionPreviewAllowed"].Bool(),
+			perm == permRetention && !data["retentionAllowed"].Bool():
+			return a.forbidden(c, permissionMessages[perm])
+		}
+		return next(c)
+	}
+}
+
+// forbidden respond with 403 as JSON to API requests and as HTML page otherwise.
+func (a *apiClient) forbidden(c echo.Context, message string) error {
+	if a.wantsJSON(c) {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": message})
+	}
+	data := a.setUserPermissions(c)
+	data.Set("message", message)
+	return c.Render(http.StatusForbidden, "forbidden.html", data)
+}
+
+// setUserPermissions evaluate permissions of the user making the request and return them as template vars.
+// Admins can view the UI as another user, then the permissions are evaluated for that user instead.
+func (a *apiClient) setUserPermissions(c echo.Context) jet.VarMap {
+	user, groups := a.requestUser(c), a.requestGroups(c)
+	viewAs := ""
+	if a.isAdmin(user, groups) {
+		if cookie, err := c.Cookie(viewAsCookie); err == nil {
+			viewAs = cookie.Value
+		}
+	}
+
+	data := jet.VarMap{}
+	data.Set("realUser", user)
+	data.Set("realIsAdmin", a.isAdmin(user, groups))
+	// The groups header is of the real user, so only the users lists count when viewing the UI as another user.
+	if viewAs != "" {
+		user, groups = viewAs, nil
+	}
+	data.Set("user", user)
+	data.Set("viewAs", viewAs)
+	data.Set("isAdmin", a.isAdmin(user, groups))
+	data.Set("deleteAllowed", a.config.feature("deletion") && a.checkDeletePermission(user, groups))
+	data.Set("deleteReasonRequired", a.config.DeleteReasonRequired)
+	data.Set("deletionApprovals", a.config.DeletionApprovals)
+	data.Set("deleteApprovalRequired", a.approvalRequired(a.isAdmin(user, groups)))
+	data.Set("retentionAllowed", a.config.feature("deletion") && a.checkRetentionPermission(user, groups))
+	data.Set("retentionPreviewAllowed", a.checkRetentionPermission(user, groups) || (user != "" && registry.ItemInSlice(user, a.config.RetentionPreviewers)))
+	data.Set("registryOutage", a.registry.get())
+	_, err := c.Cookie(sessionCookie)
+	data.Set("loggedIn", a.config.LocalUsers && err == nil && data["realUser"].String() != "")
+	return data
+}
+
+// isAdmin check if user is listed among admins or is a member of an admin group.
+func (a *apiClient) isAdmin(user string, groups []string) bool {
+	return user != "" && (registry.ItemInSlice(user, a.config.Admins) || inGroups(groups, a.config.AdminGroups))
+}
+
+// checkDeletePermission check if tag deletion is allowed whether by anyone or permitted users and groups.
+func (a *apiClient) checkDeletePermission(user string, groups []string) bool {
+	return a.config.AnyoneCanDelete || a.isAdmin(user, groups) ||
+		(user != "" && (registry.ItemInSlice(user, a.config.Deleters) || inGroups(groups, a.config.DeleterGroups)))
+}
+
+// checkRetentionPermission check if the user can purge old tags and change retention rules.
+// Deleting tags manually does not grant it, so the rules cannot be loosened by whoever can delete.
+func (a *apiClient) checkRetentionPermission(user string, groups []string) bool {
+	return a.isAdmin(user, groups) || (user != "" && registry.ItemInSlice(user, a.config.RetentionManagers))
+}
+
+// inGroups whether any of the user groups is among the granted ones.
+func inGroups(groups, granted []string) bool {
+	for _, g := range groups {
+		if registry.ItemInSlice(g, granted) {
+			return true
+		}
+	}
+	return false
+}
+
+// viewAs let admin view the UI as another user, empty user switches back.
+func (a *apiClient) viewAs(c echo.Context) error {
+	cookie := &http.Cookie{
+		Name:     viewAsCookie,
+		Value:    c.QueryParam("user"),
+		Path:     a.config.BasePath + "/",
+		HttpOnly: true,
+	}
+	if cookie.Value == "" {
+		cookie.MaxAge = -1
+	}
+	c.SetCookie(cookie)
+	a.trackAction(c, "view-as")
+
+	// Return to the same page but never redirect to other hosts.
+	redirect := a.config.BasePath + "/"
+	if u, err := url.Parse(c.Request().Referer()); err == nil && u.Host == c.Request().Host && u.Path != "" {
+		redirect = u.RequestURI()
+	}
+	return c.Redirect(http.StatusSeeOther, redirect)
+}
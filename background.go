@@ -0,0 +1,259 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/quiq/docker-registry-ui/registry"
+	"github.com/robfig/cron"
+	"github.com/sirupsen/logrus"
+)
+
+// refreshTagsTask name of the task refreshing the catalog, tag counts and search index.
+const refreshTagsTask = "Refresh tag counts and search index"
+
+// Background task status.
+const (
+	taskIdle    = "idle"
+	taskRunning = "running"
+	taskFailed  = "failed"
+)
+
+// backgroundTask task run by schedule in background, e.g. refreshing tag counts.
+type backgroundTask struct {
+	schedule cron.Schedule
+	run      func(t *backgroundTask) (string, error)
+	trigger  chan struct{}
+	mux      sync.Mutex
+	info     taskInfo
+	logger   *logrus.Entry
+}
+
+// taskInfo copy of the task status safe to pass to templates and API.
+type taskInfo struct {
+	ID           int       `json:"id"`
+	Name         string    `json:"name"`
+	Status       string    `json:"status"`
+	Done         int       `json:"done"`
+	Total        int       `json:"total"`
+	LastStarted  time.Time `json:"last_started"`
+	LastFinished time.Time `json:"last_finished"`
+	LastResult   string    `json:"last_result"`
+	LastError    string    `json:"last_error"`
+	// LastSucceeded when the task finished without error the last time.
+	LastSucceeded time.Time `json:"last_succeeded"`
+	NextRun       time.Time `json:"next_run"`
+	// Paused task is not run by schedule but still can be triggered.
+	Paused bool `json:"paused"`
+}
+
+// Percent progress of the running task.
+func (t taskInfo) Percent() int {
+	if t.Total == 0 {
+		return 0
+	}
+	return t.Done * 100 / t.Total
+}
+
+// LastDuration how long the last run took.
+func (t taskInfo) LastDuration() time.Duration {
+	if t.LastFinished.Before(t.LastStarted) {
+		return 0
+	}
+	return t.LastFinished.Sub(t.LastStarted)
+}
+
+// progress set the count of processed items out of total.
+func (t *backgroundTask) progress(done, total int) {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	t.info.Done = done
+	t.info.Total = total
+}
+
+// execute run the task once recording its status.
+func (t *backgroundTask) execute() {
+	t.mux.Lock()
+	t.info.Status = taskRunning
+	t.info.LastStarted = time.Now()
+	t.info.Done, t.info.Total = 0, 0
+	t.mux.Unlock()
+
+	result, err := t.run(t)
+
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	t.info.LastFinished = time.Now()
+	t.info.LastResult = result
+	t.info.Status = taskIdle
+	t.info.LastError = ""
+	if err != nil {
+		t.info.Status = taskFailed
+		t.info.LastError = err.Error()
+		t.logger.Errorf("Background task %q failed: %s", t.info.Name, err)
+		return
+	}
+	t.info.LastSucceeded = t.info.LastFinished
+}
+
+// loop run the task by schedule or when triggered forever.
+func (t *backgroundTask) loop(runAtStart bool) {
+	if runAtStart {
+		t.execute()
+	}
+	for {
+		next := t.schedule.Next(time.Now())
+		t.mux.Lock()
+		t.info.NextRun = next
+		t.mux.Unlock()
+		timer := time.NewTimer(next.Sub(time.Now()))
+		select {
+		case <-timer.C:
+			t.mux.Lock()
+			paused := t.info.Paused
+			t.mux.Unlock()
+			if paused {
+				continue
+			}
+		case <-t.trigger:
+			timer.Stop()
+		}
+		t.execute()
+	}
+}
+
+// runNow run the task as soon as possible, at most one run is queued while it is running.
+func (t *backgroundTask) runNow() {
+	select {
+	case t.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// pause stop or resume running the task by schedule.
+func (t *backgroundTask) pause(paused bool) {
+	t.mux.Lock()
+	t.info.Paused = paused
+	t.mux.Unlock()
+}
+
+// backgroundTasks tasks running by schedule since the start.
+type backgroundTasks struct {
+	mux    sync.Mutex
+	tasks  []*backgroundTask
+	logger *logrus.Entry
+}
+
+// add start running the task by schedule, optionally right away.
+func (l *backgroundTasks) add(name string, schedule cron.Schedule, runAtStart bool, run func(t *backgroundTask) (string, error)) {
+	t := &backgroundTask{schedule: schedule, run: run, trigger: make(chan struct{}, 1), info: taskInfo{Name: name, Status: taskIdle}}
+	l.mux.Lock()
+	if l.logger == nil {
+		l.logger = registry.SetupLogging("main.tasks")
+	}
+	t.logger = l.logger
+	t.info.ID = len(l.tasks) + 1
+	l.tasks = append(l.tasks, t)
+	l.mux.Unlock()
+	go t.loop(runAtStart)
+}
+
+// list status of all tasks.
+func (l *backgroundTasks) list() []taskInfo {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	list := make([]taskInfo, 0, len(l.tasks))
+	for _, t := range l.tasks {
+		t.mux.Lock()
+		list = append(list, t.info)
+		t.mux.Unlock()
+	}
+	return list
+}
+
+// find task by name.
+func (l *backgroundTasks) find(name string) (*backgroundTask, bool) {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	for _, t := range l.tasks {
+		if t.info.Name == name {
+			return t, true
+		}
+	}
+	return nil, false
+}
+
+// get task by id.
+func (l *backgroundTasks) get(id int) (*backgroundTask, bool) {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	if id < 1 || id > len(l.tasks) {
+		return nil, false
+	}
+	return l.tasks[id-1], true
+}
+
+// controlTask pause, resume or run the background task now.
+func (a *apiClient) controlTask(c echo.Context) error {
+	id, _ := strconv.Atoi(c.Param("id"))
+	t, ok := a.tasks.get(id)
+	if !ok {
+		return c.String(http.StatusNotFound, "Task not found.")
+	}
+	action := c.Param("action")
+	switch action {
+	case "pause":
+		t.pause(true)
+	case "resume":
+		t.pause(false)
+	case "run":
+		t.runNow()
+	default:
+		return c.String(http.StatusBadRequest, "Unknown action.")
+	}
+	a.log(c).Infof("Background task %q: %s by %s", t.info.Name, action, a.setUserPermissions(c)["user"].String())
+	a.trackAction(c, "task-"+action)
+	return c.Redirect(http.StatusSeeOther, a.config.BasePath+"/jobs")
+}
+
+// startBackgroundTasks schedule refreshing tag counts, prefetching recently viewed repos and purging tags.
+func (a *apiClient) startBackgroundTasks(purgeDryRun bool) {
+	interval := time.Duration(a.config.CacheRefreshInterval) * time.Minute
+	a.client.SetMetadataTTL(interval)
+	var refreshSchedule cron.Schedule = cron.Every(interval)
+	if a.config.CatalogRefreshCron != "" {
+		refreshSchedule, _ = cron.Parse(a.config.CatalogRefreshCron)
+	}
+	a.tasks.add(refreshTagsTask, refreshSchedule, true, func(t *backgroundTask) (string, error) {
+		start := time.Now()
+		a.logger.Info("Refreshing tag counts...")
+		total, unchanged := a.client.RefreshTags(false, t.progress)
+		a.logger.Infof("Tag counts refreshed (%v), %d of %d repos unchanged.", time.Now().Sub(start), unchanged, total)
+		return fmt.Sprintf("%d repos, %d unchanged", total, unchanged), nil
+	})
+	a.tasks.add("Prefetch recently viewed repos", cron.Every(time.Minute), false, func(t *backgroundTask) (string, error) {
+		if b := a.client.Backoff(); !b.PausedUntil.IsZero() {
+			return "Paused till " + b.PausedUntil.Format("15:04:05") + " as registry is throttling", nil
+		}
+		return fmt.Sprintf("%d repos", a.client.PrefetchRecent()), nil
+	})
+	if a.config.feature("files") {
+		a.tasks.add("Index files of image layers", cron.Every(time.Duration(a.config.FileIndexInterval)*time.Minute), true, a.indexFiles)
+	}
+	if a.config.feature("blobs") {
+		a.tasks.add("Build blob reference graph", cron.Every(time.Duration(a.config.BlobGraphInterval)*time.Minute), true, a.buildBlobGraph)
+	}
+	if a.config.feature("purging") {
+		schedule, _ := cron.Parse(a.config.PurgeTagsSchedule)
+		a.tasks.add("Purge old tags", schedule, false, func(t *backgroundTask) (string, error) {
+			if j := a.schedulePurge("scheduler", purgeDryRun); j != nil {
+				return fmt.Sprintf("Started job #%d", j.ID), nil
+			}
+			return "Skipped, the previous purge is still waiting or running", nil
+		})
+	}
+}
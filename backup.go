@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+type apiRestoreResponse struct {
+	Mode string `json:"mode"`
+	// Merged count of added rows by table when merging.
+	Merged map[string]int `json:"merged,omitempty"`
+}
+
+// apiBackup download a consistent snapshot of the sqlite event database.
+func (a *apiClient) apiBackup(c echo.Context) error {
+	c.Response().Header().Set(echo.HeaderContentType, echo.MIMEOctetStream)
+	c.Response().Header().Set(echo.HeaderContentDisposition,
+		fmt.Sprintf(`attachment; filename="registry-ui-events-%s.db"`, time.Now().UTC().Format("20060102-150405")))
+	// Nothing is written until the snapshot is ready, so errors are still sent as JSON.
+	if err := a.eventListener.Backup(c.Response()); err != nil {
+		a.log(c).Errorf("Cannot back up event database: %s", err)
+		c.Response().Header().Del(echo.HeaderContentDisposition)
+		return apiError(c, http.StatusBadRequest, err)
+	}
+	a.trackAction(c, "backup")
+	a.audit(c, "backup", "", "", "")
+	return nil
+}
+
+// apiRestore replace the event database by the uploaded snapshot or merge its events and audit log into it.
+// The snapshot is sent as the body or as snapshot file of the form, up to restore_max_upload_mb.
+func (a *apiClient) apiRestore(c echo.Context) error {
+	// The form is parsed into temporary files too, so the body is limited before reading any of it.
+	if limit := int64(a.config.RestoreMaxUploadMB) << 20; limit > 0 {
+		if c.Request().ContentLength > limit {
+			return apiError(c, http.StatusRequestEntityTooLarge, fmt.Errorf("the snapshot is over %d MB", a.config.RestoreMaxUploadMB))
+		}
+		c.Request().Body = http.MaxBytesReader(c.Response(), c.Request().Body, limit)
+	}
+	mode := c.QueryParam("mode")
+	if mode == "" {
+		mode = c.FormValue("mode")
+	}
+	if mode != "replace" && mode != "merge" {
+		return apiError(c, http.StatusBadRequest, fmt.Errorf("mode should be replace or merge"))
+	}
+	var body io.Reader = c.Request().Body
+	if strings.HasPrefix(c.Request().Header.Get(echo.HeaderContentType), echo.MIMEMultipartForm) {
+		file, err := c.FormFile("snapshot")
+		if err != nil {
+			return apiError(c, http.StatusBadRequest, fmt.Errorf("snapshot file is required"))
+		}
+		f, err := file.Open()
+		if err != nil {
+			return apiError(c, http.StatusBadRequest, err)
+		}
+		defer f.Close()
+		body = f
+	}
+	tmp, err := ioutil.TempFile("", "events-snapshot")
+	if err != nil {
+		return apiError(c, http.StatusInternalServerError, err)
+	}
+	defer os.Remove(tmp.Name())
+	_, err = io.Copy(tmp, body)
+	tmp.Close()
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, fmt.Errorf("cannot read the snapshot: %s", err))
+	}
+
+	res := apiRestoreResponse{Mode: mode}
+	if mode == "replace" {
+		err = a.eventListener.Restore(tmp.Name())
+	} else {
+		res.Merged, err = a.eventListener.Merge(tmp.Name())
+	}
+	if err != nil {
+		a.log(c).Errorf("Cannot %s event database: %s", mode, err)
+		return apiError(c, http.StatusBadRequest, err)
+	}
+
+	reason := "Replaced by the snapshot, the previous database is kept as .bak file"
+	if mode == "merge" {
+		var merged []string
+		for table, count := range res.Merged {
+			merged = append(merged, fmt.Sprintf("%s: %d", table, count))
+		}
+		sort.Strings(merged)
+		reason = "Merged from the snapshot, " + strings.Join(merged, ", ")
+	}
+	a.trackAction(c, "restore")
+	a.audit(c, "restore", "", "", reason)
+	return c.JSON(http.StatusOK, res)
+}
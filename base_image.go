@@ -0,0 +1,29 @@
+package main
+
+import (
+	"github.com/quiq/docker-registry-ui/policy"
+	"github.com/quiq/docker-registry-ui/registry"
+)
+
+// baseImageStatus the base image of the tag compared with the upstream tag, Base is empty if unknown and
+// Reference is empty if not compared. Multi-arch images are compared by their first platform image.
+func (a *apiClient) baseImageStatus(repo string, meta registry.TagMeta, layers []registry.Layer, subImages []subImage) registry.BaseImageStatus {
+	base := policy.BaseImage(meta.Annotations, meta.Labels)
+	if base == "" || a.baseImages == nil {
+		return registry.BaseImageStatus{Base: base}
+	}
+	platform := ""
+	if len(meta.Platforms) > 0 {
+		platform = meta.Platforms[0]
+	}
+	if len(layers) == 0 && len(subImages) > 0 {
+		_, manifest, _ := a.client.TagInfo(repo, subImages[0].Digest, true)
+		layers = registry.Layers(manifest)
+		platform = subImages[0].Platform
+	}
+	s := a.baseImages.Check(base, policy.BaseDigest(meta.Annotations, meta.Labels), platform, layers)
+	if s.Error != "" {
+		a.logger.Warnf("Cannot compare %s with its base image %s: %s", repo, base, s.Error)
+	}
+	return s
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/quiq/docker-registry-ui/events"
+	"github.com/quiq/docker-registry-ui/registry"
+	"github.com/tidwall/gjson"
+)
+
+type apiBlobResponse struct {
+	Digest    string            `json:"digest"`
+	Referrers []events.BlobRef  `json:"referrers"`
+	Children  []events.BlobRef  `json:"children"`
+	Impact    events.BlobImpact `json:"impact"`
+}
+
+// imageBlobRefs reference graph of the tag: the manifest the tag points to, the images of the index
+// with their configs and layers. Foreign layers are not stored by the registry so they are skipped.
+func (a *apiClient) imageBlobRefs(repo, tag string) ([]events.BlobRef, error) {
+	manifest, _, digest, err := a.client.GetManifest(repo, tag)
+	if err != nil {
+		return nil, err
+	}
+	kind := events.BlobManifest
+	if gjson.Get(manifest, "manifests").Exists() {
+		kind = events.BlobIndex
+	}
+	refs := []events.BlobRef{{Repository: repo, Tag: tag, Digest: digest, Kind: kind, Size: int64(len(manifest))}}
+	for _, m := range gjson.Get(manifest, "manifests").Array() {
+		sub, _, subDigest, err := a.client.GetManifest(repo, m.Get("digest").String())
+		if err != nil {
+			return nil, err
+		}
+		refs = append(refs, events.BlobRef{Repository: repo, Tag: tag, Parent: digest, Digest: subDigest, Kind: events.BlobManifest, Size: int64(len(sub))})
+		refs = append(refs, manifestBlobRefs(repo, tag, subDigest, sub)...)
+	}
+	return append(refs, manifestBlobRefs(repo, tag, digest, manifest)...), nil
+}
+
+// manifestBlobRefs config and layers of the image manifest.
+func manifestBlobRefs(repo, tag, digest, manifest string) []events.BlobRef {
+	var refs []events.BlobRef
+	if c := gjson.Get(manifest, "config"); c.Exists() {
+		refs = append(refs, events.BlobRef{Repository: repo, Tag: tag, Parent: digest, Digest: c.Get("digest").String(), Kind: events.BlobConfig, Size: c.Get("size").Int()})
+	}
+	for _, l := range registry.Layers(manifest) {
+		if !l.Foreign {
+			refs = append(refs, events.BlobRef{Repository: repo, Tag: tag, Parent: digest, Digest: l.Digest, Kind: events.BlobLayer, Size: l.Size})
+		}
+	}
+	return refs
+}
+
+// buildBlobGraph store the reference graph of all tags of the opted-in repos, replacing the previous one.
+func (a *apiClient) buildBlobGraph(t *backgroundTask) (string, error) {
+	var repos []string
+	for namespace, names := range a.client.Repositories(true) {
+		for _, name := range names {
+			if repo := catalogRepoPath(namespace, name); repoPatternsMatch(a.config.BlobGraphRepos, repo) {
+				repos = append(repos, repo)
+			}
+		}
+	}
+	tags, failed := 0, 0
+	for i, repo := range repos {
+		t.progress(i, len(repos))
+		var refs []events.BlobRef
+		for _, tag := range a.client.Tags(repo) {
+			list, err := a.imageBlobRefs(repo, tag)
+			if err != nil {
+				a.logger.Warnf("Cannot get manifests of %s:%s for the blob graph: %s", repo, tag, err)
+				failed++
+				continue
+			}
+			refs = append(refs, list...)
+			tags++
+		}
+		if err := a.eventListener.SetBlobRefs(repo, refs); err != nil {
+			return "", err
+		}
+	}
+	if err := a.eventListener.PruneBlobGraph(repos); err != nil {
+		return "", err
+	}
+	result := fmt.Sprintf("%d repos, %d tags", len(repos), tags)
+	if failed > 0 {
+		result += fmt.Sprintf(", %d failed", failed)
+	}
+	return result, nil
+}
+
+// blobReferences what references the digest, the blobs it references and what breaks when it is deleted.
+func (a *apiClient) blobReferences(digest string) (apiBlobResponse, error) {
+	r := apiBlobResponse{Digest: digest}
+	var err error
+	if r.Referrers, err = a.eventListener.GetBlobReferrers(digest); err != nil {
+		return r, err
+	}
+	if r.Children, err = a.eventListener.GetBlobChildren(digest); err != nil {
+		return r, err
+	}
+	r.Impact, err = a.eventListener.GetBlobImpact(digest)
+	return r, err
+}
+
+// viewBlobs explore the reference graph of the digest.
+func (a *apiClient) viewBlobs(c echo.Context) error {
+	if !a.config.feature("blobs") {
+		return c.String(http.StatusNotFound, "Blob explorer is disabled, see blob_graph_repos.")
+	}
+	digest := strings.TrimSpace(c.QueryParam("digest"))
+	data := a.setUserPermissions(c)
+	data.Set("digest", digest)
+	data.Set("invalid", digest != "" && !digestRegexp.MatchString(digest))
+	var blob apiBlobResponse
+	if digest != "" && digestRegexp.MatchString(digest) {
+		var err error
+		if blob, err = a.blobReferences(digest); err != nil {
+			a.log(c).Error(err)
+		}
+	}
+	var orphanedSize int64
+	for _, b := range blob.Impact.Orphaned {
+		orphanedSize += b.Size
+	}
+	stats, _ := a.eventListener.GetBlobGraphStats()
+	data.Set("blob", blob)
+	data.Set("orphanedSize", orphanedSize)
+	data.Set("stats", stats)
+	return c.Render(http.StatusOK, "blobs.html", data)
+}
+
+// apiBlobs what references the digest and what breaks when it is deleted.
+func (a *apiClient) apiBlobs(c echo.Context) error {
+	if !a.config.feature("blobs") {
+		return apiError(c, http.StatusNotFound, fmt.Errorf("blob explorer is disabled"))
+	}
+	digest := strings.TrimSpace(c.QueryParam("digest"))
+	if !digestRegexp.MatchString(digest) {
+		return apiError(c, http.StatusBadRequest, fmt.Errorf("digest should be sha256:<64 hex digits>"))
+	}
+	blob, err := a.blobReferences(digest)
+	if err != nil {
+		return apiError(c, http.StatusInternalServerError, err)
+	}
+	return c.JSON(http.StatusOK, blob)
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/quiq/docker-registry-ui/events"
+)
+
+type apiImportResponse struct {
+	Imported map[string]int `json:"imported"`
+}
+
+// apiExport download the settings managed from UI as JSON bundle for backup or moving to another environment.
+func (a *apiClient) apiExport(c echo.Context) error {
+	b, err := a.eventListener.ExportBundle(version)
+	if err != nil {
+		a.log(c).Error(err)
+		return apiError(c, http.StatusInternalServerError, fmt.Errorf("cannot export settings, see the log for details"))
+	}
+	a.trackAction(c, "export")
+	a.audit(c, "export", "", "", "")
+	c.Response().Header().Set(echo.HeaderContentDisposition,
+		fmt.Sprintf(`attachment; filename="registry-ui-settings-%s.json"`, b.Exported.Format("20060102-150405")))
+	return c.JSONPretty(http.StatusOK, b, "  ")
+}
+
+// apiImport replace the settings managed from UI by the tables of the bundle, the bundle is sent
+// as JSON body or as bundle file of the form.
+func (a *apiClient) apiImport(c echo.Context) error {
+	var body io.Reader = c.Request().Body
+	if strings.HasPrefix(c.Request().Header.Get(echo.HeaderContentType), echo.MIMEMultipartForm) {
+		file, err := c.FormFile("bundle")
+		if err != nil {
+			return apiError(c, http.StatusBadRequest, fmt.Errorf("bundle file is required"))
+		}
+		f, err := file.Open()
+		if err != nil {
+			return apiError(c, http.StatusBadRequest, err)
+		}
+		defer f.Close()
+		body = f
+	}
+	var b events.Bundle
+	if err := json.NewDecoder(body).Decode(&b); err != nil {
+		return apiError(c, http.StatusBadRequest, fmt.Errorf("cannot parse the bundle: %s", err))
+	}
+	if len(b.Tables) == 0 {
+		return apiError(c, http.StatusBadRequest, fmt.Errorf("the bundle has no tables"))
+	}
+	counts, err := a.eventListener.ImportBundle(b)
+	if err != nil {
+		a.log(c).Errorf("Cannot import settings: %s", err)
+		return apiError(c, http.StatusBadRequest, err)
+	}
+
+	var imported []string
+	for table, count := range counts {
+		imported = append(imported, fmt.Sprintf("%s: %d", table, count))
+	}
+	sort.Strings(imported)
+	a.trackAction(c, "import")
+	a.audit(c, "import", "", "", fmt.Sprintf("Bundle of v%s exported on %s, %s",
+		b.Version, b.Exported.Format(time.RFC3339), strings.Join(imported, ", ")))
+	return c.JSON(http.StatusOK, apiImportResponse{counts})
+}
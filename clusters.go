@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/quiq/docker-registry-ui/kubernetes"
+	"github.com/quiq/docker-registry-ui/registry"
+	"github.com/robfig/cron"
+)
+
+// clusterUsage the latest lookup of the images running in Kubernetes clusters.
+type clusterUsage struct {
+	mux   sync.Mutex
+	usage *kubernetes.Usage
+}
+
+// clusterStatus result of the latest lookup in the cluster shown on Diagnostics page.
+type clusterStatus struct {
+	Name    string
+	OK      bool
+	Message string
+}
+
+// startClusterLookup look up the images of running pods in background and keep the tags in use from deletion.
+func (a *apiClient) startClusterLookup() {
+	if !a.config.feature("kubernetes") {
+		return
+	}
+	interval := time.Duration(a.config.KubernetesRefresh) * time.Minute
+	a.tasks.add("Look up images in Kubernetes clusters", cron.Every(interval), true, func(t *backgroundTask) (string, error) {
+		usage := kubernetes.Collect(a.config.KubernetesClusters)
+		a.clusters.mux.Lock()
+		a.clusters.usage = usage
+		a.clusters.mux.Unlock()
+		if len(usage.Errors) > 0 {
+			var errs []string
+			for name, err := range usage.Errors {
+				errs = append(errs, name+": "+err)
+			}
+			return "", fmt.Errorf("%s", strings.Join(errs, "; "))
+		}
+		return fmt.Sprintf("%d clusters", len(a.config.KubernetesClusters)), nil
+	})
+}
+
+// imageUses clusters running the tag by its image name or digest.
+func (a *apiClient) imageUses(repo, tag, digest string) []kubernetes.Use {
+	a.clusters.mux.Lock()
+	usage := a.clusters.usage
+	a.clusters.mux.Unlock()
+	return usage.Lookup(a.config.imageName(repo)+":"+tag, digest)
+}
+
+// guardClusterUsage refuse deleting the tag still running in any cluster.
+func (a *apiClient) guardClusterUsage(repo, tag, digest string) error {
+	uses := a.imageUses(repo, tag, digest)
+	if len(uses) == 0 {
+		return nil
+	}
+	var list []string
+	for _, u := range uses {
+		list = append(list, u.String())
+	}
+	return fmt.Errorf("%w by %s", registry.ErrTagInUse, strings.Join(list, ", "))
+}
+
+// usageBadges clusters running the image.
+func usageBadges(uses []kubernetes.Use) string {
+	var badges []string
+	for _, u := range uses {
+		badges = append(badges, fmt.Sprintf(`<span class="label label-primary">%s</span>`, html.EscapeString(u.String())))
+	}
+	return strings.Join(badges, " ")
+}
+
+// clusterStatuses result of the latest lookup by cluster.
+func (a *apiClient) clusterStatuses() []clusterStatus {
+	a.clusters.mux.Lock()
+	usage := a.clusters.usage
+	a.clusters.mux.Unlock()
+	var list []clusterStatus
+	for _, k := range a.config.KubernetesClusters {
+		s := clusterStatus{Name: k.Name}
+		switch {
+		case usage == nil:
+			s.Message = "not looked up yet"
+		case usage.Errors[k.Name] != "":
+			s.Message = usage.Errors[k.Name]
+		default:
+			s.OK = true
+			s.Message = "looked up at " + usage.Updated.Format("2006-01-02 15:04:05")
+		}
+		list = append(list, s)
+	}
+	return list
+}
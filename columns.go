@@ -0,0 +1,79 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/quiq/docker-registry-ui/registry"
+)
+
+// tagColumnsPreference name of the user preference with visible columns of the tag list.
+const tagColumnsPreference = "tag_columns"
+
+// tagColumn optional column of the tag list.
+type tagColumn struct {
+	Name  string
+	Title string
+}
+
+// tagColumns optional columns of the tag list in the order shown.
+var tagColumns = []tagColumn{
+	{"digest", "Digest"},
+	{"created", "Created"},
+	{"age", "Age"},
+	{"size", "Size"},
+	{"platforms", "Platforms"},
+	{"pulls", "Pulls"},
+	{"vulnerabilities", "Vulnerabilities"},
+	{"policy", "Policy"},
+	{"upstream", "Upstream"},
+	{"usage", "In Use"},
+}
+
+// defaultTagColumns shown until the user chooses otherwise, the custom columns are shown too.
+var defaultTagColumns = []string{"created", "age", "size", "vulnerabilities", "policy", "upstream", "usage"}
+
+// visibleTagColumns columns of the tag list chosen by the user.
+func (a *apiClient) visibleTagColumns(c echo.Context) map[string]bool {
+	names := defaultTagColumns
+	for _, p := range a.metadata {
+		names = append(names, p.Column().Name)
+	}
+	if value := a.eventListener.GetPreference(a.viewerOf(c), tagColumnsPreference); value != "" {
+		names = strings.Split(value, ",")
+	}
+	visible := map[string]bool{}
+	for _, n := range names {
+		visible[n] = true
+	}
+	return visible
+}
+
+// saveTagColumns store the columns of the tag list chosen by the user and go back to the tag list.
+func (a *apiClient) saveTagColumns(c echo.Context) error {
+	form, err := c.FormParams()
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	var names []string
+	for _, col := range a.allTagColumns() {
+		if registry.ItemInSlice(col.Name, form["columns"]) {
+			names = append(names, col.Name)
+		}
+	}
+	// Hiding all columns is stored as "none" to tell it from not set preference.
+	value := strings.Join(names, ",")
+	if value == "" {
+		value = "none"
+	}
+	if err := a.eventListener.SetPreference(a.viewerOf(c), tagColumnsPreference, value); err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+
+	back := c.FormValue("back")
+	if !strings.HasPrefix(back, a.config.BasePath+"/") || strings.HasPrefix(back, "//") {
+		back = a.config.BasePath + "/"
+	}
+	return c.Redirect(http.StatusSeeOther, back)
+}
@@ -0,0 +1,614 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/mail"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/quiq/docker-registry-ui/events"
+	"github.com/quiq/docker-registry-ui/kubernetes"
+	"github.com/quiq/docker-registry-ui/lint"
+	"github.com/quiq/docker-registry-ui/registry"
+	"github.com/quiq/docker-registry-ui/scanner"
+	"github.com/robfig/cron"
+	"gopkg.in/yaml.v2"
+)
+
+type configData struct {
+	ListenAddr                    string                  `yaml:"listen_addr"`
+	BasePath                      string                  `yaml:"base_path"`
+	RegistryURL                   string                  `yaml:"registry_url"`
+	VerifyTLS                     bool                    `yaml:"verify_tls"`
+	Username                      string                  `yaml:"registry_username"`
+	Password                      string                  `yaml:"registry_password"`
+	PasswordFile                  string                  `yaml:"registry_password_file"`
+	RegistryPullHost              string                  `yaml:"registry_pull_host"`
+	RegistryPullHosts             []registry.PullHost     `yaml:"registry_pull_hosts"`
+	RegistryFlavor                string                  `yaml:"registry_flavor"`
+	RegistryAPIToken              string                  `yaml:"registry_api_token"`
+	RegistryAPITokenFile          string                  `yaml:"registry_api_token_file"`
+	MaxConcurrentRegistryRequests int                     `yaml:"max_concurrent_registry_requests"`
+	RegistryConnectRetries        int                     `yaml:"registry_connect_retries"`
+	ProxyRemoteURL                string                  `yaml:"proxy_remote_url"`
+	ProxyUsername                 string                  `yaml:"proxy_username"`
+	ProxyPassword                 string                  `yaml:"proxy_password"`
+	BaseImageUpstreams            []registry.BaseUpstream `yaml:"base_image_upstreams"`
+	RegistryMock                  bool                    `yaml:"registry_mock"`
+	RegistryMockFixture           string                  `yaml:"registry_mock_fixture"`
+	EventListenerToken            string                  `yaml:"event_listener_token"`
+	EventListenerTokens           []string                `yaml:"event_listener_tokens"`
+	EventListenerTokenFile        string                  `yaml:"event_listener_token_file"`
+	EventRetentionDays            int                     `yaml:"event_retention_days"`
+	EventDatabaseDriver           string                  `yaml:"event_database_driver"`
+	EventDatabaseLocation         string                  `yaml:"event_database_location"`
+	EventDeletionEnabled          bool                    `yaml:"event_deletion_enabled"`
+	RestoreMaxUploadMB            int                     `yaml:"restore_max_upload_mb"`
+	EventDedupSeconds             int                     `yaml:"event_dedup_seconds"`
+	EventAggregateSeconds         int                     `yaml:"event_aggregate_seconds"`
+	WebhookAttempts               int                     `yaml:"webhook_attempts"`
+	SMTPHost                      string                  `yaml:"smtp_host"`
+	SMTPPort                      int                     `yaml:"smtp_port"`
+	SMTPTLS                       bool                    `yaml:"smtp_tls"`
+	SMTPUsername                  string                  `yaml:"smtp_username"`
+	SMTPPassword                  string                  `yaml:"smtp_password"`
+	SMTPFrom                      string                  `yaml:"smtp_from"`
+	EmailRules                    []emailRule             `yaml:"email_rules"`
+	CacheRefreshInterval          uint8                   `yaml:"cache_refresh_interval"`
+	CatalogRefreshCron            string                  `yaml:"catalog_refresh_cron"`
+	SearchIndexMetadata           bool                    `yaml:"search_index_metadata"`
+	PageCacheMaxAge               int                     `yaml:"page_cache_max_age"`
+	TagCacheMaxRepos              int                     `yaml:"tag_cache_max_repos"`
+	TagCacheMaxTagsPerRepo        int                     `yaml:"tag_cache_max_tags_per_repo"`
+	TagCacheMaxEntries            int                     `yaml:"tag_cache_max_entries"`
+	CatalogResumeFile             string                  `yaml:"catalog_resume_file"`
+	ConfigCacheDir                string                  `yaml:"config_cache_dir"`
+	APIRequireToken               bool                    `yaml:"api_require_token"`
+	AnyoneCanDelete               bool                    `yaml:"anyone_can_delete"`
+	DeleteReasonRequired          bool                    `yaml:"delete_reason_required"`
+	DeleteUndoMinutes             int                     `yaml:"delete_undo_minutes"`
+	DeletionApprovals             bool                    `yaml:"deletion_approvals"`
+	DeletionApprovalsWebhookURL   string                  `yaml:"deletion_approvals_webhook_url"`
+	Admins                        []string                `yaml:"admins"`
+	Deleters                      []string                `yaml:"deleters"`
+	RetentionPreviewers           []string                `yaml:"retention_previewers"`
+	RetentionManagers             []string                `yaml:"retention_managers"`
+	Tenants                       []tenant                `yaml:"tenants"`
+	TenantGroupsHeader            string                  `yaml:"tenant_groups_header"`
+	UserHeader                    string                  `yaml:"user_header"`
+	TrustedProxies                []string                `yaml:"trusted_proxies"`
+	GroupsHeader                  string                  `yaml:"groups_header"`
+	AdminGroups                   []string                `yaml:"admin_groups"`
+	DeleterGroups                 []string                `yaml:"deleter_groups"`
+	LocalUsers                    bool                    `yaml:"local_users"`
+	SessionMaxAgeHours            int                     `yaml:"session_max_age_hours"`
+	LoginAttemptsPerMinute        int                     `yaml:"login_attempts_per_minute"`
+	LoginMaxFailures              int                     `yaml:"login_max_failures"`
+	LoginLockoutMinutes           int                     `yaml:"login_lockout_minutes"`
+	Debug                         bool                    `yaml:"debug"`
+	TemplatesOverrideDir          string                  `yaml:"templates_override_dir"`
+	NoscriptMode                  bool                    `yaml:"noscript_mode"`
+	ResponseCompression           bool                    `yaml:"response_compression"`
+	AccessLog                     string                  `yaml:"access_log"`
+	AccessLogFormat               string                  `yaml:"access_log_format"`
+	AccessLogSkipPaths            []string                `yaml:"access_log_skip_paths"`
+	AccessLogSampleRate           float64                 `yaml:"access_log_sample_rate"`
+	SentryDSN                     string                  `yaml:"sentry_dsn"`
+	ErrorWebhookURL               string                  `yaml:"error_webhook_url"`
+	ErrorReportInterval           int                     `yaml:"error_report_interval"`
+	Metrics                       bool                    `yaml:"metrics"`
+	MetricsToken                  string                  `yaml:"metrics_token"`
+	DebugEndpoints                bool                    `yaml:"debug_endpoints"`
+	PurgeTagsKeepDays             int                     `yaml:"purge_tags_keep_days"`
+	PurgeTagsKeepCount            int                     `yaml:"purge_tags_keep_count"`
+	PurgeTagsSchedule             string                  `yaml:"purge_tags_schedule"`
+	MaintenanceSchedule           string                  `yaml:"maintenance_window_schedule"`
+	MaintenanceDuration           int                     `yaml:"maintenance_window_duration"`
+	GCCommand                     string                  `yaml:"gc_command"`
+	GCURL                         string                  `yaml:"gc_url"`
+	GCURLMethod                   string                  `yaml:"gc_url_method"`
+	GCURLBody                     string                  `yaml:"gc_url_body"`
+	GCURLUsername                 string                  `yaml:"gc_url_username"`
+	GCURLPassword                 string                  `yaml:"gc_url_password"`
+	GCAfterDeletions              bool                    `yaml:"gc_after_deletions"`
+	GCTimeout                     int                     `yaml:"gc_timeout"`
+	Scanner                       string                  `yaml:"scanner"`
+	ScannerTimeout                int                     `yaml:"scanner_timeout"`
+	ScannerGrypePath              string                  `yaml:"scanner_grype_path"`
+	ScannerGrypeDBUpdateURL       string                  `yaml:"scanner_grype_db_update_url"`
+	SigningCosignPath             string                  `yaml:"signing_cosign_path"`
+	SigningKey                    string                  `yaml:"signing_key"`
+	SigningKeyPassword            string                  `yaml:"signing_key_password"`
+	SigningTokenFile              string                  `yaml:"signing_identity_token_file"`
+	SigningFulcioURL              string                  `yaml:"signing_fulcio_url"`
+	SigningRekorURL               string                  `yaml:"signing_rekor_url"`
+	SigningTimeout                int                     `yaml:"signing_timeout"`
+	PolicyRequireSignature        bool                    `yaml:"policy_require_signature"`
+	PolicyRequireScan             bool                    `yaml:"policy_require_scan"`
+	PolicyMaxSeverity             string                  `yaml:"policy_max_severity"`
+	PolicyMaxSizeMB               int                     `yaml:"policy_max_size_mb"`
+	PolicyAllowedBaseImages       []string                `yaml:"policy_allowed_base_images"`
+	LintRules                     map[string]string       `yaml:"lint_rules"`
+	LintMaxLayerMB                int                     `yaml:"lint_max_layer_mb"`
+	LintRequiredAnnotations       []string                `yaml:"lint_required_annotations"`
+	TagConventions                lint.Conventions        `yaml:"tag_conventions"`
+	SecretScanRepos               []string                `yaml:"secret_scan_repos"`
+	SecretScanMaxLayerMB          int                     `yaml:"secret_scan_max_layer_mb"`
+	SecretScanMaxFileKB           int                     `yaml:"secret_scan_max_file_kb"`
+	StorageDriver                 string                  `yaml:"storage_driver"`
+	StorageRoot                   string                  `yaml:"storage_filesystem_root"`
+	StorageS3Bucket               string                  `yaml:"storage_s3_bucket"`
+	StorageS3Region               string                  `yaml:"storage_s3_region"`
+	StorageS3Endpoint             string                  `yaml:"storage_s3_endpoint"`
+	StorageS3AccessKey            string                  `yaml:"storage_s3_access_key"`
+	StorageS3SecretKey            string                  `yaml:"storage_s3_secret_key"`
+	StorageS3RootDirectory        string                  `yaml:"storage_s3_root_directory"`
+	ImageAgeWarningDays           int                     `yaml:"image_age_warning_days"`
+	ImageAgeCriticalDays          int                     `yaml:"image_age_critical_days"`
+	StaleRepoDays                 int                     `yaml:"stale_repo_days"`
+	TransferDir                   string                  `yaml:"transfer_dir"`
+	TransferKeepExports           int                     `yaml:"transfer_keep_exports"`
+	TransferMaxUploadMB           int                     `yaml:"transfer_max_upload_mb"`
+	RepoOwners                    []events.RepoOwner      `yaml:"repo_owners"`
+	MetadataColumns               []metadataHook          `yaml:"metadata_columns"`
+	ImageLinks                    []imageLink             `yaml:"image_links"`
+	LayerFilesMaxSizeMB           int                     `yaml:"layer_files_max_size_mb"`
+	LayerFilesMaxEntries          int                     `yaml:"layer_files_max_entries"`
+	FileIndexRepos                []string                `yaml:"file_index_repos"`
+	FileIndexMaxFiles             int                     `yaml:"file_index_max_files"`
+	FileIndexInterval             int                     `yaml:"file_index_interval"`
+	BlobGraphRepos                []string                `yaml:"blob_graph_repos"`
+	BlobGraphInterval             int                     `yaml:"blob_graph_interval"`
+	KubernetesClusters            []kubernetes.Cluster    `yaml:"kubernetes_clusters"`
+	KubernetesRefresh             int                     `yaml:"kubernetes_refresh_interval"`
+	Features                      map[string]bool         `yaml:"features"`
+}
+
+// imageName name to pull the repo by, e.g. registry.local/team/app.
+func (c *configData) imageName(repo string) string {
+	host := c.RegistryPullHost
+	if host == "" {
+		u, _ := url.Parse(c.RegistryURL)
+		host = u.Host
+	}
+	return registry.ImageName(c.RegistryPullHosts, host, repo)
+}
+
+// readConfig read config file, env vars overriding it and the files referenced from them.
+// The config file is optional when the settings are passed by env vars only, e.g. in Kubernetes.
+func readConfig(configFile string) (configData, error) {
+	var config configData
+	// Omitted settings keep the defaults of the options schema.
+	if err := yaml.Unmarshal(defaultConfig(), &config); err != nil {
+		return config, fmt.Errorf("cannot parse default config: %s", err)
+	}
+	bytes, err := ioutil.ReadFile(configFile)
+	if err != nil && !(envConfigured() && (configFile == "" || os.IsNotExist(err))) {
+		return config, fmt.Errorf("cannot read config file: %s", err)
+	}
+	if err := yaml.Unmarshal(bytes, &config); err != nil {
+		return config, fmt.Errorf("cannot parse config file %s: %s", configFile, err)
+	}
+	if err := applyEnv(&config); err != nil {
+		return config, err
+	}
+
+	// Normalize base path.
+	if config.BasePath != "" {
+		if !strings.HasPrefix(config.BasePath, "/") {
+			config.BasePath = "/" + config.BasePath
+		}
+		if strings.HasSuffix(config.BasePath, "/") {
+			config.BasePath = config.BasePath[0 : len(config.BasePath)-1]
+		}
+	}
+	if config.ScannerGrypePath == "" {
+		config.ScannerGrypePath = "grype"
+	}
+	if config.ScannerTimeout == 0 {
+		config.ScannerTimeout = 10
+	}
+	if config.GCTimeout == 0 {
+		config.GCTimeout = 60
+	}
+	if config.GCURLMethod == "" {
+		config.GCURLMethod = "POST"
+	}
+	// Read password from file.
+	if config.PasswordFile != "" {
+		if config.Password, err = readSecret(config.PasswordFile); err != nil {
+			return config, fmt.Errorf("registry_password_file: cannot read password: %s", err)
+		}
+	}
+	if config.RegistryAPITokenFile != "" {
+		if config.RegistryAPIToken, err = readSecret(config.RegistryAPITokenFile); err != nil {
+			return config, fmt.Errorf("registry_api_token_file: cannot read token: %s", err)
+		}
+	}
+	return config, nil
+}
+
+// groupsHeader header with the groups of the user, tenant_groups_header is the former name of groups_header.
+func (c configData) groupsHeader() string {
+	if c.TenantGroupsHeader != "" {
+		return c.TenantGroupsHeader
+	}
+	return c.GroupsHeader
+}
+
+// validate check the config values for sanity, returns all the problems found.
+func (c *configData) validate() []error {
+	var errs []error
+	if c.ListenAddr == "" {
+		errs = append(errs, fmt.Errorf("listen_addr: should be set, e.g. 0.0.0.0:8000"))
+	}
+	if c.TemplatesOverrideDir != "" {
+		if info, err := os.Stat(c.TemplatesOverrideDir); err != nil {
+			errs = append(errs, fmt.Errorf("templates_override_dir: %s", err))
+		} else if !info.IsDir() {
+			errs = append(errs, fmt.Errorf("templates_override_dir: %s is not a directory", c.TemplatesOverrideDir))
+		}
+	}
+	if c.RegistryMock {
+		if _, err := os.Stat(c.RegistryMockFixture); err != nil {
+			errs = append(errs, fmt.Errorf("registry_mock_fixture: %s", err))
+		}
+	} else if u, err := url.Parse(c.RegistryURL); err != nil {
+		errs = append(errs, fmt.Errorf("registry_url: %s", err))
+	} else if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		errs = append(errs, fmt.Errorf("registry_url: should include schema and host, e.g. https://docker-registry.local, got %q", c.RegistryURL))
+	}
+	if c.RegistryConnectRetries < 0 {
+		errs = append(errs, fmt.Errorf("registry_connect_retries: should not be negative"))
+	}
+	if c.MaxConcurrentRegistryRequests < 0 {
+		errs = append(errs, fmt.Errorf("max_concurrent_registry_requests: should not be negative"))
+	}
+	if c.EventListenerTokenFile != "" {
+		if _, err := os.Stat(c.EventListenerTokenFile); err != nil {
+			errs = append(errs, fmt.Errorf("event_listener_token_file: %s", err))
+		}
+	}
+	if c.EventRetentionDays < 0 {
+		errs = append(errs, fmt.Errorf("event_retention_days: should not be negative"))
+	}
+	if c.RestoreMaxUploadMB < 0 {
+		errs = append(errs, fmt.Errorf("restore_max_upload_mb: should not be negative"))
+	}
+	if c.EventDedupSeconds < 0 || c.EventAggregateSeconds < 0 {
+		errs = append(errs, fmt.Errorf("event_dedup_seconds/event_aggregate_seconds: should not be negative"))
+	}
+	switch c.EventDatabaseDriver {
+	case "sqlite3":
+		if c.EventDatabaseLocation == "" {
+			errs = append(errs, fmt.Errorf("event_database_location: should be set to the path of sqlite db file"))
+		} else if _, err := os.Stat(filepath.Dir(c.EventDatabaseLocation)); err != nil {
+			errs = append(errs, fmt.Errorf("event_database_location: directory for sqlite db file does not exist: %s", err))
+		}
+	case "mysql":
+		if c.EventDatabaseLocation == "" {
+			errs = append(errs, fmt.Errorf("event_database_location: should be set to mysql DSN"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("event_database_driver: should be either sqlite3 or mysql, got %q", c.EventDatabaseDriver))
+	}
+	if c.CacheRefreshInterval == 0 {
+		errs = append(errs, fmt.Errorf("cache_refresh_interval: should be at least 1 minute"))
+	}
+	if c.AccessLogFormat != "combined" && c.AccessLogFormat != "json" {
+		errs = append(errs, fmt.Errorf("access_log_format: should be either combined or json, got %q", c.AccessLogFormat))
+	}
+	if c.AccessLogSampleRate <= 0 || c.AccessLogSampleRate > 1 {
+		errs = append(errs, fmt.Errorf("access_log_sample_rate: should be greater than 0 and at most 1"))
+	}
+	if c.SentryDSN != "" {
+		if _, err := newSentryReporter(c.SentryDSN); err != nil {
+			errs = append(errs, fmt.Errorf("sentry_dsn: %s", err))
+		}
+	}
+	if c.ErrorWebhookURL != "" {
+		if u, err := url.Parse(c.ErrorWebhookURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			errs = append(errs, fmt.Errorf("error_webhook_url: should be http or https URL"))
+		}
+	}
+	if c.DeletionApprovalsWebhookURL != "" {
+		if u, err := url.Parse(c.DeletionApprovalsWebhookURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			errs = append(errs, fmt.Errorf("deletion_approvals_webhook_url: should be http or https URL"))
+		}
+	}
+	if c.ErrorReportInterval < 0 {
+		errs = append(errs, fmt.Errorf("error_report_interval: should not be negative"))
+	}
+	if c.PageCacheMaxAge < 0 {
+		errs = append(errs, fmt.Errorf("page_cache_max_age: should not be negative"))
+	}
+	if c.TagCacheMaxRepos < 0 || c.TagCacheMaxTagsPerRepo < 0 || c.TagCacheMaxEntries < 0 {
+		errs = append(errs, fmt.Errorf("tag_cache_max_repos, tag_cache_max_tags_per_repo, tag_cache_max_entries: should not be negative"))
+	}
+	if c.CatalogRefreshCron != "" {
+		if _, err := cron.Parse(c.CatalogRefreshCron); err != nil {
+			errs = append(errs, fmt.Errorf("catalog_refresh_cron: invalid schedule format %q: %s", c.CatalogRefreshCron, err))
+		}
+	}
+	if c.PurgeTagsKeepDays < 0 {
+		errs = append(errs, fmt.Errorf("purge_tags_keep_days: should not be negative"))
+	}
+	if c.PurgeTagsKeepCount < 0 {
+		errs = append(errs, fmt.Errorf("purge_tags_keep_count: should not be negative"))
+	}
+	if c.ImageAgeWarningDays < 0 || c.ImageAgeCriticalDays < 0 {
+		errs = append(errs, fmt.Errorf("image_age_warning_days/image_age_critical_days: should not be negative"))
+	} else if c.ImageAgeCriticalDays > 0 && c.ImageAgeCriticalDays < c.ImageAgeWarningDays {
+		errs = append(errs, fmt.Errorf("image_age_critical_days: should be greater than image_age_warning_days"))
+	}
+	if c.DeleteUndoMinutes < 0 {
+		errs = append(errs, fmt.Errorf("delete_undo_minutes: should not be negative"))
+	}
+	if c.TransferDir != "" {
+		if fi, err := os.Stat(c.TransferDir); err != nil {
+			errs = append(errs, fmt.Errorf("transfer_dir: %s", err))
+		} else if !fi.IsDir() {
+			errs = append(errs, fmt.Errorf("transfer_dir: %s is not a directory", c.TransferDir))
+		}
+		if c.TransferKeepExports <= 0 {
+			errs = append(errs, fmt.Errorf("transfer_keep_exports: should be at least 1"))
+		}
+		if c.TransferMaxUploadMB < 0 {
+			errs = append(errs, fmt.Errorf("transfer_max_upload_mb: should not be negative"))
+		}
+	}
+	if c.WebhookAttempts < 1 {
+		errs = append(errs, fmt.Errorf("webhook_attempts: should be at least 1"))
+	}
+	for i, r := range c.EmailRules {
+		if err := r.validate(); err != nil {
+			errs = append(errs, fmt.Errorf("email_rules: item %d: %s", i+1, err))
+		}
+	}
+	if len(c.EmailRules) > 0 {
+		if c.SMTPHost == "" {
+			errs = append(errs, fmt.Errorf("smtp_host: should be set when email_rules are configured"))
+		}
+		if c.SMTPPort <= 0 || c.SMTPPort > 65535 {
+			errs = append(errs, fmt.Errorf("smtp_port: should be a port number, got %d", c.SMTPPort))
+		}
+		if _, err := mail.ParseAddress(c.SMTPFrom); err != nil {
+			errs = append(errs, fmt.Errorf("smtp_from: should be an email address, e.g. Registry UI <registry-ui@example.com>: %s", err))
+		}
+	}
+	if c.StaleRepoDays < 0 {
+		errs = append(errs, fmt.Errorf("stale_repo_days: should not be negative"))
+	}
+	for i, o := range c.RepoOwners {
+		if o.Repos == "" || o.Team == "" {
+			errs = append(errs, fmt.Errorf("repo_owners: repos and team of the item %d should be set", i+1))
+		}
+	}
+	names := map[string]bool{}
+	for i, h := range c.MetadataColumns {
+		if !metadataNameRegexp.MatchString(h.Name) {
+			errs = append(errs, fmt.Errorf("metadata_columns: name of the item %d should be lowercase letters, digits and underscores, got %q", i+1, h.Name))
+		} else if names[h.Name] {
+			errs = append(errs, fmt.Errorf("metadata_columns: duplicate name %q", h.Name))
+		}
+		names[h.Name] = true
+		if u, err := url.Parse(h.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			errs = append(errs, fmt.Errorf("metadata_columns: url of the item %d should be http or https URL, got %q", i+1, h.URL))
+		}
+		if h.Timeout < 0 {
+			errs = append(errs, fmt.Errorf("metadata_columns: timeout of the item %d should not be negative", i+1))
+		}
+	}
+	for i, l := range c.ImageLinks {
+		if err := l.validate(); err != nil {
+			errs = append(errs, fmt.Errorf("image_links: item %d: %s", i+1, err))
+		}
+	}
+	for i, t := range c.Tenants {
+		if t.Group == "" && len(t.Users) == 0 {
+			errs = append(errs, fmt.Errorf("tenants: group or users of the item %d should be set", i+1))
+		}
+		if len(t.Repos) == 0 {
+			errs = append(errs, fmt.Errorf("tenants: repos of the item %d should be set", i+1))
+		}
+	}
+	if c.UserHeader == "" {
+		errs = append(errs, fmt.Errorf("user_header: should be set"))
+	}
+	if _, err := parseTrustedProxies(c.TrustedProxies); err != nil {
+		errs = append(errs, fmt.Errorf("trusted_proxies: %s", err))
+	}
+	if c.LocalUsers && c.SessionMaxAgeHours < 1 {
+		errs = append(errs, fmt.Errorf("session_max_age_hours: should be at least 1"))
+	}
+	if c.LoginAttemptsPerMinute < 0 {
+		errs = append(errs, fmt.Errorf("login_attempts_per_minute: should not be negative"))
+	}
+	if c.LoginMaxFailures < 0 {
+		errs = append(errs, fmt.Errorf("login_max_failures: should not be negative"))
+	}
+	if c.LoginMaxFailures > 0 && c.LoginLockoutMinutes < 1 {
+		errs = append(errs, fmt.Errorf("login_lockout_minutes: should be at least 1 when login_max_failures is set"))
+	}
+	if (len(c.Tenants) > 0 || len(c.AdminGroups) > 0 || len(c.DeleterGroups) > 0) && c.groupsHeader() == "" {
+		errs = append(errs, fmt.Errorf("groups_header: should be set when tenants or admin and deleter groups are configured"))
+	}
+	for i, u := range c.BaseImageUpstreams {
+		if u.Prefix == "" {
+			errs = append(errs, fmt.Errorf("base_image_upstreams: prefix of the item %d should be set", i+1))
+		}
+		if p, err := url.Parse(u.URL); err != nil || (p.Scheme != "http" && p.Scheme != "https") {
+			errs = append(errs, fmt.Errorf("base_image_upstreams: url of the item %d should be http or https URL, got %q", i+1, u.URL))
+		}
+	}
+	if c.PurgeTagsSchedule != "" {
+		if _, err := cron.Parse(c.PurgeTagsSchedule); err != nil {
+			errs = append(errs, fmt.Errorf("purge_tags_schedule: invalid schedule format %q: %s", c.PurgeTagsSchedule, err))
+		}
+	}
+	if c.MaintenanceSchedule != "" {
+		if _, err := cron.Parse(c.MaintenanceSchedule); err != nil {
+			errs = append(errs, fmt.Errorf("maintenance_window_schedule: invalid schedule format %q: %s", c.MaintenanceSchedule, err))
+		}
+		if c.MaintenanceDuration <= 0 {
+			errs = append(errs, fmt.Errorf("maintenance_window_duration: should be set in minutes when maintenance_window_schedule is used"))
+		}
+	}
+	if c.GCURL != "" {
+		if u, err := url.Parse(c.GCURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			errs = append(errs, fmt.Errorf("gc_url: should be http or https URL, got %q", c.GCURL))
+		}
+	}
+	if c.GCTimeout < 0 {
+		errs = append(errs, fmt.Errorf("gc_timeout: should not be negative"))
+	}
+	if c.GCAfterDeletions && c.GCCommand == "" && c.GCURL == "" {
+		errs = append(errs, fmt.Errorf("gc_after_deletions: requires gc_command or gc_url"))
+	}
+	for i, h := range c.RegistryPullHosts {
+		if h.Host == "" {
+			errs = append(errs, fmt.Errorf("registry_pull_hosts: host of the item %d should be set", i+1))
+		}
+	}
+	switch c.RegistryFlavor {
+	case "", registry.FlavorAuto, registry.FlavorDistribution, registry.FlavorHarbor, registry.FlavorQuay:
+	default:
+		errs = append(errs, fmt.Errorf("registry_flavor: should be auto, distribution, harbor or quay, got %q", c.RegistryFlavor))
+	}
+	if c.ProxyRemoteURL != "" {
+		if u, err := url.Parse(c.ProxyRemoteURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("proxy_remote_url: should be http or https URL, got %q", c.ProxyRemoteURL))
+		}
+	}
+	switch c.Scanner {
+	case "":
+	case "grype":
+		if _, err := exec.LookPath(c.ScannerGrypePath); err != nil {
+			errs = append(errs, fmt.Errorf("scanner_grype_path: %s", err))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("scanner: only grype is supported, got %q", c.Scanner))
+	}
+	if c.ScannerTimeout < 0 {
+		errs = append(errs, fmt.Errorf("scanner_timeout: should not be negative"))
+	}
+	if c.SigningKey != "" || c.SigningTokenFile != "" {
+		if c.SigningKey != "" && c.SigningTokenFile != "" {
+			errs = append(errs, fmt.Errorf("signing_key/signing_identity_token_file: only one of them should be set"))
+		}
+		if _, err := exec.LookPath(c.SigningCosignPath); err != nil {
+			errs = append(errs, fmt.Errorf("signing_cosign_path: %s", err))
+		}
+		if c.SigningKey != "" && !strings.Contains(c.SigningKey, "://") {
+			if _, err := os.Stat(c.SigningKey); err != nil {
+				errs = append(errs, fmt.Errorf("signing_key: %s", err))
+			}
+		}
+	}
+	if c.SigningTimeout < 0 {
+		errs = append(errs, fmt.Errorf("signing_timeout: should not be negative"))
+	}
+	if c.PolicyMaxSeverity != "" {
+		known := false
+		for _, s := range scanner.Severities {
+			known = known || s == c.PolicyMaxSeverity
+		}
+		if !known {
+			errs = append(errs, fmt.Errorf("policy_max_severity: should be one of %s", strings.Join(scanner.Severities, ", ")))
+		}
+	}
+	if (c.PolicyRequireScan || c.PolicyMaxSeverity != "") && c.Scanner == "" {
+		errs = append(errs, fmt.Errorf("policy_require_scan/policy_max_severity: requires scanner"))
+	}
+	if c.PolicyMaxSizeMB < 0 {
+		errs = append(errs, fmt.Errorf("policy_max_size_mb: should not be negative"))
+	}
+	for _, rule := range registry.SortedMapKeys(c.LintRules) {
+		if _, ok := lint.DefaultSeverity[rule]; !ok {
+			errs = append(errs, fmt.Errorf("lint_rules: unknown rule %q, should be one of %s", rule,
+				strings.Join(registry.SortedMapKeys(lint.DefaultSeverity), ", ")))
+		} else if !lint.ValidSeverity(c.LintRules[rule]) {
+			errs = append(errs, fmt.Errorf("lint_rules: severity of %s should be one of %s", rule, strings.Join(lint.Severities, ", ")))
+		}
+	}
+	if c.LintMaxLayerMB < 0 {
+		errs = append(errs, fmt.Errorf("lint_max_layer_mb: should not be negative"))
+	}
+	for i, t := range c.TagConventions {
+		if len(t.Patterns) == 0 {
+			errs = append(errs, fmt.Errorf("tag_conventions: patterns of the item %d should be set", i+1))
+		}
+		for _, p := range t.Patterns {
+			if _, err := lint.TagPattern(p); err != nil {
+				errs = append(errs, fmt.Errorf("tag_conventions: invalid pattern %q of the item %d: %s", p, i+1, err))
+			}
+		}
+	}
+	for _, p := range c.SecretScanRepos {
+		if !validRepoPattern(p) {
+			errs = append(errs, fmt.Errorf("secret_scan_repos: invalid repo pattern %q", p))
+		}
+	}
+	if len(c.SecretScanRepos) > 0 {
+		if c.SecretScanMaxLayerMB <= 0 {
+			errs = append(errs, fmt.Errorf("secret_scan_max_layer_mb: should be at least 1"))
+		}
+		if c.SecretScanMaxFileKB <= 0 {
+			errs = append(errs, fmt.Errorf("secret_scan_max_file_kb: should be at least 1"))
+		}
+	}
+	switch c.StorageDriver {
+	case "":
+	case "filesystem":
+		if _, err := os.Stat(c.StorageRoot); err != nil {
+			errs = append(errs, fmt.Errorf("storage_filesystem_root: %s", err))
+		}
+	case "s3":
+		if c.StorageS3Bucket == "" || c.StorageS3Region == "" {
+			errs = append(errs, fmt.Errorf("storage_s3_bucket/storage_s3_region: should be set for s3 storage driver"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("storage_driver: should be either filesystem or s3, got %q", c.StorageDriver))
+	}
+	if c.LayerFilesMaxSizeMB < 0 {
+		errs = append(errs, fmt.Errorf("layer_files_max_size_mb: should not be negative"))
+	}
+	if c.LayerFilesMaxSizeMB > 0 && c.LayerFilesMaxEntries <= 0 {
+		errs = append(errs, fmt.Errorf("layer_files_max_entries: should be at least 1"))
+	}
+	if c.FileIndexMaxFiles > 0 {
+		if c.LayerFilesMaxSizeMB <= 0 {
+			errs = append(errs, fmt.Errorf("file_index_max_files: the file index needs layer_files_max_size_mb to read layers"))
+		}
+		if c.FileIndexInterval <= 0 {
+			errs = append(errs, fmt.Errorf("file_index_interval: should be at least 1 minute"))
+		}
+	}
+	for _, p := range c.FileIndexRepos {
+		if !validRepoPattern(p) {
+			errs = append(errs, fmt.Errorf("file_index_repos: invalid repo pattern %q", p))
+		}
+	}
+	for _, p := range c.BlobGraphRepos {
+		if !validRepoPattern(p) {
+			errs = append(errs, fmt.Errorf("blob_graph_repos: invalid repo pattern %q", p))
+		}
+	}
+	if len(c.BlobGraphRepos) > 0 && c.BlobGraphInterval <= 0 {
+		errs = append(errs, fmt.Errorf("blob_graph_interval: should be at least 1 minute"))
+	}
+	for i, k := range c.KubernetesClusters {
+		if k.Name == "" {
+			errs = append(errs, fmt.Errorf("kubernetes_clusters: name of the item %d should be set", i+1))
+		}
+	}
+	if len(c.KubernetesClusters) > 0 && c.KubernetesRefresh <= 0 {
+		errs = append(errs, fmt.Errorf("kubernetes_refresh_interval: should be at least 1 minute"))
+	}
+	errs = append(errs, c.validateFeatures()...)
+	return errs
+}
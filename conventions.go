@@ -0,0 +1,89 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/quiq/docker-registry-ui/lint"
+)
+
+// conventionSampleLimit non-conforming tags listed per repo on the report.
+const conventionSampleLimit = 20
+
+// conventionRepo compliance of the tags of the repo with its naming convention.
+type conventionRepo struct {
+	Repo       string
+	Convention lint.TagConvention
+	Tags       int
+	// Failing first non-conforming tags, FailingCount all of them.
+	Failing      []string
+	FailingCount int
+}
+
+// Compliance percentage of the conforming tags.
+func (r conventionRepo) Compliance() int {
+	if r.Tags == 0 {
+		return 100
+	}
+	return (r.Tags - r.FailingCount) * 100 / r.Tags
+}
+
+// conventionTitle why the tag does not conform to the naming convention of the repo, empty if it does or there is none.
+func (a *apiClient) conventionTitle(repo, tag string) string {
+	t, ok := a.config.TagConventions.For(repo)
+	if !ok || t.Conforms(tag) {
+		return ""
+	}
+	return "Tag does not match the naming convention: " + strings.Join(t.Patterns, ", ")
+}
+
+// viewConventions report of the tags not conforming to the naming conventions of the repos the user can see.
+func (a *apiClient) viewConventions(c echo.Context) error {
+	if !a.config.feature("conventions") {
+		return c.String(http.StatusNotFound, "Tag naming conventions are not configured, see tag_conventions.")
+	}
+	scope := a.tenantScope(c)
+	var repos []string
+	for namespace, names := range a.client.Repositories(true) {
+		for _, name := range names {
+			if repo := catalogRepoPath(namespace, name); inScope(scope, repo) {
+				repos = append(repos, repo)
+			}
+		}
+	}
+	sort.Strings(repos)
+
+	var report []conventionRepo
+	total, failing := 0, 0
+	for _, repo := range repos {
+		t, ok := a.config.TagConventions.For(repo)
+		if !ok {
+			continue
+		}
+		r := conventionRepo{Repo: repo, Convention: t, Failing: []string{}}
+		for _, tag := range a.client.Tags(repo) {
+			r.Tags++
+			if t.Conforms(tag) {
+				continue
+			}
+			r.FailingCount++
+			if len(r.Failing) < conventionSampleLimit {
+				r.Failing = append(r.Failing, tag)
+			}
+		}
+		total += r.Tags
+		failing += r.FailingCount
+		report = append(report, r)
+	}
+	// The least compliant repos first.
+	sort.SliceStable(report, func(i, j int) bool { return report[i].Compliance() < report[j].Compliance() })
+
+	data := a.setUserPermissions(c)
+	data.Set("report", report)
+	data.Set("total", total)
+	data.Set("failing", failing)
+	data.Set("conventions", a.config.TagConventions)
+	return c.Render(http.StatusOK, "conventions.html", data)
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"html"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/quiq/docker-registry-ui/events"
+	"github.com/quiq/docker-registry-ui/registry"
+	"github.com/quiq/docker-registry-ui/storage"
+)
+
+// badgeRegexp text of the bootstrap labels rendered for the table cells.
+var badgeRegexp = regexp.MustCompile(`<span[^>]*>([^<]*)</span>`)
+
+// csvTable table of the page exported as CSV.
+type csvTable struct {
+	Header []string
+	Rows   [][]string
+}
+
+// csvRequested if the table of the page is requested as CSV by format=csv instead of HTML.
+func csvRequested(c echo.Context) bool {
+	return c.QueryParam("format") == "csv"
+}
+
+// csvCell protect the value from being run as a formula by spreadsheets.
+func csvCell(value string) string {
+	if value != "" && strings.ContainsAny(value[:1], "=+-@\t\r") {
+		return "'" + value
+	}
+	return value
+}
+
+// badgeText plain text of the labels of the cell, e.g. "2 Critical, 5 High".
+func badgeText(badges string) string {
+	var list []string
+	for _, m := range badgeRegexp.FindAllStringSubmatch(badges, -1) {
+		list = append(list, html.UnescapeString(m[1]))
+	}
+	return strings.Join(list, ", ")
+}
+
+// renderCSV stream the table as CSV file named by the page, e.g. tags-team-app-20210414.csv.
+func renderCSV(c echo.Context, name string, t csvTable) error {
+	name = strings.NewReplacer("/", "-", `"`, "", " ", "-").Replace(name)
+	c.Response().Header().Set(echo.HeaderContentType, "text/csv; charset=utf-8")
+	c.Response().Header().Set(echo.HeaderContentDisposition,
+		fmt.Sprintf(`attachment; filename="%s-%s.csv"`, name, time.Now().Format("20060102")))
+	c.Response().WriteHeader(http.StatusOK)
+	w := csv.NewWriter(c.Response())
+	if err := w.Write(t.Header); err != nil {
+		return err
+	}
+	for _, row := range t.Rows {
+		for i := range row {
+			row[i] = csvCell(row[i])
+		}
+		if err := w.Write(row); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// catalogCSV repos with tag counts, unknown counts are left empty.
+func catalogCSV(repos []registry.CatalogRepo) csvTable {
+	t := csvTable{Header: []string{"Namespace", "Repository", "Tags"}}
+	for _, r := range repos {
+		count := ""
+		if r.Tags >= 0 {
+			count = strconv.Itoa(r.Tags)
+		}
+		t.Rows = append(t.Rows, []string{r.Namespace, r.Repo, count})
+	}
+	return t
+}
+
+// tagsCSV tag list with the visible columns as on the page, badges of the cells are given by column name.
+func tagsCSV(available []tagColumn, visible map[string]bool, tags []registry.TagMeta, pulls []int,
+	badges map[string][]string, metaColumns []tagColumn, metaCells [][]metadataCell) csvTable {
+	t := csvTable{Header: []string{"Tag"}}
+	var names []string
+	for _, col := range available {
+		if visible[col.Name] && !strings.HasPrefix(col.Name, metadataColumnPrefix) {
+			names = append(names, col.Name)
+			t.Header = append(t.Header, col.Title)
+		}
+	}
+	for _, col := range metaColumns {
+		t.Header = append(t.Header, col.Title)
+	}
+	for i, tag := range tags {
+		row := []string{tag.Tag}
+		for _, name := range names {
+			value := ""
+			switch name {
+			case "digest":
+				value = tag.Digest
+			case "created":
+				if tag.AgeDays() >= 0 {
+					value = tag.Created.Format(time.RFC3339)
+				}
+			case "age":
+				if tag.AgeDays() >= 0 {
+					value = strconv.Itoa(tag.AgeDays())
+				}
+			case "size":
+				value = strconv.FormatInt(tag.Size, 10)
+			case "platforms":
+				value = strings.Join(tag.PlatformLabels, ", ")
+			case "pulls":
+				value = strconv.Itoa(pulls[i])
+			case "vulnerabilities":
+				value = "not scanned"
+				if badges[name][i] != "" {
+					value = badgeText(badges[name][i])
+				}
+			default:
+				value = badgeText(badges[name][i])
+			}
+			row = append(row, value)
+		}
+		for _, cell := range metaCells[i] {
+			row = append(row, cell.Text)
+		}
+		t.Rows = append(t.Rows, row)
+	}
+	return t
+}
+
+// eventsCSV events of the event log.
+func eventsCSV(list []events.EventRow) csvTable {
+	t := csvTable{Header: []string{"Action", "Repository", "Tag", "IP Address", "User", "Time"}}
+	for _, e := range list {
+		t.Rows = append(t.Rows, []string{e.Action, e.Repository, e.Tag, e.IP, e.User, e.Created})
+	}
+	return t
+}
+
+// storageCSV usage by repository of the storage report, sizes are in bytes.
+func storageCSV(report storage.Report) csvTable {
+	t := csvTable{Header: []string{"Repository", "Size", "Layers", "Manifests"}}
+	for _, r := range report.Repos {
+		t.Rows = append(t.Rows, []string{r.Name, strconv.FormatInt(r.Size, 10), strconv.Itoa(r.Layers), strconv.Itoa(r.Manifests)})
+	}
+	return t
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// startedAt when the process started, for the uptime.
+var startedAt = time.Now()
+
+// runtimeStats memory and goroutines of the process with the sizes of the in-memory caches,
+// to tell which of them grows when the memory usage does.
+type runtimeStats struct {
+	GoVersion   string
+	Uptime      time.Duration
+	CPUs        int
+	MaxProcs    int
+	Goroutines  int
+	HeapAlloc   int64
+	HeapInuse   int64
+	HeapObjects int64
+	HeapSys     int64
+	StackInuse  int64
+	Sys         int64
+	NextGC      int64
+	NumGC       int
+	LastGC      time.Time
+	PauseTotal  time.Duration
+
+	CatalogRepos int
+	TagCounts    int
+	Jobs         int
+	Tasks        int
+}
+
+// readRuntimeStats collect the stats, reading memory stats stops the world for a moment.
+func (a *apiClient) readRuntimeStats() runtimeStats {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	_, repos := a.client.CatalogStatus()
+	s := runtimeStats{
+		GoVersion:    runtime.Version(),
+		Uptime:       time.Since(startedAt).Round(time.Second),
+		CPUs:         runtime.NumCPU(),
+		MaxProcs:     runtime.GOMAXPROCS(0),
+		Goroutines:   runtime.NumGoroutine(),
+		HeapAlloc:    int64(m.HeapAlloc),
+		HeapInuse:    int64(m.HeapInuse),
+		HeapObjects:  int64(m.HeapObjects),
+		HeapSys:      int64(m.HeapSys),
+		StackInuse:   int64(m.StackInuse),
+		Sys:          int64(m.Sys),
+		NextGC:       int64(m.NextGC),
+		NumGC:        int(m.NumGC),
+		PauseTotal:   time.Duration(m.PauseTotalNs),
+		CatalogRepos: repos,
+		TagCounts:    len(a.client.TagCounts()),
+		Jobs:         len(a.jobs.list()),
+		Tasks:        len(a.tasks.list()),
+	}
+	if m.LastGC > 0 {
+		s.LastGC = time.Unix(0, int64(m.LastGC))
+	}
+	return s
+}
+
+// viewRuntime view runtime stats of the process and cache sizes.
+func (a *apiClient) viewRuntime(c echo.Context) error {
+	data := a.setUserPermissions(c)
+	data.Set("runtime", a.readRuntimeStats())
+	data.Set("tagCache", a.client.MetadataCacheStats())
+	data.Set("configCache", a.client.ConfigCacheStats())
+	data.Set("pageCache", a.pages.stats())
+	return c.Render(http.StatusOK, "runtime.html", data)
+}
+
+// pprofHandler profiles of net/http/pprof served under the base path by the admin-only routes,
+// the default mux the package registers them on is not served.
+func (a *apiClient) pprofHandler() echo.HandlerFunc {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	return echo.WrapHandler(http.StripPrefix(a.config.BasePath, mux))
+}
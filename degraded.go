@@ -0,0 +1,95 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// Delays between the attempts to connect to the registry, doubling after every failed one.
+const (
+	connectRetryMin = time.Second
+	connectRetryMax = time.Minute
+)
+
+// registryOutage why the registry was unreachable since startup, zero once connected.
+// The UI runs in degraded mode meanwhile showing the banner and the data loaded so far.
+type registryOutage struct {
+	Error     string
+	Since     time.Time
+	NextRetry time.Time
+}
+
+// registryState connection state of the registry shared with the pages.
+type registryState struct {
+	mux    sync.RWMutex
+	outage registryOutage
+}
+
+// get copy of the outage, zero when the registry is connected.
+func (s *registryState) get() registryOutage {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+	return s.outage
+}
+
+// set record the failed attempt and when the next one is, nil error clears the outage.
+func (s *registryState) set(err error, next time.Time) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if err == nil {
+		s.outage = registryOutage{}
+		return
+	}
+	if s.outage.Since.IsZero() {
+		s.outage.Since = time.Now()
+	}
+	s.outage.Error, s.outage.NextRetry = err.Error(), next
+}
+
+// nextConnectDelay double the delay up to the longest one.
+func nextConnectDelay(delay time.Duration) time.Duration {
+	if delay *= 2; delay > connectRetryMax {
+		return connectRetryMax
+	}
+	return delay
+}
+
+// connectRegistry connect to the registry retrying with exponential backoff up to the count of retries,
+// so a registry restarting along with the UI does not fail the startup.
+func (a *apiClient) connectRegistry(retries int) error {
+	delay := connectRetryMin
+	for i := 0; ; i++ {
+		err := a.client.Connect()
+		if err == nil || i >= retries {
+			return err
+		}
+		a.logger.Warnf("Cannot connect to registry %s: %s, retrying in %s", a.config.RegistryURL, err, delay)
+		time.Sleep(delay)
+		delay = nextConnectDelay(delay)
+	}
+}
+
+// superviseRegistry start in degraded mode keeping to connect to the unreachable registry in background
+// at the longest delay. Once connected the flavor is detected again and the catalog is refreshed.
+func (a *apiClient) superviseRegistry(err error) {
+	a.logger.Errorf("Cannot connect to registry %s: %s, running in degraded mode until it is reachable", a.config.RegistryURL, err)
+	a.registry.set(err, time.Now().Add(connectRetryMax))
+	go func() {
+		for {
+			time.Sleep(connectRetryMax)
+			if err := a.client.Connect(); err != nil {
+				a.registry.set(err, time.Now().Add(connectRetryMax))
+				a.logger.Warnf("Cannot connect to registry %s: %s, retrying in %s", a.config.RegistryURL, err, connectRetryMax)
+				continue
+			}
+			a.logger.Infof("Connected to registry %s, leaving degraded mode", a.config.RegistryURL)
+			a.registry.set(nil, time.Time{})
+			a.client.SetFlavor(a.config.RegistryFlavor, a.client.APIToken())
+			if t, ok := a.tasks.find(refreshTagsTask); ok {
+				t.runNow()
+			}
+			a.pages.invalidate()
+			return
+		}
+	}()
+}
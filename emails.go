@@ -0,0 +1,299 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/quiq/docker-registry-ui/events"
+	"github.com/quiq/docker-registry-ui/scanner"
+)
+
+const (
+	// emailTimeout how long to wait for the SMTP server to accept the message.
+	emailTimeout = 30 * time.Second
+	// emailVulnerability event of the finished scan finding vulnerabilities of the rule severity or higher.
+	emailVulnerability = "vulnerability"
+	// emailVulnerabilitiesListed vulnerabilities listed in the message, the rest are on the image page.
+	emailVulnerabilitiesListed = 20
+)
+
+// emailEvents events the email rules can notify of.
+var emailEvents = append(append([]string{}, webhookActions...), emailVulnerability)
+
+// defaultEmailSubject subject template of the rules without one.
+const defaultEmailSubject = `[Registry UI] {{.Summary}}`
+
+// defaultEmailBody body template of the rules without one.
+const defaultEmailBody = `{{.Summary}}
+
+Repository: {{.Repository}}
+{{- if .Tags}}
+Tags: {{range $i, $tag := .Tags}}{{if $i}}, {{end}}{{$tag}}{{end}}
+{{- else if .Tag}}
+Tag: {{.Tag}}{{end}}
+{{- if .Digest}}
+Digest: {{.Digest}}{{end}}
+{{- if .User}}
+User: {{.User}}{{end}}
+{{- if .IP}}
+IP: {{.IP}}{{end}}
+Time: {{.Timestamp.Format "2006-01-02 15:04:05 MST"}}
+{{- if .Vulnerabilities}}
+{{range .Vulnerabilities}}
+{{.Severity}} {{.ID}} in {{.Package}} {{.Version}}{{if .FixedIn}}, fixed in {{.FixedIn}}{{end}}
+{{- end}}
+{{- if gt .Count (len .Vulnerabilities)}}
+...and {{.Count}} in total.
+{{- end}}{{end}}
+
+Open in Registry UI: {{.URL}}
+`
+
+// emailRule recipients notified of the event on the matching repos, e.g. of deletions in a namespace
+// of production images or of critical vulnerabilities found by scans.
+type emailRule struct {
+	// Event push, pull, delete or vulnerability.
+	Event string `yaml:"event"`
+	// Repos repo path, a trailing * matches by prefix.
+	Repos string `yaml:"repos"`
+	// Severity lowest severity of vulnerability events, Critical by default.
+	Severity   string   `yaml:"severity"`
+	Recipients []string `yaml:"recipients"`
+	// Subject and Body text/template of the message, the defaults are used when empty.
+	Subject string `yaml:"subject"`
+	Body    string `yaml:"body"`
+}
+
+// emailData values of the subject and body templates.
+type emailData struct {
+	webhookPayload
+	// Summary who did what, e.g. "ci pushed registry.local/team/app:v1".
+	Summary string
+	// Vulnerabilities found at the rule severity or higher, the first ones only, Count is of all of them.
+	Vulnerabilities []scanner.Vulnerability
+	Count           int
+}
+
+// severity lowest severity the rule notifies of.
+func (r emailRule) severity() string {
+	if r.Severity == "" {
+		return scanner.Severities[0]
+	}
+	return r.Severity
+}
+
+// Matches whether the rule wants the event on the repo.
+func (r emailRule) Matches(event, repo string) bool {
+	return r.Event == event && (events.RepoOwner{Repos: r.Repos}).Matches(repo)
+}
+
+// templates parse the subject and body templates, the defaults are used for the empty ones.
+func (r emailRule) templates() (*texttemplate.Template, *texttemplate.Template, error) {
+	subject, body := r.Subject, r.Body
+	if subject == "" {
+		subject = defaultEmailSubject
+	}
+	if body == "" {
+		body = defaultEmailBody
+	}
+	s, err := texttemplate.New("subject").Option("missingkey=error").Parse(subject)
+	if err != nil {
+		return nil, nil, fmt.Errorf("subject: %s", err)
+	}
+	b, err := texttemplate.New("body").Option("missingkey=error").Parse(body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("body: %s", err)
+	}
+	return s, b, nil
+}
+
+// validate check the event, recipients and templates, rendering them with sample values.
+func (r emailRule) validate() error {
+	if !validEmailEvent(r.Event) {
+		return fmt.Errorf("event should be one of %s, got %q", strings.Join(emailEvents, ", "), r.Event)
+	}
+	if !validRepoPattern(r.Repos) {
+		return fmt.Errorf("repos should be a repo path, a trailing * matches by prefix, got %q", r.Repos)
+	}
+	if scanner.SeverityRank(r.severity()) == len(scanner.Severities) {
+		return fmt.Errorf("severity should be one of %s", strings.Join(scanner.Severities, ", "))
+	}
+	if len(r.Recipients) == 0 {
+		return fmt.Errorf("recipients should be set")
+	}
+	for _, to := range r.Recipients {
+		if _, err := mail.ParseAddress(to); err != nil {
+			return fmt.Errorf("invalid recipient %q: %s", to, err)
+		}
+	}
+	sample := emailData{
+		webhookPayload:  webhookPayload{Action: r.Event, Repository: "team/app", Tag: "v1", Timestamp: time.Now()},
+		Vulnerabilities: []scanner.Vulnerability{{ID: "CVE-2021-3449", Severity: "Critical"}},
+		Count:           1,
+	}
+	if _, _, err := renderEmail(r, sample); err != nil {
+		return err
+	}
+	return nil
+}
+
+// validEmailEvent whether the rules can notify of the event.
+func validEmailEvent(event string) bool {
+	for _, e := range emailEvents {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// renderEmail subject and body of the message by the templates of the rule.
+func renderEmail(r emailRule, data emailData) (string, string, error) {
+	s, b, err := r.templates()
+	if err != nil {
+		return "", "", err
+	}
+	var subject, body bytes.Buffer
+	if err := s.Execute(&subject, data); err != nil {
+		return "", "", fmt.Errorf("subject: %s", err)
+	}
+	if err := b.Execute(&body, data); err != nil {
+		return "", "", fmt.Errorf("body: %s", err)
+	}
+	// Line breaks in the subject would start new headers.
+	return strings.Join(strings.Fields(subject.String()), " "), body.String(), nil
+}
+
+// composeEmail plain text message with the headers, the subject is encoded for non-ASCII characters.
+func composeEmail(from string, to []string, subject, body string, date time.Time) []byte {
+	b := make([]byte, 8)
+	rand.Read(b)
+	domain := "registry-ui"
+	if at := strings.LastIndex(from, "@"); at >= 0 {
+		domain = strings.Trim(from[at+1:], ">")
+	}
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", from)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	fmt.Fprintf(&msg, "Message-ID: <%s@%s>\r\n", hex.EncodeToString(b), domain)
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	msg.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	msg.WriteString("Auto-Submitted: auto-generated\r\n\r\n")
+	msg.WriteString(strings.Replace(strings.Replace(body, "\r\n", "\n", -1), "\n", "\r\n", -1))
+	return msg.Bytes()
+}
+
+// sendEmail send the message by the SMTP server of the config, over TLS from the start on smtp_tls
+// or upgraded by STARTTLS when the server offers it.
+func (a *apiClient) sendEmail(to []string, subject, body string) error {
+	c := &a.config
+	addr := net.JoinHostPort(c.SMTPHost, strconv.Itoa(c.SMTPPort))
+	tlsConfig := &tls.Config{ServerName: c.SMTPHost}
+	var conn net.Conn
+	var err error
+	if c.SMTPTLS {
+		conn, err = tls.DialWithDialer(&net.Dialer{Timeout: emailTimeout}, "tcp", addr, tlsConfig)
+	} else {
+		conn, err = net.DialTimeout("tcp", addr, emailTimeout)
+	}
+	if err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Now().Add(emailTimeout))
+	client, err := smtp.NewClient(conn, c.SMTPHost)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok && !c.SMTPTLS {
+		if err := client.StartTLS(tlsConfig); err != nil {
+			return err
+		}
+	}
+	if c.SMTPUsername != "" {
+		if err := client.Auth(smtp.PlainAuth("", c.SMTPUsername, c.SMTPPassword, c.SMTPHost)); err != nil {
+			return err
+		}
+	}
+	from, _ := mail.ParseAddress(c.SMTPFrom)
+	if err := client.Mail(from.Address); err != nil {
+		return err
+	}
+	for _, addr := range to {
+		rcpt, _ := mail.ParseAddress(addr)
+		if err := client.Rcpt(rcpt.Address); err != nil {
+			return fmt.Errorf("recipient %s: %s", addr, err)
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(composeEmail(c.SMTPFrom, to, subject, body, time.Now())); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// emailByRule render the message of the rule and send it to its recipients, the errors are logged.
+func (a *apiClient) emailByRule(i int, r emailRule, data emailData) {
+	subject, body, err := renderEmail(r, data)
+	if err == nil {
+		err = a.sendEmail(r.Recipients, subject, body)
+	}
+	if err != nil {
+		a.logger.Errorf("Cannot email %s of %s to %s by email rule %d: %s",
+			r.Event, data.Repository, strings.Join(r.Recipients, ", "), i+1, err)
+	}
+}
+
+// notifyEmail email the recipients of the rules matching the event in background.
+func (a *apiClient) notifyEmail(p webhookPayload) {
+	for i, r := range a.config.EmailRules {
+		if r.Matches(p.Action, p.Repository) {
+			go a.emailByRule(i, r, emailData{webhookPayload: p, Summary: p.summary()})
+		}
+	}
+}
+
+// notifyVulnerabilities email the recipients of the vulnerability rules matching the scanned image
+// when it has vulnerabilities of the rule severity or higher, the accepted ones are not counted.
+func (a *apiClient) notifyVulnerabilities(p webhookPayload, report scanner.Report) {
+	p.Action = emailVulnerability
+	for i, r := range a.config.EmailRules {
+		if !r.Matches(emailVulnerability, p.Repository) {
+			continue
+		}
+		data := emailData{webhookPayload: p, Count: report.CountAtLeast(r.severity())}
+		if data.Count == 0 {
+			continue
+		}
+		for _, v := range report.Vulnerabilities {
+			if len(data.Vulnerabilities) < emailVulnerabilitiesListed &&
+				!v.Accepted && scanner.SeverityRank(v.Severity) <= scanner.SeverityRank(r.severity()) {
+				data.Vulnerabilities = append(data.Vulnerabilities, v)
+			}
+		}
+		data.Summary = fmt.Sprintf("%d %s or more severe vulnerabilities found in %s", data.Count, r.severity(), p.Image)
+		go a.emailByRule(i, r, data)
+	}
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/quiq/docker-registry-ui/registry"
+	"github.com/sirupsen/logrus"
+)
+
+// errorReportTimeout how long to wait for Sentry or the webhook to accept the report.
+const errorReportTimeout = 10 * time.Second
+
+// errorReportsPerMinute cap of the reports sent, e.g. when registry is down and every page fails.
+const errorReportsPerMinute = 30
+
+// errorEvent error logged by UI, registry client, jobs or background tasks as it is reported.
+type errorEvent struct {
+	Time      time.Time `json:"time"`
+	Level     string    `json:"level"`
+	Logger    string    `json:"logger"`
+	Message   string    `json:"message"`
+	RequestID string    `json:"request_id,omitempty"`
+	User      string    `json:"user,omitempty"`
+	Stack     string    `json:"stack,omitempty"`
+	// Repeated how many times the same error was logged without reporting since the last report.
+	Repeated int `json:"repeated"`
+}
+
+// reportedError when the error was last reported and how many times it was suppressed since.
+type reportedError struct {
+	at         time.Time
+	suppressed int
+}
+
+// errorReporter logrus hook reporting the logged errors to Sentry or the webhook.
+// The same error is reported once per error_report_interval, the repeats are counted.
+type errorReporter struct {
+	mux      sync.Mutex
+	sentry   *sentryReporter
+	webhook  string
+	interval time.Duration
+	reported map[string]*reportedError
+	minute   time.Time
+	sent     int
+	logger   *logrus.Entry
+}
+
+// newErrorReporter reporter configured by sentry_dsn and error_webhook_url, nil if neither is set.
+func newErrorReporter(config configData) *errorReporter {
+	if config.SentryDSN == "" && config.ErrorWebhookURL == "" {
+		return nil
+	}
+	r := &errorReporter{
+		webhook:  config.ErrorWebhookURL,
+		interval: time.Duration(config.ErrorReportInterval) * time.Second,
+		reported: map[string]*reportedError{},
+		logger:   registry.SetupLogging("main.errors"),
+	}
+	if config.SentryDSN != "" {
+		r.sentry, _ = newSentryReporter(config.SentryDSN)
+	}
+	return r
+}
+
+// Levels the hook fires for.
+func (r *errorReporter) Levels() []logrus.Level {
+	return []logrus.Level{logrus.PanicLevel, logrus.FatalLevel, logrus.ErrorLevel}
+}
+
+// Fire report the logged error unless reported recently.
+func (r *errorReporter) Fire(entry *logrus.Entry) error {
+	field := func(name string) string {
+		if v, ok := entry.Data[name]; ok {
+			return fmt.Sprint(v)
+		}
+		return ""
+	}
+	e := errorEvent{
+		Time:      entry.Time,
+		Level:     entry.Level.String(),
+		Logger:    field("logger"),
+		Message:   entry.Message,
+		RequestID: field("request_id"),
+		User:      field("user"),
+		Stack:     field("stack"),
+	}
+	repeated, ok := r.allow(e.Logger+"|"+e.Message, e.Time)
+	if !ok {
+		return nil
+	}
+	e.Repeated = repeated
+	go r.send(e)
+	return nil
+}
+
+// allow whether to report the error now and how many times it was suppressed before.
+func (r *errorReporter) allow(key string, now time.Time) (int, bool) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	if p, ok := r.reported[key]; ok && now.Sub(p.at) < r.interval {
+		p.suppressed++
+		return 0, false
+	}
+	if now.Sub(r.minute) >= time.Minute {
+		r.minute, r.sent = now, 0
+	}
+	if r.sent >= errorReportsPerMinute {
+		return 0, false
+	}
+	r.sent++
+	repeated := 0
+	if p, ok := r.reported[key]; ok {
+		repeated = p.suppressed
+	}
+	r.reported[key] = &reportedError{at: now}
+	// Forget the errors not repeated for a while so the map does not grow forever.
+	for k, p := range r.reported {
+		if age := now.Sub(p.at); age >= 2*r.interval || (age >= r.interval && p.suppressed == 0) {
+			delete(r.reported, k)
+		}
+	}
+	return repeated, true
+}
+
+// send the event to Sentry and the webhook, the failures are logged as warnings not to be reported again.
+func (r *errorReporter) send(e errorEvent) {
+	if r.sentry != nil {
+		if err := r.sentry.send(e); err != nil {
+			r.logger.Warnf("Cannot report error to Sentry: %s", err)
+		}
+	}
+	if r.webhook != "" {
+		body, _ := json.Marshal(e)
+		if err := postReport(r.webhook, nil, body); err != nil {
+			r.logger.Warnf("Cannot report error to webhook: %s", err)
+		}
+	}
+}
+
+// postReport post the JSON report.
+func postReport(url string, headers map[string]string, body []byte) error {
+	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := (&http.Client{Timeout: errorReportTimeout}).Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		msg, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("%s %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// sentryReporter sends the errors to Sentry store API, see https://develop.sentry.dev/sdk/store/
+type sentryReporter struct {
+	storeURL string
+	key      string
+}
+
+// newSentryReporter reporter for the DSN of the project, e.g. https://<key>@sentry.local/<project id>
+func newSentryReporter(dsn string) (*sentryReporter, error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return nil, err
+	}
+	project := path.Base(u.Path)
+	if u.Scheme == "" || u.Host == "" || u.User == nil || u.User.Username() == "" || project == "." || project == "/" {
+		return nil, fmt.Errorf("should be like https://<key>@sentry.local/<project id>")
+	}
+	prefix := strings.TrimSuffix(path.Dir(u.Path), "/")
+	return &sentryReporter{
+		storeURL: fmt.Sprintf("%s://%s%s/api/%s/store/", u.Scheme, u.Host, prefix, project),
+		key:      u.User.Username(),
+	}, nil
+}
+
+// sentryEventID random 32 hex digits ID of the event.
+func sentryEventID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// send the event, panics are reported as fatal.
+func (s *sentryReporter) send(e errorEvent) error {
+	level := e.Level
+	if e.Stack != "" {
+		level = "fatal"
+	}
+	event := map[string]interface{}{
+		"event_id":  sentryEventID(),
+		"timestamp": e.Time.UTC().Format("2006-01-02T15:04:05"),
+		"level":     level,
+		"platform":  "go",
+		"logger":    e.Logger,
+		"message":   e.Message,
+		"extra":     map[string]interface{}{"repeated": e.Repeated, "stack": e.Stack},
+	}
+	if e.RequestID != "" {
+		event["tags"] = map[string]string{"request_id": e.RequestID}
+	}
+	if e.User != "" {
+		event["user"] = map[string]string{"username": e.User}
+	}
+	body, _ := json.Marshal(event)
+	auth := fmt.Sprintf("Sentry sentry_version=7, sentry_client=docker-registry-ui, sentry_key=%s", s.key)
+	return postReport(s.storeURL, map[string]string{"X-Sentry-Auth": auth}, body)
+}
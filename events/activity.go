@@ -0,0 +1,70 @@
+package events
+
+import (
+	"strings"
+	"time"
+)
+
+// GetPushActivity counts of the pushes by day since the time, of the repos matching the scope, see scopeCondition.
+// Days are in UTC formatted as 2006-01-02.
+func (e *EventListener) GetPushActivity(scope []string, since time.Time) map[string]int {
+	days := map[string]int{}
+	db, err := e.getDatabaseHandler()
+	if err != nil {
+		e.logger.Error(err)
+		return days
+	}
+	defer db.Close()
+
+	condition, args := scopeCondition(scope)
+	args = append([]interface{}{since.UTC().Format("2006-01-02 15:04:05")}, args...)
+	rows, err := db.Query("SELECT DATE(created) AS day, COUNT(*) FROM events WHERE action='push' AND created >= ? AND "+condition+
+		" GROUP BY day", args...)
+	if err != nil {
+		e.logger.Error("Error selecting from table: ", err)
+		return days
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var day string
+		var count int
+		rows.Scan(&day, &count)
+		// MySQL may return the date with the time.
+		if len(day) > 10 {
+			day = day[:10]
+		}
+		days[day] = count
+	}
+	return days
+}
+
+// GetLastPushes time of the latest push of every repo by the push events and the new tags recorded, the new tags are
+// kept longer than the events.
+func (e *EventListener) GetLastPushes() map[string]time.Time {
+	pushes := map[string]time.Time{}
+	db, err := e.getDatabaseHandler()
+	if err != nil {
+		e.logger.Error(err)
+		return pushes
+	}
+	defer db.Close()
+
+	rows, err := db.Query("SELECT repository, MAX(created) FROM (SELECT repository, created FROM events WHERE action='push' " +
+		"UNION ALL SELECT repository, created FROM new_tags) AS pushes GROUP BY repository")
+	if err != nil {
+		e.logger.Error("Error selecting from table: ", err)
+		return pushes
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var repository, created string
+		rows.Scan(&repository, &created)
+		if len(created) < 19 {
+			continue
+		}
+		if t, err := time.Parse("2006-01-02 15:04:05", strings.Replace(created[:19], "T", " ", 1)); err == nil {
+			pushes[repository] = t
+		}
+	}
+	return pushes
+}
@@ -0,0 +1,41 @@
+package events
+
+import (
+	"testing"
+	"time"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestPushActivity(t *testing.T) {
+	e := newTestListener(t)
+
+	db, _ := e.getDatabaseHandler()
+	defer db.Close()
+	for _, r := range [][]string{{"push", "team/app", "2020-03-01 10:00:00"}, {"push", "team/app", "2020-03-01 23:59:00"},
+		{"pull", "team/app", "2020-03-01 11:00:00"}, {"push", "team/worker", "2020-03-02 08:00:00"},
+		{"push", "alpine", "2020-03-03 08:00:00"}, {"push", "team/app", "2020-02-01 08:00:00"}} {
+		db.Exec("INSERT INTO events(action, repository, tag, ip, user, created) VALUES(?, ?, 'latest', '', '', ?)", r[0], r[1], r[2])
+	}
+	since := time.Date(2020, 2, 20, 0, 0, 0, 0, time.UTC)
+
+	convey.Convey("Count the pushes by day since the time", t, func() {
+		convey.So(e.GetPushActivity(nil, since), convey.ShouldResemble, map[string]int{"2020-03-01": 2, "2020-03-02": 1, "2020-03-03": 1})
+	})
+
+	convey.Convey("Count the pushes of the scope only", t, func() {
+		convey.So(e.GetPushActivity([]string{"team/app"}, since), convey.ShouldResemble, map[string]int{"2020-03-01": 2})
+		convey.So(e.GetPushActivity([]string{"team/*"}, since), convey.ShouldResemble, map[string]int{"2020-03-01": 2, "2020-03-02": 1})
+		convey.So(e.GetPushActivity([]string{}, since), convey.ShouldBeEmpty)
+	})
+	convey.Convey("Get the latest push of every repo from the events and the new tags", t, func() {
+		db.Exec("INSERT INTO new_tags(repository, tag, digest, source, created) VALUES('team/worker', 'v1', '', 'push', '2020-03-05 09:00:00')")
+		db.Exec("INSERT INTO new_tags(repository, tag, digest, source, created) VALUES('busybox', 'v1', '', 'push', '2019-01-01 00:00:00')")
+		convey.So(e.GetLastPushes(), convey.ShouldResemble, map[string]time.Time{
+			"team/app":    time.Date(2020, 3, 1, 23, 59, 0, 0, time.UTC),
+			"team/worker": time.Date(2020, 3, 5, 9, 0, 0, 0, time.UTC),
+			"alpine":      time.Date(2020, 3, 3, 8, 0, 0, 0, time.UTC),
+			"busybox":     time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC),
+		})
+	})
+}
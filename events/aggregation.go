@@ -0,0 +1,124 @@
+package events
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tagColumnSize size of the tag column of the events table, the tags of aggregated pushes are cut to fit it.
+const tagColumnSize = 100
+
+// eventWindows registry events seen recently to drop the duplicates and the tag pushes held to be aggregated.
+type eventWindows struct {
+	mux       sync.Mutex
+	dedup     time.Duration
+	aggregate time.Duration
+	seen      map[string]time.Time
+	pending   map[string]*Event
+}
+
+// SetEventWindows drop the registry events repeating within the dedup window, the same action on the same
+// repo, tag and digest by the same user and IP, e.g. the notifications resent by the registry. Tag pushes of
+// the same digest by the same user within the aggregate window, e.g. of one CI run, are stored and notified as
+// one event with all the tags after the window. Zero disables either, they are in memory of this instance only.
+func (e *EventListener) SetEventWindows(dedup, aggregate time.Duration) {
+	e.windows.mux.Lock()
+	defer e.windows.mux.Unlock()
+	e.windows.dedup, e.windows.aggregate = dedup, aggregate
+	e.windows.seen = map[string]time.Time{}
+	e.windows.pending = map[string]*Event{}
+}
+
+// duplicate whether the same event was seen within the dedup window, the event is remembered otherwise.
+func (e *EventListener) duplicate(ev Event) bool {
+	w := &e.windows
+	w.mux.Lock()
+	defer w.mux.Unlock()
+	if w.dedup <= 0 {
+		return false
+	}
+	now := time.Now()
+	for key, t := range w.seen {
+		if now.Sub(t) >= w.dedup {
+			delete(w.seen, key)
+		}
+	}
+	key := strings.Join([]string{ev.Action, ev.Repository, ev.Tag, ev.Digest, ev.User, ev.IP}, "\x00")
+	if _, ok := w.seen[key]; ok {
+		return true
+	}
+	w.seen[key] = now
+	return false
+}
+
+// hold add the tag push to the pending one of the same digest and user, the first push starts the window,
+// after it the aggregated event is stored and passed to notify. Returns false if the push is not held.
+func (e *EventListener) hold(ev Event, notify func([]Event)) bool {
+	w := &e.windows
+	w.mux.Lock()
+	defer w.mux.Unlock()
+	// Quay sends the tags of one push together already.
+	if w.aggregate <= 0 || ev.Action != "push" || ev.Tag == "" || len(ev.Tags) > 0 {
+		return false
+	}
+	key := strings.Join([]string{ev.Repository, ev.Digest, ev.User}, "\x00")
+	if p, ok := w.pending[key]; ok {
+		for _, tag := range p.Tags {
+			if tag == ev.Tag {
+				return true
+			}
+		}
+		p.Tags = append(p.Tags, ev.Tag)
+		return true
+	}
+	ev.Tags = []string{ev.Tag}
+	w.pending[key] = &ev
+	time.AfterFunc(w.aggregate, func() {
+		w.mux.Lock()
+		p := w.pending[key]
+		delete(w.pending, key)
+		w.mux.Unlock()
+		e.flush(*p, notify)
+	})
+	return true
+}
+
+// flush store the aggregated push and notify of it.
+func (e *EventListener) flush(ev Event, notify func([]Event)) {
+	db, err := e.getDatabaseHandler()
+	if err != nil {
+		e.logger.Error(err)
+		return
+	}
+	defer db.Close()
+	if err := e.storeEvent(db, ev); err != nil {
+		e.logger.Error(err)
+		return
+	}
+	e.logger.Debugf("Aggregated push of %d tags of %s@%s", len(ev.Tags), ev.Repository, ev.Digest)
+	if notify != nil {
+		notify([]Event{ev})
+	}
+}
+
+// joinTags tags as stored in the tag column, e.g. "v1.2.0, v1.2, latest", those not fitting are counted.
+func joinTags(tags []string) string {
+	joined := ""
+	for i, tag := range tags {
+		item := tag
+		if i > 0 {
+			item = ", " + tag
+		}
+		more := ""
+		if i < len(tags)-1 {
+			more = fmt.Sprintf(" +%d", len(tags)-i-1)
+		}
+		if i > 0 && len(joined)+len(item)+len(more) > tagColumnSize {
+			return joined + fmt.Sprintf(" +%d", len(tags)-i)
+		}
+		joined += item
+	}
+	return joined
+}
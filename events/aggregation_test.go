@@ -0,0 +1,59 @@
+package events
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestEventWindows(t *testing.T) {
+	e := newTestListener(t)
+	e.SetEventWindows(time.Minute, 200*time.Millisecond)
+
+	notified := make(chan []Event, 10)
+	send := func(body string) {
+		r, _ := http.NewRequest("POST", "/api/events", strings.NewReader(`{"events": [`+body+`]}`))
+		e.ProcessEvents(r, "", func(list []Event) { notified <- list })
+	}
+
+	convey.Convey("Drop the duplicate events and aggregate the tag pushes of one digest", t, func() {
+		pull := `{"action": "pull", "target": {"repository": "team/app", "tag": "v1", "digest": "sha256:abc"}, "request": {"addr": "10.0.0.1:5000"}, "actor": {"name": "ci"}}`
+		send(pull + "," + pull)
+		convey.So(len(<-notified), convey.ShouldEqual, 1)
+		send(pull)
+		send(`{"action": "pull", "target": {"repository": "team/app", "tag": "v1", "digest": "sha256:abc"}, "request": {"addr": "10.0.0.2:5000"}, "actor": {"name": "ci"}}`)
+		convey.So(len(<-notified), convey.ShouldEqual, 1)
+
+		for _, tag := range []string{"v1.2.0", "v1.2", "latest", "v1.2"} {
+			send(`{"action": "push", "target": {"repository": "team/app", "tag": "` + tag + `", "digest": "sha256:def"}, "request": {"addr": "10.0.0.3:5000"}, "actor": {"name": "ci"}}`)
+		}
+		send(`{"action": "push", "target": {"repository": "team/app", "digest": "sha256:layer"}, "request": {"addr": "10.0.0.3:5000"}, "actor": {"name": "ci"}}`)
+		list := <-notified
+		convey.So(list[0].Tag, convey.ShouldEqual, "")
+
+		select {
+		case list = <-notified:
+		case <-time.After(5 * time.Second):
+		}
+		convey.So(list, convey.ShouldResemble, []Event{{Action: "push", Repository: "team/app", Tag: "v1.2.0", Tags: []string{"v1.2.0", "v1.2", "latest"},
+			Digest: "sha256:def", IP: "10.0.0.3", User: "ci"}})
+		rows := e.GetEvents("team/app")
+		convey.So(rows[0].Tag, convey.ShouldEqual, "v1.2.0, v1.2, latest")
+		convey.So(len(rows), convey.ShouldEqual, 4)
+		convey.So(len(e.GetNewTags("team/app")), convey.ShouldEqual, 3)
+	})
+
+	convey.Convey("Cut the tags of the aggregated push to fit the column", t, func() {
+		convey.So(joinTags([]string{"v1"}), convey.ShouldEqual, "v1")
+		var tags []string
+		for i := 0; i < 30; i++ {
+			tags = append(tags, "release-"+strings.Repeat("x", i%3))
+		}
+		joined := joinTags(tags)
+		convey.So(len(joined), convey.ShouldBeLessThanOrEqualTo, tagColumnSize)
+		convey.So(joined, convey.ShouldEndWith, " +22")
+	})
+}
@@ -0,0 +1,139 @@
+package events
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+)
+
+const (
+	schemaAPITokens = `
+	CREATE TABLE IF NOT EXISTS api_tokens (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name VARCHAR(100) NOT NULL,
+		user VARCHAR(50) NULL,
+		prefix VARCHAR(12) NOT NULL,
+		token_hash CHAR(64) NOT NULL,
+		created DATETIME NULL,
+		last_used DATETIME NULL
+	);
+`
+	// apiTokenPrefix makes the tokens easy to recognize, e.g. by secret scanners.
+	apiTokenPrefix = "drui_"
+)
+
+// APIToken token for non-interactive access to the JSON API, only its hash is stored.
+type APIToken struct {
+	ID       int
+	Name     string
+	User     string
+	Prefix   string
+	Created  string
+	LastUsed string
+}
+
+// hashAPIToken hash of the token to store, tokens are random so plain sha256 is enough.
+func hashAPIToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// now SQL expression of the current time for the database driver.
+func (e *EventListener) now() string {
+	if e.databaseDriver == "mysql" {
+		return "NOW()"
+	}
+	return "DateTime('now')"
+}
+
+// CreateAPIToken generate new token with the name created by the user, the token is returned only once.
+func (e *EventListener) CreateAPIToken(name, user string) (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := apiTokenPrefix + hex.EncodeToString(b)
+
+	db, err := e.getDatabaseHandler()
+	if err != nil {
+		return "", err
+	}
+	defer db.Close()
+
+	_, err = db.Exec("INSERT INTO api_tokens(name, user, prefix, token_hash, created) VALUES(?,?,?,?,"+e.now()+")",
+		name, user, token[:len(apiTokenPrefix)+4], hashAPIToken(token))
+	if err != nil {
+		return "", fmt.Errorf("Error inserting a row: %s", err)
+	}
+	e.logger.Infof("API token %q created by %s", name, user)
+	return token, nil
+}
+
+// RevokeAPIToken delete the token.
+func (e *EventListener) RevokeAPIToken(id int) error {
+	db, err := e.getDatabaseHandler()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if _, err := db.Exec("DELETE FROM api_tokens WHERE id=?", id); err != nil {
+		return fmt.Errorf("Error deleting a row: %s", err)
+	}
+	return nil
+}
+
+// GetAPITokens list the tokens.
+func (e *EventListener) GetAPITokens() []APIToken {
+	var tokens []APIToken
+	db, err := e.getDatabaseHandler()
+	if err != nil {
+		e.logger.Error(err)
+		return tokens
+	}
+	defer db.Close()
+
+	rows, err := db.Query("SELECT id, name, user, prefix, created, last_used FROM api_tokens ORDER BY id")
+	if err != nil {
+		e.logger.Error("Error selecting from table: ", err)
+		return tokens
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var t APIToken
+		var user, created, lastUsed sql.NullString
+		rows.Scan(&t.ID, &t.Name, &user, &t.Prefix, &created, &lastUsed)
+		t.User, t.Created, t.LastUsed = user.String, created.String, lastUsed.String
+		tokens = append(tokens, t)
+	}
+	return tokens
+}
+
+// CheckAPIToken find the token and record its usage.
+func (e *EventListener) CheckAPIToken(token string) (APIToken, bool) {
+	var t APIToken
+	db, err := e.getDatabaseHandler()
+	if err != nil {
+		e.logger.Error(err)
+		return t, false
+	}
+	defer db.Close()
+
+	hash := hashAPIToken(token)
+	var user sql.NullString
+	err = db.QueryRow("SELECT id, name, user, prefix FROM api_tokens WHERE token_hash=?", hash).Scan(&t.ID, &t.Name, &user, &t.Prefix)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			e.logger.Error("Error selecting from table: ", err)
+		}
+		return t, false
+	}
+	t.User = user.String
+	if _, err := db.Exec("UPDATE api_tokens SET last_used="+e.now()+" WHERE id=?", t.ID); err != nil {
+		e.logger.Error("Error updating a row: ", err)
+	}
+	return t, true
+}
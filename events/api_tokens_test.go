@@ -0,0 +1,37 @@
+package events
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestAPITokens(t *testing.T) {
+	e := newTestListener(t)
+
+	convey.Convey("Create, check and revoke API token", t, func() {
+		token, err := e.CreateAPIToken("ci", "admin")
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(strings.HasPrefix(token, apiTokenPrefix), convey.ShouldBeTrue)
+
+		tokens := e.GetAPITokens()
+		convey.So(len(tokens), convey.ShouldEqual, 1)
+		convey.So(tokens[0].Name, convey.ShouldEqual, "ci")
+		convey.So(tokens[0].User, convey.ShouldEqual, "admin")
+		convey.So(strings.HasPrefix(token, tokens[0].Prefix), convey.ShouldBeTrue)
+		convey.So(tokens[0].LastUsed, convey.ShouldEqual, "")
+
+		t, ok := e.CheckAPIToken(token)
+		convey.So(ok, convey.ShouldBeTrue)
+		convey.So(t.Name, convey.ShouldEqual, "ci")
+		convey.So(e.GetAPITokens()[0].LastUsed, convey.ShouldNotEqual, "")
+		_, ok = e.CheckAPIToken(token + "x")
+		convey.So(ok, convey.ShouldBeFalse)
+
+		convey.So(e.RevokeAPIToken(t.ID), convey.ShouldBeNil)
+		_, ok = e.CheckAPIToken(token)
+		convey.So(ok, convey.ShouldBeFalse)
+		convey.So(e.GetAPITokens(), convey.ShouldBeEmpty)
+	})
+}
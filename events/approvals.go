@@ -0,0 +1,110 @@
+package events
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+const schemaDeletionRequests = `
+	CREATE TABLE IF NOT EXISTS deletion_requests (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		repository VARCHAR(255) NOT NULL,
+		tag VARCHAR(255) NOT NULL,
+		reason VARCHAR(1000) NULL,
+		user VARCHAR(50) NULL,
+		status VARCHAR(10) NOT NULL,
+		reviewer VARCHAR(50) NULL,
+		created DATETIME NULL,
+		reviewed DATETIME NULL
+	);
+`
+
+// Statuses of the deletion requests.
+const (
+	DeletionPending  = "pending"
+	DeletionApproved = "approved"
+	DeletionRejected = "rejected"
+)
+
+// DeletionRequest deletion of the tag requested by non-admin, it is done when an admin approves it.
+type DeletionRequest struct {
+	ID         int
+	Repository string
+	Tag        string
+	Reason     string
+	User       string
+	Status     string
+	Reviewer   string
+	Created    string
+	Reviewed   string
+}
+
+// RequestDeletion store the pending request, its ID is returned.
+func (e *EventListener) RequestDeletion(r DeletionRequest) (int, error) {
+	db, err := e.getDatabaseHandler()
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+
+	res, err := db.Exec("INSERT INTO deletion_requests(repository, tag, reason, user, status, created) VALUES(?,?,?,?,?,"+e.now()+")",
+		r.Repository, r.Tag, r.Reason, r.User, DeletionPending)
+	if err != nil {
+		return 0, fmt.Errorf("Error inserting a row: %s", err)
+	}
+	id, _ := res.LastInsertId()
+	return int(id), nil
+}
+
+// ReviewDeletion set the status of the pending request, it fails if the request is already reviewed,
+// e.g. by another admin at the same time.
+func (e *EventListener) ReviewDeletion(id int, status, reviewer string) error {
+	db, err := e.getDatabaseHandler()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	res, err := db.Exec("UPDATE deletion_requests SET status=?, reviewer=?, reviewed="+e.now()+" WHERE id=? AND status=?",
+		status, reviewer, id, DeletionPending)
+	if err != nil {
+		return fmt.Errorf("Error updating a row: %s", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("deletion request %d is not pending", id)
+	}
+	return nil
+}
+
+// GetDeletionRequests retrieve the requests with the status or all when it is empty, the latest first.
+func (e *EventListener) GetDeletionRequests(status string) []DeletionRequest {
+	var list []DeletionRequest
+	db, err := e.getDatabaseHandler()
+	if err != nil {
+		e.logger.Error(err)
+		return list
+	}
+	defer db.Close()
+
+	query := "SELECT id, repository, tag, reason, user, status, reviewer, created, reviewed FROM deletion_requests"
+	args := []interface{}{}
+	if status != "" {
+		query = query + " WHERE status=?"
+		args = append(args, status)
+	}
+	rows, err := db.Query(query+" ORDER BY id DESC LIMIT 1000", args...)
+	if err != nil {
+		e.logger.Error("Error selecting from table: ", err)
+		return list
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var r DeletionRequest
+		var reason, user, reviewer, created, reviewed sql.NullString
+		rows.Scan(&r.ID, &r.Repository, &r.Tag, &reason, &user, &r.Status, &reviewer, &created, &reviewed)
+		r.Reason, r.User, r.Reviewer, r.Created, r.Reviewed = reason.String, user.String, reviewer.String, created.String, reviewed.String
+		list = append(list, r)
+	}
+	return list
+}
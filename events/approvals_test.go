@@ -0,0 +1,34 @@
+package events
+
+import (
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestDeletionRequests(t *testing.T) {
+	e := newTestListener(t)
+
+	convey.Convey("Request deletion and review it once", t, func() {
+		id, err := e.RequestDeletion(DeletionRequest{Repository: "team/app", Tag: "v1", Reason: "broken build", User: "alice"})
+		convey.So(err, convey.ShouldBeNil)
+		_, err = e.RequestDeletion(DeletionRequest{Repository: "alpine", Tag: "3.13", User: "bob"})
+		convey.So(err, convey.ShouldBeNil)
+
+		list := e.GetDeletionRequests(DeletionPending)
+		convey.So(len(list), convey.ShouldEqual, 2)
+		convey.So(list[1].ID, convey.ShouldEqual, id)
+		convey.So(list[1].Reason, convey.ShouldEqual, "broken build")
+		convey.So(list[1].User, convey.ShouldEqual, "alice")
+
+		convey.So(e.ReviewDeletion(id, DeletionApproved, "admin"), convey.ShouldBeNil)
+		convey.So(e.ReviewDeletion(id, DeletionRejected, "admin2"), convey.ShouldNotBeNil)
+		convey.So(len(e.GetDeletionRequests(DeletionPending)), convey.ShouldEqual, 1)
+
+		approved := e.GetDeletionRequests(DeletionApproved)
+		convey.So(len(approved), convey.ShouldEqual, 1)
+		convey.So(approved[0].Reviewer, convey.ShouldEqual, "admin")
+		convey.So(approved[0].Reviewed, convey.ShouldNotBeEmpty)
+		convey.So(len(e.GetDeletionRequests("")), convey.ShouldEqual, 2)
+	})
+}
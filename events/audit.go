@@ -0,0 +1,77 @@
+package events
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+const schemaAuditLog = `
+	CREATE TABLE IF NOT EXISTS audit_log (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		action VARCHAR(20) NOT NULL,
+		repository VARCHAR(255) NULL,
+		tag VARCHAR(255) NULL,
+		user VARCHAR(50) NULL,
+		ip VARCHAR(45) NULL,
+		reason VARCHAR(1000) NULL,
+		view_as VARCHAR(50) NULL,
+		created DATETIME NULL
+	);
+`
+
+// AuditRecord action performed from UI, ViewAs is the user the admin in User was viewing the UI as.
+type AuditRecord struct {
+	ID         int
+	Action     string
+	Repository string
+	Tag        string
+	User       string
+	IP         string
+	Reason     string
+	ViewAs     string
+	Created    string
+}
+
+// AddAuditRecord store the action in audit log, audit records are not purged by retention.
+func (e *EventListener) AddAuditRecord(r AuditRecord) error {
+	db, err := e.getDatabaseHandler()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	_, err = db.Exec("INSERT INTO audit_log(action, repository, tag, user, ip, reason, view_as, created) VALUES(?,?,?,?,?,?,?,"+e.now()+")",
+		r.Action, r.Repository, r.Tag, r.User, r.IP, r.Reason, r.ViewAs)
+	if err != nil {
+		return fmt.Errorf("Error inserting a row: %s", err)
+	}
+	return nil
+}
+
+// GetAuditLog retrieve the latest audit records.
+func (e *EventListener) GetAuditLog() []AuditRecord {
+	var records []AuditRecord
+	db, err := e.getDatabaseHandler()
+	if err != nil {
+		e.logger.Error(err)
+		return records
+	}
+	defer db.Close()
+
+	rows, err := db.Query("SELECT id, action, repository, tag, user, ip, reason, view_as, created FROM audit_log ORDER BY id DESC LIMIT 1000")
+	if err != nil {
+		e.logger.Error("Error selecting from table: ", err)
+		return records
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var r AuditRecord
+		var repository, tag, user, ip, reason, viewAs, created sql.NullString
+		rows.Scan(&r.ID, &r.Action, &repository, &tag, &user, &ip, &reason, &viewAs, &created)
+		r.Repository, r.Tag, r.User, r.IP, r.Reason, r.Created = repository.String, tag.String, user.String, ip.String, reason.String, created.String
+		r.ViewAs = viewAs.String
+		records = append(records, r)
+	}
+	return records
+}
@@ -0,0 +1,24 @@
+package events
+
+import (
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestAuditLog(t *testing.T) {
+	e := newTestListener(t)
+
+	convey.Convey("Add and list audit records, the latest first", t, func() {
+		convey.So(e.AddAuditRecord(AuditRecord{Action: "delete", Repository: "alpine", Tag: "3.12", User: "admin", Reason: "CVE"}), convey.ShouldBeNil)
+		convey.So(e.AddAuditRecord(AuditRecord{Action: "copy", Repository: "alpine", User: "admin", ViewAs: "bob"}), convey.ShouldBeNil)
+
+		records := e.GetAuditLog()
+		convey.So(len(records), convey.ShouldEqual, 2)
+		convey.So(records[0].Action, convey.ShouldEqual, "copy")
+		convey.So(records[0].ViewAs, convey.ShouldEqual, "bob")
+		convey.So(records[1].ViewAs, convey.ShouldEqual, "")
+		convey.So(records[1].Reason, convey.ShouldEqual, "CVE")
+		convey.So(records[1].Created, convey.ShouldNotEqual, "")
+	})
+}
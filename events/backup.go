@@ -0,0 +1,249 @@
+package events
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// mergeTables tables of the history merged from a snapshot, settings are moved by the bundle instead.
+var mergeTables = []string{"events", "audit_log"}
+
+// databaseFile path of the sqlite database file without DSN options, e.g. of file:events.db?_journal=WAL.
+func (e *EventListener) databaseFile() (string, error) {
+	if e.databaseDriver != "sqlite3" {
+		return "", fmt.Errorf("database snapshots are supported for sqlite3 only, use mysqldump for %s", e.databaseDriver)
+	}
+	return strings.TrimPrefix(strings.SplitN(e.databaseLocation, "?", 2)[0], "file:"), nil
+}
+
+// Backup write a consistent snapshot of the sqlite database. VACUUM INTO reads the committed state including
+// the WAL, and the snapshot is switched to rollback journal, so it is a single self-contained file.
+func (e *EventListener) Backup(w io.Writer) error {
+	if _, err := e.databaseFile(); err != nil {
+		return err
+	}
+	db, err := e.getDatabaseHandler()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	dir, err := ioutil.TempDir("", "events-backup")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	snapshot := filepath.Join(dir, "events.db")
+	if _, err := db.Exec("VACUUM INTO ?", snapshot); err != nil {
+		return fmt.Errorf("Error making snapshot: %s", err)
+	}
+	if err := setRollbackJournal(snapshot); err != nil {
+		return err
+	}
+	f, err := os.Open(snapshot)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(w, f)
+	return err
+}
+
+// setRollbackJournal switch the database out of WAL mode merging the WAL into the file.
+func setRollbackJournal(file string) error {
+	db, err := sql.Open("sqlite3", file)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	if _, err := db.Exec("PRAGMA journal_mode=DELETE"); err != nil {
+		return fmt.Errorf("Error switching journal mode: %s", err)
+	}
+	return nil
+}
+
+// checkSnapshot verify the file is an intact sqlite database with events table.
+func checkSnapshot(file string) error {
+	db, err := sql.Open("sqlite3", file)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	var result string
+	if err := db.QueryRow("PRAGMA integrity_check").Scan(&result); err != nil {
+		return fmt.Errorf("not a sqlite database: %s", err)
+	}
+	if result != "ok" {
+		return fmt.Errorf("the snapshot is corrupted: %s", result)
+	}
+	if _, err := db.Exec("SELECT id FROM events LIMIT 1"); err != nil {
+		return fmt.Errorf("the snapshot has no events table: %s", err)
+	}
+	return nil
+}
+
+// Restore replace the database by the snapshot file, the current database is kept as <file>.bak.
+// The WAL of the current database is checkpointed and removed, so it is not applied to the restored one.
+func (e *EventListener) Restore(snapshot string) error {
+	file, err := e.databaseFile()
+	if err != nil {
+		return err
+	}
+	if err := checkSnapshot(snapshot); err != nil {
+		return err
+	}
+	if err := setRollbackJournal(snapshot); err != nil {
+		return err
+	}
+
+	e.mux.Lock()
+	defer e.mux.Unlock()
+	if db, err := sql.Open("sqlite3", e.databaseLocation); err == nil {
+		db.Exec("PRAGMA wal_checkpoint(TRUNCATE)")
+		db.Close()
+	}
+	if err := copyFile(file, file+".bak"); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("Error keeping the current database: %s", err)
+	}
+	// Copy next to the database first, so it is replaced at once by rename.
+	tmp := file + ".restore"
+	if err := copyFile(snapshot, tmp); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, file); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	for _, suffix := range []string{"-wal", "-shm", "-journal"} {
+		os.Remove(file + suffix)
+	}
+	// The snapshot may come from an older version missing some tables.
+	e.migrated = false
+	return nil
+}
+
+// Merge add events and audit records of the snapshot missing from the database,
+// e.g. when the UI moves to another node. Returns the count of added rows by table.
+func (e *EventListener) Merge(snapshot string) (map[string]int, error) {
+	counts := map[string]int{}
+	if _, err := e.databaseFile(); err != nil {
+		return counts, err
+	}
+	if err := checkSnapshot(snapshot); err != nil {
+		return counts, err
+	}
+	db, err := e.getDatabaseHandler()
+	if err != nil {
+		return counts, err
+	}
+	defer db.Close()
+
+	// Attached database is seen by the same connection only.
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return counts, err
+	}
+	defer conn.Close()
+	if _, err := conn.ExecContext(ctx, "ATTACH DATABASE ? AS snapshot", snapshot); err != nil {
+		return counts, fmt.Errorf("Error attaching snapshot: %s", err)
+	}
+	defer conn.ExecContext(ctx, "DETACH DATABASE snapshot")
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return counts, fmt.Errorf("Error starting transaction: %s", err)
+	}
+	for _, table := range mergeTables {
+		columns, err := sharedColumns(ctx, tx, table)
+		if err != nil {
+			tx.Rollback()
+			return map[string]int{}, err
+		}
+		if len(columns) == 0 {
+			continue
+		}
+		var same []string
+		for _, c := range columns {
+			same = append(same, fmt.Sprintf("m.%s IS s.%s", c, c))
+		}
+		list := strings.Join(columns, ", ")
+		query := fmt.Sprintf("INSERT INTO main.%s(%s) SELECT %s FROM snapshot.%s s WHERE NOT EXISTS (SELECT 1 FROM main.%s m WHERE %s) ORDER BY s.id",
+			table, list, list, table, table, strings.Join(same, " AND "))
+		res, err := tx.ExecContext(ctx, query)
+		if err != nil {
+			tx.Rollback()
+			return map[string]int{}, fmt.Errorf("Error merging %s: %s", table, err)
+		}
+		n, _ := res.RowsAffected()
+		counts[table] = int(n)
+	}
+	if err := tx.Commit(); err != nil {
+		return map[string]int{}, fmt.Errorf("Error committing transaction: %s", err)
+	}
+	return counts, nil
+}
+
+// sharedColumns columns of the table but id present in both the database and the snapshot,
+// none if the snapshot has no such table.
+func sharedColumns(ctx context.Context, tx *sql.Tx, table string) ([]string, error) {
+	columnsOf := func(schema string) ([]string, error) {
+		rows, err := tx.QueryContext(ctx, fmt.Sprintf("PRAGMA %s.table_info(%s)", schema, table))
+		if err != nil {
+			return nil, fmt.Errorf("Error reading columns of %s: %s", table, err)
+		}
+		defer rows.Close()
+		var columns []string
+		for rows.Next() {
+			var cid, notNull, pk int
+			var name, kind string
+			var dflt sql.NullString
+			if err := rows.Scan(&cid, &name, &kind, &notNull, &dflt, &pk); err != nil {
+				return nil, err
+			}
+			columns = append(columns, name)
+		}
+		return columns, nil
+	}
+	main, err := columnsOf("main")
+	if err != nil {
+		return nil, err
+	}
+	snapshot, err := columnsOf("snapshot")
+	if err != nil {
+		return nil, err
+	}
+	var shared []string
+	for _, c := range main {
+		for _, s := range snapshot {
+			if c == s && c != "id" {
+				shared = append(shared, c)
+			}
+		}
+	}
+	return shared, nil
+}
+
+// copyFile copy the file replacing the destination.
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
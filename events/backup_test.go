@@ -0,0 +1,65 @@
+package events
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestBackup(t *testing.T) {
+	dir := t.TempDir()
+	// The old node runs in WAL mode, the new one does not.
+	old := NewEventListener("sqlite3", "file:"+filepath.Join(dir, "old.db")+"?_journal_mode=WAL", 7, true)
+	e := newTestListener(t)
+	snapshot := filepath.Join(dir, "snapshot.db")
+
+	convey.Convey("Snapshot the database with uncheckpointed WAL", t, func() {
+		convey.So(old.AddAuditRecord(AuditRecord{Action: "delete", Repository: "alpine", Tag: "3.12", User: "admin"}), convey.ShouldBeNil)
+		convey.So(old.AddAuditRecord(AuditRecord{Action: "lock", Repository: "team/app", Tag: "v1", User: "alice"}), convey.ShouldBeNil)
+
+		f, _ := os.Create(snapshot)
+		convey.So(old.Backup(f), convey.ShouldBeNil)
+		f.Close()
+		convey.So(checkSnapshot(snapshot), convey.ShouldBeNil)
+		_, err := os.Stat(snapshot + "-wal")
+		convey.So(os.IsNotExist(err), convey.ShouldBeTrue)
+	})
+
+	convey.Convey("Merge only the records missing from the database", t, func() {
+		convey.So(e.AddAuditRecord(AuditRecord{Action: "copy", Repository: "alpine", User: "bob"}), convey.ShouldBeNil)
+		counts, err := e.Merge(snapshot)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(counts["audit_log"], convey.ShouldEqual, 2)
+		convey.So(len(e.GetAuditLog()), convey.ShouldEqual, 3)
+
+		counts, err = e.Merge(snapshot)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(counts["audit_log"], convey.ShouldEqual, 0)
+	})
+
+	convey.Convey("Restore the snapshot keeping the current database aside", t, func() {
+		convey.So(e.Restore(snapshot), convey.ShouldBeNil)
+		records := e.GetAuditLog()
+		convey.So(len(records), convey.ShouldEqual, 2)
+		convey.So(records[0].Action, convey.ShouldEqual, "lock")
+		_, err := os.Stat(e.databaseLocation + ".bak")
+		convey.So(err, convey.ShouldBeNil)
+	})
+
+	convey.Convey("Reject files which are not event databases", t, func() {
+		bogus := filepath.Join(dir, "bogus.db")
+		ioutil.WriteFile(bogus, []byte("not a database"), 0600)
+		convey.So(e.Restore(bogus), convey.ShouldNotBeNil)
+		_, err := e.Merge(bogus)
+		convey.So(err, convey.ShouldNotBeNil)
+		convey.So(len(e.GetAuditLog()), convey.ShouldEqual, 2)
+	})
+
+	convey.Convey("Snapshots are not supported for MySQL", t, func() {
+		m := NewEventListener("mysql", "user:password@tcp(localhost:3306)/events", 7, true)
+		convey.So(m.Backup(ioutil.Discard), convey.ShouldNotBeNil)
+	})
+}
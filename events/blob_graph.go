@@ -0,0 +1,234 @@
+package events
+
+import (
+	"fmt"
+	"sort"
+)
+
+const schemaBlobRefs = `
+	CREATE TABLE IF NOT EXISTS blob_refs (
+		repository VARCHAR(255) NOT NULL,
+		tag VARCHAR(255) NOT NULL,
+		parent VARCHAR(100) NOT NULL,
+		digest VARCHAR(100) NOT NULL,
+		kind VARCHAR(10) NOT NULL,
+		size BIGINT NOT NULL,
+		PRIMARY KEY (repository, tag, parent, digest)
+	);
+`
+
+// Kinds of the blobs in the reference graph.
+const (
+	BlobIndex    = "index"
+	BlobManifest = "manifest"
+	BlobConfig   = "config"
+	BlobLayer    = "layer"
+)
+
+// BlobRef edge of the reference graph: the blob referenced by the parent manifest within the tag.
+// The parent is empty for the manifest the tag points to.
+type BlobRef struct {
+	Repository string `json:"repository,omitempty"`
+	Tag        string `json:"tag,omitempty"`
+	Parent     string `json:"parent,omitempty"`
+	Digest     string `json:"digest"`
+	Kind       string `json:"kind,omitempty"`
+	Size       int64  `json:"size,omitempty"`
+}
+
+// BlobImpact what breaks when the blob is deleted: the tags referencing it directly or through an index,
+// and the blobs below it referenced by no other tag.
+type BlobImpact struct {
+	Tags     []BlobRef `json:"tags"`
+	Orphaned []BlobRef `json:"orphaned"`
+}
+
+// BlobGraphStats size of the reference graph.
+type BlobGraphStats struct {
+	Repos  int `json:"repos"`
+	Images int `json:"images"`
+	Blobs  int `json:"blobs"`
+}
+
+// SetBlobRefs replace the reference graph of all tags of the repo.
+func (e *EventListener) SetBlobRefs(repository string, refs []BlobRef) error {
+	db, err := e.getDatabaseHandler()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec("DELETE FROM blob_refs WHERE repository=?", repository); err != nil {
+		return fmt.Errorf("Error deleting rows: %s", err)
+	}
+	stmt, err := tx.Prepare("INSERT INTO blob_refs(repository, tag, parent, digest, kind, size) VALUES(?,?,?,?,?,?)")
+	if err != nil {
+		return fmt.Errorf("Error inserting a row: %s", err)
+	}
+	defer stmt.Close()
+	seen := map[BlobRef]bool{}
+	for _, r := range refs {
+		key := BlobRef{Tag: r.Tag, Parent: r.Parent, Digest: r.Digest}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		if _, err := stmt.Exec(repository, r.Tag, r.Parent, r.Digest, r.Kind, r.Size); err != nil {
+			return fmt.Errorf("Error inserting a row: %s", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// PruneBlobGraph remove the repos which are not in the graph anymore.
+func (e *EventListener) PruneBlobGraph(repositories []string) error {
+	db, err := e.getDatabaseHandler()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	keep := map[string]bool{}
+	for _, r := range repositories {
+		keep[r] = true
+	}
+	rows, err := db.Query("SELECT DISTINCT repository FROM blob_refs")
+	if err != nil {
+		return fmt.Errorf("Error selecting from table: %s", err)
+	}
+	var stale []string
+	for rows.Next() {
+		var repo string
+		rows.Scan(&repo)
+		if !keep[repo] {
+			stale = append(stale, repo)
+		}
+	}
+	rows.Close()
+	for _, repo := range stale {
+		if _, err := db.Exec("DELETE FROM blob_refs WHERE repository=?", repo); err != nil {
+			return fmt.Errorf("Error deleting rows: %s", err)
+		}
+	}
+	return nil
+}
+
+// GetBlobGraphStats count the repos, tags and distinct blobs in the reference graph.
+func (e *EventListener) GetBlobGraphStats() (BlobGraphStats, error) {
+	var s BlobGraphStats
+	db, err := e.getDatabaseHandler()
+	if err != nil {
+		return s, err
+	}
+	defer db.Close()
+
+	err = db.QueryRow("SELECT COUNT(DISTINCT repository), COUNT(DISTINCT digest) FROM blob_refs").Scan(&s.Repos, &s.Blobs)
+	if err == nil {
+		err = db.QueryRow("SELECT COUNT(*) FROM (SELECT DISTINCT repository, tag FROM blob_refs) t").Scan(&s.Images)
+	}
+	if err != nil {
+		return s, fmt.Errorf("Error selecting from table: %s", err)
+	}
+	return s, nil
+}
+
+// selectBlobRefs rows of the reference graph by the condition, sorted by repo, tag and parent.
+func (e *EventListener) selectBlobRefs(where string, args ...interface{}) ([]BlobRef, error) {
+	list := []BlobRef{}
+	db, err := e.getDatabaseHandler()
+	if err != nil {
+		return list, err
+	}
+	defer db.Close()
+
+	rows, err := db.Query("SELECT repository, tag, parent, digest, kind, size FROM blob_refs WHERE "+where+
+		" ORDER BY repository, tag, parent, digest", args...)
+	if err != nil {
+		return list, fmt.Errorf("Error selecting from table: %s", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var r BlobRef
+		rows.Scan(&r.Repository, &r.Tag, &r.Parent, &r.Digest, &r.Kind, &r.Size)
+		list = append(list, r)
+	}
+	return list, nil
+}
+
+// GetBlobReferrers what references the blob: the edges to it with the parent manifest and the tag.
+func (e *EventListener) GetBlobReferrers(digest string) ([]BlobRef, error) {
+	return e.selectBlobRefs("digest=?", digest)
+}
+
+// GetBlobChildren blobs referenced by the manifest, once each.
+func (e *EventListener) GetBlobChildren(digest string) ([]BlobRef, error) {
+	refs, err := e.selectBlobRefs("parent=?", digest)
+	if err != nil {
+		return refs, err
+	}
+	list := []BlobRef{}
+	seen := map[string]bool{}
+	for _, r := range refs {
+		if !seen[r.Digest] {
+			seen[r.Digest] = true
+			list = append(list, BlobRef{Parent: r.Parent, Digest: r.Digest, Kind: r.Kind, Size: r.Size})
+		}
+	}
+	return list, nil
+}
+
+// GetBlobImpact what breaks when the blob is deleted. Blobs are shared by all repos, so a blob below it
+// is orphaned only when every tag referencing it breaks too.
+func (e *EventListener) GetBlobImpact(digest string) (BlobImpact, error) {
+	impact := BlobImpact{Tags: []BlobRef{}, Orphaned: []BlobRef{}}
+	refs, err := e.GetBlobReferrers(digest)
+	if err != nil {
+		return impact, err
+	}
+	broken := map[string]bool{}
+	for _, r := range refs {
+		key := r.Repository + ":" + r.Tag
+		if !broken[key] {
+			broken[key] = true
+			impact.Tags = append(impact.Tags, BlobRef{Repository: r.Repository, Tag: r.Tag})
+		}
+	}
+
+	seen := map[string]bool{digest: true}
+	queue := []string{digest}
+	for len(queue) > 0 {
+		children, err := e.GetBlobChildren(queue[0])
+		if err != nil {
+			return impact, err
+		}
+		queue = queue[1:]
+		for _, child := range children {
+			if seen[child.Digest] {
+				continue
+			}
+			seen[child.Digest] = true
+			queue = append(queue, child.Digest)
+			referrers, err := e.GetBlobReferrers(child.Digest)
+			if err != nil {
+				return impact, err
+			}
+			orphaned := true
+			for _, r := range referrers {
+				if !broken[r.Repository+":"+r.Tag] {
+					orphaned = false
+					break
+				}
+			}
+			if orphaned {
+				impact.Orphaned = append(impact.Orphaned, child)
+			}
+		}
+	}
+	sort.SliceStable(impact.Orphaned, func(i, j int) bool { return impact.Orphaned[i].Size > impact.Orphaned[j].Size })
+	return impact, nil
+}
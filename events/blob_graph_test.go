@@ -0,0 +1,64 @@
+package events
+
+import (
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestBlobGraph(t *testing.T) {
+	e := newTestListener(t)
+
+	image := func(repo, tag, parent, manifest, config, layer string) []BlobRef {
+		return []BlobRef{
+			{Repository: repo, Tag: tag, Parent: parent, Digest: manifest, Kind: BlobManifest, Size: 1},
+			{Repository: repo, Tag: tag, Parent: manifest, Digest: config, Kind: BlobConfig, Size: 2},
+			{Repository: repo, Tag: tag, Parent: manifest, Digest: "sha256:base", Kind: BlobLayer, Size: 100},
+			{Repository: repo, Tag: tag, Parent: manifest, Digest: layer, Kind: BlobLayer, Size: 10},
+		}
+	}
+
+	convey.Convey("Store the reference graph and find the referrers", t, func() {
+		multi := []BlobRef{{Repository: "team/app", Tag: "v1", Digest: "sha256:index", Kind: BlobIndex, Size: 1}}
+		multi = append(multi, image("team/app", "v1", "sha256:index", "sha256:amd64", "sha256:c1", "sha256:l1")...)
+		multi = append(multi, image("team/app", "v1", "sha256:index", "sha256:arm64", "sha256:c2", "sha256:l2")...)
+		multi = append(multi, image("team/app", "v2", "", "sha256:amd64", "sha256:c1", "sha256:l1")...)
+		convey.So(e.SetBlobRefs("team/app", multi), convey.ShouldBeNil)
+		convey.So(e.SetBlobRefs("team/worker", image("team/worker", "v1", "", "sha256:worker", "sha256:c3", "sha256:l3")), convey.ShouldBeNil)
+
+		stats, err := e.GetBlobGraphStats()
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(stats, convey.ShouldResemble, BlobGraphStats{Repos: 2, Images: 3, Blobs: 11})
+
+		refs, err := e.GetBlobReferrers("sha256:l1")
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(refs, convey.ShouldResemble, []BlobRef{
+			{Repository: "team/app", Tag: "v1", Parent: "sha256:amd64", Digest: "sha256:l1", Kind: BlobLayer, Size: 10},
+			{Repository: "team/app", Tag: "v2", Parent: "sha256:amd64", Digest: "sha256:l1", Kind: BlobLayer, Size: 10},
+		})
+		children, _ := e.GetBlobChildren("sha256:index")
+		convey.So(children, convey.ShouldHaveLength, 2)
+	})
+
+	convey.Convey("Tell what breaks when the digest is deleted", t, func() {
+		impact, err := e.GetBlobImpact("sha256:index")
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(impact.Tags, convey.ShouldResemble, []BlobRef{{Repository: "team/app", Tag: "v1"}})
+		// The amd64 image is still tagged v2, the base layer is used by the worker.
+		convey.So(impact.Orphaned, convey.ShouldResemble, []BlobRef{
+			{Parent: "sha256:arm64", Digest: "sha256:l2", Kind: BlobLayer, Size: 10},
+			{Parent: "sha256:arm64", Digest: "sha256:c2", Kind: BlobConfig, Size: 2},
+			{Parent: "sha256:index", Digest: "sha256:arm64", Kind: BlobManifest, Size: 1},
+		})
+
+		impact, _ = e.GetBlobImpact("sha256:base")
+		convey.So(impact.Tags, convey.ShouldHaveLength, 3)
+		convey.So(impact.Orphaned, convey.ShouldBeEmpty)
+	})
+
+	convey.Convey("Prune repos no longer in the graph", t, func() {
+		convey.So(e.PruneBlobGraph([]string{"team/worker"}), convey.ShouldBeNil)
+		stats, _ := e.GetBlobGraphStats()
+		convey.So(stats, convey.ShouldResemble, BlobGraphStats{Repos: 1, Images: 1, Blobs: 4})
+	})
+}
@@ -0,0 +1,150 @@
+package events
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/quiq/docker-registry-ui/registry"
+)
+
+// BundleTables tables of the settings managed from UI in the order they are exported and imported.
+// Events and audit log are not settings, so they are not included.
+var BundleTables = []string{"settings", "tag_locks", "repo_owners", "vulnerability_acceptances", "api_tokens", "user_preferences", "webhooks"}
+
+// Bundle settings managed from UI as rows by table, NULL values are nil.
+// API tokens are exported with their hashes, so the imported tokens keep working.
+type Bundle struct {
+	Version  string                          `json:"version"`
+	Exported time.Time                       `json:"exported"`
+	Tables   map[string][]map[string]*string `json:"tables"`
+}
+
+// tableColumns columns of the table as they are in the database.
+func tableColumns(db *sql.DB, table string) ([]string, error) {
+	rows, err := db.Query("SELECT * FROM " + table + " LIMIT 0")
+	if err != nil {
+		return nil, fmt.Errorf("Error selecting from table: %s", err)
+	}
+	defer rows.Close()
+	return rows.Columns()
+}
+
+// ExportBundle dump all rows of the settings tables.
+func (e *EventListener) ExportBundle(version string) (Bundle, error) {
+	b := Bundle{Version: version, Exported: time.Now().UTC(), Tables: map[string][]map[string]*string{}}
+	db, err := e.getDatabaseHandler()
+	if err != nil {
+		return b, err
+	}
+	defer db.Close()
+
+	for _, table := range BundleTables {
+		rows, err := db.Query("SELECT * FROM " + table)
+		if err != nil {
+			return b, fmt.Errorf("Error selecting from table: %s", err)
+		}
+		columns, _ := rows.Columns()
+		list := []map[string]*string{}
+		for rows.Next() {
+			values := make([]sql.NullString, len(columns))
+			ptrs := make([]interface{}, len(columns))
+			for i := range values {
+				ptrs[i] = &values[i]
+			}
+			if err := rows.Scan(ptrs...); err != nil {
+				rows.Close()
+				return b, fmt.Errorf("Error scanning row: %s", err)
+			}
+			row := map[string]*string{}
+			for i, c := range columns {
+				if values[i].Valid {
+					v := values[i].String
+					row[c] = &v
+				} else {
+					row[c] = nil
+				}
+			}
+			list = append(list, row)
+		}
+		rows.Close()
+		b.Tables[table] = list
+	}
+	return b, nil
+}
+
+// ImportBundle replace the rows of the tables present in the bundle in one transaction,
+// the tables missing from it are kept as they are. Returns the count of imported rows by table.
+func (e *EventListener) ImportBundle(b Bundle) (map[string]int, error) {
+	counts := map[string]int{}
+	for table := range b.Tables {
+		if !registry.ItemInSlice(table, BundleTables) {
+			return counts, fmt.Errorf("unknown table %q, should be one of %s", table, strings.Join(BundleTables, ", "))
+		}
+	}
+	db, err := e.getDatabaseHandler()
+	if err != nil {
+		return counts, err
+	}
+	defer db.Close()
+
+	// Column names come from the bundle, so they are checked against the table before building queries.
+	known := map[string][]string{}
+	for table, rows := range b.Tables {
+		columns, err := tableColumns(db, table)
+		if err != nil {
+			return counts, err
+		}
+		known[table] = columns
+		for _, row := range rows {
+			for c := range row {
+				if !registry.ItemInSlice(c, columns) {
+					return counts, fmt.Errorf("unknown column %q of table %s", c, table)
+				}
+			}
+		}
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return counts, fmt.Errorf("Error starting transaction: %s", err)
+	}
+	for _, table := range BundleTables {
+		rows, ok := b.Tables[table]
+		if !ok {
+			continue
+		}
+		if _, err := tx.Exec("DELETE FROM " + table); err != nil {
+			tx.Rollback()
+			return map[string]int{}, fmt.Errorf("Error deleting rows of %s: %s", table, err)
+		}
+		for _, row := range rows {
+			var columns, marks []string
+			var values []interface{}
+			for _, c := range known[table] {
+				v, ok := row[c]
+				if !ok {
+					continue
+				}
+				columns = append(columns, c)
+				marks = append(marks, "?")
+				if v == nil {
+					values = append(values, nil)
+				} else {
+					values = append(values, *v)
+				}
+			}
+			query := fmt.Sprintf("INSERT INTO %s(%s) VALUES(%s)", table, strings.Join(columns, ", "), strings.Join(marks, ","))
+			if _, err := tx.Exec(query, values...); err != nil {
+				tx.Rollback()
+				return map[string]int{}, fmt.Errorf("Error inserting a row into %s: %s", table, err)
+			}
+			counts[table]++
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return map[string]int{}, fmt.Errorf("Error committing transaction: %s", err)
+	}
+	return counts, nil
+}
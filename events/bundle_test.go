@@ -0,0 +1,49 @@
+package events
+
+import (
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestBundle(t *testing.T) {
+	src := newTestListener(t)
+	dst := newTestListener(t)
+
+	convey.Convey("Export settings and import them elsewhere replacing the existing ones", t, func() {
+		src.SetSetting(Setting{Scope: "team/app", Name: "protected_tags", Value: "v*", User: "alice"})
+		src.LockTag(TagLock{Repository: "team/app", Tag: "v1", Reason: "release", User: "alice"})
+		token, _ := src.CreateAPIToken("ci", "alice")
+		dst.LockTag(TagLock{Repository: "alpine", Tag: "3.13", Reason: "old", User: "bob"})
+		dst.SetPreference("bob", "tag_columns", "size")
+
+		b, err := src.ExportBundle("1.0")
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(len(b.Tables), convey.ShouldEqual, len(BundleTables))
+		convey.So(len(b.Tables["tag_locks"]), convey.ShouldEqual, 1)
+		convey.So(*b.Tables["tag_locks"][0]["reason"], convey.ShouldEqual, "release")
+		convey.So(b.Tables["api_tokens"][0]["last_used"], convey.ShouldBeNil)
+
+		// Preferences are not in the bundle, so they are kept.
+		delete(b.Tables, "user_preferences")
+		counts, err := dst.ImportBundle(b)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(counts["tag_locks"], convey.ShouldEqual, 1)
+		locks := dst.GetTagLocks("")
+		convey.So(len(locks), convey.ShouldEqual, 1)
+		convey.So(locks[0].Tag, convey.ShouldEqual, "v1")
+		convey.So(dst.GetSettings("team/app")["protected_tags"].Value, convey.ShouldEqual, "v*")
+		_, ok := dst.CheckAPIToken(token)
+		convey.So(ok, convey.ShouldBeTrue)
+		convey.So(dst.GetPreference("bob", "tag_columns"), convey.ShouldEqual, "size")
+	})
+
+	convey.Convey("Reject unknown tables and columns without changing anything", t, func() {
+		reason := "x"
+		_, err := dst.ImportBundle(Bundle{Tables: map[string][]map[string]*string{"events": {}}})
+		convey.So(err, convey.ShouldNotBeNil)
+		_, err = dst.ImportBundle(Bundle{Tables: map[string][]map[string]*string{"tag_locks": {{"reason; DROP TABLE events": &reason}}}})
+		convey.So(err, convey.ShouldNotBeNil)
+		convey.So(len(dst.GetTagLocks("")), convey.ShouldEqual, 1)
+	})
+}
@@ -2,11 +2,12 @@ package events
 
 import (
 	"database/sql"
-	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"os"
 	"strings"
+	"sync"
 
 	"github.com/quiq/docker-registry-ui/registry"
 	"github.com/sirupsen/logrus"
@@ -31,6 +32,18 @@ const (
 `
 )
 
+// migrations tables added after the initial schema, they are created when missing.
+var migrations = []string{schemaAPITokens, schemaAuditLog, schemaVulnAcceptances, schemaPreferences, schemaRepoOwners, schemaTagLocks, schemaSettings, schemaNewTags,
+	schemaLayerFiles, schemaIndexedLayers, schemaImageLayers, schemaDeletionRequests, schemaBlobRefs, schemaWebhooks, schemaWebhookDeliveries, schemaUsers, schemaUserSessions}
+
+// columnMigrations columns added to the tables after they were created, they are added when missing.
+var columnMigrations = []struct {
+	table, column, definition string
+}{
+	{"webhooks", "format", "VARCHAR(20) NULL"},
+	{"audit_log", "view_as", "VARCHAR(50) NULL"},
+}
+
 // EventListener event listener
 type EventListener struct {
 	databaseDriver   string
@@ -38,10 +51,9 @@ type EventListener struct {
 	retention        int
 	eventDeletion    bool
 	logger           *logrus.Entry
-}
-
-type eventData struct {
-	Events []interface{} `json:"events"`
+	migrated         bool
+	mux              sync.Mutex
+	windows          eventWindows
 }
 
 // EventRow event row from sqlite
@@ -55,6 +67,18 @@ type EventRow struct {
 	Created    string
 }
 
+// Event registry event as received, e.g. to notify webhooks of it. Tag is empty for blobs and pulls by digest.
+type Event struct {
+	Action     string `json:"action"`
+	Repository string `json:"repository"`
+	Tag        string `json:"tag"`
+	// Tags all tags of the aggregated push, Tag is the first of them.
+	Tags   []string `json:"tags,omitempty"`
+	Digest string   `json:"digest"`
+	IP     string   `json:"ip"`
+	User   string   `json:"user"`
+}
+
 // NewEventListener initialize EventListener.
 func NewEventListener(databaseDriver, databaseLocation string, retention int, eventDeletion bool) *EventListener {
 	return &EventListener{
@@ -66,57 +90,53 @@ func NewEventListener(databaseDriver, databaseLocation string, retention int, ev
 	}
 }
 
-// ProcessEvents parse and store registry events
-func (e *EventListener) ProcessEvents(request *http.Request) {
-	decoder := json.NewDecoder(request.Body)
-	var t eventData
-	if err := decoder.Decode(&t); err != nil {
+// ProcessEvents parse and store registry events of the source, it is detected by the payload when empty, see Sources.
+// The stored ones are passed to notify if set. The duplicates are dropped and the aggregated pushes are stored
+// and passed later, see SetEventWindows. Returns error if the payload is not of a known registry.
+func (e *EventListener) ProcessEvents(request *http.Request, source string, notify func([]Event)) error {
+	var stored []Event
+	body, err := ioutil.ReadAll(request.Body)
+	if err != nil || !gjson.ValidBytes(body) {
 		e.logger.Errorf("Problem decoding event from request: %+v", request)
-		return
+		return fmt.Errorf("cannot decode payload as JSON")
+	}
+	e.logger.Debugf("Received event: %s", body)
+	list, err := parseEvents(source, gjson.ParseBytes(body))
+	if err != nil {
+		return err
 	}
-	e.logger.Debugf("Received event: %+v", t)
-	j, _ := json.Marshal(t)
 
 	db, err := e.getDatabaseHandler()
 	if err != nil {
 		e.logger.Error(err)
-		return
+		return nil
 	}
 	defer db.Close()
+	defer func() {
+		if notify != nil && len(stored) > 0 {
+			notify(stored)
+		}
+	}()
 
-	now := "DateTime('now')"
-	if e.databaseDriver == "mysql" {
-		now = "NOW()"
-	}
-	stmt, _ := db.Prepare("INSERT INTO events(action, repository, tag, ip, user, created) values(?,?,?,?,?," + now + ")")
-	for _, i := range gjson.GetBytes(j, "events").Array() {
-		// Ignore calls by docker-registry-ui itself.
-		if i.Get("request.useragent").String() == "docker-registry-ui" {
+	for _, ev := range list {
+		e.logger.Debugf("Parsed event data: %s %s:%s %s %s ", ev.Action, ev.Repository, ev.Tag, ev.IP, ev.User)
+		if e.duplicate(ev) {
+			e.logger.Debugf("Dropped duplicate event: %s %s:%s@%s", ev.Action, ev.Repository, ev.Tag, ev.Digest)
 			continue
 		}
-		action := i.Get("action").String()
-		repository := i.Get("target.repository").String()
-		tag := i.Get("target.tag").String()
-		// Tag is empty in case of signed pull.
-		if tag == "" {
-			tag = i.Get("target.digest").String()
+		if e.hold(ev, notify) {
+			continue
 		}
-		ip := strings.Split(i.Get("request.addr").String(), ":")[0]
-		user := i.Get("actor.name").String()
-		e.logger.Debugf("Parsed event data: %s %s:%s %s %s ", action, repository, tag, ip, user)
-
-		res, err := stmt.Exec(action, repository, tag, ip, user)
-		if err != nil {
-			e.logger.Error("Error inserting a row: ", err)
-			return
+		if err := e.storeEvent(db, ev); err != nil {
+			e.logger.Error(err)
+			return nil
 		}
-		id, _ := res.LastInsertId()
-		e.logger.Debug("New event added with id ", id)
+		stored = append(stored, ev)
 	}
 
 	// Purge old records.
 	if !e.eventDeletion {
-		return
+		return nil
 	}
 	var res sql.Result
 	if e.databaseDriver == "mysql" {
@@ -128,6 +148,41 @@ func (e *EventListener) ProcessEvents(request *http.Request) {
 	}
 	count, _ := res.RowsAffected()
 	e.logger.Debug("Rows deleted: ", count)
+	return nil
+}
+
+// storeEvent insert the event, the tags of the aggregated push are stored in one row.
+// Tag pushes are recorded as new tags too.
+func (e *EventListener) storeEvent(db *sql.DB, ev Event) error {
+	tag := ev.Tag
+	if len(ev.Tags) > 0 {
+		tag = joinTags(ev.Tags)
+	}
+	// Tag is empty in case of signed pull.
+	if tag == "" {
+		tag = ev.Digest
+	}
+	res, err := db.Exec("INSERT INTO events(action, repository, tag, ip, user, created) values(?,?,?,?,?,"+e.now()+")",
+		ev.Action, ev.Repository, tag, ev.IP, ev.User)
+	if err != nil {
+		return fmt.Errorf("Error inserting a row: %s", err)
+	}
+	id, _ := res.LastInsertId()
+	e.logger.Debug("New event added with id ", id)
+
+	if ev.Action == "push" && ev.Tag != "" {
+		tags := ev.Tags
+		if len(tags) == 0 {
+			tags = []string{ev.Tag}
+		}
+		for _, tag := range tags {
+			t := NewTag{Repository: ev.Repository, Tag: tag, Digest: ev.Digest, Source: "push"}
+			if err := e.addNewTag(db, t); err != nil {
+				e.logger.Error(err)
+			}
+		}
+	}
+	return nil
 }
 
 // GetEvents retrieve events from sqlite db
@@ -160,11 +215,79 @@ func (e *EventListener) GetEvents(repository string) []EventRow {
 	return events
 }
 
+// RecentlyPushed repositories with the latest pushes first.
+func (e *EventListener) RecentlyPushed(limit int) []string {
+	var repos []string
+	db, err := e.getDatabaseHandler()
+	if err != nil {
+		e.logger.Error(err)
+		return repos
+	}
+	defer db.Close()
+
+	rows, err := db.Query("SELECT repository FROM events WHERE action='push' GROUP BY repository ORDER BY MAX(id) DESC LIMIT ?", limit)
+	if err != nil {
+		e.logger.Error("Error selecting from table: ", err)
+		return repos
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var repo string
+		rows.Scan(&repo)
+		repos = append(repos, repo)
+	}
+	return repos
+}
+
+// PullCounts count of pulls by tag of the repository within the events retention.
+func (e *EventListener) PullCounts(repository string) map[string]int {
+	counts := map[string]int{}
+	db, err := e.getDatabaseHandler()
+	if err != nil {
+		e.logger.Error(err)
+		return counts
+	}
+	defer db.Close()
+
+	rows, err := db.Query("SELECT tag, COUNT(*) FROM events WHERE action='pull' AND repository=? GROUP BY tag", repository)
+	if err != nil {
+		e.logger.Error("Error selecting from table: ", err)
+		return counts
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var tag string
+		var count int
+		rows.Scan(&tag, &count)
+		counts[tag] = count
+	}
+	return counts
+}
+
+// CheckDatabase check the database is reachable and writable.
+func (e *EventListener) CheckDatabase() error {
+	db, err := e.getDatabaseHandler()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("Error opening %s db: %s", e.databaseDriver, err)
+	}
+	if _, err := tx.Exec("INSERT INTO events(action) VALUES('check')"); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("Error writing to events table: %s", err)
+	}
+	return tx.Rollback()
+}
+
 func (e *EventListener) getDatabaseHandler() (*sql.DB, error) {
 	firstRun := false
 	schema := schemaSQLite
-	if e.databaseDriver == "sqlite3" {
-		if _, err := os.Stat(e.databaseLocation); os.IsNotExist(err) {
+	if file, err := e.databaseFile(); err == nil {
+		if _, err := os.Stat(file); os.IsNotExist(err) {
 			firstRun = true
 		}
 	}
@@ -192,5 +315,31 @@ func (e *EventListener) getDatabaseHandler() (*sql.DB, error) {
 			return nil, fmt.Errorf("Error creating a table: %s", err)
 		}
 	}
+
+	// Create the tables added later once per run.
+	e.mux.Lock()
+	defer e.mux.Unlock()
+	if !e.migrated {
+		for _, m := range migrations {
+			if e.databaseDriver == "mysql" {
+				m = strings.Replace(m, "AUTOINCREMENT", "AUTO_INCREMENT", 1)
+			}
+			if _, err = db.Exec(m); err != nil {
+				db.Close()
+				return nil, fmt.Errorf("Error creating a table: %s", err)
+			}
+		}
+		for _, m := range columnMigrations {
+			if rows, err := db.Query(fmt.Sprintf("SELECT %s FROM %s LIMIT 0", m.column, m.table)); err == nil {
+				rows.Close()
+				continue
+			}
+			if _, err = db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", m.table, m.column, m.definition)); err != nil {
+				db.Close()
+				return nil, fmt.Errorf("Error altering a table: %s", err)
+			}
+		}
+		e.migrated = true
+	}
 	return db, nil
 }
@@ -0,0 +1,47 @@
+package events
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+// newTestListener event listener with SQLite database in a directory removed after the test.
+func newTestListener(t *testing.T) *EventListener {
+	return NewEventListener("sqlite3", filepath.Join(t.TempDir(), "events.db"), 7, true)
+}
+
+func TestRecentlyPushed(t *testing.T) {
+	e := newTestListener(t)
+
+	convey.Convey("List repos by the latest push", t, func() {
+		db, err := e.getDatabaseHandler()
+		convey.So(err, convey.ShouldBeNil)
+		defer db.Close()
+		for _, r := range [][]string{{"push", "alpine"}, {"push", "team/app"}, {"pull", "team/worker"}, {"push", "alpine"}} {
+			_, err := db.Exec("INSERT INTO events(action, repository, tag, ip, user, created) VALUES(?, ?, 'latest', '', '', DateTime('now'))", r[0], r[1])
+			convey.So(err, convey.ShouldBeNil)
+		}
+
+		convey.So(e.RecentlyPushed(10), convey.ShouldResemble, []string{"alpine", "team/app"})
+		convey.So(e.RecentlyPushed(1), convey.ShouldResemble, []string{"alpine"})
+	})
+}
+
+func TestPullCounts(t *testing.T) {
+	e := newTestListener(t)
+
+	convey.Convey("Count pulls by tag", t, func() {
+		db, err := e.getDatabaseHandler()
+		convey.So(err, convey.ShouldBeNil)
+		defer db.Close()
+		for _, r := range [][]string{{"pull", "alpine", "latest"}, {"pull", "alpine", "latest"}, {"pull", "alpine", "3.12"}, {"push", "alpine", "3.12"}, {"pull", "busybox", "latest"}} {
+			_, err := db.Exec("INSERT INTO events(action, repository, tag, ip, user, created) VALUES(?, ?, ?, '', '', DateTime('now'))", r[0], r[1], r[2])
+			convey.So(err, convey.ShouldBeNil)
+		}
+
+		convey.So(e.PullCounts("alpine"), convey.ShouldResemble, map[string]int{"latest": 2, "3.12": 1})
+		convey.So(e.PullCounts("nginx"), convey.ShouldBeEmpty)
+	})
+}
@@ -0,0 +1,262 @@
+package events
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+const schemaLayerFiles = `
+	CREATE TABLE IF NOT EXISTS layer_files (
+		layer VARCHAR(100) NOT NULL,
+		path VARCHAR(600) NOT NULL,
+		size BIGINT NOT NULL,
+		PRIMARY KEY (layer, path)
+	);
+`
+
+const schemaIndexedLayers = `
+	CREATE TABLE IF NOT EXISTS indexed_layers (
+		layer VARCHAR(100) NOT NULL PRIMARY KEY,
+		files INTEGER NOT NULL,
+		truncated INTEGER NOT NULL,
+		indexed DATETIME NULL
+	);
+`
+
+const schemaImageLayers = `
+	CREATE TABLE IF NOT EXISTS image_layers (
+		repository VARCHAR(255) NOT NULL,
+		tag VARCHAR(255) NOT NULL,
+		layer VARCHAR(100) NOT NULL,
+		PRIMARY KEY (repository, tag, layer)
+	);
+`
+
+// MaxIndexedPath longer paths are not indexed.
+const MaxIndexedPath = 600
+
+// IndexedFile file of the layer stored in the file index.
+type IndexedFile struct {
+	Path string
+	Size int64
+}
+
+// FileMatch file found in the tag of the repo.
+type FileMatch struct {
+	Repository string `json:"repository"`
+	Tag        string `json:"tag"`
+	Layer      string `json:"layer"`
+	Path       string `json:"path"`
+	Size       int64  `json:"size"`
+}
+
+// ShortLayer first 12 hex digits of the layer digest.
+func (m FileMatch) ShortLayer() string {
+	short := strings.TrimPrefix(m.Layer, "sha256:")
+	if len(short) > 12 {
+		short = short[:12]
+	}
+	return short
+}
+
+// FileIndexStats size of the file index.
+type FileIndexStats struct {
+	Layers int `json:"layers"`
+	Files  int `json:"files"`
+	Images int `json:"images"`
+}
+
+// GetIndexedLayers digests of the layers in the file index.
+func (e *EventListener) GetIndexedLayers() (map[string]bool, error) {
+	layers := map[string]bool{}
+	db, err := e.getDatabaseHandler()
+	if err != nil {
+		return layers, err
+	}
+	defer db.Close()
+
+	rows, err := db.Query("SELECT layer FROM indexed_layers")
+	if err != nil {
+		return layers, fmt.Errorf("Error selecting from table: %s", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var layer string
+		rows.Scan(&layer)
+		layers[layer] = true
+	}
+	return layers, nil
+}
+
+// GetFileIndexStats count the indexed layers, their files and the images referencing them.
+func (e *EventListener) GetFileIndexStats() (FileIndexStats, error) {
+	var s FileIndexStats
+	db, err := e.getDatabaseHandler()
+	if err != nil {
+		return s, err
+	}
+	defer db.Close()
+
+	var files sql.NullInt64
+	err = db.QueryRow("SELECT COUNT(*), SUM(files) FROM indexed_layers").Scan(&s.Layers, &files)
+	if err == nil {
+		err = db.QueryRow("SELECT COUNT(*) FROM (SELECT DISTINCT repository, tag FROM image_layers) t").Scan(&s.Images)
+	}
+	if err != nil {
+		return s, fmt.Errorf("Error selecting from table: %s", err)
+	}
+	s.Files = int(files.Int64)
+	return s, nil
+}
+
+// AddLayerFiles store the files of the layer replacing the ones stored before. Duplicate paths,
+// e.g. a file overwritten within the same layer, are stored once.
+func (e *EventListener) AddLayerFiles(layer string, files []IndexedFile, truncated bool) error {
+	db, err := e.getDatabaseHandler()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec("DELETE FROM layer_files WHERE layer=?", layer); err != nil {
+		return fmt.Errorf("Error deleting rows: %s", err)
+	}
+	if _, err := tx.Exec("DELETE FROM indexed_layers WHERE layer=?", layer); err != nil {
+		return fmt.Errorf("Error deleting rows: %s", err)
+	}
+	stmt, err := tx.Prepare("INSERT INTO layer_files(layer, path, size) VALUES(?,?,?)")
+	if err != nil {
+		return fmt.Errorf("Error inserting a row: %s", err)
+	}
+	defer stmt.Close()
+	seen := map[string]bool{}
+	for _, f := range files {
+		if seen[f.Path] || len(f.Path) > MaxIndexedPath {
+			continue
+		}
+		seen[f.Path] = true
+		if _, err := stmt.Exec(layer, f.Path, f.Size); err != nil {
+			return fmt.Errorf("Error inserting a row: %s", err)
+		}
+	}
+	flag := 0
+	if truncated {
+		flag = 1
+	}
+	_, err = tx.Exec("INSERT INTO indexed_layers(layer, files, truncated, indexed) VALUES(?,?,?,"+e.now()+")", layer, len(seen), flag)
+	if err != nil {
+		return fmt.Errorf("Error inserting a row: %s", err)
+	}
+	return tx.Commit()
+}
+
+// SetImageLayers replace the layers of all tags of the repo in the file index, layers are given by tag.
+func (e *EventListener) SetImageLayers(repository string, layers map[string][]string) error {
+	db, err := e.getDatabaseHandler()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec("DELETE FROM image_layers WHERE repository=?", repository); err != nil {
+		return fmt.Errorf("Error deleting rows: %s", err)
+	}
+	for tag, list := range layers {
+		seen := map[string]bool{}
+		for _, layer := range list {
+			if seen[layer] {
+				continue
+			}
+			seen[layer] = true
+			if _, err := tx.Exec("INSERT INTO image_layers(repository, tag, layer) VALUES(?,?,?)", repository, tag, layer); err != nil {
+				return fmt.Errorf("Error inserting a row: %s", err)
+			}
+		}
+	}
+	return tx.Commit()
+}
+
+// PruneFileIndex remove the repos which are not indexed anymore and the layers no tag references.
+func (e *EventListener) PruneFileIndex(repositories []string) error {
+	db, err := e.getDatabaseHandler()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	keep := map[string]bool{}
+	for _, r := range repositories {
+		keep[r] = true
+	}
+	rows, err := db.Query("SELECT DISTINCT repository FROM image_layers")
+	if err != nil {
+		return fmt.Errorf("Error selecting from table: %s", err)
+	}
+	var stale []string
+	for rows.Next() {
+		var repo string
+		rows.Scan(&repo)
+		if !keep[repo] {
+			stale = append(stale, repo)
+		}
+	}
+	rows.Close()
+	for _, repo := range stale {
+		if _, err := db.Exec("DELETE FROM image_layers WHERE repository=?", repo); err != nil {
+			return fmt.Errorf("Error deleting rows: %s", err)
+		}
+	}
+	for _, table := range []string{"layer_files", "indexed_layers"} {
+		if _, err := db.Exec("DELETE FROM " + table + " WHERE layer NOT IN (SELECT DISTINCT layer FROM image_layers)"); err != nil {
+			return fmt.Errorf("Error deleting rows: %s", err)
+		}
+	}
+	return nil
+}
+
+// filePatternLike LIKE patterns of the path glob, * matches any characters and ? a single one.
+// The pattern starting with / matches from the root, otherwise in any directory, e.g. log4j*.jar.
+func filePatternLike(pattern string) (string, string) {
+	like := strings.NewReplacer("!", "!!", "%", "!%", "_", "!_", "*", "%", "?", "_").Replace(pattern)
+	if strings.HasPrefix(like, "/") {
+		like = strings.TrimPrefix(like, "/")
+		return like, like
+	}
+	return like, "%/" + like
+}
+
+// SearchFiles find the files matching the path glob in the indexed tags, sorted by repo, tag and path.
+func (e *EventListener) SearchFiles(pattern string, limit int) ([]FileMatch, error) {
+	list := []FileMatch{}
+	db, err := e.getDatabaseHandler()
+	if err != nil {
+		return list, err
+	}
+	defer db.Close()
+
+	root, nested := filePatternLike(pattern)
+	rows, err := db.Query(`SELECT i.repository, i.tag, f.layer, f.path, f.size FROM layer_files f
+		JOIN image_layers i ON i.layer = f.layer WHERE f.path LIKE ? ESCAPE '!' OR f.path LIKE ? ESCAPE '!'
+		ORDER BY i.repository, i.tag, f.path LIMIT ?`, root, nested, limit)
+	if err != nil {
+		return list, fmt.Errorf("Error selecting from table: %s", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var m FileMatch
+		rows.Scan(&m.Repository, &m.Tag, &m.Layer, &m.Path, &m.Size)
+		list = append(list, m)
+	}
+	return list, nil
+}
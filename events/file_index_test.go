@@ -0,0 +1,57 @@
+package events
+
+import (
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestFileIndex(t *testing.T) {
+	e := newTestListener(t)
+
+	convey.Convey("Index layer files and search them by path", t, func() {
+		convey.So(e.AddLayerFiles("sha256:base", []IndexedFile{
+			{Path: "usr/bin/env", Size: 10}, {Path: "usr/bin/env", Size: 10}, {Path: "etc/motd", Size: 5},
+		}, false), convey.ShouldBeNil)
+		convey.So(e.AddLayerFiles("sha256:app", []IndexedFile{
+			{Path: "app/lib/log4j-core-2.14.jar", Size: 100}, {Path: "app/lib/log4j_api.jar", Size: 50},
+		}, true), convey.ShouldBeNil)
+		convey.So(e.SetImageLayers("team/app", map[string][]string{
+			"v1": {"sha256:base", "sha256:app"},
+			"v2": {"sha256:base"},
+		}), convey.ShouldBeNil)
+
+		layers, err := e.GetIndexedLayers()
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(layers, convey.ShouldResemble, map[string]bool{"sha256:base": true, "sha256:app": true})
+		stats, _ := e.GetFileIndexStats()
+		convey.So(stats, convey.ShouldResemble, FileIndexStats{Layers: 2, Files: 4, Images: 2})
+
+		list, err := e.SearchFiles("log4j*.jar", 100)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(list, convey.ShouldHaveLength, 2)
+		convey.So(list[0], convey.ShouldResemble, FileMatch{Repository: "team/app", Tag: "v1", Layer: "sha256:app", Path: "app/lib/log4j-core-2.14.jar", Size: 100})
+		// Underscore is not a wildcard.
+		list, _ = e.SearchFiles("log4j_*", 100)
+		convey.So(list, convey.ShouldHaveLength, 1)
+		list, _ = e.SearchFiles("/usr/bin/env", 100)
+		convey.So(list, convey.ShouldHaveLength, 2)
+		list, _ = e.SearchFiles("/bin/env", 100)
+		convey.So(list, convey.ShouldBeEmpty)
+		list, _ = e.SearchFiles("bin/env", 100)
+		convey.So(list, convey.ShouldHaveLength, 2)
+		list, _ = e.SearchFiles("env", 1)
+		convey.So(list, convey.ShouldHaveLength, 1)
+	})
+
+	convey.Convey("Prune repos no longer indexed and unreferenced layers", t, func() {
+		convey.So(e.SetImageLayers("team/app", map[string][]string{"v2": {"sha256:base"}}), convey.ShouldBeNil)
+		convey.So(e.PruneFileIndex([]string{"team/app"}), convey.ShouldBeNil)
+		stats, _ := e.GetFileIndexStats()
+		convey.So(stats, convey.ShouldResemble, FileIndexStats{Layers: 1, Files: 2, Images: 1})
+
+		convey.So(e.PruneFileIndex(nil), convey.ShouldBeNil)
+		stats, _ = e.GetFileIndexStats()
+		convey.So(stats, convey.ShouldResemble, FileIndexStats{})
+	})
+}
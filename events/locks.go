@@ -0,0 +1,90 @@
+package events
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+const schemaTagLocks = `
+	CREATE TABLE IF NOT EXISTS tag_locks (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		repository VARCHAR(255) NOT NULL,
+		tag VARCHAR(255) NOT NULL,
+		reason VARCHAR(1000) NULL,
+		user VARCHAR(50) NULL,
+		created DATETIME NULL
+	);
+`
+
+// TagLock tag locked by user, it cannot be deleted from UI nor purged until unlocked.
+type TagLock struct {
+	ID         int
+	Repository string
+	Tag        string
+	Reason     string
+	User       string
+	Created    string
+}
+
+// LockTag store the lock.
+func (e *EventListener) LockTag(l TagLock) error {
+	db, err := e.getDatabaseHandler()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	_, err = db.Exec("INSERT INTO tag_locks(repository, tag, reason, user, created) VALUES(?,?,?,?,"+e.now()+")",
+		l.Repository, l.Tag, l.Reason, l.User)
+	if err != nil {
+		return fmt.Errorf("Error inserting a row: %s", err)
+	}
+	return nil
+}
+
+// UnlockTag delete the lock.
+func (e *EventListener) UnlockTag(id int) error {
+	db, err := e.getDatabaseHandler()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if _, err := db.Exec("DELETE FROM tag_locks WHERE id=?", id); err != nil {
+		return fmt.Errorf("Error deleting a row: %s", err)
+	}
+	return nil
+}
+
+// GetTagLocks retrieve locks of the repo or of all repos when it is empty, the latest first.
+func (e *EventListener) GetTagLocks(repository string) []TagLock {
+	var list []TagLock
+	db, err := e.getDatabaseHandler()
+	if err != nil {
+		e.logger.Error(err)
+		return list
+	}
+	defer db.Close()
+
+	query := "SELECT id, repository, tag, reason, user, created FROM tag_locks"
+	args := []interface{}{}
+	if repository != "" {
+		query = query + " WHERE repository=?"
+		args = append(args, repository)
+	}
+	rows, err := db.Query(query+" ORDER BY id DESC", args...)
+	if err != nil {
+		e.logger.Error("Error selecting from table: ", err)
+		return list
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var l TagLock
+		var reason, user, created sql.NullString
+		rows.Scan(&l.ID, &l.Repository, &l.Tag, &reason, &user, &created)
+		l.Reason, l.User, l.Created = reason.String, user.String, created.String
+		list = append(list, l)
+	}
+	return list
+}
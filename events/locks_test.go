@@ -0,0 +1,25 @@
+package events
+
+import (
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestTagLocks(t *testing.T) {
+	e := newTestListener(t)
+
+	convey.Convey("Lock, list and unlock tags", t, func() {
+		convey.So(e.LockTag(TagLock{Repository: "team/app", Tag: "v1", Reason: "release", User: "alice"}), convey.ShouldBeNil)
+		convey.So(e.LockTag(TagLock{Repository: "alpine", Tag: "3.13", User: "bob"}), convey.ShouldBeNil)
+
+		convey.So(len(e.GetTagLocks("")), convey.ShouldEqual, 2)
+		list := e.GetTagLocks("team/app")
+		convey.So(len(list), convey.ShouldEqual, 1)
+		convey.So(list[0].Reason, convey.ShouldEqual, "release")
+		convey.So(list[0].User, convey.ShouldEqual, "alice")
+
+		convey.So(e.UnlockTag(list[0].ID), convey.ShouldBeNil)
+		convey.So(e.GetTagLocks("team/app"), convey.ShouldBeEmpty)
+	})
+}
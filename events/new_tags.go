@@ -0,0 +1,93 @@
+package events
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+const schemaNewTags = `
+	CREATE TABLE IF NOT EXISTS new_tags (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		repository VARCHAR(255) NOT NULL,
+		tag VARCHAR(255) NOT NULL,
+		digest VARCHAR(100) NULL,
+		source VARCHAR(10) NULL,
+		created DATETIME NULL
+	);
+`
+
+// newTagsLimit how many of the latest new tags of the repo are returned for the feed.
+const newTagsLimit = 50
+
+// NewTag tag appeared in the repo, noticed by push event or by the tags refresh.
+type NewTag struct {
+	ID         int
+	Repository string
+	Tag        string
+	Digest     string
+	// Source "push" or "refresh".
+	Source  string
+	Created string
+}
+
+// AddNewTags record the tags appeared in the repo, the tags recorded before are skipped,
+// so re-pushing a tag does not make it new again.
+func (e *EventListener) AddNewTags(repository string, tags []string, source string) error {
+	db, err := e.getDatabaseHandler()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	for _, tag := range tags {
+		if err := e.addNewTag(db, NewTag{Repository: repository, Tag: tag, Source: source}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addNewTag record the tag unless it is recorded already.
+func (e *EventListener) addNewTag(db *sql.DB, t NewTag) error {
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM new_tags WHERE repository=? AND tag=?", t.Repository, t.Tag).Scan(&count); err != nil {
+		return fmt.Errorf("Error selecting from table: %s", err)
+	}
+	if count > 0 {
+		return nil
+	}
+	_, err := db.Exec("INSERT INTO new_tags(repository, tag, digest, source, created) VALUES(?,?,?,?,"+e.now()+")",
+		t.Repository, t.Tag, t.Digest, t.Source)
+	if err != nil {
+		return fmt.Errorf("Error inserting a row: %s", err)
+	}
+	return nil
+}
+
+// GetNewTags retrieve the latest new tags of the repo, the latest first.
+func (e *EventListener) GetNewTags(repository string) []NewTag {
+	var list []NewTag
+	db, err := e.getDatabaseHandler()
+	if err != nil {
+		e.logger.Error(err)
+		return list
+	}
+	defer db.Close()
+
+	rows, err := db.Query("SELECT id, repository, tag, digest, source, created FROM new_tags WHERE repository=? ORDER BY id DESC LIMIT ?",
+		repository, newTagsLimit)
+	if err != nil {
+		e.logger.Error("Error selecting from table: ", err)
+		return list
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var t NewTag
+		var digest, source, created sql.NullString
+		rows.Scan(&t.ID, &t.Repository, &t.Tag, &digest, &source, &created)
+		t.Digest, t.Source, t.Created = digest.String, source.String, created.String
+		list = append(list, t)
+	}
+	return list
+}
@@ -0,0 +1,35 @@
+package events
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestNewTags(t *testing.T) {
+	e := newTestListener(t)
+
+	convey.Convey("Record new tags once from refresh and push events", t, func() {
+		convey.So(e.AddNewTags("alpine", []string{"3.13", "3.14"}, "refresh"), convey.ShouldBeNil)
+		convey.So(e.AddNewTags("alpine", []string{"3.14"}, "refresh"), convey.ShouldBeNil)
+
+		body := `{"events": [
+			{"action": "push", "target": {"repository": "alpine", "tag": "3.15", "digest": "sha256:abc"}, "request": {"addr": "10.0.0.1:5000"}},
+			{"action": "push", "target": {"repository": "alpine", "tag": "3.13", "digest": "sha256:def"}, "request": {"addr": "10.0.0.1:5000"}},
+			{"action": "pull", "target": {"repository": "alpine", "tag": "3.12"}, "request": {"addr": "10.0.0.1:5000"}}
+		]}`
+		r, _ := http.NewRequest("POST", "/api/events", strings.NewReader(body))
+		e.ProcessEvents(r, "", nil)
+
+		list := e.GetNewTags("alpine")
+		convey.So(len(list), convey.ShouldEqual, 3)
+		convey.So(list[0].Tag, convey.ShouldEqual, "3.15")
+		convey.So(list[0].Digest, convey.ShouldEqual, "sha256:abc")
+		convey.So(list[0].Source, convey.ShouldEqual, "push")
+		convey.So(list[2].Tag, convey.ShouldEqual, "3.13")
+		convey.So(list[2].Source, convey.ShouldEqual, "refresh")
+		convey.So(e.GetNewTags("team/app"), convey.ShouldBeEmpty)
+	})
+}
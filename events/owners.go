@@ -0,0 +1,131 @@
+package events
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+const schemaRepoOwners = `
+	CREATE TABLE IF NOT EXISTS repo_owners (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		repos VARCHAR(255) NOT NULL,
+		team VARCHAR(100) NOT NULL,
+		slack VARCHAR(100) NULL,
+		email VARCHAR(255) NULL,
+		user VARCHAR(50) NULL,
+		created DATETIME NULL
+	);
+`
+
+// RepoOwner team owning the matching repos and how to reach it, from the config or set by admins.
+type RepoOwner struct {
+	ID int `yaml:"-"`
+	// Repos pattern of the repo path, a trailing * matches by prefix.
+	Repos   string `yaml:"repos"`
+	Team    string `yaml:"team"`
+	Slack   string `yaml:"slack"`
+	Email   string `yaml:"email"`
+	User    string `yaml:"-"`
+	Created string `yaml:"-"`
+}
+
+// Matches whether the repo path matches the pattern.
+func (o RepoOwner) Matches(repo string) bool {
+	if strings.HasSuffix(o.Repos, "*") {
+		return strings.HasPrefix(repo, strings.TrimSuffix(o.Repos, "*"))
+	}
+	return repo == o.Repos
+}
+
+// Contact how to reach the owner for humans, e.g. "Core (#core, core@example.com)".
+func (o RepoOwner) Contact() string {
+	var via []string
+	for _, s := range []string{o.Slack, o.Email} {
+		if s != "" {
+			via = append(via, s)
+		}
+	}
+	if len(via) == 0 {
+		return o.Team
+	}
+	return fmt.Sprintf("%s (%s)", o.Team, strings.Join(via, ", "))
+}
+
+// FindRepoOwner owner of the repo by the most specific pattern: exact repo path, then the longest prefix.
+// Earlier items win between equally specific patterns.
+func FindRepoOwner(owners []RepoOwner, repo string) (RepoOwner, bool) {
+	var found RepoOwner
+	best := -1
+	for _, o := range owners {
+		if !o.Matches(repo) {
+			continue
+		}
+		score := len(o.Repos)
+		if !strings.HasSuffix(o.Repos, "*") {
+			// Exact match beats any prefix.
+			score = score + 1000
+		}
+		if score > best {
+			found, best = o, score
+		}
+	}
+	return found, best >= 0
+}
+
+// AddRepoOwner store the owner of the repos.
+func (e *EventListener) AddRepoOwner(o RepoOwner) error {
+	db, err := e.getDatabaseHandler()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	_, err = db.Exec("INSERT INTO repo_owners(repos, team, slack, email, user, created) VALUES(?,?,?,?,?,"+e.now()+")",
+		o.Repos, o.Team, o.Slack, o.Email, o.User)
+	if err != nil {
+		return fmt.Errorf("Error inserting a row: %s", err)
+	}
+	return nil
+}
+
+// DeleteRepoOwner delete the owner.
+func (e *EventListener) DeleteRepoOwner(id int) error {
+	db, err := e.getDatabaseHandler()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if _, err := db.Exec("DELETE FROM repo_owners WHERE id=?", id); err != nil {
+		return fmt.Errorf("Error deleting a row: %s", err)
+	}
+	return nil
+}
+
+// GetRepoOwners retrieve all owners set by admins, the latest first.
+func (e *EventListener) GetRepoOwners() []RepoOwner {
+	var list []RepoOwner
+	db, err := e.getDatabaseHandler()
+	if err != nil {
+		e.logger.Error(err)
+		return list
+	}
+	defer db.Close()
+
+	rows, err := db.Query("SELECT id, repos, team, slack, email, user, created FROM repo_owners ORDER BY id DESC")
+	if err != nil {
+		e.logger.Error("Error selecting from table: ", err)
+		return list
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var o RepoOwner
+		var slack, email, user, created sql.NullString
+		rows.Scan(&o.ID, &o.Repos, &o.Team, &slack, &email, &user, &created)
+		o.Slack, o.Email, o.User, o.Created = slack.String, email.String, user.String, created.String
+		list = append(list, o)
+	}
+	return list
+}
@@ -0,0 +1,42 @@
+package events
+
+import (
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestRepoOwners(t *testing.T) {
+	e := newTestListener(t)
+
+	convey.Convey("Add, list and delete owners", t, func() {
+		convey.So(e.AddRepoOwner(RepoOwner{Repos: "team/*", Team: "Core", Slack: "#core", User: "admin"}), convey.ShouldBeNil)
+		convey.So(e.AddRepoOwner(RepoOwner{Repos: "team/app", Team: "App", Email: "app@example.com"}), convey.ShouldBeNil)
+
+		list := e.GetRepoOwners()
+		convey.So(len(list), convey.ShouldEqual, 2)
+		convey.So(list[0].Team, convey.ShouldEqual, "App")
+		convey.So(list[1].Slack, convey.ShouldEqual, "#core")
+
+		convey.So(e.DeleteRepoOwner(list[0].ID), convey.ShouldBeNil)
+		convey.So(len(e.GetRepoOwners()), convey.ShouldEqual, 1)
+	})
+
+	convey.Convey("Find the owner by the most specific pattern", t, func() {
+		owners := []RepoOwner{
+			{Repos: "*", Team: "Platform"},
+			{Repos: "team/*", Team: "Core", Slack: "#core"},
+			{Repos: "team/app", Team: "App", Slack: "#app", Email: "app@example.com"},
+			{Repos: "team/*", Team: "Other"},
+		}
+		o, ok := FindRepoOwner(owners, "team/app")
+		convey.So(ok, convey.ShouldBeTrue)
+		convey.So(o.Contact(), convey.ShouldEqual, "App (#app, app@example.com)")
+		o, _ = FindRepoOwner(owners, "team/worker")
+		convey.So(o.Contact(), convey.ShouldEqual, "Core (#core)")
+		o, _ = FindRepoOwner(owners, "alpine")
+		convey.So(o.Contact(), convey.ShouldEqual, "Platform")
+		_, ok = FindRepoOwner(owners[1:], "alpine")
+		convey.So(ok, convey.ShouldBeFalse)
+	})
+}
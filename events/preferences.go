@@ -0,0 +1,46 @@
+package events
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+const schemaPreferences = `
+	CREATE TABLE IF NOT EXISTS user_preferences (
+		user VARCHAR(50) NOT NULL,
+		name VARCHAR(50) NOT NULL,
+		value TEXT NULL,
+		PRIMARY KEY (user, name)
+	);
+`
+
+// GetPreference value of the user preference, empty if not set.
+func (e *EventListener) GetPreference(user, name string) string {
+	db, err := e.getDatabaseHandler()
+	if err != nil {
+		e.logger.Error(err)
+		return ""
+	}
+	defer db.Close()
+
+	var value sql.NullString
+	err = db.QueryRow("SELECT value FROM user_preferences WHERE user=? AND name=?", user, name).Scan(&value)
+	if err != nil && err != sql.ErrNoRows {
+		e.logger.Error("Error selecting from table: ", err)
+	}
+	return value.String
+}
+
+// SetPreference store the user preference replacing the previous value.
+func (e *EventListener) SetPreference(user, name, value string) error {
+	db, err := e.getDatabaseHandler()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if _, err := db.Exec("REPLACE INTO user_preferences(user, name, value) VALUES(?,?,?)", user, name, value); err != nil {
+		return fmt.Errorf("Error inserting a row: %s", err)
+	}
+	return nil
+}
@@ -0,0 +1,20 @@
+package events
+
+import (
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestPreferences(t *testing.T) {
+	e := newTestListener(t)
+
+	convey.Convey("Store and replace user preferences", t, func() {
+		convey.So(e.GetPreference("alice", "tag_columns"), convey.ShouldEqual, "")
+		convey.So(e.SetPreference("alice", "tag_columns", "size"), convey.ShouldBeNil)
+		convey.So(e.SetPreference("bob", "tag_columns", "digest,size"), convey.ShouldBeNil)
+		convey.So(e.SetPreference("alice", "tag_columns", "created,size"), convey.ShouldBeNil)
+		convey.So(e.GetPreference("alice", "tag_columns"), convey.ShouldEqual, "created,size")
+		convey.So(e.GetPreference("bob", "tag_columns"), convey.ShouldEqual, "digest,size")
+	})
+}
@@ -0,0 +1,86 @@
+package events
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+const schemaSettings = `
+	CREATE TABLE IF NOT EXISTS settings (
+		scope VARCHAR(255) NOT NULL,
+		name VARCHAR(50) NOT NULL,
+		value TEXT NULL,
+		user VARCHAR(50) NULL,
+		updated DATETIME NULL,
+		PRIMARY KEY (scope, name)
+	);
+`
+
+// Setting value changed from UI overriding the config one, scope is empty for global settings.
+type Setting struct {
+	Scope   string
+	Name    string
+	Value   string
+	User    string
+	Updated string
+}
+
+// SetSetting store the setting replacing the previous value.
+func (e *EventListener) SetSetting(s Setting) error {
+	db, err := e.getDatabaseHandler()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	_, err = db.Exec("REPLACE INTO settings(scope, name, value, user, updated) VALUES(?,?,?,?,"+e.now()+")",
+		s.Scope, s.Name, s.Value, s.User)
+	if err != nil {
+		return fmt.Errorf("Error inserting a row: %s", err)
+	}
+	return nil
+}
+
+// DeleteSetting delete the setting so the config value applies again.
+func (e *EventListener) DeleteSetting(scope, name string) error {
+	db, err := e.getDatabaseHandler()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if _, err := db.Exec("DELETE FROM settings WHERE scope=? AND name=?", scope, name); err != nil {
+		return fmt.Errorf("Error deleting a row: %s", err)
+	}
+	return nil
+}
+
+// GetSettings retrieve the settings of the scope by name.
+func (e *EventListener) GetSettings(scope string) map[string]Setting {
+	settings := map[string]Setting{}
+	db, err := e.getDatabaseHandler()
+	if err != nil {
+		e.logger.Error(err)
+		return settings
+	}
+	defer db.Close()
+
+	rows, err := db.Query("SELECT scope, name, value, user, updated FROM settings WHERE scope=?", scope)
+	if err != nil {
+		e.logger.Error("Error selecting from table: ", err)
+		return settings
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var s Setting
+		var value, user, updated sql.NullString
+		if err := rows.Scan(&s.Scope, &s.Name, &value, &user, &updated); err != nil {
+			e.logger.Error("Error scanning row: ", err)
+			continue
+		}
+		s.Value, s.User, s.Updated = value.String, user.String, updated.String
+		settings[s.Name] = s
+	}
+	return settings
+}
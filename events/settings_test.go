@@ -0,0 +1,28 @@
+package events
+
+import (
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestSettings(t *testing.T) {
+	e := newTestListener(t)
+
+	convey.Convey("Store, replace and delete settings by scope", t, func() {
+		convey.So(e.GetSettings(""), convey.ShouldBeEmpty)
+		convey.So(e.SetSetting(Setting{Name: "purge_tags_keep_days", Value: "30", User: "alice"}), convey.ShouldBeNil)
+		convey.So(e.SetSetting(Setting{Scope: "team/app", Name: "purge_tags_keep_days", Value: "7", User: "bob"}), convey.ShouldBeNil)
+		convey.So(e.SetSetting(Setting{Name: "purge_tags_keep_days", Value: "60", User: "bob"}), convey.ShouldBeNil)
+
+		global := e.GetSettings("")
+		convey.So(len(global), convey.ShouldEqual, 1)
+		convey.So(global["purge_tags_keep_days"].Value, convey.ShouldEqual, "60")
+		convey.So(global["purge_tags_keep_days"].User, convey.ShouldEqual, "bob")
+		convey.So(e.GetSettings("team/app")["purge_tags_keep_days"].Value, convey.ShouldEqual, "7")
+
+		convey.So(e.DeleteSetting("", "purge_tags_keep_days"), convey.ShouldBeNil)
+		convey.So(e.GetSettings(""), convey.ShouldBeEmpty)
+		convey.So(len(e.GetSettings("team/app")), convey.ShouldEqual, 1)
+	})
+}
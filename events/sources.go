@@ -0,0 +1,125 @@
+package events
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/tidwall/gjson"
+)
+
+// Registries sending the events, the payload format of each is normalized to Event.
+const (
+	SourceDistribution = "distribution"
+	SourceHarbor       = "harbor"
+	SourceQuay         = "quay"
+)
+
+// Sources registries the events are accepted from.
+var Sources = []string{SourceDistribution, SourceHarbor, SourceQuay}
+
+// harborActions actions by the event types of Harbor webhooks, the default and CloudEvents formats.
+// Other types, e.g. of scans and replication, are not image events and are ignored.
+var harborActions = map[string]string{
+	"PUSH_ARTIFACT":           "push",
+	"PULL_ARTIFACT":           "pull",
+	"DELETE_ARTIFACT":         "delete",
+	"harbor.artifact.pushed":  "push",
+	"harbor.artifact.pulled":  "pull",
+	"harbor.artifact.deleted": "delete",
+}
+
+// detectSource the registry the payload comes from by its fields, empty if unknown.
+func detectSource(payload gjson.Result) string {
+	switch {
+	case payload.Get("events").IsArray():
+		return SourceDistribution
+	case payload.Get("event_data").Exists(), strings.HasPrefix(payload.Get("type").String(), "harbor."):
+		return SourceHarbor
+	case payload.Get("updated_tags").Exists(), payload.Get("docker_url").Exists():
+		return SourceQuay
+	}
+	return ""
+}
+
+// parseEvents normalize the payload of the source, the source is detected when empty.
+func parseEvents(source string, payload gjson.Result) ([]Event, error) {
+	if !payload.IsObject() {
+		return nil, fmt.Errorf("payload should be JSON object")
+	}
+	if source == "" {
+		if source = detectSource(payload); source == "" {
+			return nil, fmt.Errorf("unknown payload, expected events of %s", strings.Join(Sources, ", "))
+		}
+	}
+	switch source {
+	case SourceDistribution:
+		return parseDistribution(payload), nil
+	case SourceHarbor:
+		return parseHarbor(payload), nil
+	case SourceQuay:
+		return parseQuay(payload), nil
+	}
+	return nil, fmt.Errorf("unknown source %q, expected one of %s", source, strings.Join(Sources, ", "))
+}
+
+// parseDistribution events of Docker Registry notifications, the ones of UI's own calls are skipped.
+func parseDistribution(payload gjson.Result) []Event {
+	var list []Event
+	for _, i := range payload.Get("events").Array() {
+		// Ignore calls by docker-registry-ui itself.
+		if i.Get("request.useragent").String() == "docker-registry-ui" {
+			continue
+		}
+		list = append(list, Event{
+			Action:     i.Get("action").String(),
+			Repository: i.Get("target.repository").String(),
+			Tag:        i.Get("target.tag").String(),
+			Digest:     i.Get("target.digest").String(),
+			IP:         strings.Split(i.Get("request.addr").String(), ":")[0],
+			User:       i.Get("actor.name").String(),
+		})
+	}
+	return list
+}
+
+// parseHarbor events of Harbor webhook, an event per artifact resource. CloudEvents format has the event data
+// with the operator under data. Harbor does not send the client IP.
+func parseHarbor(payload gjson.Result) []Event {
+	action, ok := harborActions[payload.Get("type").String()]
+	if !ok {
+		return nil
+	}
+	data := payload.Get("event_data")
+	if !data.Exists() {
+		data = payload.Get("data")
+	}
+	user := payload.Get("operator").String()
+	if user == "" {
+		user = data.Get("operator").String()
+	}
+	repo := data.Get("repository.repo_full_name").String()
+	if repo == "" {
+		repo = strings.TrimPrefix(data.Get("repository.namespace").String()+"/"+data.Get("repository.name").String(), "/")
+	}
+	var list []Event
+	for _, r := range data.Get("resources").Array() {
+		list = append(list, Event{Action: action, Repository: repo, Tag: r.Get("tag").String(), Digest: r.Get("digest").String(), User: user})
+	}
+	return list
+}
+
+// parseQuay repo_push notification of Quay, the updated tags are one push, Quay sends neither digest nor user.
+func parseQuay(payload gjson.Result) []Event {
+	var tags []string
+	for _, t := range payload.Get("updated_tags").Array() {
+		tags = append(tags, t.String())
+	}
+	if len(tags) == 0 {
+		return nil
+	}
+	ev := Event{Action: "push", Repository: payload.Get("repository").String(), Tag: tags[0]}
+	if len(tags) > 1 {
+		ev.Tags = tags
+	}
+	return []Event{ev}
+}
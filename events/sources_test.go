@@ -0,0 +1,58 @@
+package events
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+	"github.com/tidwall/gjson"
+)
+
+func TestSources(t *testing.T) {
+	convey.Convey("Normalize Harbor and Quay webhooks to registry events", t, func() {
+		harbor := `{"type": "PUSH_ARTIFACT", "occur_at": 1680000000, "operator": "ci",
+			"event_data": {"resources": [{"digest": "sha256:abc", "tag": "v1", "resource_url": "harbor.local/team/app:v1"},
+				{"digest": "sha256:abc", "tag": "latest"}],
+				"repository": {"name": "app", "namespace": "team", "repo_full_name": "team/app", "repo_type": "private"}}}`
+		list, err := parseEvents("", gjson.Parse(harbor))
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(list, convey.ShouldResemble, []Event{
+			{Action: "push", Repository: "team/app", Tag: "v1", Digest: "sha256:abc", User: "ci"},
+			{Action: "push", Repository: "team/app", Tag: "latest", Digest: "sha256:abc", User: "ci"},
+		})
+
+		cloudEvent := `{"specversion": "1.0", "type": "harbor.artifact.deleted", "source": "/projects/1/webhook/policies/1",
+			"data": {"operator": "admin", "resources": [{"digest": "sha256:def", "tag": "v0"}],
+				"repository": {"name": "app", "namespace": "team"}}}`
+		list, err = parseEvents("", gjson.Parse(cloudEvent))
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(list, convey.ShouldResemble, []Event{{Action: "delete", Repository: "team/app", Tag: "v0", Digest: "sha256:def", User: "admin"}})
+
+		list, err = parseEvents(SourceHarbor, gjson.Parse(`{"type": "SCANNING_COMPLETED", "event_data": {}}`))
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(list, convey.ShouldBeEmpty)
+
+		quay := `{"name": "app", "repository": "team/app", "namespace": "team", "docker_url": "quay.io/team/app",
+			"homepage": "https://quay.io/repository/team/app", "updated_tags": ["v2", "latest"]}`
+		list, err = parseEvents("", gjson.Parse(quay))
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(list, convey.ShouldResemble, []Event{{Action: "push", Repository: "team/app", Tag: "v2", Tags: []string{"v2", "latest"}}})
+
+		_, err = parseEvents("", gjson.Parse(`{"hello": "world"}`))
+		convey.So(err, convey.ShouldNotBeNil)
+		_, err = parseEvents("gitlab", gjson.Parse(quay))
+		convey.So(err, convey.ShouldNotBeNil)
+	})
+
+	convey.Convey("Store the events of the source given and refuse unknown payloads", t, func() {
+		e := newTestListener(t)
+
+		r, _ := http.NewRequest("POST", "/api/events/quay", strings.NewReader(`{"repository": "team/app", "updated_tags": ["v3"]}`))
+		convey.So(e.ProcessEvents(r, SourceQuay, nil), convey.ShouldBeNil)
+		convey.So(e.GetEvents("team/app")[0].Tag, convey.ShouldEqual, "v3")
+
+		r, _ = http.NewRequest("POST", "/api/events", strings.NewReader(`not json`))
+		convey.So(e.ProcessEvents(r, "", nil), convey.ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,111 @@
+package events
+
+import (
+	"strings"
+)
+
+// RepoCount count of the events of the repository.
+type RepoCount struct {
+	Repository string
+	Count      int
+}
+
+// EventStats counts of the events within the retention, e.g. to see the busiest repos.
+type EventStats struct {
+	Total     int
+	Actions   map[string]int
+	TopPulled []RepoCount
+	TopPushed []RepoCount
+}
+
+// scopeCondition SQL condition limiting events to the repos matching the patterns, a trailing * matches by prefix.
+// Nil scope is not limited, an empty one matches nothing.
+func scopeCondition(scope []string) (string, []interface{}) {
+	if scope == nil {
+		return "1=1", nil
+	}
+	if len(scope) == 0 {
+		return "1=0", nil
+	}
+	var conditions []string
+	var args []interface{}
+	// "!" escapes LIKE wildcards the same way in sqlite and MySQL, backslash is special in MySQL strings.
+	escaper := strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+	for _, p := range scope {
+		if strings.HasSuffix(p, "*") {
+			conditions = append(conditions, "repository LIKE ? ESCAPE '!'")
+			args = append(args, escaper.Replace(strings.TrimSuffix(p, "*"))+"%")
+		} else {
+			conditions = append(conditions, "repository=?")
+			args = append(args, p)
+		}
+	}
+	return "(" + strings.Join(conditions, " OR ") + ")", args
+}
+
+// GetScopedEvents the latest events of the repos matching the scope, see scopeCondition.
+func (e *EventListener) GetScopedEvents(scope []string) []EventRow {
+	var events []EventRow
+	db, err := e.getDatabaseHandler()
+	if err != nil {
+		e.logger.Error(err)
+		return events
+	}
+	defer db.Close()
+
+	condition, args := scopeCondition(scope)
+	rows, err := db.Query("SELECT * FROM events WHERE "+condition+" ORDER BY id DESC LIMIT 1000", args...)
+	if err != nil {
+		e.logger.Error("Error selecting from table: ", err)
+		return events
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var row EventRow
+		rows.Scan(&row.ID, &row.Action, &row.Repository, &row.Tag, &row.IP, &row.User, &row.Created)
+		events = append(events, row)
+	}
+	return events
+}
+
+// GetEventStats counts of the events by action and the repos pulled and pushed most, of the repos matching the scope.
+func (e *EventListener) GetEventStats(scope []string, top int) EventStats {
+	stats := EventStats{Actions: map[string]int{}, TopPulled: []RepoCount{}, TopPushed: []RepoCount{}}
+	db, err := e.getDatabaseHandler()
+	if err != nil {
+		e.logger.Error(err)
+		return stats
+	}
+	defer db.Close()
+
+	condition, args := scopeCondition(scope)
+	rows, err := db.Query("SELECT action, COUNT(*) FROM events WHERE "+condition+" GROUP BY action", args...)
+	if err != nil {
+		e.logger.Error("Error selecting from table: ", err)
+		return stats
+	}
+	for rows.Next() {
+		var action string
+		var count int
+		rows.Scan(&action, &count)
+		stats.Actions[action] = count
+		stats.Total += count
+	}
+	rows.Close()
+
+	for action, list := range map[string]*[]RepoCount{"pull": &stats.TopPulled, "push": &stats.TopPushed} {
+		rows, err := db.Query("SELECT repository, COUNT(*) AS n FROM events WHERE action=? AND "+condition+
+			" GROUP BY repository ORDER BY n DESC, repository LIMIT ?", append(append([]interface{}{action}, args...), top)...)
+		if err != nil {
+			e.logger.Error("Error selecting from table: ", err)
+			continue
+		}
+		for rows.Next() {
+			var r RepoCount
+			rows.Scan(&r.Repository, &r.Count)
+			*list = append(*list, r)
+		}
+		rows.Close()
+	}
+	return stats
+}
@@ -0,0 +1,43 @@
+package events
+
+import (
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestEventStats(t *testing.T) {
+	e := newTestListener(t)
+
+	db, _ := e.getDatabaseHandler()
+	defer db.Close()
+	for _, r := range [][]string{{"pull", "team/app"}, {"pull", "team/app"}, {"push", "team/app"}, {"pull", "team/worker"},
+		{"pull", "team_x/app"}, {"push", "alpine"}, {"delete", "alpine"}} {
+		db.Exec("INSERT INTO events(action, repository, tag, ip, user, created) VALUES(?, ?, 'latest', '', '', DateTime('now'))", r[0], r[1])
+	}
+
+	convey.Convey("Count all events when not scoped", t, func() {
+		s := e.GetEventStats(nil, 10)
+		convey.So(s.Total, convey.ShouldEqual, 7)
+		convey.So(s.Actions, convey.ShouldResemble, map[string]int{"pull": 4, "push": 2, "delete": 1})
+		convey.So(s.TopPulled, convey.ShouldResemble, []RepoCount{{"team/app", 2}, {"team/worker", 1}, {"team_x/app", 1}})
+		convey.So(s.TopPushed, convey.ShouldResemble, []RepoCount{{"alpine", 1}, {"team/app", 1}})
+		convey.So(len(e.GetScopedEvents(nil)), convey.ShouldEqual, 7)
+	})
+
+	convey.Convey("Count the events of the scope only, LIKE wildcards are matched literally", t, func() {
+		s := e.GetEventStats([]string{"team/*", "busybox"}, 1)
+		convey.So(s.Total, convey.ShouldEqual, 4)
+		convey.So(s.TopPulled, convey.ShouldResemble, []RepoCount{{"team/app", 2}})
+		convey.So(s.TopPushed, convey.ShouldResemble, []RepoCount{{"team/app", 1}})
+		convey.So(len(e.GetScopedEvents([]string{"team/*"})), convey.ShouldEqual, 4)
+		convey.So(len(e.GetScopedEvents([]string{"alpine"})), convey.ShouldEqual, 2)
+	})
+
+	convey.Convey("Nothing is visible in the empty scope", t, func() {
+		s := e.GetEventStats([]string{}, 10)
+		convey.So(s.Total, convey.ShouldEqual, 0)
+		convey.So(s.TopPulled, convey.ShouldBeEmpty)
+		convey.So(e.GetScopedEvents([]string{}), convey.ShouldBeEmpty)
+	})
+}
@@ -0,0 +1,94 @@
+package events
+
+import (
+	"crypto/subtle"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/quiq/docker-registry-ui/registry"
+	"github.com/sirupsen/logrus"
+)
+
+// Tokens bearer tokens accepted by the event listener.
+// Tokens listed in the file are re-read whenever the file is modified,
+// so they can be rotated without restarting the UI.
+type Tokens struct {
+	static     []string
+	file       string
+	fileTokens []string
+	modTime    time.Time
+	mux        sync.Mutex
+	logger     *logrus.Entry
+}
+
+// NewTokens initialize Tokens.
+func NewTokens(static []string, file string) *Tokens {
+	t := &Tokens{
+		file:   file,
+		logger: registry.SetupLogging("events.tokens"),
+	}
+	for _, s := range static {
+		if s != "" {
+			t.static = append(t.static, s)
+		}
+	}
+	t.reload()
+	return t
+}
+
+// Valid check if the token is one of the accepted tokens.
+func (t *Tokens) Valid(token string) bool {
+	if token == "" {
+		return false
+	}
+	t.reload()
+
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	valid := false
+	for _, list := range [][]string{t.static, t.fileTokens} {
+		for _, s := range list {
+			// Compare all of them to not leak which token matched by timing.
+			if subtle.ConstantTimeCompare([]byte(s), []byte(token)) == 1 {
+				valid = true
+			}
+		}
+	}
+	return valid
+}
+
+// reload re-read tokens from file if it has changed since the last read.
+func (t *Tokens) reload() {
+	if t.file == "" {
+		return
+	}
+	info, err := os.Stat(t.file)
+	if err != nil {
+		t.logger.Errorf("Cannot read token file: %s", err)
+		return
+	}
+
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	if info.ModTime().Equal(t.modTime) {
+		return
+	}
+	data, err := ioutil.ReadFile(t.file)
+	if err != nil {
+		t.logger.Errorf("Cannot read token file: %s", err)
+		return
+	}
+	var tokens []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			tokens = append(tokens, line)
+		}
+	}
+	t.fileTokens = tokens
+	t.modTime = info.ModTime()
+	t.logger.Infof("Loaded %d tokens from %s", len(tokens), t.file)
+}
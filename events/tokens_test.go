@@ -0,0 +1,34 @@
+package events
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestTokens(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "tokens")
+	ioutil.WriteFile(file, []byte("# comment\nold\n\nnew\n"), 0600)
+
+	convey.Convey("Validate tokens", t, func() {
+		tokens := NewTokens([]string{"static", ""}, file)
+		convey.So(tokens.Valid("static"), convey.ShouldBeTrue)
+		convey.So(tokens.Valid("old"), convey.ShouldBeTrue)
+		convey.So(tokens.Valid("new"), convey.ShouldBeTrue)
+		convey.So(tokens.Valid(""), convey.ShouldBeFalse)
+		convey.So(tokens.Valid("# comment"), convey.ShouldBeFalse)
+
+		convey.Convey("Reload rotated tokens from file", func() {
+			ioutil.WriteFile(file, []byte("new\n"), 0600)
+			later := time.Now().Add(time.Minute)
+			os.Chtimes(file, later, later)
+			convey.So(tokens.Valid("old"), convey.ShouldBeFalse)
+			convey.So(tokens.Valid("new"), convey.ShouldBeTrue)
+			convey.So(tokens.Valid("static"), convey.ShouldBeTrue)
+		})
+	})
+}
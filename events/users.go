@@ -0,0 +1,219 @@
+package events
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	schemaUsers = `
+	CREATE TABLE IF NOT EXISTS users (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name VARCHAR(50) NOT NULL UNIQUE,
+		password_hash VARCHAR(100) NOT NULL,
+		created DATETIME NULL,
+		last_login DATETIME NULL
+	);
+`
+	schemaUserSessions = `
+	CREATE TABLE IF NOT EXISTS user_sessions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user VARCHAR(50) NOT NULL,
+		token_hash CHAR(64) NOT NULL,
+		expires DATETIME NOT NULL
+	);
+`
+	// MinPasswordLength shortest password of the local users.
+	MinPasswordLength = 8
+)
+
+// dummyPasswordHash compared against when the user does not exist, so the response time does not tell it.
+var dummyPasswordHash = []byte("$2a$10$Y9hyj4DAyIWu1J23MZwAJ.VP8rYsTRBQVDNxJIUf45uGC1BiS8ZsO")
+
+// User local user account logging in by the form, only the bcrypt hash of the password is stored.
+type User struct {
+	ID        int
+	Name      string
+	Created   string
+	LastLogin string
+}
+
+// SetUserPassword create the user or change the password of the existing one, the sessions of the user end.
+func (e *EventListener) SetUserPassword(name, password string) error {
+	if name == "" {
+		return fmt.Errorf("user name should be set")
+	}
+	if len(password) < MinPasswordLength {
+		return fmt.Errorf("password should be at least %d characters long", MinPasswordLength)
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	db, err := e.getDatabaseHandler()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	res, err := db.Exec("UPDATE users SET password_hash=? WHERE name=?", string(hash), name)
+	if err != nil {
+		return fmt.Errorf("Error updating a row: %s", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		_, err = db.Exec("INSERT INTO users(name, password_hash, created) VALUES(?,?,"+e.now()+")", name, string(hash))
+		if err != nil {
+			return fmt.Errorf("Error inserting a row: %s", err)
+		}
+		e.logger.Infof("User %s created", name)
+		return nil
+	}
+	if _, err := db.Exec("DELETE FROM user_sessions WHERE user=?", name); err != nil {
+		return fmt.Errorf("Error deleting a row: %s", err)
+	}
+	e.logger.Infof("Password of user %s changed", name)
+	return nil
+}
+
+// DeleteUser delete the user and end their sessions.
+func (e *EventListener) DeleteUser(name string) error {
+	db, err := e.getDatabaseHandler()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	res, err := db.Exec("DELETE FROM users WHERE name=?", name)
+	if err != nil {
+		return fmt.Errorf("Error deleting a row: %s", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("user %s not found", name)
+	}
+	if _, err := db.Exec("DELETE FROM user_sessions WHERE user=?", name); err != nil {
+		return fmt.Errorf("Error deleting a row: %s", err)
+	}
+	return nil
+}
+
+// GetUsers list the users.
+func (e *EventListener) GetUsers() []User {
+	var users []User
+	db, err := e.getDatabaseHandler()
+	if err != nil {
+		e.logger.Error(err)
+		return users
+	}
+	defer db.Close()
+
+	rows, err := db.Query("SELECT id, name, created, last_login FROM users ORDER BY name")
+	if err != nil {
+		e.logger.Error("Error selecting from table: ", err)
+		return users
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var u User
+		var created, lastLogin sql.NullString
+		rows.Scan(&u.ID, &u.Name, &created, &lastLogin)
+		u.Created, u.LastLogin = created.String, lastLogin.String
+		users = append(users, u)
+	}
+	return users
+}
+
+// CheckUserPassword whether the password is of the user, the login time is recorded on success.
+func (e *EventListener) CheckUserPassword(name, password string) bool {
+	db, err := e.getDatabaseHandler()
+	if err != nil {
+		e.logger.Error(err)
+		return false
+	}
+	defer db.Close()
+
+	var hash string
+	err = db.QueryRow("SELECT password_hash FROM users WHERE name=?", name).Scan(&hash)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			e.logger.Error("Error selecting from table: ", err)
+		}
+		bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(password))
+		return false
+	}
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) != nil {
+		return false
+	}
+	if _, err := db.Exec("UPDATE users SET last_login="+e.now()+" WHERE name=?", name); err != nil {
+		e.logger.Error("Error updating a row: ", err)
+	}
+	return true
+}
+
+// CreateSession start the session of the user for the max age, the token for the cookie is returned only once.
+// Expired sessions are deleted meanwhile.
+func (e *EventListener) CreateSession(user string, maxAge time.Duration) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(b)
+
+	db, err := e.getDatabaseHandler()
+	if err != nil {
+		return "", err
+	}
+	defer db.Close()
+
+	now := time.Now().UTC()
+	if _, err := db.Exec("DELETE FROM user_sessions WHERE expires<?", now.Format("2006-01-02 15:04:05")); err != nil {
+		e.logger.Error("Error deleting a row: ", err)
+	}
+	_, err = db.Exec("INSERT INTO user_sessions(user, token_hash, expires) VALUES(?,?,?)",
+		user, hashAPIToken(token), now.Add(maxAge).Format("2006-01-02 15:04:05"))
+	if err != nil {
+		return "", fmt.Errorf("Error inserting a row: %s", err)
+	}
+	return token, nil
+}
+
+// CheckSession the user of the session if it has not expired.
+func (e *EventListener) CheckSession(token string) (string, bool) {
+	db, err := e.getDatabaseHandler()
+	if err != nil {
+		e.logger.Error(err)
+		return "", false
+	}
+	defer db.Close()
+
+	var user string
+	err = db.QueryRow("SELECT user FROM user_sessions WHERE token_hash=? AND expires>?",
+		hashAPIToken(token), time.Now().UTC().Format("2006-01-02 15:04:05")).Scan(&user)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			e.logger.Error("Error selecting from table: ", err)
+		}
+		return "", false
+	}
+	return user, true
+}
+
+// DeleteSession end the session, e.g. on logout.
+func (e *EventListener) DeleteSession(token string) error {
+	db, err := e.getDatabaseHandler()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if _, err := db.Exec("DELETE FROM user_sessions WHERE token_hash=?", hashAPIToken(token)); err != nil {
+		return fmt.Errorf("Error deleting a row: %s", err)
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package events
+
+import (
+	"testing"
+	"time"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestUsers(t *testing.T) {
+	e := newTestListener(t)
+
+	convey.Convey("Create user, change the password and delete it", t, func() {
+		convey.So(e.SetUserPassword("alice", "short"), convey.ShouldNotBeNil)
+		convey.So(e.SetUserPassword("alice", "correct horse"), convey.ShouldBeNil)
+		convey.So(e.CheckUserPassword("alice", "correct horse"), convey.ShouldBeTrue)
+		convey.So(e.CheckUserPassword("alice", "wrong horse"), convey.ShouldBeFalse)
+		convey.So(e.CheckUserPassword("bob", "correct horse"), convey.ShouldBeFalse)
+
+		users := e.GetUsers()
+		convey.So(len(users), convey.ShouldEqual, 1)
+		convey.So(users[0].Name, convey.ShouldEqual, "alice")
+		convey.So(users[0].LastLogin, convey.ShouldNotEqual, "")
+
+		convey.So(e.SetUserPassword("alice", "battery staple"), convey.ShouldBeNil)
+		convey.So(e.CheckUserPassword("alice", "correct horse"), convey.ShouldBeFalse)
+		convey.So(e.CheckUserPassword("alice", "battery staple"), convey.ShouldBeTrue)
+
+		convey.So(e.DeleteUser("alice"), convey.ShouldBeNil)
+		convey.So(e.DeleteUser("alice"), convey.ShouldNotBeNil)
+		convey.So(e.CheckUserPassword("alice", "battery staple"), convey.ShouldBeFalse)
+		convey.So(e.GetUsers(), convey.ShouldBeEmpty)
+	})
+
+	convey.Convey("Sessions end on logout, expiry and password change", t, func() {
+		convey.So(e.SetUserPassword("alice", "correct horse"), convey.ShouldBeNil)
+		token, err := e.CreateSession("alice", time.Hour)
+		convey.So(err, convey.ShouldBeNil)
+		user, ok := e.CheckSession(token)
+		convey.So(ok, convey.ShouldBeTrue)
+		convey.So(user, convey.ShouldEqual, "alice")
+		_, ok = e.CheckSession(token + "x")
+		convey.So(ok, convey.ShouldBeFalse)
+
+		convey.So(e.DeleteSession(token), convey.ShouldBeNil)
+		_, ok = e.CheckSession(token)
+		convey.So(ok, convey.ShouldBeFalse)
+
+		expired, _ := e.CreateSession("alice", -time.Minute)
+		_, ok = e.CheckSession(expired)
+		convey.So(ok, convey.ShouldBeFalse)
+
+		token, _ = e.CreateSession("alice", time.Hour)
+		convey.So(e.SetUserPassword("alice", "battery staple"), convey.ShouldBeNil)
+		_, ok = e.CheckSession(token)
+		convey.So(ok, convey.ShouldBeFalse)
+	})
+}
@@ -0,0 +1,100 @@
+package events
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+const schemaVulnAcceptances = `
+	CREATE TABLE IF NOT EXISTS vulnerability_acceptances (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		repository VARCHAR(255) NOT NULL,
+		tag VARCHAR(255) NULL,
+		vulnerability VARCHAR(100) NOT NULL,
+		reason VARCHAR(1000) NULL,
+		user VARCHAR(50) NULL,
+		created DATETIME NULL,
+		expires VARCHAR(10) NULL
+	);
+`
+
+// VulnAcceptance vulnerability accepted by admin for the image or all images of the repo when tag is empty.
+type VulnAcceptance struct {
+	ID            int
+	Repository    string
+	Tag           string
+	Vulnerability string
+	Reason        string
+	User          string
+	Created       string
+	// Expires date as YYYY-MM-DD the acceptance is valid through, empty means it never expires.
+	Expires string
+}
+
+// Expired whether the acceptance is no longer valid.
+func (v VulnAcceptance) Expired(now time.Time) bool {
+	return v.Expires != "" && v.Expires < now.Format("2006-01-02")
+}
+
+// Matches whether the acceptance is valid for the vulnerability found in the image.
+func (v VulnAcceptance) Matches(repo, tag, vulnerability string, now time.Time) bool {
+	return v.Repository == repo && (v.Tag == "" || v.Tag == tag) && v.Vulnerability == vulnerability && !v.Expired(now)
+}
+
+// AcceptVulnerability store the acceptance.
+func (e *EventListener) AcceptVulnerability(v VulnAcceptance) error {
+	db, err := e.getDatabaseHandler()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	_, err = db.Exec("INSERT INTO vulnerability_acceptances(repository, tag, vulnerability, reason, user, created, expires) VALUES(?,?,?,?,?,"+e.now()+",?)",
+		v.Repository, v.Tag, v.Vulnerability, v.Reason, v.User, v.Expires)
+	if err != nil {
+		return fmt.Errorf("Error inserting a row: %s", err)
+	}
+	return nil
+}
+
+// RevokeVulnAcceptance delete the acceptance.
+func (e *EventListener) RevokeVulnAcceptance(id int) error {
+	db, err := e.getDatabaseHandler()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if _, err := db.Exec("DELETE FROM vulnerability_acceptances WHERE id=?", id); err != nil {
+		return fmt.Errorf("Error deleting a row: %s", err)
+	}
+	return nil
+}
+
+// GetVulnAcceptances retrieve all acceptances including expired ones, the latest first.
+func (e *EventListener) GetVulnAcceptances() []VulnAcceptance {
+	var list []VulnAcceptance
+	db, err := e.getDatabaseHandler()
+	if err != nil {
+		e.logger.Error(err)
+		return list
+	}
+	defer db.Close()
+
+	rows, err := db.Query("SELECT id, repository, tag, vulnerability, reason, user, created, expires FROM vulnerability_acceptances ORDER BY id DESC")
+	if err != nil {
+		e.logger.Error("Error selecting from table: ", err)
+		return list
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var v VulnAcceptance
+		var tag, reason, user, created, expires sql.NullString
+		rows.Scan(&v.ID, &v.Repository, &tag, &v.Vulnerability, &reason, &user, &created, &expires)
+		v.Tag, v.Reason, v.User, v.Created, v.Expires = tag.String, reason.String, user.String, created.String, expires.String
+		list = append(list, v)
+	}
+	return list
+}
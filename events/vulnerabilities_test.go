@@ -0,0 +1,39 @@
+package events
+
+import (
+	"testing"
+	"time"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestVulnAcceptances(t *testing.T) {
+	e := newTestListener(t)
+	now := time.Date(2021, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	convey.Convey("Accept, list and revoke vulnerabilities", t, func() {
+		convey.So(e.AcceptVulnerability(VulnAcceptance{Repository: "alpine", Vulnerability: "CVE-2021-1", Reason: "not used", User: "admin", Expires: "2021-05-01"}), convey.ShouldBeNil)
+		convey.So(e.AcceptVulnerability(VulnAcceptance{Repository: "alpine", Tag: "3.12", Vulnerability: "CVE-2021-2"}), convey.ShouldBeNil)
+
+		list := e.GetVulnAcceptances()
+		convey.So(len(list), convey.ShouldEqual, 2)
+		convey.So(list[0].Tag, convey.ShouldEqual, "3.12")
+		convey.So(list[1].Reason, convey.ShouldEqual, "not used")
+
+		convey.So(e.RevokeVulnAcceptance(list[0].ID), convey.ShouldBeNil)
+		convey.So(len(e.GetVulnAcceptances()), convey.ShouldEqual, 1)
+	})
+
+	convey.Convey("Match acceptances by repo, tag and expiry", t, func() {
+		repo := VulnAcceptance{Repository: "alpine", Vulnerability: "CVE-2021-1", Expires: "2021-05-01"}
+		convey.So(repo.Matches("alpine", "3.13", "CVE-2021-1", now), convey.ShouldBeTrue)
+		convey.So(repo.Matches("alpine", "3.13", "CVE-2021-2", now), convey.ShouldBeFalse)
+		convey.So(repo.Matches("busybox", "3.13", "CVE-2021-1", now), convey.ShouldBeFalse)
+		convey.So(repo.Matches("alpine", "3.13", "CVE-2021-1", now.AddDate(0, 0, 1)), convey.ShouldBeFalse)
+
+		image := VulnAcceptance{Repository: "alpine", Tag: "3.12", Vulnerability: "CVE-2021-1"}
+		convey.So(image.Matches("alpine", "3.12", "CVE-2021-1", now), convey.ShouldBeTrue)
+		convey.So(image.Matches("alpine", "3.13", "CVE-2021-1", now), convey.ShouldBeFalse)
+		convey.So(image.Expired(now.AddDate(10, 0, 0)), convey.ShouldBeFalse)
+	})
+}
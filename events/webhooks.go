@@ -0,0 +1,198 @@
+package events
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+const schemaWebhooks = `
+	CREATE TABLE IF NOT EXISTS webhooks (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		repos VARCHAR(255) NOT NULL,
+		url VARCHAR(1000) NOT NULL,
+		secret VARCHAR(255) NULL,
+		actions VARCHAR(100) NOT NULL,
+		format VARCHAR(20) NULL,
+		user VARCHAR(50) NULL,
+		created DATETIME NULL
+	);
+`
+
+const schemaWebhookDeliveries = `
+	CREATE TABLE IF NOT EXISTS webhook_deliveries (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		webhook_id INTEGER NOT NULL,
+		action VARCHAR(20) NOT NULL,
+		repository VARCHAR(255) NOT NULL,
+		tag VARCHAR(255) NOT NULL,
+		attempt INTEGER NOT NULL,
+		status INTEGER NOT NULL,
+		error VARCHAR(1000) NULL,
+		created DATETIME NULL
+	);
+`
+
+// WebhookDeliveriesLimit deliveries kept per webhook, the older ones are deleted on insert.
+const WebhookDeliveriesLimit = 200
+
+// Webhook URL notified of the events of the matching repos.
+type Webhook struct {
+	ID int `json:"id"`
+	// Repos pattern of the repo path, a trailing * matches by prefix.
+	Repos string `json:"repos"`
+	URL   string `json:"url"`
+	// Secret signs the payload, empty if not signed.
+	Secret string `json:"-"`
+	// Actions event actions to notify of, e.g. push, delete.
+	Actions []string `json:"actions"`
+	// Format of the message, e.g. slack, empty for the raw JSON.
+	Format  string `json:"format"`
+	User    string `json:"user"`
+	Created string `json:"created"`
+}
+
+// Matches whether the webhook wants the action on the repo.
+func (w Webhook) Matches(action, repo string) bool {
+	if !(RepoOwner{Repos: w.Repos}).Matches(repo) {
+		return false
+	}
+	for _, a := range w.Actions {
+		if a == action {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookDelivery attempt to deliver the event to the webhook, Status is 0 when no response was received.
+type WebhookDelivery struct {
+	ID         int    `json:"id"`
+	WebhookID  int    `json:"webhook_id"`
+	Action     string `json:"action"`
+	Repository string `json:"repository"`
+	Tag        string `json:"tag"`
+	Attempt    int    `json:"attempt"`
+	Status     int    `json:"status"`
+	Error      string `json:"error"`
+	Created    string `json:"created"`
+}
+
+// OK whether the webhook accepted the event.
+func (d WebhookDelivery) OK() bool {
+	return d.Status >= 200 && d.Status < 300
+}
+
+// AddWebhook store the webhook.
+func (e *EventListener) AddWebhook(w Webhook) error {
+	db, err := e.getDatabaseHandler()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	_, err = db.Exec("INSERT INTO webhooks(repos, url, secret, actions, format, user, created) VALUES(?,?,?,?,?,?,"+e.now()+")",
+		w.Repos, w.URL, w.Secret, strings.Join(w.Actions, ","), w.Format, w.User)
+	if err != nil {
+		return fmt.Errorf("Error inserting a row: %s", err)
+	}
+	return nil
+}
+
+// DeleteWebhook delete the webhook with its deliveries.
+func (e *EventListener) DeleteWebhook(id int) error {
+	db, err := e.getDatabaseHandler()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	for _, query := range []string{"DELETE FROM webhook_deliveries WHERE webhook_id=?", "DELETE FROM webhooks WHERE id=?"} {
+		if _, err := db.Exec(query, id); err != nil {
+			return fmt.Errorf("Error deleting a row: %s", err)
+		}
+	}
+	return nil
+}
+
+// GetWebhooks retrieve all webhooks, the latest first.
+func (e *EventListener) GetWebhooks() []Webhook {
+	var list []Webhook
+	db, err := e.getDatabaseHandler()
+	if err != nil {
+		e.logger.Error(err)
+		return list
+	}
+	defer db.Close()
+
+	rows, err := db.Query("SELECT id, repos, url, secret, actions, format, user, created FROM webhooks ORDER BY id DESC")
+	if err != nil {
+		e.logger.Error("Error selecting from table: ", err)
+		return list
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var w Webhook
+		var actions string
+		var secret, format, user, created sql.NullString
+		rows.Scan(&w.ID, &w.Repos, &w.URL, &secret, &actions, &format, &user, &created)
+		w.Secret, w.Format, w.User, w.Created = secret.String, format.String, user.String, created.String
+		w.Actions = strings.Split(actions, ",")
+		list = append(list, w)
+	}
+	return list
+}
+
+// AddWebhookDelivery log the delivery attempt, only the latest deliveries of the webhook are kept.
+func (e *EventListener) AddWebhookDelivery(d WebhookDelivery) error {
+	db, err := e.getDatabaseHandler()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	_, err = db.Exec("INSERT INTO webhook_deliveries(webhook_id, action, repository, tag, attempt, status, error, created) VALUES(?,?,?,?,?,?,?,"+e.now()+")",
+		d.WebhookID, d.Action, d.Repository, d.Tag, d.Attempt, d.Status, d.Error)
+	if err != nil {
+		return fmt.Errorf("Error inserting a row: %s", err)
+	}
+	// MySQL does not allow LIMIT in the subquery of the same table, so look up the oldest ID to keep first.
+	var oldest int
+	err = db.QueryRow("SELECT id FROM webhook_deliveries WHERE webhook_id=? ORDER BY id DESC LIMIT 1 OFFSET ?",
+		d.WebhookID, WebhookDeliveriesLimit-1).Scan(&oldest)
+	if err == nil {
+		_, err = db.Exec("DELETE FROM webhook_deliveries WHERE webhook_id=? AND id<?", d.WebhookID, oldest)
+	}
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("Error deleting a row: %s", err)
+	}
+	return nil
+}
+
+// GetWebhookDeliveries retrieve the deliveries of the webhook, the latest first.
+func (e *EventListener) GetWebhookDeliveries(id int) []WebhookDelivery {
+	var list []WebhookDelivery
+	db, err := e.getDatabaseHandler()
+	if err != nil {
+		e.logger.Error(err)
+		return list
+	}
+	defer db.Close()
+
+	rows, err := db.Query("SELECT id, webhook_id, action, repository, tag, attempt, status, error, created FROM webhook_deliveries WHERE webhook_id=? ORDER BY id DESC", id)
+	if err != nil {
+		e.logger.Error("Error selecting from table: ", err)
+		return list
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var d WebhookDelivery
+		var errorText, created sql.NullString
+		rows.Scan(&d.ID, &d.WebhookID, &d.Action, &d.Repository, &d.Tag, &d.Attempt, &d.Status, &errorText, &created)
+		d.Error, d.Created = errorText.String, created.String
+		list = append(list, d)
+	}
+	return list
+}
@@ -0,0 +1,70 @@
+package events
+
+import (
+	"database/sql"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestWebhooks(t *testing.T) {
+	e := newTestListener(t)
+
+	convey.Convey("Add, list and delete webhooks with their deliveries", t, func() {
+		convey.So(e.AddWebhook(Webhook{Repos: "team/*", URL: "https://ci.local/hook", Secret: "s3cret", Actions: []string{"push", "delete"}, User: "alice"}), convey.ShouldBeNil)
+		convey.So(e.AddWebhook(Webhook{Repos: "alpine", URL: "https://hooks.slack.com/services/x", Actions: []string{"pull"}, Format: "slack"}), convey.ShouldBeNil)
+
+		list := e.GetWebhooks()
+		convey.So(len(list), convey.ShouldEqual, 2)
+		convey.So(list[0].Repos, convey.ShouldEqual, "alpine")
+		convey.So(list[0].Format, convey.ShouldEqual, "slack")
+		convey.So(list[1].Format, convey.ShouldEqual, "")
+		convey.So(list[1].Secret, convey.ShouldEqual, "s3cret")
+		convey.So(list[1].Matches("push", "team/app"), convey.ShouldBeTrue)
+		convey.So(list[1].Matches("pull", "team/app"), convey.ShouldBeFalse)
+		convey.So(list[1].Matches("push", "alpine"), convey.ShouldBeFalse)
+
+		for i := 0; i < WebhookDeliveriesLimit+5; i++ {
+			convey.So(e.AddWebhookDelivery(WebhookDelivery{WebhookID: list[1].ID, Action: "push", Repository: "team/app", Tag: "v1", Attempt: 1, Status: 500, Error: "500 Internal Server Error"}), convey.ShouldBeNil)
+		}
+		convey.So(e.AddWebhookDelivery(WebhookDelivery{WebhookID: list[1].ID, Action: "push", Repository: "team/app", Tag: "v1", Attempt: 2, Status: 204}), convey.ShouldBeNil)
+		deliveries := e.GetWebhookDeliveries(list[1].ID)
+		convey.So(len(deliveries), convey.ShouldEqual, WebhookDeliveriesLimit)
+		convey.So(deliveries[0].OK(), convey.ShouldBeTrue)
+		convey.So(deliveries[1].Error, convey.ShouldEqual, "500 Internal Server Error")
+		convey.So(e.GetWebhookDeliveries(list[0].ID), convey.ShouldBeEmpty)
+
+		convey.So(e.DeleteWebhook(list[1].ID), convey.ShouldBeNil)
+		convey.So(len(e.GetWebhooks()), convey.ShouldEqual, 1)
+		convey.So(e.GetWebhookDeliveries(list[1].ID), convey.ShouldBeEmpty)
+	})
+
+	convey.Convey("Add the format column to the webhooks table created without it", t, func() {
+		path := filepath.Join(t.TempDir(), "old.db")
+		db, _ := sql.Open("sqlite3", path)
+		_, err := db.Exec(schemaSQLite + strings.Replace(schemaWebhooks, "format VARCHAR(20) NULL,", "", 1))
+		db.Close()
+		convey.So(err, convey.ShouldBeNil)
+
+		old := NewEventListener("sqlite3", path, 7, true)
+		convey.So(old.AddWebhook(Webhook{Repos: "*", URL: "https://ci.local/hook", Actions: []string{"push"}, Format: "teams"}), convey.ShouldBeNil)
+		convey.So(old.GetWebhooks()[0].Format, convey.ShouldEqual, "teams")
+	})
+
+	convey.Convey("Return the stored registry events for notification", t, func() {
+		body := `{"events": [
+			{"action": "push", "target": {"repository": "team/app", "tag": "v2", "digest": "sha256:abc"}, "request": {"addr": "10.0.0.1:5000"}, "actor": {"name": "ci"}},
+			{"action": "push", "target": {"repository": "team/app", "digest": "sha256:layer"}, "request": {"addr": "10.0.0.1:5000"}},
+			{"action": "delete", "target": {"repository": "team/app", "tag": "v1"}, "request": {"addr": "10.0.0.2:5000", "useragent": "docker-registry-ui"}}
+		]}`
+		r, _ := http.NewRequest("POST", "/api/events", strings.NewReader(body))
+		var list []Event
+		e.ProcessEvents(r, "", func(stored []Event) { list = append(list, stored...) })
+		convey.So(len(list), convey.ShouldEqual, 2)
+		convey.So(list[0], convey.ShouldResemble, Event{Action: "push", Repository: "team/app", Tag: "v2", Digest: "sha256:abc", IP: "10.0.0.1", User: "ci"})
+		convey.So(list[1].Tag, convey.ShouldEqual, "")
+	})
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// featureFlag subsystem which can be turned on or off as a whole by the features setting.
+type featureFlag struct {
+	Name  string
+	Title string
+	// Requires settings the subsystem needs, empty if it needs none.
+	Requires string
+	// configured whether the required settings are set, the feature is on by default then.
+	configured func(c *configData) bool
+}
+
+// featureFlags subsystems in the order shown on Options page.
+var featureFlags = []featureFlag{
+	{"events", "Event listener, event log and pull counts", "", func(c *configData) bool { return true }},
+	{"deletion", "Deleting tags from UI", "", func(c *configData) bool { return true }},
+	{"purging", "Scheduled purging of old tags", "purge_tags_schedule", func(c *configData) bool {
+		return c.PurgeTagsSchedule != ""
+	}},
+	{"scanning", "Vulnerability scanning", "scanner", func(c *configData) bool { return c.Scanner != "" }},
+	{"signing", "Signing images with cosign", "signing_key or signing_identity_token_file", func(c *configData) bool {
+		return c.SigningKey != "" || c.SigningTokenFile != ""
+	}},
+	{"policy", "Policy checks", "policy_*", func(c *configData) bool {
+		return c.PolicyRequireSignature || c.PolicyRequireScan || c.PolicyMaxSeverity != "" ||
+			c.PolicyMaxSizeMB > 0 || len(c.PolicyAllowedBaseImages) > 0
+	}},
+	{"storage", "Storage usage", "storage_driver", func(c *configData) bool { return c.StorageDriver != "" }},
+	{"gc", "Registry garbage collection", "gc_command or gc_url", func(c *configData) bool {
+		return c.GCCommand != "" || c.GCURL != ""
+	}},
+	{"cache", "Pull-through cache statistics", "proxy_remote_url", func(c *configData) bool { return c.ProxyRemoteURL != "" }},
+	{"layers", "Layer content preview", "layer_files_max_size_mb", func(c *configData) bool { return c.LayerFilesMaxSizeMB > 0 }},
+	{"files", "File search across image layers", "file_index_max_files", func(c *configData) bool { return c.FileIndexMaxFiles > 0 }},
+	{"conventions", "Tag naming conventions report", "tag_conventions", func(c *configData) bool { return len(c.TagConventions) > 0 }},
+	{"secrets", "Secrets scanning of image config and small layers", "secret_scan_repos", func(c *configData) bool { return len(c.SecretScanRepos) > 0 }},
+	{"stale", "Stale repositories report", "stale_repo_days", func(c *configData) bool { return c.StaleRepoDays > 0 }},
+	{"transfer", "Image transfer as OCI layout tarballs", "transfer_dir", func(c *configData) bool { return c.TransferDir != "" }},
+	{"blobs", "Blob reference explorer", "blob_graph_repos", func(c *configData) bool { return len(c.BlobGraphRepos) > 0 }},
+	{"kubernetes", "Images in use by Kubernetes clusters", "kubernetes_clusters", func(c *configData) bool {
+		return len(c.KubernetesClusters) > 0
+	}},
+}
+
+// featureStatus state of the feature shown on Options page.
+type featureStatus struct {
+	featureFlag
+	Enabled bool
+	Reason  string
+}
+
+// feature whether the subsystem is on: as set by the features setting, otherwise when it is configured.
+func (c *configData) feature(name string) bool {
+	for _, f := range featureFlags {
+		if f.Name == name {
+			if on, ok := c.Features[name]; ok {
+				return on && f.configured(c)
+			}
+			return f.configured(c)
+		}
+	}
+	return false
+}
+
+// validateFeatures check the features setting names known features and enables only configured ones.
+func (c *configData) validateFeatures() []error {
+	var errs []error
+	var names []string
+	for name := range c.Features {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		on := c.Features[name]
+		known := false
+		for _, f := range featureFlags {
+			if f.Name != name {
+				continue
+			}
+			known = true
+			if on && !f.configured(c) {
+				errs = append(errs, fmt.Errorf("features: %s requires %s to be set", name, f.Requires))
+			}
+		}
+		if !known {
+			var all []string
+			for _, f := range featureFlags {
+				all = append(all, f.Name)
+			}
+			errs = append(errs, fmt.Errorf("features: unknown feature %q, should be one of %s", name, strings.Join(all, ", ")))
+		}
+	}
+	return errs
+}
+
+// featureStatuses state of all features with the reason.
+func (c *configData) featureStatuses() []featureStatus {
+	var list []featureStatus
+	for _, f := range featureFlags {
+		s := featureStatus{featureFlag: f, Enabled: c.feature(f.Name)}
+		if on, ok := c.Features[f.Name]; ok {
+			s.Reason = fmt.Sprintf("set to %t by features setting", on)
+		} else if f.configured(c) {
+			s.Reason = "on by default"
+		} else {
+			s.Reason = "requires " + f.Requires
+		}
+		list = append(list, s)
+	}
+	return list
+}
+
+// optionValue effective setting shown on Options page.
+type optionValue struct {
+	Group   string
+	Key     string
+	Value   string
+	Default bool
+}
+
+// isSecretOption whether the setting value should not be shown.
+func isSecretOption(key string) bool {
+	for _, s := range []string{"password", "token", "secret", "access_key"} {
+		if strings.Contains(key, s) && !strings.HasSuffix(key, "_file") {
+			return true
+		}
+	}
+	return false
+}
+
+// optionValues effective settings by the options schema, secrets are masked.
+func (c *configData) optionValues() []optionValue {
+	fields := map[string]reflect.Value{}
+	v := reflect.ValueOf(*c)
+	for i := 0; i < v.NumField(); i++ {
+		fields[strings.Split(v.Type().Field(i).Tag.Get("yaml"), ",")[0]] = v.Field(i)
+	}
+	var list []optionValue
+	for _, g := range configOptions {
+		for _, o := range g.Options {
+			field, ok := fields[o.Key]
+			if !ok {
+				continue
+			}
+			value := field.Interface()
+			ov := optionValue{Group: g.Title, Key: o.Key, Value: fmt.Sprint(value), Default: reflect.DeepEqual(value, o.Default)}
+			if isSecretOption(o.Key) && ov.Value != "" {
+				ov.Value = "******"
+			}
+			list = append(list, ov)
+		}
+	}
+	return list
+}
+
+// viewOptions view feature flags and effective settings.
+func (a *apiClient) viewOptions(c echo.Context) error {
+	data := a.setUserPermissions(c)
+	data.Set("features", a.config.featureStatuses())
+	data.Set("options", a.config.optionValues())
+	data.Set("eventDatabaseDriver", a.config.EventDatabaseDriver)
+	return c.Render(http.StatusOK, "options.html", data)
+}
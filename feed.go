@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// atomFeed Atom feed of the tags appeared in a repository.
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Link    []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+}
+
+type atomEntry struct {
+	ID      string   `xml:"id"`
+	Title   string   `xml:"title"`
+	Updated string   `xml:"updated"`
+	Link    atomLink `xml:"link"`
+	Summary string   `xml:"summary"`
+}
+
+// recordNewTags record the tags appeared since the previous tags refresh for the feeds,
+// the pushed ones are recorded by the event listener right away.
+func (a *apiClient) recordNewTags(repo string, tags []string) {
+	if err := a.eventListener.AddNewTags(repo, tags, "refresh"); err != nil {
+		a.logger.Errorf("Cannot record new tags of %s: %s", repo, err)
+	}
+}
+
+// feedTime time of the database row in the Atom format, the database returns it in UTC.
+func feedTime(created string) string {
+	for _, layout := range []string{"2006-01-02 15:04:05", time.RFC3339} {
+		if t, err := time.Parse(layout, created); err == nil {
+			return t.UTC().Format(time.RFC3339)
+		}
+	}
+	return time.Now().UTC().Format(time.RFC3339)
+}
+
+// apiFeed Atom feed of the tags appeared in the repository, e.g. to watch for new versions of base images.
+func (a *apiClient) apiFeed(c echo.Context) error {
+	repo := strings.Trim(c.QueryParam("repository"), "/")
+	if repo == "" {
+		return apiError(c, http.StatusBadRequest, fmt.Errorf("repository is required"))
+	}
+	base := c.Scheme() + "://" + c.Request().Host + a.config.BasePath
+	repoURL := base + "/" + repoURLPath(repo)
+	image := a.config.imageName(repo)
+
+	feed := atomFeed{
+		ID:    repoURL,
+		Title: "New tags of " + image,
+		Link: []atomLink{
+			{Href: repoURL},
+			{Href: c.Scheme() + "://" + c.Request().Host + c.Request().URL.RequestURI(), Rel: "self"},
+		},
+		Entries: []atomEntry{},
+	}
+	list := a.eventListener.GetNewTags(repo)
+	for _, t := range list {
+		how := "pushed"
+		if t.Source != "push" {
+			how = "noticed by the tags refresh"
+		}
+		summary := fmt.Sprintf("%s:%s %s", image, t.Tag, how)
+		if t.Digest != "" {
+			summary = summary + ", digest " + t.Digest
+		}
+		feed.Entries = append(feed.Entries, atomEntry{
+			ID:      fmt.Sprintf("%s/%s#%d", repoURL, t.Tag, t.ID),
+			Title:   image + ":" + t.Tag,
+			Updated: feedTime(t.Created),
+			Link:    atomLink{Href: repoURL + "/" + t.Tag},
+			Summary: summary,
+		})
+	}
+	feed.Updated = time.Now().UTC().Format(time.RFC3339)
+	if len(feed.Entries) > 0 {
+		feed.Updated = feed.Entries[0].Updated
+	}
+
+	body, err := xml.MarshalIndent(feed, "", "  ")
+	if err != nil {
+		return apiError(c, http.StatusInternalServerError, err)
+	}
+	return c.Blob(http.StatusOK, "application/atom+xml; charset=utf-8", append([]byte(xml.Header), body...))
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/quiq/docker-registry-ui/events"
+	"github.com/quiq/docker-registry-ui/registry"
+)
+
+// fileSearchLimit how many files to show on the file search page.
+const fileSearchLimit = 500
+
+type apiFilesResponse struct {
+	Results []events.FileMatch    `json:"results"`
+	Index   events.FileIndexStats `json:"index"`
+}
+
+// fileIndexed whether the files of the repo images are indexed: by the repo setting, otherwise by file_index_repos.
+func (a *apiClient) fileIndexed(repo string) bool {
+	if v := a.eventListener.GetSettings(repo)[settingFileIndex].Value; v != "" {
+		return v == "true"
+	}
+	return repoPatternsMatch(a.config.FileIndexRepos, repo)
+}
+
+// repoPatternsMatch the repo path matches any of the patterns, a trailing * matches by prefix.
+func repoPatternsMatch(patterns []string, repo string) bool {
+	for _, p := range patterns {
+		if p == repo || strings.HasSuffix(p, "*") && strings.HasPrefix(repo, strings.TrimSuffix(p, "*")) {
+			return true
+		}
+	}
+	return false
+}
+
+// imageLayers digests of the layers of the tag, of all platforms for multi-arch images. Foreign layers are skipped.
+func (a *apiClient) imageLayers(repo, tag string) ([]string, error) {
+	manifest, _, _, err := a.client.GetManifest(repo, tag)
+	if err != nil {
+		return nil, err
+	}
+	manifests := []string{manifest}
+	for _, m := range registry.IndexImages(manifest) {
+		sub, _, _, err := a.client.GetManifest(repo, m)
+		if err != nil {
+			return nil, err
+		}
+		manifests = append(manifests, sub)
+	}
+	var layers []string
+	for _, m := range manifests {
+		for _, l := range registry.Layers(m) {
+			if !l.Foreign {
+				layers = append(layers, l.Digest)
+			}
+		}
+	}
+	return layers, nil
+}
+
+// indexFiles index the files of the layers of all tags of the opted-in repos, the layers are immutable
+// so only the new ones are read. New layers are skipped once the index holds file_index_max_files files.
+func (a *apiClient) indexFiles(t *backgroundTask) (string, error) {
+	var repos []string
+	for namespace, names := range a.client.Repositories(true) {
+		for _, name := range names {
+			repo := name
+			if namespace != "library" {
+				repo = namespace + "/" + name
+			}
+			if a.fileIndexed(repo) {
+				repos = append(repos, repo)
+			}
+		}
+	}
+	indexed, err := a.eventListener.GetIndexedLayers()
+	if err != nil {
+		return "", err
+	}
+	stats, err := a.eventListener.GetFileIndexStats()
+	if err != nil {
+		return "", err
+	}
+	files := stats.Files
+	added, skipped, unsupported, failed := 0, 0, 0, 0
+	for i, repo := range repos {
+		t.progress(i, len(repos))
+		layers := map[string][]string{}
+		for _, tag := range a.client.Tags(repo) {
+			list, err := a.imageLayers(repo, tag)
+			if err != nil {
+				a.logger.Warnf("Cannot get layers of %s:%s for the file index: %s", repo, tag, err)
+				failed++
+				continue
+			}
+			layers[tag] = list
+			for _, layer := range list {
+				if indexed[layer] {
+					continue
+				}
+				if files >= a.config.FileIndexMaxFiles {
+					skipped++
+					continue
+				}
+				layerFiles, truncated, err := a.client.LayerFiles(repo, layer, int64(a.config.LayerFilesMaxSizeMB)<<20, a.config.LayerFilesMaxEntries)
+				// Layers cut at the size limit are indexed with the files read.
+				if errors.Is(err, registry.ErrUnsupportedLayer) {
+					// Indexed without files not to read it again.
+					a.logger.Infof("Layer %s of %s is not indexed: %s", layer, repo, err)
+					unsupported++
+				} else if err != nil && len(layerFiles) == 0 {
+					a.logger.Warnf("Cannot list files of layer %s of %s for the file index: %s", layer, repo, err)
+					failed++
+					continue
+				}
+				entries := make([]events.IndexedFile, 0, len(layerFiles))
+				for _, f := range layerFiles {
+					if !f.Dir {
+						entries = append(entries, events.IndexedFile{Path: f.Path, Size: f.Size})
+					}
+				}
+				if err := a.eventListener.AddLayerFiles(layer, entries, truncated); err != nil {
+					return "", err
+				}
+				indexed[layer] = true
+				files += len(entries)
+				if err == nil {
+					added++
+				}
+			}
+		}
+		if err := a.eventListener.SetImageLayers(repo, layers); err != nil {
+			return "", err
+		}
+	}
+	if err := a.eventListener.PruneFileIndex(repos); err != nil {
+		return "", err
+	}
+	result := fmt.Sprintf("%d repos, %d new layers", len(repos), added)
+	if skipped > 0 {
+		result += fmt.Sprintf(", %d layers skipped as file_index_max_files is reached", skipped)
+	}
+	if unsupported > 0 {
+		result += fmt.Sprintf(", %d unsupported", unsupported)
+	}
+	if failed > 0 {
+		result += fmt.Sprintf(", %d failed", failed)
+	}
+	return result, nil
+}
+
+// viewFiles search file paths in the indexed images, e.g. which images contain log4j*.jar.
+func (a *apiClient) viewFiles(c echo.Context) error {
+	if !a.config.feature("files") {
+		return c.String(http.StatusNotFound, "File search is disabled, see file_index_max_files.")
+	}
+	query := strings.TrimSpace(c.QueryParam("q"))
+	data := a.setUserPermissions(c)
+	data.Set("query", query)
+	results := []events.FileMatch{}
+	if query != "" {
+		var err error
+		if results, err = a.eventListener.SearchFiles(query, fileSearchLimit); err != nil {
+			a.log(c).Error(err)
+		}
+	}
+	resultURLs := make([]string, len(results))
+	for i, r := range results {
+		resultURLs[i] = repoURLPath(r.Repository)
+	}
+	stats, _ := a.eventListener.GetFileIndexStats()
+	data.Set("results", results)
+	data.Set("resultURLs", resultURLs)
+	data.Set("limited", len(results) == fileSearchLimit)
+	data.Set("stats", stats)
+	return c.Render(http.StatusOK, "files.html", data)
+}
+
+// apiFiles search file paths in the indexed images.
+func (a *apiClient) apiFiles(c echo.Context) error {
+	if !a.config.feature("files") {
+		return apiError(c, http.StatusNotFound, fmt.Errorf("file search is disabled"))
+	}
+	limit, err := apiLimit(c)
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, err)
+	}
+	path := strings.TrimSpace(c.QueryParam("path"))
+	if path == "" {
+		return apiError(c, http.StatusBadRequest, fmt.Errorf("path is required"))
+	}
+	results, err := a.eventListener.SearchFiles(path, limit)
+	if err != nil {
+		return apiError(c, http.StatusInternalServerError, err)
+	}
+	stats, _ := a.eventListener.GetFileIndexStats()
+	return c.JSON(http.StatusOK, apiFilesResponse{results, stats})
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// gcConfigured whether there is a way to trigger the registry garbage collection.
+func (a *apiClient) gcConfigured() bool {
+	return a.config.feature("gc")
+}
+
+// startGC start the registry garbage collection as a job, it waits for maintenance window.
+// Returns nil if the collection is already waiting or running.
+func (a *apiClient) startGC(user string) *job {
+	if !atomic.CompareAndSwapInt32(&a.collecting, 0, 1) {
+		a.logger.Warn("Garbage collection is still waiting or running, skipping this one.")
+		return nil
+	}
+	return a.jobs.start("Registry garbage collection", user, func(j *job) error {
+		defer atomic.StoreInt32(&a.collecting, 0)
+		j.waitForMaintenance(a.maintenance)
+		if a.config.GCCommand != "" {
+			if err := a.runGCCommand(j); err != nil {
+				return err
+			}
+		}
+		if a.config.GCURL != "" {
+			if err := a.callGCURL(j); err != nil {
+				return err
+			}
+		}
+		j.logf("Done")
+		return nil
+	})
+}
+
+// runGCCommand run the configured shell command capturing its output into the job, it is killed after gc_timeout.
+func (a *apiClient) runGCCommand(j *job) error {
+	j.logf("Running: %s", a.config.GCCommand)
+	timeout := time.Duration(a.config.GCTimeout) * time.Minute
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	// The command writes to the pipe directly, so waiting for it does not wait for the processes it started.
+	r, w, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	cmd := exec.CommandContext(ctx, "sh", "-c", a.config.GCCommand)
+	cmd.Stdout, cmd.Stderr = w, w
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		scanner := bufio.NewScanner(r)
+		for scanner.Scan() {
+			j.logf("%s", scanner.Text())
+		}
+	}()
+	err = cmd.Start()
+	w.Close()
+	if err == nil {
+		err = cmd.Wait()
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		// The processes started by the killed shell may still hold the pipe open.
+		r.Close()
+		<-done
+		return fmt.Errorf("command timed out after %s", timeout)
+	}
+	<-done
+	if err != nil {
+		return fmt.Errorf("command failed: %s", err)
+	}
+	return nil
+}
+
+// callGCURL call the garbage collection API, e.g. of Harbor.
+func (a *apiClient) callGCURL(j *job) error {
+	j.logf("Calling %s %s", a.config.GCURLMethod, a.config.GCURL)
+	req, err := http.NewRequest(a.config.GCURLMethod, a.config.GCURL, strings.NewReader(a.config.GCURLBody))
+	if err != nil {
+		return err
+	}
+	if a.config.GCURLBody != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if a.config.GCURLUsername != "" {
+		req.SetBasicAuth(a.config.GCURLUsername, a.config.GCURLPassword)
+	}
+	resp, err := (&http.Client{Timeout: time.Duration(a.config.GCTimeout) * time.Minute}).Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	j.logf("Response: %s", strings.TrimSpace(resp.Status+" "+string(body)))
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("garbage collection API returned %s", resp.Status)
+	}
+	return nil
+}
+
+// gcAfterDeletions trigger garbage collection after bulk deletions if enabled.
+func (a *apiClient) gcAfterDeletions(j *job) {
+	if !a.config.GCAfterDeletions || !a.gcConfigured() {
+		return
+	}
+	if gc := a.startGC(j.User); gc != nil {
+		j.logf("Started garbage collection job #%d", gc.ID)
+	}
+}
+
+// runGC start the registry garbage collection from UI.
+func (a *apiClient) runGC(c echo.Context) error {
+	if !a.gcConfigured() {
+		return c.String(http.StatusBadRequest, "Garbage collection is not configured.")
+	}
+	a.trackAction(c, "gc")
+	j := a.startGC(a.setUserPermissions(c)["user"].String())
+	if j == nil {
+		return c.String(http.StatusConflict, "Garbage collection is already waiting or running.")
+	}
+	return c.Redirect(http.StatusSeeOther, fmt.Sprintf("%s/jobs/%d", a.config.BasePath, j.ID))
+}
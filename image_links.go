@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/quiq/docker-registry-ui/registry"
+)
+
+// imageLinkPlaceholder {name} or {name:key} in the URL template of the image link.
+var imageLinkPlaceholder = regexp.MustCompile(`\{([a-z_]+)(?::([^{}]+))?\}`)
+
+// imageLinkNames placeholders of the URL templates, label and annotation take the key after colon.
+var imageLinkNames = []string{"repo", "namespace", "tag", "digest", "source", "source_repo", "revision", "label", "annotation"}
+
+// imageLink button on the image page linking to an external system, e.g. in-house Git or CI.
+type imageLink struct {
+	Title string `yaml:"title"`
+	// URL template with placeholders, e.g. https://git.internal/{source_repo}/commit/{revision}
+	URL string `yaml:"url"`
+	// Repos repo path the link is shown for, a trailing * matches by prefix, empty matches all.
+	Repos string `yaml:"repos"`
+}
+
+// renderedLink image link with the placeholders filled in.
+type renderedLink struct {
+	Title string
+	URL   string
+}
+
+// validate check the URL template has known placeholders only.
+func (l imageLink) validate() error {
+	if l.Title == "" {
+		return fmt.Errorf("title should be set")
+	}
+	// The whole URL can come from a label, it is checked when rendered then.
+	u, err := url.Parse(imageLinkPlaceholder.ReplaceAllString(l.URL, "x"))
+	if err != nil || (!strings.HasPrefix(l.URL, "{") && u.Scheme != "http" && u.Scheme != "https") {
+		return fmt.Errorf("url should be http or https URL, got %q", l.URL)
+	}
+	for _, m := range imageLinkPlaceholder.FindAllStringSubmatch(l.URL, -1) {
+		if !registry.ItemInSlice(m[1], imageLinkNames) {
+			return fmt.Errorf("unknown placeholder %s, known ones are %s", m[0], strings.Join(imageLinkNames, ", "))
+		}
+		if (m[1] == "label" || m[1] == "annotation") && m[2] == "" {
+			return fmt.Errorf("placeholder %s should have the key, e.g. {%s:org.opencontainers.image.url}", m[0], m[1])
+		}
+	}
+	return nil
+}
+
+// sourceRepo path of the source repo on its Git server, e.g. acme/app of https://github.com/acme/app.git
+func sourceRepo(source string) string {
+	u, err := url.Parse(strings.TrimPrefix(source, "git+"))
+	if err != nil || u.Host == "" {
+		return ""
+	}
+	return strings.TrimSuffix(strings.Trim(u.Path, "/"), ".git")
+}
+
+// render fill in the placeholders, the link is not shown if any of them is unknown for the image.
+func (l imageLink) render(repo, tag string, meta registry.TagMeta, build registry.BuildInfo) (renderedLink, bool) {
+	values := map[string]string{
+		"repo":        repo,
+		"namespace":   strings.SplitN(repo, "/", 2)[0],
+		"tag":         url.PathEscape(tag),
+		"digest":      meta.Digest,
+		"source":      build.Source,
+		"source_repo": sourceRepo(build.Source),
+		"revision":    url.PathEscape(build.Commit),
+	}
+	ok := true
+	link := imageLinkPlaceholder.ReplaceAllStringFunc(l.URL, func(p string) string {
+		m := imageLinkPlaceholder.FindStringSubmatch(p)
+		value := values[m[1]]
+		switch m[1] {
+		case "label":
+			value = meta.Labels[m[2]]
+		case "annotation":
+			value = meta.Annotations[m[2]]
+		}
+		if (m[1] == "label" || m[1] == "annotation") && p != l.URL {
+			value = url.PathEscape(value)
+		}
+		if value == "" {
+			ok = false
+		}
+		return value
+	})
+	return renderedLink{Title: l.Title, URL: link}, ok && webURL(link) != ""
+}
+
+// imageLinks configured links of the image with all placeholders known.
+func (a *apiClient) imageLinks(repo, tag string, meta registry.TagMeta, build registry.BuildInfo) []renderedLink {
+	var links []renderedLink
+	for _, l := range a.config.ImageLinks {
+		if l.Repos != "" && !repoPatternsMatch([]string{l.Repos}, repo) {
+			continue
+		}
+		if link, ok := l.render(repo, tag, meta, build); ok {
+			links = append(links, link)
+		}
+	}
+	return links
+}
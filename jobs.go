@@ -0,0 +1,224 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/CloudyKit/jet"
+	"github.com/labstack/echo/v4"
+	"github.com/quiq/docker-registry-ui/registry"
+	"github.com/sirupsen/logrus"
+)
+
+// Job status.
+const (
+	jobRunning = "running"
+	jobWaiting = "waiting"
+	jobDone    = "done"
+	jobFailed  = "failed"
+)
+
+// maxJobs how many finished jobs to keep for the jobs page.
+const maxJobs = 100
+
+// job long running operation started from UI, its progress is shown on the jobs page.
+type job struct {
+	ID       int
+	Name     string
+	User     string
+	Started  time.Time
+	Finished time.Time
+	Status   string
+	Done     int
+	Total    int
+	Output   []string
+	mux      sync.Mutex
+}
+
+// jobInfo copy of the job data safe to pass to templates.
+type jobInfo struct {
+	ID       int       `json:"id"`
+	Name     string    `json:"name"`
+	User     string    `json:"user"`
+	Started  time.Time `json:"started"`
+	Finished time.Time `json:"finished"`
+	Status   string    `json:"status"`
+	Done     int       `json:"done"`
+	Total    int       `json:"total"`
+	Output   []string  `json:"-"`
+}
+
+// Percent progress of the job.
+func (j jobInfo) Percent() int {
+	if j.Total == 0 {
+		if j.Status == jobRunning || j.Status == jobWaiting {
+			return 0
+		}
+		return 100
+	}
+	return j.Done * 100 / j.Total
+}
+
+// logf add line to the job output.
+func (j *job) logf(format string, args ...interface{}) {
+	j.mux.Lock()
+	defer j.mux.Unlock()
+	j.Output = append(j.Output, fmt.Sprintf("%s %s", time.Now().Format("15:04:05"), fmt.Sprintf(format, args...)))
+}
+
+// progress set the count of processed items out of total.
+func (j *job) progress(done, total int) {
+	j.mux.Lock()
+	defer j.mux.Unlock()
+	j.Done = done
+	j.Total = total
+}
+
+// waitForMaintenance put the job on hold until maintenance window opens.
+func (j *job) waitForMaintenance(w *maintenanceWindow) {
+	if w.isOpen(time.Now()) {
+		return
+	}
+	j.mux.Lock()
+	j.Status = jobWaiting
+	j.mux.Unlock()
+	w.wait(j.logf)
+	j.mux.Lock()
+	j.Status = jobRunning
+	j.mux.Unlock()
+}
+
+func (j *job) info() jobInfo {
+	j.mux.Lock()
+	defer j.mux.Unlock()
+	return jobInfo{
+		ID: j.ID, Name: j.Name, User: j.User, Started: j.Started, Finished: j.Finished,
+		Status: j.Status, Done: j.Done, Total: j.Total, Output: append([]string{}, j.Output...),
+	}
+}
+
+// jobList jobs started since the last restart.
+type jobList struct {
+	mux    sync.Mutex
+	jobs   []*job
+	lastID int
+	logger *logrus.Entry
+}
+
+// start run the function in background as a new job.
+func (l *jobList) start(name, user string, fn func(j *job) error) *job {
+	l.mux.Lock()
+	if l.logger == nil {
+		l.logger = registry.SetupLogging("main.jobs")
+	}
+	logger := l.logger
+	l.lastID++
+	j := &job{ID: l.lastID, Name: name, User: user, Started: time.Now(), Status: jobRunning}
+	l.jobs = append(l.jobs, j)
+	if len(l.jobs) > maxJobs {
+		l.jobs = l.jobs[len(l.jobs)-maxJobs:]
+	}
+	l.mux.Unlock()
+
+	go func() {
+		err := fn(j)
+		if err != nil {
+			j.logf("Error: %s", err)
+			logger.WithField("user", user).Errorf("Job %q failed: %s", name, err)
+		}
+		j.mux.Lock()
+		defer j.mux.Unlock()
+		j.Finished = time.Now()
+		j.Status = jobDone
+		if err != nil {
+			j.Status = jobFailed
+		}
+	}()
+	return j
+}
+
+// get job by id.
+func (l *jobList) get(id int) (jobInfo, bool) {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	for _, j := range l.jobs {
+		if j.ID == id {
+			return j.info(), true
+		}
+	}
+	return jobInfo{}, false
+}
+
+// list jobs, the latest first.
+func (l *jobList) list() []jobInfo {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	list := make([]jobInfo, 0, len(l.jobs))
+	for i := len(l.jobs) - 1; i >= 0; i-- {
+		list = append(list, l.jobs[i].info())
+	}
+	return list
+}
+
+// visibleJobs jobs the user can see, admins see all of them and the others only the jobs they started,
+// as the names and output tell the repos out of the tenant scope of the user.
+func visibleJobs(data jet.VarMap, jobs []jobInfo) []jobInfo {
+	if data["isAdmin"].Bool() {
+		return jobs
+	}
+	list := []jobInfo{}
+	for _, j := range jobs {
+		if j.User == data["user"].String() {
+			list = append(list, j)
+		}
+	}
+	return list
+}
+
+// viewJobs view jobs started from UI, the background tasks are shown to admins.
+func (a *apiClient) viewJobs(c echo.Context) error {
+	data := a.setUserPermissions(c)
+	data.Set("jobs", visibleJobs(data, a.jobs.list()))
+	data.Set("tasks", []taskInfo{})
+	if data["isAdmin"].Bool() {
+		data.Set("tasks", a.tasks.list())
+	}
+	data.Set("gcConfigured", a.gcConfigured())
+	gcStatus, err := a.client.GCStatus()
+	data.Set("gcStatus", gcStatus)
+	data.Set("gcStatusKnown", err == nil)
+	if err != nil && err != registry.ErrNotSupported {
+		a.log(c).Warnf("Cannot get garbage collection status: %s", err)
+	}
+	data.Set("maintenanceWindow", a.maintenance != nil)
+	if a.maintenance != nil {
+		data.Set("maintenanceOpen", a.maintenance.isOpen(time.Now()))
+		data.Set("maintenanceNext", a.maintenance.nextOpen(time.Now()).Format("2006-01-02 15:04:05"))
+	}
+	return c.Render(http.StatusOK, "jobs.html", data)
+}
+
+// viewJob view job progress and output.
+func (a *apiClient) viewJob(c echo.Context) error {
+	id, _ := strconv.Atoi(c.Param("id"))
+	j, ok := a.jobs.get(id)
+	data := a.setUserPermissions(c)
+	if !ok || len(visibleJobs(data, []jobInfo{j})) == 0 {
+		return c.Redirect(http.StatusSeeOther, a.config.BasePath+"/jobs")
+	}
+	data.Set("job", j)
+	return c.Render(http.StatusOK, "job.html", data)
+}
+
+// apiJobs status of the background tasks and the jobs started from UI, the same as shown to the user on the jobs page.
+func (a *apiClient) apiJobs(c echo.Context) error {
+	data := a.setUserPermissions(c)
+	res := apiJobsResponse{Background: []taskInfo{}, Jobs: visibleJobs(data, a.jobs.list())}
+	if data["isAdmin"].Bool() {
+		res.Background = a.tasks.list()
+	}
+	return c.JSON(http.StatusOK, res)
+}
@@ -0,0 +1,257 @@
+// Package kubernetes looks up the images of the pods running in Kubernetes clusters,
+// to show which tags are in use and to keep them from being deleted.
+package kubernetes
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tidwall/gjson"
+)
+
+// Service account files mounted into pods, used when the cluster server is not set.
+const (
+	inClusterTokenFile = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+	inClusterCAFile    = "/var/run/secrets/kubernetes.io/serviceaccount/ca.crt"
+)
+
+// requestTimeout how long to wait for the pod list.
+const requestTimeout = 30 * time.Second
+
+// Cluster Kubernetes API server, the token needs to be allowed to list pods in all namespaces.
+type Cluster struct {
+	Name string `yaml:"name"`
+	// Server URL of the API server, empty to use the cluster the UI runs in with its service account.
+	Server string `yaml:"server"`
+	Token  string `yaml:"token"`
+	// TokenFile is re-read on every lookup, so the token can be rotated.
+	TokenFile          string `yaml:"token_file"`
+	CAFile             string `yaml:"ca_file"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify"`
+}
+
+// String name and server of the cluster without the credentials.
+func (c Cluster) String() string {
+	if c.Server == "" {
+		return c.Name + " (in-cluster)"
+	}
+	return fmt.Sprintf("%s (%s)", c.Name, c.Server)
+}
+
+// Container image of a running pod.
+type Container struct {
+	Namespace string
+	Pod       string
+	Image     string
+	// Digest of the manifest the image was pulled by, empty if the runtime does not report it.
+	Digest string
+}
+
+// resolve fill in the in-cluster server and service account files if the server is not set.
+func (c Cluster) resolve() Cluster {
+	if c.Server == "" {
+		c.Server = "https://" + net.JoinHostPort(os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT"))
+		if c.Token == "" && c.TokenFile == "" {
+			c.TokenFile = inClusterTokenFile
+		}
+		if c.CAFile == "" {
+			c.CAFile = inClusterCAFile
+		}
+	}
+	return c
+}
+
+// Containers list containers of the pods running or starting in all namespaces.
+func (c Cluster) Containers() ([]Container, error) {
+	c = c.resolve()
+	token := c.Token
+	if c.TokenFile != "" {
+		data, err := ioutil.ReadFile(c.TokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read token: %s", err)
+		}
+		token = strings.TrimSpace(string(data))
+	}
+	tlsConfig := &tls.Config{InsecureSkipVerify: c.InsecureSkipVerify}
+	if c.CAFile != "" {
+		data, err := ioutil.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read CA certificate: %s", err)
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("no certificates found in %s", c.CAFile)
+		}
+	}
+	client := &http.Client{Timeout: requestTimeout, Transport: &http.Transport{TLSClientConfig: tlsConfig}}
+
+	req, err := http.NewRequest("GET", strings.TrimRight(c.Server, "/")+"/api/v1/pods", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("cannot list pods: %s: %s", resp.Status, gjson.GetBytes(data, "message").String())
+	}
+	return parsePods(data), nil
+}
+
+// parsePods containers of the pods not finished yet from the pod list.
+func parsePods(data []byte) []Container {
+	var list []Container
+	for _, pod := range gjson.GetBytes(data, "items").Array() {
+		phase := pod.Get("status.phase").String()
+		if phase == "Succeeded" || phase == "Failed" {
+			continue
+		}
+		namespace, name := pod.Get("metadata.namespace").String(), pod.Get("metadata.name").String()
+		// Statuses have the digest the image was pulled by, the spec only has the image as given.
+		reported := map[string]bool{}
+		for _, s := range append(pod.Get("status.initContainerStatuses").Array(), pod.Get("status.containerStatuses").Array()...) {
+			list = append(list, Container{Namespace: namespace, Pod: name, Image: s.Get("image").String(), Digest: imageDigest(s.Get("imageID").String())})
+			reported[s.Get("name").String()] = true
+		}
+		for _, s := range append(pod.Get("spec.initContainers").Array(), pod.Get("spec.containers").Array()...) {
+			if !reported[s.Get("name").String()] {
+				list = append(list, Container{Namespace: namespace, Pod: name, Image: s.Get("image").String()})
+			}
+		}
+	}
+	return list
+}
+
+// imageDigest manifest digest from the image ID reported by the container runtime,
+// e.g. docker-pullable://registry.local/app@sha256:..., the bare image config ID is not a manifest digest.
+func imageDigest(imageID string) string {
+	if i := strings.LastIndex(imageID, "@"); i >= 0 && strings.HasPrefix(imageID[i+1:], "sha256:") {
+		return imageID[i+1:]
+	}
+	return ""
+}
+
+// normalizeImage image reference with the tag, latest if not given, the digest part is dropped
+// as the digest reported by the runtime is used instead.
+func normalizeImage(image string) string {
+	if i := strings.Index(image, "@"); i >= 0 {
+		if !strings.Contains(image[strings.LastIndex(image[:i], "/")+1:i], ":") {
+			return ""
+		}
+		image = image[:i]
+	}
+	if image != "" && !strings.Contains(image[strings.LastIndex(image, "/")+1:], ":") {
+		image = image + ":latest"
+	}
+	return image
+}
+
+// Use of the image in a cluster.
+type Use struct {
+	Cluster string
+	Pods    int
+}
+
+func (u Use) String() string {
+	if u.Pods == 1 {
+		return fmt.Sprintf("%s (1 pod)", u.Cluster)
+	}
+	return fmt.Sprintf("%s (%d pods)", u.Cluster, u.Pods)
+}
+
+// Usage images in use by the pods of the clusters.
+type Usage struct {
+	// pods by cluster by digest or image reference.
+	pods map[string]map[string]map[string]bool
+	// Errors of the clusters which could not be looked up, the images used there are unknown.
+	Errors  map[string]string
+	Updated time.Time
+}
+
+// Collect look up the containers in all clusters concurrently.
+func Collect(clusters []Cluster) *Usage {
+	u := &Usage{pods: map[string]map[string]map[string]bool{}, Errors: map[string]string{}, Updated: time.Now()}
+	var mux sync.Mutex
+	var wg sync.WaitGroup
+	for _, c := range clusters {
+		wg.Add(1)
+		go func(c Cluster) {
+			defer wg.Done()
+			containers, err := c.Containers()
+			mux.Lock()
+			defer mux.Unlock()
+			if err != nil {
+				u.Errors[c.Name] = err.Error()
+				return
+			}
+			u.add(c.Name, containers)
+		}(c)
+	}
+	wg.Wait()
+	return u
+}
+
+// add count the pods by the image digests and references.
+func (u *Usage) add(cluster string, containers []Container) {
+	for _, c := range containers {
+		pod := c.Namespace + "/" + c.Pod
+		for _, key := range []string{c.Digest, normalizeImage(c.Image)} {
+			if key == "" {
+				continue
+			}
+			if u.pods[key] == nil {
+				u.pods[key] = map[string]map[string]bool{}
+			}
+			if u.pods[key][cluster] == nil {
+				u.pods[key][cluster] = map[string]bool{}
+			}
+			u.pods[key][cluster][pod] = true
+		}
+	}
+}
+
+// Lookup clusters running the image by its reference, e.g. registry.local/app:v1, or by its digest.
+func (u *Usage) Lookup(image, digest string) []Use {
+	if u == nil {
+		return nil
+	}
+	pods := map[string]map[string]bool{}
+	for _, key := range []string{normalizeImage(image), digest} {
+		if key == "" {
+			continue
+		}
+		for cluster, list := range u.pods[key] {
+			if pods[cluster] == nil {
+				pods[cluster] = map[string]bool{}
+			}
+			for pod := range list {
+				pods[cluster][pod] = true
+			}
+		}
+	}
+	var uses []Use
+	for cluster, list := range pods {
+		uses = append(uses, Use{Cluster: cluster, Pods: len(list)})
+	}
+	sort.Slice(uses, func(i, j int) bool { return uses[i].Cluster < uses[j].Cluster })
+	return uses
+}
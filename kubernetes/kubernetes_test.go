@@ -0,0 +1,68 @@
+package kubernetes
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+const podList = `{"items": [
+	{"metadata": {"namespace": "web", "name": "app-1"}, "status": {"phase": "Running",
+		"containerStatuses": [{"name": "app", "image": "registry.local/team/app:v1", "imageID": "docker-pullable://registry.local/team/app@sha256:aaa"}]}},
+	{"metadata": {"namespace": "web", "name": "app-2"}, "status": {"phase": "Running",
+		"containerStatuses": [{"name": "app", "image": "registry.local/team/app:v1", "imageID": "containerd://sha256:config"}]}},
+	{"metadata": {"namespace": "jobs", "name": "migrate"}, "status": {"phase": "Succeeded",
+		"containerStatuses": [{"name": "migrate", "image": "registry.local/team/tools:v2", "imageID": "docker-pullable://registry.local/team/tools@sha256:bbb"}]}},
+	{"metadata": {"namespace": "jobs", "name": "pending"},
+		"spec": {"containers": [{"name": "worker", "image": "registry.local/team/worker"}]}, "status": {"phase": "Pending"}}
+]}`
+
+func TestUsage(t *testing.T) {
+	// The clusters are looked up concurrently, so only the requests of the working one are recorded.
+	var auth string
+	var mux sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/pods" {
+			http.NotFound(w, r)
+			return
+		}
+		mux.Lock()
+		auth = r.Header.Get("Authorization")
+		mux.Unlock()
+		fmt.Fprint(w, podList)
+	}))
+	defer server.Close()
+
+	convey.Convey("Look up images of running pods", t, func() {
+		usage := Collect([]Cluster{
+			{Name: "prod", Server: server.URL, Token: "secret"},
+			{Name: "broken", Server: server.URL + "/missing"},
+		})
+		mux.Lock()
+		convey.So(auth, convey.ShouldEqual, "Bearer secret")
+		mux.Unlock()
+		convey.So(usage.Errors["broken"], convey.ShouldContainSubstring, "404")
+
+		uses := usage.Lookup("registry.local/team/app:v1", "sha256:aaa")
+		convey.So(uses, convey.ShouldResemble, []Use{{Cluster: "prod", Pods: 2}})
+		convey.So(uses[0].String(), convey.ShouldEqual, "prod (2 pods)")
+		convey.So(usage.Lookup("registry.local/team/app:v2", "sha256:aaa"), convey.ShouldHaveLength, 1)
+		convey.So(usage.Lookup("registry.local/team/tools:v2", "sha256:bbb"), convey.ShouldBeEmpty)
+		convey.So(usage.Lookup("registry.local/team/worker:latest", ""), convey.ShouldResemble, []Use{{Cluster: "prod", Pods: 1}})
+
+		var none *Usage
+		convey.So(none.Lookup("registry.local/team/app:v1", ""), convey.ShouldBeEmpty)
+	})
+
+	convey.Convey("Normalize image references", t, func() {
+		convey.So(normalizeImage("registry.local:5000/app"), convey.ShouldEqual, "registry.local:5000/app:latest")
+		convey.So(normalizeImage("registry.local/app:v1@sha256:aaa"), convey.ShouldEqual, "registry.local/app:v1")
+		convey.So(normalizeImage("registry.local/app@sha256:aaa"), convey.ShouldEqual, "")
+		convey.So(imageDigest("docker-pullable://registry.local/app@sha256:aaa"), convey.ShouldEqual, "sha256:aaa")
+		convey.So(imageDigest("sha256:config"), convey.ShouldEqual, "")
+	})
+}
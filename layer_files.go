@@ -0,0 +1,214 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/labstack/echo/v4"
+	"github.com/quiq/docker-registry-ui/registry"
+)
+
+// layerListingsLimit listings of the recently viewed layers kept to browse them without streaming again.
+const layerListingsLimit = 50
+
+var digestRegexp = regexp.MustCompile(`^sha256:[a-f0-9]{64}$`)
+
+// layerListing entries of the layer tarball, the layers are immutable by digest.
+type layerListing struct {
+	files     []registry.LayerFile
+	truncated bool
+}
+
+// layerListings the recently listed layers by digest, the oldest one is dropped first.
+type layerListings struct {
+	mux   sync.Mutex
+	items map[string]layerListing
+	order []string
+}
+
+// layerEntry file or directory shown in the browsed directory of the layer.
+type layerEntry struct {
+	Name string
+	registry.LayerFile
+	// Files count of the files under the directory.
+	Files int
+}
+
+// layerListing entries of the layer from cache or by streaming its tarball.
+func (a *apiClient) layerListing(repo, digest string) (layerListing, error) {
+	a.layers.mux.Lock()
+	l, ok := a.layers.items[digest]
+	a.layers.mux.Unlock()
+	if ok {
+		return l, nil
+	}
+	files, truncated, err := a.client.LayerFiles(repo, digest, int64(a.config.LayerFilesMaxSizeMB)<<20, a.config.LayerFilesMaxEntries)
+	if err != nil && len(files) == 0 {
+		return l, err
+	}
+	l = layerListing{files: files, truncated: truncated}
+	a.layers.mux.Lock()
+	defer a.layers.mux.Unlock()
+	if a.layers.items == nil {
+		a.layers.items = map[string]layerListing{}
+	}
+	if _, ok := a.layers.items[digest]; !ok {
+		a.layers.order = append(a.layers.order, digest)
+	}
+	a.layers.items[digest] = l
+	if len(a.layers.order) > layerListingsLimit {
+		delete(a.layers.items, a.layers.order[0])
+		a.layers.order = a.layers.order[1:]
+	}
+	return l, nil
+}
+
+// mergedFiles effective file system of the image applying the whiteouts of its layers, the platform image has to be
+// chosen for multi-arch images. Returns the files, the count of layers and whether any of them is truncated.
+func (a *apiClient) mergedFiles(repo, tag string) ([]registry.LayerFile, int, bool, error) {
+	manifest, _, _, err := a.client.GetManifest(repo, tag)
+	if err != nil {
+		return nil, 0, false, err
+	}
+	if len(registry.IndexImages(manifest)) > 0 {
+		return nil, 0, false, fmt.Errorf("%s is a multi-arch image, choose the platform image", tag)
+	}
+	var layers [][]registry.LayerFile
+	truncated := false
+	for _, layer := range registry.Layers(manifest) {
+		if layer.Foreign {
+			continue
+		}
+		l, err := a.layerListing(repo, layer.Digest)
+		if err != nil {
+			return nil, 0, false, fmt.Errorf("layer %s: %s", layer.Digest, err)
+		}
+		layers = append(layers, l.files)
+		truncated = truncated || l.truncated
+	}
+	return registry.MergeLayers(layers), len(layers), truncated, nil
+}
+
+// layerDirectory entries right under the directory, sub-directories first with the total size of their files.
+// Directories missing in the tarball are shown by the paths of their files.
+func layerDirectory(files []registry.LayerFile, dir string) []layerEntry {
+	prefix := ""
+	if dir != "" {
+		prefix = dir + "/"
+	}
+	entries := map[string]*layerEntry{}
+	for _, f := range files {
+		if !strings.HasPrefix(f.Path, prefix) || f.Path == dir {
+			continue
+		}
+		rest := strings.TrimPrefix(f.Path, prefix)
+		name := rest
+		nested := false
+		if i := strings.Index(rest, "/"); i >= 0 {
+			name, nested = rest[:i], true
+		}
+		e, ok := entries[name]
+		if !ok {
+			e = &layerEntry{Name: name, LayerFile: registry.LayerFile{Path: prefix + name, Dir: nested}}
+			entries[name] = e
+		}
+		switch {
+		case nested && !f.Dir:
+			e.Files++
+			e.Size += f.Size
+		case !nested && f.Dir:
+			// Keep the totals of the files listed before the directory.
+			e.Mode, e.Dir, e.Layer = f.Mode, true, f.Layer
+		case !nested:
+			e.LayerFile = f
+		}
+	}
+
+	list := make([]layerEntry, 0, len(entries))
+	for _, e := range entries {
+		list = append(list, *e)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Dir != list[j].Dir {
+			return list[i].Dir
+		}
+		return list[i].Name < list[j].Name
+	})
+	return list
+}
+
+// viewLayerFiles browse the files of the layer or without digest the merged file system of the tag,
+// admins only as the layers have to be streamed from the registry.
+func (a *apiClient) viewLayerFiles(c echo.Context) error {
+	if !a.config.feature("layers") {
+		return c.String(http.StatusNotFound, "Layer content preview is disabled, see layer_files_max_size_mb.")
+	}
+	repo := strings.Trim(c.QueryParam("repository"), "/")
+	tag := c.QueryParam("tag")
+	digest := c.QueryParam("digest")
+	merged := digest == ""
+	if !registry.ValidRepoName(repo) || (merged && tag == "") || (!merged && !digestRegexp.MatchString(digest)) {
+		return c.String(http.StatusBadRequest, "Invalid repository, tag or layer digest.")
+	}
+	dir := strings.Trim(c.QueryParam("path"), "/")
+	query := url.Values{"repository": {repo}, "tag": {tag}}
+	title := "Merged file system"
+	if !merged {
+		query.Set("digest", digest)
+		title = "Layer " + digest[7:19]
+	}
+
+	data := a.setUserPermissions(c)
+	data.Set("repoPath", repo)
+	data.Set("repoURL", repoURLPath(repo))
+	data.Set("tag", tag)
+	data.Set("digest", digest)
+	data.Set("merged", merged)
+	data.Set("title", title)
+	data.Set("layerQuery", query.Encode())
+	data.Set("dir", dir)
+	var crumbs, crumbPaths []string
+	if dir != "" {
+		crumbs = strings.Split(dir, "/")
+		for i := range crumbs {
+			crumbPaths = append(crumbPaths, strings.Join(crumbs[:i+1], "/"))
+		}
+	}
+	data.Set("crumbs", crumbs)
+	data.Set("crumbPaths", crumbPaths)
+
+	var files []registry.LayerFile
+	var layersCount int
+	var truncated bool
+	var err error
+	if merged {
+		files, layersCount, truncated, err = a.mergedFiles(repo, tag)
+	} else {
+		var l layerListing
+		l, err = a.layerListing(repo, digest)
+		files, truncated = l.files, l.truncated
+	}
+	if err != nil {
+		a.log(c).Warnf("Cannot list files of %s:%s %s: %s", repo, tag, digest, err)
+		data.Set("listError", fmt.Sprintf("Cannot list the files: %s", err))
+	} else {
+		data.Set("listError", "")
+	}
+	var size int64
+	for _, f := range files {
+		size += f.Size
+	}
+	data.Set("entries", layerDirectory(files, dir))
+	data.Set("filesCount", len(files))
+	data.Set("filesSize", size)
+	data.Set("layersCount", layersCount)
+	data.Set("truncated", truncated)
+	data.Set("maxEntries", a.config.LayerFilesMaxEntries)
+	a.trackAction(c, "layer-files")
+	return c.Render(http.StatusOK, "layer_files.html", data)
+}
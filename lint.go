@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/quiq/docker-registry-ui/lint"
+	"github.com/quiq/docker-registry-ui/registry"
+	"github.com/tidwall/gjson"
+)
+
+type apiLintResponse struct {
+	Results []lint.Report `json:"results"`
+}
+
+// lintRules lint rules from the config.
+func (a *apiClient) lintRules() lint.Rules {
+	return lint.Rules{
+		Severity:            a.config.LintRules,
+		MaxLayerSize:        int64(a.config.LintMaxLayerMB) << 20,
+		RequiredAnnotations: a.config.LintRequiredAnnotations,
+	}
+}
+
+// digestTags tags pointing to the digest.
+func digestTags(metas []registry.TagMeta, digest string) []string {
+	var tags []string
+	for _, m := range metas {
+		if m.Digest == digest && digest != "" {
+			tags = append(tags, m.Tag)
+		}
+	}
+	return tags
+}
+
+// lintImage lint the image of the tag, every platform of multi-arch image. Tags are the ones pointing to its digest.
+func (a *apiClient) lintImage(meta registry.TagMeta, tags []string) (lint.Report, error) {
+	report := lint.Report{Repo: meta.Repo, Tag: meta.Tag, Digest: meta.Digest, Findings: []lint.Finding{}}
+	manifest, _, _, err := a.client.GetManifest(meta.Repo, meta.Tag)
+	if err != nil {
+		return report, err
+	}
+	platforms := map[string]string{"": manifest}
+	if index := gjson.Get(manifest, "manifests"); index.Exists() {
+		platforms = map[string]string{}
+		for _, m := range index.Array() {
+			if m.Get(`annotations.vnd\.docker\.reference\.type`).String() == "attestation-manifest" {
+				continue
+			}
+			sub, _, _, err := a.client.GetManifest(meta.Repo, m.Get("digest").String())
+			if err != nil {
+				return report, err
+			}
+			platforms[registry.PlatformString(m.Get("platform"))] = sub
+		}
+	}
+
+	rules := a.lintRules()
+	for _, platform := range registry.SortedMapKeys(platforms) {
+		m := platforms[platform]
+		var config string
+		if d := gjson.Get(m, "config.digest").String(); d != "" {
+			if config, err = a.client.GetBlob(meta.Repo, d); err != nil {
+				return report, err
+			}
+		}
+		img := lint.Image{
+			Platform:    platform,
+			OS:          gjson.Get(config, "os").String(),
+			Created:     gjson.Get(config, "created").Time(),
+			User:        gjson.Get(config, "config.User").String(),
+			Tags:        tags,
+			Annotations: meta.Annotations,
+			Labels:      meta.Labels,
+		}
+		for _, e := range gjson.Get(config, "config.Env").Array() {
+			img.Env = append(img.Env, e.String())
+		}
+		for _, l := range registry.Layers(m) {
+			img.Layers = append(img.Layers, l.Size)
+		}
+		report.Add(rules.Evaluate(img))
+	}
+	return report, nil
+}
+
+// apiLint lint report of the tag or all tags of the repository, e.g. for CI.
+func (a *apiClient) apiLint(c echo.Context) error {
+	repo := strings.Trim(c.QueryParam("repository"), "/")
+	if repo == "" {
+		return apiError(c, http.StatusBadRequest, fmt.Errorf("repository is required"))
+	}
+	metas := a.client.TagsMetadata(repo, a.client.Tags(repo))
+	tag := c.QueryParam("tag")
+
+	results := []lint.Report{}
+	for _, meta := range metas {
+		if tag != "" && meta.Tag != tag {
+			continue
+		}
+		report, err := a.lintImage(meta, digestTags(metas, meta.Digest))
+		if err != nil {
+			return apiError(c, http.StatusInternalServerError, err)
+		}
+		results = append(results, report)
+	}
+	if tag != "" && len(results) == 0 {
+		return apiError(c, http.StatusNotFound, fmt.Errorf("cannot find image %s:%s", repo, tag))
+	}
+	return c.JSON(http.StatusOK, apiLintResponse{results})
+}
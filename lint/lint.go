@@ -0,0 +1,190 @@
+package lint
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Severities of the findings from the worst one, off disables the rule.
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+	SeverityInfo    = "info"
+	SeverityOff     = "off"
+)
+
+// Severities in the order from the worst one.
+var Severities = []string{SeverityError, SeverityWarning, SeverityInfo, SeverityOff}
+
+// Names of the rules.
+const (
+	RuleCreated     = "created"
+	RuleLargeLayer  = "large_layer"
+	RuleSecretEnv   = "secret_env"
+	RuleRootUser    = "root_user"
+	RuleLatestOnly  = "latest_only"
+	RuleAnnotations = "annotations"
+)
+
+// DefaultSeverity severity of the rules unless set otherwise.
+var DefaultSeverity = map[string]string{
+	RuleCreated:     SeverityWarning,
+	RuleLargeLayer:  SeverityWarning,
+	RuleSecretEnv:   SeverityError,
+	RuleRootUser:    SeverityWarning,
+	RuleLatestOnly:  SeverityInfo,
+	RuleAnnotations: SeverityInfo,
+}
+
+var (
+	secretNameRegexp = regexp.MustCompile(`(?i)(PASSW(OR)?D|SECRET|TOKEN|API_?KEY|PRIVATE_?KEY|ACCESS_?KEY|CREDENTIALS?)`)
+	// Values referring to the secret rather than holding it, e.g. a file path, also of Windows, or a variable.
+	secretRefRegexp = regexp.MustCompile(`^(/|\$|\.{0,2}/|[A-Za-z]:\\|%)|^(true|false|[0-9]+)$|_FILE$`)
+)
+
+// Rules of the lint with the severities, MaxLayerSize in bytes.
+type Rules struct {
+	Severity            map[string]string
+	MaxLayerSize        int64
+	RequiredAnnotations []string
+}
+
+// Image facts about the image of a single platform to lint.
+type Image struct {
+	Platform string
+	// OS of the image config, e.g. linux or windows.
+	OS      string
+	Created time.Time
+	// Layers sizes of the layers.
+	Layers []int64
+	Env    []string
+	User   string
+	// Tags of the repo pointing to the image digest.
+	Tags        []string
+	Annotations map[string]string
+	Labels      map[string]string
+}
+
+// Finding issue found in the image.
+type Finding struct {
+	Rule     string `json:"rule"`
+	Severity string `json:"severity"`
+	Platform string `json:"platform,omitempty"`
+	Message  string `json:"message"`
+}
+
+// Report of the image, findings of all platforms sorted by severity.
+type Report struct {
+	Repo     string    `json:"repository"`
+	Tag      string    `json:"tag"`
+	Digest   string    `json:"digest"`
+	Findings []Finding `json:"findings"`
+}
+
+// severity of the rule, the rules not set keep the default.
+func (r Rules) severity(rule string) string {
+	if s, ok := r.Severity[rule]; ok {
+		return s
+	}
+	return DefaultSeverity[rule]
+}
+
+// Evaluate lint the image, the rules with severity off are skipped.
+func (r Rules) Evaluate(img Image) []Finding {
+	var list []Finding
+	add := func(rule, message string) {
+		if s := r.severity(rule); s != SeverityOff {
+			list = append(list, Finding{Rule: rule, Severity: s, Platform: img.Platform, Message: message})
+		}
+	}
+
+	if img.Created.IsZero() || img.Created.Unix() <= 0 {
+		add(RuleCreated, "Created date is missing or set to the epoch")
+	}
+	if r.MaxLayerSize > 0 {
+		for i, size := range img.Layers {
+			if size > r.MaxLayerSize {
+				add(RuleLargeLayer, fmt.Sprintf("Layer %d is %d MB, more than %d MB", i+1, size>>20, r.MaxLayerSize>>20))
+			}
+		}
+	}
+	for _, env := range img.Env {
+		parts := strings.SplitN(env, "=", 2)
+		if len(parts) < 2 || parts[1] == "" {
+			continue
+		}
+		if len(FindSecrets(parts[1])) > 0 ||
+			secretNameRegexp.MatchString(parts[0]) && !secretRefRegexp.MatchString(parts[1]) && !secretRefRegexp.MatchString(parts[0]) {
+			add(RuleSecretEnv, fmt.Sprintf("ENV %s looks like a secret baked into the image", parts[0]))
+		}
+	}
+	user := strings.SplitN(img.User, ":", 2)[0]
+	switch {
+	case img.OS == "windows":
+		// Windows images run as ContainerUser or ContainerAdministrator, the default depends on the base image.
+		if strings.EqualFold(user, "ContainerAdministrator") {
+			add(RuleRootUser, "Image runs as ContainerAdministrator")
+		}
+	case user == "" || user == "root" || user == "0":
+		add(RuleRootUser, "Image runs as root")
+	}
+	if len(img.Tags) == 1 && img.Tags[0] == "latest" {
+		add(RuleLatestOnly, "Image is tagged only as latest, tag it by version too")
+	}
+	var missing []string
+	for _, name := range r.RequiredAnnotations {
+		if img.Annotations[name] == "" && img.Labels[name] == "" {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		add(RuleAnnotations, "Missing annotations "+strings.Join(missing, ", "))
+	}
+	return list
+}
+
+// Add the findings of the platform, the same finding of many platforms is shown once without the platform.
+func (rep *Report) Add(findings []Finding) {
+	for _, f := range findings {
+		merged := false
+		for i, e := range rep.Findings {
+			if e.Rule == f.Rule && e.Message == f.Message {
+				rep.Findings[i].Platform = ""
+				merged = true
+				break
+			}
+		}
+		if !merged {
+			rep.Findings = append(rep.Findings, f)
+		}
+	}
+	sort.SliceStable(rep.Findings, func(i, j int) bool {
+		return rank(rep.Findings[i].Severity) < rank(rep.Findings[j].Severity)
+	})
+}
+
+// Worst severity of the findings, empty when there are none.
+func (rep Report) Worst() string {
+	if len(rep.Findings) == 0 {
+		return ""
+	}
+	return rep.Findings[0].Severity
+}
+
+// ValidSeverity the severity is known.
+func ValidSeverity(s string) bool {
+	return rank(s) < len(Severities)
+}
+
+// rank position of the severity from the worst one.
+func rank(s string) int {
+	for i, v := range Severities {
+		if v == s {
+			return i
+		}
+	}
+	return len(Severities)
+}
@@ -0,0 +1,43 @@
+package lint
+
+import (
+	"regexp"
+)
+
+// secretPattern credential format recognized in the image config and files.
+type secretPattern struct {
+	Name   string
+	Regexp *regexp.Regexp
+}
+
+// secretPatterns formats of well known credentials, they are specific enough to have few false positives.
+var secretPatterns = []secretPattern{
+	{"AWS access key", regexp.MustCompile(`\b(AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{"AWS secret key", regexp.MustCompile(`(?i)aws_?secret_?access_?key["']?\s*[:=]\s*["']?[0-9A-Za-z/+]{40}\b`)},
+	{"GitHub token", regexp.MustCompile(`\bgh[pousr]_[0-9A-Za-z]{36}\b|\bgithub_pat_[0-9A-Za-z_]{82}\b`)},
+	{"GitLab token", regexp.MustCompile(`\bglpat-[0-9A-Za-z_-]{20}\b`)},
+	{"Slack token", regexp.MustCompile(`\bxox[abprs]-[0-9A-Za-z-]{10,}`)},
+	{"Google API key", regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{35}\b`)},
+	{"Stripe key", regexp.MustCompile(`\b[sr]k_live_[0-9A-Za-z]{24,}\b`)},
+	{"Private key", regexp.MustCompile(`-----BEGIN (RSA |EC |DSA |OPENSSH |PGP |ENCRYPTED )?PRIVATE KEY( BLOCK)?-----`)},
+}
+
+// SecretFinding credential found in the image, the value itself is not kept.
+type SecretFinding struct {
+	// Source where it is found, e.g. ENV DB_URL, label or the file path.
+	Source  string `json:"source"`
+	Pattern string `json:"pattern"`
+	// Layer number of the layer the file is in, 0 for the image config.
+	Layer int `json:"layer,omitempty"`
+}
+
+// FindSecrets names of the credential patterns found in the text.
+func FindSecrets(text string) []string {
+	var names []string
+	for _, p := range secretPatterns {
+		if p.Regexp.MatchString(text) {
+			names = append(names, p.Name)
+		}
+	}
+	return names
+}
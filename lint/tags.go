@@ -0,0 +1,69 @@
+package lint
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// tagPatterns built-in patterns of the tag naming conventions.
+var tagPatterns = map[string]string{
+	"semver": `^v?(0|[1-9][0-9]*)\.(0|[1-9][0-9]*)\.(0|[1-9][0-9]*)(-[0-9A-Za-z.-]+)?$`,
+	"date":   `^[0-9]{4}[.-]?[0-9]{2}[.-]?[0-9]{2}([._-][0-9A-Za-z._-]+)?$`,
+	"sha":    `^(sha-)?[0-9a-f]{7,40}$`,
+	"latest": `^latest$`,
+}
+
+// TagPatternNames names of the built-in patterns.
+var TagPatternNames = []string{"semver", "date", "sha", "latest"}
+
+// compiledPatterns regexps of the patterns compiled once.
+var compiledPatterns sync.Map
+
+// TagConvention expected patterns of the tags of the repos starting with the prefix, empty prefix is for all repos.
+type TagConvention struct {
+	Prefix   string   `yaml:"prefix"`
+	Patterns []string `yaml:"patterns"`
+}
+
+// Conventions tag naming conventions of the repos.
+type Conventions []TagConvention
+
+// TagPattern regexp of the built-in pattern, other patterns are regular expressions matching the whole tag.
+func TagPattern(pattern string) (*regexp.Regexp, error) {
+	if re, ok := compiledPatterns.Load(pattern); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	expr, ok := tagPatterns[pattern]
+	if !ok {
+		expr = "^(" + pattern + ")$"
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	compiledPatterns.Store(pattern, re)
+	return re, nil
+}
+
+// For convention of the repo, the one with the longest prefix wins.
+func (c Conventions) For(repo string) (TagConvention, bool) {
+	var found TagConvention
+	ok := false
+	for _, t := range c {
+		if strings.HasPrefix(repo, t.Prefix) && (!ok || len(t.Prefix) > len(found.Prefix)) {
+			found, ok = t, true
+		}
+	}
+	return found, ok
+}
+
+// Conforms the tag matches any pattern of the convention, invalid patterns match nothing.
+func (t TagConvention) Conforms(tag string) bool {
+	for _, p := range t.Patterns {
+		if re, err := TagPattern(p); err == nil && re.MatchString(tag) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,41 @@
+package lint
+
+import (
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestConventions(t *testing.T) {
+	conventions := Conventions{
+		{Prefix: "", Patterns: []string{"semver", "latest"}},
+		{Prefix: "team/", Patterns: []string{"date", "sha"}},
+		{Prefix: "team/legacy", Patterns: []string{`release-[0-9]+`}},
+	}
+
+	convey.Convey("Find the convention by the longest prefix", t, func() {
+		c, ok := conventions.For("team/app")
+		convey.So(ok, convey.ShouldBeTrue)
+		convey.So(c.Prefix, convey.ShouldEqual, "team/")
+		c, _ = conventions.For("team/legacy-api")
+		convey.So(c.Prefix, convey.ShouldEqual, "team/legacy")
+		c, _ = conventions.For("alpine")
+		convey.So(c.Prefix, convey.ShouldEqual, "")
+		_, ok = Conventions{{Prefix: "team/"}}.For("alpine")
+		convey.So(ok, convey.ShouldBeFalse)
+	})
+
+	convey.Convey("Match tags by the built-in patterns and regexps", t, func() {
+		for tag, want := range map[string]bool{"1.2.3": true, "v1.2.3-rc.1": true, "1.2": false, "01.2.3": false, "latest": true, "main": false} {
+			convey.So(conventions[0].Conforms(tag), convey.ShouldEqual, want)
+		}
+		for tag, want := range map[string]bool{"20240131": true, "2024-01-31-1": true, "3f2a9c1": true, "sha-3f2a9c1d": true, "1.2.3": false} {
+			convey.So(conventions[1].Conforms(tag), convey.ShouldEqual, want)
+		}
+		convey.So(conventions[2].Conforms("release-12"), convey.ShouldBeTrue)
+		convey.So(conventions[2].Conforms("release-12-hotfix"), convey.ShouldBeFalse)
+		_, err := TagPattern("release-(")
+		convey.So(err, convey.ShouldNotBeNil)
+		convey.So(TagConvention{Patterns: []string{"release-("}}.Conforms("release-("), convey.ShouldBeFalse)
+	})
+}
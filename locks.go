@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/quiq/docker-registry-ui/events"
+	"github.com/quiq/docker-registry-ui/registry"
+)
+
+// tagLock lock keeping the tag, also when it shares the digest with a locked tag as the deletion
+// removes all tags of the digest.
+func (a *apiClient) tagLock(repo, tag, digest string) (events.TagLock, bool) {
+	locks := a.eventListener.GetTagLocks(repo)
+	for _, l := range locks {
+		if l.Tag == tag {
+			return l, true
+		}
+	}
+	if digest == "" {
+		return events.TagLock{}, false
+	}
+	for _, l := range locks {
+		if meta, err := a.client.TagMetadata(repo, l.Tag); err == nil && meta.Digest == digest {
+			return l, true
+		}
+	}
+	return events.TagLock{}, false
+}
+
+// blockedTitles why the tags cannot be deleted by index of tagsMeta, empty for tags not blocked.
+// Tags sharing the digest with a blocked tag are blocked too as the deletion removes all tags of the digest,
+// shared is the title format for them with the blocked tag.
+func blockedTitles(tagsMeta []registry.TagMeta, why func(tag string) string, shared string) []string {
+	titles := make([]string, len(tagsMeta))
+	blockedDigests := map[string]string{}
+	for i, t := range tagsMeta {
+		titles[i] = why(t.Tag)
+		if titles[i] != "" && t.Digest != "" {
+			blockedDigests[t.Digest] = t.Tag
+		}
+	}
+	for i, t := range tagsMeta {
+		if blocked, ok := blockedDigests[t.Digest]; ok && titles[i] == "" {
+			titles[i] = fmt.Sprintf(shared, blocked)
+		}
+	}
+	return titles
+}
+
+// lockTitles why the tags are locked by index of tagsMeta, empty for tags not locked.
+func lockTitles(locks []events.TagLock, tagsMeta []registry.TagMeta) []string {
+	return blockedTitles(tagsMeta, func(tag string) string {
+		for _, l := range locks {
+			if l.Tag == tag {
+				return fmt.Sprintf("Locked by %s: %s", l.User, l.Reason)
+			}
+		}
+		return ""
+	}, "Shares the digest with locked tag %s")
+}
+
+// protectedTitles why the tags are protected by index of tagsMeta, empty for tags not protected.
+func protectedTitles(patterns []string, tagsMeta []registry.TagMeta) []string {
+	return blockedTitles(tagsMeta, func(tag string) string {
+		if p := protectedBy(patterns, tag); p != "" {
+			return "Protected by pattern " + p
+		}
+		return ""
+	}, "Shares the digest with protected tag %s")
+}
+
+// guardDeletion refuse deleting locked and protected tags and tags running in clusters from UI, API and purging alike.
+func (a *apiClient) guardDeletion(repo, tag, digest string) error {
+	if l, ok := a.tagLock(repo, tag, digest); ok {
+		if l.Tag != tag {
+			return fmt.Errorf("%w: it shares the digest with %s locked by %s", registry.ErrTagLocked, l.Tag, l.User)
+		}
+		return fmt.Errorf("%w by %s: %s", registry.ErrTagLocked, l.User, l.Reason)
+	}
+	if protected, pattern, ok := a.tagProtection(repo, tag, digest); ok {
+		if protected != tag {
+			return fmt.Errorf("%w: it shares the digest with %s protected by pattern %s", registry.ErrTagProtected, protected, pattern)
+		}
+		return fmt.Errorf("%w by pattern %s of the repo settings", registry.ErrTagProtected, pattern)
+	}
+	return a.guardClusterUsage(repo, tag, digest)
+}
+
+// lockTag lock the tag against deletion and purging until unlocked.
+func (a *apiClient) lockTag(c echo.Context) error {
+	data := a.setUserPermissions(c)
+	l := events.TagLock{
+		Repository: c.FormValue("repo"),
+		Tag:        c.FormValue("tag"),
+		Reason:     strings.TrimSpace(c.FormValue("reason")),
+		User:       data["user"].String(),
+	}
+	if !registry.ValidRepoName(l.Repository) || !registry.ValidTagName(l.Tag) {
+		return c.String(http.StatusBadRequest, "Repository and tag should be set.")
+	}
+	if l.Reason == "" {
+		return c.String(http.StatusBadRequest, "Reason for locking the tag is required.")
+	}
+	if existing, ok := a.tagLock(l.Repository, l.Tag, ""); ok {
+		return c.String(http.StatusConflict, fmt.Sprintf("Tag %s:%s is already locked by %s.", l.Repository, l.Tag, existing.User))
+	}
+	if err := a.eventListener.LockTag(l); err != nil {
+		a.log(c).Error(err)
+		return c.String(http.StatusInternalServerError, "Cannot lock tag, see the log for request "+requestIDOf(c)+".")
+	}
+	a.trackAction(c, "lock")
+	a.audit(c, "lock", l.Repository, l.Tag, l.Reason)
+	return c.Redirect(http.StatusSeeOther, fmt.Sprintf("%s/%s/%s", a.config.BasePath, repoURLPath(l.Repository), l.Tag))
+}
+
+// unlockTag remove the lock, only the user locked the tag or admins can do it.
+func (a *apiClient) unlockTag(c echo.Context) error {
+	data := a.setUserPermissions(c)
+	id, _ := strconv.Atoi(c.Param("id"))
+	for _, l := range a.eventListener.GetTagLocks("") {
+		if l.ID != id {
+			continue
+		}
+		if l.User != data["user"].String() && !data["isAdmin"].Bool() {
+			return a.forbidden(c, fmt.Sprintf("The tag is locked by %s, only they or admins can unlock it.", l.User))
+		}
+		if err := a.eventListener.UnlockTag(id); err != nil {
+			a.log(c).Error(err)
+			return c.String(http.StatusInternalServerError, "Cannot unlock tag, see the log for request "+requestIDOf(c)+".")
+		}
+		a.trackAction(c, "unlock")
+		a.audit(c, "unlock", l.Repository, l.Tag, fmt.Sprintf("Locked by %s: %s", l.User, l.Reason))
+		return c.Redirect(http.StatusSeeOther, fmt.Sprintf("%s/%s/%s", a.config.BasePath, repoURLPath(l.Repository), l.Tag))
+	}
+	return c.String(http.StatusNotFound, "Lock not found.")
+}
+
+// repoURLPath repo path as in the links on the pages: namespace, library for repos without one,
+// and the rest of the path escaped.
+func repoURLPath(repo string) string {
+	namespace := "library"
+	if i := strings.Index(repo, "/"); i >= 0 {
+		namespace, repo = repo[:i], repo[i+1:]
+	}
+	return namespace + "/" + url.QueryEscape(repo)
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+const sessionCookie = "registry_ui_session"
+
+// sessionAuth middleware authenticating the requests by the session cookie of the local user logged in by the form.
+// The user is passed on in user_header as if sent by the proxy, requests without a session are redirected to the form.
+// Requests authenticated by the trusted proxy, API tokens, event listener and metrics tokens are let through.
+func (a *apiClient) sessionAuth(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		req := c.Request()
+		if a.requestUser(c) != "" {
+			return next(c)
+		}
+		if cookie, err := c.Cookie(sessionCookie); err == nil {
+			if user, ok := a.eventListener.CheckSession(cookie.Value); ok {
+				req.Header.Set(a.config.UserHeader, user)
+				return next(c)
+			}
+		}
+
+		path := strings.TrimPrefix(req.URL.Path, a.config.BasePath)
+		switch {
+		case path == "/login", path == "/metrics" && a.config.Metrics, req.URL.Path == "/favicon.ico",
+			strings.HasPrefix(path, "/static/"), strings.HasPrefix(path, "/api/events"),
+			strings.HasPrefix(path, "/api/") && strings.HasPrefix(req.Header.Get("Authorization"), "Bearer "):
+			return next(c)
+		case a.wantsJSON(c):
+			return apiError(c, http.StatusUnauthorized, fmt.Errorf("login is required"))
+		}
+		return c.Redirect(http.StatusSeeOther, a.config.BasePath+"/login?next="+url.QueryEscape(req.URL.RequestURI()))
+	}
+}
+
+// viewLogin view the login form of the local users.
+func (a *apiClient) viewLogin(c echo.Context) error {
+	return a.renderLogin(c, http.StatusOK, "", a.loginNext(c.QueryParam("next")), "")
+}
+
+// renderLogin render the login form again with the error.
+func (a *apiClient) renderLogin(c echo.Context, code int, name, next, message string) error {
+	data := a.setUserPermissions(c)
+	data.Set("next", next)
+	data.Set("name", name)
+	data.Set("error", message)
+	return c.Render(code, "login.html", data)
+}
+
+// login check the password of the local user and start the session.
+// Attempts over the limit per client IP and of the locked out users are rejected without checking the password.
+func (a *apiClient) login(c echo.Context) error {
+	name, next := strings.TrimSpace(c.FormValue("name")), a.loginNext(c.FormValue("next"))
+	now := time.Now()
+	if !a.logins.allow(c.RealIP(), a.config.LoginAttemptsPerMinute, now) {
+		a.log(c).Warnf("Too many login attempts from %s, rejected login of user %q", c.RealIP(), name)
+		return a.renderLogin(c, http.StatusTooManyRequests, name, next, "Too many login attempts, try again in a minute.")
+	}
+	if until := a.logins.lockedUntil(name, now); !until.IsZero() {
+		a.log(c).Warnf("User %q is locked out until %s, rejected login from %s", name, until.Format("15:04:05"), c.RealIP())
+		return a.renderLogin(c, http.StatusTooManyRequests, name, next,
+			fmt.Sprintf("Too many failed logins, try again in %s.", until.Sub(now).Round(time.Second)))
+	}
+	if !a.eventListener.CheckUserPassword(name, c.FormValue("password")) {
+		a.log(c).Warnf("Failed login of user %q from %s", name, c.RealIP())
+		a.auditAs(name, c.RealIP(), "login-failed", "", "", "")
+		lockout := time.Duration(a.config.LoginLockoutMinutes) * time.Minute
+		if a.logins.failed(name, a.config.LoginMaxFailures, lockout, now) {
+			a.log(c).Warnf("User %q is locked out for %s after %d failed logins", name, lockout, a.config.LoginMaxFailures)
+			a.auditAs(name, c.RealIP(), "login-locked", "", "",
+				fmt.Sprintf("%d failed logins in a row, locked out for %s", a.config.LoginMaxFailures, lockout))
+		}
+		return a.renderLogin(c, http.StatusUnauthorized, name, next, "Invalid user name or password.")
+	}
+	a.logins.succeeded(name)
+
+	maxAge := time.Duration(a.config.SessionMaxAgeHours) * time.Hour
+	token, err := a.eventListener.CreateSession(name, maxAge)
+	if err != nil {
+		a.log(c).Error(err)
+		return c.String(http.StatusInternalServerError, "Cannot log in, see the log for request "+requestIDOf(c)+".")
+	}
+	a.log(c).Infof("User %s logged in from %s", name, c.RealIP())
+	a.auditAs(name, c.RealIP(), "login", "", "", "")
+	c.SetCookie(&http.Cookie{
+		Name:     sessionCookie,
+		Value:    token,
+		Path:     a.config.BasePath + "/",
+		MaxAge:   int(maxAge.Seconds()),
+		HttpOnly: true,
+		Secure:   c.IsTLS() || c.Request().Header.Get("X-Forwarded-Proto") == "https",
+		SameSite: http.SameSiteLaxMode,
+	})
+	return c.Redirect(http.StatusSeeOther, next)
+}
+
+// logout end the session of the local user.
+func (a *apiClient) logout(c echo.Context) error {
+	if cookie, err := c.Cookie(sessionCookie); err == nil {
+		if err := a.eventListener.DeleteSession(cookie.Value); err != nil {
+			a.log(c).Error(err)
+		}
+		a.auditAs(a.requestUser(c), c.RealIP(), "logout", "", "", "")
+	}
+	c.SetCookie(&http.Cookie{Name: sessionCookie, Path: a.config.BasePath + "/", MaxAge: -1, HttpOnly: true})
+	return c.Redirect(http.StatusSeeOther, a.config.BasePath+"/login")
+}
+
+// loginNext the page to return to after login, only the pages of UI are allowed so the form cannot redirect elsewhere.
+func (a *apiClient) loginNext(next string) string {
+	if !strings.HasPrefix(next, a.config.BasePath+"/") || strings.HasPrefix(next, "//") || strings.Contains(next, "\\") ||
+		strings.HasPrefix(next, a.config.BasePath+"/login") {
+		return a.config.BasePath + "/"
+	}
+	return next
+}
+
+// manageUsers run the local user command given by the flags and print the result.
+// The password is read from the first line of stdin, so it does not show in the process list or shell history.
+func (a *apiClient) manageUsers(setPassword, deleteUser string, listUsers bool) error {
+	switch {
+	case setPassword != "":
+		fmt.Fprintf(os.Stderr, "Password for %s: ", setPassword)
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if err := a.eventListener.SetUserPassword(setPassword, strings.TrimRight(line, "\r\n")); err != nil {
+			return err
+		}
+		fmt.Printf("Password of user %s is set.\n", setPassword)
+	case deleteUser != "":
+		if err := a.eventListener.DeleteUser(deleteUser); err != nil {
+			return err
+		}
+		fmt.Printf("User %s is deleted.\n", deleteUser)
+	case listUsers:
+		for _, u := range a.eventListener.GetUsers() {
+			if u.LastLogin == "" {
+				u.LastLogin = "never"
+			}
+			fmt.Printf("%s\tcreated %s\tlast login %s\n", u.Name, u.Created, u.LastLogin)
+		}
+	}
+	return nil
+}
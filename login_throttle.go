@@ -0,0 +1,99 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// loginRateWindow window of the login attempts limited per client IP.
+const loginRateWindow = time.Minute
+
+// loginFailures consecutive failed logins of the user, the user is locked out once they reach the maximum.
+type loginFailures struct {
+	count       int
+	last        time.Time
+	lockedUntil time.Time
+}
+
+// loginThrottle brute-force protection of the login form, kept in memory so it resets on restart.
+// The attempts are limited per client IP and the user is locked out after too many failed logins in a row.
+// Unknown users are locked out the same way, so the lockout does not tell which users exist.
+type loginThrottle struct {
+	mux      sync.Mutex
+	attempts map[string][]time.Time
+	failures map[string]loginFailures
+}
+
+// allow count the attempt from the IP, false when the limit of attempts per minute is reached. 0 means no limit.
+func (t *loginThrottle) allow(ip string, limit int, now time.Time) bool {
+	if limit <= 0 {
+		return true
+	}
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	if t.attempts == nil {
+		t.attempts = map[string][]time.Time{}
+	}
+	for k, times := range t.attempts {
+		recent := times[:0]
+		for _, at := range times {
+			if now.Sub(at) < loginRateWindow {
+				recent = append(recent, at)
+			}
+		}
+		if len(recent) == 0 {
+			delete(t.attempts, k)
+		} else {
+			t.attempts[k] = recent
+		}
+	}
+	if len(t.attempts[ip]) >= limit {
+		return false
+	}
+	t.attempts[ip] = append(t.attempts[ip], now)
+	return true
+}
+
+// lockedUntil when the lockout of the user ends, zero if not locked out.
+func (t *loginThrottle) lockedUntil(user string, now time.Time) time.Time {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	if f := t.failures[user]; now.Before(f.lockedUntil) {
+		return f.lockedUntil
+	}
+	return time.Time{}
+}
+
+// failed count the failed login of the user, true when it locks the user out. 0 max failures disables the lockout.
+// Failures older than the lockout duration are forgotten.
+func (t *loginThrottle) failed(user string, maxFailures int, lockout time.Duration, now time.Time) bool {
+	if maxFailures <= 0 {
+		return false
+	}
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	if t.failures == nil {
+		t.failures = map[string]loginFailures{}
+	}
+	for k, f := range t.failures {
+		if now.Sub(f.last) > lockout && !now.Before(f.lockedUntil) {
+			delete(t.failures, k)
+		}
+	}
+	f := t.failures[user]
+	f.count++
+	f.last = now
+	locked := f.count >= maxFailures
+	if locked {
+		f.count, f.lockedUntil = 0, now.Add(lockout)
+	}
+	t.failures[user] = f
+	return locked
+}
+
+// succeeded reset the failed logins of the user.
+func (t *loginThrottle) succeeded(user string) {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	delete(t.failures, user)
+}
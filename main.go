@@ -1,54 +1,63 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
-	"io/ioutil"
 	"net/http"
 	"net/url"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/CloudyKit/jet"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 	"github.com/quiq/docker-registry-ui/events"
+	"github.com/quiq/docker-registry-ui/policy"
 	"github.com/quiq/docker-registry-ui/registry"
-	"github.com/robfig/cron"
+	"github.com/quiq/docker-registry-ui/scanner"
 	"github.com/sirupsen/logrus"
 	"github.com/tidwall/gjson"
-	"gopkg.in/yaml.v2"
 )
 
-type configData struct {
-	ListenAddr            string   `yaml:"listen_addr"`
-	BasePath              string   `yaml:"base_path"`
-	RegistryURL           string   `yaml:"registry_url"`
-	VerifyTLS             bool     `yaml:"verify_tls"`
-	Username              string   `yaml:"registry_username"`
-	Password              string   `yaml:"registry_password"`
-	PasswordFile          string   `yaml:"registry_password_file"`
-	EventListenerToken    string   `yaml:"event_listener_token"`
-	EventRetentionDays    int      `yaml:"event_retention_days"`
-	EventDatabaseDriver   string   `yaml:"event_database_driver"`
-	EventDatabaseLocation string   `yaml:"event_database_location"`
-	EventDeletionEnabled  bool     `yaml:"event_deletion_enabled"`
-	CacheRefreshInterval  uint8    `yaml:"cache_refresh_interval"`
-	AnyoneCanDelete       bool     `yaml:"anyone_can_delete"`
-	Admins                []string `yaml:"admins"`
-	Debug                 bool     `yaml:"debug"`
-	PurgeTagsKeepDays     int      `yaml:"purge_tags_keep_days"`
-	PurgeTagsKeepCount    int      `yaml:"purge_tags_keep_count"`
-	PurgeTagsSchedule     string   `yaml:"purge_tags_schedule"`
-}
-
 type template struct {
 	View *jet.Set
 }
 
+// recentlyPushedLimit how many recently pushed repos to refresh first.
+const recentlyPushedLimit = 100
+
+// eventStatsTop how many most pulled and pushed repos to show on the event log.
+const eventStatsTop = 5
+
 type apiClient struct {
 	client        *registry.Client
 	eventListener *events.EventListener
+	eventTokens   *events.Tokens
+	usage         *usageStats
+	jobs          jobList
+	tasks         backgroundTasks
+	maintenance   *maintenanceWindow
+	storage       storageUsage
+	layers        layerListings
+	secrets       secretScans
+	deleted       deletedTags
+	uploads       chunkedUploads
+	pages         pageCache
+	scanner       scanner.Scanner
+	scans         scanResults
+	policy        policy.Rules
+	upstream      *registry.Upstream
+	baseImages    *registry.BaseImages
+	metadata      []metadataProvider
+	clusters      clusterUsage
+	registry      registryState
+	proxies       trustedProxies
+	logins        loginThrottle
+	purging       int32
+	collecting    int32
+	logger        *logrus.Entry
 	config        configData
 }
 
@@ -58,132 +67,345 @@ func main() {
 
 		configFile, loggingLevel string
 		purgeTags, purgeDryRun   bool
+		checkConfig              bool
+		setUserPassword          string
+		deleteUser               string
+		listUsers                bool
+		printDefaultConfig       bool
 	)
 	flag.StringVar(&configFile, "config-file", "config.yml", "path to the config file")
 	flag.StringVar(&loggingLevel, "log-level", "info", "logging level")
 	flag.BoolVar(&purgeTags, "purge-tags", false, "purge old tags instead of running a web server")
 	flag.BoolVar(&purgeDryRun, "dry-run", false, "dry-run for purging task, does not delete anything")
+	flag.BoolVar(&checkConfig, "check-config", false, "validate config, check registry and event database access and exit")
+	flag.BoolVar(&printDefaultConfig, "print-default-config", false, "print config file with all settings set to defaults and exit")
+	flag.StringVar(&setUserPassword, "set-user-password", "", "create the local user or change the password read from stdin and exit")
+	flag.StringVar(&deleteUser, "delete-user", "", "delete the local user and exit")
+	flag.BoolVar(&listUsers, "list-users", false, "list the local users and exit")
 	flag.Parse()
 
+	if printDefaultConfig {
+		os.Stdout.Write(defaultConfig())
+		return
+	}
+
 	if loggingLevel != "info" {
 		if level, err := logrus.ParseLevel(loggingLevel); err == nil {
 			logrus.SetLevel(level)
 		}
 	}
 
-	// Read config file.
-	if _, err := os.Stat(configFile); os.IsNotExist(err) {
-		panic(err)
-	}
-	bytes, err := ioutil.ReadFile(configFile)
+	a.logger = registry.SetupLogging("main")
+
+	// Read and validate config file.
+	config, err := readConfig(configFile)
 	if err != nil {
-		panic(err)
+		exitWithErrors(err)
 	}
-	if err := yaml.Unmarshal(bytes, &a.config); err != nil {
-		panic(err)
+	if errs := config.validate(); len(errs) > 0 {
+		exitWithErrors(errs...)
 	}
-	// Validate registry URL.
-	u, err := url.Parse(a.config.RegistryURL)
-	if err != nil {
-		panic(err)
+	a.config = config
+	a.proxies, _ = parseTrustedProxies(a.config.TrustedProxies)
+	if r := newErrorReporter(a.config); r != nil {
+		logrus.AddHook(r)
 	}
-	// Normalize base path.
-	if a.config.BasePath != "" {
-		if !strings.HasPrefix(a.config.BasePath, "/") {
-			a.config.BasePath = "/" + a.config.BasePath
+	a.maintenance, _ = newMaintenanceWindow(a.config.MaintenanceSchedule, a.config.MaintenanceDuration)
+
+	// Start mock registry and use it instead of the real one.
+	if a.config.RegistryMock {
+		mock, err := registry.NewMockRegistry(a.config.RegistryMockFixture)
+		if err != nil {
+			exitWithErrors(fmt.Errorf("registry_mock_fixture: %s", err))
 		}
-		if strings.HasSuffix(a.config.BasePath, "/") {
-			a.config.BasePath = a.config.BasePath[0 : len(a.config.BasePath)-1]
+		if a.config.RegistryURL, err = mock.Start(); err != nil {
+			exitWithErrors(fmt.Errorf("registry_mock: cannot start mock registry: %s", err))
 		}
+		a.config.Username, a.config.Password = "", ""
+		a.config.RegistryFlavor = registry.FlavorDistribution
 	}
-	// Read password from file.
-	if a.config.PasswordFile != "" {
-		if _, err := os.Stat(a.config.PasswordFile); os.IsNotExist(err) {
-			panic(err)
+	u, _ := url.Parse(a.config.RegistryURL)
+
+	a.eventListener = events.NewEventListener(
+		a.config.EventDatabaseDriver, a.config.EventDatabaseLocation, a.config.EventRetentionDays, a.config.EventDeletionEnabled,
+	)
+	a.eventListener.SetEventWindows(time.Duration(a.config.EventDedupSeconds)*time.Second, time.Duration(a.config.EventAggregateSeconds)*time.Second)
+
+	// Manage local users and exit.
+	if setUserPassword != "" || deleteUser != "" || listUsers {
+		if err := a.manageUsers(setUserPassword, deleteUser, listUsers); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
 		}
-		passwordBytes, err := ioutil.ReadFile(a.config.PasswordFile)
-		if err != nil {
-			panic(err)
+		return
+	}
+
+	// Run self-check and exit.
+	if checkConfig {
+		if errs := a.selfCheck(); len(errs) > 0 {
+			exitWithErrors(errs...)
 		}
-		a.config.Password = strings.TrimSuffix(string(passwordBytes[:]), "\n")
+		fmt.Println("Config is valid, registry and event database are accessible.")
+		return
 	}
 
 	// Init registry API client.
 	a.client = registry.NewClient(a.config.RegistryURL, a.config.VerifyTLS, a.config.Username, a.config.Password)
-	if a.client == nil {
-		panic(fmt.Errorf("cannot initialize api client or unsupported auth method"))
+	if err := a.connectRegistry(a.config.RegistryConnectRetries); err != nil {
+		// The web server keeps running until the registry is back, CLI tasks cannot.
+		if purgeTags {
+			exitWithErrors(fmt.Errorf("registry_url: cannot connect to %s: %s", a.config.RegistryURL, err))
+		}
+		a.superviseRegistry(err)
+	}
+	if _, err := a.client.SetFlavor(a.config.RegistryFlavor, a.config.RegistryAPIToken); err != nil {
+		exitWithErrors(fmt.Errorf("registry_flavor: %s", err))
+	}
+	// Locked tags are kept by purging from CLI too.
+	a.client.SetDeletionGuard(a.guardDeletion)
+	a.client.SetMaxConcurrentRequests(a.config.MaxConcurrentRegistryRequests)
+	a.client.SetCacheLimits(registry.CacheLimits{
+		MaxRepos:       a.config.TagCacheMaxRepos,
+		MaxTagsPerRepo: a.config.TagCacheMaxTagsPerRepo,
+		MaxEntries:     a.config.TagCacheMaxEntries,
+	})
+	if a.config.CatalogResumeFile != "" {
+		if err := a.client.SetCatalogResumeFile(a.config.CatalogResumeFile); err != nil {
+			exitWithErrors(fmt.Errorf("catalog_resume_file: %s", err))
+		}
+	}
+	if err := a.client.SetConfigCacheDir(a.config.ConfigCacheDir); err != nil {
+		exitWithErrors(fmt.Errorf("config_cache_dir: %s", err))
 	}
 
 	// Execute CLI task and exit.
 	if purgeTags {
+		if !purgeDryRun {
+			a.maintenance.wait(a.logger.Infof)
+		}
 		a.purgeOldTags(purgeDryRun)
 		return
 	}
-	// Schedules to purge tags.
-	if a.config.PurgeTagsSchedule != "" {
-		c := cron.New()
-		task := func() {
-			a.purgeOldTags(purgeDryRun)
-		}
-		if err := c.AddFunc(a.config.PurgeTagsSchedule, task); err != nil {
-			panic(fmt.Errorf("Invalid schedule format: %s", a.config.PurgeTagsSchedule))
-		}
-		c.Start()
-	}
-
-	// Count tags in background.
-	go a.client.CountTags(a.config.CacheRefreshInterval)
-
-	if a.config.EventDatabaseDriver != "sqlite3" && a.config.EventDatabaseDriver != "mysql" {
-		panic(fmt.Errorf("event_database_driver should be either sqlite3 or mysql"))
+	// Count tags, build search index and purge tags in background.
+	a.client.IndexMetadata(a.config.SearchIndexMetadata)
+	a.client.RecentlyPushed(func() []string { return a.eventListener.RecentlyPushed(recentlyPushedLimit) })
+	a.client.OnNewTags(a.recordNewTags)
+	a.client.OnCatalogChange(a.pages.invalidate)
+	a.startBackgroundTasks(purgeDryRun)
+	a.watchSecrets()
+	a.startClusterLookup()
+	a.scanner = a.newScanner()
+	a.scans.reports = map[string]scanner.Report{}
+	a.policy = a.policyRules()
+	a.upstream = a.newUpstream()
+	if len(a.config.BaseImageUpstreams) > 0 {
+		a.baseImages = registry.NewBaseImages(a.config.BaseImageUpstreams)
 	}
-	a.eventListener = events.NewEventListener(
-		a.config.EventDatabaseDriver, a.config.EventDatabaseLocation, a.config.EventRetentionDays, a.config.EventDeletionEnabled,
-	)
+	a.metadata = a.newMetadataProviders()
 
 	// Template engine init.
 	e := echo.New()
-	e.Renderer = setupRenderer(a.config.Debug, u.Host, a.config.BasePath)
+	e.Renderer = setupRenderer(a.config, u.Host)
+	e.HTTPErrorHandler = a.handleError
+	e.IPExtractor = a.proxies.ipExtractor()
+	a.usage = newUsageStats()
+	accessLog, err := newAccessLog(a.config)
+	if err != nil {
+		exitWithErrors(fmt.Errorf("access_log: %s", err))
+	}
+	a.useMiddleware(e, accessLog)
 
 	// Web routes.
 	e.File("/favicon.ico", "static/favicon.ico")
 	e.Static(a.config.BasePath+"/static", "static")
 	if a.config.BasePath != "" {
-		e.GET(a.config.BasePath, a.viewRepositories)
+		e.GET(a.config.BasePath, a.viewRepositories, a.cachePage)
 	}
-	e.GET(a.config.BasePath+"/", a.viewRepositories)
-	e.GET(a.config.BasePath+"/:namespace", a.viewRepositories)
+	e.GET(a.config.BasePath+"/", a.viewRepositories, a.cachePage)
+	e.GET(a.config.BasePath+"/:namespace", a.viewRepositories, a.cachePage)
 	e.GET(a.config.BasePath+"/:namespace/:repo", a.viewTags)
 	e.GET(a.config.BasePath+"/:namespace/:repo/:tag", a.viewTagInfo)
 	e.GET(a.config.BasePath+"/:namespace/:repo/:tag/delete", a.deleteTag)
+	e.POST(a.config.BasePath+"/undo/:id", a.undoDeletion)
 	e.GET(a.config.BasePath+"/events", a.viewLog)
-
-	// Protected event listener.
+	e.GET(a.config.BasePath+"/approvals", a.viewApprovals)
+	e.POST(a.config.BasePath+"/approvals/:id/:action", a.reviewDeletion)
+	e.GET(a.config.BasePath+"/view-as", a.viewAs)
+	if a.config.Metrics {
+		e.GET(a.config.BasePath+"/metrics", a.viewMetrics)
+	}
+	if a.config.DebugEndpoints {
+		e.GET(a.config.BasePath+"/debug/runtime", a.viewRuntime)
+		e.GET(a.config.BasePath+"/debug/pprof/*", a.pprofHandler())
+	}
+	if a.config.LocalUsers {
+		e.GET(a.config.BasePath+"/login", a.viewLogin)
+		e.POST(a.config.BasePath+"/login", a.login)
+		e.POST(a.config.BasePath+"/logout", a.logout)
+	}
+	e.GET(a.config.BasePath+"/usage", a.viewUsage)
+	e.GET(a.config.BasePath+"/audit", a.viewAuditLog)
+	e.GET(a.config.BasePath+"/diagnostics", a.viewDiagnostics)
+	e.GET(a.config.BasePath+"/storage", a.viewStorage, a.cachePage)
+	e.GET(a.config.BasePath+"/layers", a.viewLayerFiles)
+	e.GET(a.config.BasePath+"/files", a.viewFiles)
+	e.GET(a.config.BasePath+"/blobs", a.viewBlobs)
+	e.GET(a.config.BasePath+"/conventions", a.viewConventions, a.cachePage)
+	e.GET(a.config.BasePath+"/stale", a.viewStale, a.cachePage)
+	e.GET(a.config.BasePath+"/transfer", a.viewTransfer)
+	e.POST(a.config.BasePath+"/transfer/export", a.exportImages)
+	e.GET(a.config.BasePath+"/transfer/exports/:name", a.downloadExport)
+	e.POST(a.config.BasePath+"/transfer/import", a.importImages)
+	e.POST(a.config.BasePath+"/transfer/uploads", a.startUpload)
+	e.GET(a.config.BasePath+"/transfer/uploads/:id", a.uploadStatus)
+	e.PATCH(a.config.BasePath+"/transfer/uploads/:id", a.uploadChunk)
+	e.DELETE(a.config.BasePath+"/transfer/uploads/:id", a.cancelUpload)
+	e.POST(a.config.BasePath+"/transfer/uploads/:id/import", a.importUpload)
+	e.POST(a.config.BasePath+"/storage/scan", a.scanStorage)
+	e.POST(a.config.BasePath+"/gc", a.runGC)
+	e.POST(a.config.BasePath+"/tasks/:id/:action", a.controlTask)
+	e.GET(a.config.BasePath+"/jobs", a.viewJobs)
+	e.GET(a.config.BasePath+"/jobs/:id", a.viewJob)
+	e.GET(a.config.BasePath+"/search", a.viewSearch)
+	e.GET(a.config.BasePath+"/api-tokens", a.viewAPITokens)
+	e.POST(a.config.BasePath+"/api-tokens", a.createAPIToken)
+	e.POST(a.config.BasePath+"/api-tokens/:id/revoke", a.revokeAPIToken)
+	e.GET(a.config.BasePath+"/vulnerabilities", a.viewVulnAcceptances)
+	e.POST(a.config.BasePath+"/vulnerabilities", a.acceptVulnerability)
+	e.POST(a.config.BasePath+"/vulnerabilities/:id/revoke", a.revokeVulnAcceptance)
+	e.POST(a.config.BasePath+"/scan", a.scanImage)
+	e.POST(a.config.BasePath+"/sign", a.signImage)
+	e.POST(a.config.BasePath+"/locks", a.lockTag)
+	e.POST(a.config.BasePath+"/locks/:id/unlock", a.unlockTag)
+	e.POST(a.config.BasePath+"/preferences/columns", a.saveTagColumns)
+	e.POST(a.config.BasePath+"/copy", a.copyImages)
+	e.POST(a.config.BasePath+"/prune-index", a.pruneIndex)
+	e.GET(a.config.BasePath+"/options", a.viewOptions)
+	e.GET(a.config.BasePath+"/webhooks", a.viewWebhooks)
+	e.POST(a.config.BasePath+"/webhooks", a.addWebhook)
+	e.GET(a.config.BasePath+"/webhooks/:id", a.viewWebhookDeliveries)
+	e.POST(a.config.BasePath+"/webhooks/:id/test", a.testWebhook)
+	e.POST(a.config.BasePath+"/webhooks/:id/delete", a.deleteWebhook)
+	e.GET(a.config.BasePath+"/owners", a.viewOwners)
+	e.POST(a.config.BasePath+"/owners", a.addOwner)
+	e.POST(a.config.BasePath+"/owners/:id/delete", a.deleteOwner)
+	e.GET(a.config.BasePath+"/settings", a.viewRepoSettings)
+	e.POST(a.config.BasePath+"/settings", a.saveRepoSettings)
+	e.GET(a.config.BasePath+"/retention", a.viewRetention)
+	e.POST(a.config.BasePath+"/retention/preview", a.previewRetention)
+	e.POST(a.config.BasePath+"/retention/run", a.runRetention)
+	e.POST(a.config.BasePath+"/retention/rules", a.saveRetentionRules)
+	e.POST(a.config.BasePath+"/retention/import", a.importRetention)
+	e.GET(a.config.BasePath+"/cache", a.viewCache, a.cachePage)
+	e.POST(a.config.BasePath+"/cache/check", a.checkCache)
+
+	// Protected event listener and API.
+	a.eventTokens = events.NewTokens(
+		append([]string{a.config.EventListenerToken}, a.config.EventListenerTokens...), a.config.EventListenerTokenFile,
+	)
 	p := e.Group(a.config.BasePath + "/api")
 	p.Use(middleware.KeyAuthWithConfig(middleware.KeyAuthConfig{
+		// Quay cannot send headers with its webhooks, the token is accepted as query parameter too.
+		Skipper: func(c echo.Context) bool {
+			return c.QueryParam("token") != "" && a.eventTokens.Valid(c.QueryParam("token"))
+		},
 		Validator: middleware.KeyAuthValidator(func(token string, c echo.Context) (bool, error) {
-			return token == a.config.EventListenerToken, nil
+			return a.eventTokens.Valid(token), nil
 		}),
 	}))
-	p.POST("/events", a.receiveEvents)
+
+	// JSON API, the routes requiring token are under the protected group.
+	for _, r := range a.apiRoutes() {
+		if r.Auth {
+			p.Add(r.Method, strings.TrimPrefix(r.Path, "/api"), r.handler)
+		} else {
+			e.Add(r.Method, a.config.BasePath+r.Path, r.handler)
+		}
+	}
+	e.GET(a.config.BasePath+"/api/v1/docs", a.viewAPIDocs)
 
 	e.Logger.Fatal(e.Start(a.config.ListenAddr))
 }
 
+// useMiddleware add the middleware of all requests. The user is taken from the trusted proxy, API token or session
+// before the permissions and the tenant scope are checked.
+func (a *apiClient) useMiddleware(e *echo.Echo, accessLog *accessLog) {
+	e.Use(a.requestID)
+	e.Use(a.trustProxy)
+	if accessLog != nil {
+		e.Use(accessLog.middleware)
+	}
+	if a.config.ResponseCompression {
+		e.Use(middleware.Gzip())
+	}
+	e.Use(a.recoverPanic)
+	e.Use(a.apiAuth)
+	if a.config.LocalUsers {
+		e.Use(a.sessionAuth)
+	}
+	e.Use(a.trackPageViews)
+	e.Use(a.authorize)
+	e.Use(a.tenancy)
+}
+
 func (a *apiClient) viewRepositories(c echo.Context) error {
 	namespace := c.Param("namespace")
 	if namespace == "" {
 		namespace = "library"
 	}
+	scope := a.tenantScope(c)
+	if csvRequested(c) {
+		return renderCSV(c, "repositories-"+namespace, catalogCSV(a.visibleCatalogRepos(namespace, scope)))
+	}
 
-	repos, _ := a.client.Repositories(true)[namespace]
-	data := jet.VarMap{}
+	// Repos are loaded page by page from the API by the browser unless in noscript mode.
+	data := a.setUserPermissions(c)
 	data.Set("namespace", namespace)
-	data.Set("namespaces", a.client.Namespaces())
-	data.Set("repos", repos)
-	data.Set("tagCounts", a.client.TagCounts())
+	data.Set("namespaces", a.visibleNamespaces(scope))
+	repos := []registry.CatalogRepo{}
+	if a.config.NoscriptMode {
+		repos = a.visibleCatalogRepos(namespace, scope)
+	}
+	pager, rows := a.tablePage(c, len(repos), "repo", false, map[string]func(i, j int) bool{
+		"repo": func(i, j int) bool { return repos[i].Repo < repos[j].Repo },
+		"tags": func(i, j int) bool { return repos[i].Tags < repos[j].Tags },
+	})
+	page := []registry.CatalogRepo{}
+	for _, i := range rows {
+		page = append(page, repos[i])
+	}
+	data.Set("repos", page)
+	data.Set("pager", pager)
+	loading, progress := a.client.CatalogLoading()
+	data.Set("catalogLoading", loading)
+	data.Set("catalogProgress", progress)
 
-	return c.Render(http.StatusOK, "repositories.html", data)
+	return renderStream(c, http.StatusOK, "repositories.html", data)
+}
+
+// catalogRepos all repos of the namespace read page by page from the catalog.
+func (a *apiClient) catalogRepos(namespace string) []registry.CatalogRepo {
+	var list []registry.CatalogRepo
+	after := ""
+	for {
+		repos, next := a.client.CatalogPage(namespace, after, 1000)
+		list = append(list, repos...)
+		if next == "" {
+			return list
+		}
+		after = next
+	}
+}
+
+// visibleCatalogRepos repos of the namespace within the tenant scope.
+func (a *apiClient) visibleCatalogRepos(namespace string, scope []string) []registry.CatalogRepo {
+	repos := []registry.CatalogRepo{}
+	for _, r := range a.catalogRepos(namespace) {
+		if inScope(scope, catalogRepoPath(r.Namespace, r.Repo)) {
+			repos = append(repos, r)
+		}
+	}
+	return repos
 }
 
 func (a *apiClient) viewTags(c echo.Context) error {
@@ -195,17 +417,141 @@ func (a *apiClient) viewTags(c echo.Context) error {
 	}
 
 	tags := a.client.Tags(repoPath)
-	deleteAllowed := a.checkDeletePermission(c.Request().Header.Get("X-WEBAUTH-USER"))
 
-	data := jet.VarMap{}
+	data := a.setUserPermissions(c)
 	data.Set("namespace", namespace)
 	data.Set("repo", repo)
-	data.Set("tags", tags)
-	data.Set("deleteAllowed", deleteAllowed)
 	repoPath, _ = url.PathUnescape(repoPath)
-	data.Set("events", a.eventListener.GetEvents(repoPath))
+	a.client.RepoViewed(a.viewerOf(c), repoPath)
+	data.Set("repoPath", repoPath)
+	// In noscript mode only the page of tags is shown and sorting by tag needs no metadata,
+	// so then it is fetched for the page and the locked and protected tags the page may share the digest with.
+	var allMeta []registry.TagMeta
+	if column := c.QueryParam("sort"); !a.config.NoscriptMode || csvRequested(c) || column != "" && column != "tag" {
+		allMeta = a.client.TagsMetadata(repoPath, tags)
+	}
+	pager, rows := a.tablePage(c, len(tags), "tag", true, map[string]func(i, j int) bool{
+		"tag":     func(i, j int) bool { return tags[i] < tags[j] },
+		"created": func(i, j int) bool { return allMeta[i].Created.Before(allMeta[j].Created) },
+		"age":     func(i, j int) bool { return allMeta[i].Created.After(allMeta[j].Created) },
+		"size":    func(i, j int) bool { return allMeta[i].Size < allMeta[j].Size },
+	})
+	locks, patterns := a.eventListener.GetTagLocks(repoPath), a.protectedPatterns(repoPath)
+	if allMeta == nil {
+		allMeta = a.pageTagsMetadata(repoPath, tags, rows, locks, patterns)
+	}
+	tagsMeta := make([]registry.TagMeta, len(rows))
+	for i, row := range rows {
+		tagsMeta[i] = allMeta[row]
+	}
+	pageTitles := func(titles []string) []string {
+		list := make([]string, len(rows))
+		for i, row := range rows {
+			list[i] = titles[row]
+		}
+		return list
+	}
+	data.Set("tags", tagsMeta)
+	data.Set("pager", pager)
+	eventsEnabled := a.config.feature("events")
+	data.Set("eventsEnabled", eventsEnabled)
+	if eventsEnabled {
+		data.Set("events", a.eventListener.GetEvents(repoPath))
+		data.Set("activity", a.pushActivity([]string{repoPath}))
+	} else {
+		data.Set("events", []events.EventRow{})
+		data.Set("activity", activityCalendar{})
+	}
 
-	return c.Render(http.StatusOK, "tags.html", data)
+	// Columns are set one by one and the cells are aligned with tags by index
+	// as jet cannot look up a map inside range reliably.
+	// Columns of the features turned off are not available.
+	unavailable := map[string]bool{
+		"pulls":           !eventsEnabled,
+		"vulnerabilities": a.scanner == nil,
+		"policy":          !a.policy.Enabled(),
+		"upstream":        a.upstream == nil,
+		"usage":           !a.config.feature("kubernetes"),
+	}
+	columns := a.visibleTagColumns(c)
+	var available []tagColumn
+	for _, col := range a.allTagColumns() {
+		columns[col.Name] = columns[col.Name] && !unavailable[col.Name]
+		data.Set("col_"+col.Name, columns[col.Name])
+		if !unavailable[col.Name] {
+			available = append(available, col)
+		}
+	}
+	data.Set("tagColumns", available)
+	data.Set("visibleColumns", columns)
+
+	// Hidden columns are not calculated.
+	pulls := make([]int, len(tagsMeta))
+	scanBadges := make([]string, len(tagsMeta))
+	policyBadges := make([]string, len(tagsMeta))
+	conventionTitles := make([]string, len(tagsMeta))
+	policyRules := a.repoPolicy(repoPath)
+	upstreamBadges := make([]string, len(tagsMeta))
+	usageBadgesList := make([]string, len(tagsMeta))
+	inUse := make([]bool, len(tagsMeta))
+	var pullCounts map[string]int
+	if columns["pulls"] {
+		pullCounts = a.eventListener.PullCounts(repoPath)
+	}
+	if columns["upstream"] {
+		for i, s := range a.upstream.Compare(repoPath, tagsMeta) {
+			upstreamBadges[i] = upstreamBadge(s)
+		}
+	}
+	for i, t := range tagsMeta {
+		pulls[i] = pullCounts[t.Tag]
+		conventionTitles[i] = a.conventionTitle(repoPath, t.Tag)
+		// Tags in use cannot be deleted, so it is checked even if the column is hidden.
+		if !unavailable["usage"] {
+			uses := a.imageUses(repoPath, t.Tag, t.Digest)
+			inUse[i] = len(uses) > 0
+			usageBadgesList[i] = usageBadges(uses)
+		}
+		if columns["vulnerabilities"] {
+			if report, ok := a.scanReport(repoPath, t.Tag, t.Digest); ok {
+				scanBadges[i] = severityBadges(report.Summary())
+			}
+		}
+		if columns["policy"] {
+			policyBadges[i] = policyBadge(a.evaluatePolicy(policyRules, t, tags))
+		}
+	}
+	metaColumns, metaCells := a.metadataCells(repoPath, tagsMeta, columns)
+	if csvRequested(c) {
+		badges := map[string][]string{"vulnerabilities": scanBadges, "policy": policyBadges, "upstream": upstreamBadges, "usage": usageBadgesList}
+		return renderCSV(c, "tags-"+repoPath, tagsCSV(available, columns, tagsMeta, pulls, badges, metaColumns, metaCells))
+	}
+	data.Set("metaColumns", metaColumns)
+	data.Set("metaCells", metaCells)
+	data.Set("pulls", pulls)
+	data.Set("lockTitles", pageTitles(lockTitles(locks, allMeta)))
+	data.Set("protectedTitles", pageTitles(protectedTitles(patterns, allMeta)))
+	data.Set("conventionTitles", conventionTitles)
+	data.Set("undos", a.pendingUndos(data["user"].String(), repoPath))
+	data.Set("undoWindow", a.undoWindow())
+	repoPulls, err := a.client.PullCount(repoPath)
+	data.Set("repoPulls", repoPulls)
+	data.Set("repoPullsKnown", err == nil)
+	data.Set("flavor", a.client.Flavor())
+	data.Set("scanBadges", scanBadges)
+	data.Set("policyBadges", policyBadges)
+	data.Set("upstreamBadges", upstreamBadges)
+	data.Set("usageBadges", usageBadgesList)
+	data.Set("inUse", inUse)
+	if a.upstream != nil {
+		data.Set("upstreamReference", a.upstream.Reference(repoPath))
+	} else {
+		data.Set("upstreamReference", "")
+	}
+	owner, _ := a.repoOwner(repoPath)
+	data.Set("owner", owner)
+
+	return renderStream(c, http.StatusOK, "tags.html", data)
 }
 
 func (a *apiClient) viewTagInfo(c echo.Context) error {
@@ -225,16 +571,17 @@ func (a *apiClient) viewTagInfo(c echo.Context) error {
 	}
 
 	created := gjson.Get(gjson.Get(infoV1, "history.0.v1Compatibility").String(), "created").String()
+	meta, _ := a.client.TagMetadata(repoPath, tag)
+	if created == "" && !meta.Created.IsZero() {
+		created = meta.Created.Format(time.RFC3339)
+	}
 	isDigest := strings.HasPrefix(tag, "sha256:")
 	if len(manifests) > 0 {
 		sha256 = sha256list
 	}
 
 	// Gather layers v2
-	var layersV2 []map[string]gjson.Result
-	for _, s := range gjson.Get(infoV2, "layers").Array() {
-		layersV2 = append(layersV2, s.Map())
-	}
+	layersV2 := registry.Layers(infoV2)
 
 	// Gather layers v1
 	var layersV1 []map[string]interface{}
@@ -265,9 +612,17 @@ func (a *apiClient) viewTagInfo(c echo.Context) error {
 
 	// Gather sub-image info of multi-arch or cache image
 	var digestList []map[string]interface{}
+	var subImages []subImage
 	for _, s := range manifests {
+		if s.Get(`annotations.vnd\.docker\.reference\.type`).String() != "attestation-manifest" {
+			subImages = append(subImages, subImage{
+				Digest:   s.Get("digest").String(),
+				Platform: registry.PlatformString(s.Get("platform")),
+				Label:    registry.PlatformLabel(s.Get("platform")),
+			})
+		}
 		r, _ := gjson.Parse(s.String()).Value().(map[string]interface{})
-		if s.Get("mediaType").String() == "application/vnd.docker.distribution.manifest.v2+json" {
+		if mediaType := s.Get("mediaType").String(); mediaType == registry.MediaTypeManifestV2 || mediaType == registry.MediaTypeOCIManifest {
 			// Sub-image of the specific arch.
 			_, dInfoV1, _ := a.client.TagInfo(repoPath, s.Get("digest").String(), true)
 			var dSize int64
@@ -276,9 +631,7 @@ func (a *apiClient) viewTagInfo(c echo.Context) error {
 			}
 			r["size"] = dSize
 			// Create link here because there is a bug with jet template when referencing a value by map key in the "if" condition under "range".
-			if r["mediaType"] == "application/vnd.docker.distribution.manifest.v2+json" {
-				r["digest"] = fmt.Sprintf(`<a href="%s/%s/%s/%s">%s</a>`, a.config.BasePath, namespace, repo, r["digest"], r["digest"])
-			}
+			r["digest"] = fmt.Sprintf(`<a href="%s/%s/%s/%s">%s</a>`, a.config.BasePath, namespace, repo, r["digest"], r["digest"])
 		} else {
 			// Sub-image of the cache type.
 			r["size"] = s.Get("size").Int()
@@ -288,7 +641,7 @@ func (a *apiClient) viewTagInfo(c echo.Context) error {
 	}
 
 	// Populate template vars
-	data := jet.VarMap{}
+	data := a.setUserPermissions(c)
 	data.Set("namespace", namespace)
 	data.Set("repo", repo)
 	data.Set("tag", tag)
@@ -296,11 +649,76 @@ func (a *apiClient) viewTagInfo(c echo.Context) error {
 	data.Set("sha256", sha256)
 	data.Set("imageSize", imageSize)
 	data.Set("created", created)
+	data.Set("ageDays", meta.AgeDays())
+	data.Set("platforms", meta.PlatformLabels)
+	data.Set("annotations", annotationRows(meta.Annotations))
 	data.Set("layersCount", layersCount)
 	data.Set("layersV2", layersV2)
 	data.Set("layersV1", layersV1)
 	data.Set("isDigest", isDigest)
 	data.Set("digestList", digestList)
+	data.Set("subImages", subImages)
+
+	scanReport, scanned := a.scanReport(repoPath, tag, meta.Digest)
+	data.Set("scannerEnabled", a.scanner != nil && !a.scanDisabled(repoPath))
+	data.Set("scanned", scanned)
+	data.Set("scanReport", scanReport)
+	repoTags := a.client.Tags(repoPath)
+	data.Set("policyEnabled", a.policy.Enabled())
+	data.Set("policyResult", a.evaluatePolicy(a.repoPolicy(repoPath), meta, repoTags))
+	lintMetas := []registry.TagMeta{meta}
+	if tag == "latest" {
+		lintMetas = a.client.TagsMetadata(repoPath, repoTags)
+	}
+	lintReport, err := a.lintImage(meta, digestTags(lintMetas, meta.Digest))
+	if err != nil {
+		a.log(c).Warnf("Cannot lint %s:%s: %s", repoPath, tag, err)
+	}
+	data.Set("lint", lintReport)
+	var secrets secretScan
+	if a.secretScanEnabled(repoPath) {
+		if secrets, err = a.scanSecrets(repoPath, tag, meta.Digest); err != nil {
+			a.log(c).Warnf("Cannot scan %s:%s for secrets: %s", repoPath, tag, err)
+		}
+	}
+	data.Set("secretScanEnabled", a.secretScanEnabled(repoPath))
+	data.Set("secretFindings", secrets.findings)
+	data.Set("secretSkipped", secrets.skipped)
+	data.Set("signingEnabled", a.config.feature("signing"))
+	data.Set("signed", isSigned(meta.Digest, repoTags))
+	data.Set("signatureTag", policy.SignatureTag(meta.Digest))
+	data.Set("usage", usageBadges(a.imageUses(repoPath, tag, meta.Digest)))
+	owner, _ := a.repoOwner(repoPath)
+	data.Set("owner", owner)
+	lock, locked := a.tagLock(repoPath, tag, meta.Digest)
+	data.Set("lock", lock)
+	data.Set("locked", locked)
+	data.Set("canUnlock", locked && (lock.User == data["user"].String() || data["isAdmin"].Bool()))
+	protectedTag, protectedPattern, _ := a.tagProtection(repoPath, tag, meta.Digest)
+	data.Set("protectedTag", protectedTag)
+	data.Set("protectedPattern", protectedPattern)
+	data.Set("baseImage", a.baseImageStatus(repoPath, meta, layersV2, subImages))
+	reference := a.config.imageName(repoPath) + ":" + tag
+	if isDigest {
+		reference = a.config.imageName(repoPath) + "@" + tag
+	}
+	data.Set("snippets", deploymentSnippets(reference, repoPath, meta.Ports))
+	provenance, err := a.client.Provenance(repoPath, tag)
+	if err != nil {
+		a.log(c).Warnf("Cannot get provenance of %s:%s: %s", repoPath, tag, err)
+	}
+	data.Set("provenance", provenance)
+	var imageConfig string
+	if d := gjson.Get(infoV2, "config.digest").String(); d != "" && len(manifests) == 0 {
+		if imageConfig, err = a.client.GetBlob(repoPath, d); err != nil {
+			a.log(c).Warnf("Cannot get image config of %s:%s: %s", repoPath, tag, err)
+		}
+	}
+	build := registry.ExtractBuildInfo(provenance, imageConfig, meta.Labels)
+	data.Set("build", build)
+	data.Set("buildSourceURL", webURL(strings.TrimPrefix(build.Source, "git+")))
+	data.Set("buildCommitURL", commitURL(build.Source, build.Commit))
+	data.Set("imageLinks", a.imageLinks(repoPath, tag, meta, build))
 
 	return c.Render(http.StatusOK, "tag_info.html", data)
 }
@@ -314,42 +732,126 @@ func (a *apiClient) deleteTag(c echo.Context) error {
 		repoPath = fmt.Sprintf("%s/%s", namespace, repo)
 	}
 
-	if a.checkDeletePermission(c.Request().Header.Get("X-WEBAUTH-USER")) {
-		a.client.DeleteTag(repoPath, tag)
+	reason := strings.TrimSpace(c.QueryParam("reason"))
+	if a.config.DeleteReasonRequired && reason == "" {
+		return c.String(http.StatusBadRequest, "Reason for deleting the image is required.")
+	}
+	if a.approvalRequired(a.setUserPermissions(c)["isAdmin"].Bool()) {
+		return a.requestDeletion(c, repoPath, tag, reason)
+	}
+	// The manifest is kept to push it back on undo, the blobs stay until the garbage collection.
+	var undo deletedTag
+	if a.undoWindow() > 0 {
+		manifest, mediaType, digest, err := a.client.GetManifest(repoPath, tag)
+		if err != nil {
+			a.log(c).Warnf("Cannot keep the manifest of %s:%s for undo: %s", repoPath, tag, err)
+		}
+		undo = deletedTag{Repo: repoPath, Tag: tag, Digest: digest, User: a.setUserPermissions(c)["user"].String(),
+			mediaType: mediaType, manifest: manifest}
+	}
+	err := a.client.DeleteTag(repoPath, tag)
+	if errors.Is(err, registry.ErrTagInUse) || errors.Is(err, registry.ErrTagLocked) || errors.Is(err, registry.ErrTagProtected) {
+		return c.String(http.StatusConflict, fmt.Sprintf("Cannot delete %s:%s: %s.", repoPath, tag, err))
+	}
+	if err == nil {
+		a.audit(c, "delete", repoPath, tag, reason)
+		a.notifyDeletedTag(a.uiBaseURL(c), a.setUserPermissions(c)["user"].String(), c.RealIP(), repoPath, tag)
+		if undo.manifest != "" {
+			a.rememberDeletion(undo)
+		}
 	}
+	a.trackAction(c, "delete")
 
 	return c.Redirect(http.StatusSeeOther, fmt.Sprintf("%s/%s/%s", a.config.BasePath, namespace, repo))
 }
 
-// checkDeletePermission check if tag deletion is allowed whether by anyone or permitted users.
-func (a *apiClient) checkDeletePermission(user string) bool {
-	deleteAllowed := a.config.AnyoneCanDelete
-	if !deleteAllowed {
-		for _, u := range a.config.Admins {
-			if u == user {
-				deleteAllowed = true
-				break
-			}
-		}
-	}
-	return deleteAllowed
+// viewDiagnostics check registry connectivity live and show stats of the recent requests to registry.
+func (a *apiClient) viewDiagnostics(c echo.Context) error {
+	data := a.setUserPermissions(c)
+	data.Set("checks", a.client.Diagnose())
+	data.Set("stats", a.client.RequestStats())
+	data.Set("backoff", a.client.Backoff())
+	data.Set("pageCache", a.pages.stats())
+	data.Set("tagCache", a.client.MetadataCacheStats())
+	data.Set("configCache", a.client.ConfigCacheStats())
+	data.Set("flavor", a.client.Flavor())
+	data.Set("clusters", a.clusterStatuses())
+	data.Set("debugEndpoints", a.config.DebugEndpoints)
+	return c.Render(http.StatusOK, "diagnostics.html", data)
 }
 
 // viewLog view events from sqlite.
 func (a *apiClient) viewLog(c echo.Context) error {
-	data := jet.VarMap{}
-	data.Set("events", a.eventListener.GetEvents(""))
+	if !a.config.feature("events") {
+		return c.String(http.StatusNotFound, "Event listener is disabled by features setting.")
+	}
+	// Events of the other tenants' repos are not read at all, so the latest 1000 are the tenant's ones.
+	scope := a.tenantScope(c)
+	list := a.eventListener.GetScopedEvents(scope)
+	if csvRequested(c) {
+		return renderCSV(c, "events", eventsCSV(list))
+	}
+	pager, rows := a.tablePage(c, len(list), "time", true, map[string]func(i, j int) bool{
+		"action": func(i, j int) bool { return list[i].Action < list[j].Action },
+		"image": func(i, j int) bool {
+			return list[i].Repository+":"+list[i].Tag < list[j].Repository+":"+list[j].Tag
+		},
+		"ip":   func(i, j int) bool { return list[i].IP < list[j].IP },
+		"user": func(i, j int) bool { return list[i].User < list[j].User },
+		"time": func(i, j int) bool { return list[i].ID < list[j].ID },
+	})
+	page := []events.EventRow{}
+	for _, i := range rows {
+		page = append(page, list[i])
+	}
+	data := a.setUserPermissions(c)
+	data.Set("events", page)
+	data.Set("pager", pager)
+	data.Set("stats", a.eventListener.GetEventStats(scope, eventStatsTop))
+	data.Set("activity", a.pushActivity(scope))
 
-	return c.Render(http.StatusOK, "event_log.html", data)
+	return renderStream(c, http.StatusOK, "event_log.html", data)
 }
 
-// receiveEvents receive events.
+// receiveEvents receive events of the registry, the source is detected by the payload unless given by the path.
 func (a *apiClient) receiveEvents(c echo.Context) error {
-	a.eventListener.ProcessEvents(c.Request())
+	if !a.config.feature("events") {
+		return apiError(c, http.StatusNotFound, fmt.Errorf("event listener is disabled by features setting"))
+	}
+	source := c.Param("source")
+	if source != "" && !registry.ItemInSlice(source, events.Sources) {
+		return apiError(c, http.StatusNotFound, fmt.Errorf("unknown source %q, expected one of %s", source, strings.Join(events.Sources, ", ")))
+	}
+	baseURL := a.uiBaseURL(c)
+	err := a.eventListener.ProcessEvents(c.Request(), source, func(list []events.Event) {
+		a.notifyEvents(baseURL, list)
+	})
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, err)
+	}
 	return c.String(http.StatusOK, "OK")
 }
 
-// purgeOldTags purges old tags.
-func (a *apiClient) purgeOldTags(dryRun bool) {
-	registry.PurgeOldTags(a.client, dryRun, a.config.PurgeTagsKeepDays, a.config.PurgeTagsKeepCount)
+// selfCheck check the registry is reachable with the configured credentials and the event database is writable.
+func (a *apiClient) selfCheck() []error {
+	var errs []error
+	client := registry.NewClient(a.config.RegistryURL, a.config.VerifyTLS, a.config.Username, a.config.Password)
+	if err := client.Connect(); err != nil {
+		errs = append(errs, fmt.Errorf("registry_url: cannot connect to %s: %s", a.config.RegistryURL, err))
+	} else if err := client.CheckAccess(); err != nil {
+		errs = append(errs, fmt.Errorf("registry_username/registry_password: %s", err))
+	}
+	if err := a.eventListener.CheckDatabase(); err != nil {
+		errs = append(errs, fmt.Errorf("event_database_location: %s", err))
+	}
+	return errs
+}
+
+// exitWithErrors print errors and exit.
+func exitWithErrors(errs ...error) {
+	fmt.Fprintln(os.Stderr, "Configuration problems found:")
+	for _, err := range errs {
+		fmt.Fprintf(os.Stderr, "  * %s\n", err)
+	}
+	os.Exit(1)
 }
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"time"
+
+	"github.com/robfig/cron"
+)
+
+// maintenanceWindow periods destructive jobs are allowed to run in, they start by cron schedule and last for the duration.
+type maintenanceWindow struct {
+	schedule cron.Schedule
+	duration time.Duration
+}
+
+// newMaintenanceWindow parse the schedule, nil window means destructive jobs can run any time.
+func newMaintenanceWindow(schedule string, minutes int) (*maintenanceWindow, error) {
+	if schedule == "" {
+		return nil, nil
+	}
+	s, err := cron.Parse(schedule)
+	if err != nil {
+		return nil, err
+	}
+	return &maintenanceWindow{schedule: s, duration: time.Duration(minutes) * time.Minute}, nil
+}
+
+// isOpen check if the time falls into a window, i.e. the window has started within the duration before it.
+func (w *maintenanceWindow) isOpen(t time.Time) bool {
+	if w == nil {
+		return true
+	}
+	return !w.schedule.Next(t.Add(-w.duration)).After(t)
+}
+
+// nextOpen the time the next window opens at, or the time itself if the window is open.
+func (w *maintenanceWindow) nextOpen(t time.Time) time.Time {
+	if w.isOpen(t) {
+		return t
+	}
+	return w.schedule.Next(t)
+}
+
+// wait block until the window opens, the message is logged if it has to wait.
+func (w *maintenanceWindow) wait(logf func(format string, args ...interface{})) {
+	now := time.Now()
+	if w.isOpen(now) {
+		return
+	}
+	next := w.nextOpen(now)
+	logf("Waiting for the maintenance window opening at %s", next.Format("2006-01-02 15:04:05"))
+	time.Sleep(next.Sub(now))
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/quiq/docker-registry-ui/registry"
+)
+
+// metadataColumnPrefix prefix of the names of custom columns, so they never clash with the built-in ones.
+const metadataColumnPrefix = "meta_"
+
+// defaultMetadataHookTimeout how long the tag list waits for a hook when its timeout is not set.
+const defaultMetadataHookTimeout = 5 * time.Second
+
+// metadataNameRegexp allowed names of the custom columns.
+var metadataNameRegexp = regexp.MustCompile(`^[a-z0-9_]+$`)
+
+// metadataCell value of a custom column for a tag.
+type metadataCell struct {
+	Text string `json:"text"`
+	// Link opened by clicking the text, only http(s) and relative links are kept.
+	Link  string `json:"link"`
+	Title string `json:"title"`
+	// Class bootstrap label class to show the text as a label, e.g. success or danger, plain text if empty.
+	Class string `json:"class"`
+}
+
+// metadataProvider source of a custom column of the tag list, e.g. ticket links or deployment status
+// from internal systems. Providers built into a custom binary register themselves by registerMetadataProvider
+// from init(), the HTTP hooks are configured by metadata_columns.
+type metadataProvider interface {
+	Column() tagColumn
+	// Cells values by tag, the tags without a value are left empty.
+	Cells(repo string, tags []registry.TagMeta) (map[string]metadataCell, error)
+}
+
+// builtinMetadataProviders registered by registerMetadataProvider.
+var builtinMetadataProviders []metadataProvider
+
+// registerMetadataProvider add a custom column provided by the code built into the binary.
+func registerMetadataProvider(p metadataProvider) {
+	builtinMetadataProviders = append(builtinMetadataProviders, p)
+}
+
+// metadataHook custom column filled by an HTTP hook.
+type metadataHook struct {
+	// Name of the column in the preferences of visible columns.
+	Name  string `yaml:"name"`
+	Title string `yaml:"title"`
+	URL   string `yaml:"url"`
+	// Token sent as bearer token if set.
+	Token string `yaml:"token"`
+	// Timeout in seconds.
+	Timeout int `yaml:"timeout"`
+}
+
+// metadataHookTag tag sent to the hook.
+type metadataHookTag struct {
+	Tag     string    `json:"tag"`
+	Digest  string    `json:"digest"`
+	Created time.Time `json:"created"`
+}
+
+// String hook with the token masked, as shown on Options page.
+func (h metadataHook) String() string {
+	token := ""
+	if h.Token != "" {
+		token = "******"
+	}
+	return fmt.Sprintf("{%s %s %s %s %d}", h.Name, h.Title, h.URL, token, h.Timeout)
+}
+
+// Column custom column of the hook.
+func (h metadataHook) Column() tagColumn {
+	title := h.Title
+	if title == "" {
+		title = h.Name
+	}
+	return tagColumn{metadataColumnPrefix + h.Name, title}
+}
+
+// Cells post the repo with its tags to the hook, it responds with the cells by tag, e.g.
+// {"v1.0": {"text": "deployed", "class": "success", "link": "https://deploy.local/app"}}.
+func (h metadataHook) Cells(repo string, tags []registry.TagMeta) (map[string]metadataCell, error) {
+	request := struct {
+		Repository string            `json:"repository"`
+		Tags       []metadataHookTag `json:"tags"`
+	}{Repository: repo, Tags: []metadataHookTag{}}
+	for _, t := range tags {
+		request.Tags = append(request.Tags, metadataHookTag{Tag: t.Tag, Digest: t.Digest, Created: t.Created})
+	}
+	body, _ := json.Marshal(request)
+	req, err := http.NewRequest("POST", h.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if h.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+h.Token)
+	}
+	timeout := defaultMetadataHookTimeout
+	if h.Timeout > 0 {
+		timeout = time.Duration(h.Timeout) * time.Second
+	}
+	resp, err := (&http.Client{Timeout: timeout}).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("hook returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	cells := map[string]metadataCell{}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 10*1024*1024)).Decode(&cells); err != nil {
+		return nil, fmt.Errorf("cannot parse hook response: %s", err)
+	}
+	return cells, nil
+}
+
+// newMetadataProviders built-in providers followed by the configured hooks.
+func (a *apiClient) newMetadataProviders() []metadataProvider {
+	providers := append([]metadataProvider{}, builtinMetadataProviders...)
+	for _, h := range a.config.MetadataColumns {
+		providers = append(providers, h)
+	}
+	return providers
+}
+
+// allTagColumns built-in optional columns of the tag list followed by the custom ones.
+func (a *apiClient) allTagColumns() []tagColumn {
+	columns := append([]tagColumn{}, tagColumns...)
+	for _, p := range a.metadata {
+		columns = append(columns, p.Column())
+	}
+	return columns
+}
+
+// metadataCells cells of the visible custom columns aligned with the tags by index,
+// the providers are called in parallel and the failed ones leave their cells empty.
+func (a *apiClient) metadataCells(repo string, tags []registry.TagMeta, visible map[string]bool) ([]tagColumn, [][]metadataCell) {
+	var providers []metadataProvider
+	columns := []tagColumn{}
+	for _, p := range a.metadata {
+		if visible[p.Column().Name] {
+			providers = append(providers, p)
+			columns = append(columns, p.Column())
+		}
+	}
+	results := make([]map[string]metadataCell, len(providers))
+	var wg sync.WaitGroup
+	for i, p := range providers {
+		wg.Add(1)
+		go func(i int, p metadataProvider) {
+			defer wg.Done()
+			cells, err := p.Cells(repo, tags)
+			if err != nil {
+				a.logger.Warnf("Cannot get %s column of %s: %s", p.Column().Name, repo, err)
+				return
+			}
+			results[i] = cells
+		}(i, p)
+	}
+	wg.Wait()
+
+	cells := make([][]metadataCell, len(tags))
+	for i, t := range tags {
+		cells[i] = make([]metadataCell, len(providers))
+		for j := range providers {
+			cell := results[j][t.Tag]
+			if !strings.HasPrefix(cell.Link, "https://") && !strings.HasPrefix(cell.Link, "http://") &&
+				!(strings.HasPrefix(cell.Link, "/") && !strings.HasPrefix(cell.Link, "//")) {
+				cell.Link = ""
+			}
+			cells[i][j] = cell
+		}
+	}
+	return columns, cells
+}
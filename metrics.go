@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// metricLabelEscaper escape label values of Prometheus text format.
+var metricLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// metricSample value of the metric with the labels, e.g. `task="..."`.
+type metricSample struct {
+	labels string
+	value  float64
+}
+
+// writeGauge write the gauge in Prometheus text format.
+func writeGauge(b *bytes.Buffer, name, help string, samples ...metricSample) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s gauge\n", name, help, name)
+	for _, s := range samples {
+		value := strconv.FormatFloat(s.value, 'f', -1, 64)
+		if s.labels != "" {
+			fmt.Fprintf(b, "%s{%s} %s\n", name, s.labels, value)
+		} else {
+			fmt.Fprintf(b, "%s %s\n", name, value)
+		}
+	}
+}
+
+// unixTime seconds since epoch, 0 for zero time, so "time() - metric" alerts fire before the first success too.
+func unixTime(t time.Time) float64 {
+	if t.IsZero() {
+		return 0
+	}
+	return float64(t.UnixNano()) / 1e9
+}
+
+// boolValue 1 for true.
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// viewMetrics Prometheus metrics of the freshness of the catalog, tag counts and other background tasks,
+// designed for alerting rules on stale caches rather than for graphs.
+func (a *apiClient) viewMetrics(c echo.Context) error {
+	if a.config.MetricsToken != "" {
+		token := strings.TrimPrefix(c.Request().Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(a.config.MetricsToken)) != 1 {
+			return c.String(http.StatusUnauthorized, "Invalid metrics token.\n")
+		}
+	}
+
+	b := &bytes.Buffer{}
+	writeGauge(b, "registry_ui_build_info", "Version of Docker Registry UI.",
+		metricSample{`version="` + version + `"`, 1})
+	writeGauge(b, "registry_ui_registry_up", "Whether the registry was reachable at startup or reconnected since, 0 in degraded mode.",
+		metricSample{"", boolValue(a.registry.get().Error == "")})
+
+	listedAt, repos := a.client.CatalogStatus()
+	loading, _ := a.client.CatalogLoading()
+	writeGauge(b, "registry_ui_catalog_last_success_timestamp", "Unix time the catalog was listed completely the last time, 0 before that.",
+		metricSample{"", unixTime(listedAt)})
+	writeGauge(b, "registry_ui_catalog_loading", "Whether the catalog is still being listed for the first time.",
+		metricSample{"", boolValue(loading)})
+	writeGauge(b, "registry_ui_catalog_repositories", "Repositories in the cached catalog.",
+		metricSample{"", float64(repos)})
+
+	tasks := a.tasks.list()
+	var lastSuccess, duration, running, failed []metricSample
+	for _, t := range tasks {
+		labels := `task="` + metricLabelEscaper.Replace(t.Name) + `"`
+		if t.Name == refreshTagsTask {
+			writeGauge(b, "registry_ui_tags_refresh_duration_seconds", "How long the last refresh of the catalog and tag counts took.",
+				metricSample{"", t.LastDuration().Seconds()})
+			writeGauge(b, "registry_ui_tags_refresh_last_success_timestamp", "Unix time the refresh of the catalog and tag counts finished the last time, 0 before that.",
+				metricSample{"", unixTime(t.LastSucceeded)})
+		}
+		lastSuccess = append(lastSuccess, metricSample{labels, unixTime(t.LastSucceeded)})
+		duration = append(duration, metricSample{labels, t.LastDuration().Seconds()})
+		running = append(running, metricSample{labels, boolValue(t.Status == taskRunning)})
+		failed = append(failed, metricSample{labels, boolValue(t.Status == taskFailed)})
+	}
+	writeGauge(b, "registry_ui_task_last_success_timestamp", "Unix time the background task finished without error the last time, 0 before that.", lastSuccess...)
+	writeGauge(b, "registry_ui_task_last_duration_seconds", "How long the last run of the background task took.", duration...)
+	writeGauge(b, "registry_ui_task_running", "Whether the background task is running now.", running...)
+	writeGauge(b, "registry_ui_task_last_run_failed", "Whether the last run of the background task failed.", failed...)
+
+	return c.Blob(http.StatusOK, "text/plain; version=0.0.4; charset=utf-8", b.Bytes())
+}
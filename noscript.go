@@ -0,0 +1,165 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"net/url"
+	"sort"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"github.com/quiq/docker-registry-ui/events"
+	"github.com/quiq/docker-registry-ui/registry"
+)
+
+// noscriptPageSize rows per page of the tables sorted and paginated server-side.
+const noscriptPageSize = 50
+
+// tablePager server-side sorting and pagination of a table in noscript mode,
+// otherwise DataTables does it in the browser and the pager is disabled.
+type tablePager struct {
+	Enabled bool
+	Sort    string
+	Desc    bool
+	Page    int
+	Pages   int
+	Total   int
+	columns map[string]bool
+}
+
+// tablePage sort the rows by the sort and order params and cut the page by page param in noscript mode.
+// less compares the rows by their index for each sortable column. Returns the row indexes to show,
+// all rows in their order unless in noscript mode.
+func (a *apiClient) tablePage(c echo.Context, total int, defaultSort string, defaultDesc bool,
+	less map[string]func(i, j int) bool) (tablePager, []int) {
+	rows := make([]int, total)
+	for i := range rows {
+		rows[i] = i
+	}
+	if !a.config.NoscriptMode || csvRequested(c) {
+		return tablePager{}, rows
+	}
+
+	p := tablePager{Enabled: true, Sort: defaultSort, Desc: defaultDesc, Page: 1, Total: total, columns: map[string]bool{}}
+	for column := range less {
+		p.columns[column] = true
+	}
+	if column := c.QueryParam("sort"); p.columns[column] {
+		p.Sort = column
+		p.Desc = c.QueryParam("order") == "desc"
+	}
+	if cmp, ok := less[p.Sort]; ok {
+		sort.SliceStable(rows, func(i, j int) bool {
+			if p.Desc {
+				return cmp(rows[j], rows[i])
+			}
+			return cmp(rows[i], rows[j])
+		})
+	}
+
+	p.Pages = (total + noscriptPageSize - 1) / noscriptPageSize
+	if p.Pages == 0 {
+		p.Pages = 1
+	}
+	if page, err := strconv.Atoi(c.QueryParam("page")); err == nil && page >= 1 && page <= p.Pages {
+		p.Page = page
+	}
+	from := (p.Page - 1) * noscriptPageSize
+	to := from + noscriptPageSize
+	if to > total {
+		to = total
+	}
+	return p, rows[from:to]
+}
+
+// link query of the table sorted by the column on the page.
+func (p tablePager) link(column string, desc bool, page int) string {
+	q := url.Values{}
+	q.Set("sort", column)
+	if desc {
+		q.Set("order", "desc")
+	} else {
+		q.Set("order", "asc")
+	}
+	if page > 1 {
+		q.Set("page", strconv.Itoa(page))
+	}
+	return "?" + q.Encode()
+}
+
+// Header title of the column header, a link sorting by the column if it is sortable server-side.
+// Sorting by the current column again reverses the order.
+func (p tablePager) Header(column, title string) string {
+	title = html.EscapeString(title)
+	if !p.Enabled || !p.columns[column] {
+		return title
+	}
+	desc := false
+	mark := ""
+	if column == p.Sort {
+		desc = !p.Desc
+		mark = " &#9650;"
+		if p.Desc {
+			mark = " &#9660;"
+		}
+	}
+	return fmt.Sprintf(`<a href="%s">%s</a>%s`, html.EscapeString(p.link(column, desc, 1)), title, mark)
+}
+
+// PageLink query of the page keeping the sorting.
+func (p tablePager) PageLink(page int) string {
+	return p.link(p.Sort, p.Desc, page)
+}
+
+// PageNumbers all page numbers for the page links.
+func (p tablePager) PageNumbers() []int {
+	pages := make([]int, p.Pages)
+	for i := range pages {
+		pages[i] = i + 1
+	}
+	return pages
+}
+
+// From number of the first row on the page for "rows 1-50 of 120".
+func (p tablePager) From() int {
+	if p.Total == 0 {
+		return 0
+	}
+	return (p.Page-1)*noscriptPageSize + 1
+}
+
+// To number of the last row on the page.
+func (p tablePager) To() int {
+	to := p.Page * noscriptPageSize
+	if to > p.Total {
+		to = p.Total
+	}
+	return to
+}
+
+// pageTagsMetadata metadata of the tags by their index, fetched only for the rows of the page and the locked
+// and protected tags, the others have just the tag set.
+func (a *apiClient) pageTagsMetadata(repo string, tags []string, rows []int, locks []events.TagLock, patterns []string) []registry.TagMeta {
+	needed := map[int]bool{}
+	for _, row := range rows {
+		needed[row] = true
+	}
+	locked := map[string]bool{}
+	for _, l := range locks {
+		locked[l.Tag] = true
+	}
+	list := make([]registry.TagMeta, len(tags))
+	var indexes []int
+	var fetch []string
+	for i, tag := range tags {
+		list[i] = registry.TagMeta{Repo: repo, Tag: tag}
+		if needed[i] || locked[tag] || protectedBy(patterns, tag) != "" {
+			indexes = append(indexes, i)
+			fetch = append(fetch, tag)
+		}
+	}
+	for i, meta := range a.client.TagsMetadata(repo, fetch) {
+		list[indexes[i]] = meta
+	}
+	return list
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+var pathParamRegexp = regexp.MustCompile(`:(\w+)`)
+
+// openAPISpec generate OpenAPI 3 document from the API routes.
+func openAPISpec(routes []apiRoute, basePath string) map[string]interface{} {
+	schemas := map[string]interface{}{}
+	paths := map[string]interface{}{}
+	for _, r := range routes {
+		params := []interface{}{}
+		for _, p := range r.Params {
+			params = append(params, map[string]interface{}{
+				"name": p.Name, "in": p.In, "description": p.Description, "required": p.Required || p.In == "path",
+				"schema": map[string]interface{}{"type": p.Type},
+			})
+		}
+		content := map[string]interface{}{"text/plain": map[string]interface{}{"schema": map[string]interface{}{"type": "string"}}}
+		if r.Response != nil {
+			content = map[string]interface{}{"application/json": map[string]interface{}{"schema": typeSchema(reflect.TypeOf(r.Response), schemas)}}
+		}
+		op := map[string]interface{}{
+			"summary":     r.Summary,
+			"operationId": operationID(r),
+			"parameters":  params,
+			"responses": map[string]interface{}{
+				"200": map[string]interface{}{"description": "OK", "content": content},
+			},
+		}
+		if r.Body != nil {
+			op["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content":  map[string]interface{}{"application/json": map[string]interface{}{"schema": typeSchema(reflect.TypeOf(r.Body), schemas)}},
+			}
+		}
+		if r.Auth {
+			op["security"] = []interface{}{map[string]interface{}{"eventListenerToken": []string{}}}
+		} else {
+			// API token is optional unless api_require_token is set.
+			op["security"] = []interface{}{map[string]interface{}{"apiToken": []string{}}, map[string]interface{}{}}
+		}
+		op["responses"].(map[string]interface{})["401"] = map[string]interface{}{"description": "Missing or invalid token"}
+		if !r.Auth && len(r.Params) > 0 {
+			op["responses"].(map[string]interface{})["400"] = map[string]interface{}{
+				"description": "Invalid parameters",
+				"content": map[string]interface{}{"application/json": map[string]interface{}{
+					"schema": map[string]interface{}{"type": "object", "properties": map[string]interface{}{
+						"error":      map[string]interface{}{"type": "string"},
+						"request_id": map[string]interface{}{"type": "string"},
+					}},
+				}},
+			}
+		}
+
+		path := pathParamRegexp.ReplaceAllString(r.Path, "{$1}")
+		if paths[path] == nil {
+			paths[path] = map[string]interface{}{}
+		}
+		paths[path].(map[string]interface{})[strings.ToLower(r.Method)] = op
+	}
+
+	server := basePath
+	if server == "" {
+		server = "/"
+	}
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info":    map[string]interface{}{"title": "Docker Registry UI API", "version": version},
+		"servers": []interface{}{map[string]interface{}{"url": server}},
+		"paths":   paths,
+		"components": map[string]interface{}{
+			"schemas": schemas,
+			"securitySchemes": map[string]interface{}{
+				"apiToken":           map[string]interface{}{"type": "http", "scheme": "bearer"},
+				"eventListenerToken": map[string]interface{}{"type": "http", "scheme": "bearer"},
+			},
+		},
+	}
+}
+
+// operationID name of the operation for generated clients, e.g. getApiV1Repos.
+func operationID(r apiRoute) string {
+	id := strings.ToLower(r.Method)
+	for _, f := range strings.FieldsFunc(r.Path, func(c rune) bool { return c == '/' || c == '.' || c == ':' || c == '_' }) {
+		id = id + strings.ToUpper(f[:1]) + f[1:]
+	}
+	return id
+}
+
+// typeSchema JSON schema of the Go type, structs are added to schemas and referenced by name.
+func typeSchema(t reflect.Type, schemas map[string]interface{}) map[string]interface{} {
+	if t == reflect.TypeOf(time.Time{}) {
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		return typeSchema(t.Elem(), schemas)
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": typeSchema(t.Elem(), schemas)}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": typeSchema(t.Elem(), schemas)}
+	case reflect.Struct:
+		name := schemaName(t)
+		if _, ok := schemas[name]; !ok {
+			// Reserve the name first for recursive types.
+			schemas[name] = nil
+			props := map[string]interface{}{}
+			for i := 0; i < t.NumField(); i++ {
+				f := t.Field(i)
+				tag := strings.Split(f.Tag.Get("json"), ",")[0]
+				if f.PkgPath != "" || tag == "-" {
+					continue
+				}
+				if tag == "" {
+					tag = f.Name
+				}
+				props[tag] = typeSchema(f.Type, schemas)
+			}
+			schemas[name] = map[string]interface{}{"type": "object", "properties": props}
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+	}
+	return map[string]interface{}{}
+}
+
+// schemaName name of the struct for the spec, e.g. CatalogRepo or ReposResponse.
+func schemaName(t reflect.Type) string {
+	name := t.Name()
+	if strings.HasPrefix(name, "api") {
+		name = name[3:]
+	}
+	return strings.ToUpper(name[:1]) + name[1:]
+}
+
+// apiSpec serve OpenAPI spec of the JSON API.
+func (a *apiClient) apiSpec(c echo.Context) error {
+	return c.JSON(http.StatusOK, openAPISpec(a.apiRoutes(), a.config.BasePath))
+}
+
+// viewAPIDocs view the API documentation.
+func (a *apiClient) viewAPIDocs(c echo.Context) error {
+	data := a.setUserPermissions(c)
+	data.Set("routes", a.apiRoutes())
+	return c.Render(http.StatusOK, "api_docs.html", data)
+}
@@ -0,0 +1,367 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/quiq/docker-registry-ui/events"
+	"github.com/quiq/docker-registry-ui/kubernetes"
+	"github.com/quiq/docker-registry-ui/lint"
+	"github.com/quiq/docker-registry-ui/registry"
+	"gopkg.in/yaml.v2"
+)
+
+// envPrefix prefix of the env vars overriding the settings, e.g. REGISTRY_UI_REGISTRY_URL for registry_url.
+const envPrefix = "REGISTRY_UI_"
+
+// configOption setting of the config file with the value used when it is omitted.
+type configOption struct {
+	Key     string
+	Default interface{}
+	Help    string
+}
+
+// configGroup related settings documented together.
+type configGroup struct {
+	Title   string
+	Options []configOption
+}
+
+// configOptions schema of all supported settings, the defaults are applied to the omitted ones
+// and -print-default-config dumps it as a commented config file.
+var configOptions = []configGroup{
+	{"Web server", []configOption{
+		{"listen_addr", "0.0.0.0:8000", "Listen interface."},
+		{"base_path", "", "Base path of Docker Registry UI, e.g. /registry-ui when served under a sub-path."},
+		{"debug", false, "Debug mode, templates are reloaded on every request."},
+		{"templates_override_dir", "", "Directory with templates shadowing the built-in ones by the same file name, e.g. to add columns or internal links.\n" +
+			"Templates missing from it are taken from the built-in ones, the overrides are reloaded on change in debug mode."},
+		{"noscript_mode", false, "Render pages without JavaScript for locked-down browsers and screen readers, the repository, tag\n" +
+			"and event tables are sorted and paginated server-side by links."},
+		{"response_compression", true, "Compress pages and API responses by gzip for the browsers accepting it, disable when the reverse proxy\n" +
+			"compresses them already."},
+		{"access_log", "", "HTTP access log of UI requests: stdout or file path to append to, empty disables it.\n" +
+			"Errors and warnings of UI are logged to stdout regardless."},
+		{"access_log_format", "combined", "Access log format: combined (Apache/nginx style) or json with one object per line."},
+		{"access_log_skip_paths", []string{}, "Request paths not to log, e.g. health checks of the load balancer, a trailing * matches by prefix."},
+		{"access_log_sample_rate", 1.0, "Share of requests to log from 0 to 1, e.g. 0.1 logs every tenth request on average.\n" +
+			"Server errors are always logged."},
+		{"sentry_dsn", "", "Sentry DSN to report errors to: failed requests, panics, failed jobs and background tasks and registry\n" +
+			"client errors, e.g. https://<key>@sentry.local/<project id>"},
+		{"error_webhook_url", "", "URL to post the same errors to as JSON, e.g. of an alerting service."},
+		{"error_report_interval", 600, "Seconds to report the same error once per, the repeats are counted and sent with the next report."},
+		{"metrics", false, "Serve Prometheus metrics at /metrics for alerting on stale catalog, tag counts and failing background tasks."},
+		{"metrics_token", "", "Bearer token required by /metrics, empty serves it to anyone."},
+		{"debug_endpoints", false, "Serve Go profiles at /debug/pprof/ and runtime stats at /debug/runtime to admins, to diagnose memory growth."},
+	}},
+	{"Registry", []configOption{
+		{"registry_url", "", "Registry URL with schema and port, required, e.g. https://docker-registry.local\n" +
+			"It may include the path for registries under a sub-path, e.g. https://artifactory.local/artifactory/api/docker/docker-local"},
+		{"verify_tls", false, "Verify TLS certificate when using https."},
+		{"registry_username", "", "Registry credentials, they need to have a full access to the registry.\n" +
+			"If token authentication service is enabled, it is auto-discovered and the credentials are used to obtain access tokens."},
+		{"registry_password", "", ""},
+		{"registry_password_file", "", "File with the password, e.g. docker secret, overrides registry_password.\n" +
+			"It is re-read every minute, so the secret can be rotated without restart."},
+		{"registry_mock", false, "Run with in-memory mock registry seeded from the fixture file instead of the real one, e.g. for demos.\n" +
+			"registry_url and credentials are ignored then. Changes made to the mock registry are lost on restart."},
+		{"registry_mock_fixture", "mock-registry.yml", ""},
+		{"registry_pull_host", "", "Host to pull images from shown in image names, defaults to the host of registry_url."},
+		{"registry_pull_hosts", []registry.PullHost{}, "Pull hosts by repo for Nexus or Artifactory, the first matching item is used: repos is a repo path,\n" +
+			"a trailing * matches by prefix, empty matches all. {namespace} in host is replaced with the first segment\n" +
+			"of the repo path, strip_namespace removes it from the image path. E.g.\n" +
+			"- repos: team/*\n  host: nexus.local:8083\n- host: '{namespace}.artifactory.local'\n  strip_namespace: true"},
+		{"registry_flavor", registry.FlavorAuto, "Registry flavor: auto, distribution, harbor or quay. Harbor and Quay APIs are used for tag metadata,\n" +
+			"pull counts and garbage collection status, auto detects them falling back to distribution."},
+		{"registry_api_token", "", "Quay API requires OAuth access token of a Quay application, Harbor API accepts the registry credentials."},
+		{"registry_api_token_file", "", "File with the token, overrides registry_api_token, re-read every minute like registry_password_file."},
+		{"max_concurrent_registry_requests", 0, "How many requests to send to registry at once from UI pages and background tasks together,\n" +
+			"the others wait, e.g. not to overwhelm a small registry. 0 means no limit."},
+		{"registry_connect_retries", 5, "How many times to retry connecting to the registry at startup, waiting 1s, 2s, 4s and so on between.\n" +
+			"If it is still unreachable, UI starts in degraded mode showing a banner and keeps connecting every minute."},
+	}},
+	{"Pull-through cache", []configOption{
+		{"proxy_remote_url", "", "Upstream of the registry running as a pull-through cache, the same as proxy.remoteurl of the registry config,\n" +
+			"e.g. https://registry-1.docker.io. Cached repos show their upstream reference and stale tags."},
+		{"proxy_username", "", ""},
+		{"proxy_password", "", ""},
+	}},
+	{"Base images", []configOption{
+		{"base_image_upstreams", []registry.BaseUpstream{}, "Registries of the base images to flag images built on an outdated base, the first item with the prefix\n" +
+			"of the full base image name is used, e.g. docker.io/ for alpine:3.12. The base image is given by the\n" +
+			"org.opencontainers.image.base.name annotation or label. E.g.\n" +
+			"- prefix: docker.io/\n  url: https://registry-1.docker.io\n- prefix: quay.io/\n  url: https://quay.io"},
+	}},
+	{"Event listener", []configOption{
+		{"event_listener_token", "", "Token the registry sends events with as Authorization Bearer token."},
+		{"event_listener_tokens", []string{}, "Additional accepted tokens, e.g. the new and the old one while rotating it."},
+		{"event_listener_token_file", "", "File with accepted tokens, one per line, re-read when changed."},
+		{"event_retention_days", 0, "Days to keep the events for."},
+		{"event_database_driver", "sqlite3", "Event storage: sqlite3 or mysql."},
+		{"event_database_location", "data/registry_events.db", "Path of sqlite db file or mysql DSN, e.g. user:password@tcp(localhost:3306)/docker_events"},
+		{"event_deletion_enabled", false, "Purge old events, disable on some hosts of master-master or cluster setup to avoid deadlocks."},
+		{"restore_max_upload_mb", 2048, "The largest snapshot of the event database accepted for restore, 0 for unlimited."},
+		{"event_dedup_seconds", 60, "Seconds to drop the repeats of the same event for, the same action on the same tag and digest by the same user\n" +
+			"and IP, e.g. the notifications resent by the registry. 0 disables it."},
+		{"event_aggregate_seconds", 0, "Seconds to collect the tags pushed with the same digest by the same user for, e.g. by one CI run, they are stored\n" +
+			"and sent to webhooks and email rules as one event after it. 0 disables it. Both are in memory of every UI instance."},
+		{"webhook_attempts", 5, "Attempts to deliver the event to the webhooks registered for the repos, the delay doubles from 10 seconds."},
+	}},
+	{"Email notifications", []configOption{
+		{"smtp_host", "", "SMTP server to send the emails of email_rules by, STARTTLS is used when the server offers it."},
+		{"smtp_port", 587, ""},
+		{"smtp_tls", false, "Connect by TLS from the start, usually on port 465."},
+		{"smtp_username", "", "Credentials of PLAIN authentication, empty sends without it."},
+		{"smtp_password", "", ""},
+		{"smtp_from", "", "Sender address, e.g. Registry UI <registry-ui@example.com>"},
+		{"email_rules", []emailRule{}, "Recipients notified of push, pull or delete events or of vulnerabilities found by scans of the matching repos.\n" +
+			"repos is a repo path, a trailing * matches by prefix. severity is the lowest one of vulnerability rules, Critical by default.\n" +
+			"subject and body are Go text/template with the fields of the webhook JSON and .Summary, .Vulnerabilities and .Count. E.g.\n" +
+			"- event: delete\n  repos: prod/*\n  recipients: [release@example.com]\n" +
+			"- event: vulnerability\n  repos: '*'\n  severity: Critical\n  recipients: [security@example.com]\n" +
+			"  subject: 'Critical CVEs in {{.Image}}'"},
+	}},
+	{"Cache and search", []configOption{
+		{"cache_refresh_interval", 10, "Minutes to cache repository list and tag counts for."},
+		{"catalog_refresh_cron", "", "Cron schedule (with seconds) to refresh tag counts and search index instead, e.g. '0 0 3 * * *'."},
+		{"search_index_metadata", false, "Index image labels and annotations too, this fetches metadata of all tags on every refresh."},
+		{"page_cache_max_age", 300, "Seconds to serve the rendered repository list, storage and pull-through cache pages from cache for,\n" +
+			"they are rendered again sooner when the catalog or tag counts change. 0 disables it."},
+		{"tag_cache_max_repos", 0, "Bounds of the tag metadata cache, the least recently used tags are evicted over them, 0 means no limit.\n" +
+			"An entry takes roughly 0.5-2 KB depending on the labels, see the Diagnostics page for the current size."},
+		{"tag_cache_max_tags_per_repo", 0, ""},
+		{"tag_cache_max_entries", 200000, ""},
+		{"catalog_resume_file", "", "File to keep the pages of the catalog listed in, the listing interrupted by registry errors\n" +
+			"or restart continues from the last page instead of starting over. Empty keeps them in memory only."},
+		{"config_cache_dir", "", "Directory to cache image configs in by digest, they are immutable so the created dates, labels and ports\n" +
+			"are never fetched again, even after restart. Empty disables it, e.g. data/config-cache"},
+	}},
+	{"Access", []configOption{
+		{"user_header", "X-WEBAUTH-USER", "Header with the user name sent by your authenticating proxy, e.g. X-Forwarded-User of oauth2-proxy."},
+		{"trusted_proxies", []string{}, "CIDRs or IP addresses of the proxies allowed to send user_header and groups_header,\n" +
+			"the headers of requests from other addresses are ignored, so clients reaching UI directly cannot spoof them.\n" +
+			"Client IPs are taken from X-Forwarded-For set by these proxies then. Empty trusts any source, e.g. [10.0.0.0/8, 127.0.0.1]"},
+		{"api_require_token", false, "Require API token from requests not coming through the proxy with user_header."},
+		{"anyone_can_delete", false, "If users can delete tags, otherwise only admins."},
+		{"delete_reason_required", false, "Make the reason of deleting images mandatory."},
+		{"delete_undo_minutes", 0, "Minutes to keep the manifest of a tag deleted from UI, so the user can undo the deletion, 0 disables it.\n" +
+			"The undo fails if the garbage collection removed the blobs meanwhile."},
+		{"deletion_approvals", false, "Deletions by non-admins wait on Approvals page until another admin approves them, two-person rule."},
+		{"deletion_approvals_webhook_url", "", "URL to POST JSON about new, approved and rejected deletion requests to, its text field suits Slack or Mattermost."},
+		{"admins", []string{}, "Admin users sent via user_header from your proxy."},
+		{"deleters", []string{}, "Users allowed to delete tags manually besides admins, they cannot change retention rules."},
+		{"groups_header", "X-WEBAUTH-GROUPS", "Header with comma-separated groups of the user sent by your proxy, e.g. from OIDC groups claim."},
+		{"admin_groups", []string{}, "Groups whose members are admins and deleters, in addition to the users listed above."},
+		{"deleter_groups", []string{}, ""},
+		{"retention_previewers", []string{}, "Users allowed to preview purging old tags by dry-run but not to purge them."},
+		{"retention_managers", []string{}, "Users allowed to preview and run purging old tags and to change retention rules."},
+		{"tenants", []tenant{}, "Restrict the repos visible to users by their groups, admins see all repos. Users outside any tenant see none.\n" +
+			"The groups are sent by your proxy in groups_header, users are matched by user_header. E.g.\n" +
+			"- group: payments\n  users: [alice]\n  repos: [payments/*, library/postgres]"},
+		{"tenant_groups_header", "", "Former name of groups_header, it is used instead of groups_header when set."},
+		{"local_users", false, "Log in by the form with the user accounts stored in the event database, for teams without SSO or auth proxy.\n" +
+			"Manage them by -set-user-password, -delete-user and -list-users flags. user_header and groups_header are ignored\n" +
+			"unless trusted_proxies are listed, then users authenticated by the proxy skip the form."},
+		{"session_max_age_hours", 12, "Hours the user stays logged in with local_users."},
+		{"login_attempts_per_minute", 10, "Login attempts allowed per client IP in a minute, 0 disables the limit."},
+		{"login_max_failures", 5, "Failed logins in a row locking the user out for login_lockout_minutes, 0 disables the lockout.\n" +
+			"Logins, failed logins and lockouts are recorded in the audit log."},
+		{"login_lockout_minutes", 15, ""},
+	}},
+	{"Tag list", []configOption{
+		{"image_age_warning_days", 0, "Image age thresholds in days to highlight stale images, 0 disables the threshold."},
+		{"image_age_critical_days", 0, ""},
+		{"metadata_columns", []metadataHook{}, "Custom columns of the tag list filled by HTTP hooks, e.g. ticket links or deployment status from internal systems.\n" +
+			"The hook gets POST with JSON {\"repository\": ..., \"tags\": [{\"tag\": ..., \"digest\": ..., \"created\": ...}]} and responds\n" +
+			"with the cells by tag {\"v1\": {\"text\": ..., \"link\": ..., \"title\": ..., \"class\": \"success\"}}. token is sent as bearer token, timeout is in seconds. E.g.\n" +
+			"- name: deployed\n  title: Deployed\n  url: https://deploy.local/registry-hook\n  timeout: 3"},
+		{"tag_conventions", lint.Conventions{}, "Expected tag patterns of the repos by prefix, the longest prefix wins and empty one is for all repos.\n" +
+			"Patterns are semver, date, sha, latest or regular expressions matching the whole tag. Tags not matching any are marked. E.g.\n" +
+			"- prefix: team/\n  patterns: [semver, latest]"},
+		{"image_links", []imageLink{}, "Buttons on the image page linking to external systems, e.g. in-house Git or CI. url is a template with\n" +
+			"placeholders {repo}, {namespace}, {tag}, {digest}, {source}, {source_repo}, {revision}, {label:<key>} and {annotation:<key>},\n" +
+			"source and revision are the ones of the Build section. The link is hidden when a placeholder is unknown for the image.\n" +
+			"repos is a repo path, a trailing * matches by prefix, empty matches all. E.g.\n" +
+			"- title: Commit\n  url: https://git.internal/{source_repo}/commit/{revision}\n" +
+			"- title: CI build\n  url: '{label:com.example.ci.build-url}'"},
+	}},
+	{"Repo owners", []configOption{
+		{"repo_owners", []events.RepoOwner{}, "Teams owning the repos shown on repo pages and in delete confirmations, so people know who to ask.\n" +
+			"repos is a repo path, a trailing * matches by prefix, the most specific pattern wins. Admins can add more on Owners page. E.g.\n" +
+			"- repos: team/*\n  team: Core\n  slack: '#core'\n  email: core@example.com"},
+		{"stale_repo_days", 0, "Repos with no pushes, new tags or newly built images for this many days are listed on Stale page, 0 disables it."},
+	}},
+	{"Purging tags", []configOption{
+		{"purge_tags_keep_days", 0, "How many days to keep tags but also keep the minimal count provided no matter how old."},
+		{"purge_tags_keep_count", 0, ""},
+		{"purge_tags_schedule", "", "Cron schedule (with seconds) to purge tags in server mode, e.g. '25 54 17 * * *', empty disables it."},
+	}},
+	{"Maintenance window", []configOption{
+		{"maintenance_window_schedule", "", "Cron schedule (with seconds) opening the window for destructive jobs, empty allows them any time."},
+		{"maintenance_window_duration", 0, "Minutes the window lasts."},
+	}},
+	{"Garbage collection", []configOption{
+		{"gc_command", "", "Shell command running the registry garbage collection, e.g.\n" +
+			"docker exec registry bin/registry garbage-collect /etc/docker/registry/config.yml"},
+		{"gc_url", "", "URL of the registry GC API, e.g. https://harbor.local/api/v2.0/system/gc/schedule"},
+		{"gc_url_method", "POST", ""},
+		{"gc_url_body", "", ""},
+		{"gc_url_username", "", ""},
+		{"gc_url_password", "", ""},
+		{"gc_after_deletions", false, "Run it after purging tags and renaming with deletion."},
+		{"gc_timeout", 60, "Minutes to wait for the command or API call, the command is killed then."},
+	}},
+	{"Vulnerability scanning", []configOption{
+		{"scanner", "", "grype or empty to disable."},
+		{"scanner_grype_path", "grype", ""},
+		{"scanner_grype_db_update_url", "", "Mirror of the vulnerability database listing for air-gapped installs."},
+		{"scanner_timeout", 10, "Minutes to wait for a scan."},
+	}},
+	{"Image signing", []configOption{
+		{"signing_cosign_path", "cosign", "Admins can sign images with cosign from the image page, the signature is pushed to the repo of the image."},
+		{"signing_key", "", "Key file or KMS URI of the signing key, e.g. /run/secrets/cosign.key or awskms:///alias/cosign."},
+		{"signing_key_password", "", ""},
+		{"signing_identity_token_file", "", "File with OIDC token for keyless signing instead of the key, re-read on every signing,\n" +
+			"e.g. a projected service account token. Fulcio and Rekor of a private Sigstore instance can be set, public ones are used otherwise."},
+		{"signing_fulcio_url", "", ""},
+		{"signing_rekor_url", "", ""},
+		{"signing_timeout", 5, "Minutes to wait for signing."},
+	}},
+	{"Policy", []configOption{
+		{"policy_require_signature", false, "Cosign signature tag sha256-<digest>.sig should exist in the repo."},
+		{"policy_require_scan", false, ""},
+		{"policy_max_severity", "", "No vulnerabilities of this severity or higher except accepted ones, e.g. High."},
+		{"policy_max_size_mb", 0, ""},
+		{"policy_allowed_base_images", []string{}, "Allowed base images without tag, a trailing * matches by prefix."},
+	}},
+	{"Lint", []configOption{
+		{"lint_rules", map[string]string{}, "Severity of the lint rules: error, warning, info or off. By default secret_env is error,\n" +
+			"created, large_layer and root_user are warning, latest_only and annotations are info."},
+		{"lint_max_layer_mb", 500, "Layers bigger than this are flagged, 0 disables the check."},
+		{"lint_required_annotations", []string{"org.opencontainers.image.source", "org.opencontainers.image.revision"},
+			"OCI annotations or labels every image should have."},
+		{"secret_scan_repos", []string{}, "Repos whose images are scanned for credentials like cloud keys, tokens and private keys in the config\n" +
+			"(env, labels, build history) and in the text files of small layers, a trailing * matches by prefix. Empty disables it."},
+		{"secret_scan_max_layer_mb", 10, "Larger layers are not read."},
+		{"secret_scan_max_file_kb", 256, "Larger files are not read."},
+	}},
+	{"Storage usage", []configOption{
+		{"storage_driver", "", "filesystem or s3, empty disables this feature."},
+		{"storage_filesystem_root", "/var/lib/registry", "The rootdirectory of the registry filesystem driver mounted to this container."},
+		{"storage_s3_bucket", "", ""},
+		{"storage_s3_region", "", ""},
+		{"storage_s3_endpoint", "", "Endpoint of S3 compatible storage like minio."},
+		{"storage_s3_access_key", "", "Falls back to AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY env vars."},
+		{"storage_s3_secret_key", "", ""},
+		{"storage_s3_root_directory", "", ""},
+	}},
+	{"Layer content", []configOption{
+		{"layer_files_max_size_mb", 256, "Admins can list files of the image layers, the layer tarball is streamed from the registry\n" +
+			"without writing it to disk. Larger layers are not listed, 0 disables this feature."},
+		{"layer_files_max_entries", 50000, "The listing stops after this many files."},
+		{"file_index_repos", []string{}, "Repos whose image files are indexed for the file search, a trailing * matches by prefix, e.g. team/*.\n" +
+			"Admins can opt repos in or out on the repo settings page."},
+		{"file_index_max_files", 0, "Storage budget of the file index in the event database: new layers are not indexed once it holds\n" +
+			"this many files. 0 disables the file search. Layers are read with the limits of layer_files_*."},
+		{"file_index_interval", 60, "Minutes between the indexer runs, only new layers are read."},
+		{"blob_graph_repos", []string{}, "Repos whose digest reference graph (index, manifests, configs and layers) is stored for the blob explorer,\n" +
+			"a trailing * matches by prefix. Empty disables the explorer."},
+		{"blob_graph_interval", 60, "Minutes between the graph rebuilds."},
+	}},
+	{"Image transfer", []configOption{
+		{"transfer_dir", "", "Directory for the OCI layout tarballs exported for transfer into air-gapped registries and the uploaded ones\n" +
+			"being imported, empty disables the transfer."},
+		{"transfer_keep_exports", 10, "Older exports are removed."},
+		{"transfer_max_upload_mb", 20480, "The largest tarball accepted for import, 0 for unlimited."},
+	}},
+	{"Kubernetes", []configOption{
+		{"kubernetes_clusters", []kubernetes.Cluster{}, "Clusters to look up the images of running pods in, the tags in use are marked and cannot be deleted.\n" +
+			"The token needs to be allowed to list pods in all namespaces. Empty server means the cluster the UI runs in. E.g.\n" +
+			"- name: prod\n  server: https://k8s.prod.local:6443\n  token_file: /run/secrets/prod-token\n  ca_file: /run/secrets/prod-ca.crt\n- name: local"},
+		{"kubernetes_refresh_interval", 5, "Minutes between the lookups."},
+	}},
+	{"Features", []configOption{
+		{"features", map[string]bool{}, "Turn subsystems on or off as a whole, they are on when their settings are set otherwise.\n" +
+			"Features: events, deletion, purging, scanning, signing, policy, storage, gc, cache, kubernetes. E.g. {deletion: false, events: false}"},
+	}},
+}
+
+// defaultConfig config file with all the settings set to their defaults, commented by groups.
+// The settings missing from the schema are listed at the end so none is left out.
+func defaultConfig() []byte {
+	var buf bytes.Buffer
+	documented := map[string]bool{}
+	for i, g := range configOptions {
+		if i > 0 {
+			buf.WriteString("\n")
+		}
+		fmt.Fprintf(&buf, "## %s\n", g.Title)
+		for _, o := range g.Options {
+			documented[o.Key] = true
+			if o.Help != "" {
+				for _, line := range strings.Split(o.Help, "\n") {
+					fmt.Fprintf(&buf, "# %s\n", line)
+				}
+			}
+			value, _ := yaml.Marshal(map[string]interface{}{o.Key: o.Default})
+			buf.Write(value)
+		}
+	}
+	header := false
+	t := reflect.TypeOf(configData{})
+	for i := 0; i < t.NumField(); i++ {
+		key := strings.Split(t.Field(i).Tag.Get("yaml"), ",")[0]
+		if key == "" || key == "-" || documented[key] {
+			continue
+		}
+		if !header {
+			buf.WriteString("\n## Other\n")
+			header = true
+		}
+		value, _ := yaml.Marshal(map[string]interface{}{key: reflect.Zero(t.Field(i).Type).Interface()})
+		buf.Write(value)
+	}
+	return buf.Bytes()
+}
+
+// envName env var of the setting.
+func envName(key string) string {
+	return envPrefix + strings.ToUpper(key)
+}
+
+// envConfigured whether any setting is set by env var.
+func envConfigured() bool {
+	for _, e := range os.Environ() {
+		if strings.HasPrefix(e, envPrefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// applyEnv override the settings by env vars. String settings are taken as is,
+// others are parsed as YAML, e.g. REGISTRY_UI_ADMINS='[alice, bob]' or REGISTRY_UI_VERIFY_TLS=true.
+func applyEnv(config *configData) error {
+	t := reflect.TypeOf(*config)
+	for i := 0; i < t.NumField(); i++ {
+		key := strings.Split(t.Field(i).Tag.Get("yaml"), ",")[0]
+		if key == "" || key == "-" {
+			continue
+		}
+		env, ok := os.LookupEnv(envName(key))
+		if !ok {
+			continue
+		}
+		if t.Field(i).Type.Kind() == reflect.String {
+			reflect.ValueOf(config).Elem().Field(i).SetString(env)
+			continue
+		}
+		if err := yaml.Unmarshal([]byte(key+": "+env), config); err != nil {
+			return fmt.Errorf("%s: cannot parse %q: %s", envName(key), env, err)
+		}
+	}
+	return nil
+}
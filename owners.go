@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/quiq/docker-registry-ui/events"
+	"github.com/quiq/docker-registry-ui/registry"
+)
+
+// validRepoPattern repo path, a trailing * matches by prefix, e.g. team/* or * for all repos.
+func validRepoPattern(pattern string) bool {
+	if pattern == "*" {
+		return true
+	}
+	prefix := strings.TrimSuffix(pattern, "*")
+	if prefix != pattern {
+		prefix = strings.TrimRight(prefix, "/._-")
+	}
+	return registry.ValidRepoName(prefix)
+}
+
+// repoOwner owner of the repo by its settings, otherwise by the matching patterns.
+func (a *apiClient) repoOwner(repo string) (events.RepoOwner, bool) {
+	settings := a.eventListener.GetSettings(repo)
+	if team := settings[settingOwnerTeam].Value; team != "" {
+		return events.RepoOwner{
+			Repos: repo, Team: team, Slack: settings[settingOwnerSlack].Value, Email: settings[settingOwnerEmail].Value,
+		}, true
+	}
+	return a.patternOwner(repo)
+}
+
+// patternOwner owner of the repo set by admins or in the config, admins' ones win between equally specific patterns.
+func (a *apiClient) patternOwner(repo string) (events.RepoOwner, bool) {
+	owners := append(a.eventListener.GetRepoOwners(), a.config.RepoOwners...)
+	return events.FindRepoOwner(owners, repo)
+}
+
+// viewOwners view owners of repos from the config and set by admins.
+func (a *apiClient) viewOwners(c echo.Context) error {
+	data := a.setUserPermissions(c)
+	data.Set("owners", a.eventListener.GetRepoOwners())
+	data.Set("configOwners", a.config.RepoOwners)
+	data.Set("repos", c.QueryParam("repos"))
+	return c.Render(http.StatusOK, "owners.html", data)
+}
+
+// addOwner set the owner of the matching repos.
+func (a *apiClient) addOwner(c echo.Context) error {
+	o := events.RepoOwner{
+		Repos: strings.Trim(strings.TrimSpace(c.FormValue("repos")), "/"),
+		Team:  strings.TrimSpace(c.FormValue("team")),
+		Slack: strings.TrimSpace(c.FormValue("slack")),
+		Email: strings.TrimSpace(c.FormValue("email")),
+		User:  a.setUserPermissions(c)["user"].String(),
+	}
+	if o.Team == "" || !validRepoPattern(o.Repos) {
+		return c.String(http.StatusBadRequest, "Repository pattern, e.g. team/app or team/*, and team should be set.")
+	}
+	if err := a.eventListener.AddRepoOwner(o); err != nil {
+		a.log(c).Error(err)
+		return c.String(http.StatusInternalServerError, "Cannot add owner, see the log for request "+requestIDOf(c)+".")
+	}
+	a.trackAction(c, "add-owner")
+	a.audit(c, "add-owner", o.Repos, "", o.Contact())
+	return c.Redirect(http.StatusSeeOther, a.config.BasePath+"/owners")
+}
+
+// deleteOwner delete the owner set by admins.
+func (a *apiClient) deleteOwner(c echo.Context) error {
+	id, _ := strconv.Atoi(c.Param("id"))
+	for _, o := range a.eventListener.GetRepoOwners() {
+		if o.ID != id {
+			continue
+		}
+		if err := a.eventListener.DeleteRepoOwner(id); err != nil {
+			a.log(c).Error(err)
+			return c.String(http.StatusInternalServerError, "Cannot delete owner, see the log for request "+requestIDOf(c)+".")
+		}
+		a.trackAction(c, "delete-owner")
+		a.audit(c, "delete-owner", o.Repos, "", fmt.Sprintf("Was %s", o.Contact()))
+	}
+	return c.Redirect(http.StatusSeeOther, a.config.BasePath+"/owners")
+}
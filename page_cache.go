@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// pageCacheLimit how many pages to keep, e.g. of the namespaces and table pages, the others are not cached.
+const pageCacheLimit = 500
+
+// pageCache pages rendered by the expensive handlers by URL and permission set of the viewer.
+// All of them are dropped when the data behind them changes, e.g. the catalog is refreshed.
+type pageCache struct {
+	mux    sync.Mutex
+	items  map[string]cachedPage
+	hits   int
+	misses int
+	// generation changes on every invalidation, pages rendered from the data before it are not kept.
+	generation int
+}
+
+type cachedPage struct {
+	contentType string
+	body        []byte
+	at          time.Time
+}
+
+// pageCacheStats effectiveness of the page cache shown on the diagnostics page.
+type pageCacheStats struct {
+	Pages  int
+	Bytes  int
+	Hits   int
+	Misses int
+}
+
+// pageRecorder response writer keeping a copy of the body.
+type pageRecorder struct {
+	http.ResponseWriter
+	body bytes.Buffer
+}
+
+func (r *pageRecorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
+
+// invalidate drop all cached pages.
+func (p *pageCache) invalidate() {
+	p.mux.Lock()
+	p.items = nil
+	p.generation++
+	p.mux.Unlock()
+}
+
+// stats count and size of the cached pages, hits and misses since start.
+func (p *pageCache) stats() pageCacheStats {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	s := pageCacheStats{Pages: len(p.items), Hits: p.hits, Misses: p.misses}
+	for _, page := range p.items {
+		s.Bytes += len(page.body)
+	}
+	return s
+}
+
+// cachePage serve the page from cache if rendered for the same user, permissions and tenant scope within page_cache_max_age.
+// The user is a part of the key as the page shows it, e.g. in the logout form. Pages viewed as another user
+// and CSV exports are not cached.
+func (a *apiClient) cachePage(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		maxAge := time.Duration(a.config.PageCacheMaxAge) * time.Second
+		data := a.setUserPermissions(c)
+		if maxAge <= 0 || data["viewAs"].String() != "" || csvRequested(c) {
+			return next(c)
+		}
+		key := fmt.Sprintf("%s|%q|%q|%v|%v|%v|%v|%v|%v|%s", c.Request().URL.RequestURI(), data["realUser"].String(),
+			data["user"].String(), data["loggedIn"], data["realIsAdmin"], data["isAdmin"], data["deleteAllowed"],
+			data["retentionAllowed"], data["retentionPreviewAllowed"], strings.Join(a.tenantScope(c), ","))
+
+		a.pages.mux.Lock()
+		page, ok := a.pages.items[key]
+		if ok && time.Now().Sub(page.at) < maxAge {
+			a.pages.hits++
+			a.pages.mux.Unlock()
+			c.Response().Header().Set("X-Page-Cache", "hit")
+			return c.Blob(http.StatusOK, page.contentType, page.body)
+		}
+		a.pages.misses++
+		generation := a.pages.generation
+		a.pages.mux.Unlock()
+
+		rec := &pageRecorder{ResponseWriter: c.Response().Writer}
+		c.Response().Writer = rec
+		c.Response().Header().Set("X-Page-Cache", "miss")
+		start := time.Now()
+		err := next(c)
+		c.Response().Writer = rec.ResponseWriter
+		if err != nil || c.Response().Status != http.StatusOK {
+			return err
+		}
+		a.pages.mux.Lock()
+		defer a.pages.mux.Unlock()
+		if a.pages.generation != generation || len(a.pages.items) >= pageCacheLimit {
+			return nil
+		}
+		if a.pages.items == nil {
+			a.pages.items = map[string]cachedPage{}
+		}
+		a.pages.items[key] = cachedPage{contentType: c.Response().Header().Get(echo.HeaderContentType), body: rec.body.Bytes(), at: start}
+		return nil
+	}
+}
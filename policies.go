@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/quiq/docker-registry-ui/policy"
+	"github.com/quiq/docker-registry-ui/registry"
+)
+
+type apiPolicyResponse struct {
+	Enabled bool            `json:"enabled"`
+	Results []policy.Result `json:"results"`
+}
+
+// policyRules rules from the config, none when the feature is off.
+func (a *apiClient) policyRules() policy.Rules {
+	if !a.config.feature("policy") {
+		return policy.Rules{}
+	}
+	return policy.Rules{
+		RequireSignature:  a.config.PolicyRequireSignature,
+		RequireScan:       a.config.PolicyRequireScan,
+		MaxSeverity:       a.config.PolicyMaxSeverity,
+		MaxSize:           int64(a.config.PolicyMaxSizeMB) << 20,
+		AllowedBaseImages: a.config.PolicyAllowedBaseImages,
+	}
+}
+
+// repoPolicy policy rules of the repo with its scan settings applied.
+func (a *apiClient) repoPolicy(repo string) policy.Rules {
+	rules := a.policy
+	if !rules.Enabled() {
+		return rules
+	}
+	settings := a.eventListener.GetSettings(repo)
+	if s, ok := settings[settingMaxSeverity]; ok {
+		rules.MaxSeverity = s.Value
+	}
+	if settings[settingScanDisabled].Value == "true" {
+		rules.RequireScan = false
+		rules.MaxSeverity = ""
+	}
+	return rules
+}
+
+// evaluatePolicy check the tag against the policy rules of its repo, tags are all tags of the repo to look up signatures.
+func (a *apiClient) evaluatePolicy(rules policy.Rules, meta registry.TagMeta, tags []string) policy.Result {
+	img := policy.Image{
+		Repo:      meta.Repo,
+		Tag:       meta.Tag,
+		Digest:    meta.Digest,
+		Size:      meta.Size,
+		BaseImage: policy.BaseImage(meta.Annotations, meta.Labels),
+	}
+	img.Signed = isSigned(meta.Digest, tags)
+	if report, ok := a.scanReport(meta.Repo, meta.Tag, meta.Digest); ok {
+		img.Scan = &report
+	}
+	return rules.Evaluate(img)
+}
+
+// policyBadge HTML label with policy status, the failed checks are in the title.
+func policyBadge(res policy.Result) string {
+	if res.Compliant {
+		return `<span class="label label-success">compliant</span>`
+	}
+	return fmt.Sprintf(`<span class="label label-danger" title="%s">non-compliant</span>`, html.EscapeString(strings.Join(res.Failed(), "; ")))
+}
+
+// apiPolicy policy status of the tag or all tags of the repository for external gating, e.g. in deployment pipelines.
+func (a *apiClient) apiPolicy(c echo.Context) error {
+	repo := strings.Trim(c.QueryParam("repository"), "/")
+	if repo == "" {
+		return apiError(c, http.StatusBadRequest, fmt.Errorf("repository is required"))
+	}
+	repoTags := a.client.Tags(repo)
+	tags := repoTags
+	tag := c.QueryParam("tag")
+	if tag != "" {
+		tags = []string{tag}
+	}
+
+	rules := a.repoPolicy(repo)
+	results := []policy.Result{}
+	for _, meta := range a.client.TagsMetadata(repo, tags) {
+		if tag != "" && meta.Digest == "" {
+			return apiError(c, http.StatusNotFound, fmt.Errorf("cannot find image %s:%s", repo, tag))
+		}
+		results = append(results, a.evaluatePolicy(rules, meta, repoTags))
+	}
+	return c.JSON(http.StatusOK, apiPolicyResponse{a.policy.Enabled(), results})
+}
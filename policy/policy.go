@@ -0,0 +1,164 @@
+package policy
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/quiq/docker-registry-ui/scanner"
+)
+
+// BaseImageAnnotation OCI annotation or label with the name of the base image.
+const BaseImageAnnotation = "org.opencontainers.image.base.name"
+
+// BaseDigestAnnotation OCI annotation or label with the digest of the base image.
+const BaseDigestAnnotation = "org.opencontainers.image.base.digest"
+
+// Rules the image should comply with, zero values disable the checks.
+type Rules struct {
+	// RequireSignature image should have cosign signature in the same repo.
+	RequireSignature bool
+	// RequireScan image should be scanned for vulnerabilities.
+	RequireScan bool
+	// MaxSeverity scanned image should have no vulnerabilities of this severity or higher except accepted ones.
+	MaxSeverity string
+	// MaxSize in bytes.
+	MaxSize int64
+	// AllowedBaseImages names of the base images without tag, a trailing * matches by prefix.
+	AllowedBaseImages []string
+}
+
+// Image facts about the image to evaluate the rules on.
+type Image struct {
+	Repo      string
+	Tag       string
+	Digest    string
+	Size      int64
+	BaseImage string
+	Signed    bool
+	// Scan report, nil when the image is not scanned.
+	Scan *scanner.Report
+}
+
+// Check result of a single rule.
+type Check struct {
+	Name    string `json:"name"`
+	Passed  bool   `json:"passed"`
+	Message string `json:"message"`
+}
+
+// Result of evaluating the rules on the image.
+type Result struct {
+	Repo      string  `json:"repository"`
+	Tag       string  `json:"tag"`
+	Digest    string  `json:"digest"`
+	Compliant bool    `json:"compliant"`
+	Checks    []Check `json:"checks"`
+}
+
+// Failed messages of the failed checks.
+func (r Result) Failed() []string {
+	var list []string
+	for _, c := range r.Checks {
+		if !c.Passed {
+			list = append(list, c.Message)
+		}
+	}
+	return list
+}
+
+// Enabled any rule is set.
+func (r Rules) Enabled() bool {
+	return r.RequireSignature || r.RequireScan || r.MaxSeverity != "" || r.MaxSize > 0 || len(r.AllowedBaseImages) > 0
+}
+
+// Evaluate the rules on the image, it is compliant when all checks pass.
+func (r Rules) Evaluate(img Image) Result {
+	res := Result{Repo: img.Repo, Tag: img.Tag, Digest: img.Digest, Compliant: true}
+	add := func(name string, passed bool, message string) {
+		res.Checks = append(res.Checks, Check{Name: name, Passed: passed, Message: message})
+		if !passed {
+			res.Compliant = false
+		}
+	}
+
+	if r.RequireSignature {
+		if img.Signed {
+			add("signed", true, "Image is signed")
+		} else {
+			add("signed", false, "Image is not signed")
+		}
+	}
+	if r.RequireScan {
+		if img.Scan != nil {
+			add("scanned", true, "Image is scanned")
+		} else {
+			add("scanned", false, "Image is not scanned")
+		}
+	}
+	if r.MaxSeverity != "" && img.Scan != nil {
+		if n := img.Scan.CountAtLeast(r.MaxSeverity); n > 0 {
+			add("vulnerabilities", false, fmt.Sprintf("%d vulnerabilities of %s severity or higher", n, r.MaxSeverity))
+		} else {
+			add("vulnerabilities", true, fmt.Sprintf("No vulnerabilities of %s severity or higher", r.MaxSeverity))
+		}
+	}
+	if r.MaxSize > 0 {
+		if img.Size <= r.MaxSize {
+			add("size", true, fmt.Sprintf("Size is within %d MB", r.MaxSize>>20))
+		} else {
+			add("size", false, fmt.Sprintf("Size exceeds %d MB", r.MaxSize>>20))
+		}
+	}
+	if len(r.AllowedBaseImages) > 0 {
+		switch {
+		case img.BaseImage == "":
+			add("base_image", false, "Base image is unknown")
+		case baseImageAllowed(img.BaseImage, r.AllowedBaseImages):
+			add("base_image", true, fmt.Sprintf("Base image %s is allowed", img.BaseImage))
+		default:
+			add("base_image", false, fmt.Sprintf("Base image %s is not allowed", img.BaseImage))
+		}
+	}
+	return res
+}
+
+// BaseImage name of the base image from the annotations or labels, empty if unknown.
+func BaseImage(annotations, labels map[string]string) string {
+	if v := annotations[BaseImageAnnotation]; v != "" {
+		return v
+	}
+	return labels[BaseImageAnnotation]
+}
+
+// BaseDigest digest of the base image from the annotations or labels, empty if unknown.
+func BaseDigest(annotations, labels map[string]string) string {
+	if v := annotations[BaseDigestAnnotation]; v != "" {
+		return v
+	}
+	return labels[BaseDigestAnnotation]
+}
+
+// SignatureTag tag cosign stores the signature of the image digest under.
+func SignatureTag(digest string) string {
+	return strings.Replace(digest, ":", "-", 1) + ".sig"
+}
+
+// baseImageAllowed the image name without tag or digest matches any of the allowed ones.
+func baseImageAllowed(image string, allowed []string) bool {
+	name := image
+	if i := strings.Index(name, "@"); i >= 0 {
+		name = name[:i]
+	}
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		name = name[:i]
+	}
+	for _, a := range allowed {
+		if strings.HasSuffix(a, "*") && strings.HasPrefix(image, strings.TrimSuffix(a, "*")) {
+			return true
+		}
+		if name == a {
+			return true
+		}
+	}
+	return false
+}
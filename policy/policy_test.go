@@ -0,0 +1,70 @@
+package policy
+
+import (
+	"testing"
+
+	"github.com/quiq/docker-registry-ui/scanner"
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestEvaluate(t *testing.T) {
+	rules := Rules{
+		RequireSignature:  true,
+		RequireScan:       true,
+		MaxSeverity:       "High",
+		MaxSize:           100 << 20,
+		AllowedBaseImages: []string{"docker.io/library/alpine", "registry.local/base/*"},
+	}
+
+	convey.Convey("Compliant image passes all checks", t, func() {
+		img := Image{
+			Repo: "team/app", Tag: "v1", Size: 50 << 20, BaseImage: "docker.io/library/alpine:3.18", Signed: true,
+			Scan: &scanner.Report{Vulnerabilities: []scanner.Vulnerability{{ID: "CVE-1", Severity: "Medium"}}},
+		}
+		res := rules.Evaluate(img)
+		convey.So(res.Compliant, convey.ShouldBeTrue)
+		convey.So(len(res.Checks), convey.ShouldEqual, 5)
+		convey.So(res.Failed(), convey.ShouldBeEmpty)
+	})
+
+	convey.Convey("Failed checks make the image non-compliant", t, func() {
+		img := Image{
+			Repo: "team/app", Tag: "v2", Size: 200 << 20, BaseImage: "docker.io/library/debian:11",
+			Scan: &scanner.Report{Vulnerabilities: []scanner.Vulnerability{
+				{ID: "CVE-1", Severity: "Critical"}, {ID: "CVE-2", Severity: "High", Accepted: true},
+			}},
+		}
+		res := rules.Evaluate(img)
+		convey.So(res.Compliant, convey.ShouldBeFalse)
+		convey.So(res.Failed(), convey.ShouldResemble, []string{
+			"Image is not signed",
+			"1 vulnerabilities of High severity or higher",
+			"Size exceeds 100 MB",
+			"Base image docker.io/library/debian:11 is not allowed",
+		})
+	})
+
+	convey.Convey("Severity is not checked for not scanned image", t, func() {
+		res := Rules{MaxSeverity: "High"}.Evaluate(Image{})
+		convey.So(res.Compliant, convey.ShouldBeTrue)
+		convey.So(res.Checks, convey.ShouldBeEmpty)
+		res = rules.Evaluate(Image{Signed: true})
+		convey.So(res.Failed(), convey.ShouldResemble, []string{"Image is not scanned", "Base image is unknown"})
+	})
+
+	convey.Convey("Base images are matched by name or prefix", t, func() {
+		allowed := rules.AllowedBaseImages
+		convey.So(baseImageAllowed("docker.io/library/alpine", allowed), convey.ShouldBeTrue)
+		convey.So(baseImageAllowed("docker.io/library/alpine@sha256:abc", allowed), convey.ShouldBeTrue)
+		convey.So(baseImageAllowed("docker.io/library/alpine-extra:1", allowed), convey.ShouldBeFalse)
+		convey.So(baseImageAllowed("registry.local/base/go:1.21", allowed), convey.ShouldBeTrue)
+		convey.So(baseImageAllowed("registry.local:5000/base", allowed), convey.ShouldBeFalse)
+	})
+
+	convey.Convey("Helpers", t, func() {
+		convey.So(Rules{}.Enabled(), convey.ShouldBeFalse)
+		convey.So(rules.Enabled(), convey.ShouldBeTrue)
+		convey.So(SignatureTag("sha256:abc"), convey.ShouldEqual, "sha256-abc.sig")
+		convey.So(BaseImage(map[string]string{}, map[string]string{BaseImageAnnotation: "alpine"}), convey.ShouldEqual, "alpine")
+	})
+}
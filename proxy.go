@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/quiq/docker-registry-ui/registry"
+)
+
+// cachedRepo repo of the pull-through cache with the latest comparison with upstream.
+type cachedRepo struct {
+	Repo      string
+	Path      string
+	Reference string
+	Checked   time.Time
+	Tags      int
+	Stale     int
+	Missing   int
+	Unknown   int
+}
+
+// newUpstream upstream of the registry if it is a pull-through cache.
+func (a *apiClient) newUpstream() *registry.Upstream {
+	if !a.config.feature("cache") {
+		return nil
+	}
+	return registry.NewUpstream(a.config.ProxyRemoteURL, a.config.ProxyUsername, a.config.ProxyPassword)
+}
+
+// upstreamBadge status of the cached tag compared with upstream.
+func upstreamBadge(s registry.UpstreamStatus) string {
+	switch s.Status() {
+	case registry.UpstreamFresh:
+		return `<span class="label label-success">fresh</span>`
+	case registry.UpstreamStale:
+		return fmt.Sprintf(`<span class="label label-warning" title="Upstream digest %s">stale</span>`, html.EscapeString(s.UpstreamDigest))
+	case registry.UpstreamMissing:
+		return `<span class="label label-danger" title="The tag is gone upstream">missing</span>`
+	}
+	return fmt.Sprintf(`<span class="label label-default" title="%s">unknown</span>`, html.EscapeString(s.Error))
+}
+
+// cachedRepos repos of the catalog with the latest statuses of their tags.
+func (a *apiClient) cachedRepos() []cachedRepo {
+	var list []cachedRepo
+	for namespace, repos := range a.client.Repositories(true) {
+		for _, name := range repos {
+			repo := name
+			if namespace != "library" {
+				repo = namespace + "/" + name
+			}
+			r := cachedRepo{Repo: repo, Path: namespace + "/" + name, Reference: a.upstream.Reference(repo)}
+			for _, s := range a.upstream.Checked(repo) {
+				r.Tags++
+				if s.Checked.After(r.Checked) {
+					r.Checked = s.Checked
+				}
+				switch s.Status() {
+				case registry.UpstreamStale:
+					r.Stale++
+				case registry.UpstreamMissing:
+					r.Missing++
+				case registry.UpstreamUnknown:
+					r.Unknown++
+				}
+			}
+			list = append(list, r)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Repo < list[j].Repo })
+	return list
+}
+
+// viewCache view cached repos with their upstream references and staleness.
+func (a *apiClient) viewCache(c echo.Context) error {
+	if a.upstream == nil {
+		return c.String(http.StatusNotFound, "The registry is not configured as a pull-through cache, see proxy_remote_url.")
+	}
+	data := a.setUserPermissions(c)
+	data.Set("upstream", a.config.ProxyRemoteURL)
+	scope := a.tenantScope(c)
+	repos := []cachedRepo{}
+	for _, r := range a.cachedRepos() {
+		if inScope(scope, r.Repo) {
+			repos = append(repos, r)
+		}
+	}
+	data.Set("repos", repos)
+	return c.Render(http.StatusOK, "cache.html", data)
+}
+
+// checkCache compare all cached repos with upstream in background.
+func (a *apiClient) checkCache(c echo.Context) error {
+	if a.upstream == nil {
+		return c.String(http.StatusNotFound, "The registry is not configured as a pull-through cache, see proxy_remote_url.")
+	}
+	user := a.setUserPermissions(c)["user"].String()
+	j := a.jobs.start("Check cache against upstream", user, func(j *job) error {
+		stale := 0
+		for _, r := range a.cachedRepos() {
+			tags := a.client.Tags(r.Repo)
+			for _, s := range a.upstream.Compare(r.Repo, a.client.TagsMetadata(r.Repo, tags)) {
+				if s.Status() != registry.UpstreamFresh {
+					j.logf("%s:%s is %s", r.Repo, s.Tag, s.Status())
+					stale++
+				}
+			}
+		}
+		j.logf("Found %d tags not matching upstream", stale)
+		return nil
+	})
+	a.trackAction(c, "cache-check")
+	return c.Redirect(http.StatusSeeOther, fmt.Sprintf("%s/jobs/%d", a.config.BasePath, j.ID))
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/quiq/docker-registry-ui/registry"
+)
+
+// subImage platform of the manifest list entry.
+type subImage struct {
+	Digest   string
+	Platform string
+	Label    string
+}
+
+// pruneIndex recreate manifest list keeping only the selected platforms and push it back under the same tag.
+func (a *apiClient) pruneIndex(c echo.Context) error {
+	namespace := c.FormValue("namespace")
+	repo := c.FormValue("repo")
+	tag := c.FormValue("tag")
+	repoPath := repo
+	if namespace != "library" {
+		repoPath = fmt.Sprintf("%s/%s", namespace, repo)
+	}
+	form, _ := c.FormParams()
+	keep := form["keep"]
+
+	manifest, mediaType, _, err := a.client.GetManifest(repoPath, tag)
+	if err != nil {
+		return c.String(http.StatusBadGateway, err.Error())
+	}
+	if mediaType != registry.MediaTypeManifestList && mediaType != registry.MediaTypeOCIIndex {
+		return c.String(http.StatusBadRequest, fmt.Sprintf("%s:%s is not a manifest list.", repoPath, tag))
+	}
+	pruned, err := registry.PruneIndex(manifest, keep)
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	if err := a.client.PutManifest(repoPath, tag, mediaType, pruned); err != nil {
+		return c.String(http.StatusBadGateway, err.Error())
+	}
+	a.trackAction(c, "prune-index")
+	a.audit(c, "prune-index", repoPath, tag, "Kept "+strings.Join(keep, ", "))
+	a.log(c).Infof("User %q pruned %s:%s keeping %s", a.setUserPermissions(c)["user"].String(), repoPath, tag, strings.Join(keep, ", "))
+
+	return c.Redirect(http.StatusSeeOther, fmt.Sprintf("%s/%s/%s/%s", a.config.BasePath, namespace, repo, tag))
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"net/http"
+	"runtime/debug"
+
+	"github.com/labstack/echo/v4"
+)
+
+// recoverPanic respond with the error page when a handler panics instead of dropping the connection.
+// The stack is logged with the request ID, so it is reported to Sentry or the webhook as other errors.
+func (a *apiClient) recoverPanic(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) (err error) {
+		defer func() {
+			r := recover()
+			if r == nil {
+				return
+			}
+			if r == http.ErrAbortHandler {
+				panic(r)
+			}
+			req := c.Request()
+			a.log(c).WithField("user", a.setUserPermissions(c)["user"].String()).WithField("stack", string(debug.Stack())).
+				Errorf("Panic in %s %s: %v", req.Method, req.URL.RequestURI(), r)
+			if !c.Response().Committed {
+				err = a.renderError(c, http.StatusInternalServerError, "Something went wrong while serving this page, the problem was logged.")
+			}
+		}()
+		return next(c)
+	}
+}
@@ -0,0 +1,102 @@
+package registry
+
+import (
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Delays of the background jobs while registry is throttling requests.
+const (
+	backoffMin = time.Second
+	backoffMax = time.Minute
+	// backoffPause the jobs pause for when registry is still throttling at the longest delay.
+	backoffPause = 5 * time.Minute
+)
+
+// backoff adaptive delay of the background jobs, it doubles on every throttled response and halves on success.
+type backoff struct {
+	mux   sync.Mutex
+	delay time.Duration
+	until time.Time
+	// sleep waits between the steps of the jobs, time.Sleep if nil.
+	sleep  func(time.Duration)
+	logger *logrus.Entry
+}
+
+// BackoffStatus how the background jobs are slowed down, zero when registry is not throttling.
+type BackoffStatus struct {
+	Delay       time.Duration
+	PausedUntil time.Time
+}
+
+// throttledStatus registry asks to slow down by 429 Too Many Requests or it is overloaded.
+func throttledStatus(status int) bool {
+	return status == 429 || status >= 500
+}
+
+// observeStatus adapt the delay of the background jobs by the response status, the status is 0 on network errors.
+func (c *Client) observeStatus(status int, now time.Time) {
+	if status == 0 {
+		return
+	}
+	b := &c.backoff
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	if b.logger == nil {
+		b.logger = SetupLogging("registry.backoff")
+	}
+	switch {
+	case throttledStatus(status):
+		switch {
+		case b.delay == 0:
+			b.delay = backoffMin
+			b.logger.Warnf("Registry responds %d, slowing down background jobs", status)
+		case b.delay < backoffMax:
+			b.delay *= 2
+			if b.delay > backoffMax {
+				b.delay = backoffMax
+			}
+		case now.After(b.until):
+			b.until = now.Add(backoffPause)
+			b.logger.Warnf("Registry is still throttling, background jobs are paused for %s", backoffPause)
+		}
+	case b.delay > 0:
+		b.delay /= 2
+		if b.delay < backoffMin {
+			b.delay, b.until = 0, time.Time{}
+			b.logger.Info("Registry is not throttling anymore, background jobs run at full speed")
+		}
+	}
+}
+
+// Backoff current slow down of the background jobs.
+func (c *Client) Backoff() BackoffStatus {
+	c.backoff.mux.Lock()
+	defer c.backoff.mux.Unlock()
+	s := BackoffStatus{Delay: c.backoff.delay}
+	if time.Now().Before(c.backoff.until) {
+		s.PausedUntil = c.backoff.until
+	}
+	return s
+}
+
+// throttle wait before the next step of a background job while registry is throttling or until the pause is over.
+// Requests of UI pages are not delayed.
+func (c *Client) throttle() {
+	c.backoff.mux.Lock()
+	wait := c.backoff.delay
+	if d := c.backoff.until.Sub(time.Now()); d > wait {
+		wait = d
+	}
+	sleep := c.backoff.sleep
+	c.backoff.mux.Unlock()
+	if wait <= 0 {
+		return
+	}
+	if sleep == nil {
+		sleep = time.Sleep
+	}
+	sleep(wait)
+}
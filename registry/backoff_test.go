@@ -0,0 +1,53 @@
+package registry
+
+import (
+	"testing"
+	"time"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestBackoff(t *testing.T) {
+	c := &Client{}
+	var slept []time.Duration
+	c.backoff.sleep = func(d time.Duration) { slept = append(slept, d) }
+	now := time.Now()
+
+	convey.Convey("Slow down on throttled responses", t, func() {
+		c.throttle()
+		convey.So(slept, convey.ShouldBeEmpty)
+		c.observeStatus(404, now)
+		c.observeStatus(0, now)
+		convey.So(c.Backoff().Delay, convey.ShouldEqual, 0)
+
+		c.observeStatus(429, now)
+		c.observeStatus(503, now)
+		convey.So(c.Backoff().Delay, convey.ShouldEqual, 2*time.Second)
+		c.throttle()
+		convey.So(slept, convey.ShouldResemble, []time.Duration{2 * time.Second})
+
+		c.observeStatus(200, now)
+		convey.So(c.Backoff().Delay, convey.ShouldEqual, time.Second)
+		c.observeStatus(200, now)
+		convey.So(c.Backoff(), convey.ShouldResemble, BackoffStatus{})
+	})
+
+	convey.Convey("Pause when still throttled at the longest delay", t, func() {
+		for i := 0; i < 10; i++ {
+			c.observeStatus(429, now)
+		}
+		convey.So(c.Backoff().Delay, convey.ShouldEqual, backoffMax)
+		convey.So(c.Backoff().PausedUntil, convey.ShouldEqual, now.Add(backoffPause))
+		convey.So(c.PrefetchRecent(), convey.ShouldEqual, 0)
+
+		slept = nil
+		c.throttle()
+		convey.So(slept, convey.ShouldHaveLength, 1)
+		convey.So(slept[0], convey.ShouldBeGreaterThan, backoffMax)
+
+		for i := 0; i < 10; i++ {
+			c.observeStatus(200, now)
+		}
+		convey.So(c.Backoff(), convey.ShouldResemble, BackoffStatus{})
+	})
+}
@@ -0,0 +1,202 @@
+package registry
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tidwall/gjson"
+)
+
+// baseManifestsLimit manifests of the upstream base images kept in memory, they are immutable by digest.
+const baseManifestsLimit = 500
+
+// BaseUpstream upstream registry of the base images under the name prefix, e.g. docker.io/ for Docker Hub.
+type BaseUpstream struct {
+	// Prefix of the normalized base image name, e.g. docker.io/ or quay.io/prometheus/.
+	Prefix   string `yaml:"prefix"`
+	URL      string `yaml:"url"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+}
+
+// String the upstream with the password masked for the options page.
+func (b BaseUpstream) String() string {
+	password := ""
+	if b.Password != "" {
+		password = "********"
+	}
+	return fmt.Sprintf("{prefix: %s, url: %s, username: %s, password: %s}", b.Prefix, b.URL, b.Username, password)
+}
+
+// BaseImageStatus the base image embedded in the image compared with the current upstream tag.
+type BaseImageStatus struct {
+	// Base name of the base image as given by the annotation or label.
+	Base string `json:"base"`
+	// Reference normalized name of the upstream tag, empty if no upstream is configured for the base.
+	Reference      string    `json:"reference,omitempty"`
+	UpstreamDigest string    `json:"upstream_digest,omitempty"`
+	Outdated       bool      `json:"outdated"`
+	Checked        time.Time `json:"checked"`
+	Error          string    `json:"error,omitempty"`
+}
+
+// BaseImages checks images against their base images upstream.
+type BaseImages struct {
+	upstreams []BaseUpstream
+	clients   []*Upstream
+	mux       sync.Mutex
+	manifests map[string]string
+}
+
+// NewBaseImages create the checker of the base images by upstream, the first matching prefix is used.
+func NewBaseImages(upstreams []BaseUpstream) *BaseImages {
+	b := &BaseImages{upstreams: upstreams, manifests: map[string]string{}}
+	for _, u := range upstreams {
+		b.clients = append(b.clients, NewUpstream(u.URL, u.Username, u.Password))
+	}
+	return b
+}
+
+// NormalizeImageName full name of the image as docker resolves it, e.g. alpine:3.12 is docker.io/library/alpine:3.12.
+// Returns the name, the tag and the digest, the tag defaults to latest unless pinned by digest only.
+func NormalizeImageName(image string) (string, string, string) {
+	name, tag, digest := image, "", ""
+	if i := strings.Index(name, "@"); i >= 0 {
+		name, digest = name[:i], name[i+1:]
+	}
+	if i := strings.LastIndex(name, ":"); i >= 0 && !strings.Contains(name[i:], "/") {
+		name, tag = name[:i], name[i+1:]
+	}
+	if tag == "" && digest == "" {
+		tag = "latest"
+	}
+	parts := strings.SplitN(name, "/", 2)
+	if len(parts) == 1 || (!strings.ContainsAny(parts[0], ".:") && parts[0] != "localhost") {
+		name = "docker.io/" + name
+	}
+	if strings.HasPrefix(name, "docker.io/") && !strings.Contains(strings.TrimPrefix(name, "docker.io/"), "/") {
+		name = "docker.io/library/" + strings.TrimPrefix(name, "docker.io/")
+	}
+	return name, tag, digest
+}
+
+// manifest of the upstream image by digest from cache or by GET request.
+func (b *BaseImages) manifest(u *Upstream, repo, digest string) (string, error) {
+	b.mux.Lock()
+	m, ok := b.manifests[digest]
+	b.mux.Unlock()
+	if ok {
+		return m, nil
+	}
+	c, err := u.connect()
+	if err != nil {
+		return "", err
+	}
+	m, _, _, err = c.GetManifest(u.path(repo), digest)
+	if err != nil {
+		return "", err
+	}
+	b.mux.Lock()
+	if len(b.manifests) >= baseManifestsLimit {
+		b.manifests = map[string]string{}
+	}
+	b.manifests[digest] = m
+	b.mux.Unlock()
+	return m, nil
+}
+
+// Check compare the image with the current upstream tag of its base image. The base digest given by
+// org.opencontainers.image.base.digest is compared with the upstream digests, otherwise the layers of the
+// upstream image of the platform should be the first layers of the image. Reference is empty if no upstream
+// is configured for the base image.
+func (b *BaseImages) Check(base, baseDigest, platform string, layers []Layer) BaseImageStatus {
+	s := BaseImageStatus{Base: base, Checked: time.Now()}
+	name, tag, pinned := NormalizeImageName(base)
+	var u *Upstream
+	repo := ""
+	for i, up := range b.upstreams {
+		if strings.HasPrefix(name, up.Prefix) {
+			u, repo = b.clients[i], strings.Trim(strings.TrimPrefix(name, up.Prefix), "/")
+			break
+		}
+	}
+	if u == nil {
+		return s
+	}
+	if tag == "" {
+		s.Reference = name + "@" + pinned
+		s.Error = "the base image is pinned by digest without tag"
+		return s
+	}
+	s.Reference = name + ":" + tag
+	if baseDigest == "" {
+		baseDigest = pinned
+	}
+
+	digest, err := u.digest(repo, tag)
+	if err != nil {
+		s.Error = err.Error()
+		return s
+	}
+	if digest == "" {
+		s.Error = "the tag is gone upstream"
+		return s
+	}
+	s.UpstreamDigest = digest
+	if baseDigest == digest {
+		return s
+	}
+
+	manifest, err := b.manifest(u, repo, digest)
+	if err != nil {
+		s.Error = err.Error()
+		return s
+	}
+	// Manifest list, the base digest may be the one of the platform image.
+	platforms := gjson.Get(manifest, "manifests").Array()
+	if baseDigest != "" {
+		s.Outdated = true
+		for _, m := range platforms {
+			if m.Get("digest").String() == baseDigest {
+				s.Outdated = false
+			}
+		}
+		return s
+	}
+
+	if len(layers) == 0 {
+		s.Error = "the image has no layers to compare"
+		return s
+	}
+	if len(platforms) > 0 {
+		platformDigest := ""
+		for _, m := range platforms {
+			if PlatformString(m.Get("platform")) == platform {
+				platformDigest = m.Get("digest").String()
+				break
+			}
+		}
+		if platformDigest == "" {
+			s.Error = fmt.Sprintf("the upstream tag has no %s image", platform)
+			return s
+		}
+		if manifest, err = b.manifest(u, repo, platformDigest); err != nil {
+			s.Error = err.Error()
+			return s
+		}
+	}
+	upstreamLayers := Layers(manifest)
+	if len(upstreamLayers) == 0 || len(upstreamLayers) > len(layers) {
+		s.Outdated = true
+		return s
+	}
+	for i, l := range upstreamLayers {
+		if layers[i].Digest != l.Digest {
+			s.Outdated = true
+			break
+		}
+	}
+	return s
+}
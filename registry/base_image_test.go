@@ -0,0 +1,81 @@
+package registry
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestBaseImages(t *testing.T) {
+	manifests := map[string][2]string{
+		"3.12":       {"sha256:m12", `{"schemaVersion": 2, "layers": [{"digest": "sha256:l1"}]}`},
+		"sha256:m12": {"sha256:m12", `{"schemaVersion": 2, "layers": [{"digest": "sha256:l1"}]}`},
+		"3.13":       {"sha256:i13", `{"schemaVersion": 2, "manifests": [{"digest": "sha256:arm", "platform": {"os": "linux", "architecture": "arm64"}}, {"digest": "sha256:amd", "platform": {"os": "linux", "architecture": "amd64"}}]}`},
+		"sha256:i13": {"sha256:i13", `{"schemaVersion": 2, "manifests": [{"digest": "sha256:arm", "platform": {"os": "linux", "architecture": "arm64"}}, {"digest": "sha256:amd", "platform": {"os": "linux", "architecture": "amd64"}}]}`},
+		"sha256:amd": {"sha256:amd", `{"schemaVersion": 2, "layers": [{"digest": "sha256:l2"}, {"digest": "sha256:l3"}]}`},
+		"sha256:arm": {"sha256:arm", `{"schemaVersion": 2, "layers": [{"digest": "sha256:l4"}]}`},
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v2/" {
+			return
+		}
+		m, ok := manifests[strings.TrimPrefix(r.URL.Path, "/v2/library/alpine/manifests/")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Docker-Content-Digest", m[0])
+		w.Write([]byte(m[1]))
+	}))
+	defer server.Close()
+	b := NewBaseImages([]BaseUpstream{{Prefix: "docker.io/", URL: server.URL}})
+
+	convey.Convey("Normalize base image names", t, func() {
+		name, tag, digest := NormalizeImageName("alpine")
+		convey.So([]string{name, tag, digest}, convey.ShouldResemble, []string{"docker.io/library/alpine", "latest", ""})
+		name, tag, digest = NormalizeImageName("grafana/grafana:7.5@sha256:abc")
+		convey.So([]string{name, tag, digest}, convey.ShouldResemble, []string{"docker.io/grafana/grafana", "7.5", "sha256:abc"})
+		name, tag, digest = NormalizeImageName("localhost:5000/team/app@sha256:abc")
+		convey.So([]string{name, tag, digest}, convey.ShouldResemble, []string{"localhost:5000/team/app", "", "sha256:abc"})
+		name, _, _ = NormalizeImageName("quay.io/prometheus/node-exporter:v1")
+		convey.So(name, convey.ShouldEqual, "quay.io/prometheus/node-exporter")
+	})
+
+	convey.Convey("Compare the image layers with the upstream base", t, func() {
+		s := b.Check("alpine:3.12", "", "linux/amd64", []Layer{{Digest: "sha256:l1"}, {Digest: "sha256:app"}})
+		convey.So(s.Reference, convey.ShouldEqual, "docker.io/library/alpine:3.12")
+		convey.So(s.UpstreamDigest, convey.ShouldEqual, "sha256:m12")
+		convey.So(s.Error, convey.ShouldEqual, "")
+		convey.So(s.Outdated, convey.ShouldBeFalse)
+
+		s = b.Check("docker.io/library/alpine:3.12", "", "linux/amd64", []Layer{{Digest: "sha256:l0"}, {Digest: "sha256:app"}})
+		convey.So(s.Outdated, convey.ShouldBeTrue)
+
+		s = b.Check("alpine:3.13", "", "linux/amd64", []Layer{{Digest: "sha256:l2"}, {Digest: "sha256:l3"}, {Digest: "sha256:app"}})
+		convey.So(s.Error, convey.ShouldEqual, "")
+		convey.So(s.Outdated, convey.ShouldBeFalse)
+		s = b.Check("alpine:3.13", "", "linux/arm64", []Layer{{Digest: "sha256:l2"}, {Digest: "sha256:app"}})
+		convey.So(s.Outdated, convey.ShouldBeTrue)
+		s = b.Check("alpine:3.13", "", "linux/s390x", []Layer{{Digest: "sha256:l2"}})
+		convey.So(s.Error, convey.ShouldEqual, "the upstream tag has no linux/s390x image")
+	})
+
+	convey.Convey("Compare the base digest with the upstream one", t, func() {
+		convey.So(b.Check("alpine:3.13", "sha256:i13", "", nil).Outdated, convey.ShouldBeFalse)
+		convey.So(b.Check("alpine:3.13", "sha256:amd", "", nil).Outdated, convey.ShouldBeFalse)
+		convey.So(b.Check("alpine:3.13", "sha256:old", "", nil).Outdated, convey.ShouldBeTrue)
+		convey.So(b.Check("alpine:3.12@sha256:old", "", "", nil).Outdated, convey.ShouldBeTrue)
+	})
+
+	convey.Convey("Report unknown status", t, func() {
+		s := b.Check("alpine:3.14", "", "linux/amd64", []Layer{{Digest: "sha256:l1"}})
+		convey.So(s.Error, convey.ShouldEqual, "the tag is gone upstream")
+		convey.So(s.Outdated, convey.ShouldBeFalse)
+		s = b.Check("quay.io/prometheus/node-exporter:v1", "", "linux/amd64", nil)
+		convey.So(s.Reference, convey.ShouldEqual, "")
+		convey.So(s.Error, convey.ShouldEqual, "")
+	})
+}
@@ -0,0 +1,101 @@
+package registry
+
+import (
+	"encoding/base64"
+	"sort"
+	"strings"
+
+	"github.com/tidwall/gjson"
+)
+
+// buildArgPrefix build args among BuildKit frontend attributes and provenance parameters.
+const buildArgPrefix = "build-arg:"
+
+// Labels of the source repo and commit set by docker/metadata-action, label-schema and others.
+var (
+	sourceLabels   = []string{"org.opencontainers.image.source", "org.label-schema.vcs-url"}
+	revisionLabels = []string{"org.opencontainers.image.revision", "org.label-schema.vcs-ref"}
+)
+
+// BuildInfo how the image was built: the commit, BuildKit frontend and build args.
+type BuildInfo struct {
+	Source   string
+	Commit   string
+	Frontend string
+	Args     []Parameter
+	// From where the info was found: provenance, buildinfo of the image config or labels.
+	From []string
+}
+
+// Empty whether nothing is known about the build.
+func (b BuildInfo) Empty() bool {
+	return b.Source == "" && b.Commit == "" && b.Frontend == "" && len(b.Args) == 0
+}
+
+// ExtractBuildInfo combine the build details of the provenance, the deprecated BuildKit buildinfo
+// of the image config and the labels, the first found value wins in this order.
+func ExtractBuildInfo(provenance []Provenance, config string, labels map[string]string) BuildInfo {
+	var b BuildInfo
+	args := map[string]string{}
+	found := func(from string) {
+		if !ItemInSlice(from, b.From) {
+			b.From = append(b.From, from)
+		}
+	}
+	set := func(field *string, value, from string) {
+		if *field == "" && value != "" {
+			*field = value
+			found(from)
+		}
+	}
+
+	for _, p := range provenance {
+		set(&b.Source, p.Source, "provenance")
+		set(&b.Commit, p.Commit, "provenance")
+		for _, param := range p.Parameters {
+			switch {
+			case param.Name == "frontend":
+				set(&b.Frontend, param.Value, "provenance")
+			case strings.HasPrefix(param.Name, "args."+buildArgPrefix):
+				name := strings.TrimPrefix(param.Name, "args."+buildArgPrefix)
+				if _, ok := args[name]; !ok {
+					args[name] = param.Value
+					found("provenance")
+				}
+			}
+		}
+	}
+
+	// BuildKit before provenance attestations embedded base64 encoded buildinfo into the image config.
+	if encoded := gjson.Get(config, `moby\.buildkit\.buildinfo\.v1`).String(); encoded != "" {
+		if decoded, err := base64.StdEncoding.DecodeString(encoded); err == nil {
+			info := gjson.ParseBytes(decoded)
+			set(&b.Frontend, info.Get("frontend").String(), "buildinfo")
+			info.Get("attrs").ForEach(func(k, v gjson.Result) bool {
+				name := k.String()
+				if strings.HasPrefix(name, buildArgPrefix) {
+					if _, ok := args[name[len(buildArgPrefix):]]; !ok {
+						args[name[len(buildArgPrefix):]] = v.String()
+						found("buildinfo")
+					}
+				}
+				return true
+			})
+			set(&b.Source, info.Get("attrs.vcs:source").String(), "buildinfo")
+			set(&b.Commit, info.Get("attrs.vcs:revision").String(), "buildinfo")
+		}
+	}
+
+	for _, l := range sourceLabels {
+		set(&b.Source, labels[l], "labels")
+	}
+	for _, l := range revisionLabels {
+		set(&b.Commit, labels[l], "labels")
+	}
+
+	for name, value := range args {
+		b.Args = append(b.Args, Parameter{name, value})
+	}
+	sort.Slice(b.Args, func(i, j int) bool { return b.Args[i].Name < b.Args[j].Name })
+	return b
+}
@@ -0,0 +1,37 @@
+package registry
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestExtractBuildInfo(t *testing.T) {
+	convey.Convey("Take commit, frontend and build args from BuildKit provenance", t, func() {
+		p, err := ParseProvenance([]byte(buildkitProvenance))
+		convey.So(err, convey.ShouldBeNil)
+		b := ExtractBuildInfo([]Provenance{p}, "", map[string]string{"org.opencontainers.image.revision": "other"})
+		convey.So(b.Source, convey.ShouldEqual, "https://github.com/acme/app")
+		convey.So(b.Commit, convey.ShouldEqual, "9c8d7e6")
+		convey.So(b.Frontend, convey.ShouldEqual, "dockerfile.v0")
+		convey.So(b.Args, convey.ShouldResemble, []Parameter{{"VERSION", "1.0"}})
+		convey.So(b.From, convey.ShouldResemble, []string{"provenance"})
+	})
+
+	convey.Convey("Fall back to buildinfo of the image config and labels", t, func() {
+		info := base64.StdEncoding.EncodeToString([]byte(`{"frontend": "dockerfile.v0", "attrs": {"build-arg:GO": "1.13", "filename": "Dockerfile"}}`))
+		config := `{"architecture": "amd64", "moby.buildkit.buildinfo.v1": "` + info + `"}`
+		labels := map[string]string{"org.label-schema.vcs-url": "https://gitlab.com/acme/app", "org.label-schema.vcs-ref": "1a2b3c"}
+		b := ExtractBuildInfo(nil, config, labels)
+		convey.So(b.Source, convey.ShouldEqual, "https://gitlab.com/acme/app")
+		convey.So(b.Commit, convey.ShouldEqual, "1a2b3c")
+		convey.So(b.Frontend, convey.ShouldEqual, "dockerfile.v0")
+		convey.So(b.Args, convey.ShouldResemble, []Parameter{{"GO", "1.13"}})
+		convey.So(b.From, convey.ShouldResemble, []string{"buildinfo", "labels"})
+	})
+
+	convey.Convey("Nothing known about the build", t, func() {
+		convey.So(ExtractBuildInfo(nil, "{}", nil).Empty(), convey.ShouldBeTrue)
+	})
+}
@@ -0,0 +1,215 @@
+package registry
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// catalogPartialInterval how often the repos listed so far are shown during the first listing of the catalog.
+const catalogPartialInterval = time.Second
+
+// catalogAlphabet characters of repo names in the order the registry sorts them.
+const catalogAlphabet = "-./0123456789_abcdefghijklmnopqrstuvwxyz"
+
+// CatalogRepo repository entry of the catalog page.
+type CatalogRepo struct {
+	Namespace string `json:"namespace"`
+	Repo      string `json:"repo"`
+	Tags      int    `json:"tags"`
+}
+
+// catalogSnapshot repositories sorted by "namespace/repo" key, the default namespace is "library".
+type catalogSnapshot struct {
+	mux  sync.RWMutex
+	keys []string
+	// changed is called when the repos or their tag counts change.
+	changed func()
+	// listing whether the catalog is being listed, loaded whether it was listed completely at least once,
+	// until then keys are the repos listed so far and progress is the estimated percent of them.
+	listing  bool
+	loaded   bool
+	progress int
+	shown    time.Time
+	// listedAt when the catalog was listed completely the last time.
+	listedAt time.Time
+}
+
+// CatalogLoading whether the catalog is still being listed for the first time and the estimated percent listed,
+// the repos listed so far are served meanwhile. It stays loading after a failed page until a refresh completes it.
+func (c *Client) CatalogLoading() (bool, int) {
+	c.catalog.mux.RLock()
+	defer c.catalog.mux.RUnlock()
+	return !c.catalog.loaded && (c.catalog.listing || c.catalog.keys != nil), c.catalog.progress
+}
+
+// CatalogStatus when the catalog was listed completely the last time and the count of its repos,
+// zero time until the first listing completes. The refresh failing leaves it behind, e.g. to alert on.
+func (c *Client) CatalogStatus() (time.Time, int) {
+	c.catalog.mux.RLock()
+	defer c.catalog.mux.RUnlock()
+	return c.catalog.listedAt, len(c.catalog.keys)
+}
+
+// setCatalogListing mark the catalog listing started or finished.
+func (c *Client) setCatalogListing(listing bool) {
+	c.catalog.mux.Lock()
+	c.catalog.listing = listing
+	c.catalog.mux.Unlock()
+}
+
+// setPartialCatalog show the repos listed so far until the catalog is loaded completely, at most once
+// per interval unless forced. Last is the last repo listed to estimate the progress by.
+func (c *Client) setPartialCatalog(names []string, last string, force bool) {
+	c.catalog.mux.Lock()
+	if c.catalog.loaded || !force && time.Since(c.catalog.shown) < catalogPartialInterval {
+		c.catalog.mux.Unlock()
+		return
+	}
+	c.catalog.shown = time.Now()
+	c.catalog.mux.Unlock()
+
+	keys := []string{}
+	for namespace, list := range groupCatalog(names) {
+		for _, r := range list {
+			keys = append(keys, namespace+"/"+r)
+		}
+	}
+	sort.Strings(keys)
+	c.catalog.mux.Lock()
+	c.catalog.keys = keys
+	if p := catalogProgress(last); p > c.catalog.progress {
+		c.catalog.progress = p
+	}
+	c.catalog.mux.Unlock()
+	c.catalogChanged()
+}
+
+// catalogProgress estimate the percent of the catalog listed up to the repo by its name, the catalog is sorted
+// by names, so the first characters tell how far in the alphabet the listing is. It is below 100 until loaded.
+func catalogProgress(name string) int {
+	fraction, scale := 0.0, 1.0
+	for i := 0; i < len(name) && i < 3; i++ {
+		scale /= float64(len(catalogAlphabet))
+		if pos := strings.IndexByte(catalogAlphabet, name[i]); pos >= 0 {
+			fraction += float64(pos) * scale
+		}
+	}
+	if p := int(fraction * 100); p < 99 {
+		return p
+	}
+	return 99
+}
+
+// OnCatalogChange set the callback called when the repos or their tag counts change, e.g. to drop rendered pages.
+func (c *Client) OnCatalogChange(fn func()) {
+	c.catalog.mux.Lock()
+	c.catalog.changed = fn
+	c.catalog.mux.Unlock()
+}
+
+// catalogChanged call the change callback if any.
+func (c *Client) catalogChanged() {
+	c.catalog.mux.RLock()
+	fn := c.catalog.changed
+	c.catalog.mux.RUnlock()
+	if fn != nil {
+		fn()
+	}
+}
+
+// setCatalog replace the snapshot with repos of the catalog.
+func (c *Client) setCatalog(repos map[string][]string) {
+	keys := []string{}
+	for namespace, list := range repos {
+		for _, r := range list {
+			keys = append(keys, namespace+"/"+r)
+		}
+	}
+	sort.Strings(keys)
+	c.catalog.mux.Lock()
+	c.catalog.keys = keys
+	c.catalog.loaded = true
+	c.catalog.progress = 100
+	c.catalog.listedAt = time.Now()
+	c.catalog.mux.Unlock()
+	c.catalogChanged()
+}
+
+// CatalogPage return up to limit repos following the "namespace/repo" cursor, optionally only from the namespace.
+// Repos known to have no tags are skipped. The cursor for the next page is returned, it is empty on the last page.
+func (c *Client) CatalogPage(namespace, after string, limit int) ([]CatalogRepo, string) {
+	c.catalog.mux.RLock()
+	keys, listing := c.catalog.keys, c.catalog.listing
+	c.catalog.mux.RUnlock()
+	// The first listing running serves the repos listed so far instead of waiting for it.
+	if keys == nil && !listing {
+		c.Repositories(true)
+		c.catalog.mux.RLock()
+		keys = c.catalog.keys
+		c.catalog.mux.RUnlock()
+	}
+
+	prefix := ""
+	if namespace != "" {
+		prefix = namespace + "/"
+		if after < prefix {
+			after = prefix
+		}
+	}
+	// Keys are sorted, so the page starts right after the cursor.
+	i := sort.SearchStrings(keys, after)
+	if i < len(keys) && keys[i] == after {
+		i++
+	}
+
+	counts := c.TagCounts()
+	page := []CatalogRepo{}
+	for ; i < len(keys) && strings.HasPrefix(keys[i], prefix); i++ {
+		if len(page) == limit {
+			return page, page[len(page)-1].Namespace + "/" + page[len(page)-1].Repo
+		}
+		count, ok := counts[keys[i]]
+		if ok && count == 0 {
+			continue
+		}
+		if !ok {
+			count = -1
+		}
+		f := strings.SplitN(keys[i], "/", 2)
+		page = append(page, CatalogRepo{Namespace: f[0], Repo: f[1], Tags: count})
+	}
+	return page, ""
+}
+
+// TagCounts return copy of the map with tag counts by "namespace/repo" key.
+func (c *Client) TagCounts() map[string]int {
+	c.countsMux.RLock()
+	defer c.countsMux.RUnlock()
+	counts := make(map[string]int, len(c.tagCounts))
+	for k, v := range c.tagCounts {
+		counts[k] = v
+	}
+	return counts
+}
+
+// setTagCount update tag count of the repo, delta is added to the current count when set is false.
+func (c *Client) setTagCount(repo string, count int, set bool) {
+	key := repo
+	if !strings.Contains(repo, "/") {
+		key = "library/" + repo
+	}
+	c.countsMux.Lock()
+	prev, ok := c.tagCounts[key]
+	if set {
+		c.tagCounts[key] = count
+	} else if ok {
+		c.tagCounts[key] = prev + count
+	}
+	changed := c.tagCounts[key] != prev || set && !ok
+	c.countsMux.Unlock()
+	if changed {
+		c.catalogChanged()
+	}
+}
@@ -0,0 +1,116 @@
+package registry
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"time"
+)
+
+// catalogResumeMaxAge how old the listing may be to resume it, older ones start over as the repos listed may be gone.
+const catalogResumeMaxAge = 24 * time.Hour
+
+// catalogResume pages of the catalog listed by the interrupted run, the next run continues with the next page link
+// instead of starting over. It is kept in memory and appended to the file if set, so restarts resume too.
+type catalogResume struct {
+	path    string
+	started time.Time
+	next    string
+	names   []string
+}
+
+// catalogResumeLine line of the resume file, the first one has the start time, every other one a page.
+type catalogResumeLine struct {
+	Started      time.Time `json:"started,omitempty"`
+	Next         string    `json:"next,omitempty"`
+	Repositories []string  `json:"repositories,omitempty"`
+}
+
+// SetCatalogResumeFile keep the pages of the catalog listed in the file, the listing interrupted by registry errors
+// or restart continues from the next page link. The listing left by the previous run is loaded from it.
+func (c *Client) SetCatalogResumeFile(path string) error {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.resume = catalogResume{path: path}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var r catalogResume
+	for i, line := range bytes.Split(bytes.TrimSpace(data), []byte("\n")) {
+		var l catalogResumeLine
+		if err := json.Unmarshal(line, &l); err != nil {
+			// The last line may be cut by the crash, the pages before it are good.
+			c.logger.Warnf("Catalog resume file %s: line %d: %s", path, i+1, err)
+			break
+		}
+		if i == 0 {
+			r.started = l.Started
+			continue
+		}
+		r.next = l.Next
+		r.names = append(r.names, l.Repositories...)
+	}
+	if r.next != "" && time.Since(r.started) < catalogResumeMaxAge {
+		c.resume.started, c.resume.next, c.resume.names = r.started, r.next, r.names
+	}
+	return nil
+}
+
+// resumeCatalog the next page and the repos listed before it if the previous listing was interrupted recently.
+func (c *Client) resumeCatalog() (string, []string) {
+	r := c.resume
+	if r.next == "" || time.Since(r.started) >= catalogResumeMaxAge {
+		c.startCatalog()
+		return "", nil
+	}
+	c.logger.Infof("Resuming catalog listing from %s after %d repos", r.next, len(r.names))
+	return r.next, append([]string{}, r.names...)
+}
+
+// startCatalog start the listing from the first page.
+func (c *Client) startCatalog() {
+	c.resume = catalogResume{path: c.resume.path, started: time.Now()}
+	if c.resume.path == "" {
+		return
+	}
+	b, _ := json.Marshal(catalogResumeLine{Started: c.resume.started})
+	if err := ioutil.WriteFile(c.resume.path, append(b, '\n'), 0644); err != nil {
+		c.logger.Errorf("Cannot write catalog resume file: %s", err)
+	}
+}
+
+// catalogPageListed remember the page listed and the link to the next one.
+func (c *Client) catalogPageListed(names []string, next string) {
+	c.resume.next = next
+	c.resume.names = append(c.resume.names, names...)
+	if c.resume.path == "" {
+		return
+	}
+	f, err := os.OpenFile(c.resume.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err == nil {
+		b, _ := json.Marshal(catalogResumeLine{Next: next, Repositories: names})
+		_, err = f.Write(append(b, '\n'))
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if err != nil {
+		c.logger.Errorf("Cannot write catalog resume file: %s", err)
+	}
+}
+
+// catalogListed forget the listing completed.
+func (c *Client) catalogListed() {
+	c.resume = catalogResume{path: c.resume.path}
+	if c.resume.path == "" {
+		return
+	}
+	if err := os.Remove(c.resume.path); err != nil && !os.IsNotExist(err) {
+		c.logger.Errorf("Cannot remove catalog resume file: %s", err)
+	}
+}
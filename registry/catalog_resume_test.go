@@ -0,0 +1,100 @@
+package registry
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestCatalogResume(t *testing.T) {
+	pages := map[string]string{
+		"":  `{"repositories": ["alpine", "team/app"]}`,
+		"2": `{"repositories": ["team/multi"]}`,
+		"3": `{"repositories": ["web"]}`,
+	}
+	fail := map[string]bool{}
+	var requested []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v2/_catalog" {
+			return
+		}
+		page := r.URL.Query().Get("page")
+		requested = append(requested, page)
+		if fail[page] {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		switch page {
+		case "":
+			w.Header().Set("Link", `</v2/_catalog?page=2>; rel="next"`)
+		case "2":
+			w.Header().Set("Link", `</v2/_catalog?page=3>; rel="next"`)
+		}
+		fmt.Fprint(w, pages[page])
+	}))
+	defer server.Close()
+	dir, _ := ioutil.TempDir("", "resume")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "catalog.jsonl")
+	all := map[string][]string{"library": {"alpine", "web"}, "team": {"app", "multi"}}
+
+	convey.Convey("Continue the interrupted listing from the failed page", t, func() {
+		c := NewClient(server.URL, true, "", "")
+		convey.So(c.SetCatalogResumeFile(path), convey.ShouldBeNil)
+
+		fail["3"] = true
+		requested = nil
+		convey.So(c.Repositories(false), convey.ShouldBeEmpty)
+		convey.So(requested, convey.ShouldResemble, []string{"", "2", "3"})
+		page, _ := c.CatalogPage("", "", 10)
+		convey.So(len(page), convey.ShouldEqual, 3)
+		loading, _ := c.CatalogLoading()
+		convey.So(loading, convey.ShouldBeTrue)
+
+		fail["3"] = false
+		requested = nil
+		convey.So(c.Repositories(false), convey.ShouldResemble, all)
+		convey.So(requested, convey.ShouldResemble, []string{"3"})
+		_, err := os.Stat(path)
+		convey.So(os.IsNotExist(err), convey.ShouldBeTrue)
+
+		requested = nil
+		convey.So(c.Repositories(false), convey.ShouldResemble, all)
+		convey.So(requested, convey.ShouldResemble, []string{"", "2", "3"})
+	})
+
+	convey.Convey("Resume the listing of the previous run from the file", t, func() {
+		c := NewClient(server.URL, true, "", "")
+		convey.So(c.SetCatalogResumeFile(path), convey.ShouldBeNil)
+		fail["2"] = true
+		convey.So(c.Repositories(false), convey.ShouldBeEmpty)
+
+		fail["2"] = false
+		c = NewClient(server.URL, true, "", "")
+		convey.So(c.SetCatalogResumeFile(path), convey.ShouldBeNil)
+		requested = nil
+		convey.So(c.Repositories(false), convey.ShouldResemble, all)
+		convey.So(requested, convey.ShouldResemble, []string{"2", "3"})
+	})
+
+	convey.Convey("Keep the pages in memory without the file", t, func() {
+		c := NewClient(server.URL, true, "", "")
+		fail["3"] = true
+		c.Repositories(false)
+		fail["3"] = false
+		requested = nil
+		convey.So(c.Repositories(false), convey.ShouldResemble, all)
+		convey.So(requested, convey.ShouldResemble, []string{"3"})
+
+		c = NewClient(server.URL, true, "", "")
+		requested = nil
+		c.Repositories(false)
+		convey.So(requested, convey.ShouldResemble, []string{"", "2", "3"})
+	})
+}
@@ -0,0 +1,106 @@
+package registry
+
+import (
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestCatalogPage(t *testing.T) {
+	c := &Client{tagCounts: map[string]int{"library/alpine": 3, "team/app": 2, "team/empty": 0}}
+	c.setCatalog(map[string][]string{
+		"library": {"busybox", "alpine"},
+		"team":    {"multi", "empty", "app"},
+		"teams":   {"web"},
+	})
+
+	convey.Convey("Page through the whole catalog", t, func() {
+		page, next := c.CatalogPage("", "", 2)
+		convey.So(page, convey.ShouldResemble, []CatalogRepo{{"library", "alpine", 3}, {"library", "busybox", -1}})
+		convey.So(next, convey.ShouldEqual, "library/busybox")
+
+		page, next = c.CatalogPage("", next, 2)
+		convey.So(page, convey.ShouldResemble, []CatalogRepo{{"team", "app", 2}, {"team", "multi", -1}})
+		convey.So(next, convey.ShouldEqual, "team/multi")
+
+		page, next = c.CatalogPage("", next, 2)
+		convey.So(page, convey.ShouldResemble, []CatalogRepo{{"teams", "web", -1}})
+		convey.So(next, convey.ShouldEqual, "")
+	})
+
+	convey.Convey("Page through the namespace", t, func() {
+		page, next := c.CatalogPage("team", "", 10)
+		convey.So(page, convey.ShouldResemble, []CatalogRepo{{"team", "app", 2}, {"team", "multi", -1}})
+		convey.So(next, convey.ShouldEqual, "")
+
+		page, next = c.CatalogPage("team", "team/app", 10)
+		convey.So(page, convey.ShouldResemble, []CatalogRepo{{"team", "multi", -1}})
+		convey.So(next, convey.ShouldEqual, "")
+
+		page, _ = c.CatalogPage("missing", "", 10)
+		convey.So(page, convey.ShouldBeEmpty)
+	})
+}
+
+func TestOnCatalogChange(t *testing.T) {
+	c := &Client{tagCounts: map[string]int{"library/alpine": 3}}
+	changes := 0
+	c.OnCatalogChange(func() { changes++ })
+
+	convey.Convey("Notify when repos or tag counts change", t, func() {
+		c.setTagCount("alpine", 3, true)
+		c.setTagCount("team/missing", 1, false)
+		convey.So(changes, convey.ShouldEqual, 0)
+		c.setTagCount("alpine", -1, false)
+		convey.So(changes, convey.ShouldEqual, 1)
+		c.setTagCount("team/app", 0, true)
+		convey.So(changes, convey.ShouldEqual, 2)
+		c.setCatalog(map[string][]string{"library": {"alpine"}})
+		convey.So(changes, convey.ShouldEqual, 3)
+	})
+}
+
+func TestPartialCatalog(t *testing.T) {
+	c := &Client{tagCounts: map[string]int{}}
+
+	convey.Convey("Serve the repos listed so far until the catalog is loaded", t, func() {
+		loading, _ := c.CatalogLoading()
+		convey.So(loading, convey.ShouldBeFalse)
+
+		c.setCatalogListing(true)
+		c.setPartialCatalog([]string{"alpine", "mongo"}, "mongo", false)
+		c.setPartialCatalog([]string{"alpine", "mongo", "nginx"}, "nginx", false)
+		page, _ := c.CatalogPage("", "", 10)
+		convey.So(page, convey.ShouldResemble, []CatalogRepo{{"library", "alpine", -1}, {"library", "mongo", -1}})
+		loading, progress := c.CatalogLoading()
+		convey.So(loading, convey.ShouldBeTrue)
+		convey.So(progress, convey.ShouldEqual, catalogProgress("mongo"))
+
+		c.setCatalogListing(false)
+		c.setPartialCatalog([]string{"alpine", "mongo", "nginx", "team/app"}, "team/app", true)
+		convey.So(c.Namespaces(), convey.ShouldResemble, []string{"library", "team"})
+		loading, progress = c.CatalogLoading()
+		convey.So(loading, convey.ShouldBeTrue)
+		convey.So(progress, convey.ShouldEqual, catalogProgress("team/app"))
+		listedAt, _ := c.CatalogStatus()
+		convey.So(listedAt.IsZero(), convey.ShouldBeTrue)
+
+		c.setCatalog(map[string][]string{"library": {"alpine", "mongo", "nginx", "web"}, "team": {"app"}})
+		c.setPartialCatalog([]string{"alpine"}, "alpine", true)
+		page, _ = c.CatalogPage("library", "", 10)
+		convey.So(len(page), convey.ShouldEqual, 4)
+		loading, progress = c.CatalogLoading()
+		convey.So(loading, convey.ShouldBeFalse)
+		convey.So(progress, convey.ShouldEqual, 100)
+		listedAt, repos := c.CatalogStatus()
+		convey.So(listedAt.IsZero(), convey.ShouldBeFalse)
+		convey.So(repos, convey.ShouldEqual, 5)
+	})
+
+	convey.Convey("Estimate the progress by the position of the repo name", t, func() {
+		convey.So(catalogProgress(""), convey.ShouldEqual, 0)
+		convey.So(catalogProgress("alpine"), convey.ShouldBeLessThan, catalogProgress("mongo"))
+		convey.So(catalogProgress("mongo"), convey.ShouldBeLessThan, catalogProgress("team/app"))
+		convey.So(catalogProgress("zzz"), convey.ShouldEqual, 99)
+	})
+}
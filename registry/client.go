@@ -1,9 +1,10 @@
 package registry
 
 import (
-	"crypto"
 	"crypto/tls"
+	"errors"
 	"fmt"
+	"net/http"
 	"regexp"
 	"sort"
 	"strings"
@@ -23,16 +24,45 @@ type Client struct {
 	verifyTLS bool
 	username  string
 	password  string
+	apiToken  string
+	credsMux  sync.RWMutex
 	request   *gorequest.SuperAgent
+	http      *http.Client
+	basicAuth bool
 	logger    *logrus.Entry
 	mux       sync.Mutex
 	tokens    map[string]string
+	tokensMux sync.Mutex
 	repos     map[string][]string
 	tagCounts map[string]int
-	authURL   string
+	countsMux sync.RWMutex
+	catalog   catalogSnapshot
+	meta      tagMetaCache
+	listed    listedRepos
+	recent    recentRepos
+	search    searchIndex
+	stats     requestStats
+	// Index labels and annotations besides repo and tag names.
+	indexMetadata bool
+	authURL       string
+	// Extended API of the registry flavor, nil for plain distribution.
+	flavor string
+	ext    extension
+	// deletionGuard refuses deleting tags, e.g. still running somewhere.
+	deletionGuard func(repo, tag, digest string) error
+	// limiter bounds the requests to registry at once, nil for no limit.
+	limiter    requestLimiter
+	limiterMux sync.RWMutex
+	backoff    backoff
+	// resume pages of the interrupted catalog listing, guarded by mux.
+	resume catalogResume
+	// flights concurrent fetches of the same tags, manifests and metadata sharing one round trip.
+	flights flightGroup
+	// configs image configs cached by digest on disk.
+	configs configCache
 }
 
-// NewClient initialize Client.
+// NewClient initialize Client, Connect discovers the auth method of the registry.
 func NewClient(url string, verifyTLS bool, username, password string) *Client {
 	c := &Client{
 		url:       strings.TrimRight(url, "/"),
@@ -40,70 +70,147 @@ func NewClient(url string, verifyTLS bool, username, password string) *Client {
 		username:  username,
 		password:  password,
 
-		request:   gorequest.New().TLSClientConfig(&tls.Config{InsecureSkipVerify: !verifyTLS}),
+		request: gorequest.New().TLSClientConfig(&tls.Config{InsecureSkipVerify: !verifyTLS}),
+		http: &http.Client{Transport: &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: !verifyTLS},
+		}},
 		logger:    SetupLogging("registry.client"),
 		tokens:    map[string]string{},
 		repos:     map[string][]string{},
 		tagCounts: map[string]int{},
+		meta:      tagMetaCache{ttl: 10 * time.Minute},
 	}
+	return c
+}
+
+// Connect check the registry is reachable and discover its auth method. It can be called again after
+// a failure, e.g. when the registry was down at startup.
+func (c *Client) Connect() error {
 	resp, _, errs := c.request.Get(c.url+"/v2/").
 		Set("User-Agent", userAgent).End()
 	if len(errs) > 0 {
-		c.logger.Error(errs[0])
-		return nil
+		return errs[0]
 	}
 
 	authHeader := ""
 	if resp.StatusCode == 200 {
-		return c
+		return nil
 	} else if resp.StatusCode == 401 {
 		authHeader = resp.Header.Get("WWW-Authenticate")
 	} else {
-		c.logger.Error(resp.Status)
-		return nil
+		return fmt.Errorf("unexpected response: %s", resp.Status)
 	}
 
 	if strings.HasPrefix(authHeader, "Bearer") {
 		r, _ := regexp.Compile(`^Bearer realm="(http.+)",service="(.+)"`)
-		if m := r.FindStringSubmatch(authHeader); len(m) > 0 {
-			c.authURL = fmt.Sprintf("%s?service=%s", m[1], m[2])
-			c.logger.Info("Token auth service discovered at ", c.authURL)
-		}
-		if c.authURL == "" {
-			c.logger.Warn("No token auth service discovered from ", c.url)
-			return nil
+		m := r.FindStringSubmatch(authHeader)
+		if len(m) == 0 {
+			return fmt.Errorf("no token auth service discovered from %s", c.url)
 		}
+		c.credsMux.Lock()
+		c.authURL = fmt.Sprintf("%s?service=%s", m[1], m[2])
+		c.credsMux.Unlock()
+		c.logger.Info("Token auth service discovered at ", c.authURL)
 	} else if strings.HasPrefix(strings.ToLower(authHeader), "basic") {
+		c.credsMux.Lock()
 		c.request = c.request.SetBasicAuth(c.username, c.password)
+		c.basicAuth = true
+		c.credsMux.Unlock()
 		c.logger.Info("It was discovered the registry is configured with HTTP basic auth.")
 	}
+	return nil
+}
 
-	return c
+// credentials the registry credentials and the token of the extended API in use.
+func (c *Client) credentials() (string, string, string) {
+	c.credsMux.RLock()
+	defer c.credsMux.RUnlock()
+	return c.username, c.password, c.apiToken
+}
+
+// Credentials the registry credentials in use.
+func (c *Client) Credentials() (string, string) {
+	username, password, _ := c.credentials()
+	return username, password
+}
+
+// APIToken the token of the extended API in use.
+func (c *Client) APIToken() string {
+	_, _, token := c.credentials()
+	return token
+}
+
+// SetCredentials replace the registry credentials, e.g. after the secret is rotated.
+// Tokens obtained with the old credentials are dropped.
+func (c *Client) SetCredentials(username, password string) {
+	c.credsMux.Lock()
+	c.username, c.password = username, password
+	if c.basicAuth {
+		c.request = c.request.SetBasicAuth(username, password)
+	}
+	c.credsMux.Unlock()
+
+	c.tokensMux.Lock()
+	c.tokens = map[string]string{}
+	c.tokensMux.Unlock()
+}
+
+// SetAPIToken replace the bearer token of the extended API, empty to use the registry credentials.
+func (c *Client) SetAPIToken(token string) {
+	c.credsMux.Lock()
+	c.apiToken = token
+	c.credsMux.Unlock()
 }
 
 // getToken get existing or new auth token.
 func (c *Client) getToken(scope string) string {
+	c.tokensMux.Lock()
+	defer c.tokensMux.Unlock()
+
 	// Check if we have already a token and it's not expired.
 	if token, ok := c.tokens[scope]; ok {
-		resp, _, _ := c.request.Get(c.url+"/v2/").
+		release := c.acquire()
+		start := time.Now()
+		resp, _, errs := c.request.Get(c.url+"/v2/").
 			Set("Authorization", fmt.Sprintf("Bearer %s", token)).
 			Set("User-Agent", userAgent).End()
+		release()
+		c.recordRequest("GET", c.url+"/v2/", statusOf((*http.Response)(resp)), firstError(errs), start)
 		if resp != nil && resp.StatusCode == 200 {
 			return token
 		}
 	}
 
-	request := gorequest.New().TLSClientConfig(&tls.Config{InsecureSkipVerify: !c.verifyTLS})
-	resp, data, errs := request.Get(fmt.Sprintf("%s&scope=%s", c.authURL, scope)).
-		SetBasicAuth(c.username, c.password).
-		Set("User-Agent", userAgent).End()
-	if len(errs) > 0 {
-		c.logger.Error(errs[0])
+	token, err := c.fetchToken(scope)
+	if err != nil {
+		c.logger.Error(err)
 		return ""
 	}
+	c.tokens[scope] = token
+	c.logger.Debugf("Received new token for scope %s", scope)
+
+	return c.tokens[scope]
+}
+
+// fetchToken get new auth token from the token service.
+func (c *Client) fetchToken(scope string) (string, error) {
+	uri := fmt.Sprintf("%s&scope=%s", c.authURL, scope)
+	start := time.Now()
+	request := gorequest.New().TLSClientConfig(&tls.Config{InsecureSkipVerify: !c.verifyTLS}).Get(uri)
+	// Anonymous tokens are requested without credentials, e.g. from Docker Hub.
+	if username, password, _ := c.credentials(); username != "" {
+		request = request.SetBasicAuth(username, password)
+	}
+	release := c.acquire()
+	resp, data, errs := request.Set("User-Agent", userAgent).End()
+	release()
+	c.recordRequest("GET", uri, statusOf((*http.Response)(resp)), firstError(errs), start)
+	if len(errs) > 0 {
+		return "", errs[0]
+	}
 	if resp.StatusCode != 200 {
-		c.logger.Error("Failed to get token for scope ", scope, " from ", c.authURL)
-		return ""
+		return "", fmt.Errorf("Failed to get token for scope %s from %s: %s", scope, c.authURL, resp.Status)
 	}
 
 	token := gjson.Get(data, "token").String()
@@ -111,25 +218,40 @@ func (c *Client) getToken(scope string) string {
 	if token == "" {
 		token = gjson.Get(data, "access_token").String()
 	}
+	return token, nil
+}
 
-	c.tokens[scope] = token
-	c.logger.Debugf("Received new token for scope %s", scope)
-
-	return c.tokens[scope]
+// firstError the first error of gorequest errors if any.
+func firstError(errs []error) error {
+	if len(errs) > 0 {
+		return errs[0]
+	}
+	return nil
 }
 
 // callRegistry make an HTTP request to retrieve data from Docker registry.
 func (c *Client) callRegistry(uri, scope, manifestFormat string) (string, gorequest.Response) {
 	acceptHeader := fmt.Sprintf("application/vnd.docker.distribution.%s+json", manifestFormat)
+	// Accept OCI counterparts too, otherwise OCI images are not found.
+	switch manifestFormat {
+	case "manifest.v2":
+		acceptHeader = acceptHeader + ", " + MediaTypeOCIManifest
+	case "manifest.list.v2":
+		acceptHeader = acceptHeader + ", " + MediaTypeOCIIndex
+	}
 	authHeader := ""
 	if c.authURL != "" {
 		authHeader = fmt.Sprintf("Bearer %s", c.getToken(scope))
 	}
 
+	release := c.acquire()
+	start := time.Now()
 	resp, data, errs := c.request.Get(c.url+uri).
 		Set("Accept", acceptHeader).
 		Set("Authorization", authHeader).
 		Set("User-Agent", userAgent).End()
+	release()
+	c.recordRequest("GET", uri, statusOf((*http.Response)(resp)), firstError(errs), start)
 	if len(errs) > 0 {
 		c.logger.Error(errs[0])
 		return "", resp
@@ -146,18 +268,42 @@ func (c *Client) callRegistry(uri, scope, manifestFormat string) (string, gorequ
 	digest := resp.Header.Get("Docker-Content-Digest")
 	if digest == "" {
 		// Try to get digest from body instead, should be equal to what would be presented in Docker-Content-Digest.
-		h := crypto.SHA256.New()
-		h.Write([]byte(data))
-		resp.Header.Set("Docker-Content-Digest", fmt.Sprintf("sha256:%x", h.Sum(nil)))
+		resp.Header.Set("Docker-Content-Digest", DigestOf([]byte(data)))
 	}
 	return data, resp
 }
 
-// Namespaces list repo namespaces.
+// CheckAccess check the registry is reachable with the configured credentials.
+func (c *Client) CheckAccess() error {
+	scope := "registry:catalog:*"
+	if c.authURL != "" && c.getToken(scope) == "" {
+		return fmt.Errorf("cannot obtain a token from %s, check the registry credentials", c.authURL)
+	}
+	_, resp := c.callRegistry("/v2/_catalog?n=1", scope, "manifest.v2")
+	if resp == nil {
+		return fmt.Errorf("cannot connect to %s", c.url)
+	}
+	switch resp.StatusCode {
+	case 200:
+		return nil
+	case 401, 403:
+		return fmt.Errorf("access to the catalog is denied (%s), check the registry credentials", resp.Status)
+	}
+	return fmt.Errorf("unexpected response on the catalog request: %s", resp.Status)
+}
+
+// Namespaces list repo namespaces, of the repos listed so far during the first listing of the catalog.
 func (c *Client) Namespaces() []string {
-	namespaces := make([]string, 0, len(c.repos))
-	for k := range c.repos {
-		namespaces = append(namespaces, k)
+	c.catalog.mux.RLock()
+	keys := c.catalog.keys
+	c.catalog.mux.RUnlock()
+	namespaces := []string{}
+	for _, k := range keys {
+		// Keys are sorted, so the repos of a namespace are next to each other.
+		namespace := strings.SplitN(k, "/", 2)[0]
+		if len(namespaces) == 0 || namespaces[len(namespaces)-1] != namespace {
+			namespaces = append(namespaces, namespace)
+		}
 	}
 	if !ItemInSlice("library", namespaces) {
 		namespaces = append(namespaces, "library")
@@ -180,21 +326,31 @@ func (c *Client) Repositories(useCache bool) map[string][]string {
 	scope := "registry:catalog:*"
 	uri := "/v2/_catalog"
 	c.repos = map[string][]string{}
+	// The listing interrupted before continues with the next page.
+	next, names := c.resumeCatalog()
+	if next != "" {
+		uri = next
+	}
+	c.setCatalogListing(true)
+	defer c.setCatalogListing(false)
+	last := ""
 	for {
 		data, resp := c.callRegistry(uri, scope, "manifest.v2")
 		if data == "" {
+			// The repos listed before the failed page stay shown until the refresh lists the rest.
+			if len(names) > 0 {
+				c.setPartialCatalog(names, last, true)
+			}
 			return c.repos
 		}
 
+		var page []string
 		for _, r := range gjson.Get(data, "repositories").Array() {
-			namespace := "library"
-			repo := r.String()
-			if strings.Contains(repo, "/") {
-				f := strings.SplitN(repo, "/", 2)
-				namespace = f[0]
-				repo = f[1]
-			}
-			c.repos[namespace] = append(c.repos[namespace], repo)
+			page = append(page, r.String())
+		}
+		names = append(names, page...)
+		if len(page) > 0 {
+			last = page[len(page)-1]
 		}
 
 		// pagination
@@ -202,17 +358,34 @@ func (c *Client) Repositories(useCache bool) map[string][]string {
 		link := linkRegexp.FindStringSubmatch(linkHeader)
 		if len(link) == 2 {
 			// update uri and query next page
-			uri = link[1]
+			uri = c.relativeURI(link[1])
+			c.catalogPageListed(page, uri)
+			c.setPartialCatalog(names, last, false)
 		} else {
 			// no more pages
+			c.catalogListed()
 			break
 		}
 	}
+	c.repos = groupCatalog(names)
+	c.setCatalog(c.repos)
 	return c.repos
 }
 
-// Tags get tags for the repo.
+// Tags get tags for the repo, the concurrent calls for the same repo share one request.
 func (c *Client) Tags(repo string) []string {
+	v, _, shared := c.flights.do("tags:"+repo, func() (interface{}, error) {
+		return c.listTags(repo), nil
+	})
+	tags, _ := v.([]string)
+	if shared {
+		tags = append([]string(nil), tags...)
+	}
+	return tags
+}
+
+// listTags get tags for the repo from registry.
+func (c *Client) listTags(repo string) []string {
 	scope := fmt.Sprintf("repository:%s:*", repo)
 	data, _ := c.callRegistry(fmt.Sprintf("/v2/%s/tags/list", repo), scope, "manifest.v2")
 	var tags []string
@@ -238,8 +411,18 @@ func (c *Client) ManifestList(repo, tag string) (string, []gjson.Result) {
 	return sha256, gjson.Get(info, "manifests").Array()
 }
 
-// TagInfo get image info for the repo tag or digest sha256.
+// TagInfo get image info for the repo tag or digest sha256, the concurrent calls for the same tag share the requests.
 func (c *Client) TagInfo(repo, tag string, v1only bool) (string, string, string) {
+	v, _, _ := c.flights.do(fmt.Sprintf("info:%s:%s:%v", repo, tag, v1only), func() (interface{}, error) {
+		sha256, infoV1, infoV2 := c.tagInfo(repo, tag, v1only)
+		return [3]string{sha256, infoV1, infoV2}, nil
+	})
+	info, _ := v.([3]string)
+	return info[0], info[1], info[2]
+}
+
+// tagInfo get image info for the repo tag or digest sha256 from registry.
+func (c *Client) tagInfo(repo, tag string, v1only bool) (string, string, string) {
 	scope := fmt.Sprintf("repository:%s:*", repo)
 	uri := fmt.Sprintf("/v2/%s/manifests/%s", repo, tag)
 	// Note, if manifest.v1 does not exist because the image is requested by sha256,
@@ -264,38 +447,30 @@ func (c *Client) TagInfo(repo, tag string, v1only bool) (string, string, string)
 	return sha256, infoV1, infoV2
 }
 
-// TagCounts return map with tag counts.
-func (c *Client) TagCounts() map[string]int {
-	return c.tagCounts
-}
+var (
+	// ErrTagInUse the tag is not deleted as it is in use.
+	ErrTagInUse = errors.New("tag is in use")
+	// ErrTagLocked the tag is not deleted as it is locked by user.
+	ErrTagLocked = errors.New("tag is locked")
+	// ErrTagProtected the tag is not deleted as it matches protected tag patterns.
+	ErrTagProtected = errors.New("tag is protected")
+)
 
-// CountTags count repository tags in background regularly.
-func (c *Client) CountTags(interval uint8) {
-	for {
-		start := time.Now()
-		c.logger.Info("[CountTags] Calculating image tags...")
-		catalog := c.Repositories(false)
-		for n, repos := range catalog {
-			for _, r := range repos {
-				repoPath := r
-				if n != "library" {
-					repoPath = fmt.Sprintf("%s/%s", n, r)
-				}
-				c.tagCounts[fmt.Sprintf("%s/%s", n, r)] = len(c.Tags(repoPath))
-			}
-		}
-		c.logger.Infof("[CountTags] Job complete (%v).", time.Now().Sub(start))
-		time.Sleep(time.Duration(interval) * time.Minute)
-	}
+// SetDeletionGuard check every tag before deleting it, the guard returns error wrapping ErrTagInUse,
+// ErrTagLocked or ErrTagProtected to keep the tag.
+func (c *Client) SetDeletionGuard(guard func(repo, tag, digest string) error) {
+	c.mux.Lock()
+	c.deletionGuard = guard
+	c.mux.Unlock()
 }
 
 // DeleteTag delete image tag.
-func (c *Client) DeleteTag(repo, tag string) {
+func (c *Client) DeleteTag(repo, tag string) error {
 	scope := fmt.Sprintf("repository:%s:*", repo)
 	// Get sha256 digest for tag.
 	_, resp := c.callRegistry(fmt.Sprintf("/v2/%s/manifests/%s", repo, tag), scope, "manifest.list.v2")
 
-	if resp.Header.Get("Content-Type") != "application/vnd.docker.distribution.manifest.list.v2+json" {
+	if contentType := resp.Header.Get("Content-Type"); contentType != MediaTypeManifestList && contentType != MediaTypeOCIIndex {
 		_, resp = c.callRegistry(fmt.Sprintf("/v2/%s/manifests/%s", repo, tag), scope, "manifest.v2")
 	}
 
@@ -304,19 +479,34 @@ func (c *Client) DeleteTag(repo, tag string) {
 	if c.authURL != "" {
 		authHeader = fmt.Sprintf("Bearer %s", c.getToken(scope))
 	}
-	uri := fmt.Sprintf("/v2/%s/manifests/%s", repo, resp.Header.Get("Docker-Content-Digest"))
+	digest := resp.Header.Get("Docker-Content-Digest")
+	c.mux.Lock()
+	guard := c.deletionGuard
+	c.mux.Unlock()
+	if guard != nil {
+		if err := guard(repo, tag, digest); err != nil {
+			c.logger.Warnf("Not deleting %s:%s: %s", repo, tag, err)
+			return err
+		}
+	}
+	uri := fmt.Sprintf("/v2/%s/manifests/%s", repo, digest)
+	release := c.acquire()
+	start := time.Now()
 	resp, _, errs := c.request.Delete(c.url+uri).
 		Set("Authorization", authHeader).
 		Set("User-Agent", userAgent).End()
+	release()
+	c.recordRequest("DELETE", uri, statusOf((*http.Response)(resp)), firstError(errs), start)
 	if len(errs) > 0 {
 		c.logger.Error(errs[0])
-	} else {
-		// Returns 202 on success.
-		if !strings.Contains(repo, "/") {
-			c.tagCounts["library/"+repo]--
-		} else {
-			c.tagCounts[repo]--
-		}
-		c.logger.Infof("DELETE %s (tag:%s) %s", uri, tag, resp.Status)
+		return errs[0]
+	}
+	c.logger.Infof("DELETE %s (tag:%s) %s", uri, tag, resp.Status)
+	// Returns 202 on success.
+	if resp.StatusCode != 202 {
+		return fmt.Errorf("cannot delete %s:%s: %s", repo, tag, resp.Status)
 	}
+	c.forgetTagMetadata(repo, digest)
+	c.setTagCount(repo, -1, false)
+	return nil
 }
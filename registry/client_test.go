@@ -0,0 +1,66 @@
+package registry
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestSetCredentials(t *testing.T) {
+	password := "old"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if u, p, ok := r.BasicAuth(); !ok || u != "user" || p != password {
+			w.Header().Set("WWW-Authenticate", `Basic realm="registry"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/v2/app/tags/list":
+			fmt.Fprint(w, `{"name": "app", "tags": ["v1"]}`)
+		case "/v2/app/manifests/v1":
+			w.Header().Set("Docker-Content-Digest", "sha256:aaa")
+		}
+	}))
+	defer server.Close()
+
+	convey.Convey("Use rotated credentials", t, func() {
+		c := NewClient(server.URL, true, "user", "old")
+		convey.So(c.Connect(), convey.ShouldBeNil)
+		convey.So(c.Tags("app"), convey.ShouldResemble, []string{"v1"})
+
+		password = "new"
+		convey.So(c.Tags("app"), convey.ShouldBeEmpty)
+		_, err := c.ManifestDigest("app", "v1")
+		convey.So(err, convey.ShouldNotBeNil)
+
+		c.SetCredentials("user", "new")
+		convey.So(c.Tags("app"), convey.ShouldResemble, []string{"v1"})
+		digest, err := c.ManifestDigest("app", "v1")
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(digest, convey.ShouldEqual, "sha256:aaa")
+	})
+}
+
+func TestConnect(t *testing.T) {
+	status := http.StatusServiceUnavailable
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v2/" && status != http.StatusOK {
+			w.Header().Set("WWW-Authenticate", `Basic realm="registry"`)
+			w.WriteHeader(status)
+		}
+	}))
+	defer server.Close()
+
+	convey.Convey("Connect again after the registry failed", t, func() {
+		c := NewClient(server.URL, true, "user", "pass")
+		convey.So(c.Connect(), convey.ShouldNotBeNil)
+		convey.So(c.basicAuth, convey.ShouldBeFalse)
+
+		status = http.StatusUnauthorized
+		convey.So(c.Connect(), convey.ShouldBeNil)
+		convey.So(c.basicAuth, convey.ShouldBeTrue)
+	})
+}
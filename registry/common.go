@@ -1,9 +1,11 @@
 package registry
 
 import (
+	"crypto/sha256"
 	"fmt"
 	"os"
 	"reflect"
+	"regexp"
 	"sort"
 	"time"
 
@@ -58,3 +60,23 @@ func ItemInSlice(item string, slice []string) bool {
 	}
 	return false
 }
+
+// DigestOf calculate sha256 digest of the data as used in content addressable references.
+func DigestOf(data []byte) string {
+	return fmt.Sprintf("sha256:%x", sha256.Sum256(data))
+}
+
+var (
+	repoNameRegexp = regexp.MustCompile(`^[a-z0-9]+(?:(?:[._]|__|[-]*)[a-z0-9]+)*(?:/[a-z0-9]+(?:(?:[._]|__|[-]*)[a-z0-9]+)*)*$`)
+	tagNameRegexp  = regexp.MustCompile(`^[\w][\w.-]{0,127}$`)
+)
+
+// ValidRepoName check if the repository name is valid according to the distribution spec.
+func ValidRepoName(name string) bool {
+	return len(name) <= 255 && repoNameRegexp.MatchString(name)
+}
+
+// ValidTagName check if the tag name is valid according to the distribution spec.
+func ValidTagName(name string) bool {
+	return tagNameRegexp.MatchString(name)
+}
@@ -55,3 +55,19 @@ func TestItemInSlice(t *testing.T) {
 		convey.So(ItemInSlice("gh", a), convey.ShouldBeFalse)
 	})
 }
+
+func TestValidNames(t *testing.T) {
+	convey.Convey("Validate repository and tag names", t, func() {
+		convey.So(ValidRepoName("alpine"), convey.ShouldBeTrue)
+		convey.So(ValidRepoName("team/my-app_1.2"), convey.ShouldBeTrue)
+		convey.So(ValidRepoName("a/b/c"), convey.ShouldBeTrue)
+		convey.So(ValidRepoName("Alpine"), convey.ShouldBeFalse)
+		convey.So(ValidRepoName("team//app"), convey.ShouldBeFalse)
+		convey.So(ValidRepoName("/app"), convey.ShouldBeFalse)
+		convey.So(ValidRepoName("app-"), convey.ShouldBeFalse)
+		convey.So(ValidTagName("v1.0.0-rc_1"), convey.ShouldBeTrue)
+		convey.So(ValidTagName("latest"), convey.ShouldBeTrue)
+		convey.So(ValidTagName(".hidden"), convey.ShouldBeFalse)
+		convey.So(ValidTagName("a:b"), convey.ShouldBeFalse)
+	})
+}
@@ -0,0 +1,154 @@
+package registry
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tidwall/gjson"
+)
+
+// configDigestRegexp digests the config cache keeps files by, other ones are not cached not to escape the directory.
+var configDigestRegexp = regexp.MustCompile(`^sha256:[a-f0-9]{64}$`)
+
+// ImageConfig fields of the image config blob shown in the tag list. Config blobs are immutable per digest,
+// so they are cached by digest for good and only these fields are kept instead of the whole blob with history.
+type ImageConfig struct {
+	Created time.Time         `json:"created"`
+	Labels  map[string]string `json:"labels,omitempty"`
+	Ports   []string          `json:"ports,omitempty"`
+	// Platform e.g. linux/amd64, empty when the config does not tell it.
+	Platform  string `json:"platform,omitempty"`
+	OSVersion string `json:"os_version,omitempty"`
+}
+
+// PlatformLabel platform with OS version to show.
+func (ic ImageConfig) PlatformLabel() string {
+	return platformLabel(ic.Platform, ic.OSVersion)
+}
+
+// ConfigCacheStats usage of the config cache since start.
+type ConfigCacheStats struct {
+	Dir     string
+	Hits    int
+	Fetched int
+}
+
+// configCache image configs kept as JSON files by digest in the directory, so they survive restarts.
+type configCache struct {
+	mux     sync.Mutex
+	dir     string
+	hits    int
+	fetched int
+}
+
+// SetConfigCacheDir keep the image configs in the directory, empty disables the cache.
+func (c *Client) SetConfigCacheDir(dir string) error {
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	c.configs.mux.Lock()
+	c.configs.dir = dir
+	c.configs.mux.Unlock()
+	return nil
+}
+
+// ConfigCacheStats usage of the config cache.
+func (c *Client) ConfigCacheStats() ConfigCacheStats {
+	c.configs.mux.Lock()
+	defer c.configs.mux.Unlock()
+	return ConfigCacheStats{Dir: c.configs.dir, Hits: c.configs.hits, Fetched: c.configs.fetched}
+}
+
+// ParseImageConfig typed fields of the image config blob.
+func ParseImageConfig(blob string) ImageConfig {
+	config := gjson.Parse(blob)
+	ic := ImageConfig{Created: config.Get("created").Time(), Ports: ExposedPorts(config.Get("config"))}
+	for k, v := range config.Get("config.Labels").Map() {
+		if ic.Labels == nil {
+			ic.Labels = map[string]string{}
+		}
+		ic.Labels[k] = v.String()
+	}
+	if config.Get("os").String() != "" {
+		ic.Platform = PlatformString(config)
+		ic.OSVersion = config.Get(`os\.version`).String()
+	}
+	return ic
+}
+
+// ImageConfig get the image config by digest from the cache or registry. The blob fetched is cached only
+// when its digest matches, so a broken response is not kept for good.
+func (c *Client) ImageConfig(repo, digest string) (ImageConfig, error) {
+	c.configs.mux.Lock()
+	dir := c.configs.dir
+	c.configs.mux.Unlock()
+	path := ""
+	if dir != "" && configDigestRegexp.MatchString(digest) {
+		path = filepath.Join(dir, strings.TrimPrefix(digest, "sha256:")+".json")
+	}
+	if path != "" {
+		if data, err := ioutil.ReadFile(path); err == nil {
+			var ic ImageConfig
+			if err := json.Unmarshal(data, &ic); err == nil {
+				c.configs.mux.Lock()
+				c.configs.hits++
+				c.configs.mux.Unlock()
+				return ic, nil
+			}
+			c.logger.Warnf("Ignoring broken config cache file %s", path)
+		}
+	}
+
+	v, err, _ := c.flights.do("config:"+repo+"@"+digest, func() (interface{}, error) {
+		blob, err := c.GetBlob(repo, digest)
+		if err != nil {
+			return ImageConfig{}, err
+		}
+		ic := ParseImageConfig(blob)
+		c.configs.mux.Lock()
+		c.configs.fetched++
+		c.configs.mux.Unlock()
+		if path != "" && DigestOf([]byte(blob)) == digest {
+			if err := writeConfigCache(path, ic); err != nil {
+				c.logger.Warnf("Cannot cache image config %s: %s", digest, err)
+			}
+		}
+		return ic, nil
+	})
+	ic, ok := v.(ImageConfig)
+	if !ok {
+		// The shared fetch panicked.
+		return ImageConfig{}, fmt.Errorf("cannot get image config %s of %s", digest, repo)
+	}
+	return ic, err
+}
+
+// writeConfigCache write the cache file via a temporary one, so readers never see a partial file.
+func writeConfigCache(path string, ic ImageConfig) error {
+	data, err := json.Marshal(ic)
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(path), ".config-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,82 @@
+package registry
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestConfigCache(t *testing.T) {
+	config := `{"created": "2021-01-02T03:04:05Z", "os": "linux", "architecture": "amd64",
+		"config": {"Labels": {"maintainer": "team"}, "ExposedPorts": {"80/tcp": {}}}}`
+	digest := DigestOf([]byte(config))
+	wrong := "sha256:" + fmt.Sprintf("%064d", 0)
+	var mux sync.Mutex
+	fetched := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/alpine/manifests/3.13":
+			fmt.Fprintf(w, `{"mediaType": "%s", "config": {"digest": "%s"}, "layers": [{"size": 100}]}`, MediaTypeManifestV2, digest)
+		case "/v2/alpine/blobs/" + digest, "/v2/alpine/blobs/" + wrong:
+			mux.Lock()
+			fetched++
+			mux.Unlock()
+			fmt.Fprint(w, config)
+		}
+	}))
+	defer server.Close()
+	dir, _ := ioutil.TempDir("", "configs")
+	defer os.RemoveAll(dir)
+	fetches := func() int {
+		mux.Lock()
+		defer mux.Unlock()
+		n := fetched
+		fetched = 0
+		return n
+	}
+
+	convey.Convey("Keep the image configs by digest across restarts", t, func() {
+		c := NewClient(server.URL, true, "", "")
+		convey.So(c.SetConfigCacheDir(dir), convey.ShouldBeNil)
+		meta, err := c.TagMetadata("alpine", "3.13")
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(fetches(), convey.ShouldEqual, 1)
+
+		c = NewClient(server.URL, true, "", "")
+		convey.So(c.SetConfigCacheDir(dir), convey.ShouldBeNil)
+		cached, err := c.TagMetadata("alpine", "3.13")
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(fetches(), convey.ShouldEqual, 0)
+		convey.So(cached.Created.Equal(meta.Created), convey.ShouldBeTrue)
+		convey.So(cached.Labels, convey.ShouldResemble, map[string]string{"maintainer": "team"})
+		convey.So(cached.Ports, convey.ShouldResemble, []string{"80/tcp"})
+		convey.So(cached.Platforms, convey.ShouldResemble, []string{"linux/amd64"})
+		convey.So(c.ConfigCacheStats(), convey.ShouldResemble, ConfigCacheStats{Dir: dir, Hits: 1})
+	})
+
+	convey.Convey("Do not keep the blobs not matching the digest", t, func() {
+		c := NewClient(server.URL, true, "", "")
+		convey.So(c.SetConfigCacheDir(dir), convey.ShouldBeNil)
+		c.ImageConfig("alpine", wrong)
+		c.ImageConfig("alpine", wrong)
+		convey.So(fetches(), convey.ShouldEqual, 2)
+		_, err := os.Stat(filepath.Join(dir, fmt.Sprintf("%064d", 0)+".json"))
+		convey.So(os.IsNotExist(err), convey.ShouldBeTrue)
+	})
+
+	convey.Convey("Fetch every time without the directory", t, func() {
+		c := NewClient(server.URL, true, "", "")
+		ic, err := c.ImageConfig("alpine", digest)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(ic.Platform, convey.ShouldEqual, "linux/amd64")
+		c.ImageConfig("alpine", digest)
+		convey.So(fetches(), convey.ShouldEqual, 2)
+	})
+}
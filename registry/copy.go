@@ -0,0 +1,209 @@
+package registry
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/tidwall/gjson"
+)
+
+// Manifest media types.
+const (
+	MediaTypeManifestV2   = "application/vnd.docker.distribution.manifest.v2+json"
+	MediaTypeManifestList = "application/vnd.docker.distribution.manifest.list.v2+json"
+	MediaTypeOCIManifest  = "application/vnd.oci.image.manifest.v1+json"
+	MediaTypeOCIIndex     = "application/vnd.oci.image.index.v1+json"
+)
+
+var manifestMediaTypes = []string{MediaTypeManifestList, MediaTypeOCIIndex, MediaTypeManifestV2, MediaTypeOCIManifest}
+
+// do make an HTTP request to Docker registry, the caller has to close the response body.
+func (c *Client) do(method, uri, scope string, header http.Header, body io.Reader) (*http.Response, error) {
+	if !strings.HasPrefix(uri, "http") {
+		uri = c.url + uri
+	}
+	req, err := http.NewRequest(method, uri, body)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	// Registries may refuse uploads of unknown length, e.g. blobs read from a file section.
+	if s, ok := body.(interface{ Size() int64 }); ok && req.ContentLength == 0 {
+		req.ContentLength = s.Size()
+	}
+	req.Header.Set("User-Agent", userAgent)
+	if c.authURL != "" {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.getToken(scope)))
+	} else if c.basicAuth {
+		username, password, _ := c.credentials()
+		req.SetBasicAuth(username, password)
+	}
+
+	release := c.acquire()
+	start := time.Now()
+	resp, err := c.http.Do(req)
+	release()
+	c.recordRequest(method, uri, statusOf(resp), err, start)
+	if err != nil {
+		return nil, err
+	}
+	c.logger.Debugf("%s %s %s", method, uri, resp.Status)
+	return resp, nil
+}
+
+// GetManifest get raw manifest of any supported type, returns the body, its media type and digest.
+func (c *Client) GetManifest(repo, reference string) (string, string, string, error) {
+	scope := fmt.Sprintf("repository:%s:*", repo)
+	header := http.Header{"Accept": {strings.Join(manifestMediaTypes, ", ")}}
+	resp, err := c.do("GET", fmt.Sprintf("/v2/%s/manifests/%s", repo, reference), scope, header, nil)
+	if err != nil {
+		return "", "", "", err
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", "", "", err
+	}
+	if resp.StatusCode != 200 {
+		return "", "", "", fmt.Errorf("cannot get manifest %s:%s: %s", repo, reference, resp.Status)
+	}
+
+	mediaType := gjson.Get(string(data), "mediaType").String()
+	if mediaType == "" {
+		mediaType = strings.Split(resp.Header.Get("Content-Type"), ";")[0]
+	}
+	digest := resp.Header.Get("Docker-Content-Digest")
+	if digest == "" {
+		digest = DigestOf(data)
+	}
+	return string(data), mediaType, digest, nil
+}
+
+// PutManifest upload manifest under the reference, tag or digest.
+func (c *Client) PutManifest(repo, reference, mediaType, manifest string) error {
+	scope := fmt.Sprintf("repository:%s:*", repo)
+	header := http.Header{"Content-Type": {mediaType}}
+	resp, err := c.do("PUT", fmt.Sprintf("/v2/%s/manifests/%s", repo, reference), scope, header, strings.NewReader(manifest))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 201 {
+		msg, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("cannot put manifest %s:%s: %s %s", repo, reference, resp.Status, msg)
+	}
+	c.logger.Infof("PUT manifest %s:%s %s", repo, reference, resp.Status)
+	c.meta.mux.Lock()
+	c.meta.remove(repo + ":" + reference)
+	c.meta.mux.Unlock()
+	return nil
+}
+
+// copyBlob make the blob available in the destination repo, cross-repo mount is tried first.
+func (c *Client) copyBlob(srcRepo, dstRepo, digest string) error {
+	scope := fmt.Sprintf("repository:%s:*&scope=repository:%s:*", dstRepo, srcRepo)
+	resp, err := c.do("POST", fmt.Sprintf("/v2/%s/blobs/uploads/?mount=%s&from=%s", dstRepo, digest, srcRepo), scope, nil, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode == 201 {
+		return nil
+	}
+	if resp.StatusCode != 202 {
+		return fmt.Errorf("cannot mount blob %s to %s: %s", digest, dstRepo, resp.Status)
+	}
+
+	// Mount is not supported, the upload was started instead. Stream the blob from the source repo.
+	location := resp.Header.Get("Location")
+	blob, err := c.do("GET", fmt.Sprintf("/v2/%s/blobs/%s", srcRepo, digest), scope, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer blob.Body.Close()
+	if blob.StatusCode != 200 {
+		return fmt.Errorf("cannot get blob %s from %s: %s", digest, srcRepo, blob.Status)
+	}
+	return c.uploadBlob(dstRepo, location, scope, digest, blob.Body)
+}
+
+// uploadBlob finish the blob upload started at the location in a single request.
+func (c *Client) uploadBlob(repo, location, scope, digest string, body io.Reader) error {
+	if strings.Contains(location, "?") {
+		location = location + "&digest=" + digest
+	} else {
+		location = location + "?digest=" + digest
+	}
+	header := http.Header{"Content-Type": {"application/octet-stream"}}
+	resp, err := c.do("PUT", location, scope, header, body)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 201 {
+		return fmt.Errorf("cannot upload blob %s to %s: %s", digest, repo, resp.Status)
+	}
+	return nil
+}
+
+// PushBlob upload the blob to the repo unless it is there already.
+func (c *Client) PushBlob(repo, digest string, body io.Reader) error {
+	scope := fmt.Sprintf("repository:%s:*", repo)
+	resp, err := c.do("HEAD", fmt.Sprintf("/v2/%s/blobs/%s", repo, digest), scope, nil, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode == 200 {
+		return nil
+	}
+	resp, err = c.do("POST", fmt.Sprintf("/v2/%s/blobs/uploads/", repo), scope, nil, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 202 {
+		return fmt.Errorf("cannot start upload of blob %s to %s: %s", digest, repo, resp.Status)
+	}
+	return c.uploadBlob(repo, resp.Header.Get("Location"), scope, digest, body)
+}
+
+// CopyTag copy image or manifest list with all the blobs from one repo:tag to another.
+func (c *Client) CopyTag(srcRepo, srcTag, dstRepo, dstTag string) error {
+	manifest, mediaType, digest, err := c.GetManifest(srcRepo, srcTag)
+	if err != nil {
+		return err
+	}
+
+	switch mediaType {
+	case MediaTypeManifestList, MediaTypeOCIIndex:
+		// Copy all sub-images by digest first.
+		for _, m := range gjson.Get(manifest, "manifests.#.digest").Array() {
+			if err := c.CopyTag(srcRepo, m.String(), dstRepo, m.String()); err != nil {
+				return err
+			}
+		}
+	case MediaTypeManifestV2, MediaTypeOCIManifest:
+		if srcRepo != dstRepo {
+			blobs := append(gjson.Get(manifest, "layers.#.digest").Array(), gjson.Get(manifest, "config.digest"))
+			for _, b := range blobs {
+				if err := c.copyBlob(srcRepo, dstRepo, b.String()); err != nil {
+					return err
+				}
+			}
+		}
+	default:
+		return fmt.Errorf("cannot copy %s:%s, unsupported manifest type %q", srcRepo, srcTag, mediaType)
+	}
+
+	if dstTag == "" {
+		dstTag = digest
+	}
+	return c.PutManifest(dstRepo, dstTag, mediaType, manifest)
+}
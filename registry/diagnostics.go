@@ -0,0 +1,216 @@
+package registry
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// statsWindow how long to keep request samples for the error rates.
+const statsWindow = 15 * time.Minute
+
+// maxSamples how many latest samples to keep per endpoint.
+const maxSamples = 1000
+
+var endpointRegexp = regexp.MustCompile(`^/v2/.+/(tags/list|manifests|blobs/uploads|blobs)(/|$)`)
+
+// extEndpointRegexp extended APIs of Harbor and Quay grouped by the top resource.
+var extEndpointRegexp = regexp.MustCompile(`^/api/v[0-9.]+/[a-z]+`)
+
+type requestSample struct {
+	at      time.Time
+	latency time.Duration
+	failed  bool
+}
+
+type endpointSamples struct {
+	samples     []requestSample
+	lastError   string
+	lastErrorAt time.Time
+}
+
+type requestStats struct {
+	mux       sync.Mutex
+	endpoints map[string]*endpointSamples
+}
+
+// EndpointStats request stats of registry endpoint for the recent period.
+type EndpointStats struct {
+	Endpoint    string
+	Requests    int
+	Errors      int
+	AvgLatency  time.Duration
+	MaxLatency  time.Duration
+	LastError   string
+	LastErrorAt time.Time
+}
+
+// ErrorRate percent of the failed requests.
+func (s EndpointStats) ErrorRate() int {
+	if s.Requests == 0 {
+		return 0
+	}
+	return s.Errors * 100 / s.Requests
+}
+
+// CheckResult result of the connectivity check.
+type CheckResult struct {
+	Name    string
+	OK      bool
+	Latency time.Duration
+	Message string
+}
+
+// endpointName group request URIs by registry API endpoint, e.g. "GET /v2/<name>/manifests".
+func (c *Client) endpointName(method, uri string) string {
+	if c.authURL != "" && strings.HasPrefix(uri, strings.Split(c.authURL, "?")[0]) {
+		return method + " token"
+	}
+	if u, err := url.Parse(c.relativeURI(uri)); err == nil {
+		uri = u.Path
+	}
+	if m := endpointRegexp.FindStringSubmatch(uri); len(m) > 0 {
+		return fmt.Sprintf("%s /v2/<name>/%s", method, m[1])
+	}
+	if m := extEndpointRegexp.FindString(uri); m != "" {
+		return method + " " + m
+	}
+	return method + " " + uri
+}
+
+// recordRequest add the request result to the stats, network errors, server errors and denied access count as failures.
+func (c *Client) recordRequest(method, uri string, status int, err error, start time.Time) {
+	sample := requestSample{at: start, latency: time.Now().Sub(start)}
+	msg := ""
+	if err != nil {
+		msg = err.Error()
+	} else if status >= 500 || status == 401 || status == 403 {
+		msg = fmt.Sprintf("%d %s", status, http.StatusText(status))
+	}
+	sample.failed = msg != ""
+	c.observeStatus(status, time.Now())
+
+	name := c.endpointName(method, uri)
+	c.stats.mux.Lock()
+	defer c.stats.mux.Unlock()
+	if c.stats.endpoints == nil {
+		c.stats.endpoints = map[string]*endpointSamples{}
+	}
+	e, ok := c.stats.endpoints[name]
+	if !ok {
+		e = &endpointSamples{}
+		c.stats.endpoints[name] = e
+	}
+	e.samples = append(e.samples, sample)
+	if len(e.samples) > maxSamples {
+		e.samples = e.samples[len(e.samples)-maxSamples:]
+	}
+	if sample.failed {
+		e.lastError = msg
+		e.lastErrorAt = start
+	}
+}
+
+// RequestStats stats of the requests made to registry recently by endpoint.
+func (c *Client) RequestStats() []EndpointStats {
+	since := time.Now().Add(-statsWindow)
+	list := []EndpointStats{}
+	c.stats.mux.Lock()
+	defer c.stats.mux.Unlock()
+	for name, e := range c.stats.endpoints {
+		s := EndpointStats{Endpoint: name, LastError: e.lastError, LastErrorAt: e.lastErrorAt}
+		var total time.Duration
+		for _, sample := range e.samples {
+			if sample.at.Before(since) {
+				continue
+			}
+			s.Requests++
+			if sample.failed {
+				s.Errors++
+			}
+			total = total + sample.latency
+			if sample.latency > s.MaxLatency {
+				s.MaxLatency = sample.latency
+			}
+		}
+		if s.Requests == 0 && s.LastErrorAt.Before(since) {
+			continue
+		}
+		if s.Requests > 0 {
+			s.AvgLatency = total / time.Duration(s.Requests)
+		}
+		list = append(list, s)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Endpoint < list[j].Endpoint })
+	return list
+}
+
+// Diagnose check registry connectivity live: ping, token acquisition and catalog access.
+func (c *Client) Diagnose() []CheckResult {
+	var results []CheckResult
+
+	// Ping without credentials, 401 means the registry is up and requires auth.
+	release := c.acquire()
+	start := time.Now()
+	ping := CheckResult{Name: "Ping /v2/"}
+	resp, err := c.http.Get(c.url + "/v2/")
+	release()
+	ping.Latency = time.Now().Sub(start)
+	c.recordRequest("GET", c.url+"/v2/", statusOf(resp), err, start)
+	if err != nil {
+		ping.Message = err.Error()
+	} else {
+		resp.Body.Close()
+		ping.OK = resp.StatusCode == 200 || resp.StatusCode == 401
+		ping.Message = resp.Status
+	}
+	results = append(results, ping)
+
+	scope := "registry:catalog:*"
+	auth := CheckResult{Name: "Auth token"}
+	switch {
+	case c.authURL != "":
+		start = time.Now()
+		_, err := c.fetchToken(scope)
+		auth.Latency = time.Now().Sub(start)
+		auth.OK = err == nil
+		auth.Message = "Token received from " + c.authURL
+		if err != nil {
+			auth.Message = err.Error()
+		}
+	case c.basicAuth:
+		auth.OK = true
+		auth.Message = "Basic auth is used, no token needed"
+	default:
+		auth.OK = true
+		auth.Message = "Registry does not require auth"
+	}
+	results = append(results, auth)
+
+	start = time.Now()
+	catalog := CheckResult{Name: "Catalog /v2/_catalog"}
+	resp, err = c.do("GET", "/v2/_catalog?n=1", scope, nil, nil)
+	catalog.Latency = time.Now().Sub(start)
+	if err != nil {
+		catalog.Message = err.Error()
+	} else {
+		resp.Body.Close()
+		catalog.OK = resp.StatusCode == 200
+		catalog.Message = resp.Status
+	}
+	results = append(results, catalog)
+	return results
+}
+
+// statusOf status code of the response or 0 if there is none.
+func statusOf(resp *http.Response) int {
+	if resp == nil {
+		return 0
+	}
+	return resp.StatusCode
+}
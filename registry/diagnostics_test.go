@@ -0,0 +1,43 @@
+package registry
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestRequestStats(t *testing.T) {
+	c := &Client{url: "https://registry.local", authURL: "https://auth.local/token?service=registry"}
+
+	convey.Convey("Group requests by endpoint", t, func() {
+		convey.So(c.endpointName("GET", "/v2/"), convey.ShouldEqual, "GET /v2/")
+		convey.So(c.endpointName("GET", "/v2/_catalog?n=100"), convey.ShouldEqual, "GET /v2/_catalog")
+		convey.So(c.endpointName("GET", "/v2/team/app/tags/list"), convey.ShouldEqual, "GET /v2/<name>/tags/list")
+		convey.So(c.endpointName("PUT", "https://registry.local/v2/team/app/manifests/latest"), convey.ShouldEqual, "PUT /v2/<name>/manifests")
+		convey.So(c.endpointName("PUT", "/v2/app/blobs/uploads/123?digest=sha256:aaa"), convey.ShouldEqual, "PUT /v2/<name>/blobs/uploads")
+		convey.So(c.endpointName("GET", "https://auth.local/token?service=registry&scope=x"), convey.ShouldEqual, "GET token")
+		convey.So(c.endpointName("GET", "/api/v2.0/projects/team/repositories/app/artifacts?page=1"), convey.ShouldEqual, "GET /api/v2.0/projects")
+	})
+
+	convey.Convey("Count errors of the recent requests", t, func() {
+		start := time.Now()
+		c.recordRequest("GET", "/v2/app/manifests/1", 200, nil, start)
+		c.recordRequest("GET", "/v2/app/manifests/2", 404, nil, start)
+		c.recordRequest("GET", "/v2/app/manifests/3", 503, nil, start)
+		c.recordRequest("GET", "/v2/_catalog", 0, errors.New("connection refused"), start)
+		c.recordRequest("GET", "/v2/_catalog", 200, nil, start.Add(-time.Hour))
+
+		stats := c.RequestStats()
+		convey.So(len(stats), convey.ShouldEqual, 2)
+		convey.So(stats[0].Endpoint, convey.ShouldEqual, "GET /v2/<name>/manifests")
+		convey.So(stats[0].Requests, convey.ShouldEqual, 3)
+		convey.So(stats[0].Errors, convey.ShouldEqual, 1)
+		convey.So(stats[0].ErrorRate(), convey.ShouldEqual, 33)
+		convey.So(stats[0].LastError, convey.ShouldEqual, "503 Service Unavailable")
+		convey.So(stats[1].Endpoint, convey.ShouldEqual, "GET /v2/_catalog")
+		convey.So(stats[1].Requests, convey.ShouldEqual, 1)
+		convey.So(stats[1].ErrorRate(), convey.ShouldEqual, 100)
+	})
+}
@@ -0,0 +1,176 @@
+package registry
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/tidwall/gjson"
+)
+
+// Registry flavors, the products having richer APIs besides the registry one.
+const (
+	FlavorAuto         = "auto"
+	FlavorDistribution = "distribution"
+	FlavorHarbor       = "harbor"
+	FlavorQuay         = "quay"
+)
+
+// ErrNotSupported the registry flavor does not provide the information.
+var ErrNotSupported = errors.New("not supported by the registry")
+
+// GCStatus the latest garbage collection run by the registry.
+type GCStatus struct {
+	Status   string
+	Started  time.Time
+	Finished time.Time
+}
+
+// extension richer API of the registry product, methods return ErrNotSupported where it is not available.
+type extension interface {
+	// detect the registry is of this flavor.
+	detect() bool
+	// tagsMetadata metadata of all tags of the repo by a few API calls instead of fetching manifests one by one.
+	tagsMetadata(repo string) (map[string]TagMeta, error)
+	// pullCount how many times the repo was pulled.
+	pullCount(repo string) (int, error)
+	gcStatus() (GCStatus, error)
+}
+
+// SetFlavor use the extended API of the registry flavor, auto detects Harbor or Quay and falls back to distribution.
+// Requests to the extended API use the token as bearer if set, otherwise the registry credentials.
+// Returns the flavor in use.
+func (c *Client) SetFlavor(flavor, apiToken string) (string, error) {
+	c.SetAPIToken(apiToken)
+	api := extensionAPI{c: c}
+	flavors := map[string]extension{FlavorHarbor: harbor{api}, FlavorQuay: quay{api}}
+	switch flavor {
+	case FlavorAuto, "":
+		for _, name := range []string{FlavorHarbor, FlavorQuay} {
+			if flavors[name].detect() {
+				c.logger.Infof("Registry is detected as %s, its API is used for extended info.", name)
+				c.setExtension(name, flavors[name])
+				return name, nil
+			}
+		}
+		c.setExtension(FlavorDistribution, nil)
+	case FlavorDistribution:
+		c.setExtension(FlavorDistribution, nil)
+	case FlavorHarbor, FlavorQuay:
+		c.setExtension(flavor, flavors[flavor])
+	default:
+		return "", fmt.Errorf("unknown registry flavor %q", flavor)
+	}
+	return c.Flavor(), nil
+}
+
+func (c *Client) setExtension(flavor string, ext extension) {
+	c.mux.Lock()
+	c.flavor = flavor
+	c.ext = ext
+	c.mux.Unlock()
+}
+
+func (c *Client) extension() extension {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	return c.ext
+}
+
+// Flavor the registry flavor in use.
+func (c *Client) Flavor() string {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if c.flavor == "" {
+		return FlavorDistribution
+	}
+	return c.flavor
+}
+
+// PullCount how many times the repo was pulled according to the registry.
+func (c *Client) PullCount(repo string) (int, error) {
+	ext := c.extension()
+	if ext == nil {
+		return 0, ErrNotSupported
+	}
+	return ext.pullCount(repo)
+}
+
+// GCStatus the latest garbage collection according to the registry.
+func (c *Client) GCStatus() (GCStatus, error) {
+	ext := c.extension()
+	if ext == nil {
+		return GCStatus{}, ErrNotSupported
+	}
+	return ext.gcStatus()
+}
+
+// prefetchExtTagsMetadata cache metadata of all tags of the repo from the extended API.
+// Returns false if it is not available, then the metadata is fetched from manifests.
+func (c *Client) prefetchExtTagsMetadata(repo string) bool {
+	ext := c.extension()
+	if ext == nil {
+		return false
+	}
+	metas, err := ext.tagsMetadata(repo)
+	if err != nil {
+		if err != ErrNotSupported {
+			c.logger.Warnf("Cannot get metadata of %s tags from %s API: %s", repo, c.Flavor(), err)
+		}
+		return false
+	}
+	now := time.Now()
+	c.meta.mux.Lock()
+	for tag, meta := range metas {
+		meta.Repo = repo
+		meta.Tag = tag
+		meta.fetched = now
+		c.meta.put(meta)
+	}
+	c.meta.mux.Unlock()
+	return true
+}
+
+// extensionAPI makes requests to the extended API of the registry.
+type extensionAPI struct {
+	c *Client
+}
+
+// get JSON from the API path, e.g. /api/v2.0/systeminfo.
+func (a extensionAPI) get(path string) (gjson.Result, int, error) {
+	req, err := http.NewRequest("GET", a.c.url+path, nil)
+	if err != nil {
+		return gjson.Result{}, 0, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Accept", "application/json")
+	username, password, token := a.c.credentials()
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	} else if username != "" {
+		req.SetBasicAuth(username, password)
+	}
+
+	release := a.c.acquire()
+	start := time.Now()
+	resp, err := a.c.http.Do(req)
+	release()
+	a.c.recordRequest("GET", a.c.url+path, statusOf(resp), err, start)
+	if err != nil {
+		return gjson.Result{}, 0, err
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return gjson.Result{}, resp.StatusCode, err
+	}
+	if resp.StatusCode != 200 {
+		return gjson.Result{}, resp.StatusCode, fmt.Errorf("GET %s: %s", path, resp.Status)
+	}
+	if !gjson.ValidBytes(data) {
+		return gjson.Result{}, resp.StatusCode, fmt.Errorf("GET %s: invalid JSON", path)
+	}
+	return gjson.ParseBytes(data), resp.StatusCode, nil
+}
@@ -0,0 +1,111 @@
+package registry
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+// fakeExtensionAPI registry answering the registry ping and the given API paths.
+func fakeExtensionAPI(responses map[string]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v2/" {
+			return
+		}
+		body, ok := responses[r.URL.RequestURI()]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, body)
+	}))
+}
+
+func TestHarbor(t *testing.T) {
+	server := fakeExtensionAPI(map[string]string{
+		"/api/v2.0/systeminfo": `{"auth_mode": "db_auth", "harbor_version": "v2.3.0"}`,
+		"/api/v2.0/projects/team/repositories/tools%252Fapp/artifacts?with_tag=true&page=1&page_size=100": `[
+			{"digest": "sha256:aaa", "manifest_media_type": "application/vnd.oci.image.manifest.v1+json", "size": 1024,
+			 "push_time": "2021-05-02T10:00:00Z", "tags": [{"name": "v1"}, {"name": "latest"}],
+			 "extra_attrs": {"created": "2021-05-01T10:00:00Z", "os": "linux", "architecture": "amd64", "config": {"Labels": {"team": "core"}, "ExposedPorts": {"8080/tcp": {}}}}},
+			{"digest": "sha256:bbb", "manifest_media_type": "application/vnd.oci.image.index.v1+json", "size": 2048,
+			 "push_time": "2021-05-03T10:00:00Z", "tags": [{"name": "multi"}],
+			 "references": [{"platform": {"os": "linux", "architecture": "arm64"}}, {"platform": {"os": "unknown", "architecture": "unknown"}}]}
+		]`,
+		"/api/v2.0/projects/team/repositories/tools%252Fapp": `{"name": "team/tools/app", "pull_count": 42}`,
+		"/api/v2.0/system/gc?page=1&page_size=1":             `[{"job_status": "Success", "creation_time": "2021-05-04T01:00:00Z", "update_time": "2021-05-04T01:05:00Z"}]`,
+	})
+	defer server.Close()
+	c := NewClient(server.URL, true, "", "")
+
+	convey.Convey("Detect Harbor and use its API", t, func() {
+		flavor, err := c.SetFlavor(FlavorAuto, "")
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(flavor, convey.ShouldEqual, FlavorHarbor)
+
+		metas := c.TagsMetadata("team/tools/app", []string{"latest", "multi"})
+		convey.So(metas[0].Digest, convey.ShouldEqual, "sha256:aaa")
+		convey.So(metas[0].Tag, convey.ShouldEqual, "latest")
+		convey.So(metas[0].Created.Format("2006-01-02"), convey.ShouldEqual, "2021-05-01")
+		convey.So(metas[0].Platforms, convey.ShouldResemble, []string{"linux/amd64"})
+		convey.So(metas[0].Labels["team"], convey.ShouldEqual, "core")
+		convey.So(metas[0].Ports, convey.ShouldResemble, []string{"8080/tcp"})
+		convey.So(metas[1].Created.Format("2006-01-02"), convey.ShouldEqual, "2021-05-03")
+		convey.So(metas[1].Platforms, convey.ShouldResemble, []string{"linux/arm64"})
+
+		pulls, err := c.PullCount("team/tools/app")
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(pulls, convey.ShouldEqual, 42)
+
+		gc, err := c.GCStatus()
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(gc.Status, convey.ShouldEqual, "success")
+		convey.So(gc.Finished.Format("15:04"), convey.ShouldEqual, "01:05")
+
+		_, err = c.PullCount("app")
+		convey.So(err, convey.ShouldEqual, ErrNotSupported)
+	})
+}
+
+func TestQuay(t *testing.T) {
+	server := fakeExtensionAPI(map[string]string{
+		"/api/v1/discovery": `{"swagger": "2.0", "info": {"title": "Quay Frontend"}}`,
+		"/api/v1/repository/team/app/tag/?onlyActiveTags=true&limit=100&page=1": `{"tags": [
+			{"name": "v1", "manifest_digest": "sha256:aaa", "size": 1024, "start_ts": 1620000000}], "has_additional": true}`,
+		"/api/v1/repository/team/app/tag/?onlyActiveTags=true&limit=100&page=2": `{"tags": [
+			{"name": "v2", "manifest_digest": "sha256:bbb", "size": 2048, "start_ts": 1620100000}], "has_additional": false}`,
+		"/api/v1/repository/team/app?includeStats=true": `{"stats": [{"date": "2021-05-01", "count": 3}, {"date": "2021-05-02", "count": 4}]}`,
+	})
+	defer server.Close()
+	c := NewClient(server.URL, true, "", "")
+
+	convey.Convey("Detect Quay and use its API", t, func() {
+		flavor, err := c.SetFlavor(FlavorAuto, "token")
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(flavor, convey.ShouldEqual, FlavorQuay)
+
+		metas := c.TagsMetadata("team/app", []string{"v1", "v2"})
+		convey.So(metas[0].Digest, convey.ShouldEqual, "sha256:aaa")
+		convey.So(metas[1].Size, convey.ShouldEqual, 2048)
+
+		pulls, _ := c.PullCount("team/app")
+		convey.So(pulls, convey.ShouldEqual, 7)
+
+		_, err = c.GCStatus()
+		convey.So(err, convey.ShouldEqual, ErrNotSupported)
+	})
+
+	convey.Convey("Fall back to plain registry", t, func() {
+		flavor, err := c.SetFlavor(FlavorDistribution, "")
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(flavor, convey.ShouldEqual, FlavorDistribution)
+		_, err = c.PullCount("team/app")
+		convey.So(err, convey.ShouldEqual, ErrNotSupported)
+
+		_, err = c.SetFlavor("nexus", "")
+		convey.So(err, convey.ShouldNotBeNil)
+	})
+}
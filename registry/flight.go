@@ -0,0 +1,49 @@
+package registry
+
+import "sync"
+
+// flightCall fetch in progress, the callers arriving meanwhile wait for its result.
+type flightCall struct {
+	wg    sync.WaitGroup
+	value interface{}
+	err   error
+	dups  int
+}
+
+// flightGroup deduplicate concurrent fetches by key, e.g. when several pages of the same tag are loaded at once
+// they share one registry round trip instead of requesting the same manifest and config each.
+// Only the fetches running at the same time are shared, nothing is cached once the fetch is done.
+type flightGroup struct {
+	mux   sync.Mutex
+	calls map[string]*flightCall
+}
+
+// do run fn unless it is already running for the key, then wait for that run and return its result.
+// shared is true when the result was returned to more than one caller, so mutable values should be copied.
+func (g *flightGroup) do(key string, fn func() (interface{}, error)) (value interface{}, err error, shared bool) {
+	g.mux.Lock()
+	if g.calls == nil {
+		g.calls = map[string]*flightCall{}
+	}
+	if call, ok := g.calls[key]; ok {
+		call.dups++
+		g.mux.Unlock()
+		call.wg.Wait()
+		return call.value, call.err, true
+	}
+	call := &flightCall{}
+	call.wg.Add(1)
+	g.calls[key] = call
+	g.mux.Unlock()
+
+	// The waiters are released even if fn panics, e.g. recovered by the request handler.
+	defer func() {
+		g.mux.Lock()
+		delete(g.calls, key)
+		shared = call.dups > 0
+		g.mux.Unlock()
+		call.wg.Done()
+	}()
+	call.value, call.err = fn()
+	return call.value, call.err, false
+}
@@ -0,0 +1,123 @@
+package registry
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestFlightGroup(t *testing.T) {
+	var mux sync.Mutex
+	requests := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mux.Lock()
+		requests[r.URL.Path]++
+		mux.Unlock()
+		time.Sleep(50 * time.Millisecond)
+		switch r.URL.Path {
+		case "/v2/alpine/tags/list":
+			fmt.Fprint(w, `{"name": "alpine", "tags": ["3.12", "3.13"]}`)
+		case "/v2/alpine/manifests/3.13":
+			fmt.Fprintf(w, `{"mediaType": "%s", "config": {"digest": "sha256:ccc"}, "layers": [{"size": 100}]}`, MediaTypeManifestV2)
+		case "/v2/alpine/blobs/sha256:ccc":
+			fmt.Fprint(w, `{"created": "2021-01-02T03:04:05Z"}`)
+		}
+	}))
+	defer server.Close()
+
+	c := NewClient(server.URL, true, "", "")
+	burst := func(fn func()) map[string]int {
+		mux.Lock()
+		requests = map[string]int{}
+		mux.Unlock()
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				fn()
+			}()
+		}
+		wg.Wait()
+		mux.Lock()
+		defer mux.Unlock()
+		return requests
+	}
+
+	convey.Convey("Concurrent tag lists of the repo share one request", t, func() {
+		var results [][]string
+		var resultsMux sync.Mutex
+		requests := burst(func() {
+			tags := c.Tags("alpine")
+			resultsMux.Lock()
+			results = append(results, tags)
+			resultsMux.Unlock()
+		})
+		convey.So(requests["/v2/alpine/tags/list"], convey.ShouldEqual, 1)
+		convey.So(len(results), convey.ShouldEqual, 8)
+		results[0][0] = "changed"
+		for _, tags := range results[1:] {
+			convey.So(tags, convey.ShouldResemble, []string{"3.12", "3.13"})
+		}
+
+		// Nothing is cached once the request is done.
+		c.Tags("alpine")
+		mux.Lock()
+		convey.So(requests["/v2/alpine/tags/list"], convey.ShouldEqual, 2)
+		mux.Unlock()
+	})
+
+	convey.Convey("Concurrent metadata fetches of the tag share the manifest and config requests", t, func() {
+		var sizes []int64
+		var sizesMux sync.Mutex
+		requests := burst(func() {
+			meta, _ := c.TagMetadata("alpine", "3.13")
+			sizesMux.Lock()
+			sizes = append(sizes, meta.Size)
+			sizesMux.Unlock()
+		})
+		convey.So(sizes, convey.ShouldResemble, []int64{100, 100, 100, 100, 100, 100, 100, 100})
+		convey.So(requests["/v2/alpine/manifests/3.13"], convey.ShouldEqual, 1)
+		convey.So(requests["/v2/alpine/blobs/sha256:ccc"], convey.ShouldEqual, 1)
+	})
+
+	convey.Convey("Concurrent image info of the tag share the requests", t, func() {
+		requests := burst(func() {
+			c.TagInfo("alpine", "3.13", false)
+		})
+		convey.So(requests["/v2/alpine/manifests/3.13"], convey.ShouldEqual, 2)
+	})
+
+	convey.Convey("Release the waiters when the shared fetch panics", t, func() {
+		var g flightGroup
+		started := make(chan struct{})
+		done := make(chan error)
+		go func() {
+			defer func() { recover() }()
+			g.do("key", func() (interface{}, error) {
+				close(started)
+				time.Sleep(20 * time.Millisecond)
+				panic("failed")
+			})
+		}()
+		<-started
+		go func() {
+			_, err, shared := g.do("key", func() (interface{}, error) { return nil, nil })
+			if !shared {
+				err = fmt.Errorf("not shared")
+			}
+			done <- err
+		}()
+		select {
+		case err := <-done:
+			convey.So(err, convey.ShouldBeNil)
+		case <-time.After(time.Second):
+			t.Fatal("waiter is not released")
+		}
+	})
+}
@@ -0,0 +1,117 @@
+package registry
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/tidwall/gjson"
+)
+
+// harborPageSize items per page of Harbor API lists, the maximum allowed.
+const harborPageSize = 100
+
+// harbor extended API of Harbor v2.
+type harbor struct {
+	api extensionAPI
+}
+
+func (h harbor) detect() bool {
+	res, _, err := h.api.get("/api/v2.0/systeminfo")
+	return err == nil && (res.Get("harbor_version").Exists() || res.Get("auth_mode").Exists())
+}
+
+// repoPath API path of the repo, its name within the project is escaped twice as Harbor requires.
+func (h harbor) repoPath(repo string) (string, error) {
+	parts := strings.SplitN(repo, "/", 2)
+	if len(parts) < 2 {
+		return "", ErrNotSupported
+	}
+	return fmt.Sprintf("/api/v2.0/projects/%s/repositories/%s", url.PathEscape(parts[0]), url.PathEscape(url.PathEscape(parts[1]))), nil
+}
+
+func (h harbor) tagsMetadata(repo string) (map[string]TagMeta, error) {
+	path, err := h.repoPath(repo)
+	if err != nil {
+		return nil, err
+	}
+	metas := map[string]TagMeta{}
+	for page := 1; ; page++ {
+		res, _, err := h.api.get(fmt.Sprintf("%s/artifacts?with_tag=true&page=%d&page_size=%d", path, page, harborPageSize))
+		if err != nil {
+			return nil, err
+		}
+		artifacts := res.Array()
+		for _, a := range artifacts {
+			meta := harborArtifactMeta(a)
+			for _, t := range a.Get("tags.#.name").Array() {
+				metas[t.String()] = meta
+			}
+		}
+		if len(artifacts) < harborPageSize {
+			return metas, nil
+		}
+	}
+}
+
+// harborArtifactMeta tag metadata from Harbor artifact.
+func harborArtifactMeta(a gjson.Result) TagMeta {
+	meta := TagMeta{
+		Digest:      a.Get("digest").String(),
+		MediaType:   a.Get("manifest_media_type").String(),
+		Size:        a.Get("size").Int(),
+		Created:     a.Get("extra_attrs.created").Time(),
+		Labels:      map[string]string{},
+		Annotations: map[string]string{},
+	}
+	if meta.Created.IsZero() {
+		// Image index has no config, the push time is the best guess then.
+		meta.Created = a.Get("push_time").Time()
+	}
+	for k, v := range a.Get("extra_attrs.config.Labels").Map() {
+		meta.Labels[k] = v.String()
+	}
+	for k, v := range a.Get("annotations").Map() {
+		meta.Annotations[k] = v.String()
+	}
+	meta.Ports = ExposedPorts(a.Get("extra_attrs.config"))
+	if a.Get("extra_attrs.os").String() != "" {
+		meta.Platforms = []string{PlatformString(a.Get("extra_attrs"))}
+		meta.PlatformLabels = []string{PlatformLabel(a.Get("extra_attrs"))}
+	}
+	for _, r := range a.Get("references").Array() {
+		if platform := PlatformString(r.Get("platform")); platform != "unknown/unknown" && platform != "/" {
+			meta.Platforms = append(meta.Platforms, platform)
+			meta.PlatformLabels = append(meta.PlatformLabels, PlatformLabel(r.Get("platform")))
+		}
+	}
+	return meta
+}
+
+func (h harbor) pullCount(repo string) (int, error) {
+	path, err := h.repoPath(repo)
+	if err != nil {
+		return 0, err
+	}
+	res, _, err := h.api.get(path)
+	if err != nil {
+		return 0, err
+	}
+	return int(res.Get("pull_count").Int()), nil
+}
+
+func (h harbor) gcStatus() (GCStatus, error) {
+	res, _, err := h.api.get("/api/v2.0/system/gc?page=1&page_size=1")
+	if err != nil {
+		return GCStatus{}, err
+	}
+	last := res.Get("0")
+	if !last.Exists() {
+		return GCStatus{Status: "never run"}, nil
+	}
+	status := GCStatus{Status: strings.ToLower(last.Get("job_status").String()), Started: last.Get("creation_time").Time()}
+	if status.Status != "running" && status.Status != "pending" {
+		status.Finished = last.Get("update_time").Time()
+	}
+	return status, nil
+}
@@ -0,0 +1,51 @@
+package registry
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/tidwall/gjson"
+)
+
+// PruneIndex remove sub-images from manifest list or OCI index keeping only those with the digests listed.
+// Attestation manifests referring to removed sub-images are removed as well.
+func PruneIndex(manifest string, keep []string) (string, error) {
+	var index map[string]interface{}
+	if err := json.Unmarshal([]byte(manifest), &index); err != nil {
+		return "", err
+	}
+
+	var manifests []interface{}
+	kept := 0
+	for _, m := range gjson.Get(manifest, "manifests").Array() {
+		if m.Get(`annotations.vnd\.docker\.reference\.type`).String() == "attestation-manifest" {
+			if !ItemInSlice(m.Get(`annotations.vnd\.docker\.reference\.digest`).String(), keep) {
+				continue
+			}
+		} else if ItemInSlice(m.Get("digest").String(), keep) {
+			kept++
+		} else {
+			continue
+		}
+		manifests = append(manifests, m.Value())
+	}
+	if kept == 0 {
+		return "", fmt.Errorf("at least one sub-image has to be kept")
+	}
+
+	index["manifests"] = manifests
+	data, err := json.MarshalIndent(index, "", "   ")
+	return string(data), err
+}
+
+// IndexImages digests of the sub-images of manifest list or OCI index without attestation manifests,
+// empty for a single image manifest.
+func IndexImages(manifest string) []string {
+	var list []string
+	for _, m := range gjson.Get(manifest, "manifests").Array() {
+		if m.Get(`annotations.vnd\.docker\.reference\.type`).String() != "attestation-manifest" {
+			list = append(list, m.Get("digest").String())
+		}
+	}
+	return list
+}
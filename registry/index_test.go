@@ -0,0 +1,38 @@
+package registry
+
+import (
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+	"github.com/tidwall/gjson"
+)
+
+func TestPruneIndex(t *testing.T) {
+	index := `{
+		"schemaVersion": 2,
+		"mediaType": "application/vnd.oci.image.index.v1+json",
+		"manifests": [
+			{"digest": "sha256:aaa", "platform": {"os": "linux", "architecture": "amd64"}},
+			{"digest": "sha256:bbb", "platform": {"os": "windows", "architecture": "amd64"}},
+			{"digest": "sha256:ccc", "platform": {"os": "unknown", "architecture": "unknown"},
+			 "annotations": {"vnd.docker.reference.digest": "sha256:aaa", "vnd.docker.reference.type": "attestation-manifest"}},
+			{"digest": "sha256:ddd", "platform": {"os": "unknown", "architecture": "unknown"},
+			 "annotations": {"vnd.docker.reference.digest": "sha256:bbb", "vnd.docker.reference.type": "attestation-manifest"}}
+		]
+	}`
+	convey.Convey("Prune sub-images of the index", t, func() {
+		res, err := PruneIndex(index, []string{"sha256:aaa"})
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(gjson.Get(res, "manifests.#.digest").String(), convey.ShouldEqual, `["sha256:aaa","sha256:ccc"]`)
+		convey.So(gjson.Get(res, "mediaType").String(), convey.ShouldEqual, MediaTypeOCIIndex)
+		convey.So(gjson.Get(res, "manifests.1.annotations.vnd\\.docker\\.reference\\.type").String(), convey.ShouldEqual, "attestation-manifest")
+
+		_, err = PruneIndex(index, []string{"sha256:ccc"})
+		convey.So(err, convey.ShouldNotBeNil)
+	})
+
+	convey.Convey("List sub-images of the index without attestations", t, func() {
+		convey.So(IndexImages(index), convey.ShouldResemble, []string{"sha256:aaa", "sha256:bbb"})
+		convey.So(IndexImages(`{"schemaVersion": 2, "layers": []}`), convey.ShouldBeEmpty)
+	})
+}
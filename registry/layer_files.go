@@ -0,0 +1,226 @@
+package registry
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"sort"
+	"strings"
+)
+
+// whiteoutPrefix marks the files deleted by the layer from the lower layers.
+const whiteoutPrefix = ".wh."
+
+// opaqueWhiteout hides all entries of the directory from the lower layers.
+const opaqueWhiteout = whiteoutPrefix + whiteoutPrefix + ".opq"
+
+// ErrUnsupportedLayer the layer is not a tarball or its compression is not supported.
+var ErrUnsupportedLayer = errors.New("unsupported layer")
+
+// LayerFile entry of the layer tarball.
+type LayerFile struct {
+	Path string
+	Size int64
+	// Mode file type and permissions as ls shows them, e.g. -rwxr-xr-x.
+	Mode string
+	Dir  bool
+	// Link target of the symlink or hard link.
+	Link string
+	// Whiteout the file is deleted from the lower layers.
+	Whiteout bool
+	// Layer number of the layer the entry comes from in the merged view, 1 is the base layer.
+	Layer int
+}
+
+// LayerFiles list entries of the layer streaming its tarball, nothing is written to disk. The layer is not read
+// if it is larger than maxSize and the listing stops after maxEntries, it is truncated then.
+func (c *Client) LayerFiles(repo, digest string, maxSize int64, maxEntries int) ([]LayerFile, bool, error) {
+	scope := fmt.Sprintf("repository:%s:pull", repo)
+	resp, err := c.do("GET", fmt.Sprintf("/v2/%s/blobs/%s", repo, digest), scope, nil, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return nil, false, fmt.Errorf("cannot get blob %s from %s: %s", digest, repo, resp.Status)
+	}
+	if resp.ContentLength > maxSize {
+		return nil, false, fmt.Errorf("layer of %s is larger than the limit of %s", PrettySize(float64(resp.ContentLength)), PrettySize(float64(maxSize)))
+	}
+	return listTar(io.LimitReader(resp.Body, maxSize), maxEntries)
+}
+
+// ReadLayerText read the text files of the layer not larger than maxFileSize streaming its tarball, binary files
+// are skipped. The layer is not read if it is larger than maxSize.
+func (c *Client) ReadLayerText(repo, digest string, maxSize, maxFileSize int64, fn func(path string, data []byte)) error {
+	scope := fmt.Sprintf("repository:%s:pull", repo)
+	resp, err := c.do("GET", fmt.Sprintf("/v2/%s/blobs/%s", repo, digest), scope, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("cannot get blob %s from %s: %s", digest, repo, resp.Status)
+	}
+	if resp.ContentLength > maxSize {
+		return fmt.Errorf("layer of %s is larger than the limit of %s", PrettySize(float64(resp.ContentLength)), PrettySize(float64(maxSize)))
+	}
+	return readTarText(io.LimitReader(resp.Body, maxSize), maxFileSize, fn)
+}
+
+// readTarText call fn with the content of the regular text files of the tarball not larger than maxFileSize.
+func readTarText(r io.Reader, maxFileSize int64, fn func(path string, data []byte)) error {
+	stream, err := decompressLayer(r)
+	if err != nil {
+		return err
+	}
+	tr := tar.NewReader(stream)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if h.Typeflag != tar.TypeReg || h.Size == 0 || h.Size > maxFileSize {
+			continue
+		}
+		data, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return err
+		}
+		head := data
+		if len(head) > 512 {
+			head = head[:512]
+		}
+		if bytes.IndexByte(head, 0) < 0 {
+			fn(strings.TrimPrefix(path.Clean("/"+h.Name), "/"), data)
+		}
+	}
+}
+
+// decompressLayer uncompressed stream of the layer, gzip compressed or not.
+func decompressLayer(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	magic, _ := br.Peek(4)
+	switch {
+	case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrUnsupportedLayer, err)
+		}
+		return gz, nil
+	case bytes.Equal(magic, []byte{0x28, 0xb5, 0x2f, 0xfd}):
+		return nil, fmt.Errorf("%w: zstd compression is not supported", ErrUnsupportedLayer)
+	}
+	return br, nil
+}
+
+// listTar list entries of the tarball, gzip compressed or not.
+func listTar(r io.Reader, maxEntries int) ([]LayerFile, bool, error) {
+	stream, err := decompressLayer(r)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var files []LayerFile
+	tr := tar.NewReader(stream)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			return files, false, nil
+		}
+		if err != nil {
+			if len(files) == 0 {
+				return nil, false, fmt.Errorf("%w: not a tar archive: %s", ErrUnsupportedLayer, err)
+			}
+			if err == io.ErrUnexpectedEOF {
+				return files, true, fmt.Errorf("layer is cut at the size limit")
+			}
+			return files, false, err
+		}
+		if len(files) >= maxEntries {
+			return files, true, nil
+		}
+		name := strings.TrimPrefix(path.Clean("/"+h.Name), "/")
+		if name == "" {
+			continue
+		}
+		f := LayerFile{
+			Path:     name,
+			Size:     h.Size,
+			Mode:     h.FileInfo().Mode().String(),
+			Dir:      h.Typeflag == tar.TypeDir,
+			Whiteout: strings.HasPrefix(path.Base(name), whiteoutPrefix),
+		}
+		if h.Typeflag == tar.TypeSymlink || h.Typeflag == tar.TypeLink {
+			f.Link = h.Linkname
+		}
+		files = append(files, f)
+	}
+}
+
+// underDir the path is the directory itself or inside it, empty directory is the root.
+func underDir(p, dir string) bool {
+	return dir == "" || p == dir || strings.HasPrefix(p, dir+"/")
+}
+
+// MergeLayers effective file system of the image from the listings of its layers, the base layer first.
+// The entries of the upper layers replace the ones of the lower layers, whiteouts delete the files or
+// directories they name from the lower layers and opaque whiteouts hide the whole directory contents.
+// The whiteouts themselves are not shown.
+func MergeLayers(layers [][]LayerFile) []LayerFile {
+	var merged []LayerFile
+	for n, files := range layers {
+		// Whiteouts apply to the lower layers only, not to the entries of the same layer.
+		var deleted, opaque []string
+		for _, f := range files {
+			if !f.Whiteout {
+				continue
+			}
+			dir, name := path.Split(f.Path)
+			dir = strings.TrimSuffix(dir, "/")
+			if name == opaqueWhiteout {
+				opaque = append(opaque, dir)
+			} else {
+				deleted = append(deleted, path.Join(dir, strings.TrimPrefix(name, whiteoutPrefix)))
+			}
+		}
+		own := map[string]bool{}
+		for _, f := range files {
+			if !f.Whiteout {
+				own[f.Path] = true
+			}
+		}
+		kept := merged[:0]
+		for _, f := range merged {
+			// The entry of the upper layer replaces the lower one, the contents of directories are merged.
+			hidden := own[f.Path]
+			for _, d := range deleted {
+				hidden = hidden || underDir(f.Path, d)
+			}
+			for _, d := range opaque {
+				hidden = hidden || f.Path != d && underDir(f.Path, d)
+			}
+			if !hidden {
+				kept = append(kept, f)
+			}
+		}
+		merged = kept
+		for _, f := range files {
+			if !f.Whiteout {
+				f.Layer = n + 1
+				merged = append(merged, f)
+			}
+		}
+	}
+	sort.SliceStable(merged, func(i, j int) bool { return merged[i].Path < merged[j].Path })
+	return merged
+}
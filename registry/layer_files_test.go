@@ -0,0 +1,107 @@
+package registry
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestListTar(t *testing.T) {
+	var plain bytes.Buffer
+	tw := tar.NewWriter(&plain)
+	tw.WriteHeader(&tar.Header{Name: "./", Typeflag: tar.TypeDir, Mode: 0755})
+	tw.WriteHeader(&tar.Header{Name: "./etc/", Typeflag: tar.TypeDir, Mode: 0755})
+	tw.WriteHeader(&tar.Header{Name: "./etc/motd", Typeflag: tar.TypeReg, Mode: 0644, Size: 5})
+	tw.Write([]byte("hello"))
+	tw.WriteHeader(&tar.Header{Name: "bin/sh", Typeflag: tar.TypeSymlink, Mode: 0777, Linkname: "/bin/busybox"})
+	tw.WriteHeader(&tar.Header{Name: "tmp/.wh.cache", Typeflag: tar.TypeReg, Mode: 0600})
+	tw.Close()
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	gz.Write(plain.Bytes())
+	gz.Close()
+
+	convey.Convey("Read text files of the layer", t, func() {
+		var layer bytes.Buffer
+		tw := tar.NewWriter(&layer)
+		for name, content := range map[string]string{"app/.env": "TOKEN=abc", "app/bin": "\x7fELF\x00\x01", "app/big.txt": "0123456789"} {
+			tw.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(content))})
+			tw.Write([]byte(content))
+		}
+		tw.Close()
+		files := map[string]string{}
+		err := readTarText(bytes.NewReader(layer.Bytes()), 9, func(path string, data []byte) {
+			files[path] = string(data)
+		})
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(files, convey.ShouldResemble, map[string]string{"app/.env": "TOKEN=abc"})
+	})
+
+	convey.Convey("List entries of gzip compressed and plain layers", t, func() {
+		for _, data := range [][]byte{compressed.Bytes(), plain.Bytes()} {
+			files, truncated, err := listTar(bytes.NewReader(data), 100)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(truncated, convey.ShouldBeFalse)
+			convey.So(files, convey.ShouldResemble, []LayerFile{
+				{Path: "etc", Mode: "drwxr-xr-x", Dir: true},
+				{Path: "etc/motd", Size: 5, Mode: "-rw-r--r--"},
+				{Path: "bin/sh", Mode: "Lrwxrwxrwx", Link: "/bin/busybox"},
+				{Path: "tmp/.wh.cache", Mode: "-rw-------", Whiteout: true},
+			})
+		}
+	})
+
+	convey.Convey("Stop at the entries limit", t, func() {
+		files, truncated, err := listTar(bytes.NewReader(compressed.Bytes()), 2)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(truncated, convey.ShouldBeTrue)
+		convey.So(files, convey.ShouldHaveLength, 2)
+	})
+
+	convey.Convey("Reject layers which are not tarballs", t, func() {
+		_, _, err := listTar(bytes.NewReader([]byte{0x28, 0xb5, 0x2f, 0xfd, 0}), 100)
+		convey.So(errors.Is(err, ErrUnsupportedLayer), convey.ShouldBeTrue)
+		_, _, err = listTar(bytes.NewReader([]byte("alpine:3.12 layer 0 of 100 bytes")), 100)
+		convey.So(errors.Is(err, ErrUnsupportedLayer), convey.ShouldBeTrue)
+	})
+}
+
+func TestMergeLayers(t *testing.T) {
+	convey.Convey("Merge layers applying whiteouts", t, func() {
+		base := []LayerFile{
+			{Path: "etc", Dir: true, Mode: "drwxr-xr-x"},
+			{Path: "etc/motd", Size: 5},
+			{Path: "etc/passwd", Size: 10},
+			{Path: "var", Dir: true},
+			{Path: "var/cache", Dir: true},
+			{Path: "var/cache/apk.tar", Size: 100},
+			{Path: "opt/app", Dir: true},
+			{Path: "opt/app/old.jar", Size: 50},
+		}
+		upper := []LayerFile{
+			{Path: "etc", Dir: true, Mode: "drwx------"},
+			{Path: "etc/motd", Size: 7},
+			{Path: "etc/.wh.passwd", Whiteout: true},
+			{Path: "var/.wh.cache", Whiteout: true},
+			{Path: "opt/app", Dir: true},
+			{Path: "opt/app/.wh..wh..opq", Whiteout: true},
+			{Path: "opt/app/new.jar", Size: 60},
+			// Whiteouts do not apply to the entries of the same layer.
+			{Path: "tmp/x", Size: 1},
+			{Path: "tmp/.wh.x", Whiteout: true},
+		}
+		convey.So(MergeLayers([][]LayerFile{base, upper}), convey.ShouldResemble, []LayerFile{
+			{Path: "etc", Dir: true, Mode: "drwx------", Layer: 2},
+			{Path: "etc/motd", Size: 7, Layer: 2},
+			{Path: "opt/app", Dir: true, Layer: 2},
+			{Path: "opt/app/new.jar", Size: 60, Layer: 2},
+			{Path: "tmp/x", Size: 1, Layer: 2},
+			{Path: "var", Dir: true, Layer: 1},
+		})
+		convey.So(MergeLayers(nil), convey.ShouldBeEmpty)
+	})
+}
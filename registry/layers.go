@@ -0,0 +1,69 @@
+package registry
+
+import (
+	"strings"
+
+	"github.com/tidwall/gjson"
+)
+
+// Layer compression types.
+const (
+	CompressionNone        = "none"
+	CompressionGzip        = "gzip"
+	CompressionZstd        = "zstd"
+	CompressionEStargz     = "estargz"
+	CompressionZstdChunked = "zstd:chunked"
+	CompressionUnknown     = "unknown"
+)
+
+// Layer blob of the image manifest.
+type Layer struct {
+	Digest      string
+	Size        int64
+	MediaType   string
+	Compression string
+	// Foreign layers (Windows base layers) are not distributable, they are pulled from the URLs instead.
+	Foreign bool
+	URLs    []string
+}
+
+// Layers parse layers of image manifest v2 schema 2 or OCI image manifest.
+func Layers(manifest string) []Layer {
+	var layers []Layer
+	for _, l := range gjson.Get(manifest, "layers").Array() {
+		mediaType := l.Get("mediaType").String()
+		layer := Layer{
+			Digest:      l.Get("digest").String(),
+			Size:        l.Get("size").Int(),
+			MediaType:   mediaType,
+			Compression: LayerCompression(l),
+			Foreign:     strings.Contains(mediaType, ".foreign.") || strings.Contains(mediaType, ".nondistributable."),
+		}
+		for _, u := range l.Get("urls").Array() {
+			layer.URLs = append(layer.URLs, u.String())
+		}
+		layers = append(layers, layer)
+	}
+	return layers
+}
+
+// LayerCompression detect compression of the layer by its media type and annotations.
+// Seekable variants (estargz, zstd:chunked) are recognized by the annotations as their media type is a regular one.
+func LayerCompression(layer gjson.Result) string {
+	mediaType := layer.Get("mediaType").String()
+	switch {
+	case strings.HasSuffix(mediaType, "+zstd"):
+		if layer.Get(`annotations.io\.github\.containers\.zstd-chunked\.manifest-checksum`).Exists() {
+			return CompressionZstdChunked
+		}
+		return CompressionZstd
+	case strings.HasSuffix(mediaType, "+gzip") || strings.HasSuffix(mediaType, ".tar.gzip"):
+		if layer.Get(`annotations.containerd\.io/snapshot/stargz/toc\.digest`).Exists() {
+			return CompressionEStargz
+		}
+		return CompressionGzip
+	case strings.HasSuffix(mediaType, ".tar") || strings.HasSuffix(mediaType, ".diff.tar"):
+		return CompressionNone
+	}
+	return CompressionUnknown
+}
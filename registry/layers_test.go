@@ -0,0 +1,51 @@
+package registry
+
+import (
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+	"github.com/tidwall/gjson"
+)
+
+func TestLayerCompression(t *testing.T) {
+	convey.Convey("Detect layer compression", t, func() {
+		input := map[string]string{
+			`{"mediaType": "application/vnd.docker.image.rootfs.diff.tar.gzip"}`:            CompressionGzip,
+			`{"mediaType": "application/vnd.docker.image.rootfs.foreign.diff.tar.gzip"}`:    CompressionGzip,
+			`{"mediaType": "application/vnd.oci.image.layer.v1.tar+gzip"}`:                  CompressionGzip,
+			`{"mediaType": "application/vnd.oci.image.layer.v1.tar+zstd"}`:                  CompressionZstd,
+			`{"mediaType": "application/vnd.oci.image.layer.nondistributable.v1.tar+zstd"}`: CompressionZstd,
+			`{"mediaType": "application/vnd.oci.image.layer.v1.tar"}`:                       CompressionNone,
+			`{"mediaType": "application/vnd.in-toto+json"}`:                                 CompressionUnknown,
+			`{"mediaType": "application/vnd.oci.image.layer.v1.tar+gzip",
+			  "annotations": {"containerd.io/snapshot/stargz/toc.digest": "sha256:abc"}}`: CompressionEStargz,
+			`{"mediaType": "application/vnd.oci.image.layer.v1.tar+zstd",
+			  "annotations": {"io.github.containers.zstd-chunked.manifest-checksum": "sha256:abc"}}`: CompressionZstdChunked,
+		}
+		for layer, compression := range input {
+			convey.So(LayerCompression(gjson.Parse(layer)), convey.ShouldEqual, compression)
+		}
+	})
+}
+
+func TestForeignLayers(t *testing.T) {
+	manifest := `{"layers": [
+		{"mediaType": "application/vnd.docker.image.rootfs.foreign.diff.tar.gzip", "size": 100, "digest": "sha256:aaa",
+		 "urls": ["https://mcr.microsoft.com/v2/windows/servercore/blobs/sha256:aaa"]},
+		{"mediaType": "application/vnd.docker.image.rootfs.diff.tar.gzip", "size": 10, "digest": "sha256:bbb"}
+	]}`
+	convey.Convey("Detect foreign layers", t, func() {
+		layers := Layers(manifest)
+		convey.So(len(layers), convey.ShouldEqual, 2)
+		convey.So(layers[0].Foreign, convey.ShouldBeTrue)
+		convey.So(layers[0].URLs, convey.ShouldResemble, []string{"https://mcr.microsoft.com/v2/windows/servercore/blobs/sha256:aaa"})
+		convey.So(layers[1].Foreign, convey.ShouldBeFalse)
+	})
+	convey.Convey("Format platform", t, func() {
+		convey.So(PlatformString(gjson.Parse(`{"os": "linux", "architecture": "arm64", "variant": "v8"}`)), convey.ShouldEqual, "linux/arm64/v8")
+		windows := gjson.Parse(`{"os": "windows", "architecture": "amd64", "os.version": "10.0.17763.1817"}`)
+		convey.So(PlatformString(windows), convey.ShouldEqual, "windows/amd64")
+		convey.So(PlatformLabel(windows), convey.ShouldEqual, "windows/amd64 (10.0.17763.1817)")
+		convey.So(ParseImageConfig(windows.Raw).PlatformLabel(), convey.ShouldEqual, "windows/amd64 (10.0.17763.1817)")
+	})
+}
@@ -0,0 +1,29 @@
+package registry
+
+// requestLimiter slots of the requests to registry running at once.
+type requestLimiter chan struct{}
+
+// SetMaxConcurrentRequests limit how many requests are sent to registry at once, the others wait for a free slot,
+// e.g. not to overwhelm a small registry by UI users and background tasks together. 0 means no limit.
+// Only the request and its response headers hold the slot, the streamed blobs are read without it.
+func (c *Client) SetMaxConcurrentRequests(n int) {
+	var l requestLimiter
+	if n > 0 {
+		l = make(requestLimiter, n)
+	}
+	c.limiterMux.Lock()
+	c.limiter = l
+	c.limiterMux.Unlock()
+}
+
+// acquire wait for a free request slot, returns the function releasing it.
+func (c *Client) acquire() func() {
+	c.limiterMux.RLock()
+	l := c.limiter
+	c.limiterMux.RUnlock()
+	if l == nil {
+		return func() {}
+	}
+	l <- struct{}{}
+	return func() { <-l }
+}
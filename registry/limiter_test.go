@@ -0,0 +1,61 @@
+package registry
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestMaxConcurrentRequests(t *testing.T) {
+	var mux sync.Mutex
+	running, maxRunning := 0, 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mux.Lock()
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		mux.Unlock()
+		time.Sleep(20 * time.Millisecond)
+		mux.Lock()
+		running--
+		mux.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	c := NewClient(server.URL, true, "", "")
+	burst := func() int {
+		mux.Lock()
+		maxRunning = 0
+		mux.Unlock()
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				c.GetManifest("alpine", "latest")
+			}()
+		}
+		wg.Wait()
+		mux.Lock()
+		defer mux.Unlock()
+		return maxRunning
+	}
+
+	convey.Convey("Requests wait for a free slot", t, func() {
+		c.SetMaxConcurrentRequests(2)
+		convey.So(burst(), convey.ShouldEqual, 2)
+		c.SetMaxConcurrentRequests(1)
+		convey.So(burst(), convey.ShouldEqual, 1)
+	})
+
+	convey.Convey("No limit by default", t, func() {
+		c.SetMaxConcurrentRequests(0)
+		convey.So(burst(), convey.ShouldBeGreaterThan, 2)
+	})
+}
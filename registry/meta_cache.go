@@ -0,0 +1,152 @@
+package registry
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// metaEntryOverhead approximate bytes of the cached entry besides its strings: the struct, map and list elements.
+const metaEntryOverhead = 400
+
+// CacheLimits bounds of the tag metadata cache, the least recently used tags are evicted over them. 0 means no limit.
+type CacheLimits struct {
+	MaxRepos       int
+	MaxTagsPerRepo int
+	MaxEntries     int
+}
+
+// CacheStats size of the tag metadata cache.
+type CacheStats struct {
+	Entries int
+	Repos   int
+	// Bytes rough estimate of the memory taken by the entries.
+	Bytes   int64
+	Evicted int
+	Limits  CacheLimits
+}
+
+type tagMetaCache struct {
+	mux     sync.Mutex
+	items   map[string]*metaEntry
+	repos   map[string]*list.List
+	lru     *list.List
+	ttl     time.Duration
+	limits  CacheLimits
+	bytes   int64
+	evicted int
+}
+
+// metaEntry cached tag metadata linked in the list of all entries and in the list of its repo, the latest used first.
+type metaEntry struct {
+	key    string
+	meta   TagMeta
+	bytes  int64
+	all    *list.Element
+	inRepo *list.Element
+}
+
+// metaSize rough estimate of the memory taken by the tag metadata.
+func metaSize(m TagMeta) int64 {
+	size := metaEntryOverhead + len(m.Repo)*2 + len(m.Tag)*2 + len(m.Digest) + len(m.MediaType)
+	for _, p := range m.Platforms {
+		size += len(p) + 16
+	}
+	for _, p := range m.PlatformLabels {
+		size += len(p) + 16
+	}
+	for _, p := range m.Ports {
+		size += len(p) + 16
+	}
+	for k, v := range m.Labels {
+		size += len(k) + len(v) + 32
+	}
+	for k, v := range m.Annotations {
+		size += len(k) + len(v) + 32
+	}
+	return int64(size)
+}
+
+// get cached metadata of the "repo:tag" key marking it as recently used.
+func (m *tagMetaCache) get(key string) (TagMeta, bool) {
+	e, ok := m.items[key]
+	if !ok {
+		return TagMeta{}, false
+	}
+	m.lru.MoveToFront(e.all)
+	m.repos[e.meta.Repo].MoveToFront(e.inRepo)
+	return e.meta, true
+}
+
+// put cache the metadata and evict the least recently used entries over the limits.
+func (m *tagMetaCache) put(meta TagMeta) {
+	if m.items == nil {
+		m.items = map[string]*metaEntry{}
+		m.repos = map[string]*list.List{}
+		m.lru = list.New()
+	}
+	key := meta.Repo + ":" + meta.Tag
+	m.remove(key)
+	e := &metaEntry{key: key, meta: meta, bytes: metaSize(meta)}
+	repo, ok := m.repos[meta.Repo]
+	if !ok {
+		repo = list.New()
+		m.repos[meta.Repo] = repo
+	}
+	e.all = m.lru.PushFront(e)
+	e.inRepo = repo.PushFront(e)
+	m.items[key] = e
+	m.bytes += e.bytes
+
+	if m.limits.MaxTagsPerRepo > 0 {
+		for repo.Len() > m.limits.MaxTagsPerRepo {
+			m.evict(repo.Back().Value.(*metaEntry))
+		}
+	}
+	for (m.limits.MaxEntries > 0 && len(m.items) > m.limits.MaxEntries) ||
+		(m.limits.MaxRepos > 0 && len(m.repos) > m.limits.MaxRepos) {
+		m.evict(m.lru.Back().Value.(*metaEntry))
+	}
+}
+
+// evict drop the entry to make room.
+func (m *tagMetaCache) evict(e *metaEntry) {
+	m.remove(e.key)
+	m.evicted++
+}
+
+// remove drop the entry of the "repo:tag" key if cached.
+func (m *tagMetaCache) remove(key string) {
+	e, ok := m.items[key]
+	if !ok {
+		return
+	}
+	m.lru.Remove(e.all)
+	repo := m.repos[e.meta.Repo]
+	repo.Remove(e.inRepo)
+	if repo.Len() == 0 {
+		delete(m.repos, e.meta.Repo)
+	}
+	delete(m.items, key)
+	m.bytes -= e.bytes
+}
+
+// SetCacheLimits bound the tag metadata cache, the entries over the new limits are evicted with the next put.
+func (c *Client) SetCacheLimits(limits CacheLimits) {
+	c.meta.mux.Lock()
+	c.meta.limits = limits
+	c.meta.mux.Unlock()
+}
+
+// MetadataCacheStats size of the tag metadata cache.
+func (c *Client) MetadataCacheStats() CacheStats {
+	c.meta.mux.Lock()
+	defer c.meta.mux.Unlock()
+	return CacheStats{
+		Entries: len(c.meta.items),
+		Repos:   len(c.meta.repos),
+		Bytes:   c.meta.bytes,
+		Evicted: c.meta.evicted,
+		Limits:  c.meta.limits,
+	}
+}
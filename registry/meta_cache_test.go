@@ -0,0 +1,50 @@
+package registry
+
+import (
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestTagMetaCache(t *testing.T) {
+	cached := func(m *tagMetaCache) []string {
+		var keys []string
+		for e := m.lru.Front(); e != nil; e = e.Next() {
+			keys = append(keys, e.Value.(*metaEntry).key)
+		}
+		return keys
+	}
+
+	convey.Convey("Evict the least recently used tags over the limits", t, func() {
+		m := &tagMetaCache{limits: CacheLimits{MaxTagsPerRepo: 2}}
+		m.put(TagMeta{Repo: "alpine", Tag: "1"})
+		m.put(TagMeta{Repo: "alpine", Tag: "2"})
+		m.get("alpine:1")
+		m.put(TagMeta{Repo: "alpine", Tag: "3"})
+		convey.So(cached(m), convey.ShouldResemble, []string{"alpine:3", "alpine:1"})
+
+		m.limits = CacheLimits{MaxRepos: 2, MaxEntries: 3}
+		m.put(TagMeta{Repo: "busybox", Tag: "1"})
+		m.put(TagMeta{Repo: "busybox", Tag: "2"})
+		convey.So(cached(m), convey.ShouldResemble, []string{"busybox:2", "busybox:1", "alpine:3"})
+		m.get("alpine:3")
+		m.put(TagMeta{Repo: "team/app", Tag: "1"})
+		convey.So(cached(m), convey.ShouldResemble, []string{"team/app:1", "alpine:3"})
+		convey.So(m.evicted, convey.ShouldEqual, 4)
+		convey.So(m.repos, convey.ShouldHaveLength, 2)
+	})
+
+	convey.Convey("Track the estimated memory", t, func() {
+		m := &tagMetaCache{}
+		meta := TagMeta{Repo: "alpine", Tag: "latest", Labels: map[string]string{"maintainer": "team"}}
+		m.put(meta)
+		m.put(meta)
+		convey.So(m.bytes, convey.ShouldEqual, metaSize(meta))
+		convey.So(m.bytes, convey.ShouldBeGreaterThan, metaEntryOverhead)
+		m.remove("alpine:latest")
+		m.remove("alpine:missing")
+		convey.So(m.bytes, convey.ShouldEqual, 0)
+		convey.So(m.items, convey.ShouldBeEmpty)
+		convey.So(m.repos, convey.ShouldBeEmpty)
+	})
+}
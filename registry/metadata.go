@@ -0,0 +1,284 @@
+package registry
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tidwall/gjson"
+)
+
+// metaWorkers how many tags to fetch metadata for concurrently.
+const metaWorkers = 5
+
+// TagMeta image metadata of the tag.
+type TagMeta struct {
+	Repo      string
+	Tag       string
+	Digest    string
+	MediaType string
+	Created   time.Time
+	Size      int64
+	// Platforms os/arch/variant of the images, PlatformLabels the same with OS version to show.
+	Platforms      []string
+	PlatformLabels []string
+	// Labels from image config, for multi-arch images the labels of all sub-images are merged.
+	Labels      map[string]string
+	Annotations map[string]string
+	// Ports exposed by image config, e.g. 8080/tcp, merged for multi-arch images too.
+	Ports   []string
+	fetched time.Time
+}
+
+// AgeDays how many days ago the image was created.
+func (m TagMeta) AgeDays() int {
+	if m.Created.IsZero() {
+		return -1
+	}
+	return int(time.Now().Sub(m.Created).Hours() / 24)
+}
+
+// ShortDigest first 12 hex digits of the digest as shown by docker.
+func (m TagMeta) ShortDigest() string {
+	d := strings.TrimPrefix(m.Digest, "sha256:")
+	if len(d) > 12 {
+		return d[:12]
+	}
+	return d
+}
+
+// GetBlob get blob content, suitable for small blobs like image config.
+func (c *Client) GetBlob(repo, digest string) (string, error) {
+	scope := fmt.Sprintf("repository:%s:*", repo)
+	resp, err := c.do("GET", fmt.Sprintf("/v2/%s/blobs/%s", repo, digest), scope, nil, nil)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return "", fmt.Errorf("cannot get blob %s from %s: %s", digest, repo, resp.Status)
+	}
+	data, err := ioutil.ReadAll(resp.Body)
+	return string(data), err
+}
+
+// TagMetadata get image metadata of the tag from cache or registry.
+func (c *Client) TagMetadata(repo, tag string) (TagMeta, error) {
+	c.meta.mux.Lock()
+	ttl := c.meta.ttl
+	c.meta.mux.Unlock()
+	return c.tagMetadata(repo, tag, ttl)
+}
+
+// tagMetadata get image metadata of the tag from cache if it is not older than maxAge or from registry.
+// Concurrent fetches of the same tag, e.g. by page loads and the prefetch, share one set of requests.
+func (c *Client) tagMetadata(repo, tag string, maxAge time.Duration) (TagMeta, error) {
+	key := repo + ":" + tag
+	c.meta.mux.Lock()
+	meta, ok := c.meta.get(key)
+	c.meta.mux.Unlock()
+	if ok && time.Now().Sub(meta.fetched) < maxAge {
+		return meta, nil
+	}
+
+	v, err, _ := c.flights.do("meta:"+key, func() (interface{}, error) {
+		meta, err := c.fetchTagMetadata(repo, tag)
+		if err != nil {
+			return meta, err
+		}
+		meta.fetched = time.Now()
+		c.meta.mux.Lock()
+		c.meta.put(meta)
+		c.meta.mux.Unlock()
+		return meta, nil
+	})
+	meta, ok = v.(TagMeta)
+	if !ok {
+		// The shared fetch panicked.
+		return TagMeta{Repo: repo, Tag: tag}, fmt.Errorf("cannot get metadata of %s", key)
+	}
+	return meta, err
+}
+
+// TagsMetadata get metadata for multiple tags of the repo concurrently, the order of tags is preserved.
+// Tags the metadata could not be fetched for have only the name set.
+func (c *Client) TagsMetadata(repo string, tags []string) []TagMeta {
+	c.meta.mux.Lock()
+	ttl := c.meta.ttl
+	c.meta.mux.Unlock()
+	return c.tagsMetadata(repo, tags, ttl)
+}
+
+// tagsMetadata get metadata for multiple tags refreshing the cached items older than maxAge.
+func (c *Client) tagsMetadata(repo string, tags []string, maxAge time.Duration) []TagMeta {
+	// The extended API gives metadata of all tags at once, it is used when any tag is missing in cache.
+	c.meta.mux.Lock()
+	stale := false
+	for _, tag := range tags {
+		if e, ok := c.meta.items[repo+":"+tag]; !ok || time.Now().Sub(e.meta.fetched) >= maxAge {
+			stale = true
+			break
+		}
+	}
+	c.meta.mux.Unlock()
+	if stale {
+		c.prefetchExtTagsMetadata(repo)
+	}
+
+	list := make([]TagMeta, len(tags))
+	queue := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < metaWorkers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range queue {
+				meta, err := c.tagMetadata(repo, tags[i], maxAge)
+				if err != nil {
+					c.logger.Warnf("Cannot get metadata of %s:%s: %s", repo, tags[i], err)
+					meta = TagMeta{Repo: repo, Tag: tags[i]}
+				}
+				list[i] = meta
+			}
+		}()
+	}
+	for i := range tags {
+		queue <- i
+	}
+	close(queue)
+	wg.Wait()
+	return list
+}
+
+// forgetTagMetadata drop cached metadata of all tags in the repo with the digest.
+func (c *Client) forgetTagMetadata(repo, digest string) {
+	c.meta.mux.Lock()
+	defer c.meta.mux.Unlock()
+	for k, e := range c.meta.items {
+		if e.meta.Repo == repo && e.meta.Digest == digest {
+			c.meta.remove(k)
+		}
+	}
+}
+
+// fetchTagMetadata get image metadata of the tag from registry.
+func (c *Client) fetchTagMetadata(repo, tag string) (TagMeta, error) {
+	meta := TagMeta{Repo: repo, Tag: tag, Labels: map[string]string{}, Annotations: map[string]string{}}
+	manifest, mediaType, digest, err := c.GetManifest(repo, tag)
+	if err != nil {
+		return meta, err
+	}
+	meta.Digest = digest
+	meta.MediaType = mediaType
+	for k, v := range gjson.Get(manifest, "annotations").Map() {
+		meta.Annotations[k] = v.String()
+	}
+
+	switch mediaType {
+	case MediaTypeManifestList, MediaTypeOCIIndex:
+		for _, m := range gjson.Get(manifest, "manifests").Array() {
+			platform := PlatformString(m.Get("platform"))
+			if platform == "unknown/unknown" {
+				// Attestation manifests are not images.
+				continue
+			}
+			sub, err := c.fetchTagMetadata(repo, m.Get("digest").String())
+			if err != nil {
+				return meta, err
+			}
+			meta.Size = meta.Size + sub.Size
+			if sub.Created.After(meta.Created) {
+				meta.Created = sub.Created
+			}
+			meta.Platforms = append(meta.Platforms, platform)
+			meta.PlatformLabels = append(meta.PlatformLabels, PlatformLabel(m.Get("platform")))
+			for k, v := range sub.Labels {
+				meta.Labels[k] = v
+			}
+			meta.Ports = mergePorts(meta.Ports, sub.Ports)
+		}
+	case MediaTypeManifestV2, MediaTypeOCIManifest:
+		for _, s := range gjson.Get(manifest, "layers.#.size").Array() {
+			meta.Size = meta.Size + s.Int()
+		}
+		config, err := c.ImageConfig(repo, gjson.Get(manifest, "config.digest").String())
+		if err != nil {
+			return meta, err
+		}
+		meta.Created = config.Created
+		for k, v := range config.Labels {
+			meta.Labels[k] = v
+		}
+		meta.Ports = config.Ports
+		if config.Platform != "" {
+			meta.Platforms = []string{config.Platform}
+			meta.PlatformLabels = []string{config.PlatformLabel()}
+		}
+	default:
+		// Manifest v2 schema 1.
+		meta.Created = gjson.Get(gjson.Get(manifest, "history.0.v1Compatibility").String(), "created").Time()
+		for _, s := range gjson.Get(manifest, "history.#.v1Compatibility").Array() {
+			meta.Size = meta.Size + gjson.Get(s.String(), "Size").Int()
+		}
+	}
+	return meta, nil
+}
+
+// ExposedPorts sorted ports exposed by image config, the protocol is always set, e.g. 53/udp.
+func ExposedPorts(config gjson.Result) []string {
+	var ports []string
+	for p := range config.Get("ExposedPorts").Map() {
+		if !strings.Contains(p, "/") {
+			p = p + "/tcp"
+		}
+		ports = append(ports, p)
+	}
+	return mergePorts(nil, ports)
+}
+
+// mergePorts sorted union of the ports.
+func mergePorts(a, b []string) []string {
+	seen := map[string]bool{}
+	var ports []string
+	for _, p := range append(append([]string{}, a...), b...) {
+		if !seen[p] {
+			seen[p] = true
+			ports = append(ports, p)
+		}
+	}
+	sort.Slice(ports, func(i, j int) bool {
+		var pi, pj int
+		fmt.Sscanf(ports[i], "%d", &pi)
+		fmt.Sscanf(ports[j], "%d", &pj)
+		if pi != pj {
+			return pi < pj
+		}
+		return ports[i] < ports[j]
+	})
+	return ports
+}
+
+// PlatformString format platform as os/arch/variant, the images of the same platform are matched by it.
+func PlatformString(platform gjson.Result) string {
+	s := platform.Get("os").String() + "/" + platform.Get("architecture").String()
+	if v := platform.Get("variant").String(); v != "" {
+		s = s + "/" + v
+	}
+	return s
+}
+
+// PlatformLabel platform to show, OS version is added when known, e.g. for Windows.
+// It changes with every Windows update, so it is not used for matching.
+func PlatformLabel(platform gjson.Result) string {
+	return platformLabel(PlatformString(platform), platform.Get(`os\.version`).String())
+}
+
+func platformLabel(platform, osVersion string) string {
+	if osVersion == "" {
+		return platform
+	}
+	return platform + " (" + osVersion + ")"
+}
@@ -0,0 +1,368 @@
+package registry
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v2"
+)
+
+// MockImage image of the mock registry fixture, multi-arch images have platforms set instead of layers.
+type MockImage struct {
+	Created      string            `yaml:"created"`
+	OS           string            `yaml:"os"`
+	Architecture string            `yaml:"architecture"`
+	Variant      string            `yaml:"variant"`
+	Layers       []int64           `yaml:"layers"`
+	Labels       map[string]string `yaml:"labels"`
+	Ports        []string          `yaml:"ports"`
+	Platforms    []MockImage       `yaml:"platforms"`
+	// Provenance attached as BuildKit attestation manifests, the image is pushed as an index then.
+	Provenance *MockProvenance `yaml:"provenance"`
+}
+
+// MockProvenance SLSA provenance of the mock image.
+type MockProvenance struct {
+	Builder string `yaml:"builder"`
+	Source  string `yaml:"source"`
+	Commit  string `yaml:"commit"`
+}
+
+// mockFixture repositories with tags and their images.
+type mockFixture struct {
+	Repositories map[string]map[string]MockImage `yaml:"repositories"`
+}
+
+type mockManifest struct {
+	mediaType string
+	body      []byte
+}
+
+var (
+	mockUploadRegexp = regexp.MustCompile(`^/v2/(.+)/blobs/uploads/(.*)$`)
+	mockPathRegexp   = regexp.MustCompile(`^/v2/(.+)/(manifests|blobs|tags)/(.+)$`)
+)
+
+// MockRegistry in-memory registry for demos and tests, it supports reads, pushing manifests, blob uploads and deletion.
+type MockRegistry struct {
+	mux       sync.Mutex
+	blobs     map[string][]byte
+	manifests map[string]map[string]mockManifest
+	tags      map[string][]string
+	uploads   map[string][]byte
+	lastID    int
+	logger    *logrus.Entry
+}
+
+// NewMockRegistry create mock registry seeded with images from the fixture file.
+func NewMockRegistry(fixtureFile string) (*MockRegistry, error) {
+	m := &MockRegistry{
+		blobs:     map[string][]byte{},
+		manifests: map[string]map[string]mockManifest{},
+		tags:      map[string][]string{},
+		uploads:   map[string][]byte{},
+		logger:    SetupLogging("registry.mock"),
+	}
+	data, err := ioutil.ReadFile(fixtureFile)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read mock registry fixture: %s", err)
+	}
+	var fixture mockFixture
+	if err := yaml.Unmarshal(data, &fixture); err != nil {
+		return nil, fmt.Errorf("cannot parse mock registry fixture %s: %s", fixtureFile, err)
+	}
+	for repo, tags := range fixture.Repositories {
+		for tag, image := range tags {
+			m.addImage(repo, tag, image)
+		}
+	}
+	return m, nil
+}
+
+// Start serve the registry on a random local port, returns its URL.
+func (m *MockRegistry) Start() (string, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", err
+	}
+	go http.Serve(listener, m)
+	url := "http://" + listener.Addr().String()
+	m.logger.Infof("Mock registry is listening on %s", url)
+	return url, nil
+}
+
+// putManifest store manifest by digest and the tag if set, must be called under lock.
+func (m *MockRegistry) putManifest(repo, tag, mediaType string, body []byte) string {
+	digest := DigestOf(body)
+	if m.manifests[repo] == nil {
+		m.manifests[repo] = map[string]mockManifest{}
+	}
+	m.manifests[repo][digest] = mockManifest{mediaType, body}
+	if tag != "" && !strings.HasPrefix(tag, "sha256:") {
+		m.manifests[repo][tag] = m.manifests[repo][digest]
+		if !ItemInSlice(tag, m.tags[repo]) {
+			m.tags[repo] = append(m.tags[repo], tag)
+			sort.Strings(m.tags[repo])
+		}
+	}
+	return digest
+}
+
+// addImage create blobs and manifests of the fixture image.
+func (m *MockRegistry) addImage(repo, tag string, image MockImage) string {
+	if image.OS == "" {
+		image.OS = "linux"
+	}
+	if image.Architecture == "" {
+		image.Architecture = "amd64"
+	}
+	if len(image.Platforms) > 0 || image.Provenance != nil {
+		platforms := image.Platforms
+		if len(platforms) == 0 {
+			// BuildKit pushes a single-arch image with attestations as an index.
+			single := image
+			single.Provenance = nil
+			platforms = []MockImage{single}
+		}
+		var list []map[string]interface{}
+		for _, p := range platforms {
+			if p.Created == "" {
+				p.Created = image.Created
+			}
+			if p.OS == "" {
+				p.OS = "linux"
+			}
+			if p.Architecture == "" {
+				p.Architecture = "amd64"
+			}
+			digest := m.addImage(repo, "", p)
+			platform := map[string]string{"os": p.OS, "architecture": p.Architecture}
+			if p.Variant != "" {
+				platform["variant"] = p.Variant
+			}
+			list = append(list, map[string]interface{}{
+				"mediaType": MediaTypeManifestV2, "size": len(m.manifests[repo][digest].body), "digest": digest, "platform": platform,
+			})
+			if image.Provenance != nil {
+				list = append(list, m.addAttestation(repo, digest, p.Created, *image.Provenance))
+			}
+		}
+		mediaType := MediaTypeManifestList
+		if image.Provenance != nil {
+			mediaType = MediaTypeOCIIndex
+		}
+		body, _ := json.MarshalIndent(map[string]interface{}{"schemaVersion": 2, "mediaType": mediaType, "manifests": list}, "", "   ")
+		return m.putManifest(repo, tag, mediaType, body)
+	}
+
+	exposedPorts := map[string]struct{}{}
+	for _, p := range image.Ports {
+		exposedPorts[p] = struct{}{}
+	}
+	config, _ := json.Marshal(map[string]interface{}{
+		"architecture": image.Architecture, "os": image.OS, "variant": image.Variant, "created": image.Created,
+		"config":  map[string]interface{}{"Labels": image.Labels, "ExposedPorts": exposedPorts},
+		"history": []interface{}{map[string]string{"created": image.Created, "created_by": "/bin/sh -c #(nop) ADD file"}},
+	})
+	configDigest := DigestOf(config)
+	m.blobs[configDigest] = config
+	layers := []map[string]interface{}{}
+	for i, size := range image.Layers {
+		// Layer content is a placeholder, the size comes from the fixture.
+		b := []byte(fmt.Sprintf("%s:%s layer %d of %d bytes", repo, tag, i, size))
+		m.blobs[DigestOf(b)] = b
+		layers = append(layers, map[string]interface{}{"mediaType": "application/vnd.docker.image.rootfs.diff.tar.gzip", "size": size, "digest": DigestOf(b)})
+	}
+	body, _ := json.MarshalIndent(map[string]interface{}{
+		"schemaVersion": 2, "mediaType": MediaTypeManifestV2,
+		"config": map[string]interface{}{"mediaType": "application/vnd.docker.container.image.v1+json", "size": len(config), "digest": configDigest},
+		"layers": layers,
+	}, "", "   ")
+	return m.putManifest(repo, tag, MediaTypeManifestV2, body)
+}
+
+// addAttestation create BuildKit attestation manifest with SLSA provenance v0.2 of the image digest,
+// returns its descriptor for the index. Must be called under lock.
+func (m *MockRegistry) addAttestation(repo, digest, created string, p MockProvenance) map[string]interface{} {
+	statement, _ := json.Marshal(map[string]interface{}{
+		"_type":         "https://in-toto.io/Statement/v0.1",
+		"predicateType": "https://slsa.dev/provenance/v0.2",
+		"subject":       []interface{}{map[string]interface{}{"name": repo, "digest": map[string]string{"sha256": strings.TrimPrefix(digest, "sha256:")}}},
+		"predicate": map[string]interface{}{
+			"builder":   map[string]string{"id": p.Builder},
+			"buildType": "https://mobyproject.org/buildkit@v1",
+			"invocation": map[string]interface{}{
+				"configSource": map[string]interface{}{"uri": p.Source, "digest": map[string]string{"sha1": p.Commit}, "entryPoint": "Dockerfile"},
+				"parameters":   map[string]interface{}{"frontend": "dockerfile.v0"},
+			},
+			"metadata":  map[string]interface{}{"buildStartedOn": created, "buildFinishedOn": created},
+			"materials": []interface{}{map[string]interface{}{"uri": p.Source, "digest": map[string]string{"sha1": p.Commit}}},
+		},
+	})
+	m.blobs[DigestOf(statement)] = statement
+	config := []byte("{}")
+	m.blobs[DigestOf(config)] = config
+	body, _ := json.MarshalIndent(map[string]interface{}{
+		"schemaVersion": 2, "mediaType": MediaTypeOCIManifest,
+		"config": map[string]interface{}{"mediaType": "application/vnd.oci.image.config.v1+json", "size": len(config), "digest": DigestOf(config)},
+		"layers": []interface{}{map[string]interface{}{
+			"mediaType": MediaTypeInToto, "size": len(statement), "digest": DigestOf(statement),
+			"annotations": map[string]string{"in-toto.io/predicate-type": "https://slsa.dev/provenance/v0.2"},
+		}},
+	}, "", "   ")
+	attDigest := m.putManifest(repo, "", MediaTypeOCIManifest, body)
+	return map[string]interface{}{
+		"mediaType": MediaTypeOCIManifest, "size": len(body), "digest": attDigest,
+		"platform": map[string]string{"os": "unknown", "architecture": "unknown"},
+		"annotations": map[string]string{
+			"vnd.docker.reference.type": "attestation-manifest", "vnd.docker.reference.digest": digest,
+		},
+	}
+}
+
+// ServeHTTP handle registry API requests.
+func (m *MockRegistry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	m.logger.Debugf("%s %s", r.Method, r.URL)
+
+	switch {
+	case r.URL.Path == "/v2/":
+		return
+	case r.URL.Path == "/v2/_catalog":
+		repos := []string{}
+		for repo := range m.tags {
+			repos = append(repos, repo)
+		}
+		sort.Strings(repos)
+		json.NewEncoder(w).Encode(map[string]interface{}{"repositories": repos})
+		return
+	}
+
+	if u := mockUploadRegexp.FindStringSubmatch(r.URL.Path); u != nil {
+		m.serveUpload(w, r, u[1], u[2])
+		return
+	}
+	p := mockPathRegexp.FindStringSubmatch(r.URL.Path)
+	if p == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	repo, kind, ref := p[1], p[2], p[3]
+	switch kind {
+	case "tags":
+		if _, ok := m.tags[repo]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"name": repo, "tags": m.tagsPage(w, r, repo)})
+	case "blobs":
+		b, ok := m.blobs[ref]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(b)
+	case "manifests":
+		m.serveManifest(w, r, repo, ref)
+	}
+}
+
+// tagsPage tags following the "last" one limited by "n" if set, the link to the next page is added as the registry does.
+func (m *MockRegistry) tagsPage(w http.ResponseWriter, r *http.Request, repo string) []string {
+	tags := m.tags[repo]
+	if last := r.URL.Query().Get("last"); last != "" {
+		tags = tags[sort.SearchStrings(tags, last):]
+		if len(tags) > 0 && tags[0] == last {
+			tags = tags[1:]
+		}
+	}
+	if n, err := strconv.Atoi(r.URL.Query().Get("n")); err == nil && n > 0 && n < len(tags) {
+		tags = tags[:n]
+		w.Header().Set("Link", fmt.Sprintf(`</v2/%s/tags/list?last=%s&n=%d>; rel="next"`, repo, url.QueryEscape(tags[n-1]), n))
+	}
+	return tags
+}
+
+// serveUpload handle blob mount and upload.
+func (m *MockRegistry) serveUpload(w http.ResponseWriter, r *http.Request, repo, id string) {
+	switch r.Method {
+	case "POST":
+		if digest := r.URL.Query().Get("mount"); digest != "" && m.blobs[digest] != nil {
+			w.WriteHeader(http.StatusCreated)
+			return
+		}
+		m.lastID++
+		w.Header().Set("Location", fmt.Sprintf("/v2/%s/blobs/uploads/%d", repo, m.lastID))
+		w.WriteHeader(http.StatusAccepted)
+	case "PATCH":
+		b, _ := ioutil.ReadAll(r.Body)
+		m.uploads[id] = append(m.uploads[id], b...)
+		w.Header().Set("Location", fmt.Sprintf("/v2/%s/blobs/uploads/%s", repo, id))
+		w.WriteHeader(http.StatusAccepted)
+	case "PUT":
+		b, _ := ioutil.ReadAll(r.Body)
+		data := append(m.uploads[id], b...)
+		delete(m.uploads, id)
+		if DigestOf(data) != r.URL.Query().Get("digest") {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		m.blobs[DigestOf(data)] = data
+		w.WriteHeader(http.StatusCreated)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// serveManifest handle manifest get, push and delete.
+func (m *MockRegistry) serveManifest(w http.ResponseWriter, r *http.Request, repo, ref string) {
+	switch r.Method {
+	case "PUT":
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Header().Set("Docker-Content-Digest", m.putManifest(repo, ref, r.Header.Get("Content-Type"), body))
+		w.WriteHeader(http.StatusCreated)
+		return
+	case "DELETE":
+		manifest, ok := m.manifests[repo][ref]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		// Deleting by digest removes all the tags pointing to it.
+		digest := DigestOf(manifest.body)
+		tags := []string{}
+		for _, t := range m.tags[repo] {
+			if DigestOf(m.manifests[repo][t].body) == digest {
+				delete(m.manifests[repo], t)
+			} else {
+				tags = append(tags, t)
+			}
+		}
+		m.tags[repo] = tags
+		delete(m.manifests[repo], digest)
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	manifest, ok := m.manifests[repo][ref]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", manifest.mediaType)
+	w.Header().Set("Docker-Content-Digest", DigestOf(manifest.body))
+	if r.Method != "HEAD" {
+		w.Write(manifest.body)
+	}
+}
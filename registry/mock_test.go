@@ -0,0 +1,78 @@
+package registry
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestMockRegistry(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "mock")
+	defer os.RemoveAll(dir)
+	fixture := filepath.Join(dir, "fixture.yml")
+	ioutil.WriteFile(fixture, []byte(`
+repositories:
+  alpine:
+    "3.13": {created: 2021-04-14T19:19:39Z, layers: [100, 200], labels: {maintainer: team}, ports: [8080/tcp, 53/udp, "80"]}
+  team/multi:
+    "1.0":
+      created: 2021-01-01T00:00:00Z
+      platforms:
+        - {os: linux, architecture: amd64, layers: [10]}
+        - {os: linux, architecture: arm64, variant: v8, layers: [20]}
+`), 0600)
+
+	mock, err := NewMockRegistry(fixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	url, err := mock.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewClient(url, true, "", "")
+
+	convey.Convey("Browse the mock registry", t, func() {
+		convey.So(c.Repositories(false), convey.ShouldResemble, map[string][]string{"library": {"alpine"}, "team": {"multi"}})
+		convey.So(c.Tags("alpine"), convey.ShouldResemble, []string{"3.13"})
+
+		meta, err := c.TagMetadata("alpine", "3.13")
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(meta.Size, convey.ShouldEqual, 300)
+		convey.So(meta.Created.Year(), convey.ShouldEqual, 2021)
+		convey.So(meta.Labels, convey.ShouldResemble, map[string]string{"maintainer": "team"})
+		convey.So(meta.Ports, convey.ShouldResemble, []string{"53/udp", "80/tcp", "8080/tcp"})
+
+		meta, err = c.TagMetadata("team/multi", "1.0")
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(meta.MediaType, convey.ShouldEqual, MediaTypeManifestList)
+		convey.So(meta.Platforms, convey.ShouldResemble, []string{"linux/amd64", "linux/arm64/v8"})
+	})
+
+	convey.Convey("Copy and delete in the mock registry", t, func() {
+		convey.So(c.CopyTag("team/multi", "1.0", "other/multi", "2.0"), convey.ShouldBeNil)
+		convey.So(c.Tags("other/multi"), convey.ShouldResemble, []string{"2.0"})
+		meta, err := c.TagMetadata("other/multi", "2.0")
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(meta.Size, convey.ShouldEqual, 30)
+
+		c.SetDeletionGuard(func(repo, tag, digest string) error {
+			if repo == "team/multi" && digest != "" {
+				return fmt.Errorf("%w by prod", ErrTagInUse)
+			}
+			return nil
+		})
+		err = c.DeleteTag("team/multi", "1.0")
+		convey.So(errors.Is(err, ErrTagInUse), convey.ShouldBeTrue)
+		convey.So(c.Tags("team/multi"), convey.ShouldResemble, []string{"1.0"})
+
+		c.SetDeletionGuard(nil)
+		convey.So(c.DeleteTag("team/multi", "1.0"), convey.ShouldBeNil)
+		convey.So(c.Tags("team/multi"), convey.ShouldBeEmpty)
+	})
+}
@@ -0,0 +1,468 @@
+package registry
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/tidwall/gjson"
+)
+
+// OCI image layout files and the annotations naming the images in its index.
+const (
+	layoutVersionFile   = "oci-layout"
+	layoutIndexFile     = "index.json"
+	annotationRefName   = "org.opencontainers.image.ref.name"
+	annotationImageName = "io.containerd.image.name"
+)
+
+// LayoutImage image of the OCI image layout, Repo is empty when the layout names only the tag.
+type LayoutImage struct {
+	Repo      string
+	Tag       string
+	Digest    string
+	MediaType string
+}
+
+// String repo:tag of the image.
+func (i LayoutImage) String() string {
+	return i.Repo + ":" + i.Tag
+}
+
+// layoutDescriptor entry of the index of OCI image layout.
+type layoutDescriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// layoutWriter writes OCI image layout to tar, every blob once.
+type layoutWriter struct {
+	c       *Client
+	tw      *tar.Writer
+	written map[string]bool
+	// Size of the files written so far.
+	Size int64
+}
+
+// writeFile add the file to the tar.
+func (w *layoutWriter) writeFile(name string, size int64, r io.Reader) error {
+	if err := w.tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: size, Typeflag: tar.TypeReg}); err != nil {
+		return err
+	}
+	n, err := io.Copy(w.tw, r)
+	if err != nil {
+		return err
+	}
+	if n != size {
+		return fmt.Errorf("%s is %d bytes, expected %d", name, n, size)
+	}
+	w.Size += size
+	return nil
+}
+
+// blobPath path of the blob in the layout.
+func blobPath(digest string) string {
+	return "blobs/" + strings.Replace(digest, ":", "/", 1)
+}
+
+// writeBlob stream the blob from the registry unless written already.
+func (w *layoutWriter) writeBlob(repo, digest string, size int64) error {
+	if w.written[digest] {
+		return nil
+	}
+	scope := fmt.Sprintf("repository:%s:*", repo)
+	resp, err := w.c.do("GET", fmt.Sprintf("/v2/%s/blobs/%s", repo, digest), scope, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("cannot get blob %s from %s: %s", digest, repo, resp.Status)
+	}
+	if err := w.writeFile(blobPath(digest), size, resp.Body); err != nil {
+		return err
+	}
+	w.written[digest] = true
+	return nil
+}
+
+// writeManifest write the manifest with its config and layers, sub-images of manifest list too.
+func (w *layoutWriter) writeManifest(repo, reference string) (layoutDescriptor, error) {
+	manifest, mediaType, digest, err := w.c.GetManifest(repo, reference)
+	if err != nil {
+		return layoutDescriptor{}, err
+	}
+	switch mediaType {
+	case MediaTypeManifestList, MediaTypeOCIIndex:
+		for _, m := range gjson.Get(manifest, "manifests.#.digest").Array() {
+			if _, err := w.writeManifest(repo, m.String()); err != nil {
+				return layoutDescriptor{}, err
+			}
+		}
+	case MediaTypeManifestV2, MediaTypeOCIManifest:
+		config := gjson.Get(manifest, "config")
+		if err := w.writeBlob(repo, config.Get("digest").String(), config.Get("size").Int()); err != nil {
+			return layoutDescriptor{}, err
+		}
+		for _, l := range Layers(manifest) {
+			// Foreign layers are not in the registry, they are pulled from their URLs.
+			if l.Foreign {
+				continue
+			}
+			if err := w.writeBlob(repo, l.Digest, l.Size); err != nil {
+				return layoutDescriptor{}, err
+			}
+		}
+	default:
+		return layoutDescriptor{}, fmt.Errorf("cannot export %s:%s, unsupported manifest type %q", repo, reference, mediaType)
+	}
+	d := layoutDescriptor{MediaType: mediaType, Digest: digest, Size: int64(len(manifest))}
+	if !w.written[digest] {
+		if err := w.writeFile(blobPath(digest), d.Size, strings.NewReader(manifest)); err != nil {
+			return d, err
+		}
+		w.written[digest] = true
+	}
+	return d, nil
+}
+
+// ExportLayout write the images with all their manifests and blobs as OCI image layout tar, e.g. for transfer
+// into air-gapped registries. Blobs shared by the images are written once, the images are named by the index
+// annotations. The callback is called after every image. Returns the size of the files written.
+func (c *Client) ExportLayout(out io.Writer, images []LayoutImage, progress func(i int, image LayoutImage)) (int64, error) {
+	w := &layoutWriter{c: c, tw: tar.NewWriter(out), written: map[string]bool{}}
+	index := struct {
+		SchemaVersion int                `json:"schemaVersion"`
+		MediaType     string             `json:"mediaType"`
+		Manifests     []layoutDescriptor `json:"manifests"`
+	}{2, MediaTypeOCIIndex, []layoutDescriptor{}}
+	for i, image := range images {
+		d, err := w.writeManifest(image.Repo, image.Tag)
+		if err != nil {
+			return w.Size, err
+		}
+		d.Annotations = map[string]string{annotationRefName: image.Tag, annotationImageName: image.String()}
+		index.Manifests = append(index.Manifests, d)
+		if progress != nil {
+			progress(i, image)
+		}
+	}
+
+	version := `{"imageLayoutVersion":"1.0.0"}`
+	if err := w.writeFile(layoutVersionFile, int64(len(version)), strings.NewReader(version)); err != nil {
+		return w.Size, err
+	}
+	data, _ := json.Marshal(index)
+	if err := w.writeFile(layoutIndexFile, int64(len(data)), strings.NewReader(string(data))); err != nil {
+		return w.Size, err
+	}
+	return w.Size, w.tw.Close()
+}
+
+// Media types of the manifests made up for the images of docker save tarball.
+const (
+	mediaTypeOCIConfig      = "application/vnd.oci.image.config.v1+json"
+	mediaTypeOCILayer       = "application/vnd.oci.image.layer.v1.tar"
+	mediaTypeOCILayerGzip   = "application/vnd.oci.image.layer.v1.tar+gzip"
+	dockerSaveManifestFile  = "manifest.json"
+	dockerHubDomain         = "docker.io"
+	dockerHubOfficialPrefix = "library/"
+)
+
+// layoutEntry position of the file content in the tar.
+type layoutEntry struct {
+	offset int64
+	size   int64
+}
+
+// Layout OCI image layout or docker save tar opened for reading, the blobs are read from the file on demand.
+type Layout struct {
+	f       *os.File
+	entries map[string]layoutEntry
+	index   string
+	// Files of the blobs by digest and the manifests made up for the images of docker save tarball.
+	blobs     map[string]string
+	manifests map[string]string
+}
+
+// OpenLayout index the files of OCI image layout or docker save tar, it is read from the start.
+// The tarball of the older docker save has no OCI layout, its images are given OCI manifests.
+func OpenLayout(f *os.File) (*Layout, error) {
+	l := &Layout{f: f, entries: map[string]layoutEntry{}, blobs: map[string]string{}, manifests: map[string]string{}}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	tr := tar.NewReader(f)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("cannot read tar: %s", err)
+		}
+		if h.Typeflag != tar.TypeReg {
+			continue
+		}
+		// The tar reader stops at the start of the file content.
+		offset, err := f.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return nil, err
+		}
+		l.entries[strings.TrimPrefix(path.Clean(h.Name), "./")] = layoutEntry{offset: offset, size: h.Size}
+	}
+	if _, ok := l.entries[layoutVersionFile]; !ok {
+		if _, ok := l.entries[dockerSaveManifestFile]; ok {
+			return l, l.readDockerSave()
+		}
+		return nil, fmt.Errorf("neither OCI image layout nor docker save tarball, %s is missing", layoutVersionFile)
+	}
+	index, err := l.readFile(layoutIndexFile)
+	if err != nil {
+		return nil, err
+	}
+	l.index = index
+	return l, nil
+}
+
+// readDockerSave make up the index of the images of docker save tarball with OCI manifests of them.
+func (l *Layout) readDockerSave() error {
+	data, err := l.readFile(dockerSaveManifestFile)
+	if err != nil {
+		return err
+	}
+	var images []struct {
+		Config   string
+		RepoTags []string
+		Layers   []string
+	}
+	if err := json.Unmarshal([]byte(data), &images); err != nil {
+		return fmt.Errorf("cannot parse %s: %s", dockerSaveManifestFile, err)
+	}
+	index := struct {
+		SchemaVersion int                `json:"schemaVersion"`
+		Manifests     []layoutDescriptor `json:"manifests"`
+	}{2, []layoutDescriptor{}}
+	for _, image := range images {
+		config, err := l.addBlob(image.Config, mediaTypeOCIConfig)
+		if err != nil {
+			return err
+		}
+		manifest := struct {
+			SchemaVersion int                `json:"schemaVersion"`
+			MediaType     string             `json:"mediaType"`
+			Config        layoutDescriptor   `json:"config"`
+			Layers        []layoutDescriptor `json:"layers"`
+		}{2, MediaTypeOCIManifest, config, []layoutDescriptor{}}
+		for _, name := range image.Layers {
+			layer, err := l.addBlob(name, mediaTypeOCILayer)
+			if err != nil {
+				return err
+			}
+			manifest.Layers = append(manifest.Layers, layer)
+		}
+		body, _ := json.Marshal(manifest)
+		d := layoutDescriptor{MediaType: MediaTypeOCIManifest, Digest: DigestOf(body), Size: int64(len(body))}
+		l.manifests[d.Digest] = string(body)
+		if len(image.RepoTags) == 0 {
+			index.Manifests = append(index.Manifests, d)
+		}
+		for _, name := range image.RepoTags {
+			d.Annotations = map[string]string{annotationImageName: name}
+			index.Manifests = append(index.Manifests, d)
+		}
+	}
+	body, _ := json.Marshal(index)
+	l.index = string(body)
+	return nil
+}
+
+// addBlob descriptor of the file of docker save tarball, the layers compressed by gzip get the media type of it.
+func (l *Layout) addBlob(name, mediaType string) (layoutDescriptor, error) {
+	name = strings.TrimPrefix(path.Clean(name), "./")
+	r, err := l.open(name)
+	if err != nil {
+		return layoutDescriptor{}, err
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return layoutDescriptor{}, err
+	}
+	if mediaType == mediaTypeOCILayer {
+		magic := make([]byte, 2)
+		if _, err := r.ReadAt(magic, 0); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+			mediaType = mediaTypeOCILayerGzip
+		}
+	}
+	d := layoutDescriptor{MediaType: mediaType, Digest: fmt.Sprintf("sha256:%x", h.Sum(nil)), Size: r.Size()}
+	l.blobs[d.Digest] = name
+	return d, nil
+}
+
+// open reader of the file content.
+func (l *Layout) open(name string) (*io.SectionReader, error) {
+	e, ok := l.entries[name]
+	if !ok {
+		return nil, fmt.Errorf("%s is missing in the layout", name)
+	}
+	return io.NewSectionReader(l.f, e.offset, e.size), nil
+}
+
+// readFile content of the small file, e.g. a manifest.
+func (l *Layout) readFile(name string) (string, error) {
+	r, err := l.open(name)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if _, err := io.Copy(&b, r); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// openBlob reader of the blob by digest.
+func (l *Layout) openBlob(digest string) (*io.SectionReader, error) {
+	if name, ok := l.blobs[digest]; ok {
+		return l.open(name)
+	}
+	return l.open(blobPath(digest))
+}
+
+// readManifest the manifest by digest with its media type, the one of the descriptor unless empty
+// as OCI manifests may omit it.
+func (l *Layout) readManifest(digest, mediaType string) (string, string, error) {
+	manifest, ok := l.manifests[digest]
+	if !ok {
+		var err error
+		if manifest, err = l.readFile(blobPath(digest)); err != nil {
+			return "", "", err
+		}
+	}
+	if mediaType == "" {
+		mediaType = gjson.Get(manifest, "mediaType").String()
+	}
+	return manifest, mediaType, nil
+}
+
+// splitImageName repo and tag of the image name, the registry domain is dropped, e.g. from docker.io/library/alpine:3.
+func splitImageName(name string) (string, string) {
+	repo, tag := name, ""
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		repo, tag = name[:i], name[i+1:]
+	}
+	if i := strings.Index(repo, "/"); i > 0 {
+		domain := repo[:i]
+		if strings.ContainsAny(domain, ".:") || domain == "localhost" {
+			repo = repo[i+1:]
+			if domain == dockerHubDomain {
+				repo = strings.TrimPrefix(repo, dockerHubOfficialPrefix)
+			}
+		}
+	}
+	return repo, tag
+}
+
+// Images of the index of the layout, Tag is empty for the ones without a name and Repo when it names only the tag.
+func (l *Layout) Images() []LayoutImage {
+	var list []LayoutImage
+	for _, m := range gjson.Get(l.index, "manifests").Array() {
+		image := LayoutImage{Digest: m.Get("digest").String(), MediaType: m.Get("mediaType").String()}
+		annotations := m.Get("annotations")
+		if name := annotations.Get(strings.Replace(annotationImageName, ".", `\.`, -1)).String(); name != "" {
+			image.Repo, image.Tag = splitImageName(name)
+			if image.Tag == "" {
+				image.Repo = ""
+			}
+		}
+		if image.Tag == "" {
+			image.Tag = annotations.Get(strings.Replace(annotationRefName, ".", `\.`, -1)).String()
+		}
+		list = append(list, image)
+	}
+	return list
+}
+
+// imageBlobs blobs of the manifest and of its sub-images, every one once.
+func (l *Layout) imageBlobs(digest, mediaType string, seen map[string]bool) ([]string, error) {
+	manifest, mediaType, err := l.readManifest(digest, mediaType)
+	if err != nil {
+		return nil, err
+	}
+	var blobs []string
+	switch mediaType {
+	case MediaTypeManifestList, MediaTypeOCIIndex:
+		for _, m := range gjson.Get(manifest, "manifests").Array() {
+			sub, err := l.imageBlobs(m.Get("digest").String(), m.Get("mediaType").String(), seen)
+			if err != nil {
+				return nil, err
+			}
+			blobs = append(blobs, sub...)
+		}
+	case MediaTypeManifestV2, MediaTypeOCIManifest:
+		digests := []string{gjson.Get(manifest, "config.digest").String()}
+		for _, layer := range Layers(manifest) {
+			if !layer.Foreign {
+				digests = append(digests, layer.Digest)
+			}
+		}
+		for _, d := range digests {
+			if !seen[d] {
+				seen[d] = true
+				blobs = append(blobs, d)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("cannot import %s, unsupported manifest type %q", digest, mediaType)
+	}
+	return blobs, nil
+}
+
+// pushManifest push the manifest of the layout to the repo, sub-images of manifest list first.
+// The blobs have to be pushed before.
+func (c *Client) pushManifest(l *Layout, repo, reference, digest, mediaType string) error {
+	manifest, mediaType, err := l.readManifest(digest, mediaType)
+	if err != nil {
+		return err
+	}
+	if mediaType == MediaTypeManifestList || mediaType == MediaTypeOCIIndex {
+		for _, m := range gjson.Get(manifest, "manifests").Array() {
+			d := m.Get("digest").String()
+			if err := c.pushManifest(l, repo, d, d, m.Get("mediaType").String()); err != nil {
+				return err
+			}
+		}
+	}
+	return c.PutManifest(repo, reference, mediaType, manifest)
+}
+
+// ImportLayout push the image of the layout with all its blobs to the repo under the tag. The callback is called
+// after every blob with the count of the blobs pushed out of all and the bytes pushed so far.
+func (c *Client) ImportLayout(l *Layout, image LayoutImage, repo, tag string, progress func(done, total int, size int64)) error {
+	blobs, err := l.imageBlobs(image.Digest, image.MediaType, map[string]bool{})
+	if err != nil {
+		return err
+	}
+	var size int64
+	for i, b := range blobs {
+		r, err := l.openBlob(b)
+		if err != nil {
+			return err
+		}
+		if err := c.PushBlob(repo, b, r); err != nil {
+			return err
+		}
+		size += r.Size()
+		if progress != nil {
+			progress(i+1, len(blobs), size)
+		}
+	}
+	return c.pushManifest(l, repo, tag, image.Digest, image.MediaType)
+}
@@ -0,0 +1,166 @@
+package registry
+
+import (
+	"archive/tar"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+	"github.com/tidwall/gjson"
+)
+
+// memoryRegistry minimal registry keeping manifests and blobs in memory.
+type memoryRegistry struct {
+	mux       sync.Mutex
+	manifests map[string]string
+	blobs     map[string]string
+	uploads   int
+}
+
+func (m *memoryRegistry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/v2/"), "/", 3)
+	if len(parts) < 3 {
+		return
+	}
+	repo, kind, ref := parts[0], parts[1], parts[2]
+	switch {
+	case kind == "blobs" && ref == "uploads/" && r.Method == "POST":
+		w.Header().Set("Location", "/v2/"+repo+"/blobs/uploads/1?state=x")
+		w.WriteHeader(http.StatusAccepted)
+	case kind == "blobs" && strings.HasPrefix(ref, "uploads/") && r.Method == "PUT":
+		data, _ := ioutil.ReadAll(r.Body)
+		if DigestOf(data) != r.URL.Query().Get("digest") || r.ContentLength != int64(len(data)) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		m.blobs[repo+"@"+DigestOf(data)] = string(data)
+		m.uploads++
+		w.WriteHeader(http.StatusCreated)
+	case kind == "blobs":
+		data, ok := m.blobs[repo+"@"+ref]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprint(w, data)
+	case kind == "manifests" && r.Method == "PUT":
+		data, _ := ioutil.ReadAll(r.Body)
+		m.manifests[repo+":"+ref] = string(data)
+		m.manifests[repo+":"+DigestOf(data)] = string(data)
+		w.WriteHeader(http.StatusCreated)
+	case kind == "manifests":
+		data, ok := m.manifests[repo+":"+ref]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", MediaTypeOCIManifest)
+		w.Header().Set("Docker-Content-Digest", DigestOf([]byte(data)))
+		fmt.Fprint(w, data)
+	}
+}
+
+func TestLayout(t *testing.T) {
+	config, layer := `{"architecture": "amd64"}`, "layer content"
+	manifest := fmt.Sprintf(`{"schemaVersion": 2, "mediaType": "%s", "config": {"digest": "%s", "size": %d}, "layers": [
+		{"mediaType": "application/vnd.oci.image.layer.v1.tar+gzip", "digest": "%s", "size": %d}]}`,
+		MediaTypeOCIManifest, DigestOf([]byte(config)), len(config), DigestOf([]byte(layer)), len(layer))
+	reg := &memoryRegistry{
+		manifests: map[string]string{"app:v1": manifest, "app:v2": manifest},
+		blobs:     map[string]string{"app@" + DigestOf([]byte(config)): config, "app@" + DigestOf([]byte(layer)): layer},
+	}
+	server := httptest.NewServer(reg)
+	defer server.Close()
+	c := NewClient(server.URL, true, "", "")
+
+	f, _ := ioutil.TempFile("", "layout")
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	convey.Convey("Export the images with the blobs shared by them written once", t, func() {
+		var done []string
+		size, err := c.ExportLayout(f, []LayoutImage{{Repo: "app", Tag: "v1"}, {Repo: "app", Tag: "v2"}}, func(i int, image LayoutImage) {
+			done = append(done, image.String())
+		})
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(done, convey.ShouldResemble, []string{"app:v1", "app:v2"})
+		convey.So(size, convey.ShouldBeGreaterThan, len(manifest)+len(config)+len(layer))
+
+		_, err = c.ExportLayout(ioutil.Discard, []LayoutImage{{Repo: "app", Tag: "missing"}}, nil)
+		convey.So(err, convey.ShouldNotBeNil)
+	})
+
+	convey.Convey("Read the images of the layout and push them under another name", t, func() {
+		l, err := OpenLayout(f)
+		convey.So(err, convey.ShouldBeNil)
+		images := l.Images()
+		convey.So(len(images), convey.ShouldEqual, 2)
+		convey.So(images[0], convey.ShouldResemble, LayoutImage{Repo: "app", Tag: "v1", Digest: DigestOf([]byte(manifest)), MediaType: MediaTypeOCIManifest})
+
+		var pushed []int64
+		err = c.ImportLayout(l, images[0], "copy", "latest", func(done, total int, size int64) {
+			convey.So(total, convey.ShouldEqual, 2)
+			pushed = append(pushed, size)
+		})
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(pushed, convey.ShouldResemble, []int64{int64(len(config)), int64(len(config) + len(layer))})
+		convey.So(reg.manifests["copy:latest"], convey.ShouldEqual, manifest)
+		convey.So(reg.blobs["copy@"+DigestOf([]byte(layer))], convey.ShouldEqual, layer)
+		convey.So(reg.uploads, convey.ShouldEqual, 2)
+
+		// The blobs are in the repo already.
+		convey.So(c.ImportLayout(l, images[1], "copy", "v2", nil), convey.ShouldBeNil)
+		convey.So(reg.uploads, convey.ShouldEqual, 2)
+	})
+
+	convey.Convey("Refuse tar which is not OCI image layout", t, func() {
+		g, _ := ioutil.TempFile("", "layout")
+		defer os.Remove(g.Name())
+		defer g.Close()
+		g.WriteString(strings.Repeat("\x00", 1024))
+		_, err := OpenLayout(g)
+		convey.So(err, convey.ShouldNotBeNil)
+	})
+
+	convey.Convey("Push the images of docker save tarball with OCI manifests made up for them", t, func() {
+		g, _ := ioutil.TempFile("", "docker-save")
+		defer os.Remove(g.Name())
+		defer g.Close()
+		gzipped := "\x1f\x8bgzipped layer"
+		tw := tar.NewWriter(g)
+		for _, file := range [][2]string{
+			{"manifest.json", `[{"Config": "config.json", "RepoTags": ["docker.io/library/alpine:3", "registry.local:5000/team/app:v1"],
+				"Layers": ["a/layer.tar", "b/layer.tar"]}, {"Config": "config.json", "RepoTags": null, "Layers": []}]`},
+			{"config.json", config},
+			{"a/layer.tar", layer},
+			{"b/layer.tar", gzipped},
+		} {
+			tw.WriteHeader(&tar.Header{Name: file[0], Mode: 0644, Size: int64(len(file[1])), Typeflag: tar.TypeReg})
+			tw.Write([]byte(file[1]))
+		}
+		tw.Close()
+
+		l, err := OpenLayout(g)
+		convey.So(err, convey.ShouldBeNil)
+		images := l.Images()
+		convey.So(len(images), convey.ShouldEqual, 3)
+		convey.So(images[0].String(), convey.ShouldEqual, "alpine:3")
+		convey.So(images[1].String(), convey.ShouldEqual, "team/app:v1")
+		convey.So(images[2].Tag, convey.ShouldEqual, "")
+
+		convey.So(c.ImportLayout(l, images[0], "saved", "v1", nil), convey.ShouldBeNil)
+		pushed := reg.manifests["saved:v1"]
+		convey.So(gjson.Get(pushed, "config.digest").String(), convey.ShouldEqual, DigestOf([]byte(config)))
+		convey.So(gjson.Get(pushed, "layers.#.mediaType").String(), convey.ShouldEqual,
+			`["application/vnd.oci.image.layer.v1.tar","application/vnd.oci.image.layer.v1.tar+gzip"]`)
+		convey.So(reg.blobs["saved@"+DigestOf([]byte(gzipped))], convey.ShouldEqual, gzipped)
+	})
+}
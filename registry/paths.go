@@ -0,0 +1,91 @@
+package registry
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// PullHost host to pull images of the matching repos from, for registries like Nexus or Artifactory
+// exposing docker repositories under different ports, sub-domains or paths than their registry API.
+type PullHost struct {
+	// Repos pattern of the repo path, a trailing * matches by prefix, empty matches all.
+	Repos string `yaml:"repos"`
+	// Host with optional path, {namespace} is replaced with the first segment of the repo path.
+	Host string `yaml:"host"`
+	// StripNamespace remove the first segment from the repo path, e.g. when it is the sub-domain.
+	StripNamespace bool `yaml:"strip_namespace"`
+}
+
+// matches the repo path.
+func (h PullHost) matches(repo string) bool {
+	if h.Repos == "" || h.Repos == "*" {
+		return true
+	}
+	if strings.HasSuffix(h.Repos, "*") {
+		return strings.HasPrefix(repo, strings.TrimSuffix(h.Repos, "*"))
+	}
+	return repo == h.Repos
+}
+
+// ImageName name to pull the repo by without tag, the first matching host is used, defaultHost otherwise.
+func ImageName(hosts []PullHost, defaultHost, repo string) string {
+	for _, h := range hosts {
+		if !h.matches(repo) {
+			continue
+		}
+		namespace, path := "library", repo
+		if i := strings.Index(repo, "/"); i >= 0 {
+			namespace = repo[:i]
+			if h.StripNamespace {
+				path = repo[i+1:]
+			}
+		}
+		return strings.TrimRight(strings.Replace(h.Host, "{namespace}", namespace, -1), "/") + "/" + path
+	}
+	return defaultHost + "/" + repo
+}
+
+// normalizeCatalog clean up repo names from the catalog: Nexus group repositories list the same repo
+// from every member and some registries return names with slashes around.
+func normalizeCatalog(names []string) []string {
+	seen := map[string]bool{}
+	list := []string{}
+	for _, n := range names {
+		n = strings.Trim(n, "/")
+		if n == "" || seen[n] {
+			continue
+		}
+		seen[n] = true
+		list = append(list, n)
+	}
+	sort.Strings(list)
+	return list
+}
+
+// groupCatalog repos of the catalog by namespaces where 'library' is the default one.
+func groupCatalog(names []string) map[string][]string {
+	repos := map[string][]string{}
+	for _, repo := range normalizeCatalog(names) {
+		namespace := "library"
+		if strings.Contains(repo, "/") {
+			f := strings.SplitN(repo, "/", 2)
+			namespace = f[0]
+			repo = f[1]
+		}
+		repos[namespace] = append(repos[namespace], repo)
+	}
+	return repos
+}
+
+// relativeURI make URI from Link header or pagination relative to the registry URL,
+// registries under a sub-path return links with the full path or absolute URLs.
+func (c *Client) relativeURI(link string) string {
+	if u, err := url.Parse(link); err == nil && u.IsAbs() {
+		link = u.RequestURI()
+	}
+	if base, err := url.Parse(c.url); err == nil && base.Path != "" && strings.HasPrefix(link, base.Path+"/") {
+		link = strings.TrimPrefix(link, base.Path)
+	}
+	return link
+}
@@ -0,0 +1,34 @@
+package registry
+
+import (
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestPaths(t *testing.T) {
+	convey.Convey("Image names by pull hosts", t, func() {
+		hosts := []PullHost{
+			{Repos: "team/*", Host: "nexus.local:8083"},
+			{Repos: "alpine", Host: "artifactory.local/docker-remote/"},
+			{Host: "{namespace}.docker.local", StripNamespace: true},
+		}
+		convey.So(ImageName(hosts, "registry.local", "team/app"), convey.ShouldEqual, "nexus.local:8083/team/app")
+		convey.So(ImageName(hosts, "registry.local", "alpine"), convey.ShouldEqual, "artifactory.local/docker-remote/alpine")
+		convey.So(ImageName(hosts, "registry.local", "infra/tools/git"), convey.ShouldEqual, "infra.docker.local/tools/git")
+		convey.So(ImageName(nil, "registry.local", "team/app"), convey.ShouldEqual, "registry.local/team/app")
+	})
+
+	convey.Convey("Normalize catalog names", t, func() {
+		names := normalizeCatalog([]string{"team/app", "/alpine", "alpine", "", "team/app/"})
+		convey.So(names, convey.ShouldResemble, []string{"alpine", "team/app"})
+	})
+
+	convey.Convey("Links relative to registry under sub-path", t, func() {
+		c := &Client{url: "https://artifactory.local/artifactory/api/docker/docker-local"}
+		convey.So(c.relativeURI("/v2/_catalog?last=a&n=100"), convey.ShouldEqual, "/v2/_catalog?last=a&n=100")
+		convey.So(c.relativeURI("/artifactory/api/docker/docker-local/v2/_catalog?last=a"), convey.ShouldEqual, "/v2/_catalog?last=a")
+		convey.So(c.relativeURI("https://artifactory.local/artifactory/api/docker/docker-local/v2/_catalog?last=a"), convey.ShouldEqual, "/v2/_catalog?last=a")
+		convey.So(c.endpointName("GET", c.url+"/v2/team/app/manifests/latest"), convey.ShouldEqual, "GET /v2/<name>/manifests")
+	})
+}
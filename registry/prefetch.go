@@ -0,0 +1,158 @@
+package registry
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Limits of the recently viewed repos tracking.
+const (
+	recentPerViewer = 10
+	recentMaxAge    = 24 * time.Hour
+)
+
+type recentView struct {
+	repo string
+	at   time.Time
+}
+
+// recentRepos repos recently browsed by each viewer, the latest first.
+type recentRepos struct {
+	mux     sync.Mutex
+	viewers map[string][]recentView
+	// pushed returns recently pushed repos, the latest first.
+	pushed func() []string
+}
+
+// repoRef repository of the catalog.
+type repoRef struct {
+	namespace string
+	repo      string
+	path      string
+	pushed    bool
+}
+
+// RecentlyPushed set the source of recently pushed repos, e.g. registry events, to refresh them first.
+func (c *Client) RecentlyPushed(fn func() []string) {
+	c.recent.mux.Lock()
+	c.recent.pushed = fn
+	c.recent.mux.Unlock()
+}
+
+// refreshOrder order repos of the catalog for the background refresh:
+// recently pushed first, then recently viewed, then the rest by name.
+func (c *Client) refreshOrder(catalog map[string][]string) []repoRef {
+	var all []repoRef
+	byPath := map[string]repoRef{}
+	for n, repos := range catalog {
+		for _, r := range repos {
+			ref := repoRef{namespace: n, repo: r, path: r}
+			if n != "library" {
+				ref.path = n + "/" + r
+			}
+			all = append(all, ref)
+			byPath[ref.path] = ref
+		}
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].path < all[j].path })
+
+	c.recent.mux.Lock()
+	pushed := c.recent.pushed
+	c.recent.mux.Unlock()
+	var first []string
+	if pushed != nil {
+		first = pushed()
+	}
+	pushedCount := len(first)
+	first = append(first, c.hotRepos(time.Now())...)
+
+	list := make([]repoRef, 0, len(all))
+	seen := map[string]bool{}
+	for i, path := range first {
+		if ref, ok := byPath[path]; ok && !seen[path] {
+			ref.pushed = i < pushedCount
+			list = append(list, ref)
+			seen[path] = true
+		}
+	}
+	for _, ref := range all {
+		if !seen[ref.path] {
+			list = append(list, ref)
+		}
+	}
+	return list
+}
+
+// RepoViewed remember the repo was browsed by the viewer, e.g. user name or IP, to keep its tag metadata prefetched.
+func (c *Client) RepoViewed(viewer, repo string) {
+	c.recent.mux.Lock()
+	defer c.recent.mux.Unlock()
+	if c.recent.viewers == nil {
+		c.recent.viewers = map[string][]recentView{}
+	}
+	list := []recentView{{repo, time.Now()}}
+	for _, v := range c.recent.viewers[viewer] {
+		if v.repo != repo && len(list) < recentPerViewer {
+			list = append(list, v)
+		}
+	}
+	c.recent.viewers[viewer] = list
+}
+
+// hotRepos recently viewed repos ordered by the count of viewers and then by the last view,
+// views older than recentMaxAge are forgotten.
+func (c *Client) hotRepos(now time.Time) []string {
+	c.recent.mux.Lock()
+	defer c.recent.mux.Unlock()
+	viewers := map[string]int{}
+	last := map[string]time.Time{}
+	for viewer, list := range c.recent.viewers {
+		var kept []recentView
+		for _, v := range list {
+			if now.Sub(v.at) > recentMaxAge {
+				continue
+			}
+			kept = append(kept, v)
+			viewers[v.repo]++
+			if v.at.After(last[v.repo]) {
+				last[v.repo] = v.at
+			}
+		}
+		if len(kept) == 0 {
+			delete(c.recent.viewers, viewer)
+		} else {
+			c.recent.viewers[viewer] = kept
+		}
+	}
+
+	repos := make([]string, 0, len(viewers))
+	for repo := range viewers {
+		repos = append(repos, repo)
+	}
+	sort.Slice(repos, func(i, j int) bool {
+		if viewers[repos[i]] != viewers[repos[j]] {
+			return viewers[repos[i]] > viewers[repos[j]]
+		}
+		return last[repos[i]].After(last[repos[j]])
+	})
+	return repos
+}
+
+// PrefetchRecent refresh tag metadata of the recently viewed repos if it is going to expire soon,
+// so returning to the repo page does not wait for the registry. Returns the count of repos.
+// Nothing is prefetched while the background jobs are paused as registry is throttling.
+func (c *Client) PrefetchRecent() int {
+	if !c.Backoff().PausedUntil.IsZero() {
+		return 0
+	}
+	c.meta.mux.Lock()
+	maxAge := c.meta.ttl / 2
+	c.meta.mux.Unlock()
+	repos := c.hotRepos(time.Now())
+	for _, repo := range repos {
+		c.throttle()
+		c.tagsMetadata(repo, c.Tags(repo), maxAge)
+	}
+	return len(repos)
+}
@@ -0,0 +1,53 @@
+package registry
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestHotRepos(t *testing.T) {
+	convey.Convey("Order recently viewed repos by viewers and last view", t, func() {
+		c := &Client{}
+		c.RepoViewed("alice", "team/app")
+		c.RepoViewed("alice", "library/alpine")
+		c.RepoViewed("bob", "team/worker")
+		c.RepoViewed("bob", "team/app")
+		convey.So(c.hotRepos(time.Now()), convey.ShouldResemble, []string{"team/app", "team/worker", "library/alpine"})
+	})
+
+	convey.Convey("Keep the latest repos per viewer", t, func() {
+		c := &Client{}
+		for i := 0; i < recentPerViewer+5; i++ {
+			c.RepoViewed("alice", fmt.Sprintf("repo%d", i))
+		}
+		c.RepoViewed("alice", "repo10")
+		repos := c.hotRepos(time.Now())
+		convey.So(len(repos), convey.ShouldEqual, recentPerViewer)
+		convey.So(repos[0], convey.ShouldEqual, "repo10")
+	})
+
+	convey.Convey("Forget old views", t, func() {
+		c := &Client{}
+		c.RepoViewed("alice", "team/app")
+		convey.So(c.hotRepos(time.Now().Add(recentMaxAge+time.Minute)), convey.ShouldBeEmpty)
+		convey.So(c.recent.viewers, convey.ShouldBeEmpty)
+	})
+}
+
+func TestRefreshOrder(t *testing.T) {
+	convey.Convey("Refresh pushed and viewed repos first", t, func() {
+		c := &Client{}
+		c.RecentlyPushed(func() []string { return []string{"team/worker", "gone/repo"} })
+		c.RepoViewed("alice", "alpine")
+		c.RepoViewed("bob", "team/worker")
+
+		var paths []string
+		for _, r := range c.refreshOrder(map[string][]string{"library": {"busybox", "alpine"}, "team": {"app", "worker"}}) {
+			paths = append(paths, r.path)
+		}
+		convey.So(paths, convey.ShouldResemble, []string{"team/worker", "alpine", "busybox", "team/app"})
+	})
+}
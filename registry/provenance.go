@@ -0,0 +1,250 @@
+package registry
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tidwall/gjson"
+)
+
+const (
+	// MediaTypeInToto layer of BuildKit attestation manifest with in-toto statement.
+	MediaTypeInToto = "application/vnd.in-toto+json"
+	// MediaTypeDSSE layer of cosign attestation with in-toto statement in DSSE envelope.
+	MediaTypeDSSE = "application/vnd.dsse.envelope.v1+json"
+)
+
+// Material source the image was built from, e.g. git repo or base image.
+type Material struct {
+	URI    string
+	Digest string
+}
+
+// Parameter build parameter with dotted name, e.g. args.build-arg:VERSION.
+type Parameter struct {
+	Name  string
+	Value string
+}
+
+// Provenance SLSA provenance of the image decoded from in-toto statement.
+type Provenance struct {
+	// Platform of the image the attestation is for, empty for single-arch images.
+	Platform string
+	// Origin how the attestation is attached: BuildKit attestation manifest or cosign attestation.
+	Origin        string
+	PredicateType string
+	Builder       string
+	BuildType     string
+	Source        string
+	Commit        string
+	// EntryPoint build file or workflow, e.g. Dockerfile or .github/workflows/build.yml.
+	EntryPoint   string
+	InvocationID string
+	Started      time.Time
+	Finished     time.Time
+	Parameters   []Parameter
+	Materials    []Material
+	// Statement the whole in-toto statement indented.
+	Statement string
+}
+
+// IsProvenance whether the predicate type is SLSA provenance of any version.
+func IsProvenance(predicateType string) bool {
+	return strings.HasPrefix(predicateType, "https://slsa.dev/provenance/")
+}
+
+// ParseProvenance decode SLSA provenance v0.2 or v1 from in-toto statement.
+func ParseProvenance(statement []byte) (Provenance, error) {
+	if !gjson.ValidBytes(statement) {
+		return Provenance{}, fmt.Errorf("cannot parse in-toto statement")
+	}
+	s := gjson.ParseBytes(statement)
+	p := Provenance{PredicateType: s.Get("predicateType").String()}
+	if !IsProvenance(p.PredicateType) {
+		return p, fmt.Errorf("not SLSA provenance: %q", p.PredicateType)
+	}
+	var indented bytes.Buffer
+	json.Indent(&indented, statement, "", "  ")
+	p.Statement = indented.String()
+
+	pred := s.Get("predicate")
+	// BuildKit keeps git details in its own metadata in both versions.
+	vcs := buildkitVCS(pred.Get("metadata"))
+	if strings.HasSuffix(p.PredicateType, "/v0.2") || strings.HasSuffix(p.PredicateType, "/v0.1") {
+		p.Builder = pred.Get("builder.id").String()
+		p.BuildType = pred.Get("buildType").String()
+		p.Source = pred.Get("invocation.configSource.uri").String()
+		p.Commit = pred.Get("invocation.configSource.digest.sha1").String()
+		p.EntryPoint = pred.Get("invocation.configSource.entryPoint").String()
+		p.InvocationID = pred.Get("metadata.buildInvocationID").String()
+		p.Started = pred.Get("metadata.buildStartedOn").Time()
+		p.Finished = pred.Get("metadata.buildFinishedOn").Time()
+		p.Parameters = parameters(pred.Get("invocation.parameters"), "", nil)
+		for _, m := range pred.Get("materials").Array() {
+			p.Materials = append(p.Materials, Material{URI: m.Get("uri").String(), Digest: firstDigest(m.Get("digest"))})
+		}
+	} else {
+		run := pred.Get("runDetails")
+		def := pred.Get("buildDefinition")
+		p.Builder = run.Get("builder.id").String()
+		p.BuildType = def.Get("buildType").String()
+		p.InvocationID = run.Get("metadata.invocationId").String()
+		if p.InvocationID == "" {
+			p.InvocationID = run.Get("metadata.invocationID").String()
+		}
+		p.Started = run.Get("metadata.startedOn").Time()
+		p.Finished = run.Get("metadata.finishedOn").Time()
+		// GitHub Actions workflow or BuildKit config source.
+		if w := def.Get("externalParameters.workflow"); w.Exists() {
+			p.Source = w.Get("repository").String()
+			p.EntryPoint = w.Get("path").String()
+		} else {
+			p.Source = def.Get("externalParameters.configSource.uri").String()
+			p.Commit = def.Get("externalParameters.configSource.digest.sha1").String()
+			p.EntryPoint = def.Get("externalParameters.configSource.path").String()
+		}
+		p.Parameters = parameters(def.Get("externalParameters"), "", nil)
+		for _, m := range def.Get("resolvedDependencies").Array() {
+			material := Material{URI: m.Get("uri").String(), Digest: firstDigest(m.Get("digest"))}
+			if p.Commit == "" && strings.HasPrefix(material.URI, "git+") && m.Get("digest.gitCommit").Exists() {
+				p.Commit = m.Get("digest.gitCommit").String()
+			}
+			p.Materials = append(p.Materials, material)
+		}
+		vcs = buildkitVCS(run.Get("metadata"))
+	}
+	if p.Source == "" {
+		p.Source = vcs.Get("source").String()
+	}
+	if p.Commit == "" {
+		p.Commit = vcs.Get("revision").String()
+	}
+	sort.Slice(p.Parameters, func(i, j int) bool { return p.Parameters[i].Name < p.Parameters[j].Name })
+	return p, nil
+}
+
+// buildkitVCS git source and revision from BuildKit metadata, the key is looked up as is
+// since it contains characters special to gjson paths.
+func buildkitVCS(metadata gjson.Result) gjson.Result {
+	return metadata.Map()["https://mobyproject.org/buildkit@v1#metadata"].Get("vcs")
+}
+
+// parameters flatten build parameters into dotted names.
+func parameters(value gjson.Result, prefix string, params []Parameter) []Parameter {
+	if !value.IsObject() {
+		if prefix != "" && value.Exists() {
+			params = append(params, Parameter{prefix, value.String()})
+		}
+		return params
+	}
+	value.ForEach(func(k, v gjson.Result) bool {
+		name := k.String()
+		if prefix != "" {
+			name = prefix + "." + name
+		}
+		params = parameters(v, name, params)
+		return true
+	})
+	return params
+}
+
+// firstDigest digest as algorithm:hex, sha256 is preferred when there are several.
+func firstDigest(digests gjson.Result) string {
+	m := digests.Map()
+	if d, ok := m["sha256"]; ok {
+		return "sha256:" + d.String()
+	}
+	var algs []string
+	for alg := range m {
+		algs = append(algs, alg)
+	}
+	sort.Strings(algs)
+	if len(algs) == 0 {
+		return ""
+	}
+	return algs[0] + ":" + m[algs[0]].String()
+}
+
+// Provenance SLSA provenance attached to the tag by BuildKit attestation manifests of the index
+// or by cosign attestation tag sha256-<digest>.att.
+func (c *Client) Provenance(repo, tag string) ([]Provenance, error) {
+	manifest, mediaType, digest, err := c.GetManifest(repo, tag)
+	if err != nil {
+		return nil, err
+	}
+	var list []Provenance
+	if mediaType == MediaTypeManifestList || mediaType == MediaTypeOCIIndex {
+		platforms := map[string]string{}
+		for _, m := range gjson.Get(manifest, "manifests").Array() {
+			platforms[m.Get("digest").String()] = PlatformString(m.Get("platform"))
+		}
+		for _, m := range gjson.Get(manifest, "manifests").Array() {
+			if m.Get(`annotations.vnd\.docker\.reference\.type`).String() != "attestation-manifest" {
+				continue
+			}
+			found, err := c.attestations(repo, m.Get("digest").String(), "BuildKit attestation")
+			if err != nil {
+				return list, err
+			}
+			for _, p := range found {
+				p.Platform = platforms[m.Get(`annotations.vnd\.docker\.reference\.digest`).String()]
+				list = append(list, p)
+			}
+		}
+	}
+	// Cosign attestations are stored by the digest of the tag itself.
+	attTag := strings.Replace(digest, ":", "-", 1) + ".att"
+	if ItemInSlice(attTag, c.Tags(repo)) {
+		found, err := c.attestations(repo, attTag, "cosign attestation")
+		if err != nil {
+			return list, err
+		}
+		list = append(list, found...)
+	}
+	return list, nil
+}
+
+// attestations provenance statements from the layers of the attestation manifest.
+func (c *Client) attestations(repo, reference, origin string) ([]Provenance, error) {
+	manifest, _, _, err := c.GetManifest(repo, reference)
+	if err != nil {
+		return nil, err
+	}
+	var list []Provenance
+	for _, l := range gjson.Get(manifest, "layers").Array() {
+		mediaType := l.Get("mediaType").String()
+		predicateType := l.Get(`annotations.in-toto\.io/predicate-type`).String()
+		if mediaType != MediaTypeInToto && mediaType != MediaTypeDSSE {
+			continue
+		}
+		if predicateType != "" && !IsProvenance(predicateType) {
+			continue
+		}
+		blob, err := c.GetBlob(repo, l.Get("digest").String())
+		if err != nil {
+			return list, err
+		}
+		statement := []byte(blob)
+		if mediaType == MediaTypeDSSE {
+			statement, err = base64.StdEncoding.DecodeString(gjson.Get(blob, "payload").String())
+			if err != nil {
+				return list, fmt.Errorf("cannot decode DSSE payload of %s: %s", reference, err)
+			}
+		}
+		if !IsProvenance(gjson.GetBytes(statement, "predicateType").String()) {
+			continue
+		}
+		p, err := ParseProvenance(statement)
+		if err != nil {
+			return list, err
+		}
+		p.Origin = origin
+		list = append(list, p)
+	}
+	return list, nil
+}
@@ -0,0 +1,145 @@
+package registry
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+const githubProvenance = `{
+  "_type": "https://in-toto.io/Statement/v1",
+  "predicateType": "https://slsa.dev/provenance/v1",
+  "predicate": {
+    "buildDefinition": {
+      "buildType": "https://actions.github.io/buildtypes/workflow/v1",
+      "externalParameters": {"workflow": {"ref": "refs/heads/main", "repository": "https://github.com/acme/app", "path": ".github/workflows/build.yml"}},
+      "resolvedDependencies": [{"uri": "git+https://github.com/acme/app@refs/heads/main", "digest": {"gitCommit": "3f2a1b0"}}]
+    },
+    "runDetails": {
+      "builder": {"id": "https://github.com/acme/app/.github/workflows/build.yml@refs/heads/main"},
+      "metadata": {"invocationId": "https://github.com/acme/app/actions/runs/1/attempts/1"}
+    }
+  }
+}`
+
+const buildkitProvenance = `{
+  "_type": "https://in-toto.io/Statement/v0.1",
+  "predicateType": "https://slsa.dev/provenance/v0.2",
+  "predicate": {
+    "builder": {"id": ""},
+    "buildType": "https://mobyproject.org/buildkit@v1",
+    "invocation": {
+      "configSource": {"entryPoint": "Dockerfile"},
+      "parameters": {"frontend": "dockerfile.v0", "args": {"build-arg:VERSION": "1.0"}}
+    },
+    "metadata": {
+      "buildStartedOn": "2021-04-01T10:00:00Z",
+      "https://mobyproject.org/buildkit@v1#metadata": {"vcs": {"source": "https://github.com/acme/app", "revision": "9c8d7e6"}}
+    },
+    "materials": [{"uri": "pkg:docker/alpine@3.13", "digest": {"sha256": "abc"}}]
+  }
+}`
+
+func TestParseProvenance(t *testing.T) {
+	convey.Convey("Parse SLSA provenance v1 of GitHub Actions", t, func() {
+		p, err := ParseProvenance([]byte(githubProvenance))
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(p.Builder, convey.ShouldEqual, "https://github.com/acme/app/.github/workflows/build.yml@refs/heads/main")
+		convey.So(p.Source, convey.ShouldEqual, "https://github.com/acme/app")
+		convey.So(p.Commit, convey.ShouldEqual, "3f2a1b0")
+		convey.So(p.EntryPoint, convey.ShouldEqual, ".github/workflows/build.yml")
+		convey.So(p.InvocationID, convey.ShouldEqual, "https://github.com/acme/app/actions/runs/1/attempts/1")
+		convey.So(p.Parameters, convey.ShouldResemble, []Parameter{
+			{"workflow.path", ".github/workflows/build.yml"}, {"workflow.ref", "refs/heads/main"}, {"workflow.repository", "https://github.com/acme/app"},
+		})
+		convey.So(p.Materials, convey.ShouldResemble, []Material{{URI: "git+https://github.com/acme/app@refs/heads/main", Digest: "gitCommit:3f2a1b0"}})
+	})
+
+	convey.Convey("Parse SLSA provenance v0.2 of BuildKit with git details in its metadata", t, func() {
+		p, err := ParseProvenance([]byte(buildkitProvenance))
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(p.BuildType, convey.ShouldEqual, "https://mobyproject.org/buildkit@v1")
+		convey.So(p.Source, convey.ShouldEqual, "https://github.com/acme/app")
+		convey.So(p.Commit, convey.ShouldEqual, "9c8d7e6")
+		convey.So(p.EntryPoint, convey.ShouldEqual, "Dockerfile")
+		convey.So(p.Started.Year(), convey.ShouldEqual, 2021)
+		convey.So(p.Parameters, convey.ShouldResemble, []Parameter{{"args.build-arg:VERSION", "1.0"}, {"frontend", "dockerfile.v0"}})
+		convey.So(p.Materials, convey.ShouldResemble, []Material{{URI: "pkg:docker/alpine@3.13", Digest: "sha256:abc"}})
+	})
+
+	convey.Convey("Other statements are not provenance", t, func() {
+		_, err := ParseProvenance([]byte(`{"predicateType": "https://spdx.dev/Document"}`))
+		convey.So(err, convey.ShouldNotBeNil)
+		_, err = ParseProvenance([]byte("not json"))
+		convey.So(err, convey.ShouldNotBeNil)
+	})
+}
+
+func TestProvenanceLookup(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "mock")
+	defer os.RemoveAll(dir)
+	fixture := filepath.Join(dir, "fixture.yml")
+	ioutil.WriteFile(fixture, []byte(`
+repositories:
+  team/app:
+    "1.0":
+      created: 2021-04-01T10:00:00Z
+      layers: [100]
+      provenance: {builder: https://github.com/acme/app/actions, source: https://github.com/acme/app, commit: 9c8d7e6}
+    "2.0": {created: 2021-04-01T10:00:00Z, layers: [200]}
+`), 0600)
+	mock, err := NewMockRegistry(fixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	url, err := mock.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewClient(url, true, "", "")
+
+	convey.Convey("Find BuildKit attestation of the index", t, func() {
+		list, err := c.Provenance("team/app", "1.0")
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(len(list), convey.ShouldEqual, 1)
+		convey.So(list[0].Origin, convey.ShouldEqual, "BuildKit attestation")
+		convey.So(list[0].Platform, convey.ShouldEqual, "linux/amd64")
+		convey.So(list[0].Source, convey.ShouldEqual, "https://github.com/acme/app")
+		convey.So(list[0].Commit, convey.ShouldEqual, "9c8d7e6")
+
+		meta, err := c.TagMetadata("team/app", "1.0")
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(meta.Platforms, convey.ShouldResemble, []string{"linux/amd64"})
+	})
+
+	convey.Convey("Find cosign attestation by the digest tag", t, func() {
+		list, err := c.Provenance("team/app", "2.0")
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(list, convey.ShouldBeEmpty)
+
+		_, _, digest, _ := c.GetManifest("team/app", "2.0")
+		envelope, _ := json.Marshal(map[string]string{
+			"payloadType": "application/vnd.in-toto+json",
+			"payload":     base64.StdEncoding.EncodeToString([]byte(githubProvenance)),
+		})
+		mock.mux.Lock()
+		mock.blobs[DigestOf(envelope)] = envelope
+		body, _ := json.Marshal(map[string]interface{}{
+			"schemaVersion": 2, "mediaType": MediaTypeOCIManifest,
+			"layers": []interface{}{map[string]interface{}{"mediaType": MediaTypeDSSE, "size": len(envelope), "digest": DigestOf(envelope)}},
+		})
+		mock.putManifest("team/app", "sha256-"+digest[len("sha256:"):]+".att", MediaTypeOCIManifest, body)
+		mock.mux.Unlock()
+
+		list, err = c.Provenance("team/app", "2.0")
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(len(list), convey.ShouldEqual, 1)
+		convey.So(list[0].Origin, convey.ShouldEqual, "cosign attestation")
+		convey.So(list[0].Commit, convey.ShouldEqual, "3f2a1b0")
+	})
+}
@@ -0,0 +1,61 @@
+package registry
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// quayPageSize items per page of Quay API lists, the maximum allowed.
+const quayPageSize = 100
+
+// quay extended API of Quay, the token should be OAuth access token of Quay application.
+type quay struct {
+	api extensionAPI
+}
+
+func (q quay) detect() bool {
+	res, _, err := q.api.get("/api/v1/discovery")
+	return err == nil && strings.Contains(res.Get("info.title").String(), "Quay")
+}
+
+// tagsMetadata metadata of active tags, Quay does not expose the image creation time, so the push time is used.
+func (q quay) tagsMetadata(repo string) (map[string]TagMeta, error) {
+	metas := map[string]TagMeta{}
+	for page := 1; ; page++ {
+		res, _, err := q.api.get(fmt.Sprintf("/api/v1/repository/%s/tag/?onlyActiveTags=true&limit=%d&page=%d", repo, quayPageSize, page))
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range res.Get("tags").Array() {
+			metas[t.Get("name").String()] = TagMeta{
+				Digest:      t.Get("manifest_digest").String(),
+				Size:        t.Get("size").Int(),
+				Created:     time.Unix(t.Get("start_ts").Int(), 0),
+				Labels:      map[string]string{},
+				Annotations: map[string]string{},
+			}
+		}
+		if !res.Get("has_additional").Bool() {
+			return metas, nil
+		}
+	}
+}
+
+// pullCount pulls within the period Quay keeps the stats for, usually 30 days.
+func (q quay) pullCount(repo string) (int, error) {
+	res, _, err := q.api.get(fmt.Sprintf("/api/v1/repository/%s?includeStats=true", repo))
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, s := range res.Get("stats.#.count").Array() {
+		count = count + int(s.Int())
+	}
+	return count, nil
+}
+
+// gcStatus Quay collects garbage continuously by itself.
+func (q quay) gcStatus() (GCStatus, error) {
+	return GCStatus{}, ErrNotSupported
+}
@@ -0,0 +1,124 @@
+package registry
+
+import (
+	"fmt"
+
+	"github.com/tidwall/gjson"
+)
+
+// ImageBlobs blobs of the image stored by the registry with their sizes: the manifests, configs and layers.
+// Foreign layers are not stored so they are skipped.
+type ImageBlobs map[string]int64
+
+// Reclaim space estimated to be reclaimed by deleting tags.
+type Reclaim struct {
+	Tags int
+	// NaiveSize sum of the image sizes of the deleted tags, shared blobs are counted many times.
+	NaiveSize int64
+	// Size of the blobs referenced only by the deleted images, garbage collection frees them.
+	Size  int64
+	Blobs int
+}
+
+// String describe the reclaimed space for the logs.
+func (r Reclaim) String() string {
+	return fmt.Sprintf("%s in %d blobs of %s total size of %d tags",
+		PrettySize(float64(r.Size)), r.Blobs, PrettySize(float64(r.NaiveSize)), r.Tags)
+}
+
+// ManifestBlobs parse the blobs of image manifest, the index children are added by the caller.
+func ManifestBlobs(manifest string, blobs ImageBlobs) {
+	if c := gjson.Get(manifest, "config"); c.Exists() {
+		blobs[c.Get("digest").String()] = c.Get("size").Int()
+	}
+	for _, l := range Layers(manifest) {
+		if !l.Foreign {
+			blobs[l.Digest] = l.Size
+		}
+	}
+}
+
+// GetImageBlobs get blobs of the tag including the images of the index, returns the manifest digest too.
+func (c *Client) GetImageBlobs(repo, tag string) (string, ImageBlobs, error) {
+	manifest, _, digest, err := c.GetManifest(repo, tag)
+	if err != nil {
+		return "", nil, err
+	}
+	blobs := ImageBlobs{digest: int64(len(manifest))}
+	ManifestBlobs(manifest, blobs)
+	for _, m := range gjson.Get(manifest, "manifests").Array() {
+		sub, _, subDigest, err := c.GetManifest(repo, m.Get("digest").String())
+		if err != nil {
+			return "", nil, err
+		}
+		blobs[subDigest] = int64(len(sub))
+		ManifestBlobs(sub, blobs)
+	}
+	return digest, blobs, nil
+}
+
+// EstimateReclaim estimate the space reclaimed by deleting the images: the blobs are shared by all repos
+// so only the ones not referenced by any kept image are freed by garbage collection.
+func EstimateReclaim(deleted, kept []ImageBlobs) Reclaim {
+	r := Reclaim{Tags: len(deleted)}
+	inUse := map[string]bool{}
+	for _, blobs := range kept {
+		for digest := range blobs {
+			inUse[digest] = true
+		}
+	}
+	freed := map[string]bool{}
+	for _, blobs := range deleted {
+		for digest, size := range blobs {
+			r.NaiveSize += size
+			if inUse[digest] || freed[digest] {
+				continue
+			}
+			freed[digest] = true
+			r.Size += size
+			r.Blobs++
+		}
+	}
+	return r
+}
+
+// EstimateTagsReclaim scan all tags of the registry and estimate the space reclaimed by deleting the tags by repo.
+// Deleting a tag deletes its manifest so the other tags of the repo pointing to the same digest go too,
+// the tags refused by the deletion guard are kept.
+func EstimateTagsReclaim(client *Client, deletions map[string][]string) (Reclaim, error) {
+	client.mux.Lock()
+	guard := client.deletionGuard
+	client.mux.Unlock()
+	type image struct {
+		repo, digest string
+		blobs        ImageBlobs
+	}
+	var images []image
+	deletedDigests := map[string]bool{}
+	for namespace, repos := range client.Repositories(true) {
+		for _, repo := range repos {
+			if namespace != "library" {
+				repo = fmt.Sprintf("%s/%s", namespace, repo)
+			}
+			for _, tag := range client.Tags(repo) {
+				digest, blobs, err := client.GetImageBlobs(repo, tag)
+				if err != nil {
+					return Reclaim{}, err
+				}
+				if ItemInSlice(tag, deletions[repo]) && (guard == nil || guard(repo, tag, digest) == nil) {
+					deletedDigests[repo+"@"+digest] = true
+				}
+				images = append(images, image{repo: repo, digest: digest, blobs: blobs})
+			}
+		}
+	}
+	var deleted, kept []ImageBlobs
+	for _, i := range images {
+		if deletedDigests[i.repo+"@"+i.digest] {
+			deleted = append(deleted, i.blobs)
+		} else {
+			kept = append(kept, i.blobs)
+		}
+	}
+	return EstimateReclaim(deleted, kept), nil
+}
@@ -0,0 +1,34 @@
+package registry
+
+import (
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestEstimateReclaim(t *testing.T) {
+	convey.Convey("Parse blobs of the image manifest", t, func() {
+		manifest := `{"config": {"digest": "sha256:c", "size": 10}, "layers": [
+			{"mediaType": "application/vnd.docker.image.rootfs.diff.tar.gzip", "digest": "sha256:a", "size": 100},
+			{"mediaType": "application/vnd.docker.image.rootfs.foreign.diff.tar.gzip", "digest": "sha256:w", "size": 5000}]}`
+		blobs := ImageBlobs{}
+		ManifestBlobs(manifest, blobs)
+		convey.So(blobs, convey.ShouldResemble, ImageBlobs{"sha256:c": 10, "sha256:a": 100})
+	})
+
+	convey.Convey("Count only blobs not referenced by the kept images", t, func() {
+		base := ImageBlobs{"sha256:base": 1000}
+		old1 := ImageBlobs{"sha256:base": 1000, "sha256:app1": 50, "sha256:cfg1": 1}
+		old2 := ImageBlobs{"sha256:base": 1000, "sha256:app2": 50, "sha256:cfg2": 1}
+		current := ImageBlobs{"sha256:base": 1000, "sha256:app2": 50, "sha256:cfg3": 1}
+
+		r := EstimateReclaim([]ImageBlobs{old1, old2}, []ImageBlobs{current})
+		convey.So(r, convey.ShouldResemble, Reclaim{Tags: 2, NaiveSize: 2102, Size: 52, Blobs: 3})
+
+		r = EstimateReclaim([]ImageBlobs{old1, old1}, []ImageBlobs{base})
+		convey.So(r, convey.ShouldResemble, Reclaim{Tags: 2, NaiveSize: 2102, Size: 51, Blobs: 2})
+
+		r = EstimateReclaim(nil, []ImageBlobs{base})
+		convey.So(r, convey.ShouldResemble, Reclaim{})
+	})
+}
@@ -0,0 +1,143 @@
+package registry
+
+import (
+	"fmt"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/tidwall/gjson"
+)
+
+// fullRefreshEvery every which refresh cycle all the repos are listed again regardless of the head check.
+const fullRefreshEvery = 12
+
+// listedTags tags of the repo from the previous refresh cycle.
+type listedTags struct {
+	tags []string
+	meta []TagMeta
+}
+
+// listedRepos tags of the repos from the previous refresh, the lock also prevents concurrent refreshes.
+type listedRepos struct {
+	mux    sync.Mutex
+	repos  map[string]listedTags
+	cycles int
+	// newTags is called with the tags appeared since the previous refresh by repo.
+	newTags func(repo string, tags []string)
+}
+
+// OnNewTags set the callback receiving the tags appeared since the previous refresh, e.g. to record them
+// for the feeds. Tags of the first refresh after start are not new.
+func (c *Client) OnNewTags(fn func(repo string, tags []string)) {
+	c.listed.mux.Lock()
+	c.listed.newTags = fn
+	c.listed.mux.Unlock()
+}
+
+// SetMetadataTTL set how long to cache tag metadata, usually the same as the tags refresh interval.
+func (c *Client) SetMetadataTTL(ttl time.Duration) {
+	c.meta.mux.Lock()
+	c.meta.ttl = ttl
+	c.meta.mux.Unlock()
+}
+
+// RefreshTags count tags of all repos and rebuild the search index, progress is reported after each repo.
+// Every fullRefreshEvery time or when forced all the repos are listed again.
+// Returns the count of repos and how many of them were unchanged since the previous refresh.
+func (c *Client) RefreshTags(force bool, progress func(done, total int)) (int, int) {
+	c.listed.mux.Lock()
+	defer c.listed.mux.Unlock()
+	full := force || c.listed.cycles%fullRefreshEvery == 0
+	c.listed.cycles++
+	listed, unchanged := c.refreshTags(c.listed.repos, full, progress)
+	if c.listed.repos != nil && c.listed.newTags != nil {
+		for _, repo := range SortedMapKeys(listed) {
+			if added := addedTags(c.listed.repos[repo].tags, listed[repo].tags); len(added) > 0 {
+				c.listed.newTags(repo, added)
+			}
+		}
+	}
+	c.listed.repos = listed
+	return len(listed), unchanged
+}
+
+// refreshTags list tags of all repos, count them and rebuild the search index, slowing down while registry is throttling.
+// Repos whose tags look unchanged since the previous cycle by the cheap head check are not listed again,
+// unless it is a full refresh or the repo was recently pushed to. Returns the tags listed and the count of unchanged repos.
+func (c *Client) refreshTags(prev map[string]listedTags, full bool, progress func(done, total int)) (map[string]listedTags, int) {
+	c.search.mux.RLock()
+	indexMetadata := c.indexMetadata
+	c.search.mux.RUnlock()
+	index := newSearchIndex()
+	listed := map[string]listedTags{}
+	unchanged := 0
+	repos := c.refreshOrder(c.Repositories(false))
+	for i, r := range repos {
+		c.throttle()
+		l, ok := prev[r.path]
+		if ok && !full && !r.pushed && c.tagsUnchanged(r.path, l.tags) {
+			unchanged++
+		} else {
+			l = listedTags{tags: c.Tags(r.path)}
+		}
+		if indexMetadata && l.meta == nil {
+			l.meta = c.TagsMetadata(r.path, l.tags)
+		}
+		if !indexMetadata {
+			l.meta = nil
+		}
+		listed[r.path] = l
+		c.setTagCount(r.path, len(l.tags), true)
+		index.addRepo(r.namespace, r.repo, l.tags, l.meta)
+		if progress != nil {
+			progress(i+1, len(repos))
+		}
+	}
+	c.setSearchIndex(index)
+	return listed, unchanged
+}
+
+// addedTags tags missing from the previous list.
+func addedTags(prev, tags []string) []string {
+	known := map[string]bool{}
+	for _, t := range prev {
+		known[t] = true
+	}
+	var added []string
+	for _, t := range tags {
+		if !known[t] {
+			added = append(added, t)
+		}
+	}
+	return added
+}
+
+// tagsUnchanged cheap check the repo has the same tags as listed before: the first tag is the same and
+// there are no tags after the last one. Tags changed in the middle are noticed by the next full refresh.
+func (c *Client) tagsUnchanged(repo string, tags []string) bool {
+	if len(tags) == 0 {
+		return false
+	}
+	first, err := c.tagsPage(repo, "n=1")
+	// More tags returned means the registry does not support pagination, there is nothing cheap to compare.
+	if err != nil || len(first) != 1 || first[0] != tags[0] {
+		return false
+	}
+	next, err := c.tagsPage(repo, "n=1&last="+url.QueryEscape(tags[len(tags)-1]))
+	return err == nil && len(next) == 0
+}
+
+// tagsPage get a page of tags of the repo.
+func (c *Client) tagsPage(repo, query string) ([]string, error) {
+	scope := fmt.Sprintf("repository:%s:*", repo)
+	data, resp := c.callRegistry(fmt.Sprintf("/v2/%s/tags/list?%s", repo, query), scope, "manifest.v2")
+	if resp == nil || resp.StatusCode != 200 {
+		return nil, fmt.Errorf("cannot list tags of %s", repo)
+	}
+	tags := []string{}
+	for _, t := range gjson.Get(data, "tags").Array() {
+		tags = append(tags, t.String())
+	}
+	return tags, nil
+}
@@ -0,0 +1,70 @@
+package registry
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestRefreshTags(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "refresh")
+	defer os.RemoveAll(dir)
+	fixture := filepath.Join(dir, "fixture.yml")
+	ioutil.WriteFile(fixture, []byte(`
+repositories:
+  alpine:
+    "3.12": {layers: [100]}
+    "3.13": {layers: [100]}
+  team/app:
+    "1.0": {layers: [10]}
+`), 0600)
+	mock, err := NewMockRegistry(fixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	url, err := mock.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewClient(url, true, "", "")
+
+	convey.Convey("Skip listing tags of unchanged repos", t, func() {
+		listed, unchanged := c.refreshTags(nil, false, nil)
+		convey.So(unchanged, convey.ShouldEqual, 0)
+		convey.So(listed["alpine"].tags, convey.ShouldResemble, []string{"3.12", "3.13"})
+
+		listed, unchanged = c.refreshTags(listed, false, nil)
+		convey.So(unchanged, convey.ShouldEqual, 2)
+
+		convey.So(c.CopyTag("alpine", "3.13", "alpine", "3.14"), convey.ShouldBeNil)
+		listed, unchanged = c.refreshTags(listed, false, nil)
+		convey.So(unchanged, convey.ShouldEqual, 1)
+		convey.So(listed["alpine"].tags, convey.ShouldResemble, []string{"3.12", "3.13", "3.14"})
+		convey.So(c.TagCounts()["library/alpine"], convey.ShouldEqual, 3)
+
+		listed, unchanged = c.refreshTags(listed, true, nil)
+		convey.So(unchanged, convey.ShouldEqual, 0)
+	})
+
+	convey.Convey("Report tags appeared since the previous refresh", t, func() {
+		added := map[string][]string{}
+		c.OnNewTags(func(repo string, tags []string) { added[repo] = tags })
+		c.RefreshTags(false, nil)
+		convey.So(added, convey.ShouldBeEmpty)
+
+		convey.So(c.CopyTag("team/app", "1.0", "team/app", "1.1"), convey.ShouldBeNil)
+		c.RefreshTags(true, nil)
+		convey.So(added, convey.ShouldResemble, map[string][]string{"team/app": {"1.1"}})
+		convey.So(addedTags([]string{"a", "b"}, []string{"b", "c", "a"}), convey.ShouldResemble, []string{"c"})
+	})
+
+	convey.Convey("Always list recently pushed repos", t, func() {
+		listed, _ := c.refreshTags(nil, false, nil)
+		c.RecentlyPushed(func() []string { return []string{"team/app"} })
+		_, unchanged := c.refreshTags(listed, false, nil)
+		convey.So(unchanged, convey.ShouldEqual, 1)
+	})
+}
@@ -0,0 +1,390 @@
+package registry
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Formats of the retention policy files of other cleanup tools.
+const (
+	RetentionFormatHarbor = "harbor"
+	RetentionFormatECR    = "ecr"
+	RetentionFormatGitLab = "gitlab"
+)
+
+// ImportedRetention rules converted from the policy file of another cleanup tool, with what could not be converted.
+type ImportedRetention struct {
+	Format   string
+	Rules    []ImportedRule
+	Warnings []string
+}
+
+// ImportedRule days and count of tags to keep and protected tag patterns of the repo, of all repos when it is empty.
+// Unset days and count are -1.
+type ImportedRule struct {
+	Repo      string
+	KeepDays  int
+	KeepCount int
+	Protected []string
+}
+
+// harborPolicy tag retention policy exported from Harbor API, see https://goharbor.io/docs/main/working-with-projects/working-with-images/create-tag-retention-rules/
+type harborPolicy struct {
+	Rules []struct {
+		Disabled     bool           `yaml:"disabled"`
+		Action       string         `yaml:"action"`
+		Template     string         `yaml:"template"`
+		Params       map[string]int `yaml:"params"`
+		TagSelectors []struct {
+			Decoration string `yaml:"decoration"`
+			Pattern    string `yaml:"pattern"`
+		} `yaml:"tag_selectors"`
+		ScopeSelectors map[string][]struct {
+			Decoration string `yaml:"decoration"`
+			Pattern    string `yaml:"pattern"`
+		} `yaml:"scope_selectors"`
+	} `yaml:"rules"`
+}
+
+// ecrPolicy lifecycle policy of Amazon ECR repo, see https://docs.aws.amazon.com/AmazonECR/latest/userguide/LifecyclePolicies.html
+type ecrPolicy struct {
+	Rules []struct {
+		RulePriority int    `yaml:"rulePriority"`
+		Description  string `yaml:"description"`
+		Selection    struct {
+			TagStatus      string   `yaml:"tagStatus"`
+			TagPrefixList  []string `yaml:"tagPrefixList"`
+			TagPatternList []string `yaml:"tagPatternList"`
+			CountType      string   `yaml:"countType"`
+			CountUnit      string   `yaml:"countUnit"`
+			CountNumber    int      `yaml:"countNumber"`
+		} `yaml:"selection"`
+		Action struct {
+			Type string `yaml:"type"`
+		} `yaml:"action"`
+	} `yaml:"rules"`
+}
+
+// gitlabPolicy cleanup policy of GitLab project as in its API, see https://docs.gitlab.com/ee/user/packages/container_registry/reduce_container_registry_storage.html
+type gitlabPolicy struct {
+	Enabled         *bool  `yaml:"enabled"`
+	KeepN           int    `yaml:"keep_n"`
+	OlderThan       string `yaml:"older_than"`
+	NameRegex       string `yaml:"name_regex"`
+	NameRegexDelete string `yaml:"name_regex_delete"`
+	NameRegexKeep   string `yaml:"name_regex_keep"`
+}
+
+// gitlabOlderThan older_than of GitLab cleanup policy, e.g. 90d.
+var gitlabOlderThan = regexp.MustCompile(`^(\d+)d$`)
+
+// ImportRetention convert retention policy file of Harbor, Amazon ECR or GitLab in JSON or YAML to the rules of the UI.
+// The rules without own repo scope apply to the repo when given, otherwise to all repos. Repo patterns of Harbor
+// are matched against the repos, with or without the namespace as Harbor patterns are relative to the project.
+func ImportRetention(data []byte, repo string, repos []string) (ImportedRetention, error) {
+	var doc map[string]interface{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return ImportedRetention{}, fmt.Errorf("cannot parse the file as JSON or YAML: %s", err)
+	}
+	if _, ok := doc["scripts"]; ok {
+		return ImportedRetention{}, fmt.Errorf("regbot scripts are Lua code and cannot be converted, " +
+			"rewrite them as days and count of tags to keep and protected tags")
+	}
+	for _, key := range []string{"container_expiration_policy_attributes", "container_expiration_policy"} {
+		if nested, ok := doc[key]; ok {
+			data, _ = yaml.Marshal(nested)
+			return importGitLab(data, repo)
+		}
+	}
+	if _, ok := doc["keep_n"]; ok {
+		return importGitLab(data, repo)
+	}
+	if _, ok := doc["older_than"]; ok {
+		return importGitLab(data, repo)
+	}
+	// Output of aws ecr get-lifecycle-policy has the policy as JSON string.
+	if text, ok := doc["lifecyclePolicyText"].(string); ok {
+		return ImportRetention([]byte(text), repo, repos)
+	}
+	rules, ok := doc["rules"].([]interface{})
+	if !ok {
+		return ImportedRetention{}, fmt.Errorf("unknown format, expected Harbor tag retention, Amazon ECR lifecycle or GitLab cleanup policy")
+	}
+	for _, r := range rules {
+		if m, ok := r.(map[interface{}]interface{}); ok && m["selection"] != nil {
+			return importECR(data, repo)
+		}
+	}
+	return importHarbor(data, repo, repos)
+}
+
+// newImportedRule rule with days and count unset.
+func newImportedRule(repo string) ImportedRule {
+	return ImportedRule{Repo: repo, KeepDays: -1, KeepCount: -1}
+}
+
+// keepMore set the value keeping more tags when several rules set it.
+func keepMore(value *int, n int) {
+	if n > *value {
+		*value = n
+	}
+}
+
+func importGitLab(data []byte, repo string) (ImportedRetention, error) {
+	var p gitlabPolicy
+	if err := yaml.Unmarshal(data, &p); err != nil {
+		return ImportedRetention{}, fmt.Errorf("cannot parse GitLab cleanup policy: %s", err)
+	}
+	imported := ImportedRetention{Format: RetentionFormatGitLab}
+	if p.Enabled != nil && !*p.Enabled {
+		imported.Warnings = append(imported.Warnings, "The policy is disabled in GitLab, its rules are imported anyway.")
+	}
+	r := newImportedRule(repo)
+	if p.KeepN > 0 {
+		r.KeepCount = p.KeepN
+	}
+	if p.OlderThan != "" {
+		m := gitlabOlderThan.FindStringSubmatch(p.OlderThan)
+		if m == nil {
+			return ImportedRetention{}, fmt.Errorf("older_than should be days, e.g. 90d, got %q", p.OlderThan)
+		}
+		r.KeepDays, _ = strconv.Atoi(m[1])
+	}
+	for _, expr := range []string{p.NameRegex, p.NameRegexDelete} {
+		if expr != "" && expr != ".*" {
+			imported.Warnings = append(imported.Warnings, fmt.Sprintf("Tags to delete %q are not converted, the rules apply to all tags.", expr))
+		}
+	}
+	if p.NameRegexKeep != "" {
+		patterns, ok := regexToPatterns(p.NameRegexKeep)
+		if !ok {
+			imported.Warnings = append(imported.Warnings, fmt.Sprintf("name_regex_keep %q cannot be converted to tag patterns, set protected tags by hand.", p.NameRegexKeep))
+		} else {
+			r.Protected = patterns
+		}
+	}
+	imported.Rules = append(imported.Rules, r)
+	return imported, nil
+}
+
+func importECR(data []byte, repo string) (ImportedRetention, error) {
+	var p ecrPolicy
+	if err := yaml.Unmarshal(data, &p); err != nil {
+		return ImportedRetention{}, fmt.Errorf("cannot parse Amazon ECR lifecycle policy: %s", err)
+	}
+	imported := ImportedRetention{Format: RetentionFormatECR}
+	r := newImportedRule(repo)
+	for _, rule := range p.Rules {
+		name := fmt.Sprintf("Rule %d", rule.RulePriority)
+		s := rule.Selection
+		if s.TagStatus == "untagged" {
+			imported.Warnings = append(imported.Warnings, name+" expires untagged images, they are removed by the registry garbage collection.")
+			continue
+		}
+		if len(s.TagPrefixList) > 0 || len(s.TagPatternList) > 0 {
+			imported.Warnings = append(imported.Warnings, name+" selects tags by prefix or pattern, it is imported for all tags.")
+		}
+		switch s.CountType {
+		case "imageCountMoreThan":
+			keepMore(&r.KeepCount, s.CountNumber)
+		case "sinceImagePushed":
+			if s.CountUnit != "days" {
+				imported.Warnings = append(imported.Warnings, fmt.Sprintf("%s counts %s, only days are supported.", name, s.CountUnit))
+				continue
+			}
+			keepMore(&r.KeepDays, s.CountNumber)
+		default:
+			imported.Warnings = append(imported.Warnings, fmt.Sprintf("%s has unknown countType %q.", name, s.CountType))
+		}
+	}
+	if r.KeepDays >= 0 && r.KeepCount >= 0 {
+		imported.Warnings = append(imported.Warnings, "ECR expires images matching any rule, purging keeps the tags within "+
+			"the days to keep or among the count to keep, so the combined rules keep more tags than in ECR.")
+	}
+	imported.Rules = append(imported.Rules, r)
+	return imported, nil
+}
+
+func importHarbor(data []byte, repo string, repos []string) (ImportedRetention, error) {
+	var p harborPolicy
+	if err := yaml.Unmarshal(data, &p); err != nil {
+		return ImportedRetention{}, fmt.Errorf("cannot parse Harbor tag retention policy: %s", err)
+	}
+	imported := ImportedRetention{Format: RetentionFormatHarbor}
+	rules := map[string]*ImportedRule{}
+	for i, rule := range p.Rules {
+		name := fmt.Sprintf("Rule %d", i+1)
+		if rule.Disabled {
+			imported.Warnings = append(imported.Warnings, name+" is disabled, skipped.")
+			continue
+		}
+		if rule.Action != "" && rule.Action != "retain" {
+			imported.Warnings = append(imported.Warnings, fmt.Sprintf("%s has unknown action %q, skipped.", name, rule.Action))
+			continue
+		}
+
+		// Repos of the rule, the given repo or all of them for "**" pattern.
+		targets := []string{repo}
+		for _, s := range rule.ScopeSelectors["repository"] {
+			if s.Pattern == "**" && s.Decoration != "repoExcludes" {
+				continue
+			}
+			if s.Decoration == "repoExcludes" {
+				imported.Warnings = append(imported.Warnings, fmt.Sprintf("%s excludes repos %s, it is imported for all repos.", name, s.Pattern))
+				continue
+			}
+			targets = matchRepos(s.Pattern, repos)
+			if len(targets) == 0 {
+				imported.Warnings = append(imported.Warnings, fmt.Sprintf("%s matches no repos by %s, skipped.", name, s.Pattern))
+			}
+		}
+
+		var tagPatterns []string
+		for _, s := range rule.TagSelectors {
+			if s.Pattern == "**" || s.Pattern == "*" {
+				continue
+			}
+			if s.Decoration == "excludes" {
+				imported.Warnings = append(imported.Warnings, fmt.Sprintf("%s excludes tags %s, it is imported for all tags.", name, s.Pattern))
+				continue
+			}
+			tagPatterns = append(tagPatterns, expandBraces(strings.Replace(s.Pattern, "**", "*", -1))...)
+		}
+
+		for _, target := range targets {
+			r, ok := rules[target]
+			if !ok {
+				nr := newImportedRule(target)
+				r = &nr
+				rules[target] = r
+			}
+			switch rule.Template {
+			case "always":
+				if len(tagPatterns) == 0 {
+					imported.Warnings = append(imported.Warnings, name+" retains all tags, set the days and count to keep to 0 to stop purging instead.")
+					continue
+				}
+				r.Protected = appendMissing(r.Protected, tagPatterns...)
+			case "latestPushedK", "latestPulledN", "nDaysSinceLastPush", "nDaysSinceLastPull":
+				if len(tagPatterns) > 0 {
+					imported.Warnings = append(imported.Warnings, fmt.Sprintf("%s selects tags %s, it is imported for all tags.", name, strings.Join(tagPatterns, ", ")))
+				}
+				if strings.Contains(rule.Template, "Pull") {
+					imported.Warnings = append(imported.Warnings, name+" counts pulls, the UI purges by push time.")
+				}
+				if strings.HasPrefix(rule.Template, "latest") {
+					keepMore(&r.KeepCount, rule.Params[rule.Template])
+				} else {
+					keepMore(&r.KeepDays, rule.Params[rule.Template])
+				}
+			default:
+				imported.Warnings = append(imported.Warnings, fmt.Sprintf("%s has unsupported template %q, skipped.", name, rule.Template))
+			}
+		}
+	}
+	for _, target := range SortedMapKeys(rules) {
+		imported.Rules = append(imported.Rules, *rules[target])
+	}
+	return imported, nil
+}
+
+// matchRepos repos matching doublestar pattern of Harbor with or without the namespace.
+func matchRepos(pattern string, repos []string) []string {
+	var list []string
+	re := globRegexp(pattern)
+	for _, r := range repos {
+		short := r
+		if i := strings.Index(r, "/"); i >= 0 {
+			short = r[i+1:]
+		}
+		if re.MatchString(r) || re.MatchString(short) {
+			list = append(list, r)
+		}
+	}
+	sort.Strings(list)
+	return list
+}
+
+// globRegexp regexp of doublestar pattern: ** matches across slashes, * and ? do not, {a,b} matches any of the items.
+func globRegexp(pattern string) *regexp.Regexp {
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch ch := pattern[i]; ch {
+		case '*':
+			if i+1 < len(pattern) && pattern[i+1] == '*' {
+				b.WriteString(".*")
+				i++
+			} else {
+				b.WriteString("[^/]*")
+			}
+		case '?':
+			b.WriteString("[^/]")
+		case '{':
+			b.WriteString("(?:")
+		case '}':
+			b.WriteString(")")
+		case ',':
+			b.WriteString("|")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(ch)))
+		}
+	}
+	b.WriteString("$")
+	re, err := regexp.Compile(b.String())
+	if err != nil {
+		return regexp.MustCompile(`^` + regexp.QuoteMeta(pattern) + `$`)
+	}
+	return re
+}
+
+// expandBraces patterns without {a,b} alternatives, as the protected tags are matched by path.Match.
+func expandBraces(pattern string) []string {
+	start := strings.Index(pattern, "{")
+	end := strings.Index(pattern, "}")
+	if start < 0 || end < start {
+		return []string{pattern}
+	}
+	var list []string
+	for _, item := range strings.Split(pattern[start+1:end], ",") {
+		list = append(list, expandBraces(pattern[:start]+item+pattern[end+1:])...)
+	}
+	return list
+}
+
+// simpleRegexp literal text with .* wildcards, e.g. ^v.*-stable$
+var simpleRegexp = regexp.MustCompile(`^(?:[A-Za-z0-9_-]|\\\.|\.\*)+$`)
+
+// regexToPatterns tag patterns of the regexp made of alternatives of literals and .* wildcards, e.g. (latest|v.*).
+// GitLab matches the regexp against the whole tag.
+func regexToPatterns(expr string) ([]string, bool) {
+	expr = strings.TrimSuffix(strings.TrimPrefix(expr, "^"), "$")
+	if strings.HasPrefix(expr, "(") && strings.HasSuffix(expr, ")") {
+		expr = strings.TrimPrefix(strings.TrimSuffix(expr, ")"), "(")
+		expr = strings.TrimPrefix(expr, "?:")
+	}
+	var patterns []string
+	for _, alt := range strings.Split(expr, "|") {
+		alt = strings.TrimSuffix(strings.TrimPrefix(alt, "^"), "$")
+		if !simpleRegexp.MatchString(alt) {
+			return nil, false
+		}
+		patterns = append(patterns, strings.Replace(strings.Replace(alt, ".*", "*", -1), `\.`, ".", -1))
+	}
+	return patterns, true
+}
+
+// appendMissing append the items not in the list yet.
+func appendMissing(list []string, items ...string) []string {
+	for _, item := range items {
+		if !ItemInSlice(item, list) {
+			list = append(list, item)
+		}
+	}
+	return list
+}
@@ -0,0 +1,68 @@
+package registry
+
+import (
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+const harborRetention = `{
+	"algorithm": "or",
+	"rules": [
+		{"action": "retain", "template": "latestPushedK", "params": {"latestPushedK": 10},
+		 "tag_selectors": [{"kind": "doublestar", "decoration": "matches", "pattern": "**"}],
+		 "scope_selectors": {"repository": [{"kind": "doublestar", "decoration": "repoMatches", "pattern": "**"}]}},
+		{"action": "retain", "template": "nDaysSinceLastPush", "params": {"nDaysSinceLastPush": 30},
+		 "tag_selectors": [{"kind": "doublestar", "decoration": "matches", "pattern": "**"}],
+		 "scope_selectors": {"repository": [{"kind": "doublestar", "decoration": "repoMatches", "pattern": "app*"}]}},
+		{"action": "retain", "template": "always",
+		 "tag_selectors": [{"kind": "doublestar", "decoration": "matches", "pattern": "{latest,v*}"}],
+		 "scope_selectors": {"repository": [{"kind": "doublestar", "decoration": "repoMatches", "pattern": "team/app"}]}}
+	]
+}`
+
+func TestImportRetention(t *testing.T) {
+	repos := []string{"team/app", "team/worker", "alpine"}
+
+	convey.Convey("Convert Harbor retention rules by repo", t, func() {
+		r, err := ImportRetention([]byte(harborRetention), "", repos)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(r.Format, convey.ShouldEqual, RetentionFormatHarbor)
+		convey.So(r.Rules, convey.ShouldResemble, []ImportedRule{
+			{Repo: "", KeepDays: -1, KeepCount: 10},
+			{Repo: "team/app", KeepDays: 30, KeepCount: -1, Protected: []string{"latest", "v*"}},
+		})
+		convey.So(r.Warnings, convey.ShouldBeEmpty)
+	})
+
+	convey.Convey("Convert Amazon ECR lifecycle policy for the repo", t, func() {
+		policy := `{"lifecyclePolicyText": "{\"rules\": [` +
+			`{\"rulePriority\": 1, \"selection\": {\"tagStatus\": \"untagged\", \"countType\": \"sinceImagePushed\", \"countUnit\": \"days\", \"countNumber\": 1}, \"action\": {\"type\": \"expire\"}},` +
+			`{\"rulePriority\": 2, \"selection\": {\"tagStatus\": \"any\", \"countType\": \"imageCountMoreThan\", \"countNumber\": 20}, \"action\": {\"type\": \"expire\"}}]}"}`
+		r, err := ImportRetention([]byte(policy), "team/app", repos)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(r.Format, convey.ShouldEqual, RetentionFormatECR)
+		convey.So(r.Rules, convey.ShouldResemble, []ImportedRule{{Repo: "team/app", KeepDays: -1, KeepCount: 20}})
+		convey.So(len(r.Warnings), convey.ShouldEqual, 1)
+	})
+
+	convey.Convey("Convert GitLab cleanup policy in YAML", t, func() {
+		policy := "container_expiration_policy_attributes:\n  enabled: true\n  keep_n: 5\n  older_than: 90d\n  name_regex_keep: '^(latest|v\\d+.*)$'\n"
+		r, err := ImportRetention([]byte(policy), "", repos)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(r.Format, convey.ShouldEqual, RetentionFormatGitLab)
+		convey.So(r.Rules, convey.ShouldResemble, []ImportedRule{{KeepDays: 90, KeepCount: 5}})
+		convey.So(len(r.Warnings), convey.ShouldEqual, 1)
+
+		r, err = ImportRetention([]byte(`{"keep_n": 10, "name_regex_keep": "(latest|release-.*)"}`), "", repos)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(r.Rules, convey.ShouldResemble, []ImportedRule{{KeepDays: -1, KeepCount: 10, Protected: []string{"latest", "release-*"}}})
+	})
+
+	convey.Convey("Refuse regbot scripts and unknown files", t, func() {
+		_, err := ImportRetention([]byte("version: 1\nscripts:\n  - name: cleanup\n    script: tag.ls()\n"), "", repos)
+		convey.So(err, convey.ShouldNotBeNil)
+		_, err = ImportRetention([]byte("foo: bar\n"), "", repos)
+		convey.So(err, convey.ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,230 @@
+package registry
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// Weights of the fields in search results ranking.
+const (
+	weightRepo       = 4
+	weightTag        = 3
+	weightLabel      = 1
+	weightAnnotation = 1
+	// An exact term match counts more than a prefix match.
+	exactMatchFactor = 3
+)
+
+// SearchResult repository or tag matching the search query.
+type SearchResult struct {
+	Namespace string   `json:"namespace"`
+	Repo      string   `json:"repo"`
+	Tag       string   `json:"tag,omitempty"`
+	Score     int      `json:"score"`
+	Matches   []string `json:"matches"`
+}
+
+type searchDoc struct {
+	namespace string
+	repo      string
+	tag       string
+}
+
+type posting struct {
+	doc    int
+	field  string
+	weight int
+}
+
+// SearchIndex inverted index over repo names, tag names, labels and annotations.
+type SearchIndex struct {
+	docs  []searchDoc
+	terms map[string][]posting
+	// Sorted terms for prefix lookups.
+	sorted []string
+	built  time.Time
+}
+
+// searchIndex the index currently used, it is replaced as a whole when rebuilt.
+type searchIndex struct {
+	mux   sync.RWMutex
+	index *SearchIndex
+}
+
+// newSearchIndex create empty index.
+func newSearchIndex() *SearchIndex {
+	return &SearchIndex{terms: map[string][]posting{}}
+}
+
+// tokenize split text into lowercase alphanumeric terms.
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// addDoc add repo or tag to the index, fields are field name to text to index.
+func (s *SearchIndex) addDoc(doc searchDoc, fields map[string]string, weights map[string]int) {
+	id := len(s.docs)
+	s.docs = append(s.docs, doc)
+	for field, text := range fields {
+		seen := map[string]bool{}
+		for _, term := range tokenize(text) {
+			if seen[term] {
+				continue
+			}
+			seen[term] = true
+			s.terms[term] = append(s.terms[term], posting{doc: id, field: field, weight: weights[field]})
+		}
+	}
+}
+
+// addRepo index the repo with its tags and their metadata, meta can be nil.
+func (s *SearchIndex) addRepo(namespace, repo string, tags []string, meta []TagMeta) {
+	weights := map[string]int{"repo": weightRepo}
+	s.addDoc(searchDoc{namespace: namespace, repo: repo}, map[string]string{"repo": namespace + "/" + repo}, weights)
+	for i, tag := range tags {
+		// Repo name is indexed with lower weight to find tags by "repo tag" queries.
+		fields := map[string]string{"tag": tag, "repo": namespace + "/" + repo}
+		weights := map[string]int{"tag": weightTag, "repo": 1}
+		if i < len(meta) {
+			for k, v := range meta[i].Labels {
+				fields["label "+k] = k + " " + v
+				weights["label "+k] = weightLabel
+			}
+			for k, v := range meta[i].Annotations {
+				fields["annotation "+k] = k + " " + v
+				weights["annotation "+k] = weightAnnotation
+			}
+		}
+		s.addDoc(searchDoc{namespace: namespace, repo: repo, tag: tag}, fields, weights)
+	}
+}
+
+// finish prepare the index for searching.
+func (s *SearchIndex) finish() {
+	s.sorted = make([]string, 0, len(s.terms))
+	for term := range s.terms {
+		s.sorted = append(s.sorted, term)
+	}
+	sort.Strings(s.sorted)
+	s.built = time.Now()
+}
+
+// Search find repos and tags matching all the terms of the query, the best matches first.
+// Query terms match index terms exactly or by prefix.
+func (s *SearchIndex) Search(query string, limit int) []SearchResult {
+	return s.search(query, limit, func(string) bool { return true })
+}
+
+// Suggest find repos and tags by names only for typeahead, e.g. "team/app:v1" or "app v1".
+func (s *SearchIndex) Suggest(query string, limit int) []SearchResult {
+	return s.search(query, limit, func(field string) bool { return field == "repo" || field == "tag" })
+}
+
+// search find docs matching all the terms of the query in the fields accepted by the filter.
+func (s *SearchIndex) search(query string, limit int, filter func(field string) bool) []SearchResult {
+	results := []SearchResult{}
+	terms := tokenize(query)
+	if len(terms) == 0 {
+		return results
+	}
+
+	var scores map[int]int
+	matches := map[int]map[string]bool{}
+	for _, term := range terms {
+		termScores := map[int]int{}
+		i := sort.SearchStrings(s.sorted, term)
+		for ; i < len(s.sorted) && strings.HasPrefix(s.sorted[i], term); i++ {
+			factor := 1
+			if s.sorted[i] == term {
+				factor = exactMatchFactor
+			}
+			for _, p := range s.terms[s.sorted[i]] {
+				if !filter(p.field) {
+					continue
+				}
+				if scores != nil {
+					if _, ok := scores[p.doc]; !ok {
+						continue
+					}
+				}
+				termScores[p.doc] = termScores[p.doc] + p.weight*factor
+				if matches[p.doc] == nil {
+					matches[p.doc] = map[string]bool{}
+				}
+				matches[p.doc][p.field] = true
+			}
+		}
+		// All the terms should match.
+		if scores != nil {
+			for doc, score := range termScores {
+				termScores[doc] = score + scores[doc]
+			}
+		}
+		scores = termScores
+	}
+
+	for doc, score := range scores {
+		d := s.docs[doc]
+		r := SearchResult{Namespace: d.namespace, Repo: d.repo, Tag: d.tag, Score: score}
+		for field := range matches[doc] {
+			r.Matches = append(r.Matches, field)
+		}
+		sort.Strings(r.Matches)
+		results = append(results, r)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		if results[i].Namespace+"/"+results[i].Repo != results[j].Namespace+"/"+results[j].Repo {
+			return results[i].Namespace+"/"+results[i].Repo < results[j].Namespace+"/"+results[j].Repo
+		}
+		return results[i].Tag < results[j].Tag
+	})
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
+
+// Search find repos and tags in the search index, it is empty until the first tag count is complete.
+func (c *Client) Search(query string, limit int) ([]SearchResult, time.Time) {
+	c.search.mux.RLock()
+	index := c.search.index
+	c.search.mux.RUnlock()
+	if index == nil {
+		return []SearchResult{}, time.Time{}
+	}
+	return index.Search(query, limit), index.built
+}
+
+// Suggest find repos and tags by names in the search index for typeahead.
+func (c *Client) Suggest(query string, limit int) []SearchResult {
+	c.search.mux.RLock()
+	index := c.search.index
+	c.search.mux.RUnlock()
+	if index == nil {
+		return []SearchResult{}
+	}
+	return index.Suggest(query, limit)
+}
+
+// IndexMetadata enable indexing of image labels and annotations for search, it requires fetching metadata of all tags.
+func (c *Client) IndexMetadata(enabled bool) {
+	c.search.mux.Lock()
+	c.indexMetadata = enabled
+	c.search.mux.Unlock()
+}
+
+// setSearchIndex replace the search index with the new one.
+func (c *Client) setSearchIndex(index *SearchIndex) {
+	index.finish()
+	c.search.mux.Lock()
+	c.search.index = index
+	c.search.mux.Unlock()
+}
@@ -0,0 +1,57 @@
+package registry
+
+import (
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestSearchIndex(t *testing.T) {
+	index := newSearchIndex()
+	index.addRepo("library", "alpine", []string{"3.12", "latest"}, nil)
+	index.addRepo("team", "app", []string{"v1.0.0"}, []TagMeta{
+		{Labels: map[string]string{"org.opencontainers.image.source": "https://github.com/acme/app"}},
+	})
+	index.addRepo("team", "alpine-tools", []string{"1.0"}, nil)
+	index.finish()
+
+	convey.Convey("Exact match ranks above prefix match", t, func() {
+		res := index.Search("alpine", 10)
+		convey.So(len(res), convey.ShouldEqual, 5)
+		convey.So(res[0], convey.ShouldResemble, SearchResult{Namespace: "library", Repo: "alpine", Score: 12, Matches: []string{"repo"}})
+		convey.So(res[1].Repo, convey.ShouldEqual, "alpine-tools")
+		convey.So(res[1].Tag, convey.ShouldEqual, "")
+	})
+
+	convey.Convey("All terms should match", t, func() {
+		res := index.Search("alpine latest", 10)
+		convey.So(len(res), convey.ShouldEqual, 1)
+		convey.So(res[0].Tag, convey.ShouldEqual, "latest")
+
+		convey.So(index.Search("alpine acme", 10), convey.ShouldBeEmpty)
+		convey.So(index.Search(" ", 10), convey.ShouldBeEmpty)
+	})
+
+	convey.Convey("Find tags by labels", t, func() {
+		res := index.Search("ACME", 10)
+		convey.So(len(res), convey.ShouldEqual, 1)
+		convey.So(res[0].Tag, convey.ShouldEqual, "v1.0.0")
+		convey.So(res[0].Matches, convey.ShouldResemble, []string{"label org.opencontainers.image.source"})
+	})
+
+	convey.Convey("Suggest by names only", t, func() {
+		res := index.Suggest("team/app:v1", 10)
+		convey.So(len(res), convey.ShouldEqual, 1)
+		convey.So(res[0].Tag, convey.ShouldEqual, "v1.0.0")
+
+		res = index.Suggest("alp", 10)
+		convey.So(len(res), convey.ShouldEqual, 5)
+		convey.So(res[0].Tag, convey.ShouldEqual, "")
+
+		convey.So(index.Suggest("acme", 10), convey.ShouldBeEmpty)
+	})
+
+	convey.Convey("Limit results", t, func() {
+		convey.So(len(index.Search("alpine", 2)), convey.ShouldEqual, 2)
+	})
+}
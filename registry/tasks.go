@@ -31,14 +31,17 @@ func (p timeSlice) Swap(i, j int) {
 	p[i], p[j] = p[j], p[i]
 }
 
-// PurgeOldTags purge old tags.
-func PurgeOldTags(client *Client, purgeDryRun bool, purgeTagsKeepDays, purgeTagsKeepCount int) {
+// PurgeOldTags purge old tags by the days and count of tags to keep of each repo,
+// returns the tags selected for purging by repo.
+func PurgeOldTags(client *Client, purgeDryRun bool, rules func(repo string) (keepDays, keepCount int)) map[string][]string {
+	purgeTags := SelectOldTags(client, rules)
+	PurgeTags(client, purgeDryRun, purgeTags)
+	return purgeTags
+}
+
+// SelectOldTags select old tags for purging by the days and count of tags to keep of each repo.
+func SelectOldTags(client *Client, rules func(repo string) (keepDays, keepCount int)) map[string][]string {
 	logger := SetupLogging("registry.tasks.PurgeOldTags")
-	dryRunText := ""
-	if purgeDryRun {
-		logger.Warn("Dry-run mode enabled.")
-		dryRunText = "skipped"
-	}
 	logger.Info("Scanning registry for repositories, tags and their creation dates...")
 	catalog := client.Repositories(true)
 	// catalog := map[string][]string{"library": []string{""}}
@@ -82,6 +85,7 @@ func PurgeOldTags(client *Client, purgeDryRun bool, purgeTagsKeepDays, purgeTags
 		}
 		sort.Sort(sortedTags)
 		repos[repo] = sortedTags
+		purgeTagsKeepDays, purgeTagsKeepCount := rules(repo)
 
 		// Filter out tags by retention days.
 		for _, tag := range repos[repo] {
@@ -111,7 +115,18 @@ func PurgeOldTags(client *Client, purgeDryRun bool, purgeTagsKeepDays, purgeTags
 	}
 
 	logger.Infof("There are %d tags to purge.", count)
-	if count > 0 {
+	return purgeTags
+}
+
+// PurgeTags delete the tags by repo.
+func PurgeTags(client *Client, purgeDryRun bool, purgeTags map[string][]string) {
+	logger := SetupLogging("registry.tasks.PurgeOldTags")
+	dryRunText := ""
+	if purgeDryRun {
+		logger.Warn("Dry-run mode enabled.")
+		dryRunText = "skipped"
+	}
+	if len(purgeTags) > 0 {
 		logger.Info("Purging old tags...")
 	}
 
@@ -121,7 +136,9 @@ func PurgeOldTags(client *Client, purgeDryRun bool, purgeTagsKeepDays, purgeTags
 			continue
 		}
 		for _, tag := range purgeTags[repo] {
-			client.DeleteTag(repo, tag)
+			if err := client.DeleteTag(repo, tag); err != nil {
+				logger.Warnf("[%s] Cannot purge tag %s: %s", repo, tag, err)
+			}
 		}
 	}
 	logger.Info("Done.")
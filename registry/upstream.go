@@ -0,0 +1,192 @@
+package registry
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// upstreamDigestTTL how long to keep upstream digests, Docker Hub limits the request rate.
+const upstreamDigestTTL = 10 * time.Minute
+
+// Upstream statuses of the cached tag.
+const (
+	UpstreamFresh   = "fresh"
+	UpstreamStale   = "stale"
+	UpstreamMissing = "missing"
+	UpstreamUnknown = "unknown"
+)
+
+// UpstreamStatus the tag cached by pull-through cache compared with the upstream one.
+type UpstreamStatus struct {
+	Tag            string    `json:"tag"`
+	CachedDigest   string    `json:"cached_digest"`
+	UpstreamDigest string    `json:"upstream_digest"`
+	Checked        time.Time `json:"checked"`
+	Error          string    `json:"error,omitempty"`
+}
+
+// Status fresh if the digests are the same, stale if they differ, missing if the tag is gone upstream,
+// unknown if the upstream could not be checked.
+func (s UpstreamStatus) Status() string {
+	switch {
+	case s.Error != "" || s.CachedDigest == "":
+		return UpstreamUnknown
+	case s.UpstreamDigest == "":
+		return UpstreamMissing
+	case s.UpstreamDigest != s.CachedDigest:
+		return UpstreamStale
+	}
+	return UpstreamFresh
+}
+
+type upstreamDigest struct {
+	digest  string
+	err     error
+	checked time.Time
+}
+
+// Upstream registry the target registry is a pull-through cache of, e.g. registry:2 with proxy.remoteurl.
+type Upstream struct {
+	url       string
+	host      string
+	username  string
+	password  string
+	dockerHub bool
+	mux       sync.Mutex
+	client    *Client
+	digests   map[string]upstreamDigest
+	// checked the latest statuses by repo.
+	checked map[string][]UpstreamStatus
+}
+
+// NewUpstream create upstream registry, it is connected on the first use.
+func NewUpstream(remoteURL, username, password string) *Upstream {
+	u, _ := url.Parse(remoteURL)
+	host := ""
+	if u != nil {
+		host = u.Host
+	}
+	return &Upstream{
+		url:       strings.TrimRight(remoteURL, "/"),
+		host:      host,
+		username:  username,
+		password:  password,
+		dockerHub: host == "registry-1.docker.io" || host == "index.docker.io" || host == "docker.io",
+		digests:   map[string]upstreamDigest{},
+		checked:   map[string][]UpstreamStatus{},
+	}
+}
+
+// path of the repo upstream, official Docker Hub images are under library/ there.
+func (u *Upstream) path(repo string) string {
+	if u.dockerHub && !strings.Contains(repo, "/") {
+		return "library/" + repo
+	}
+	return repo
+}
+
+// Reference name of the upstream repo to pull it directly, e.g. docker.io/library/alpine.
+func (u *Upstream) Reference(repo string) string {
+	if u.dockerHub {
+		return "docker.io/" + u.path(repo)
+	}
+	return u.host + "/" + repo
+}
+
+// connect create the upstream client if it is not yet.
+func (u *Upstream) connect() (*Client, error) {
+	u.mux.Lock()
+	defer u.mux.Unlock()
+	if u.client == nil {
+		c := NewClient(u.url, true, u.username, u.password)
+		if err := c.Connect(); err != nil {
+			return nil, fmt.Errorf("cannot connect to upstream %s: %s", u.url, err)
+		}
+		u.client = c
+	}
+	return u.client, nil
+}
+
+// digest of the upstream tag from cache or by HEAD request which is not counted by Docker Hub pull limits.
+func (u *Upstream) digest(repo, tag string) (string, error) {
+	key := repo + ":" + tag
+	u.mux.Lock()
+	d, ok := u.digests[key]
+	u.mux.Unlock()
+	if ok && time.Now().Sub(d.checked) < upstreamDigestTTL {
+		return d.digest, d.err
+	}
+
+	d = upstreamDigest{checked: time.Now()}
+	c, err := u.connect()
+	if err == nil {
+		d.digest, d.err = c.ManifestDigest(u.path(repo), tag)
+	} else {
+		d.err = err
+	}
+	u.mux.Lock()
+	u.digests[key] = d
+	u.mux.Unlock()
+	return d.digest, d.err
+}
+
+// Compare the cached tags with upstream ones.
+func (u *Upstream) Compare(repo string, metas []TagMeta) []UpstreamStatus {
+	list := make([]UpstreamStatus, len(metas))
+	queue := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < metaWorkers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range queue {
+				s := UpstreamStatus{Tag: metas[i].Tag, CachedDigest: metas[i].Digest, Checked: time.Now()}
+				digest, err := u.digest(repo, metas[i].Tag)
+				if err != nil {
+					s.Error = err.Error()
+				}
+				s.UpstreamDigest = digest
+				list[i] = s
+			}
+		}()
+	}
+	for i := range metas {
+		queue <- i
+	}
+	close(queue)
+	wg.Wait()
+
+	u.mux.Lock()
+	u.checked[repo] = list
+	u.mux.Unlock()
+	return list
+}
+
+// Checked the latest statuses of the repo tags, nil if not checked yet.
+func (u *Upstream) Checked(repo string) []UpstreamStatus {
+	u.mux.Lock()
+	defer u.mux.Unlock()
+	return u.checked[repo]
+}
+
+// ManifestDigest digest of the manifest by HEAD request, empty if the manifest does not exist.
+func (c *Client) ManifestDigest(repo, reference string) (string, error) {
+	scope := fmt.Sprintf("repository:%s:pull", repo)
+	header := http.Header{"Accept": {strings.Join(manifestMediaTypes, ", ")}}
+	resp, err := c.do("HEAD", fmt.Sprintf("/v2/%s/manifests/%s", repo, reference), scope, header, nil)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	switch resp.StatusCode {
+	case 200:
+		return resp.Header.Get("Docker-Content-Digest"), nil
+	case 404:
+		return "", nil
+	}
+	return "", fmt.Errorf("cannot check manifest %s:%s: %s", repo, reference, resp.Status)
+}
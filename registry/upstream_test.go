@@ -0,0 +1,48 @@
+package registry
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestUpstream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/":
+		case "/v2/team/app/manifests/v1":
+			w.Header().Set("Docker-Content-Digest", "sha256:aaa")
+		case "/v2/team/app/manifests/v2":
+			w.Header().Set("Docker-Content-Digest", "sha256:ccc")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	convey.Convey("Compare cached tags with upstream", t, func() {
+		u := NewUpstream(server.URL, "", "")
+		convey.So(u.Checked("team/app"), convey.ShouldBeNil)
+		list := u.Compare("team/app", []TagMeta{
+			{Tag: "v1", Digest: "sha256:aaa"},
+			{Tag: "v2", Digest: "sha256:bbb"},
+			{Tag: "v3", Digest: "sha256:ddd"},
+			{Tag: "v4"},
+		})
+		convey.So(list[0].Status(), convey.ShouldEqual, UpstreamFresh)
+		convey.So(list[1].Status(), convey.ShouldEqual, UpstreamStale)
+		convey.So(list[1].UpstreamDigest, convey.ShouldEqual, "sha256:ccc")
+		convey.So(list[2].Status(), convey.ShouldEqual, UpstreamMissing)
+		convey.So(list[3].Status(), convey.ShouldEqual, UpstreamUnknown)
+		convey.So(u.Checked("team/app"), convey.ShouldHaveLength, 4)
+	})
+
+	convey.Convey("Upstream references", t, func() {
+		hub := NewUpstream("https://registry-1.docker.io", "", "")
+		convey.So(hub.Reference("alpine"), convey.ShouldEqual, "docker.io/library/alpine")
+		convey.So(hub.Reference("grafana/grafana"), convey.ShouldEqual, "docker.io/grafana/grafana")
+		convey.So(NewUpstream("https://quay.io/", "", "").Reference("alpine"), convey.ShouldEqual, "quay.io/alpine")
+	})
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/quiq/docker-registry-ui/registry"
+)
+
+// copyImages copy all tags of the repo or a single tag to another name, optionally deleting the originals.
+// Registries do not support renaming, so this is how the rename is done.
+func (a *apiClient) copyImages(c echo.Context) error {
+	data := a.setUserPermissions(c)
+
+	srcRepo := strings.Trim(c.FormValue("src_repo"), "/")
+	srcTag := c.FormValue("src_tag")
+	dstRepo := strings.Trim(c.FormValue("dst_repo"), "/")
+	dstTag := c.FormValue("dst_tag")
+	deleteOriginals := c.FormValue("delete") != ""
+	reason := strings.TrimSpace(c.FormValue("reason"))
+	if deleteOriginals && !data["deleteAllowed"].Bool() {
+		return a.forbidden(c, permissionMessages[permDelete])
+	}
+	if deleteOriginals && a.config.DeleteReasonRequired && reason == "" {
+		return c.String(http.StatusBadRequest, "Reason for deleting the originals is required.")
+	}
+	for _, repo := range []string{srcRepo, dstRepo} {
+		if !registry.ValidRepoName(repo) {
+			return c.String(http.StatusBadRequest, fmt.Sprintf("Invalid repository name %q.", repo))
+		}
+	}
+	// The tenancy middleware checks only the "repository" and "repo" parameters.
+	scope := a.tenantScope(c)
+	for _, repo := range []string{srcRepo, dstRepo} {
+		if !inScope(scope, repo) {
+			return a.renderError(c, http.StatusNotFound, fmt.Sprintf("Repository %s is not found.", repo))
+		}
+	}
+	if srcTag != "" && !registry.ValidTagName(srcTag) {
+		return c.String(http.StatusBadRequest, fmt.Sprintf("Invalid tag name %q.", srcTag))
+	}
+	if dstTag != "" && !registry.ValidTagName(dstTag) {
+		return c.String(http.StatusBadRequest, fmt.Sprintf("Invalid tag name %q.", dstTag))
+	}
+	if srcTag == "" && dstTag != "" {
+		return c.String(http.StatusBadRequest, "Tag name can be only set when copying a single tag.")
+	}
+	if dstTag == "" {
+		dstTag = srcTag
+	}
+	if srcRepo == dstRepo && srcTag == dstTag {
+		return c.String(http.StatusBadRequest, "Source and destination are the same.")
+	}
+	if srcRepo == dstRepo && deleteOriginals {
+		// Manifests are deleted by digest, so deleting the original would delete the copy too.
+		return c.String(http.StatusBadRequest, "Cannot delete the originals when copying within the same repository, the copies share their digests.")
+	}
+
+	name := fmt.Sprintf("Copy %s to %s", srcRepo, dstRepo)
+	if srcTag != "" {
+		name = fmt.Sprintf("Copy %s:%s to %s:%s", srcRepo, srcTag, dstRepo, dstTag)
+	}
+	if deleteOriginals {
+		name = strings.Replace(name, "Copy", "Rename", 1)
+	}
+	a.trackAction(c, "copy")
+	a.audit(c, "copy", srcRepo, srcTag, strings.TrimSpace(fmt.Sprintf("%s. %s", name, reason)))
+	user, ip, baseURL := data["user"].String(), c.RealIP(), a.uiBaseURL(c)
+	j := a.jobs.start(name, user, func(j *job) error {
+		if deleteOriginals {
+			j.waitForMaintenance(a.maintenance)
+		}
+		tags := []string{srcTag}
+		if srcTag == "" {
+			tags = a.client.Tags(srcRepo)
+		}
+		total := len(tags)
+		if deleteOriginals {
+			total = total * 2
+		}
+		j.progress(0, total)
+
+		target := func(tag string) string {
+			if dstTag == "" {
+				return tag
+			}
+			return dstTag
+		}
+		for i, tag := range tags {
+			j.logf("Copying %s:%s to %s:%s", srcRepo, tag, dstRepo, target(tag))
+			if err := a.client.CopyTag(srcRepo, tag, dstRepo, target(tag)); err != nil {
+				return err
+			}
+			j.progress(i+1, total)
+		}
+		if deleteOriginals {
+			for i, tag := range tags {
+				j.logf("Deleting %s:%s", srcRepo, tag)
+				if err := a.client.DeleteTag(srcRepo, tag); err != nil {
+					return err
+				}
+				a.auditAs(user, ip, "delete", srcRepo, tag, strings.TrimSpace(fmt.Sprintf("Renamed to %s:%s. %s", dstRepo, target(tag), reason)))
+				a.notifyDeletedTag(baseURL, user, ip, srcRepo, tag)
+				j.progress(len(tags)+i+1, total)
+			}
+		}
+
+		j.logf("Refreshing the list of repositories")
+		a.client.Repositories(false)
+		j.logf("Done, %d tags processed", len(tags))
+		if deleteOriginals {
+			a.gcAfterDeletions(j)
+		}
+		return nil
+	})
+	return c.Redirect(http.StatusSeeOther, fmt.Sprintf("%s/jobs/%d", a.config.BasePath, j.ID))
+}
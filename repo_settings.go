@@ -0,0 +1,330 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/quiq/docker-registry-ui/events"
+	"github.com/quiq/docker-registry-ui/registry"
+	"github.com/quiq/docker-registry-ui/scanner"
+)
+
+// Kinds of repo settings defining how the value is validated and edited.
+const (
+	settingNumber   = "number"
+	settingBool     = "bool"
+	settingPatterns = "patterns"
+	settingSeverity = "severity"
+	settingText     = "text"
+)
+
+// Repo settings which are looked up by name.
+const (
+	settingProtectedTags = "protected_tags"
+	settingOwnerTeam     = "owner_team"
+	settingOwnerSlack    = "owner_slack"
+	settingOwnerEmail    = "owner_email"
+	settingScanDisabled  = "scan_disabled"
+	settingMaxSeverity   = "policy_max_severity"
+	settingFileIndex     = "file_index"
+)
+
+// repoSettingOption setting admins can set per repo overriding the global config.
+type repoSettingOption struct {
+	Name        string
+	Group       string
+	Kind        string
+	Description string
+}
+
+// repoSettingOptions repo settings in the order shown on the settings page.
+var repoSettingOptions = []repoSettingOption{
+	{settingKeepDays, "Retention", settingNumber, "Days to keep tags of the repo when purging."},
+	{settingKeepCount, "Retention", settingNumber, "Minimal count of tags to keep no matter how old."},
+	{settingProtectedTags, "Protection", settingPatterns, "Tags never deleted or purged, comma-separated patterns, e.g. latest, v*."},
+	{settingOwnerTeam, "Owner", settingText, "Team owning the repo, overrides the owners matching by pattern."},
+	{settingOwnerSlack, "Owner", settingText, "Slack channel of the team."},
+	{settingOwnerEmail, "Owner", settingText, "Email of the team."},
+	{settingScanDisabled, "Scanning", settingBool, "Do not scan images of the repo, e.g. third-party ones, the policy does not require scans then."},
+	{settingMaxSeverity, "Scanning", settingSeverity, "No vulnerabilities of this severity or higher allowed by the policy."},
+	{settingFileIndex, "File search", settingBool, "Index file paths of the repo images for the file search of admins."},
+}
+
+// repoSetting value of the repo setting with the default coming from the global config.
+type repoSetting struct {
+	Name        string `json:"name"`
+	Group       string `json:"group"`
+	Kind        string `json:"kind"`
+	Description string `json:"description"`
+	// Value set for the repo, empty if the default applies.
+	Value   string `json:"value"`
+	Default string `json:"default"`
+	User    string `json:"user"`
+	Updated string `json:"updated"`
+}
+
+type apiRepoSettingsResponse struct {
+	Repository string        `json:"repository"`
+	Settings   []repoSetting `json:"settings"`
+}
+
+// findRepoSettingOption option of the repo setting by name.
+func findRepoSettingOption(name string) (repoSettingOption, bool) {
+	for _, o := range repoSettingOptions {
+		if o.Name == name {
+			return o, true
+		}
+	}
+	return repoSettingOption{}, false
+}
+
+// normalizeRepoSetting validate the value by the kind of the setting and return it in the stored form.
+func normalizeRepoSetting(o repoSettingOption, value string) (string, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return "", nil
+	}
+	switch o.Kind {
+	case settingNumber:
+		if n, err := strconv.Atoi(value); err != nil || n < 0 {
+			return "", fmt.Errorf("%s: should be a non-negative number", o.Name)
+		}
+	case settingBool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return "", fmt.Errorf("%s: should be true or false", o.Name)
+		}
+		value = strconv.FormatBool(b)
+	case settingPatterns:
+		patterns := splitPatterns(value)
+		for _, p := range patterns {
+			if _, err := path.Match(p, ""); err != nil {
+				return "", fmt.Errorf("%s: invalid pattern %q", o.Name, p)
+			}
+		}
+		value = strings.Join(patterns, ", ")
+	case settingSeverity:
+		if !registry.ItemInSlice(value, scanner.Severities) {
+			return "", fmt.Errorf("%s: should be one of %s", o.Name, strings.Join(scanner.Severities, ", "))
+		}
+	default:
+		if len(value) > 255 {
+			return "", fmt.Errorf("%s: should not be longer than 255 characters", o.Name)
+		}
+	}
+	return value, nil
+}
+
+// splitPatterns comma-separated patterns without empty ones.
+func splitPatterns(value string) []string {
+	var patterns []string
+	for _, p := range strings.Split(value, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			patterns = append(patterns, p)
+		}
+	}
+	return patterns
+}
+
+// repoSettingDefaults values applying to the repo when not set for it, taken from the global config.
+func (a *apiClient) repoSettingDefaults(repo string) map[string]string {
+	rules := a.retentionRules()
+	owner, _ := a.patternOwner(repo)
+	return map[string]string{
+		settingKeepDays:     strconv.Itoa(rules.KeepDays),
+		settingKeepCount:    strconv.Itoa(rules.KeepCount),
+		settingOwnerTeam:    owner.Team,
+		settingOwnerSlack:   owner.Slack,
+		settingOwnerEmail:   owner.Email,
+		settingScanDisabled: "false",
+		settingMaxSeverity:  a.config.PolicyMaxSeverity,
+		settingFileIndex:    strconv.FormatBool(repoPatternsMatch(a.config.FileIndexRepos, repo)),
+	}
+}
+
+// repoSettings all settings of the repo with their defaults.
+func (a *apiClient) repoSettings(repo string) []repoSetting {
+	stored := a.eventListener.GetSettings(repo)
+	defaults := a.repoSettingDefaults(repo)
+	list := []repoSetting{}
+	for _, o := range repoSettingOptions {
+		s := stored[o.Name]
+		list = append(list, repoSetting{
+			Name: o.Name, Group: o.Group, Kind: o.Kind, Description: o.Description,
+			Value: s.Value, Default: defaults[o.Name], User: s.User, Updated: s.Updated,
+		})
+	}
+	return list
+}
+
+// setRepoSetting store the setting of the repo, empty value resets it to the default.
+// Returns whether the value has changed.
+func (a *apiClient) setRepoSetting(repo, name, value, user string) (bool, error) {
+	o, ok := findRepoSettingOption(name)
+	if !ok {
+		return false, fmt.Errorf("unknown setting %q", name)
+	}
+	value, err := normalizeRepoSetting(o, value)
+	if err != nil {
+		return false, err
+	}
+	if a.eventListener.GetSettings(repo)[name].Value == value {
+		return false, nil
+	}
+	if value == "" {
+		return true, a.eventListener.DeleteSetting(repo, name)
+	}
+	return true, a.eventListener.SetSetting(events.Setting{Scope: repo, Name: name, Value: value, User: user})
+}
+
+// repoRetentionRules days and count of tags to keep for the repo, for purging.
+func (a *apiClient) repoRetentionRules(rules retentionRules) func(repo string) (int, int) {
+	return func(repo string) (int, int) {
+		keepDays, keepCount := rules.KeepDays, rules.KeepCount
+		settings := a.eventListener.GetSettings(repo)
+		if n, err := strconv.Atoi(settings[settingKeepDays].Value); err == nil {
+			keepDays = n
+		}
+		if n, err := strconv.Atoi(settings[settingKeepCount].Value); err == nil {
+			keepCount = n
+		}
+		return keepDays, keepCount
+	}
+}
+
+// protectedPatterns patterns of tags of the repo never deleted or purged.
+func (a *apiClient) protectedPatterns(repo string) []string {
+	return splitPatterns(a.eventListener.GetSettings(repo)[settingProtectedTags].Value)
+}
+
+// protectedBy the pattern protecting the tag, empty if none.
+func protectedBy(patterns []string, tag string) string {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, tag); ok {
+			return p
+		}
+	}
+	return ""
+}
+
+// tagProtection the protected tag keeping the tag, also when it shares the digest with a protected tag
+// as the deletion removes all tags of the digest.
+func (a *apiClient) tagProtection(repo, tag, digest string) (string, string, bool) {
+	patterns := a.protectedPatterns(repo)
+	if len(patterns) == 0 {
+		return "", "", false
+	}
+	if p := protectedBy(patterns, tag); p != "" {
+		return tag, p, true
+	}
+	if digest == "" {
+		return "", "", false
+	}
+	for _, t := range a.client.Tags(repo) {
+		p := protectedBy(patterns, t)
+		if p == "" || t == tag {
+			continue
+		}
+		if meta, err := a.client.TagMetadata(repo, t); err == nil && meta.Digest == digest {
+			return t, p, true
+		}
+	}
+	return "", "", false
+}
+
+// scanDisabled whether scanning images of the repo is turned off by its settings.
+func (a *apiClient) scanDisabled(repo string) bool {
+	return a.eventListener.GetSettings(repo)[settingScanDisabled].Value == "true"
+}
+
+// viewRepoSettings view settings of the repo to change them.
+func (a *apiClient) viewRepoSettings(c echo.Context) error {
+	repo := strings.Trim(c.QueryParam("repo"), "/")
+	if !registry.ValidRepoName(repo) {
+		return c.String(http.StatusBadRequest, "Repository should be set.")
+	}
+	data := a.setUserPermissions(c)
+	data.Set("repo", repo)
+	data.Set("repoURLPath", repoURLPath(repo))
+	data.Set("settings", a.repoSettings(repo))
+	data.Set("severities", scanner.Severities)
+	return c.Render(http.StatusOK, "repo_settings.html", data)
+}
+
+// saveRepoSettings store the settings of the repo from the form, empty fields reset them to the defaults.
+func (a *apiClient) saveRepoSettings(c echo.Context) error {
+	repo := strings.Trim(c.FormValue("repo"), "/")
+	if !registry.ValidRepoName(repo) {
+		return c.String(http.StatusBadRequest, "Repository should be set.")
+	}
+	user := a.setUserPermissions(c)["user"].String()
+	for _, o := range repoSettingOptions {
+		if _, err := normalizeRepoSetting(o, c.FormValue(o.Name)); err != nil {
+			return c.String(http.StatusBadRequest, err.Error())
+		}
+	}
+	var changes []string
+	for _, o := range repoSettingOptions {
+		value := c.FormValue(o.Name)
+		changed, err := a.setRepoSetting(repo, o.Name, value, user)
+		if err != nil {
+			a.log(c).Error(err)
+			return c.String(http.StatusInternalServerError, "Cannot save repo settings, see the log for request "+requestIDOf(c)+".")
+		}
+		if changed {
+			changes = append(changes, fmt.Sprintf("%s=%s", o.Name, strings.TrimSpace(value)))
+		}
+	}
+	if len(changes) > 0 {
+		a.trackAction(c, "repo-settings")
+		a.audit(c, "repo-settings", repo, "", strings.Join(changes, "; "))
+	}
+	return c.Redirect(http.StatusSeeOther, fmt.Sprintf("%s/settings?repo=%s", a.config.BasePath, url.QueryEscape(repo)))
+}
+
+// apiRepoSettings settings of the repo with their defaults.
+func (a *apiClient) apiRepoSettings(c echo.Context) error {
+	repo := strings.Trim(c.QueryParam("repository"), "/")
+	if !registry.ValidRepoName(repo) {
+		return apiError(c, http.StatusBadRequest, fmt.Errorf("repository is required"))
+	}
+	return c.JSON(http.StatusOK, apiRepoSettingsResponse{repo, a.repoSettings(repo)})
+}
+
+// apiSetRepoSetting set or reset the setting of the repo, PUT sets the value and DELETE resets it to the default.
+func (a *apiClient) apiSetRepoSetting(c echo.Context) error {
+	repo := strings.Trim(c.QueryParam("repository"), "/")
+	if !registry.ValidRepoName(repo) {
+		return apiError(c, http.StatusBadRequest, fmt.Errorf("repository is required"))
+	}
+	name := c.QueryParam("name")
+	o, ok := findRepoSettingOption(name)
+	if !ok {
+		return apiError(c, http.StatusBadRequest, fmt.Errorf("unknown setting %q", name))
+	}
+	value := ""
+	if c.Request().Method == http.MethodPut {
+		value = c.QueryParam("value")
+		if strings.TrimSpace(value) == "" {
+			return apiError(c, http.StatusBadRequest, fmt.Errorf("value is required, use DELETE to reset the setting"))
+		}
+	}
+	if _, err := normalizeRepoSetting(o, value); err != nil {
+		return apiError(c, http.StatusBadRequest, err)
+	}
+	changed, err := a.setRepoSetting(repo, name, value, a.setUserPermissions(c)["user"].String())
+	if err != nil {
+		a.log(c).Error(err)
+		return apiError(c, http.StatusInternalServerError, fmt.Errorf("cannot save the setting, see the log for details"))
+	}
+	if changed {
+		a.trackAction(c, "repo-settings")
+		a.audit(c, "repo-settings", repo, "", fmt.Sprintf("%s=%s", name, strings.TrimSpace(value)))
+	}
+	return c.JSON(http.StatusOK, apiRepoSettingsResponse{repo, a.repoSettings(repo)})
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+)
+
+// requestIDPattern IDs accepted from the reverse proxy, others are replaced to keep the log lines parsable.
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,64}$`)
+
+// newRequestID random ID short enough to be read out in a bug report.
+func newRequestID() string {
+	b := make([]byte, 6)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// requestID tag the request with the ID of the reverse proxy or a new one and return it in X-Request-Id header.
+func (a *apiClient) requestID(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		id := c.Request().Header.Get(echo.HeaderXRequestID)
+		if !requestIDPattern.MatchString(id) {
+			id = newRequestID()
+		}
+		c.Set("requestID", id)
+		c.Response().Header().Set(echo.HeaderXRequestID, id)
+		return next(c)
+	}
+}
+
+// requestIDOf ID of the request, empty for the requests not passed through the middleware.
+func requestIDOf(c echo.Context) string {
+	id, _ := c.Get("requestID").(string)
+	return id
+}
+
+// log logger of the request handler, the lines carry the request ID.
+func (a *apiClient) log(c echo.Context) *logrus.Entry {
+	return a.logger.WithField("request_id", requestIDOf(c))
+}
+
+// wantsJSON whether to respond with JSON to API requests and XHR calls instead of HTML page.
+func (a *apiClient) wantsJSON(c echo.Context) bool {
+	return strings.HasPrefix(c.Path(), a.config.BasePath+"/api/") || strings.Contains(c.Request().Header.Get("Accept"), "application/json")
+}
+
+// handleError respond to the errors returned by handlers with the request ID to report.
+// Unexpected errors are logged with it, their details are not shown to users.
+func (a *apiClient) handleError(err error, c echo.Context) {
+	code, message := http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError)
+	if he, ok := err.(*echo.HTTPError); ok {
+		code, message = he.Code, http.StatusText(he.Code)
+		if m, ok := he.Message.(string); ok {
+			message = m
+		}
+		if he.Internal != nil {
+			err = he.Internal
+		}
+	}
+	if code >= http.StatusInternalServerError {
+		a.log(c).Errorf("%s %s: %s", c.Request().Method, c.Request().URL.Path, err)
+	}
+	if c.Response().Committed {
+		return
+	}
+	if err := a.renderError(c, code, message); err != nil {
+		a.log(c).Error(err)
+	}
+}
+
+// renderError respond with the error page or JSON error to API requests, both with the request ID.
+func (a *apiClient) renderError(c echo.Context, code int, message string) error {
+	switch {
+	case c.Request().Method == http.MethodHead:
+		return c.NoContent(code)
+	case a.wantsJSON(c):
+		return c.JSON(code, map[string]string{"error": message, "request_id": requestIDOf(c)})
+	}
+	data := a.setUserPermissions(c)
+	data.Set("code", code)
+	data.Set("message", message)
+	data.Set("requestID", requestIDOf(c))
+	return c.Render(code, "error.html", data)
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/quiq/docker-registry-ui/registry"
+	"github.com/sirupsen/logrus"
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestRequestID(t *testing.T) {
+	a := &apiClient{logger: registry.SetupLogging("test")}
+	var entry *logrus.Entry
+	e := echo.New()
+	e.GET("/", func(c echo.Context) error {
+		entry = a.log(c)
+		return c.String(http.StatusOK, requestIDOf(c))
+	}, a.requestID)
+	serve := func(id string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set(echo.HeaderXRequestID, id)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	convey.Convey("Tag the log lines and the response with the request ID of the proxy", t, func() {
+		rec := serve("abc-123")
+		convey.So(rec.Header().Get(echo.HeaderXRequestID), convey.ShouldEqual, "abc-123")
+		convey.So(rec.Body.String(), convey.ShouldEqual, "abc-123")
+		convey.So(entry.Data["request_id"], convey.ShouldEqual, "abc-123")
+	})
+
+	convey.Convey("Replace the IDs not safe to log by a new one", t, func() {
+		rec := serve("bad id\n")
+		id := rec.Header().Get(echo.HeaderXRequestID)
+		convey.So(id, convey.ShouldHaveLength, 12)
+		convey.So(entry.Data["request_id"], convey.ShouldEqual, id)
+	})
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/labstack/echo/v4"
+	"github.com/quiq/docker-registry-ui/events"
+	"github.com/quiq/docker-registry-ui/registry"
+)
+
+// Retention settings which can be changed from UI, named as in the config.
+const (
+	settingKeepDays  = "purge_tags_keep_days"
+	settingKeepCount = "purge_tags_keep_count"
+)
+
+// retentionRules effective rules of purging old tags.
+type retentionRules struct {
+	KeepDays  int
+	KeepCount int
+	// User changed the rules from UI and when, empty if they come from the config.
+	User    string
+	Updated string
+}
+
+// retentionRules rules changed from UI, otherwise the config ones.
+func (a *apiClient) retentionRules() retentionRules {
+	r := retentionRules{KeepDays: a.config.PurgeTagsKeepDays, KeepCount: a.config.PurgeTagsKeepCount}
+	settings := a.eventListener.GetSettings("")
+	for name, value := range map[string]*int{settingKeepDays: &r.KeepDays, settingKeepCount: &r.KeepCount} {
+		s, ok := settings[name]
+		if !ok {
+			continue
+		}
+		if n, err := strconv.Atoi(s.Value); err == nil {
+			*value = n
+			r.User, r.Updated = s.User, s.Updated
+		}
+	}
+	return r
+}
+
+// schedulePurge start purging old tags as a job, it waits for maintenance window.
+// Purge is not queued again while the previous one is waiting or running, dry-runs are never skipped.
+// Returns nil if the purge is skipped.
+func (a *apiClient) schedulePurge(user string, dryRun bool) *job {
+	name := "Preview purging old tags"
+	if !dryRun {
+		if !atomic.CompareAndSwapInt32(&a.purging, 0, 1) {
+			a.logger.Warn("Purging old tags is still waiting or running, skipping this one.")
+			return nil
+		}
+		name = "Purge old tags"
+	}
+	return a.jobs.start(name, user, func(j *job) error {
+		if !dryRun {
+			defer atomic.StoreInt32(&a.purging, 0)
+			j.waitForMaintenance(a.maintenance)
+		}
+		rules := a.retentionRules()
+		j.logf("Keeping tags for %d days and at least %d tags per repo unless set by the repo settings, see the log for details",
+			rules.KeepDays, rules.KeepCount)
+		purged := registry.SelectOldTags(a.client, a.repoRetentionRules(rules))
+		count := 0
+		for _, repo := range registry.SortedMapKeys(purged) {
+			j.logf("[%s] %d tags: %s", repo, len(purged[repo]), strings.Join(purged[repo], ", "))
+			count += len(purged[repo])
+		}
+		if count > 0 {
+			a.logReclaim(j, purged)
+		}
+		if dryRun {
+			j.logf("Would purge %d tags, locked and protected tags and tags in use are kept anyway", count)
+			return nil
+		}
+		registry.PurgeTags(a.client, false, purged)
+		j.logf("Purged %d tags", count)
+		a.gcAfterDeletions(j)
+		return nil
+	})
+}
+
+// logReclaim log the space reclaimed by garbage collection after deleting the tags, the sum of
+// their sizes is much bigger as the layers are shared by the images.
+func (a *apiClient) logReclaim(j *job, deletions map[string][]string) {
+	r, err := registry.EstimateTagsReclaim(a.client, deletions)
+	if err != nil {
+		j.logf("Cannot estimate reclaimable space: %s", err)
+		return
+	}
+	j.logf("Reclaimable space: %s", r)
+}
+
+// purgeOldTags purges old tags by the effective retention rules, the repo settings win over them.
+// Returns the tags selected for purging by repo.
+func (a *apiClient) purgeOldTags(dryRun bool) map[string][]string {
+	return registry.PurgeOldTags(a.client, dryRun, a.repoRetentionRules(a.retentionRules()))
+}
+
+// viewRetention view retention rules with the actions the user is allowed to do.
+func (a *apiClient) viewRetention(c echo.Context) error {
+	data := a.setUserPermissions(c)
+	data.Set("rules", a.retentionRules())
+	data.Set("configKeepDays", a.config.PurgeTagsKeepDays)
+	data.Set("configKeepCount", a.config.PurgeTagsKeepCount)
+	data.Set("schedule", a.config.PurgeTagsSchedule)
+	data.Set("maintenanceSchedule", a.config.MaintenanceSchedule)
+	return c.Render(http.StatusOK, "retention.html", data)
+}
+
+// previewRetention run purging old tags in dry-run mode showing what would be purged.
+func (a *apiClient) previewRetention(c echo.Context) error {
+	j := a.schedulePurge(a.setUserPermissions(c)["user"].String(), true)
+	a.trackAction(c, "retention-preview")
+	return c.Redirect(http.StatusSeeOther, fmt.Sprintf("%s/jobs/%d", a.config.BasePath, j.ID))
+}
+
+// runRetention purge old tags right away, it still waits for maintenance window.
+func (a *apiClient) runRetention(c echo.Context) error {
+	j := a.schedulePurge(a.setUserPermissions(c)["user"].String(), false)
+	if j == nil {
+		return c.String(http.StatusConflict, "Purging old tags is still waiting or running.")
+	}
+	rules := a.retentionRules()
+	a.trackAction(c, "retention-run")
+	a.audit(c, "purge", "", "", fmt.Sprintf("Keep %d days and at least %d tags", rules.KeepDays, rules.KeepCount))
+	return c.Redirect(http.StatusSeeOther, fmt.Sprintf("%s/jobs/%d", a.config.BasePath, j.ID))
+}
+
+// saveRetentionRules change retention rules overriding the config ones, or reset them to the config.
+func (a *apiClient) saveRetentionRules(c echo.Context) error {
+	user := a.setUserPermissions(c)["user"].String()
+	if c.FormValue("reset") != "" {
+		for _, name := range []string{settingKeepDays, settingKeepCount} {
+			if err := a.eventListener.DeleteSetting("", name); err != nil {
+				a.log(c).Error(err)
+				return c.String(http.StatusInternalServerError, "Cannot reset retention rules, see the log for request "+requestIDOf(c)+".")
+			}
+		}
+		a.trackAction(c, "retention-rules")
+		a.audit(c, "retention-rules", "", "", "Reset to the config")
+		return c.Redirect(http.StatusSeeOther, a.config.BasePath+"/retention")
+	}
+
+	keepDays, err1 := strconv.Atoi(c.FormValue("keep_days"))
+	keepCount, err2 := strconv.Atoi(c.FormValue("keep_count"))
+	if err1 != nil || err2 != nil || keepDays < 0 || keepCount < 0 {
+		return c.String(http.StatusBadRequest, "Days and count of tags to keep should be non-negative numbers.")
+	}
+	for name, value := range map[string]int{settingKeepDays: keepDays, settingKeepCount: keepCount} {
+		s := events.Setting{Name: name, Value: strconv.Itoa(value), User: user}
+		if err := a.eventListener.SetSetting(s); err != nil {
+			a.log(c).Error(err)
+			return c.String(http.StatusInternalServerError, "Cannot save retention rules, see the log for request "+requestIDOf(c)+".")
+		}
+	}
+	a.trackAction(c, "retention-rules")
+	a.audit(c, "retention-rules", "", "", fmt.Sprintf("Keep %d days and at least %d tags", keepDays, keepCount))
+	return c.Redirect(http.StatusSeeOther, a.config.BasePath+"/retention")
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/quiq/docker-registry-ui/events"
+	"github.com/quiq/docker-registry-ui/registry"
+)
+
+// retentionImportLimit max size of the imported policy file.
+const retentionImportLimit = 1 << 20
+
+// retentionPolicyText policy file of the form, uploaded or pasted.
+func retentionPolicyText(c echo.Context) (string, error) {
+	if file, err := c.FormFile("file"); err == nil {
+		f, err := file.Open()
+		if err != nil {
+			return "", err
+		}
+		defer f.Close()
+		data, err := ioutil.ReadAll(io.LimitReader(f, retentionImportLimit))
+		return string(data), err
+	}
+	return c.FormValue("policy"), nil
+}
+
+// importRetention convert the retention policy of another cleanup tool and show the resulting rules,
+// they are saved when confirmed by apply field.
+func (a *apiClient) importRetention(c echo.Context) error {
+	text, err := retentionPolicyText(c)
+	if err != nil || strings.TrimSpace(text) == "" {
+		return c.String(http.StatusBadRequest, "Policy file should be uploaded or pasted.")
+	}
+	repo := strings.Trim(strings.TrimSpace(c.FormValue("repo")), "/")
+	if repo != "" && !registry.ValidRepoName(repo) {
+		return c.String(http.StatusBadRequest, "Invalid repository name.")
+	}
+	var repos []string
+	for namespace, list := range a.client.Repositories(true) {
+		for _, r := range list {
+			repos = append(repos, catalogRepoPath(namespace, r))
+		}
+	}
+	imported, err := registry.ImportRetention([]byte(text), repo, repos)
+	if err != nil {
+		return c.String(http.StatusBadRequest, fmt.Sprintf("Cannot import the policy: %s.", err))
+	}
+	for _, r := range imported.Rules {
+		if r.Repo == "" && len(r.Protected) > 0 {
+			imported.Warnings = append(imported.Warnings, fmt.Sprintf("Protected tags %s are set per repo, choose the repository to import them for.",
+				strings.Join(r.Protected, ", ")))
+		}
+	}
+
+	if c.FormValue("apply") == "" {
+		data := a.setUserPermissions(c)
+		data.Set("imported", imported)
+		data.Set("policy", text)
+		data.Set("repo", repo)
+		return c.Render(http.StatusOK, "retention_import.html", data)
+	}
+
+	user := a.setUserPermissions(c)["user"].String()
+	var changes []string
+	for _, r := range imported.Rules {
+		changed, err := a.applyImportedRule(r, user)
+		if err != nil {
+			a.log(c).Error(err)
+			return c.String(http.StatusInternalServerError, "Cannot save retention rules, see the log for request "+requestIDOf(c)+".")
+		}
+		changes = append(changes, changed...)
+	}
+	a.trackAction(c, "retention-import")
+	a.audit(c, "retention-import", repo, "", fmt.Sprintf("Imported %s policy: %s", imported.Format, strings.Join(changes, "; ")))
+	return c.Redirect(http.StatusSeeOther, a.config.BasePath+"/retention")
+}
+
+// applyImportedRule save the rule as the global retention rules or the repo settings, protected tags are
+// added to the ones of the repo. Returns the changes for the audit log.
+func (a *apiClient) applyImportedRule(r registry.ImportedRule, user string) ([]string, error) {
+	var changes []string
+	for _, setting := range []struct {
+		name  string
+		value int
+	}{{settingKeepDays, r.KeepDays}, {settingKeepCount, r.KeepCount}} {
+		name, value := setting.name, setting.value
+		if value < 0 {
+			continue
+		}
+		if r.Repo == "" {
+			if err := a.eventListener.SetSetting(events.Setting{Name: name, Value: strconv.Itoa(value), User: user}); err != nil {
+				return nil, err
+			}
+			changes = append(changes, fmt.Sprintf("%s=%d", name, value))
+			continue
+		}
+		changed, err := a.setRepoSetting(r.Repo, name, strconv.Itoa(value), user)
+		if err != nil {
+			return nil, err
+		}
+		if changed {
+			changes = append(changes, fmt.Sprintf("%s: %s=%d", r.Repo, name, value))
+		}
+	}
+	if r.Repo != "" && len(r.Protected) > 0 {
+		patterns := a.protectedPatterns(r.Repo)
+		for _, p := range r.Protected {
+			if !registry.ItemInSlice(p, patterns) {
+				patterns = append(patterns, p)
+			}
+		}
+		value := strings.Join(patterns, ", ")
+		changed, err := a.setRepoSetting(r.Repo, settingProtectedTags, value, user)
+		if err != nil {
+			return nil, err
+		}
+		if changed {
+			changes = append(changes, fmt.Sprintf("%s: %s=%s", r.Repo, settingProtectedTags, value))
+		}
+	}
+	return changes, nil
+}
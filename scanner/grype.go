@@ -0,0 +1,92 @@
+package scanner
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/tidwall/gjson"
+)
+
+// Grype scanner backend running Anchore Grype CLI, it pulls the image from the registry directly without docker.
+type Grype struct {
+	// Path to grype binary.
+	Path string
+	// DBUpdateURL listing of vulnerability DBs served locally, empty to use the public one.
+	DBUpdateURL string
+	Registry    Registry
+	Timeout     time.Duration
+}
+
+// Name of the backend.
+func (g Grype) Name() string {
+	return "Grype"
+}
+
+// Scan the image with grype.
+func (g Grype) Scan(image string) (Report, error) {
+	report := Report{Image: image, Scanner: g.Name(), Scanned: time.Now()}
+	ctx, cancel := context.WithTimeout(context.Background(), g.Timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, g.Path, "registry:"+image, "--output", "json", "--quiet")
+	cmd.Env = append(os.Environ(),
+		"GRYPE_REGISTRY_AUTH_AUTHORITY="+strings.SplitN(image, "/", 2)[0],
+		"GRYPE_REGISTRY_AUTH_USERNAME="+g.Registry.Username,
+		"GRYPE_REGISTRY_AUTH_PASSWORD="+g.Registry.Password,
+	)
+	if g.Registry.Insecure {
+		cmd.Env = append(cmd.Env, "GRYPE_REGISTRY_INSECURE_SKIP_TLS_VERIFY=true", "GRYPE_REGISTRY_INSECURE_USE_HTTP=true")
+	}
+	if g.DBUpdateURL != "" {
+		cmd.Env = append(cmd.Env, "GRYPE_DB_UPDATE_URL="+g.DBUpdateURL)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return report, fmt.Errorf("grype timed out after %s", g.Timeout)
+		}
+		return report, fmt.Errorf("grype failed: %s: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	vulns, err := parseGrype(stdout.Bytes())
+	report.Vulnerabilities = vulns
+	return report, err
+}
+
+// parseGrype get vulnerabilities from grype JSON output, the same vulnerability of the package is listed once.
+func parseGrype(data []byte) ([]Vulnerability, error) {
+	if !gjson.ValidBytes(data) {
+		return nil, fmt.Errorf("cannot parse grype output")
+	}
+	var list []Vulnerability
+	seen := map[string]bool{}
+	for _, m := range gjson.GetBytes(data, "matches").Array() {
+		v := Vulnerability{
+			ID:       m.Get("vulnerability.id").String(),
+			Severity: m.Get("vulnerability.severity").String(),
+			Package:  m.Get("artifact.name").String(),
+			Version:  m.Get("artifact.version").String(),
+			URL:      m.Get("vulnerability.dataSource").String(),
+		}
+		var fixed []string
+		for _, f := range m.Get("vulnerability.fix.versions").Array() {
+			fixed = append(fixed, f.String())
+		}
+		v.FixedIn = strings.Join(fixed, ", ")
+		if SeverityRank(v.Severity) == len(Severities) {
+			v.Severity = "Unknown"
+		}
+		key := v.ID + " " + v.Package + " " + v.Version
+		if !seen[key] {
+			seen[key] = true
+			list = append(list, v)
+		}
+	}
+	sortVulnerabilities(list)
+	return list, nil
+}
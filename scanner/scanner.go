@@ -0,0 +1,110 @@
+package scanner
+
+import (
+	"sort"
+	"time"
+)
+
+// Severities of vulnerabilities from the most severe.
+var Severities = []string{"Critical", "High", "Medium", "Low", "Negligible", "Unknown"}
+
+// Scanner backend scanning images in the registry for vulnerabilities.
+type Scanner interface {
+	// Name of the backend shown on UI.
+	Name() string
+	// Scan the image by reference, e.g. registry.local/team/app@sha256:...
+	Scan(image string) (Report, error)
+}
+
+// Registry access for the scanner to pull images, the credentials are used for the host of the image.
+type Registry struct {
+	Username string
+	Password string
+	// Insecure registry served over plain HTTP or with TLS certificate not verified.
+	Insecure bool
+}
+
+// Vulnerability found in the image.
+type Vulnerability struct {
+	ID       string
+	Severity string
+	Package  string
+	Version  string
+	FixedIn  string
+	URL      string
+	// Accepted by admin, it is not counted in the summary.
+	Accepted bool
+}
+
+// SeverityCount count of vulnerabilities of the severity.
+type SeverityCount struct {
+	Severity string
+	Count    int
+}
+
+// Report result of the image scan.
+type Report struct {
+	Image           string
+	Scanner         string
+	Scanned         time.Time
+	Vulnerabilities []Vulnerability
+}
+
+// Summary counts of vulnerabilities by severity excluding accepted ones, only severities found are listed.
+func (r Report) Summary() []SeverityCount {
+	counts := map[string]int{}
+	for _, v := range r.Vulnerabilities {
+		if !v.Accepted {
+			counts[v.Severity]++
+		}
+	}
+	var list []SeverityCount
+	for _, s := range Severities {
+		if counts[s] > 0 {
+			list = append(list, SeverityCount{s, counts[s]})
+		}
+	}
+	return list
+}
+
+// Accepted count of the accepted vulnerabilities.
+func (r Report) Accepted() int {
+	count := 0
+	for _, v := range r.Vulnerabilities {
+		if v.Accepted {
+			count++
+		}
+	}
+	return count
+}
+
+// CountAtLeast count of not accepted vulnerabilities of the severity or more severe.
+func (r Report) CountAtLeast(severity string) int {
+	count := 0
+	for _, v := range r.Vulnerabilities {
+		if !v.Accepted && SeverityRank(v.Severity) <= SeverityRank(severity) {
+			count++
+		}
+	}
+	return count
+}
+
+// SeverityRank position of the severity in the list from the most severe, unknown values go last.
+func SeverityRank(severity string) int {
+	for i, s := range Severities {
+		if s == severity {
+			return i
+		}
+	}
+	return len(Severities)
+}
+
+// sortVulnerabilities order by severity and then by ID.
+func sortVulnerabilities(list []Vulnerability) {
+	sort.SliceStable(list, func(i, j int) bool {
+		if SeverityRank(list[i].Severity) != SeverityRank(list[j].Severity) {
+			return SeverityRank(list[i].Severity) < SeverityRank(list[j].Severity)
+		}
+		return list[i].ID < list[j].ID
+	})
+}
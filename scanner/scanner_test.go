@@ -0,0 +1,48 @@
+package scanner
+
+import (
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+const grypeOutput = `{
+  "matches": [
+    {"vulnerability": {"id": "CVE-2021-2", "severity": "Medium", "dataSource": "https://nvd.nist.gov/vuln/detail/CVE-2021-2", "fix": {"versions": [], "state": "not-fixed"}},
+     "artifact": {"name": "busybox", "version": "1.32.1-r3"}},
+    {"vulnerability": {"id": "CVE-2021-1", "severity": "Critical", "fix": {"versions": ["1.1.1k-r0"], "state": "fixed"}},
+     "artifact": {"name": "libssl1.1", "version": "1.1.1j-r0"}},
+    {"vulnerability": {"id": "CVE-2021-1", "severity": "Critical", "fix": {"versions": ["1.1.1k-r0"], "state": "fixed"}},
+     "artifact": {"name": "libssl1.1", "version": "1.1.1j-r0"}},
+    {"vulnerability": {"id": "CVE-2021-3", "severity": "Critical", "fix": {"versions": ["1.1.1k-r0"]}},
+     "artifact": {"name": "libcrypto1.1", "version": "1.1.1j-r0"}},
+    {"vulnerability": {"id": "GHSA-xxxx", "severity": "bogus"}, "artifact": {"name": "lib", "version": "1"}}
+  ]
+}`
+
+func TestParseGrype(t *testing.T) {
+	convey.Convey("Parse grype output", t, func() {
+		vulns, err := parseGrype([]byte(grypeOutput))
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(len(vulns), convey.ShouldEqual, 4)
+		convey.So(vulns[0], convey.ShouldResemble, Vulnerability{ID: "CVE-2021-1", Severity: "Critical", Package: "libssl1.1", Version: "1.1.1j-r0", FixedIn: "1.1.1k-r0"})
+		convey.So(vulns[1].ID, convey.ShouldEqual, "CVE-2021-3")
+		convey.So(vulns[2].URL, convey.ShouldEqual, "https://nvd.nist.gov/vuln/detail/CVE-2021-2")
+		convey.So(vulns[3].Severity, convey.ShouldEqual, "Unknown")
+
+		_, err = parseGrype([]byte("not json"))
+		convey.So(err, convey.ShouldNotBeNil)
+	})
+
+	convey.Convey("Summary excludes accepted vulnerabilities", t, func() {
+		vulns, _ := parseGrype([]byte(grypeOutput))
+		vulns[1].Accepted = true
+		r := Report{Vulnerabilities: vulns}
+		convey.So(r.Summary(), convey.ShouldResemble, []SeverityCount{{"Critical", 1}, {"Medium", 1}, {"Unknown", 1}})
+		convey.So(r.Accepted(), convey.ShouldEqual, 1)
+		convey.So(r.CountAtLeast("Critical"), convey.ShouldEqual, 1)
+		convey.So(r.CountAtLeast("High"), convey.ShouldEqual, 1)
+		convey.So(r.CountAtLeast("Medium"), convey.ShouldEqual, 2)
+		convey.So(r.CountAtLeast("Unknown"), convey.ShouldEqual, 3)
+	})
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/quiq/docker-registry-ui/scanner"
+)
+
+// scanResults the latest scan reports by "repo@digest", they are kept in memory until restart.
+type scanResults struct {
+	mux     sync.Mutex
+	reports map[string]scanner.Report
+}
+
+// newScanner create scanner backend if configured, nil otherwise.
+func (a *apiClient) newScanner() scanner.Scanner {
+	if !a.config.feature("scanning") {
+		return nil
+	}
+	u, _ := url.Parse(a.config.RegistryURL)
+	registry := scanner.Registry{Insecure: u.Scheme == "http" || !a.config.VerifyTLS}
+	registry.Username, registry.Password = a.client.Credentials()
+	timeout := time.Duration(a.config.ScannerTimeout) * time.Minute
+	switch a.config.Scanner {
+	case "grype":
+		return scanner.Grype{Path: a.config.ScannerGrypePath, DBUpdateURL: a.config.ScannerGrypeDBUpdateURL, Registry: registry, Timeout: timeout}
+	}
+	return nil
+}
+
+// scanReport the latest scan of the image with accepted vulnerabilities marked.
+func (a *apiClient) scanReport(repo, tag, digest string) (scanner.Report, bool) {
+	a.scans.mux.Lock()
+	report, ok := a.scans.reports[repo+"@"+digest]
+	a.scans.mux.Unlock()
+	if !ok {
+		return report, false
+	}
+
+	// Acceptances may change any time, so they are applied to a copy on every view.
+	acceptances := a.eventListener.GetVulnAcceptances()
+	now := time.Now()
+	vulns := make([]scanner.Vulnerability, len(report.Vulnerabilities))
+	for i, v := range report.Vulnerabilities {
+		for _, acc := range acceptances {
+			if acc.Matches(repo, tag, v.ID, now) {
+				v.Accepted = true
+				break
+			}
+		}
+		vulns[i] = v
+	}
+	report.Vulnerabilities = vulns
+	return report, true
+}
+
+// scanImage start scanning the image as a job, the result is shown on the tag page.
+func (a *apiClient) scanImage(c echo.Context) error {
+	if a.scanner == nil {
+		return c.String(http.StatusBadRequest, "Vulnerability scanning is not configured.")
+	}
+	repo := c.FormValue("repo")
+	tag := c.FormValue("tag")
+	if a.scanDisabled(repo) {
+		return c.String(http.StatusBadRequest, fmt.Sprintf("Scanning is disabled by the settings of %s.", repo))
+	}
+	meta, err := a.client.TagMetadata(repo, tag)
+	if err != nil {
+		return c.String(http.StatusNotFound, fmt.Sprintf("Cannot find image %s:%s: %s", repo, tag, err))
+	}
+
+	a.trackAction(c, "scan")
+	user := a.setUserPermissions(c)["user"].String()
+	p := webhookPayload{
+		Repository: repo, Tag: tag, Digest: meta.Digest, Image: a.config.imageName(repo) + ":" + tag, User: user, IP: c.RealIP(),
+		URL: a.uiBaseURL(c) + "/" + repoURLPath(repo) + "/" + url.PathEscape(tag),
+	}
+	j := a.jobs.start(fmt.Sprintf("Scan %s:%s", repo, tag), user, func(j *job) error {
+		image := fmt.Sprintf("%s@%s", a.config.imageName(repo), meta.Digest)
+		j.logf("Scanning %s with %s", image, a.scanner.Name())
+		// The scanner is created again to pull the image with the current credentials, they may be rotated.
+		report, err := a.newScanner().Scan(image)
+		if err != nil {
+			return err
+		}
+		a.scans.mux.Lock()
+		a.scans.reports[repo+"@"+meta.Digest] = report
+		a.scans.mux.Unlock()
+		j.logf("Found %d vulnerabilities", len(report.Vulnerabilities))
+		// The recipients are told of the vulnerabilities not accepted at the time of the scan.
+		report, _ = a.scanReport(repo, tag, meta.Digest)
+		p.Timestamp = report.Scanned
+		a.notifyVulnerabilities(p, report)
+		return nil
+	})
+	return c.Redirect(http.StatusSeeOther, fmt.Sprintf("%s/jobs/%d", a.config.BasePath, j.ID))
+}
+
+// severityBadges HTML labels with counts of vulnerabilities by severity.
+func severityBadges(summary []scanner.SeverityCount) string {
+	if len(summary) == 0 {
+		return `<span class="label label-success">No vulnerabilities</span>`
+	}
+	var badges []string
+	for _, s := range summary {
+		class := "default"
+		switch s.Severity {
+		case "Critical":
+			class = "danger"
+		case "High":
+			class = "warning"
+		case "Medium":
+			class = "info"
+		}
+		badges = append(badges, fmt.Sprintf(`<span class="label label-%s">%d %s</span>`, class, s.Count, s.Severity))
+	}
+	return strings.Join(badges, " ")
+}
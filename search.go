@@ -0,0 +1,26 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// searchLimit how many results to show on the search page.
+const searchLimit = 100
+
+// viewSearch search repos and tags by names, labels and annotations.
+func (a *apiClient) viewSearch(c echo.Context) error {
+	query := strings.TrimSpace(c.QueryParam("q"))
+	scope := a.tenantScope(c)
+	results, built := a.client.Search(query, scopedSearchLimit(scope, searchLimit))
+	results = scopedSearch(scope, results, searchLimit)
+
+	data := a.setUserPermissions(c)
+	data.Set("query", query)
+	data.Set("results", results)
+	data.Set("indexReady", !built.IsZero())
+	data.Set("indexUpdated", built)
+	return c.Render(http.StatusOK, "search.html", data)
+}
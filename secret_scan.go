@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/quiq/docker-registry-ui/lint"
+	"github.com/quiq/docker-registry-ui/registry"
+	"github.com/tidwall/gjson"
+)
+
+// secretScansLimit results of the recently viewed images kept not to read their layers again.
+const secretScansLimit = 500
+
+// secretScan credentials found in the image, the images are immutable by digest.
+type secretScan struct {
+	findings []lint.SecretFinding
+	// skipped layers not read as they are larger than secret_scan_max_layer_mb or not supported.
+	skipped int
+}
+
+// secretScans the recently scanned images by digest, the oldest one is dropped first.
+type secretScans struct {
+	mux   sync.Mutex
+	items map[string]secretScan
+	order []string
+}
+
+// secretScanEnabled whether the images of the repo are scanned for secrets.
+func (a *apiClient) secretScanEnabled(repo string) bool {
+	return a.config.feature("secrets") && repoPatternsMatch(a.config.SecretScanRepos, repo)
+}
+
+// scanSecrets find credentials in the config and the small text layers of the image, every platform of
+// multi-arch one. Results are cached by the digest.
+func (a *apiClient) scanSecrets(repo, tag, digest string) (secretScan, error) {
+	a.secrets.mux.Lock()
+	s, ok := a.secrets.items[digest]
+	a.secrets.mux.Unlock()
+	if ok {
+		return s, nil
+	}
+
+	manifest, _, _, err := a.client.GetManifest(repo, tag)
+	if err != nil {
+		return s, err
+	}
+	manifests := []string{manifest}
+	for _, m := range registry.IndexImages(manifest) {
+		sub, _, _, err := a.client.GetManifest(repo, m)
+		if err != nil {
+			return s, err
+		}
+		manifests = append(manifests, sub)
+	}
+	seen := map[lint.SecretFinding]bool{}
+	add := func(f lint.SecretFinding) {
+		if !seen[f] {
+			seen[f] = true
+			s.findings = append(s.findings, f)
+		}
+	}
+	scannedLayers := map[string]bool{}
+	for _, m := range manifests {
+		if d := gjson.Get(m, "config.digest").String(); d != "" {
+			config, err := a.client.GetBlob(repo, d)
+			if err != nil {
+				return s, err
+			}
+			for _, f := range configSecrets(config) {
+				add(f)
+			}
+		}
+		for i, l := range registry.Layers(m) {
+			if l.Foreign || scannedLayers[l.Digest] {
+				continue
+			}
+			scannedLayers[l.Digest] = true
+			if l.Size > int64(a.config.SecretScanMaxLayerMB)<<20 {
+				s.skipped++
+				continue
+			}
+			err := a.client.ReadLayerText(repo, l.Digest, int64(a.config.SecretScanMaxLayerMB)<<20, int64(a.config.SecretScanMaxFileKB)<<10,
+				func(path string, data []byte) {
+					for _, name := range lint.FindSecrets(string(data)) {
+						add(lint.SecretFinding{Source: "/" + path, Pattern: name, Layer: i + 1})
+					}
+				})
+			if err != nil {
+				a.logger.Infof("Cannot scan layer %s of %s for secrets: %s", l.Digest, repo, err)
+				s.skipped++
+			}
+		}
+	}
+
+	if digest == "" {
+		return s, nil
+	}
+	a.secrets.mux.Lock()
+	defer a.secrets.mux.Unlock()
+	if a.secrets.items == nil {
+		a.secrets.items = map[string]secretScan{}
+	}
+	if _, ok := a.secrets.items[digest]; !ok {
+		a.secrets.order = append(a.secrets.order, digest)
+	}
+	a.secrets.items[digest] = s
+	if len(a.secrets.order) > secretScansLimit {
+		delete(a.secrets.items, a.secrets.order[0])
+		a.secrets.order = a.secrets.order[1:]
+	}
+	return s, nil
+}
+
+// configSecrets credentials in the env, labels and build history of the image config, e.g. build args
+// recorded by RUN steps.
+func configSecrets(config string) []lint.SecretFinding {
+	var list []lint.SecretFinding
+	check := func(source, text string) {
+		for _, name := range lint.FindSecrets(text) {
+			list = append(list, lint.SecretFinding{Source: source, Pattern: name})
+		}
+	}
+	for _, e := range gjson.Get(config, "config.Env").Array() {
+		check("ENV "+strings.SplitN(e.String(), "=", 2)[0], e.String())
+	}
+	gjson.Get(config, "config.Labels").ForEach(func(k, v gjson.Result) bool {
+		check("label "+k.String(), v.String())
+		return true
+	})
+	for i, h := range gjson.Get(config, "history").Array() {
+		check(fmt.Sprintf("history step %d", i+1), h.Get("created_by").String())
+	}
+	return list
+}
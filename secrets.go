@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron"
+)
+
+// secretsReloadInterval how often to check the secret files for rotation.
+const secretsReloadInterval = time.Minute
+
+// readSecret read the secret from file without the trailing newline.
+func readSecret(file string) (string, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(string(data), "\n"), nil
+}
+
+// watchSecrets re-read the registry password and API token files and use the new values when they are rotated,
+// e.g. by Kubernetes updating the mounted secret, so the running UI keeps access to the registry.
+func (a *apiClient) watchSecrets() {
+	if a.config.RegistryMock || (a.config.PasswordFile == "" && a.config.RegistryAPITokenFile == "") {
+		return
+	}
+	password, token := a.config.Password, a.config.RegistryAPIToken
+	a.tasks.add("Reload secret files", cron.Every(secretsReloadInterval), false, func(t *backgroundTask) (string, error) {
+		var reloaded []string
+		if a.config.PasswordFile != "" {
+			value, err := readSecret(a.config.PasswordFile)
+			if err != nil {
+				return "", fmt.Errorf("registry_password_file: %s", err)
+			}
+			if value != password {
+				password = value
+				a.client.SetCredentials(a.config.Username, password)
+				reloaded = append(reloaded, "registry password")
+			}
+		}
+		if a.config.RegistryAPITokenFile != "" {
+			value, err := readSecret(a.config.RegistryAPITokenFile)
+			if err != nil {
+				return "", fmt.Errorf("registry_api_token_file: %s", err)
+			}
+			if value != token {
+				token = value
+				a.client.SetAPIToken(token)
+				reloaded = append(reloaded, "registry API token")
+			}
+		}
+		if len(reloaded) == 0 {
+			return "Unchanged", nil
+		}
+		a.logger.Infof("Reloaded rotated secrets: %s", strings.Join(reloaded, ", "))
+		return "Reloaded " + strings.Join(reloaded, ", "), nil
+	})
+}
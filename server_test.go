@@ -0,0 +1,274 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/quiq/docker-registry-ui/events"
+	"github.com/quiq/docker-registry-ui/registry"
+	"github.com/smartystreets/goconvey/convey"
+)
+
+// newTestServer server of the config given with the middleware of all requests and the API routes,
+// other routes are added by the test.
+func newTestServer(t *testing.T, config string) (*apiClient, *echo.Echo) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "config.yml")
+	config = "event_database_location: " + filepath.Join(dir, "events.db") + "\n" + config
+	if err := ioutil.WriteFile(file, []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := readConfig(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if errs := cfg.validate(); len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	a := &apiClient{config: cfg, logger: registry.SetupLogging("test"), usage: newUsageStats()}
+	a.proxies, _ = parseTrustedProxies(cfg.TrustedProxies)
+	a.eventListener = events.NewEventListener(cfg.EventDatabaseDriver, cfg.EventDatabaseLocation, 7, true)
+
+	e := echo.New()
+	e.Renderer = setupRenderer(cfg, "registry.local")
+	e.HTTPErrorHandler = a.handleError
+	e.IPExtractor = a.proxies.ipExtractor()
+	a.useMiddleware(e, nil)
+	for _, r := range a.apiRoutes() {
+		if !r.Auth {
+			e.Add(r.Method, r.Path, r.handler)
+		}
+	}
+	return a, e
+}
+
+// serve response to the request with the headers given.
+func serve(e *echo.Echo, req *http.Request, headers map[string]string) *httptest.ResponseRecorder {
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestTenancy(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v2/_catalog" {
+			fmt.Fprint(w, `{"repositories": ["other/app", "team/app", "team/worker"]}`)
+		}
+	}))
+	defer server.Close()
+	a, e := newTestServer(t, `
+registry_url: http://registry.local
+admins: [admin]
+tenants:
+  - name: team
+    users: [carol]
+    repos: ["team/*"]
+`)
+	a.client = registry.NewClient(server.URL, true, "", "")
+	e.GET("/:namespace", a.viewRepositories)
+	e.GET("/:namespace/:repo", func(c echo.Context) error { return c.String(http.StatusOK, "tags") })
+
+	convey.Convey("Return not found for the repos outside the tenant scope", t, func() {
+		rec := serve(e, httptest.NewRequest("GET", "/team/app", nil), map[string]string{"X-WEBAUTH-USER": "carol"})
+		convey.So(rec.Code, convey.ShouldEqual, http.StatusOK)
+		rec = serve(e, httptest.NewRequest("GET", "/other/app", nil), map[string]string{"X-WEBAUTH-USER": "carol"})
+		convey.So(rec.Code, convey.ShouldEqual, http.StatusNotFound)
+		rec = serve(e, httptest.NewRequest("GET", "/api/v1/tags?repository=other/app", nil),
+			map[string]string{"X-WEBAUTH-USER": "carol", "Accept": "application/json"})
+		convey.So(rec.Code, convey.ShouldEqual, http.StatusNotFound)
+		convey.So(rec.Body.String(), convey.ShouldContainSubstring, "Repository other/app is not found.")
+
+		// Admins are not restricted, users outside any tenant see no repos.
+		rec = serve(e, httptest.NewRequest("GET", "/other/app", nil), map[string]string{"X-WEBAUTH-USER": "admin"})
+		convey.So(rec.Code, convey.ShouldEqual, http.StatusOK)
+		rec = serve(e, httptest.NewRequest("GET", "/team/app", nil), map[string]string{"X-WEBAUTH-USER": "dave"})
+		convey.So(rec.Code, convey.ShouldEqual, http.StatusNotFound)
+	})
+
+	convey.Convey("Export only the repos within the tenant scope as CSV", t, func() {
+		for _, ns := range []string{"team", "other"} {
+			rec := serve(e, httptest.NewRequest("GET", "/"+ns+"?format=csv", nil), map[string]string{"X-WEBAUTH-USER": "carol"})
+			convey.So(rec.Code, convey.ShouldEqual, http.StatusOK)
+			convey.So(rec.Body.String(), convey.ShouldNotContainSubstring, "other,app")
+		}
+		rec := serve(e, httptest.NewRequest("GET", "/team?format=csv", nil), map[string]string{"X-WEBAUTH-USER": "carol"})
+		convey.So(rec.Body.String(), convey.ShouldContainSubstring, "team,worker")
+		rec = serve(e, httptest.NewRequest("GET", "/other?format=csv", nil), map[string]string{"X-WEBAUTH-USER": "admin"})
+		convey.So(rec.Body.String(), convey.ShouldContainSubstring, "other,app")
+	})
+}
+
+func TestAPIToken(t *testing.T) {
+	a, e := newTestServer(t, `
+registry_url: http://registry.local
+admins: [admin]
+api_require_token: true
+`)
+	adminToken, _ := a.eventListener.CreateAPIToken("ci", "admin")
+	userToken, _ := a.eventListener.CreateAPIToken("ci", "bob")
+	settings := "/api/v1/settings?repository=alpine"
+
+	convey.Convey("Run the API requests as the user who created the token", t, func() {
+		rec := serve(e, httptest.NewRequest("GET", settings, nil), map[string]string{"Authorization": "Bearer " + adminToken})
+		convey.So(rec.Code, convey.ShouldEqual, http.StatusOK)
+		rec = serve(e, httptest.NewRequest("GET", settings, nil), map[string]string{"Authorization": "Bearer " + userToken})
+		convey.So(rec.Code, convey.ShouldEqual, http.StatusForbidden)
+
+		// The token user replaces the one in the header.
+		rec = serve(e, httptest.NewRequest("GET", settings, nil),
+			map[string]string{"Authorization": "Bearer " + userToken, "X-WEBAUTH-USER": "admin"})
+		convey.So(rec.Code, convey.ShouldEqual, http.StatusForbidden)
+	})
+
+	convey.Convey("Leave other authorization schemes to the user header", t, func() {
+		rec := serve(e, httptest.NewRequest("GET", settings, nil),
+			map[string]string{"Authorization": "Basic YWRtaW46c2VjcmV0", "X-WEBAUTH-USER": "admin"})
+		convey.So(rec.Code, convey.ShouldEqual, http.StatusOK)
+		rec = serve(e, httptest.NewRequest("GET", settings, nil), map[string]string{"Authorization": "Basic YWRtaW46c2VjcmV0"})
+		convey.So(rec.Code, convey.ShouldEqual, http.StatusUnauthorized)
+	})
+
+	convey.Convey("Reject the requests with invalid or without token", t, func() {
+		rec := serve(e, httptest.NewRequest("GET", settings, nil), map[string]string{"Authorization": "Bearer drui_invalid"})
+		convey.So(rec.Code, convey.ShouldEqual, http.StatusUnauthorized)
+		rec = serve(e, httptest.NewRequest("GET", settings, nil), nil)
+		convey.So(rec.Code, convey.ShouldEqual, http.StatusUnauthorized)
+	})
+}
+
+func TestTrustedProxies(t *testing.T) {
+	_, e := newTestServer(t, `
+registry_url: http://registry.local
+admins: [admin]
+trusted_proxies: [10.0.0.1]
+`)
+	settings := "/api/v1/settings?repository=alpine"
+
+	convey.Convey("Ignore the user header of the requests not from the trusted proxy", t, func() {
+		req := httptest.NewRequest("GET", settings, nil)
+		req.RemoteAddr = "10.0.0.1:40000"
+		rec := serve(e, req, map[string]string{"X-WEBAUTH-USER": "admin"})
+		convey.So(rec.Code, convey.ShouldEqual, http.StatusOK)
+
+		req = httptest.NewRequest("GET", settings, nil)
+		req.RemoteAddr = "192.0.2.10:40000"
+		rec = serve(e, req, map[string]string{"X-WEBAUTH-USER": "admin"})
+		convey.So(rec.Code, convey.ShouldEqual, http.StatusForbidden)
+	})
+}
+
+func TestLoginLockout(t *testing.T) {
+	a, e := newTestServer(t, `
+registry_url: http://registry.local
+local_users: true
+login_max_failures: 3
+login_lockout_minutes: 15
+login_attempts_per_minute: 100
+`)
+	e.POST("/login", a.login)
+	if err := a.eventListener.SetUserPassword("alice", "correct horse"); err != nil {
+		t.Fatal(err)
+	}
+	login := func(password string) int {
+		form := url.Values{"name": {"alice"}, "password": {password}}
+		req := httptest.NewRequest("POST", "/login", strings.NewReader(form.Encode()))
+		return serve(e, req, map[string]string{"Content-Type": "application/x-www-form-urlencoded"}).Code
+	}
+
+	convey.Convey("Lock out the user after the failed logins in a row", t, func() {
+		convey.So(login("wrong"), convey.ShouldEqual, http.StatusUnauthorized)
+		convey.So(login("correct horse"), convey.ShouldEqual, http.StatusSeeOther)
+		for i := 0; i < 3; i++ {
+			convey.So(login("wrong"), convey.ShouldEqual, http.StatusUnauthorized)
+		}
+		convey.So(login("correct horse"), convey.ShouldEqual, http.StatusTooManyRequests)
+	})
+
+	convey.Convey("Authenticate API requests with other authorization schemes by the session", t, func() {
+		basic := map[string]string{"Authorization": "Basic YWxpY2U6c2VjcmV0"}
+		rec := serve(e, httptest.NewRequest("GET", "/api/v1/settings?repository=alpine", nil), basic)
+		convey.So(rec.Code, convey.ShouldEqual, http.StatusUnauthorized)
+		convey.So(rec.Body.String(), convey.ShouldContainSubstring, "login is required")
+
+		token, _ := a.eventListener.CreateSession("alice", time.Hour)
+		req := httptest.NewRequest("GET", "/api/v1/settings?repository=alpine", nil)
+		req.AddCookie(&http.Cookie{Name: sessionCookie, Value: token})
+		rec = serve(e, req, basic)
+		convey.So(rec.Code, convey.ShouldEqual, http.StatusForbidden)
+	})
+}
+
+func TestUploadLimit(t *testing.T) {
+	a, e := newTestServer(t, `
+registry_url: http://registry.local
+admins: [admin]
+transfer_dir: `+t.TempDir()+`
+transfer_max_upload_mb: 1
+tenants:
+  - name: team
+    users: [carol]
+    repos: ["team/*"]
+`)
+	e.POST("/transfer/import", a.importImages)
+	form := func() (*bytes.Buffer, string) {
+		var buf bytes.Buffer
+		w := multipart.NewWriter(&buf)
+		part, _ := w.CreateFormFile("layout", "image.tar")
+		part.Write(make([]byte, 3<<20))
+		w.Close()
+		return &buf, w.FormDataContentType()
+	}
+	// Without the length the body is read until the limit.
+	read := int64(0)
+	upload := func(path string, body io.Reader, contentType string, length int64) int {
+		r := &countingReader{r: body}
+		req := httptest.NewRequest("POST", path, r)
+		req.ContentLength = length
+		code := serve(e, req, map[string]string{"X-WEBAUTH-USER": "admin", "Content-Type": contentType}).Code
+		read = r.n
+		return code
+	}
+
+	convey.Convey("Refuse the uploads over the limit before reading the whole body", t, func() {
+		body, contentType := form()
+		convey.So(upload("/transfer/import", body, contentType, int64(body.Len())), convey.ShouldEqual, http.StatusRequestEntityTooLarge)
+		convey.So(read, convey.ShouldEqual, 0)
+		body, contentType = form()
+		convey.So(upload("/transfer/import", body, contentType, -1), convey.ShouldEqual, http.StatusRequestEntityTooLarge)
+		convey.So(read, convey.ShouldBeLessThan, 3<<20)
+		body, contentType = form()
+		convey.So(upload("/api/v1/upload", body, contentType, -1), convey.ShouldEqual, http.StatusRequestEntityTooLarge)
+		convey.So(read, convey.ShouldBeLessThan, 3<<20)
+		convey.So(upload("/api/v1/upload", bytes.NewReader(make([]byte, 3<<20)), "application/x-tar", -1), convey.ShouldEqual, http.StatusRequestEntityTooLarge)
+		convey.So(read, convey.ShouldBeLessThan, 3<<20)
+
+		files, _ := ioutil.ReadDir(a.config.TransferDir)
+		convey.So(files, convey.ShouldBeEmpty)
+	})
+}
+
+// countingReader reader counting the bytes read.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n += int64(n)
+	return n, err
+}
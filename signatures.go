@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/quiq/docker-registry-ui/policy"
+	"github.com/quiq/docker-registry-ui/signing"
+)
+
+// newSigner create cosign signer if configured, nil otherwise.
+func (a *apiClient) newSigner() *signing.Cosign {
+	if !a.config.feature("signing") {
+		return nil
+	}
+	u, _ := url.Parse(a.config.RegistryURL)
+	registry := signing.Registry{Insecure: u.Scheme == "http" || !a.config.VerifyTLS}
+	registry.Username, registry.Password = a.client.Credentials()
+	return &signing.Cosign{
+		Path:              a.config.SigningCosignPath,
+		Key:               a.config.SigningKey,
+		KeyPassword:       a.config.SigningKeyPassword,
+		IdentityTokenFile: a.config.SigningTokenFile,
+		FulcioURL:         a.config.SigningFulcioURL,
+		RekorURL:          a.config.SigningRekorURL,
+		Registry:          registry,
+		Timeout:           time.Duration(a.config.SigningTimeout) * time.Minute,
+	}
+}
+
+// isSigned whether the cosign signature tag of the digest is among the tags of the repo.
+func isSigned(digest string, tags []string) bool {
+	if digest == "" {
+		return false
+	}
+	sig := policy.SignatureTag(digest)
+	for _, t := range tags {
+		if t == sig {
+			return true
+		}
+	}
+	return false
+}
+
+// signImage sign the image by digest with cosign as a job, the signature is pushed next to it.
+func (a *apiClient) signImage(c echo.Context) error {
+	if !a.config.feature("signing") {
+		return c.String(http.StatusBadRequest, "Image signing is not configured.")
+	}
+	repo := c.FormValue("repo")
+	tag := c.FormValue("tag")
+	meta, err := a.client.TagMetadata(repo, tag)
+	if err != nil {
+		return c.String(http.StatusNotFound, fmt.Sprintf("Cannot find image %s:%s: %s", repo, tag, err))
+	}
+
+	a.trackAction(c, "sign")
+	user, ip := a.setUserPermissions(c)["user"].String(), c.RealIP()
+	j := a.jobs.start(fmt.Sprintf("Sign %s:%s", repo, tag), user, func(j *job) error {
+		image := fmt.Sprintf("%s@%s", a.config.imageName(repo), meta.Digest)
+		// The signer is created here to push with the current credentials, they may be rotated.
+		signer := a.newSigner()
+		if signer.Keyless() {
+			j.logf("Signing %s keyless by OIDC identity", image)
+		} else {
+			j.logf("Signing %s with key %s", image, signer.Key)
+		}
+		out, err := signer.Sign(image)
+		if err != nil {
+			return err
+		}
+		if out != "" {
+			j.logf("%s", out)
+		}
+		a.auditAs(user, ip, "sign", repo, tag, fmt.Sprintf("Signed %s", meta.Digest))
+		j.logf("Signature pushed as %s:%s", repo, policy.SignatureTag(meta.Digest))
+		return nil
+	})
+	return c.Redirect(http.StatusSeeOther, fmt.Sprintf("%s/jobs/%d", a.config.BasePath, j.ID))
+}
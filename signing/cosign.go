@@ -0,0 +1,110 @@
+package signing
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Registry access for cosign to push the signature, the credentials are used for the host of the image.
+type Registry struct {
+	Username string
+	Password string
+	// Insecure registry served over plain HTTP or with TLS certificate not verified.
+	Insecure bool
+}
+
+// Cosign signer running Sigstore cosign CLI, the signature is pushed to the repo of the image
+// as the tag sha256-<digest>.sig.
+type Cosign struct {
+	// Path to cosign binary.
+	Path string
+	// Key file or KMS URI of the signing key, empty for keyless signing.
+	Key         string
+	KeyPassword string
+	// IdentityTokenFile file with OIDC token for keyless signing by Fulcio certificate.
+	IdentityTokenFile string
+	// FulcioURL and RekorURL of private Sigstore instance, empty to use the public one.
+	FulcioURL string
+	RekorURL  string
+	Registry  Registry
+	Timeout   time.Duration
+}
+
+// Keyless whether the image is signed by a short-lived certificate issued for the OIDC identity.
+func (s Cosign) Keyless() bool {
+	return s.Key == ""
+}
+
+// args cosign command line signing the image.
+func (s Cosign) args(image string) []string {
+	args := []string{"sign", "--yes"}
+	if s.Keyless() {
+		args = append(args, "--identity-token", s.IdentityTokenFile)
+	} else {
+		args = append(args, "--key", s.Key)
+	}
+	if s.FulcioURL != "" {
+		args = append(args, "--fulcio-url", s.FulcioURL)
+	}
+	if s.RekorURL != "" {
+		args = append(args, "--rekor-url", s.RekorURL)
+	}
+	if s.Registry.Insecure {
+		args = append(args, "--allow-insecure-registry", "--allow-http-registry")
+	}
+	return append(args, image)
+}
+
+// dockerConfig write docker config with the registry credentials, cosign reads them from DOCKER_CONFIG
+// so they are not exposed in the process list.
+func (s Cosign) dockerConfig(dir, image string) error {
+	auth := base64.StdEncoding.EncodeToString([]byte(s.Registry.Username + ":" + s.Registry.Password))
+	host := strings.SplitN(image, "/", 2)[0]
+	data, err := json.Marshal(map[string]interface{}{"auths": map[string]interface{}{host: map[string]string{"auth": auth}}})
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dir, "config.json"), data, 0600)
+}
+
+// Sign the image by digest reference, e.g. registry.local/team/app@sha256:..., and push the signature.
+// The output of cosign is returned for the job log.
+func (s Cosign) Sign(image string) (string, error) {
+	if !strings.Contains(image, "@") {
+		return "", fmt.Errorf("image should be referenced by digest to sign: %s", image)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), s.Timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, s.Path, s.args(image)...)
+	cmd.Env = append(os.Environ(), "COSIGN_PASSWORD="+s.KeyPassword)
+	if s.Registry.Username != "" {
+		dir, err := ioutil.TempDir("", "cosign")
+		if err != nil {
+			return "", err
+		}
+		defer os.RemoveAll(dir)
+		if err := s.dockerConfig(dir, image); err != nil {
+			return "", err
+		}
+		cmd.Env = append(cmd.Env, "DOCKER_CONFIG="+dir)
+	}
+	var output bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &output, &output
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return output.String(), fmt.Errorf("cosign timed out after %s", s.Timeout)
+		}
+		return output.String(), fmt.Errorf("cosign failed: %s: %s", err, strings.TrimSpace(output.String()))
+	}
+	return strings.TrimSpace(output.String()), nil
+}
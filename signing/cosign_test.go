@@ -0,0 +1,49 @@
+package signing
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestCosign(t *testing.T) {
+	convey.Convey("Build cosign command line", t, func() {
+		s := Cosign{Key: "cosign.key", Registry: Registry{Insecure: true}}
+		convey.So(s.args("r.local/app@sha256:abc"), convey.ShouldResemble, []string{
+			"sign", "--yes", "--key", "cosign.key", "--allow-insecure-registry", "--allow-http-registry", "r.local/app@sha256:abc",
+		})
+
+		s = Cosign{IdentityTokenFile: "/run/token", FulcioURL: "https://fulcio.local", RekorURL: "https://rekor.local"}
+		convey.So(s.Keyless(), convey.ShouldBeTrue)
+		convey.So(s.args("r.local/app@sha256:abc"), convey.ShouldResemble, []string{
+			"sign", "--yes", "--identity-token", "/run/token", "--fulcio-url", "https://fulcio.local",
+			"--rekor-url", "https://rekor.local", "r.local/app@sha256:abc",
+		})
+	})
+
+	convey.Convey("Run cosign with the key password and registry credentials", t, func() {
+		dir, _ := ioutil.TempDir("", "cosign-test")
+		defer os.RemoveAll(dir)
+		// Fake cosign printing its environment and arguments.
+		path := filepath.Join(dir, "cosign")
+		ioutil.WriteFile(path, []byte("#!/bin/sh\necho \"$COSIGN_PASSWORD $*\"\ncat \"$DOCKER_CONFIG/config.json\"\n"), 0700)
+
+		s := Cosign{Path: path, Key: "cosign.key", KeyPassword: "secret", Registry: Registry{Username: "u", Password: "p"}, Timeout: time.Minute}
+		out, err := s.Sign("r.local/app@sha256:abc")
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(out, convey.ShouldEqual, `secret sign --yes --key cosign.key r.local/app@sha256:abc
+{"auths":{"r.local":{"auth":"dTpw"}}}`)
+
+		_, err = s.Sign("r.local/app:latest")
+		convey.So(err, convey.ShouldNotBeNil)
+
+		ioutil.WriteFile(path, []byte("#!/bin/sh\necho no signing key >&2\nexit 1\n"), 0700)
+		_, err = s.Sign("r.local/app@sha256:abc")
+		convey.So(err, convey.ShouldNotBeNil)
+		convey.So(err.Error(), convey.ShouldContainSubstring, "no signing key")
+	})
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// snippet copy-pasteable manifest or command running the image.
+type snippet struct {
+	Title string
+	Text  string
+}
+
+// invalidNameChars characters not allowed in Kubernetes object and compose service names.
+var invalidNameChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// workloadName name of the container derived from the last segment of the repo path, e.g. app for team/app.
+func workloadName(repo string) string {
+	name := strings.ToLower(repo[strings.LastIndex(repo, "/")+1:])
+	name = strings.Trim(invalidNameChars.ReplaceAllString(name, "-"), "-")
+	if len(name) > 63 {
+		name = strings.TrimRight(name[:63], "-")
+	}
+	if name == "" {
+		return "app"
+	}
+	return name
+}
+
+// splitPort port number and protocol of the exposed port, e.g. 53 and udp for 53/udp.
+func splitPort(port string) (string, string) {
+	if i := strings.Index(port, "/"); i >= 0 {
+		return port[:i], strings.ToLower(port[i+1:])
+	}
+	return port, "tcp"
+}
+
+// deploymentSnippets docker run command, docker-compose service, Kubernetes Deployment and Pod
+// running the image with its exposed ports published.
+func deploymentSnippets(image, repo string, ports []string) []snippet {
+	name := workloadName(repo)
+
+	run := []string{"docker run -d --name " + name}
+	compose := []string{"services:", "  " + name + ":", "    image: " + image}
+	var containerPorts []string
+	if len(ports) > 0 {
+		compose = append(compose, "    ports:")
+	}
+	for _, p := range ports {
+		number, protocol := splitPort(p)
+		published := number + ":" + number
+		if protocol != "tcp" {
+			published = published + "/" + protocol
+		}
+		run = append(run, "-p "+published)
+		compose = append(compose, fmt.Sprintf("      - %q", published))
+		containerPorts = append(containerPorts, "  - containerPort: "+number)
+		if protocol != "tcp" {
+			containerPorts = append(containerPorts, "    protocol: "+strings.ToUpper(protocol))
+		}
+	}
+	run = append(run, image)
+
+	// Container spec with the list item indentation, it is nested into Deployment and Pod below.
+	container := []string{"- name: " + name, "  image: " + image}
+	if len(containerPorts) > 0 {
+		container = append(container, "  ports:")
+		container = append(container, containerPorts...)
+	}
+	deployment := append([]string{
+		"apiVersion: apps/v1",
+		"kind: Deployment",
+		"metadata:",
+		"  name: " + name,
+		"spec:",
+		"  replicas: 1",
+		"  selector:",
+		"    matchLabels:",
+		"      app: " + name,
+		"  template:",
+		"    metadata:",
+		"      labels:",
+		"        app: " + name,
+		"    spec:",
+		"      containers:",
+	}, indent(container, "      ")...)
+	pod := append([]string{
+		"apiVersion: v1",
+		"kind: Pod",
+		"metadata:",
+		"  name: " + name,
+		"  labels:",
+		"    app: " + name,
+		"spec:",
+		"  containers:",
+	}, indent(container, "  ")...)
+
+	return []snippet{
+		{"docker run", strings.Join(run, " \\\n  ")},
+		{"docker-compose", strings.Join(compose, "\n")},
+		{"Kubernetes Deployment", strings.Join(deployment, "\n")},
+		{"Kubernetes Pod", strings.Join(pod, "\n")},
+	}
+}
+
+// indent prefix every line.
+func indent(lines []string, prefix string) []string {
+	var out []string
+	for _, l := range lines {
+		out = append(out, prefix+l)
+	}
+	return out
+}
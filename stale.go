@@ -0,0 +1,129 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/quiq/docker-registry-ui/events"
+)
+
+// staleRepo repo with no activity for stale_repo_days, a candidate for decommissioning.
+type staleRepo struct {
+	Repo string
+	Tags int
+	// LastPush by the push events and the new tags recorded, LastBuilt the creation of the newest image,
+	// zero when unknown.
+	LastPush  time.Time
+	LastBuilt time.Time
+	// Size of the distinct images from the manifests, layers shared between them are counted more than once.
+	Size     int64
+	Owner    events.RepoOwner
+	HasOwner bool
+}
+
+// LastActivity the latest of the push and the image creation.
+func (r staleRepo) LastActivity() time.Time {
+	if r.LastBuilt.After(r.LastPush) {
+		return r.LastBuilt
+	}
+	return r.LastPush
+}
+
+// IdleDays days since the last activity, -1 when unknown.
+func (r staleRepo) IdleDays() int {
+	if r.LastActivity().IsZero() {
+		return -1
+	}
+	return int(time.Since(r.LastActivity()).Hours() / 24)
+}
+
+// LastPushDate date of the last push, empty when unknown.
+func (r staleRepo) LastPushDate() string {
+	return dateOrEmpty(r.LastPush)
+}
+
+// LastBuiltDate date of the newest image, empty when unknown.
+func (r staleRepo) LastBuiltDate() string {
+	return dateOrEmpty(r.LastBuilt)
+}
+
+// dateOrEmpty date of the time, empty when it is unknown.
+func dateOrEmpty(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format("2006-01-02")
+}
+
+// staleRepos repos of the scope with no pushes, new tags and newly built images since the cutoff, the longest idle first.
+func (a *apiClient) staleRepos(scope []string, cutoff time.Time) []staleRepo {
+	lastPushes := a.eventListener.GetLastPushes()
+	list := []staleRepo{}
+	for namespace, names := range a.client.Repositories(true) {
+		for _, name := range names {
+			repo := catalogRepoPath(namespace, name)
+			if !inScope(scope, repo) || lastPushes[repo].After(cutoff) {
+				continue
+			}
+			r := staleRepo{Repo: repo, LastPush: lastPushes[repo]}
+			tags := a.client.Tags(repo)
+			r.Tags = len(tags)
+			seen := map[string]bool{}
+			for _, m := range a.client.TagsMetadata(repo, tags) {
+				if m.Created.After(r.LastBuilt) {
+					r.LastBuilt = m.Created
+				}
+				if !seen[m.Digest] {
+					seen[m.Digest] = true
+					r.Size += m.Size
+				}
+			}
+			if r.LastBuilt.After(cutoff) {
+				continue
+			}
+			r.Owner, r.HasOwner = a.repoOwner(repo)
+			list = append(list, r)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if !list[i].LastActivity().Equal(list[j].LastActivity()) {
+			return list[i].LastActivity().Before(list[j].LastActivity())
+		}
+		return list[i].Repo < list[j].Repo
+	})
+	return list
+}
+
+// viewStale report of the repos idle for stale_repo_days as the starting point for decommissioning reviews.
+func (a *apiClient) viewStale(c echo.Context) error {
+	if !a.config.feature("stale") {
+		return c.String(http.StatusNotFound, "Stale repositories report is disabled, see stale_repo_days.")
+	}
+	list := a.staleRepos(a.tenantScope(c), time.Now().AddDate(0, 0, -a.config.StaleRepoDays))
+	if csvRequested(c) {
+		return renderCSV(c, "stale-repos", staleCSV(list))
+	}
+	var size int64
+	for _, r := range list {
+		size += r.Size
+	}
+	data := a.setUserPermissions(c)
+	data.Set("repos", list)
+	data.Set("size", size)
+	data.Set("days", a.config.StaleRepoDays)
+	data.Set("eventsEnabled", a.config.feature("events"))
+	return c.Render(http.StatusOK, "stale.html", data)
+}
+
+// staleCSV table of the stale repos.
+func staleCSV(list []staleRepo) csvTable {
+	t := csvTable{Header: []string{"Repository", "Tags", "Last push", "Last built", "Size", "Owner", "Slack", "Email"}}
+	for _, r := range list {
+		t.Rows = append(t.Rows, []string{r.Repo, strconv.Itoa(r.Tags), r.LastPushDate(), r.LastBuiltDate(),
+			strconv.FormatInt(r.Size, 10), r.Owner.Team, r.Owner.Slack, r.Owner.Email})
+	}
+	return t
+}
@@ -0,0 +1,121 @@
+// Chunked upload of the tarball for import on Transfer page, resumed from the offset received by the server
+// after the connection breaks, also after the page is reloaded and the same file is chosen again.
+$(function() {
+    var form = $('#import-form');
+    var url = form.data('upload-url');
+    var chunkSize = 8 << 20;
+    var maxDelay = 60;
+    var status = $('#upload-status');
+    var bar = status.find('.progress-bar');
+    var message = status.find('.upload-message');
+    var upload = null;
+
+    function key(file) {
+        return 'upload:' + file.name + ':' + file.size + ':' + file.lastModified;
+    }
+
+    function show(offset, size, text) {
+        var percent = Math.floor(offset * 100 / size);
+        bar.css('width', percent + '%').text(percent + '%');
+        message.text(text);
+    }
+
+    function fail(xhr, text) {
+        var error = xhr.responseJSON && xhr.responseJSON.error ? xhr.responseJSON.error : xhr.statusText;
+        message.text(text + ': ' + error + '.');
+        bar.addClass('progress-bar-danger');
+        form.find('button').prop('disabled', false);
+    }
+
+    // begin resume the upload of the file if the server still has it or start a new one.
+    function begin(file, done) {
+        var id = localStorage.getItem(key(file));
+        if (id) {
+            $.getJSON(url + '/' + id).done(done).fail(function() {
+                localStorage.removeItem(key(file));
+                begin(file, done);
+            });
+            return;
+        }
+        $.post(url, {name: file.name, size: file.size}).done(function(u) {
+            localStorage.setItem(key(file), u.id);
+            done(u);
+        }).fail(function(xhr) {
+            fail(xhr, 'Cannot start upload');
+        });
+    }
+
+    // send the chunks from the offset, retrying with growing delay while the connection is down.
+    function send(file, u, delay, done) {
+        upload = u;
+        if (u.offset >= u.size) {
+            done(u);
+            return;
+        }
+        show(u.offset, u.size, 'Uploading ' + file.name + '...');
+        $.ajax({
+            url: url + '/' + u.id + '?offset=' + u.offset,
+            type: 'PATCH',
+            data: file.slice(u.offset, u.offset + chunkSize),
+            contentType: 'application/octet-stream',
+            processData: false
+        }).done(function(next) {
+            send(file, next, 1, done);
+        }).fail(function(xhr) {
+            if (xhr.status == 409) {
+                send(file, xhr.responseJSON, 1, done);
+            } else if (xhr.status == 0 || xhr.status >= 500 || xhr.status == 400) {
+                // The server keeps the bytes received, ask it where to continue from.
+                show(u.offset, u.size, 'Connection lost, retrying in ' + delay + 's...');
+                setTimeout(function() {
+                    $.getJSON(url + '/' + u.id).done(function(current) {
+                        send(file, current, Math.min(delay * 2, maxDelay), done);
+                    }).fail(function(xhr) {
+                        if (xhr.status == 404) {
+                            localStorage.removeItem(key(file));
+                            fail(xhr, 'Upload is lost');
+                        } else {
+                            send(file, u, Math.min(delay * 2, maxDelay), done);
+                        }
+                    });
+                }, delay * 1000);
+            } else {
+                fail(xhr, 'Upload failed');
+            }
+        });
+    }
+
+    form.on('submit', function(e) {
+        var file = form.find('input[type=file]')[0].files[0];
+        if (!file || !window.localStorage || !file.slice) {
+            return true;
+        }
+        e.preventDefault();
+        form.find('button').prop('disabled', true);
+        bar.removeClass('progress-bar-danger');
+        status.show();
+        show(0, file.size, 'Starting upload of ' + file.name + '...');
+        begin(file, function(u) {
+            send(file, u, 1, function(u) {
+                show(u.size, u.size, 'Uploaded, starting import...');
+                $.post(url + '/' + u.id + '/import', {image: form.find('input[name=image]').val()}).done(function(job) {
+                    localStorage.removeItem(key(file));
+                    window.location = form.data('jobs-url') + '/' + job.id;
+                }).fail(function(xhr) {
+                    fail(xhr, 'Cannot import');
+                });
+            });
+        });
+    });
+
+    status.find('.upload-cancel').on('click', function() {
+        var file = form.find('input[type=file]')[0].files[0];
+        if (upload) {
+            $.ajax({url: url + '/' + upload.id, type: 'DELETE'});
+        }
+        if (file) {
+            localStorage.removeItem(key(file));
+        }
+        window.location.reload();
+    });
+});
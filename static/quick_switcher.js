@@ -0,0 +1,86 @@
+// Quick switcher: press Ctrl-K (Cmd-K on Mac) to jump to a repository or tag by name.
+$(function() {
+    var overlay = $('#quick-switcher');
+    var input = overlay.find('input');
+    var list = overlay.find('.list-group');
+    var url = overlay.data('suggest-url');
+    var selected = 0;
+    var timer = null;
+    var request = null;
+
+    function open() {
+        overlay.show();
+        input.val('').focus();
+        list.empty();
+    }
+
+    function close() {
+        overlay.hide();
+    }
+
+    function select(i) {
+        var items = list.children();
+        if (items.length == 0) {
+            return;
+        }
+        selected = (i + items.length) % items.length;
+        items.removeClass('active').eq(selected).addClass('active');
+    }
+
+    function suggest() {
+        var q = $.trim(input.val());
+        if (request) {
+            request.abort();
+        }
+        if (q == '') {
+            list.empty();
+            return;
+        }
+        request = $.getJSON(url, {q: q, limit: 10}, function(data) {
+            list.empty();
+            $.each(data.suggestions, function(i, s) {
+                list.append($('<a class="list-group-item">').attr('href', s.url).text(s.name));
+            });
+            if (data.suggestions.length == 0) {
+                list.append($('<span class="list-group-item text-muted">').text('Nothing found, the index is rebuilt on tag counts refresh.'));
+            }
+            select(0);
+        });
+    }
+
+    $(document).on('keydown', function(e) {
+        if ((e.ctrlKey || e.metaKey) && (e.key == 'k' || e.key == 'K')) {
+            e.preventDefault();
+            overlay.is(':visible') ? close() : open();
+        } else if (e.key == 'Escape' && overlay.is(':visible')) {
+            close();
+        }
+    });
+
+    input.on('keydown', function(e) {
+        if (e.key == 'ArrowDown') {
+            e.preventDefault();
+            select(selected + 1);
+        } else if (e.key == 'ArrowUp') {
+            e.preventDefault();
+            select(selected - 1);
+        } else if (e.key == 'Enter') {
+            e.preventDefault();
+            var href = list.children('.active').attr('href');
+            if (href) {
+                window.location = href;
+            }
+        }
+    });
+
+    input.on('input', function() {
+        clearTimeout(timer);
+        timer = setTimeout(suggest, 150);
+    });
+
+    overlay.on('click', function(e) {
+        if (e.target === this) {
+            close();
+        }
+    });
+});
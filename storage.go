@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+
+	"github.com/labstack/echo/v4"
+	"github.com/quiq/docker-registry-ui/storage"
+)
+
+// storageUsage the latest report of the registry storage scan.
+type storageUsage struct {
+	mux      sync.Mutex
+	report   *storage.Report
+	err      string
+	scanning bool
+}
+
+// storageDriver access to the registry storage if configured, nil otherwise.
+func (a *apiClient) storageDriver() storage.Driver {
+	if !a.config.feature("storage") {
+		return nil
+	}
+	switch a.config.StorageDriver {
+	case "filesystem":
+		return storage.Filesystem{Root: a.config.StorageRoot}
+	case "s3":
+		s := storage.S3{
+			Bucket:        a.config.StorageS3Bucket,
+			Region:        a.config.StorageS3Region,
+			Endpoint:      a.config.StorageS3Endpoint,
+			AccessKey:     a.config.StorageS3AccessKey,
+			SecretKey:     a.config.StorageS3SecretKey,
+			RootDirectory: a.config.StorageS3RootDirectory,
+		}
+		// Fall back to the standard AWS environment variables.
+		if s.AccessKey == "" {
+			s.AccessKey = os.Getenv("AWS_ACCESS_KEY_ID")
+			s.SecretKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+			s.SessionToken = os.Getenv("AWS_SESSION_TOKEN")
+		}
+		return s
+	}
+	return nil
+}
+
+// viewStorage show the actual storage usage from the latest scan.
+func (a *apiClient) viewStorage(c echo.Context) error {
+	data := a.setUserPermissions(c)
+	driver := a.storageDriver()
+	data.Set("configured", driver != nil)
+	data.Set("gcConfigured", a.gcConfigured())
+	if driver != nil {
+		data.Set("location", driver.String())
+	}
+	a.storage.mux.Lock()
+	if csvRequested(c) {
+		report := a.storage.report
+		a.storage.mux.Unlock()
+		if report == nil {
+			return c.String(http.StatusNotFound, "Storage is not scanned yet.")
+		}
+		return renderCSV(c, "storage-usage", storageCSV(*report))
+	}
+	data.Set("scanned", a.storage.report != nil)
+	if a.storage.report != nil {
+		data.Set("report", *a.storage.report)
+	}
+	data.Set("scanError", a.storage.err)
+	data.Set("scanning", a.storage.scanning)
+	a.storage.mux.Unlock()
+	return c.Render(http.StatusOK, "storage.html", data)
+}
+
+// scanStorage start scanning the storage as a job, it reads the whole blob store so it may take a while.
+func (a *apiClient) scanStorage(c echo.Context) error {
+	driver := a.storageDriver()
+	if driver == nil {
+		return c.String(http.StatusBadRequest, "Storage driver is not configured.")
+	}
+	a.storage.mux.Lock()
+	defer a.storage.mux.Unlock()
+	if a.storage.scanning {
+		return c.Redirect(http.StatusSeeOther, a.config.BasePath+"/storage")
+	}
+	a.storage.scanning = true
+	a.pages.invalidate()
+	user := a.setUserPermissions(c)["user"].String()
+	j := a.jobs.start("Scan registry storage", user, func(j *job) error {
+		j.logf("Scanning %s", driver)
+		report, err := storage.Scan(driver)
+		defer a.pages.invalidate()
+		a.storage.mux.Lock()
+		defer a.storage.mux.Unlock()
+		a.storage.scanning = false
+		if err != nil {
+			a.storage.err = err.Error()
+			return err
+		}
+		a.storage.report, a.storage.err = &report, ""
+		j.logf("Found %d blobs of %d bytes, %d orphaned blobs of %d bytes",
+			report.Blobs, report.Size, len(report.Orphaned), report.OrphanedSize)
+		return nil
+	})
+	a.trackAction(c, "storage-scan")
+	return c.Redirect(http.StatusSeeOther, fmt.Sprintf("%s/jobs/%d", a.config.BasePath, j.ID))
+}
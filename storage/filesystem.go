@@ -0,0 +1,44 @@
+package storage
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// Filesystem storage of the registry using filesystem driver, e.g. the volume mounted to both containers.
+type Filesystem struct {
+	Root string
+}
+
+// Walk call fn for every file under the prefix.
+func (f Filesystem) Walk(prefix string, fn func(path string, size int64) error) error {
+	dir := filepath.Join(f.Root, filepath.FromSlash(prefix))
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(f.Root, path)
+		if err != nil {
+			return err
+		}
+		return fn(filepath.ToSlash(rel), info.Size())
+	})
+	// Nothing is stored yet.
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// Read the file.
+func (f Filesystem) Read(path string) ([]byte, error) {
+	return ioutil.ReadFile(filepath.Join(f.Root, filepath.FromSlash(path)))
+}
+
+func (f Filesystem) String() string {
+	return f.Root
+}
@@ -0,0 +1,189 @@
+package storage
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// emptyHash sha256 of empty payload.
+const emptyHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// S3 storage of the registry using s3 driver, read by listing the bucket.
+type S3 struct {
+	Bucket string
+	Region string
+	// Endpoint of S3 compatible storage, e.g. minio, path-style requests are used then.
+	Endpoint  string
+	AccessKey string
+	SecretKey string
+	// SessionToken for temporary credentials.
+	SessionToken string
+	// RootDirectory the same as rootdirectory option of the registry s3 driver.
+	RootDirectory string
+	Client        *http.Client
+}
+
+type listBucketResult struct {
+	Contents []struct {
+		Key  string `xml:"Key"`
+		Size int64  `xml:"Size"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// Walk call fn for every object under the prefix.
+func (s S3) Walk(prefix string, fn func(path string, size int64) error) error {
+	root := s.root()
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {root + prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		data, err := s.get("", query)
+		if err != nil {
+			return fmt.Errorf("cannot list s3 bucket %s: %s", s.Bucket, err)
+		}
+		var res listBucketResult
+		if err := xml.Unmarshal(data, &res); err != nil {
+			return err
+		}
+		for _, o := range res.Contents {
+			if err := fn(strings.TrimPrefix(o.Key, root), o.Size); err != nil {
+				return err
+			}
+		}
+		if !res.IsTruncated {
+			return nil
+		}
+		token = res.NextContinuationToken
+	}
+}
+
+// Read the object.
+func (s S3) Read(path string) ([]byte, error) {
+	data, err := s.get(s.root()+path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read s3 object %s: %s", path, err)
+	}
+	return data, nil
+}
+
+// root prefix of the registry keys in the bucket.
+func (s S3) root() string {
+	root := strings.Trim(s.RootDirectory, "/")
+	if root != "" {
+		root = root + "/"
+	}
+	return root
+}
+
+func (s S3) String() string {
+	return fmt.Sprintf("s3://%s/%s", s.Bucket, strings.Trim(s.RootDirectory, "/"))
+}
+
+// get the object by key, or list the bucket objects by empty key.
+func (s S3) get(key string, query url.Values) ([]byte, error) {
+	u := &url.URL{Scheme: "https", Host: fmt.Sprintf("%s.s3.%s.amazonaws.com", s.Bucket, s.Region), Path: "/" + key}
+	if s.Endpoint != "" {
+		e, err := url.Parse(s.Endpoint)
+		if err != nil {
+			return nil, err
+		}
+		u = &url.URL{Scheme: e.Scheme, Host: e.Host, Path: "/" + s.Bucket}
+		if key != "" {
+			u.Path = u.Path + "/" + key
+		}
+	}
+	u.RawQuery = canonicalQuery(query)
+
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	s.sign(req, time.Now().UTC())
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("%s %s", resp.Status, data)
+	}
+	return data, nil
+}
+
+// sign add AWS Signature Version 4 headers to the request without payload.
+func (s S3) sign(req *http.Request, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", emptyHash)
+	signed := []string{"host", "x-amz-content-sha256", "x-amz-date"}
+	headers := "host:" + req.URL.Host + "\nx-amz-content-sha256:" + emptyHash + "\nx-amz-date:" + amzDate + "\n"
+	if s.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.SessionToken)
+		signed = append(signed, "x-amz-security-token")
+		headers = headers + "x-amz-security-token:" + s.SessionToken + "\n"
+	}
+
+	canonicalRequest := strings.Join([]string{
+		req.Method, req.URL.EscapedPath(), req.URL.RawQuery, headers, strings.Join(signed, ";"), emptyHash,
+	}, "\n")
+	scope := date + "/" + s.Region + "/s3/aws4_request"
+	hash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hash[:])
+
+	key := hmacSHA256([]byte("AWS4"+s.SecretKey), date)
+	key = hmacSHA256(key, s.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.AccessKey, scope, strings.Join(signed, ";"), signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// canonicalQuery encode query sorted by keys with spaces as %20 as required for signing.
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, k := range keys {
+		for _, v := range query[k] {
+			parts = append(parts, escape(k)+"="+escape(v))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+func escape(s string) string {
+	return strings.Replace(url.QueryEscape(s), "+", "%20", -1)
+}
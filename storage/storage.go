@@ -0,0 +1,178 @@
+package storage
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+)
+
+// rootPrefix where the registry keeps its data under the storage root.
+const rootPrefix = "docker/registry/v2/"
+
+// Driver read access to the registry storage backend.
+type Driver interface {
+	// Walk call fn for every file under the prefix relative to the registry storage root.
+	Walk(prefix string, fn func(path string, size int64) error) error
+	// Read the file by path relative to the registry storage root.
+	Read(path string) ([]byte, error)
+	// String describe the storage location.
+	String() string
+}
+
+// Blob blob file in the storage.
+type Blob struct {
+	Digest string
+	Size   int64
+}
+
+// RepoUsage storage used by the repository, blobs shared with other repos are counted for each of them.
+type RepoUsage struct {
+	Name      string
+	Layers    int
+	Manifests int
+	Size      int64
+}
+
+// Report storage usage calculated from the actual blob files.
+type Report struct {
+	Location     string
+	Started      time.Time
+	Duration     time.Duration
+	Blobs        int
+	Size         int64
+	Orphaned     []Blob
+	OrphanedSize int64
+	Repos        []RepoUsage
+}
+
+// Scan walk the storage to calculate the usage by repos and find blobs not referenced from any repo.
+// Blobs are referenced by the manifests linked from _manifests/revisions which are read for their configs
+// and layers, the _layers links are left after deleting the manifest so they tell nothing.
+// Orphaned blobs are left after deleting images until the registry garbage collection runs.
+func Scan(d Driver) (Report, error) {
+	r := Report{Location: d.String(), Started: time.Now()}
+
+	blobs := map[string]int64{}
+	err := d.Walk(rootPrefix+"blobs/", func(path string, size int64) error {
+		// blobs/sha256/ab/abcdef.../data
+		f := strings.Split(strings.TrimPrefix(path, rootPrefix+"blobs/"), "/")
+		if len(f) == 4 && f[3] == "data" {
+			blobs[f[0]+":"+f[2]] = size
+		}
+		return nil
+	})
+	if err != nil {
+		return r, err
+	}
+
+	manifests := map[string]map[string]bool{}
+	err = d.Walk(rootPrefix+"repositories/", func(path string, size int64) error {
+		// repositories/<name>/_manifests/revisions/sha256/abcdef.../link
+		path = strings.TrimPrefix(path, rootPrefix+"repositories/")
+		i := strings.Index(path, "/_manifests/revisions/")
+		if i <= 0 || !strings.HasSuffix(path, "/link") {
+			return nil
+		}
+		f := strings.Split(path[i+len("/_manifests/revisions/"):], "/")
+		if len(f) != 3 {
+			return nil
+		}
+		name := path[:i]
+		if manifests[name] == nil {
+			manifests[name] = map[string]bool{}
+		}
+		manifests[name][f[0]+":"+f[1]] = true
+		return nil
+	})
+	if err != nil {
+		return r, err
+	}
+
+	referenced := map[string]bool{}
+	// Manifests shared by repos are read once.
+	refs := map[string][]string{}
+	for name, digests := range manifests {
+		u := RepoUsage{Name: name, Manifests: len(digests)}
+		layers := map[string]bool{}
+		for digest := range digests {
+			referenced[digest] = true
+			u.Size = u.Size + blobs[digest]
+			if _, ok := refs[digest]; !ok {
+				if _, ok := blobs[digest]; ok {
+					if refs[digest], err = manifestReferences(d, digest); err != nil {
+						return r, err
+					}
+				}
+			}
+			for _, ref := range refs[digest] {
+				referenced[ref] = true
+				// Manifests of the index are counted by their own revision links.
+				if !digests[ref] && !layers[ref] {
+					layers[ref] = true
+					u.Layers++
+					u.Size = u.Size + blobs[ref]
+				}
+			}
+		}
+		r.Repos = append(r.Repos, u)
+	}
+
+	for digest, size := range blobs {
+		r.Blobs++
+		r.Size = r.Size + size
+		if !referenced[digest] {
+			r.Orphaned = append(r.Orphaned, Blob{Digest: digest, Size: size})
+			r.OrphanedSize = r.OrphanedSize + size
+		}
+	}
+	sort.Slice(r.Orphaned, func(i, j int) bool { return r.Orphaned[i].Size > r.Orphaned[j].Size })
+	sort.Slice(r.Repos, func(i, j int) bool { return r.Repos[i].Size > r.Repos[j].Size })
+	r.Duration = time.Now().Sub(r.Started)
+	return r, nil
+}
+
+// manifestReferences digests of the config, layers and manifests of the index referenced by the manifest blob.
+// Blobs which are not manifests reference nothing.
+func manifestReferences(d Driver, digest string) ([]string, error) {
+	f := strings.SplitN(digest, ":", 2)
+	if len(f) != 2 || len(f[1]) < 2 {
+		return nil, nil
+	}
+	data, err := d.Read(rootPrefix + "blobs/" + f[0] + "/" + f[1][:2] + "/" + f[1] + "/data")
+	if err != nil {
+		return nil, err
+	}
+	var m struct {
+		Config struct {
+			Digest string `json:"digest"`
+		} `json:"config"`
+		Layers []struct {
+			Digest string `json:"digest"`
+		} `json:"layers"`
+		Manifests []struct {
+			Digest string `json:"digest"`
+		} `json:"manifests"`
+		// FSLayers of schema 1 manifests.
+		FSLayers []struct {
+			BlobSum string `json:"blobSum"`
+		} `json:"fsLayers"`
+	}
+	if json.Unmarshal(data, &m) != nil {
+		return nil, nil
+	}
+	var refs []string
+	if m.Config.Digest != "" {
+		refs = append(refs, m.Config.Digest)
+	}
+	for _, l := range m.Layers {
+		refs = append(refs, l.Digest)
+	}
+	for _, l := range m.Manifests {
+		refs = append(refs, l.Digest)
+	}
+	for _, l := range m.FSLayers {
+		refs = append(refs, l.BlobSum)
+	}
+	return refs, nil
+}
@@ -5,6 +5,7 @@ import (
 	"io"
 	"net/url"
 	"strings"
+	"time"
 
 	"github.com/CloudyKit/jet"
 	"github.com/labstack/echo/v4"
@@ -34,13 +35,28 @@ func (r *Template) Render(w io.Writer, name string, data interface{}, c echo.Con
 	return nil
 }
 
+// renderStream render the template straight to the response instead of building the whole page in memory first,
+// so the big tables start arriving sooner. The status cannot be changed once the page is being sent.
+func renderStream(c echo.Context, code int, name string, data jet.VarMap) error {
+	c.Response().Header().Set(echo.HeaderContentType, echo.MIMETextHTMLCharsetUTF8)
+	c.Response().WriteHeader(code)
+	return c.Echo().Renderer.Render(c.Response(), name, data, c)
+}
+
 // setupRenderer template engine init.
-func setupRenderer(debug bool, registryHost, basePath string) *Template {
-	view := jet.NewHTMLSet("templates")
-	view.SetDevelopmentMode(debug)
+func setupRenderer(config configData, registryHost string) *Template {
+	// The first directory having the template wins, so the overrides shadow the built-in templates
+	// including base.html they extend.
+	dirs := []string{"templates"}
+	if config.TemplatesOverrideDir != "" {
+		dirs = append([]string{config.TemplatesOverrideDir}, dirs...)
+	}
+	view := jet.NewHTMLSet(dirs...)
+	view.SetDevelopmentMode(config.Debug)
 
 	view.AddGlobal("version", version)
-	view.AddGlobal("basePath", basePath)
+	view.AddGlobal("basePath", config.BasePath)
+	view.AddGlobal("noscriptMode", config.NoscriptMode)
 	view.AddGlobal("registryHost", registryHost)
 	view.AddGlobal("pretty_size", func(size interface{}) string {
 		var value float64
@@ -49,6 +65,8 @@ func setupRenderer(debug bool, registryHost, basePath string) *Template {
 			value = float64(i.Int())
 		case int64:
 			value = float64(i)
+		case int:
+			value = float64(i)
 		}
 		return registry.PrettySize(value)
 	})
@@ -60,10 +78,46 @@ func setupRenderer(debug bool, registryHost, basePath string) *Template {
 	view.AddGlobal("parse_map", func(m interface{}) string {
 		var res string
 		for _, k := range registry.SortedMapKeys(m) {
-			res = res + fmt.Sprintf(`<tr><td style="padding: 0 10px; width: 20%%">%s</td><td style="padding: 0 10px">%v</td></tr>`, k, m.(map[string]interface{})[k])
+			v := m.(map[string]interface{})[k]
+			// Lists like Windows os.features.
+			if list, ok := v.([]interface{}); ok {
+				var items []string
+				for _, i := range list {
+					items = append(items, fmt.Sprintf("%v", i))
+				}
+				v = strings.Join(items, ", ")
+			}
+			res = res + fmt.Sprintf(`<tr><td style="padding: 0 10px; width: 20%%">%s</td><td style="padding: 0 10px">%v</td></tr>`, k, v)
 		}
 		return res
 	})
+	view.AddGlobal("age_class", func(days int) string {
+		// Bootstrap label class according to the image age thresholds.
+		switch {
+		case days < 0:
+			return "default"
+		case config.ImageAgeCriticalDays > 0 && days >= config.ImageAgeCriticalDays:
+			return "danger"
+		case config.ImageAgeWarningDays > 0 && days >= config.ImageAgeWarningDays:
+			return "warning"
+		}
+		return "success"
+	})
+	view.AddGlobal("pretty_duration", func(d time.Duration) string {
+		if d < time.Millisecond {
+			return d.String()
+		}
+		return d.Round(time.Millisecond).String()
+	})
+	view.AddGlobal("join_list", func(list []string) string {
+		return strings.Join(list, ", ")
+	})
+	view.AddGlobal("severity_badges", severityBadges)
+	view.AddGlobal("feature", config.feature)
+	view.AddGlobal("image_name", func(repo string) string {
+		return config.imageName(repo)
+	})
+	view.AddGlobal("repo_url", repoURLPath)
 	view.AddGlobal("url_decode", func(m interface{}) string {
 		res, err := url.PathUnescape(m.(string))
 		if err != nil {
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/quiq/docker-registry-ui/registry"
+)
+
+// tenantSearchFactor how many times more search results to take before filtering them by the tenant scope.
+const tenantSearchFactor = 10
+
+// tenant user group of the proxy and the repos visible to its members.
+type tenant struct {
+	// Group name as sent by the proxy in groups_header, e.g. from OIDC groups claim.
+	Group string `yaml:"group"`
+	// Users members of the tenant regardless of the groups header, e.g. to view the UI as them.
+	Users []string `yaml:"users"`
+	// Repos repo paths, a trailing * matches by prefix.
+	Repos []string `yaml:"repos"`
+}
+
+// tenantScope repo patterns visible to the user of the request, nil when not restricted:
+// tenants are not configured or the user is admin. Users outside any tenant see no repos.
+func (a *apiClient) tenantScope(c echo.Context) []string {
+	if len(a.config.Tenants) == 0 {
+		return nil
+	}
+	data := a.setUserPermissions(c)
+	if data["isAdmin"].Bool() {
+		return nil
+	}
+	user := data["user"].String()
+	// The groups header is of the real user, so only the users lists count when admin views the UI as another user.
+	var groups []string
+	if data["viewAs"].String() == "" {
+		groups = a.requestGroups(c)
+	}
+	scope := []string{}
+	for _, t := range a.config.Tenants {
+		if (t.Group != "" && registry.ItemInSlice(t.Group, groups)) || (user != "" && registry.ItemInSlice(user, t.Users)) {
+			scope = append(scope, t.Repos...)
+		}
+	}
+	return scope
+}
+
+// inScope whether the repo is visible within the tenant scope.
+func inScope(scope []string, repoPath string) bool {
+	return scope == nil || repoPatternsMatch(scope, repoPath)
+}
+
+// catalogRepoPath repo path of the catalog or search entry, repos of library namespace are without it.
+func catalogRepoPath(namespace, repo string) string {
+	if namespace == "library" || namespace == "" {
+		return repo
+	}
+	return namespace + "/" + repo
+}
+
+// requestedRepo repo the request is about by the route or the parameters, empty if none.
+func requestedRepo(c echo.Context) string {
+	if c.Param("namespace") != "" && c.Param("repo") != "" {
+		repo, _ := url.PathUnescape(catalogRepoPath(c.Param("namespace"), c.Param("repo")))
+		return repo
+	}
+	// Forms and API calls pass the repo as "repository" or "repo" parameter.
+	for _, name := range []string{"repository", "repo"} {
+		if v := strings.Trim(c.FormValue(name), "/"); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// tenancy deny access to the repos outside the tenant scope of the user, the lists are filtered by the handlers.
+func (a *apiClient) tenancy(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if len(a.config.Tenants) == 0 {
+			return next(c)
+		}
+		// The form is read only for the scoped users, so the uploads of admins are parsed after the handler limits them.
+		scope := a.tenantScope(c)
+		if scope == nil {
+			return next(c)
+		}
+		if repo := requestedRepo(c); repo != "" && !inScope(scope, repo) {
+			return a.renderError(c, http.StatusNotFound, fmt.Sprintf("Repository %s is not found.", repo))
+		}
+		return next(c)
+	}
+}
+
+// visibleNamespaces namespaces with any repo in the tenant scope.
+func (a *apiClient) visibleNamespaces(scope []string) []string {
+	all := a.client.Namespaces()
+	if scope == nil {
+		return all
+	}
+	namespaces := []string{}
+	for _, ns := range all {
+		for _, r := range a.catalogRepos(ns) {
+			if inScope(scope, catalogRepoPath(r.Namespace, r.Repo)) {
+				namespaces = append(namespaces, ns)
+				break
+			}
+		}
+	}
+	return namespaces
+}
+
+// scopedCatalogPage page of the catalog within the tenant scope, the pages are read on until filled up.
+func (a *apiClient) scopedCatalogPage(scope []string, namespace, after string, limit int) ([]registry.CatalogRepo, string) {
+	if scope == nil {
+		return a.client.CatalogPage(namespace, after, limit)
+	}
+	page := []registry.CatalogRepo{}
+	for {
+		repos, next := a.client.CatalogPage(namespace, after, limit)
+		for _, r := range repos {
+			if !inScope(scope, catalogRepoPath(r.Namespace, r.Repo)) {
+				continue
+			}
+			page = append(page, r)
+			if len(page) == limit {
+				return page, r.Namespace + "/" + r.Repo
+			}
+		}
+		if next == "" {
+			return page, ""
+		}
+		after = next
+	}
+}
+
+// scopedSearchLimit how many results to search for to have the limit left within the tenant scope in most cases.
+func scopedSearchLimit(scope []string, limit int) int {
+	if scope == nil {
+		return limit
+	}
+	return limit * tenantSearchFactor
+}
+
+// scopedSearch search results within the tenant scope.
+func scopedSearch(scope []string, results []registry.SearchResult, limit int) []registry.SearchResult {
+	if scope == nil {
+		return results
+	}
+	list := []registry.SearchResult{}
+	for _, r := range results {
+		if inScope(scope, catalogRepoPath(r.Namespace, r.Repo)) && len(list) < limit {
+			list = append(list, r)
+		}
+	}
+	return list
+}
@@ -0,0 +1,326 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/quiq/docker-registry-ui/registry"
+)
+
+// exportNameRegexp names of the exported tarballs, also to refuse other files for download.
+var exportNameRegexp = regexp.MustCompile(`^export-[0-9]{8}-[0-9]{6}-[0-9]+\.tar$`)
+
+// exportFile tarball exported for transfer.
+type exportFile struct {
+	Name    string
+	Size    int64
+	Created time.Time
+}
+
+// exportFiles the exported tarballs, the latest first.
+func (a *apiClient) exportFiles() []exportFile {
+	list := []exportFile{}
+	files, err := ioutil.ReadDir(a.config.TransferDir)
+	if err != nil {
+		a.logger.Errorf("Cannot list %s: %s", a.config.TransferDir, err)
+		return list
+	}
+	for _, f := range files {
+		if exportNameRegexp.MatchString(f.Name()) {
+			list = append(list, exportFile{Name: f.Name(), Size: f.Size(), Created: f.ModTime()})
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name > list[j].Name })
+	return list
+}
+
+// parseImageList images of the list one per line as repo:tag, the tag is latest when omitted.
+func parseImageList(text string) ([]registry.LayoutImage, error) {
+	var list []registry.LayoutImage
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		image := registry.LayoutImage{Repo: line, Tag: "latest"}
+		if i := strings.LastIndex(line, ":"); i > strings.LastIndex(line, "/") {
+			image.Repo, image.Tag = line[:i], line[i+1:]
+		}
+		if !registry.ValidRepoName(image.Repo) || !registry.ValidTagName(image.Tag) {
+			return nil, fmt.Errorf("invalid image %q, expected repo:tag", line)
+		}
+		list = append(list, image)
+	}
+	if len(list) == 0 {
+		return nil, fmt.Errorf("no images to export")
+	}
+	return list, nil
+}
+
+// viewTransfer export and import images as OCI layout tarballs for air-gapped registries.
+func (a *apiClient) viewTransfer(c echo.Context) error {
+	if !a.config.feature("transfer") {
+		return c.String(http.StatusNotFound, "Image transfer is disabled, see transfer_dir.")
+	}
+	data := a.setUserPermissions(c)
+	data.Set("exports", a.exportFiles())
+	data.Set("images", c.QueryParam("images"))
+	data.Set("maxUpload", a.uploadLimit())
+	return c.Render(http.StatusOK, "transfer.html", data)
+}
+
+// exportImages start the job writing the images with their blobs to OCI layout tarball in transfer_dir.
+func (a *apiClient) exportImages(c echo.Context) error {
+	if !a.config.feature("transfer") {
+		return c.String(http.StatusNotFound, "Image transfer is disabled, see transfer_dir.")
+	}
+	images, err := parseImageList(c.FormValue("images"))
+	if err != nil {
+		return c.String(http.StatusBadRequest, fmt.Sprintf("Cannot export: %s.", err))
+	}
+	data := a.setUserPermissions(c)
+	a.trackAction(c, "export")
+	for _, image := range images {
+		a.audit(c, "export", image.Repo, image.Tag, "")
+	}
+	j := a.jobs.start(fmt.Sprintf("Export %d images", len(images)), data["user"].String(), func(j *job) error {
+		name := fmt.Sprintf("export-%s-%d.tar", time.Now().Format("20060102-150405"), j.ID)
+		path := filepath.Join(a.config.TransferDir, name)
+		f, err := os.Create(path + ".partial")
+		if err != nil {
+			return err
+		}
+		j.progress(0, len(images))
+		size, err := a.client.ExportLayout(f, images, func(i int, image registry.LayoutImage) {
+			j.logf("Exported %s", image)
+			j.progress(i+1, len(images))
+		})
+		f.Close()
+		if err == nil {
+			err = os.Rename(path+".partial", path)
+		}
+		if err != nil {
+			os.Remove(path + ".partial")
+			return err
+		}
+		j.logf("Done, %s written to %s, download it from Transfer page", registry.PrettySize(float64(size)), name)
+		if exports := a.exportFiles(); len(exports) > a.config.TransferKeepExports {
+			for _, old := range exports[a.config.TransferKeepExports:] {
+				j.logf("Removing old export %s", old.Name)
+				os.Remove(filepath.Join(a.config.TransferDir, old.Name))
+			}
+		}
+		return nil
+	})
+	return c.Redirect(http.StatusSeeOther, fmt.Sprintf("%s/jobs/%d", a.config.BasePath, j.ID))
+}
+
+// downloadExport download the exported tarball.
+func (a *apiClient) downloadExport(c echo.Context) error {
+	name := c.Param("name")
+	if !a.config.feature("transfer") || !exportNameRegexp.MatchString(name) {
+		return c.String(http.StatusNotFound, "Export not found.")
+	}
+	path := filepath.Join(a.config.TransferDir, name)
+	if _, err := os.Stat(path); err != nil {
+		return c.String(http.StatusNotFound, "Export not found.")
+	}
+	a.trackAction(c, "download")
+	return c.Attachment(path, name)
+}
+
+// importItem image of the uploaded tarball and the name to push it under.
+type importItem struct {
+	image     registry.LayoutImage
+	repo, tag string
+}
+
+// saveUpload write the uploaded tarball to transfer_dir and open it as OCI image layout or docker save tarball.
+// The file is removed on error, also when it is over transfer_max_upload_mb.
+func (a *apiClient) saveUpload(body io.Reader) (*os.File, *registry.Layout, error) {
+	f, err := ioutil.TempFile(a.config.TransferDir, "import-*.tar")
+	if err != nil {
+		return nil, nil, err
+	}
+	if limit := a.uploadLimit(); limit > 0 {
+		body = io.LimitReader(body, limit+1)
+	}
+	n, err := io.Copy(f, body)
+	if err == nil && a.uploadLimit() > 0 && n > a.uploadLimit() {
+		err = errUploadTooLarge
+	}
+	if err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, nil, err
+	}
+	layout, err := registry.OpenLayout(f)
+	if err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, nil, err
+	}
+	return f, layout, nil
+}
+
+// importItems the images of the tarball to push under their names, or the only image of it under the chosen repo:tag.
+func importItems(layout *registry.Layout, target string) ([]importItem, error) {
+	images := layout.Images()
+	var items []importItem
+	if target == "" {
+		for _, image := range images {
+			items = append(items, importItem{image: image, repo: image.Repo, tag: image.Tag})
+		}
+		return items, nil
+	}
+	names, err := parseImageList(target)
+	if err != nil {
+		return nil, err
+	}
+	if len(names) != 1 {
+		return nil, fmt.Errorf("expected one image to push as")
+	}
+	// docker save lists the image once per name, it is still one image.
+	for _, image := range images {
+		if len(items) == 0 || items[0].image.Digest != image.Digest {
+			items = append(items, importItem{image: image, repo: names[0].Repo, tag: names[0].Tag})
+		}
+	}
+	if len(items) != 1 {
+		return nil, fmt.Errorf("the tarball has %d images, only one can be pushed as %s", len(items), names[0])
+	}
+	return items, nil
+}
+
+// startImport start the job pushing the images of the uploaded tarball, the file is removed when done.
+func (a *apiClient) startImport(c echo.Context, f *os.File, layout *registry.Layout, items []importItem, source string) *job {
+	data := a.setUserPermissions(c)
+	user, ip := data["user"].String(), c.RealIP()
+	a.trackAction(c, "import")
+	return a.jobs.start("Import "+source, user, func(j *job) error {
+		defer os.Remove(f.Name())
+		defer f.Close()
+		imported := 0
+		for _, item := range items {
+			name := item.repo + ":" + item.tag
+			switch {
+			case item.tag == "":
+				j.logf("Skipping %s, the image is not named", item.image.Digest)
+				continue
+			case item.repo == "":
+				j.logf("Skipping %s, the layout names only the tag", item.tag)
+				continue
+			case !registry.ValidRepoName(item.repo) || !registry.ValidTagName(item.tag):
+				j.logf("Skipping %s, invalid image name", name)
+				continue
+			}
+			j.logf("Pushing %s", name)
+			var pushed int64
+			err := a.client.ImportLayout(layout, item.image, item.repo, item.tag, func(done, total int, size int64) {
+				j.progress(done, total)
+				pushed = size
+			})
+			if err != nil {
+				return err
+			}
+			j.logf("Pushed %s, %s of blobs", name, registry.PrettySize(float64(pushed)))
+			a.auditAs(user, ip, "import", item.repo, item.tag, "Imported from "+source)
+			imported++
+		}
+		j.logf("Refreshing the list of repositories")
+		a.client.Repositories(false)
+		j.logf("Done, %d of %d images imported", imported, len(items))
+		return nil
+	})
+}
+
+// importImages start the job pushing the images of the uploaded OCI layout or docker save tarball
+// under the names in it or the only image of it under the chosen repo:tag.
+func (a *apiClient) importImages(c echo.Context) error {
+	if !a.config.feature("transfer") {
+		return c.String(http.StatusNotFound, "Image transfer is disabled, see transfer_dir.")
+	}
+	tooLarge := fmt.Sprintf("Cannot import: %s.", errUploadTooLarge)
+	if !a.limitUploadBody(c) {
+		return c.String(http.StatusRequestEntityTooLarge, tooLarge)
+	}
+	file, err := c.FormFile("layout")
+	if bodyTooLarge(err) {
+		return c.String(http.StatusRequestEntityTooLarge, tooLarge)
+	}
+	if err != nil {
+		return c.String(http.StatusBadRequest, "OCI layout or docker save tarball is required.")
+	}
+	if limit := a.uploadLimit(); limit > 0 && file.Size > limit {
+		return c.String(http.StatusRequestEntityTooLarge, fmt.Sprintf("Cannot import %s: %s.", file.Filename, errUploadTooLarge))
+	}
+	src, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	f, layout, err := a.saveUpload(src)
+	if err != nil {
+		return c.String(http.StatusBadRequest, fmt.Sprintf("Cannot read %s: %s.", file.Filename, err))
+	}
+	items, err := importItems(layout, strings.TrimSpace(c.FormValue("image")))
+	if err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return c.String(http.StatusBadRequest, fmt.Sprintf("Cannot import %s: %s.", file.Filename, err))
+	}
+	j := a.startImport(c, f, layout, items, file.Filename)
+	return c.Redirect(http.StatusSeeOther, fmt.Sprintf("%s/jobs/%d", a.config.BasePath, j.ID))
+}
+
+// apiUpload push the images of OCI layout or docker save tarball sent as the body or "layout" form file,
+// responds with the job pushing them.
+func (a *apiClient) apiUpload(c echo.Context) error {
+	if !a.config.feature("transfer") {
+		return apiError(c, http.StatusNotFound, fmt.Errorf("image transfer is disabled, see transfer_dir"))
+	}
+	if !a.limitUploadBody(c) {
+		return apiError(c, http.StatusRequestEntityTooLarge, errUploadTooLarge)
+	}
+	var body io.Reader = c.Request().Body
+	source := "upload"
+	if strings.HasPrefix(c.Request().Header.Get(echo.HeaderContentType), echo.MIMEMultipartForm) {
+		file, err := c.FormFile("layout")
+		if bodyTooLarge(err) {
+			return apiError(c, http.StatusRequestEntityTooLarge, errUploadTooLarge)
+		}
+		if err != nil {
+			return apiError(c, http.StatusBadRequest, fmt.Errorf("layout file is required"))
+		}
+		src, err := file.Open()
+		if err != nil {
+			return apiError(c, http.StatusBadRequest, err)
+		}
+		defer src.Close()
+		body, source = src, file.Filename
+	}
+	f, layout, err := a.saveUpload(body)
+	if err == errUploadTooLarge || bodyTooLarge(err) {
+		return apiError(c, http.StatusRequestEntityTooLarge, err)
+	}
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, fmt.Errorf("cannot read the tarball: %s", err))
+	}
+	items, err := importItems(layout, strings.TrimSpace(c.QueryParam("image")))
+	if err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return apiError(c, http.StatusBadRequest, err)
+	}
+	j := a.startImport(c, f, layout, items, source)
+	return c.JSON(http.StatusAccepted, j.info())
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// trustedProxies networks of the proxies allowed to send the user and groups headers, empty trusts any source.
+type trustedProxies []*net.IPNet
+
+// parseTrustedProxies parse CIDRs or single IP addresses of the proxies.
+func parseTrustedProxies(list []string) (trustedProxies, error) {
+	var proxies trustedProxies
+	for i, s := range list {
+		if !strings.Contains(s, "/") {
+			ip := net.ParseIP(s)
+			if ip == nil {
+				return nil, fmt.Errorf("item %d: invalid IP address %q", i+1, s)
+			}
+			bits := 128
+			if ip.To4() != nil {
+				bits = 32
+			}
+			s = fmt.Sprintf("%s/%d", s, bits)
+		}
+		_, network, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, fmt.Errorf("item %d: %s", i+1, err)
+		}
+		proxies = append(proxies, network)
+	}
+	return proxies, nil
+}
+
+// trusts whether the request comes right from one of the proxies, by the address of the connection,
+// not by X-Forwarded-For which the client can send too.
+func (p trustedProxies) trusts(remoteAddr string) bool {
+	if len(p) == 0 {
+		return true
+	}
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, network := range p {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ipExtractor client IP from X-Forwarded-For set by the trusted proxies only, echo default when any source is trusted.
+func (p trustedProxies) ipExtractor() echo.IPExtractor {
+	if len(p) == 0 {
+		return nil
+	}
+	options := []echo.TrustOption{echo.TrustLoopback(false), echo.TrustLinkLocal(false), echo.TrustPrivateNet(false)}
+	for _, network := range p {
+		options = append(options, echo.TrustIPRange(network))
+	}
+	return echo.ExtractIPFromXFFHeader(options...)
+}
+
+// trustProxy middleware blanking the user and groups headers of the requests not coming from the trusted proxies,
+// so they cannot be spoofed by clients reaching the UI directly. Such requests are served as anonymous.
+// With local users no source is trusted unless the proxies are listed, the users log in by the form then.
+func (a *apiClient) trustProxy(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		req := c.Request()
+		if (a.config.LocalUsers && len(a.proxies) == 0) || !a.proxies.trusts(req.RemoteAddr) {
+			if req.Header.Get(a.config.UserHeader) != "" || req.Header.Get(a.config.groupsHeader()) != "" {
+				a.logger.Warnf("Ignoring %s header of the request from %s, it is not a trusted proxy", a.config.UserHeader, req.RemoteAddr)
+			}
+			req.Header.Del(a.config.UserHeader)
+			req.Header.Del(a.config.groupsHeader())
+		}
+		return next(c)
+	}
+}
+
+// requestUser the user authenticated by the proxy, empty for anonymous requests.
+func (a *apiClient) requestUser(c echo.Context) string {
+	return c.Request().Header.Get(a.config.UserHeader)
+}
+
+// requestGroups groups of the user sent by the proxy in the comma-separated header.
+func (a *apiClient) requestGroups(c echo.Context) []string {
+	var groups []string
+	for _, g := range strings.Split(c.Request().Header.Get(a.config.groupsHeader()), ",") {
+		if g = strings.TrimSpace(g); g != "" {
+			groups = append(groups, g)
+		}
+	}
+	return groups
+}
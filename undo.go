@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// deletedTagsLimit manifests of the deleted tags kept for undo, the oldest one is dropped first.
+const deletedTagsLimit = 100
+
+// deletedTag tag deleted from UI, its manifest is kept for delete_undo_minutes to push it back on undo.
+type deletedTag struct {
+	ID        int
+	Repo      string
+	Tag       string
+	Digest    string
+	User      string
+	Deleted   time.Time
+	mediaType string
+	manifest  string
+}
+
+// deletedTags the recently deleted tags in memory, they are lost on restart as the undo is meant for fat-finger
+// deletions only.
+type deletedTags struct {
+	mux    sync.Mutex
+	items  []deletedTag
+	lastID int
+}
+
+// MinutesLeft minutes until the undo expires, rounded up.
+func (d deletedTag) MinutesLeft(window time.Duration) int {
+	return int((window - time.Since(d.Deleted) + time.Minute - 1) / time.Minute)
+}
+
+// undoWindow how long the deleted tags can be restored, 0 when the undo is disabled.
+func (a *apiClient) undoWindow() time.Duration {
+	return time.Duration(a.config.DeleteUndoMinutes) * time.Minute
+}
+
+// rememberDeletion keep the manifest of the tag deleted by the user for undo, expired ones are dropped.
+func (a *apiClient) rememberDeletion(d deletedTag) {
+	a.deleted.mux.Lock()
+	defer a.deleted.mux.Unlock()
+	a.deleted.lastID++
+	d.ID = a.deleted.lastID
+	d.Deleted = time.Now()
+	items := []deletedTag{}
+	for _, i := range a.deleted.items {
+		if time.Since(i.Deleted) < a.undoWindow() {
+			items = append(items, i)
+		}
+	}
+	a.deleted.items = append(items, d)
+	if len(a.deleted.items) > deletedTagsLimit {
+		a.deleted.items = a.deleted.items[1:]
+	}
+}
+
+// pendingUndos tags of the repo deleted by the user which can still be restored, the latest first.
+func (a *apiClient) pendingUndos(user, repo string) []deletedTag {
+	a.deleted.mux.Lock()
+	defer a.deleted.mux.Unlock()
+	list := []deletedTag{}
+	for i := len(a.deleted.items) - 1; i >= 0; i-- {
+		d := a.deleted.items[i]
+		if d.User == user && d.Repo == repo && time.Since(d.Deleted) < a.undoWindow() {
+			list = append(list, d)
+		}
+	}
+	return list
+}
+
+// findDeletion the deleted tag which can still be restored.
+func (a *apiClient) findDeletion(id int) (deletedTag, bool) {
+	a.deleted.mux.Lock()
+	defer a.deleted.mux.Unlock()
+	for _, d := range a.deleted.items {
+		if d.ID == id {
+			return d, time.Since(d.Deleted) < a.undoWindow()
+		}
+	}
+	return deletedTag{}, false
+}
+
+// forgetDeletion remove the restored tag from the undo list.
+func (a *apiClient) forgetDeletion(id int) {
+	a.deleted.mux.Lock()
+	defer a.deleted.mux.Unlock()
+	for i, d := range a.deleted.items {
+		if d.ID == id {
+			a.deleted.items = append(a.deleted.items[:i], a.deleted.items[i+1:]...)
+			return
+		}
+	}
+}
+
+// undoDeletion push the manifest of the deleted tag back under the original tag. The blobs stay in the registry
+// until the garbage collection, so the image is restored as it was.
+func (a *apiClient) undoDeletion(c echo.Context) error {
+	data := a.setUserPermissions(c)
+	id, _ := strconv.Atoi(c.Param("id"))
+	d, ok := a.findDeletion(id)
+	if !ok {
+		return c.String(http.StatusNotFound, "Nothing to undo, the deletion is too old or restored already.")
+	}
+	if d.User != data["user"].String() && !data["isAdmin"].Bool() {
+		return a.forbidden(c, fmt.Sprintf("The tag is deleted by %s, only they or admins can restore it.", d.User))
+	}
+	if err := a.client.PutManifest(d.Repo, d.Tag, d.mediaType, d.manifest); err != nil {
+		a.log(c).Errorf("Cannot restore %s:%s: %s", d.Repo, d.Tag, err)
+		return c.String(http.StatusInternalServerError, fmt.Sprintf("Cannot restore %s:%s, the garbage collection may have removed its blobs: %s.",
+			d.Repo, d.Tag, err))
+	}
+	a.forgetDeletion(id)
+	a.trackAction(c, "undo")
+	a.audit(c, "undo", d.Repo, d.Tag, "Restored "+d.Digest)
+	return c.Redirect(http.StatusSeeOther, fmt.Sprintf("%s/%s/%s", a.config.BasePath, repoURLPath(d.Repo), d.Tag))
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/quiq/docker-registry-ui/registry"
+)
+
+const (
+	// uploadChunkLimit the largest chunk accepted, the browser sends 8 MB ones.
+	uploadChunkLimit = 64 << 20
+	// uploadExpiry the uploads not resumed for so long are removed.
+	uploadExpiry = 24 * time.Hour
+	// uploadFormOverhead room for the other form fields and boundaries of the form upload.
+	uploadFormOverhead = 1 << 20
+)
+
+var (
+	// errUploadTooLarge the tarball is over transfer_max_upload_mb.
+	errUploadTooLarge = errors.New("the tarball is larger than transfer_max_upload_mb")
+	// errUploadNotFound the upload is not started by the user, imported or expired.
+	errUploadNotFound = fmt.Errorf("upload not found, it expires in %s", uploadExpiry)
+)
+
+// chunkedUpload tarball uploaded by chunks from Transfer page, it is written to transfer_dir as received
+// so the browser can resume it from the offset after the connection breaks.
+type chunkedUpload struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	Offset int64  `json:"offset"`
+	user   string
+	// mux serializes the chunks.
+	mux sync.Mutex
+}
+
+// chunkedUploads the uploads in progress, the files of the previous runs are removed when expired.
+type chunkedUploads struct {
+	mux   sync.Mutex
+	items map[string]*chunkedUpload
+}
+
+// uploadLimit the largest tarball accepted in bytes, 0 when unlimited.
+func (a *apiClient) uploadLimit() int64 {
+	return int64(a.config.TransferMaxUploadMB) << 20
+}
+
+// limitUploadBody limit the request body by the upload limit before reading any of it, as the form files are
+// written to temporary files while parsing. The form fields and boundaries are let in above the limit.
+// Returns false when the client tells the body is larger already.
+func (a *apiClient) limitUploadBody(c echo.Context) bool {
+	limit := a.uploadLimit()
+	if limit == 0 {
+		return true
+	}
+	limit += uploadFormOverhead
+	req := c.Request()
+	if req.ContentLength > limit {
+		return false
+	}
+	req.Body = http.MaxBytesReader(c.Response(), req.Body, limit)
+	return true
+}
+
+// bodyTooLarge whether reading the request body failed on the limit of limitUploadBody.
+func bodyTooLarge(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "request body too large")
+}
+
+// uploadPath file the upload is written to.
+func (a *apiClient) uploadPath(id string) string {
+	return filepath.Join(a.config.TransferDir, "upload-"+id+".partial")
+}
+
+// removeExpiredUploads remove the uploads not resumed for uploadExpiry, the file is touched by every chunk.
+func (a *apiClient) removeExpiredUploads() {
+	files, _ := filepath.Glob(filepath.Join(a.config.TransferDir, "upload-*.partial"))
+	for _, path := range files {
+		if fi, err := os.Stat(path); err == nil && time.Since(fi.ModTime()) > uploadExpiry {
+			a.logger.Infof("Removing expired upload %s", path)
+			os.Remove(path)
+		}
+	}
+	for id := range a.uploads.items {
+		if _, err := os.Stat(a.uploadPath(id)); err != nil {
+			delete(a.uploads.items, id)
+		}
+	}
+}
+
+// findUpload the upload started by the user, nil if there is none.
+func (a *apiClient) findUpload(c echo.Context) *chunkedUpload {
+	a.uploads.mux.Lock()
+	defer a.uploads.mux.Unlock()
+	u, ok := a.uploads.items[c.Param("id")]
+	if !ok || u.user != a.setUserPermissions(c)["user"].String() {
+		return nil
+	}
+	return u
+}
+
+// startUpload start the chunked upload of the tarball of the given name and size.
+func (a *apiClient) startUpload(c echo.Context) error {
+	if !a.config.feature("transfer") {
+		return apiError(c, http.StatusNotFound, fmt.Errorf("image transfer is disabled, see transfer_dir"))
+	}
+	size, err := strconv.ParseInt(c.FormValue("size"), 10, 64)
+	if err != nil || size <= 0 {
+		return apiError(c, http.StatusBadRequest, fmt.Errorf("size of the tarball is required"))
+	}
+	if limit := a.uploadLimit(); limit > 0 && size > limit {
+		return apiError(c, http.StatusRequestEntityTooLarge, errUploadTooLarge)
+	}
+	b := make([]byte, 16)
+	rand.Read(b)
+	u := &chunkedUpload{ID: hex.EncodeToString(b), Name: filepath.Base(c.FormValue("name")), Size: size,
+		user: a.setUserPermissions(c)["user"].String()}
+	f, err := os.Create(a.uploadPath(u.ID))
+	if err != nil {
+		return apiError(c, http.StatusInternalServerError, err)
+	}
+	f.Close()
+
+	a.uploads.mux.Lock()
+	defer a.uploads.mux.Unlock()
+	a.removeExpiredUploads()
+	if a.uploads.items == nil {
+		a.uploads.items = map[string]*chunkedUpload{}
+	}
+	a.uploads.items[u.ID] = u
+	a.trackAction(c, "upload")
+	return c.JSON(http.StatusCreated, u)
+}
+
+// uploadStatus offset to resume the upload from.
+func (a *apiClient) uploadStatus(c echo.Context) error {
+	u := a.findUpload(c)
+	if u == nil {
+		return apiError(c, http.StatusNotFound, errUploadNotFound)
+	}
+	u.mux.Lock()
+	defer u.mux.Unlock()
+	return c.JSON(http.StatusOK, u)
+}
+
+// uploadChunk append the chunk sent at the offset of the upload, the bytes received before the connection
+// breaks are kept too. Responds with the offset to continue from, 409 when the chunk is not at it.
+func (a *apiClient) uploadChunk(c echo.Context) error {
+	u := a.findUpload(c)
+	if u == nil {
+		return apiError(c, http.StatusNotFound, errUploadNotFound)
+	}
+	u.mux.Lock()
+	defer u.mux.Unlock()
+	offset, err := strconv.ParseInt(c.QueryParam("offset"), 10, 64)
+	if err != nil || offset != u.Offset {
+		return c.JSON(http.StatusConflict, u)
+	}
+	f, err := os.OpenFile(a.uploadPath(u.ID), os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return apiError(c, http.StatusNotFound, errUploadNotFound)
+	}
+	defer f.Close()
+	limit := u.Size - u.Offset
+	if limit > uploadChunkLimit {
+		limit = uploadChunkLimit
+	}
+	n, err := io.Copy(f, io.LimitReader(c.Request().Body, limit))
+	u.Offset += n
+	if err != nil {
+		a.log(c).Warnf("Upload %s interrupted at %d of %d bytes: %s", u.ID, u.Offset, u.Size, err)
+		return apiError(c, http.StatusBadRequest, err)
+	}
+	// Anything left in the body is over the size or the chunk limit.
+	if extra, _ := io.CopyN(ioutil.Discard, c.Request().Body, 1); extra > 0 {
+		return apiError(c, http.StatusRequestEntityTooLarge, fmt.Errorf("chunk is over the size of the tarball or %d MB", uploadChunkLimit>>20))
+	}
+	return c.JSON(http.StatusOK, u)
+}
+
+// importUpload start the job pushing the images of the completed upload, see importImages.
+func (a *apiClient) importUpload(c echo.Context) error {
+	u := a.findUpload(c)
+	if u == nil {
+		return apiError(c, http.StatusNotFound, errUploadNotFound)
+	}
+	u.mux.Lock()
+	defer u.mux.Unlock()
+	if u.Offset != u.Size {
+		return apiError(c, http.StatusConflict, fmt.Errorf("upload is incomplete, %d of %d bytes received", u.Offset, u.Size))
+	}
+	f, err := os.Open(a.uploadPath(u.ID))
+	if err != nil {
+		return apiError(c, http.StatusNotFound, err)
+	}
+	layout, err := registry.OpenLayout(f)
+	if err != nil {
+		f.Close()
+		a.forgetUpload(u.ID)
+		return apiError(c, http.StatusBadRequest, fmt.Errorf("cannot read %s: %s", u.Name, err))
+	}
+	// The upload is kept to retry with another name.
+	items, err := importItems(layout, strings.TrimSpace(c.FormValue("image")))
+	if err != nil {
+		f.Close()
+		return apiError(c, http.StatusBadRequest, fmt.Errorf("cannot import %s: %s", u.Name, err))
+	}
+	a.uploads.mux.Lock()
+	delete(a.uploads.items, u.ID)
+	a.uploads.mux.Unlock()
+	j := a.startImport(c, f, layout, items, u.Name)
+	return c.JSON(http.StatusAccepted, j.info())
+}
+
+// cancelUpload remove the upload and its file.
+func (a *apiClient) cancelUpload(c echo.Context) error {
+	u := a.findUpload(c)
+	if u == nil {
+		return apiError(c, http.StatusNotFound, errUploadNotFound)
+	}
+	u.mux.Lock()
+	defer u.mux.Unlock()
+	a.forgetUpload(u.ID)
+	return c.NoContent(http.StatusNoContent)
+}
+
+// forgetUpload drop the upload and remove its file.
+func (a *apiClient) forgetUpload(id string) {
+	a.uploads.mux.Lock()
+	defer a.uploads.mux.Unlock()
+	delete(a.uploads.items, id)
+	os.Remove(a.uploadPath(id))
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/quiq/docker-registry-ui/registry"
+)
+
+// Limits of the usage counters, the least recently seen users and IPs are dropped over them,
+// so the counters do not grow with e.g. anonymous clients from changing addresses.
+const (
+	maxUsageUsers = 1000
+	maxUsageIPs   = 100
+)
+
+// usageStats in-memory activity counters per user.
+type usageStats struct {
+	mux   sync.Mutex
+	users map[string]*userUsage
+}
+
+type userUsage struct {
+	User      string
+	PageViews int
+	Actions   map[string]int
+	// IPs the user came from with the time last seen from each.
+	IPs      map[string]time.Time
+	LastSeen time.Time
+}
+
+// ActionsCount total count of actions.
+func (u userUsage) ActionsCount() int {
+	count := 0
+	for _, n := range u.Actions {
+		count = count + n
+	}
+	return count
+}
+
+// ActionsSummary list actions with their counts.
+func (u userUsage) ActionsSummary() string {
+	var list []string
+	for _, k := range registry.SortedMapKeys(u.Actions) {
+		list = append(list, fmt.Sprintf("%s: %d", k, u.Actions[k]))
+	}
+	return strings.Join(list, ", ")
+}
+
+// IPsCount count of distinct IP addresses the user came from.
+func (u userUsage) IPsCount() int {
+	return len(u.IPs)
+}
+
+func newUsageStats() *usageStats {
+	return &usageStats{users: map[string]*userUsage{}}
+}
+
+// get return usage record of the user, has to be called under lock.
+func (s *usageStats) get(user, ip string) *userUsage {
+	now := time.Now()
+	u, ok := s.users[user]
+	if !ok {
+		if len(s.users) >= maxUsageUsers {
+			var oldest *userUsage
+			for _, o := range s.users {
+				if oldest == nil || o.LastSeen.Before(oldest.LastSeen) {
+					oldest = o
+				}
+			}
+			delete(s.users, oldest.User)
+		}
+		u = &userUsage{User: user, Actions: map[string]int{}, IPs: map[string]time.Time{}}
+		s.users[user] = u
+	}
+	if _, ok := u.IPs[ip]; !ok && len(u.IPs) >= maxUsageIPs {
+		oldest, oldestSeen := "", now
+		for addr, seen := range u.IPs {
+			if seen.Before(oldestSeen) {
+				oldest, oldestSeen = addr, seen
+			}
+		}
+		delete(u.IPs, oldest)
+	}
+	u.IPs[ip] = now
+	u.LastSeen = now
+	return u
+}
+
+// pageView count a page view by the user.
+func (s *usageStats) pageView(user, ip string) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.get(user, ip).PageViews++
+}
+
+// action count an action performed by the user.
+func (s *usageStats) action(user, ip, action string) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.get(user, ip).Actions[action]++
+}
+
+// list return copy of usage records sorted by the user name.
+func (s *usageStats) list() []userUsage {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	list := make([]userUsage, 0, len(s.users))
+	for _, u := range s.users {
+		c := *u
+		c.Actions = map[string]int{}
+		for k, v := range u.Actions {
+			c.Actions[k] = v
+		}
+		c.IPs = map[string]time.Time{}
+		for k, v := range u.IPs {
+			c.IPs[k] = v
+		}
+		list = append(list, c)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].User < list[j].User })
+	return list
+}
+
+// trackPageViews middleware counting rendered pages per user.
+func (a *apiClient) trackPageViews(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		err := next(c)
+		if c.Request().Method == http.MethodGet && c.Response().Status == http.StatusOK &&
+			strings.HasPrefix(c.Response().Header().Get(echo.HeaderContentType), echo.MIMETextHTML) {
+			a.usage.pageView(a.requestUser(c), c.RealIP())
+		}
+		return err
+	}
+}
+
+// trackAction count an action performed by the user making the request.
+func (a *apiClient) trackAction(c echo.Context, action string) {
+	a.usage.action(a.requestUser(c), c.RealIP(), action)
+}
+
+// viewUsage view activity of users.
+func (a *apiClient) viewUsage(c echo.Context) error {
+	data := a.setUserPermissions(c)
+	data.Set("usage", a.usage.list())
+	return c.Render(http.StatusOK, "usage.html", data)
+}
+
+// viewerOf identify who browses the UI: user name or IP address for anonymous users.
+func (a *apiClient) viewerOf(c echo.Context) string {
+	if user := a.requestUser(c); user != "" {
+		return user
+	}
+	return c.RealIP()
+}
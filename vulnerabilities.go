@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/quiq/docker-registry-ui/events"
+)
+
+// defaultAcceptanceDays how long vulnerability acceptance is valid by default.
+const defaultAcceptanceDays = 90
+
+var vulnIDRegexp = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._:-]{1,99}$`)
+
+// viewVulnAcceptances view accepted vulnerabilities to manage them.
+func (a *apiClient) viewVulnAcceptances(c echo.Context) error {
+	data := a.setUserPermissions(c)
+	data.Set("acceptances", a.eventListener.GetVulnAcceptances())
+	data.Set("now", time.Now())
+	data.Set("today", time.Now().Format("2006-01-02"))
+	data.Set("defaultExpires", time.Now().AddDate(0, 0, defaultAcceptanceDays).Format("2006-01-02"))
+	data.Set("repository", c.QueryParam("repository"))
+	data.Set("tag", c.QueryParam("tag"))
+	data.Set("vulnerability", c.QueryParam("vulnerability"))
+	return c.Render(http.StatusOK, "vulnerabilities.html", data)
+}
+
+// acceptVulnerability accept the vulnerability for the image or all images of the repo until the expiry date.
+func (a *apiClient) acceptVulnerability(c echo.Context) error {
+	v := events.VulnAcceptance{
+		Repository:    strings.TrimSpace(c.FormValue("repository")),
+		Tag:           strings.TrimSpace(c.FormValue("tag")),
+		Vulnerability: strings.TrimSpace(c.FormValue("vulnerability")),
+		Reason:        strings.TrimSpace(c.FormValue("reason")),
+		Expires:       strings.TrimSpace(c.FormValue("expires")),
+		User:          a.setUserPermissions(c)["user"].String(),
+	}
+	if v.Repository == "" || !vulnIDRegexp.MatchString(v.Vulnerability) {
+		return c.String(http.StatusBadRequest, "Repository and vulnerability ID, e.g. CVE-2021-3449, should be set.")
+	}
+	if v.Reason == "" {
+		return c.String(http.StatusBadRequest, "Reason for accepting the vulnerability is required.")
+	}
+	if v.Expires != "" {
+		expires, err := time.Parse("2006-01-02", v.Expires)
+		if err != nil || v.Expired(time.Now()) {
+			return c.String(http.StatusBadRequest, "Expiry date should be today or later as YYYY-MM-DD.")
+		}
+		v.Expires = expires.Format("2006-01-02")
+	}
+	if err := a.eventListener.AcceptVulnerability(v); err != nil {
+		a.log(c).Error(err)
+		return c.String(http.StatusInternalServerError, "Cannot accept vulnerability, see the log for request "+requestIDOf(c)+".")
+	}
+	a.trackAction(c, "accept-vulnerability")
+	a.audit(c, "accept-vulnerability", v.Repository, v.Tag, fmt.Sprintf("%s %s. %s", v.Vulnerability, expiresText(v.Expires), v.Reason))
+	return c.Redirect(http.StatusSeeOther, a.config.BasePath+"/vulnerabilities")
+}
+
+// revokeVulnAcceptance delete the acceptance, the vulnerability alarms again.
+func (a *apiClient) revokeVulnAcceptance(c echo.Context) error {
+	id, _ := strconv.Atoi(c.Param("id"))
+	for _, v := range a.eventListener.GetVulnAcceptances() {
+		if v.ID != id {
+			continue
+		}
+		if err := a.eventListener.RevokeVulnAcceptance(id); err != nil {
+			a.log(c).Error(err)
+			return c.String(http.StatusInternalServerError, "Cannot revoke acceptance, see the log for request "+requestIDOf(c)+".")
+		}
+		a.trackAction(c, "revoke-vulnerability")
+		a.audit(c, "revoke-vulnerability", v.Repository, v.Tag, v.Vulnerability)
+	}
+	return c.Redirect(http.StatusSeeOther, a.config.BasePath+"/vulnerabilities")
+}
+
+// expiresText expiry of the acceptance for humans.
+func expiresText(expires string) string {
+	if expires == "" {
+		return "without expiry"
+	}
+	return "until " + expires
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Formats of the webhook messages, the raw JSON is for scripts and CI.
+const (
+	webhookFormatJSON  = ""
+	webhookFormatSlack = "slack"
+	webhookFormatTeams = "teams"
+)
+
+// webhookFormats formats the webhooks can be added with, by the name shown.
+var webhookFormats = []struct {
+	Name  string
+	Title string
+}{
+	{webhookFormatJSON, "JSON"},
+	{webhookFormatSlack, "Slack"},
+	{webhookFormatTeams, "Microsoft Teams"},
+}
+
+// validWebhookFormat whether the format is known.
+func validWebhookFormat(format string) bool {
+	for _, f := range webhookFormats {
+		if f.Name == format {
+			return true
+		}
+	}
+	return false
+}
+
+// webhookVerbs how the actions read in the messages.
+var webhookVerbs = map[string]string{
+	"push":      "pushed",
+	"pull":      "pulled",
+	"delete":    "deleted",
+	webhookPing: "sent a test event for",
+}
+
+// subject what the event is about, the image or the repo pattern of the ping.
+func (p webhookPayload) subject() string {
+	if p.Action == webhookPing {
+		return p.Repository
+	}
+	if p.Tag == "" && p.Digest != "" {
+		return p.Image + "@" + p.Digest
+	}
+	if len(p.Tags) > 1 {
+		return p.Image + ", " + strings.Join(p.Tags[1:], ", ")
+	}
+	return p.Image
+}
+
+// summary who did what, e.g. "ci pushed registry.local/team/app:v1".
+func (p webhookPayload) summary() string {
+	user := p.User
+	if user == "" {
+		user = "Anonymous"
+	}
+	return fmt.Sprintf("%s %s %s", user, webhookVerbs[p.Action], p.subject())
+}
+
+// facts the details shown on the cards, the empty ones are left out.
+func (p webhookPayload) facts() [][2]string {
+	var list [][2]string
+	tag := [2]string{"Tag", p.Tag}
+	if len(p.Tags) > 1 {
+		tag = [2]string{"Tags", strings.Join(p.Tags, ", ")}
+	}
+	for _, f := range [][2]string{{"Repository", p.Repository}, tag, {"Digest", p.Digest}, {"User", p.User}, {"IP", p.IP}} {
+		if f[1] != "" {
+			list = append(list, f)
+		}
+	}
+	return list
+}
+
+// slackEscape escape the control characters of Slack mrkdwn.
+func slackEscape(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
+
+// slackMessage message for Slack incoming webhook with the details and the button back to the UI,
+// the text is the fallback for notifications.
+func slackMessage(p webhookPayload) interface{} {
+	type text struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}
+	fields := []text{}
+	for _, f := range p.facts() {
+		fields = append(fields, text{"mrkdwn", fmt.Sprintf("*%s*\n%s", f[0], slackEscape(f[1]))})
+	}
+	user := p.User
+	if user == "" {
+		user = "Anonymous"
+	}
+	headline := fmt.Sprintf("*%s* %s <%s|%s>", slackEscape(user), webhookVerbs[p.Action], p.URL, slackEscape(p.subject()))
+	return map[string]interface{}{
+		"text": slackEscape(p.summary()),
+		"blocks": []interface{}{
+			map[string]interface{}{"type": "section", "text": text{"mrkdwn", headline}},
+			map[string]interface{}{"type": "section", "fields": fields},
+			map[string]interface{}{"type": "actions", "elements": []interface{}{
+				map[string]interface{}{"type": "button", "text": text{"plain_text", "Open in Registry UI"}, "url": p.URL},
+			}},
+		},
+	}
+}
+
+// teamsMessage Adaptive Card accepted by Teams workflows and incoming webhooks.
+func teamsMessage(p webhookPayload) interface{} {
+	facts := []map[string]string{}
+	for _, f := range p.facts() {
+		facts = append(facts, map[string]string{"title": f[0], "value": f[1]})
+	}
+	card := map[string]interface{}{
+		"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+		"type":    "AdaptiveCard",
+		"version": "1.4",
+		"body": []interface{}{
+			map[string]interface{}{"type": "TextBlock", "text": p.summary(), "weight": "Bolder", "wrap": true},
+			map[string]interface{}{"type": "FactSet", "facts": facts},
+		},
+		"actions": []interface{}{
+			map[string]interface{}{"type": "Action.OpenUrl", "title": "Open in Registry UI", "url": p.URL},
+		},
+	}
+	return map[string]interface{}{
+		"type": "message",
+		"attachments": []interface{}{
+			map[string]interface{}{"contentType": "application/vnd.microsoft.card.adaptive", "content": card},
+		},
+	}
+}
+
+// webhookBody the payload formatted for the webhook.
+func webhookBody(format string, p webhookPayload) []byte {
+	var message interface{} = p
+	switch format {
+	case webhookFormatSlack:
+		message = slackMessage(p)
+	case webhookFormatTeams:
+		message = teamsMessage(p)
+	}
+	body, _ := json.Marshal(message)
+	return body
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/quiq/docker-registry-ui/events"
+	"github.com/quiq/docker-registry-ui/registry"
+)
+
+const (
+	// webhookTimeout how long to wait for the webhook to accept the event.
+	webhookTimeout = 10 * time.Second
+	// webhookRetryDelay delay before the second attempt, it doubles for every next one.
+	webhookRetryDelay = 10 * time.Second
+	// webhookPing action of the test event sent from UI.
+	webhookPing = "ping"
+)
+
+// webhookActions event actions the webhooks can subscribe to.
+var webhookActions = []string{"push", "pull", "delete"}
+
+// webhookPayload JSON posted to the webhook.
+type webhookPayload struct {
+	Action     string `json:"action"`
+	Repository string `json:"repository"`
+	Tag        string `json:"tag"`
+	// Tags all tags of the aggregated push, Tag is the first of them.
+	Tags      []string  `json:"tags,omitempty"`
+	Digest    string    `json:"digest"`
+	Image     string    `json:"image"`
+	User      string    `json:"user"`
+	IP        string    `json:"ip"`
+	Timestamp time.Time `json:"timestamp"`
+	URL       string    `json:"url"`
+}
+
+// webhookSignature HMAC-SHA256 of the body by the secret as hex, GitHub sends the same in X-Hub-Signature-256.
+func webhookSignature(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// canManageWebhook whether all repos of the webhook pattern are within the tenant scope of the user,
+// so a prefix pattern needs a prefix pattern of the scope as wide or wider.
+func canManageWebhook(scope []string, repos string) bool {
+	if scope == nil || !strings.HasSuffix(repos, "*") {
+		return inScope(scope, repos)
+	}
+	prefix := strings.TrimSuffix(repos, "*")
+	for _, p := range scope {
+		if strings.HasSuffix(p, "*") && strings.HasPrefix(prefix, strings.TrimSuffix(p, "*")) {
+			return true
+		}
+	}
+	return false
+}
+
+// postWebhook post the payload once, returns the status code, 0 when there was no response.
+func postWebhook(w events.Webhook, action, delivery string, body []byte) (int, error) {
+	req, err := http.NewRequest("POST", w.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "docker-registry-ui")
+	req.Header.Set("X-Registry-UI-Event", action)
+	req.Header.Set("X-Registry-UI-Delivery", delivery)
+	if w.Secret != "" {
+		req.Header.Set("X-Hub-Signature-256", webhookSignature(w.Secret, body))
+	}
+	resp, err := (&http.Client{Timeout: webhookTimeout}).Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	// The response body is not kept, it may tell the internals of the receiving service to the UI users.
+	if resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("%s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// deliverWebhook post the payload in the format of the webhook retrying with doubling delay, every attempt is logged.
+func (a *apiClient) deliverWebhook(w events.Webhook, p webhookPayload, attempts int) {
+	body := webhookBody(w.Format, p)
+	b := make([]byte, 8)
+	rand.Read(b)
+	delivery := hex.EncodeToString(b)
+	delay := webhookRetryDelay
+	for attempt := 1; attempt <= attempts; attempt++ {
+		status, err := postWebhook(w, p.Action, delivery, body)
+		d := events.WebhookDelivery{WebhookID: w.ID, Action: p.Action, Repository: p.Repository, Tag: p.Tag, Attempt: attempt, Status: status}
+		if err != nil {
+			d.Error = err.Error()
+		}
+		if err := a.eventListener.AddWebhookDelivery(d); err != nil {
+			a.logger.Error(err)
+		}
+		if err == nil {
+			return
+		}
+		a.logger.Warnf("Cannot deliver %s of %s:%s to webhook %d, attempt %d of %d: %s",
+			p.Action, p.Repository, p.Tag, w.ID, attempt, attempts, err)
+		if attempt < attempts {
+			time.Sleep(delay)
+			delay *= 2
+		}
+	}
+}
+
+// notifyEvents deliver the events to the webhooks registered for their repos and email the recipients
+// of the matching email rules in background. Blob pushes are not tags, so they are skipped.
+// The links point to the UI at baseURL.
+func (a *apiClient) notifyEvents(baseURL string, list []events.Event) {
+	var hooks []events.Webhook
+	for _, e := range list {
+		if e.Action == "push" && e.Tag == "" {
+			continue
+		}
+		if hooks == nil {
+			hooks = a.eventListener.GetWebhooks()
+		}
+		p := webhookPayload{
+			Action: e.Action, Repository: e.Repository, Tag: e.Tag, Tags: e.Tags, Digest: e.Digest, Image: a.config.imageName(e.Repository),
+			User: e.User, IP: e.IP, Timestamp: time.Now().UTC(), URL: baseURL + "/" + repoURLPath(e.Repository),
+		}
+		if e.Tag != "" {
+			p.Image += ":" + e.Tag
+			p.URL += "/" + url.PathEscape(e.Tag)
+		}
+		for _, w := range hooks {
+			if w.Matches(e.Action, e.Repository) {
+				go a.deliverWebhook(w, p, a.config.WebhookAttempts)
+			}
+		}
+		a.notifyEmail(p)
+	}
+}
+
+// notifyDeletedTag notify the webhooks and email rules of the tag deleted from UI, the registry events of UI's own calls are ignored.
+func (a *apiClient) notifyDeletedTag(baseURL, user, ip, repo, tag string) {
+	a.notifyEvents(baseURL, []events.Event{{Action: "delete", Repository: repo, Tag: tag, User: user, IP: ip}})
+}
+
+// uiBaseURL URL of the UI as requested, for links in notifications.
+func (a *apiClient) uiBaseURL(c echo.Context) string {
+	return c.Scheme() + "://" + c.Request().Host + a.config.BasePath
+}
+
+// findWebhook the webhook within the tenant scope of the user.
+func (a *apiClient) findWebhook(c echo.Context) (events.Webhook, bool) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	for _, w := range a.eventListener.GetWebhooks() {
+		if w.ID == id && canManageWebhook(a.tenantScope(c), w.Repos) {
+			return w, true
+		}
+	}
+	return events.Webhook{}, false
+}
+
+// viewWebhooks view the webhooks of the repos the user can see.
+func (a *apiClient) viewWebhooks(c echo.Context) error {
+	if !a.config.feature("events") {
+		return c.String(http.StatusNotFound, "Webhooks need the event listener, it is disabled by features setting.")
+	}
+	scope := a.tenantScope(c)
+	list := []events.Webhook{}
+	for _, w := range a.eventListener.GetWebhooks() {
+		if canManageWebhook(scope, w.Repos) {
+			list = append(list, w)
+		}
+	}
+	data := a.setUserPermissions(c)
+	data.Set("webhooks", list)
+	data.Set("actions", webhookActions)
+	data.Set("attempts", a.config.WebhookAttempts)
+	data.Set("formats", webhookFormats)
+	data.Set("repos", c.QueryParam("repos"))
+	return c.Render(http.StatusOK, "webhooks.html", data)
+}
+
+// addWebhook register the webhook for the matching repos.
+func (a *apiClient) addWebhook(c echo.Context) error {
+	if !a.config.feature("events") {
+		return c.String(http.StatusNotFound, "Webhooks need the event listener, it is disabled by features setting.")
+	}
+	form, _ := c.FormParams()
+	w := events.Webhook{
+		Repos:  strings.Trim(strings.TrimSpace(c.FormValue("repos")), "/"),
+		URL:    strings.TrimSpace(c.FormValue("url")),
+		Secret: strings.TrimSpace(c.FormValue("secret")),
+		Format: c.FormValue("format"),
+		User:   a.setUserPermissions(c)["user"].String(),
+	}
+	for _, action := range form["actions"] {
+		if registry.ItemInSlice(action, webhookActions) {
+			w.Actions = append(w.Actions, action)
+		}
+	}
+	if u, err := url.Parse(w.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return c.String(http.StatusBadRequest, "Webhook URL should be http or https URL.")
+	}
+	if !validWebhookFormat(w.Format) {
+		return c.String(http.StatusBadRequest, "Unknown webhook format.")
+	}
+	if !validRepoPattern(w.Repos) || len(w.Actions) == 0 {
+		return c.String(http.StatusBadRequest, "Repository pattern, e.g. team/app or team/*, and at least one event should be set.")
+	}
+	if !canManageWebhook(a.tenantScope(c), w.Repos) {
+		return a.forbidden(c, "The repositories are outside of your tenant.")
+	}
+	if err := a.eventListener.AddWebhook(w); err != nil {
+		a.log(c).Error(err)
+		return c.String(http.StatusInternalServerError, "Cannot add webhook, see the log for request "+requestIDOf(c)+".")
+	}
+	a.trackAction(c, "add-webhook")
+	a.audit(c, "add-webhook", w.Repos, "", w.URL)
+	return c.Redirect(http.StatusSeeOther, a.config.BasePath+"/webhooks")
+}
+
+// deleteWebhook delete the webhook with its delivery log.
+func (a *apiClient) deleteWebhook(c echo.Context) error {
+	w, ok := a.findWebhook(c)
+	if !ok {
+		return c.String(http.StatusNotFound, "Webhook not found.")
+	}
+	if err := a.eventListener.DeleteWebhook(w.ID); err != nil {
+		a.log(c).Error(err)
+		return c.String(http.StatusInternalServerError, "Cannot delete webhook, see the log for request "+requestIDOf(c)+".")
+	}
+	a.trackAction(c, "delete-webhook")
+	a.audit(c, "delete-webhook", w.Repos, "", w.URL)
+	return c.Redirect(http.StatusSeeOther, a.config.BasePath+"/webhooks")
+}
+
+// testWebhook send ping event to the webhook once, the result is in its delivery log.
+func (a *apiClient) testWebhook(c echo.Context) error {
+	w, ok := a.findWebhook(c)
+	if !ok {
+		return c.String(http.StatusNotFound, "Webhook not found.")
+	}
+	p := webhookPayload{
+		Action: webhookPing, Repository: w.Repos, User: a.setUserPermissions(c)["user"].String(),
+		IP: c.RealIP(), Timestamp: time.Now().UTC(), URL: a.uiBaseURL(c) + "/webhooks/" + strconv.Itoa(w.ID),
+	}
+	a.deliverWebhook(w, p, 1)
+	a.trackAction(c, "test-webhook")
+	return c.Redirect(http.StatusSeeOther, fmt.Sprintf("%s/webhooks/%d", a.config.BasePath, w.ID))
+}
+
+// viewWebhookDeliveries view the delivery log of the webhook.
+func (a *apiClient) viewWebhookDeliveries(c echo.Context) error {
+	w, ok := a.findWebhook(c)
+	if !ok {
+		return c.String(http.StatusNotFound, "Webhook not found.")
+	}
+	data := a.setUserPermissions(c)
+	data.Set("webhook", w)
+	data.Set("deliveries", a.eventListener.GetWebhookDeliveries(w.ID))
+	data.Set("limit", events.WebhookDeliveriesLimit)
+	return c.Render(http.StatusOK, "webhook_deliveries.html", data)
+}
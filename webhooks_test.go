@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/quiq/docker-registry-ui/events"
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestWebhooks(t *testing.T) {
+	convey.Convey("Manage only the webhooks of the repos within the tenant scope", t, func() {
+		convey.So(canManageWebhook(nil, "*"), convey.ShouldBeTrue)
+		scope := []string{"team-a/app", "team-b/*"}
+		convey.So(canManageWebhook(scope, "team-a/app"), convey.ShouldBeTrue)
+		convey.So(canManageWebhook(scope, "team-a/app*"), convey.ShouldBeFalse)
+		convey.So(canManageWebhook(scope, "team-a/apple"), convey.ShouldBeFalse)
+		convey.So(canManageWebhook(scope, "team-b/*"), convey.ShouldBeTrue)
+		convey.So(canManageWebhook(scope, "team-b/app*"), convey.ShouldBeTrue)
+		convey.So(canManageWebhook(scope, "team-b*"), convey.ShouldBeFalse)
+		convey.So(canManageWebhook(scope, "*"), convey.ShouldBeFalse)
+		convey.So(canManageWebhook([]string{}, "team-a/app"), convey.ShouldBeFalse)
+	})
+
+	convey.Convey("Keep only the status of the failed delivery", t, func() {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprint(w, "connection to db-internal:5432 refused")
+		}))
+		defer server.Close()
+		status, err := postWebhook(events.Webhook{URL: server.URL}, "push", "1", []byte("{}"))
+		convey.So(status, convey.ShouldEqual, http.StatusInternalServerError)
+		convey.So(err.Error(), convey.ShouldEqual, "500 Internal Server Error")
+	})
+}